@@ -160,13 +160,64 @@ func runAudio(cmd *cobra.Command, args []string) error {
 	}
 }
 
-func runVeniceAudio(cmd *cobra.Command, cfg *config.Config, text string, temperature, topP *float64) error {
+// veniceAudioAPIKey returns the Venice TTS key, falling back to the Venice
+// image key.
+func veniceAudioAPIKey(cfg *config.Config) (string, error) {
 	apiKey := strings.TrimSpace(cfg.Audio.Venice.APIKey)
 	if apiKey == "" {
 		apiKey = strings.TrimSpace(cfg.Image.Venice.APIKey)
 	}
 	if apiKey == "" {
-		return fmt.Errorf("VENICE_API_KEY not configured. Set environment variable or add to audio.venice.api_key in config")
+		return "", fmt.Errorf("VENICE_API_KEY not configured. Set environment variable or add to audio.venice.api_key in config")
+	}
+	return apiKey, nil
+}
+
+// geminiAudioAPIKey returns the Gemini TTS key, falling back to the Gemini
+// image key and then the gemini text provider.
+func geminiAudioAPIKey(cfg *config.Config) (string, error) {
+	apiKey := strings.TrimSpace(cfg.Audio.Gemini.APIKey)
+	if apiKey == "" {
+		apiKey = strings.TrimSpace(cfg.Image.Gemini.APIKey)
+	}
+	if apiKey == "" {
+		geminiProvider, err := cfg.GetResolvedProviderConfig("gemini")
+		if err != nil {
+			return "", fmt.Errorf("gemini provider: %w", err)
+		}
+		if geminiProvider != nil {
+			apiKey = strings.TrimSpace(geminiProvider.ResolvedAPIKey)
+		}
+	}
+	if apiKey == "" {
+		return "", fmt.Errorf("GEMINI_API_KEY not configured. Set environment variable or add to audio.gemini.api_key in config")
+	}
+	return apiKey, nil
+}
+
+// elevenLabsAudioAPIKey returns the ElevenLabs TTS key, falling back to the
+// elevenlabs text provider.
+func elevenLabsAudioAPIKey(cfg *config.Config) (string, error) {
+	apiKey := strings.TrimSpace(cfg.Audio.ElevenLabs.APIKey)
+	if apiKey == "" {
+		elevenLabsProvider, err := cfg.GetResolvedProviderConfig("elevenlabs")
+		if err != nil {
+			return "", fmt.Errorf("elevenlabs provider: %w", err)
+		}
+		if elevenLabsProvider != nil {
+			apiKey = strings.TrimSpace(elevenLabsProvider.ResolvedAPIKey)
+		}
+	}
+	if apiKey == "" {
+		return "", fmt.Errorf("ELEVENLABS_API_KEY not configured. Set environment variable or add to audio.elevenlabs.api_key in config")
+	}
+	return apiKey, nil
+}
+
+func runVeniceAudio(cmd *cobra.Command, cfg *config.Config, text string, temperature, topP *float64) error {
+	apiKey, err := veniceAudioAPIKey(cfg)
+	if err != nil {
+		return err
 	}
 
 	model := firstNonEmpty(audioModel, cfg.Audio.Venice.Model, audio.DefaultModel)
@@ -200,21 +251,9 @@ func runVeniceAudio(cmd *cobra.Command, cfg *config.Config, text string, tempera
 }
 
 func runGeminiAudio(cmd *cobra.Command, cfg *config.Config, text string, temperature, topP *float64) error {
-	apiKey := strings.TrimSpace(cfg.Audio.Gemini.APIKey)
-	if apiKey == "" {
-		apiKey = strings.TrimSpace(cfg.Image.Gemini.APIKey)
-	}
-	if apiKey == "" {
-		geminiProvider, err := cfg.GetResolvedProviderConfig("gemini")
-		if err != nil {
-			return fmt.Errorf("gemini provider: %w", err)
-		}
-		if geminiProvider != nil {
-			apiKey = strings.TrimSpace(geminiProvider.ResolvedAPIKey)
-		}
-	}
-	if apiKey == "" {
-		return fmt.Errorf("GEMINI_API_KEY not configured. Set environment variable or add to audio.gemini.api_key in config")
+	apiKey, err := geminiAudioAPIKey(cfg)
+	if err != nil {
+		return err
 	}
 	if strings.TrimSpace(audioLanguage) != "" {
 		return fmt.Errorf("Gemini TTS auto-detects language and does not support --language")
@@ -256,18 +295,9 @@ func runGeminiAudio(cmd *cobra.Command, cfg *config.Config, text string, tempera
 }
 
 func runElevenLabsAudio(cmd *cobra.Command, cfg *config.Config, text string) error {
-	apiKey := strings.TrimSpace(cfg.Audio.ElevenLabs.APIKey)
-	if apiKey == "" {
-		elevenLabsProvider, err := cfg.GetResolvedProviderConfig("elevenlabs")
-		if err != nil {
-			return fmt.Errorf("elevenlabs provider: %w", err)
-		}
-		if elevenLabsProvider != nil {
-			apiKey = strings.TrimSpace(elevenLabsProvider.ResolvedAPIKey)
-		}
-	}
-	if apiKey == "" {
-		return fmt.Errorf("ELEVENLABS_API_KEY not configured. Set environment variable or add to audio.elevenlabs.api_key in config")
+	apiKey, err := elevenLabsAudioAPIKey(cfg)
+	if err != nil {
+		return err
 	}
 	if strings.TrimSpace(audioPrompt) != "" {
 		return fmt.Errorf("ElevenLabs TTS does not support --prompt; include style instructions in the text or use voice settings")
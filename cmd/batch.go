@@ -0,0 +1,489 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/batch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	batchServerURL string
+	batchToken     string
+	batchTimeout   time.Duration
+	batchJSON      bool
+
+	batchSubmitEndpoint string
+	batchSubmitWait     bool
+	batchSubmitOutput   string
+	batchSubmitErrors   string
+	batchSubmitNoNative bool
+	batchSubmitMetadata []string
+	batchPollInterval   time.Duration
+
+	batchResultsOutput string
+	batchResultsErrors bool
+	batchListLimit     int
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Submit and track batches of chat/responses requests",
+	Long: `Run many requests through a serve instance's /v1/batches API.
+
+An input file is JSONL in the OpenAI Batch format, one request per line:
+  {"custom_id":"row-1","method":"POST","url":"/v1/chat/completions","body":{"model":"openai:gpt-5-mini","messages":[...]}}
+
+Lines are queued through the jobs runner. Where the provider has a native
+batch API (OpenAI Batch, Anthropic Message Batches) the server submits the
+lines there and polls; other lines run locally with per-provider concurrency
+(serve.batch.concurrency). Results are JSONL with one line per request,
+carrying either the response body (with usage) or an error.
+
+Requires 'term-llm serve' with the jobs platform enabled. By default this
+talks to http://127.0.0.1:8080; override with --server / --token or the
+TERM_LLM_JOBS_SERVER / TERM_LLM_JOBS_TOKEN env vars.
+
+Examples:
+  term-llm batch submit rows.jsonl --wait -o results.jsonl
+  term-llm batch status job_abc123
+  term-llm batch results job_abc123 -o results.jsonl
+  term-llm batch results job_abc123 --errors
+  term-llm batch cancel job_abc123`,
+}
+
+var batchSubmitCmd = &cobra.Command{
+	Use:   "submit <file.jsonl>",
+	Short: "Upload a JSONL file and create a batch",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBatchSubmit,
+}
+
+var batchStatusCmd = &cobra.Command{
+	Use:   "status <batch-id>",
+	Short: "Show a batch's status and request counts",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBatchStatus,
+}
+
+var batchResultsCmd = &cobra.Command{
+	Use:   "results <batch-id>",
+	Short: "Download a finished batch's results JSONL",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBatchResults,
+}
+
+var batchListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recent batches",
+	Args:  cobra.NoArgs,
+	RunE:  runBatchList,
+}
+
+var batchCancelCmd = &cobra.Command{
+	Use:   "cancel <batch-id>",
+	Short: "Cancel a batch; finished lines keep their results",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBatchCancel,
+}
+
+func init() {
+	batchCmd.PersistentFlags().StringVar(&batchServerURL, "server", envOr("TERM_LLM_JOBS_SERVER", "http://127.0.0.1:8080"), "Serve API base URL")
+	batchCmd.PersistentFlags().StringVar(&batchToken, "token", envOr("TERM_LLM_JOBS_TOKEN", ""), "Bearer token for the serve API")
+	batchCmd.PersistentFlags().DurationVar(&batchTimeout, "timeout", 5*time.Minute, "HTTP timeout (uploads and downloads can be large)")
+	batchCmd.PersistentFlags().BoolVar(&batchJSON, "json", false, "Print JSON output")
+
+	batchSubmitCmd.Flags().StringVar(&batchSubmitEndpoint, "endpoint", "", "Batch endpoint (default: the url of the first line)")
+	batchSubmitCmd.Flags().BoolVar(&batchSubmitWait, "wait", false, "Wait for the batch to finish")
+	batchSubmitCmd.Flags().StringVarP(&batchSubmitOutput, "output", "o", "", "Write results JSONL to this file (implies --wait; - for stdout)")
+	batchSubmitCmd.Flags().StringVar(&batchSubmitErrors, "errors-output", "", "Write failed lines to this file (implies --wait)")
+	batchSubmitCmd.Flags().BoolVar(&batchSubmitNoNative, "no-native", false, "Run every line locally instead of through provider batch APIs")
+	batchSubmitCmd.Flags().StringArrayVar(&batchSubmitMetadata, "metadata", nil, "Batch metadata as key=value (repeatable)")
+	batchSubmitCmd.Flags().DurationVar(&batchPollInterval, "poll", 5*time.Second, "Status poll interval while waiting")
+
+	batchResultsCmd.Flags().StringVarP(&batchResultsOutput, "output", "o", "", "Write results to this file instead of stdout")
+	batchResultsCmd.Flags().BoolVar(&batchResultsErrors, "errors", false, "Download only the failed lines")
+
+	batchListCmd.Flags().IntVar(&batchListLimit, "limit", 20, "Max batches to return")
+
+	batchCmd.AddCommand(batchSubmitCmd, batchStatusCmd, batchResultsCmd, batchListCmd, batchCancelCmd)
+	rootCmd.AddCommand(batchCmd)
+}
+
+func newBatchClient() (*jobsClient, error) {
+	return newServeAPIClient(batchServerURL, batchToken, batchTimeout)
+}
+
+func runBatchSubmit(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+	path := args[0]
+	endpoint := strings.TrimSpace(batchSubmitEndpoint)
+	if endpoint == "" {
+		var err error
+		if endpoint, err = batchFileEndpoint(path); err != nil {
+			return err
+		}
+	}
+	metadata, err := parseBatchMetadata(batchSubmitMetadata)
+	if err != nil {
+		return err
+	}
+	client, err := newBatchClient()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	var file batch.File
+	err = client.upload(ctx, "/v1/files", map[string]string{"purpose": batch.PurposeBatch}, filepath.Base(path), f, &file)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("upload %s: %w", path, err)
+	}
+
+	req := serveBatchCreateRequest{
+		InputFileID:      file.ID,
+		Endpoint:         endpoint,
+		CompletionWindow: "24h",
+		Metadata:         metadata,
+	}
+	if batchSubmitNoNative {
+		native := false
+		req.Native = &native
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	var b serveBatchObject
+	if err := client.do(ctx, http.MethodPost, "/v1/batches", body, &b); err != nil {
+		return err
+	}
+
+	wait := batchSubmitWait || batchSubmitOutput != "" || batchSubmitErrors != ""
+	if !wait {
+		if batchJSON {
+			return printJSON(b)
+		}
+		fmt.Printf("%s\t%s\t%d requests\n", b.ID, b.Status, b.RequestCounts.Total)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Submitted %s (%s)\n", b.ID, path)
+
+	b, err = waitForBatch(ctx, client, b.ID)
+	if err != nil {
+		return err
+	}
+	if batchSubmitOutput != "" && b.OutputFileID != "" {
+		if err := downloadBatchFile(ctx, client, b.OutputFileID, batchSubmitOutput); err != nil {
+			return err
+		}
+	}
+	if batchSubmitErrors != "" && b.ErrorFileID != "" {
+		if err := downloadBatchFile(ctx, client, b.ErrorFileID, batchSubmitErrors); err != nil {
+			return err
+		}
+	}
+	// With -o - stdout carries the results, so the summary is skipped.
+	if batchSubmitOutput != "-" {
+		if batchJSON {
+			if err := printJSON(b); err != nil {
+				return err
+			}
+		} else {
+			printBatchSummary(os.Stdout, b)
+		}
+	}
+	if b.Status != "completed" {
+		return fmt.Errorf("batch %s %s", b.ID, b.Status)
+	}
+	return nil
+}
+
+func runBatchStatus(cmd *cobra.Command, args []string) error {
+	client, err := newBatchClient()
+	if err != nil {
+		return err
+	}
+	var b serveBatchObject
+	if err := client.do(cmd.Context(), http.MethodGet, "/v1/batches/"+args[0], nil, &b); err != nil {
+		return err
+	}
+	if batchJSON {
+		return printJSON(b)
+	}
+	printBatchSummary(os.Stdout, b)
+	return nil
+}
+
+func runBatchResults(cmd *cobra.Command, args []string) error {
+	client, err := newBatchClient()
+	if err != nil {
+		return err
+	}
+	var b serveBatchObject
+	if err := client.do(cmd.Context(), http.MethodGet, "/v1/batches/"+args[0], nil, &b); err != nil {
+		return err
+	}
+	fileID := b.OutputFileID
+	if batchResultsErrors {
+		fileID = b.ErrorFileID
+	}
+	if fileID == "" {
+		if batchTerminal(b.Status) {
+			if batchResultsErrors {
+				return fmt.Errorf("batch %s has no failed lines", b.ID)
+			}
+			return fmt.Errorf("batch %s %s without results", b.ID, b.Status)
+		}
+		return fmt.Errorf("batch %s is %s; results are written when it finishes", b.ID, b.Status)
+	}
+	out := batchResultsOutput
+	if out == "" {
+		out = "-"
+	}
+	return downloadBatchFile(cmd.Context(), client, fileID, out)
+}
+
+func runBatchList(cmd *cobra.Command, args []string) error {
+	client, err := newBatchClient()
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Data []serveBatchObject `json:"data"`
+	}
+	if err := client.do(cmd.Context(), http.MethodGet, fmt.Sprintf("/v1/batches?limit=%d", batchListLimit), nil, &resp); err != nil {
+		return err
+	}
+	if batchJSON {
+		return printJSON(resp.Data)
+	}
+	if len(resp.Data) == 0 {
+		fmt.Println("No batches.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tENDPOINT\tDONE\tFAILED\tCREATED")
+	for _, b := range resp.Data {
+		c := b.RequestCounts
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d/%d\t%d\t%s\n", b.ID, b.Status, b.Endpoint, c.Completed+c.Failed, c.Total, c.Failed,
+			time.Unix(b.CreatedAt, 0).Local().Format("2006-01-02 15:04"))
+	}
+	return tw.Flush()
+}
+
+func runBatchCancel(cmd *cobra.Command, args []string) error {
+	client, err := newBatchClient()
+	if err != nil {
+		return err
+	}
+	var b serveBatchObject
+	if err := client.do(cmd.Context(), http.MethodPost, "/v1/batches/"+args[0]+"/cancel", nil, &b); err != nil {
+		return err
+	}
+	if batchJSON {
+		return printJSON(b)
+	}
+	printBatchSummary(os.Stdout, b)
+	return nil
+}
+
+// batchFileEndpoint returns the url of the first request in a batch input
+// file.
+func batchFileEndpoint(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64<<10), batch.MaxLineBytes)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req batch.Request
+		if err := json.Unmarshal(line, &req); err != nil {
+			return "", fmt.Errorf("%s: first line: %w", path, err)
+		}
+		if req.URL == "" {
+			return "", fmt.Errorf("%s: first line has no url; pass --endpoint", path)
+		}
+		return req.URL, nil
+	}
+	if err := sc.Err(); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return "", fmt.Errorf("%s: no requests", path)
+}
+
+func parseBatchMetadata(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	metadata := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --metadata %q: want key=value", pair)
+		}
+		metadata[key] = value
+	}
+	return metadata, nil
+}
+
+func batchTerminal(status string) bool {
+	switch status {
+	case "completed", "failed", "expired", "cancelled":
+		return true
+	}
+	return false
+}
+
+// waitForBatch polls a batch until it reaches a terminal status, printing
+// progress to stderr whenever the counts change.
+func waitForBatch(ctx context.Context, client *jobsClient, id string) (serveBatchObject, error) {
+	interval := batchPollInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	var last string
+	for {
+		var b serveBatchObject
+		if err := client.do(ctx, http.MethodGet, "/v1/batches/"+id, nil, &b); err != nil {
+			return b, err
+		}
+		c := b.RequestCounts
+		line := fmt.Sprintf("%s: %s %d/%d done, %d failed", b.ID, b.Status, c.Completed+c.Failed, c.Total, c.Failed)
+		if line != last {
+			fmt.Fprintln(os.Stderr, line)
+			last = line
+		}
+		if batchTerminal(b.Status) {
+			return b, nil
+		}
+		select {
+		case <-ctx.Done():
+			return b, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// downloadBatchFile writes a served file to path, or stdout for "-".
+func downloadBatchFile(ctx context.Context, client *jobsClient, fileID, path string) error {
+	if path == "-" {
+		return client.download(ctx, "/v1/files/"+fileID+"/content", os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := client.download(ctx, "/v1/files/"+fileID+"/content", f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+func printBatchSummary(w io.Writer, b serveBatchObject) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	c := b.RequestCounts
+	fmt.Fprintf(tw, "Batch:\t%s\n", b.ID)
+	fmt.Fprintf(tw, "Status:\t%s\n", b.Status)
+	fmt.Fprintf(tw, "Endpoint:\t%s\n", b.Endpoint)
+	fmt.Fprintf(tw, "Requests:\t%d total, %d completed, %d failed\n", c.Total, c.Completed, c.Failed)
+	if b.Usage.InputTokens > 0 || b.Usage.OutputTokens > 0 {
+		fmt.Fprintf(tw, "Usage:\t%d input, %d output tokens\n", b.Usage.InputTokens, b.Usage.OutputTokens)
+	}
+	for _, remote := range b.Native {
+		fmt.Fprintf(tw, "Native:\t%s %s (%s) %s\n", remote.Backend, remote.ID, remote.Model, remote.Status)
+	}
+	if b.OutputFileID != "" {
+		fmt.Fprintf(tw, "Output file:\t%s\n", b.OutputFileID)
+	}
+	if b.ErrorFileID != "" {
+		fmt.Fprintf(tw, "Error file:\t%s\n", b.ErrorFileID)
+	}
+	if b.Errors != nil {
+		for _, e := range b.Errors.Data {
+			fmt.Fprintf(tw, "Error:\t%s\n", e.Message)
+		}
+	}
+	tw.Flush()
+}
+
+// upload sends a multipart/form-data POST with fields and one file part,
+// streaming the file rather than buffering it.
+func (c *jobsClient) upload(ctx context.Context, path string, fields map[string]string, filename string, r io.Reader, out any) error {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeMultipart(mw, fields, filename, r))
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, pr)
+	if err != nil {
+		pr.Close()
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		pr.Close()
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return jobsResponseError(resp.StatusCode, respBody)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func writeMultipart(mw *multipart.Writer, fields map[string]string, filename string, r io.Reader) error {
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return err
+	}
+	return mw.Close()
+}
@@ -108,6 +108,9 @@ Keyboard shortcuts:
   Ctrl+C       - Copy selection; cancel active response/tool/shell; press twice when idle to quit
   Ctrl+K       - Clear conversation
   Ctrl+N       - New session
+  Alt+T        - Open a new session tab
+  Alt+. Alt+,  - Next / previous tab (also Ctrl+PgDn / Ctrl+PgUp)
+  Alt+1…9      - Jump to tab
   Ctrl+L       - Switch model
   Ctrl+R       - Cycle reasoning effort
   Ctrl+S       - Toggle web search
@@ -145,6 +148,7 @@ Slash commands:
   /compact     - Compact conversation context
   /resume      - Browse and resume previous sessions
   /tree        - Browse paths or branch from an earlier message
+  /tab         - Open, switch or close session tabs
   /reload      - Re-exec current binary and resume session
   /handover    - Hand conversation to another agent`,
 	RunE:              runChat,
@@ -213,9 +217,13 @@ func runChat(cmd *cobra.Command, args []string) error {
 	relaunchHandoff := chatRelaunchHandoff{}
 	mainRuns := chat.NewMainRunManager(ctx)
 	defer mainRuns.Close(5 * time.Second)
+	// Tabs outlive relaunches (handover, fallback switches); /reload and
+	// --resume restore them from disk.
+	tabsPath, _ := chat.DefaultSessionTabsPath()
+	tabs := chat.NewSessionTabs(tabsPath)
 	chatHandoverApprovalMode = nil
 	for {
-		nextResumeID, nextAutoSend, err := runChatOnce(ctx, cmd, initialText, cliAgent, resumeRequested, resumeID, handoverAutoSend, &relaunchHandoff, mainRuns)
+		nextResumeID, nextAutoSend, err := runChatOnce(ctx, cmd, initialText, cliAgent, resumeRequested, resumeID, handoverAutoSend, &relaunchHandoff, mainRuns, tabs)
 		if err != nil {
 			return err
 		}
@@ -737,6 +745,7 @@ func buildChatSessionRuntime(ctx context.Context, cmd *cobra.Command, launch cha
 	}))
 	model.SetChildRunner(spawnRunner)
 	model.SetMainRunManager(mainRuns)
+	model.SetFileTrackStore(fileTrackingStore(cfg))
 
 	// Wire handover auto-send if pending from previous iteration
 	if handoverAutoSend != "" {
@@ -1017,7 +1026,7 @@ func wireChatSessionUI(ctx context.Context, rt *chatSessionRuntime, p *tea.Progr
 	}
 }
 
-func runChatOnce(ctx context.Context, cmd *cobra.Command, initialText, cliAgent string, resumeRequested bool, resumeID, handoverAutoSend string, relaunchHandoff *chatRelaunchHandoff, mainRuns *chat.MainRunManager) (string, string, error) {
+func runChatOnce(ctx context.Context, cmd *cobra.Command, initialText, cliAgent string, resumeRequested bool, resumeID, handoverAutoSend string, relaunchHandoff *chatRelaunchHandoff, mainRuns *chat.MainRunManager, tabs *chat.SessionTabs) (string, string, error) {
 	rt, err := buildChatSessionRuntime(ctx, cmd, chatSessionLaunch{
 		initialText:      initialText,
 		cliAgent:         cliAgent,
@@ -1115,6 +1124,7 @@ func runChatOnce(ctx context.Context, cmd *cobra.Command, initialText, cliAgent
 		}
 		next.model.SetProgram(p)
 		next.model.SetSessionSwitcher(switcher)
+		next.model.SetSessionTabs(tabs)
 
 		runtimeMu.Lock()
 		if programDone {
@@ -1143,6 +1153,7 @@ func runChatOnce(ctx context.Context, cmd *cobra.Command, initialText, cliAgent
 		return next.model, nil
 	}
 	rt.model.SetSessionSwitcher(switcher)
+	rt.model.SetSessionTabs(tabs)
 
 	runtimeMu.Lock()
 	activeUnwire = wireChatSessionUI(ctx, rt, p, mainRuns, true)
@@ -1304,9 +1315,9 @@ func effectiveSessionDirectory(sess *session.Session) string {
 		if dir := strings.TrimSpace(sess.WorktreeDir); dir != "" {
 			return dir
 		}
-		// Web/telegram sessions historically recorded the daemon process CWD.
+		// Serve-platform sessions historically recorded the daemon process CWD.
 		// A local interactive resume must not treat that as user-selected state.
-		if sess.Origin != session.OriginWeb && sess.Origin != session.OriginTelegram {
+		if !sess.Origin.IsServe() {
 			if dir := strings.TrimSpace(sess.CWD); dir != "" {
 				return dir
 			}
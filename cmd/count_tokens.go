@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/samsaffron/term-llm/internal/input"
+	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/mcp"
+	"github.com/samsaffron/term-llm/internal/prompt"
+	"github.com/samsaffron/term-llm/internal/signal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	countTokensProvider      string
+	countTokensSearch        bool
+	countTokensNoSearch      bool
+	countTokensMCP           string
+	countTokensTools         string
+	countTokensReadDirs      []string
+	countTokensWriteDirs     []string
+	countTokensShellAllow    []string
+	countTokensSystemMessage string
+	countTokensSkills        string
+	countTokensAgent         string
+	countTokensFiles         []string
+	countTokensJSON          bool
+	countTokensEstimate      bool
+	countTokensPerTool       bool
+)
+
+var countTokensCmd = &cobra.Command{
+	Use:   "count-tokens [@agent] [prompt]",
+	Short: "Count the input tokens a request would use",
+	Long: `Assemble a request the way ask would — system prompt, skills metadata,
+tool schemas, files and prompt — and report its input tokens per section
+without running it.
+
+Providers with a token counting endpoint (Anthropic, OpenAI) give exact
+counts; others fall back to a local estimate.
+
+Examples:
+  term-llm count-tokens "Review this" -f main.go
+  term-llm count-tokens @developer
+  term-llm count-tokens --agent reviewer --provider openai:gpt-5.2
+  term-llm count-tokens --tools all --mcp github --per-tool
+  term-llm count-tokens @developer --json | jq .tools`,
+	Args:              cobra.ArbitraryArgs,
+	RunE:              runCountTokens,
+	ValidArgsFunction: AtAgentCompletion,
+}
+
+func init() {
+	AddCommonFlags(countTokensCmd,
+		CommonProvider|CommonSearch|CommonMCP|CommonTools|CommonSystemMessage|CommonSkills|CommonAgent|CommonFiles,
+		CommonFlagBindings{
+			Provider:         &countTokensProvider,
+			Search:           &countTokensSearch,
+			NoSearch:         &countTokensNoSearch,
+			MCP:              &countTokensMCP,
+			Tools:            &countTokensTools,
+			ReadDirs:         &countTokensReadDirs,
+			WriteDirs:        &countTokensWriteDirs,
+			ShellAllow:       &countTokensShellAllow,
+			SystemMessage:    &countTokensSystemMessage,
+			Skills:           &countTokensSkills,
+			Agent:            &countTokensAgent,
+			Files:            &countTokensFiles,
+			FilesDescription: "File(s) to include in the prompt (supports globs, line ranges like file.go:10-20, 'clipboard')",
+		})
+	countTokensCmd.Flags().BoolVar(&countTokensJSON, "json", false, "Output the report as JSON")
+	countTokensCmd.Flags().BoolVar(&countTokensEstimate, "estimate", false, "Use the local estimate instead of the provider's token counting endpoint")
+	countTokensCmd.Flags().BoolVar(&countTokensPerTool, "per-tool", false, "List estimated tokens for each tool schema")
+	rootCmd.AddCommand(countTokensCmd)
+}
+
+// countTokensReport is the output of count-tokens.
+type countTokensReport struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+	Agent    string `json:"agent,omitempty"`
+	llm.TokenBreakdown
+	ToolCount     int               `json:"tool_count"`
+	ContextWindow int               `json:"context_window,omitempty"`
+	PerTool       []countTokensTool `json:"per_tool,omitempty"`
+	Warning       string            `json:"warning,omitempty"`
+}
+
+// countTokensTool is one tool's estimated schema size.
+type countTokensTool struct {
+	Name   string `json:"name"`
+	Tokens int    `json:"tokens"`
+}
+
+func runCountTokens(cmd *cobra.Command, args []string) error {
+	atAgent, filteredArgs := ExtractAgentFromArgs(args)
+	if atAgent != "" && countTokensAgent == "" {
+		countTokensAgent = atAgent
+	}
+	question := strings.Join(filteredArgs, " ")
+	ctx, stop := signal.NotifyContext()
+	defer stop()
+
+	cfg, err := loadConfigWithSetup()
+	if err != nil {
+		return err
+	}
+	agent, err := LoadAgent(countTokensAgent, cfg)
+	if err != nil {
+		return err
+	}
+	if question == "" && agent != nil {
+		question = agent.DefaultPrompt
+	}
+
+	agentProvider, agentModel, agentSkills := "", "", ""
+	if agent != nil {
+		agentProvider, agentModel, agentSkills = agent.Provider, agent.Model, agent.Skills
+	}
+	if err := applyProviderOverridesWithAgent(cfg, cfg.Ask.Provider, cfg.Ask.Model, countTokensProvider, agentProvider, agentModel); err != nil {
+		return err
+	}
+	provider, err := llm.NewProvider(cfg)
+	if err != nil {
+		return err
+	}
+	engine := newEngine(provider, cfg)
+
+	skillsSetup := SetupSkills(&cfg.Skills, countTokensSkills, agentSkills, cmd.ErrOrStderr())
+	settings, err := ResolveSettings(cfg, agent, CLIFlags{
+		Provider:      countTokensProvider,
+		Tools:         countTokensTools,
+		ReadDirs:      countTokensReadDirs,
+		WriteDirs:     countTokensWriteDirs,
+		ShellAllow:    countTokensShellAllow,
+		MCP:           countTokensMCP,
+		SystemMessage: countTokensSystemMessage,
+		Search:        countTokensSearch,
+		NoSearch:      countTokensNoSearch,
+		Files:         countTokensFiles,
+		Platform:      "console",
+	}, cfg.Ask.Provider, cfg.Ask.Model, cfg.Ask.Instructions, cfg.Ask.MaxTurns, 50)
+	if err != nil {
+		return err
+	}
+	baseSystem := settings.SystemPrompt
+	settings.SystemPrompt = InjectSkillsMetadata(baseSystem, skillsSetup)
+	alignSettingsToActiveProvider(&settings, cfg, provider)
+
+	// Register everything ask would expose so the tool section matches a
+	// real run; nothing is executed.
+	toolMgr, err := settings.SetupToolManager(cfg, engine)
+	if err != nil {
+		return err
+	}
+	if toolMgr != nil && toolMgr.ApprovalMgr != nil {
+		defer toolMgr.ApprovalMgr.Close()
+	}
+	if agent != nil && agent.OutputTool.IsConfigured() {
+		registerAgentOutputTool(agent.OutputTool, toolMgr, engine)
+	}
+	RegisterSkillToolWithEngine(engine, toolMgr, skillsSetup)
+	if settings.MCP != "" {
+		var mcpManager *mcp.Manager
+		mcpManager, err = enableMCPServersWithFeedback(ctx, settings.MCP, engine, cmd.ErrOrStderr(), &MCPOptions{
+			Provider:      provider,
+			Model:         activeModel(cfg),
+			ToolDiscovery: cfg.ToolDiscovery,
+		})
+		if err != nil {
+			return err
+		}
+		if mcpManager != nil {
+			defer mcpManager.StopAll()
+		}
+	}
+
+	var files []input.FileContent
+	if len(countTokensFiles) > 0 {
+		files, err = input.ReadFiles(countTokensFiles)
+		if err != nil {
+			return fmt.Errorf("failed to read files: %w", err)
+		}
+	}
+	stdinContent, err := input.ReadStdin()
+	if err != nil {
+		return fmt.Errorf("failed to read stdin: %w", err)
+	}
+	var messages []llm.Message
+	if userPrompt := prompt.AskUserPrompt(question, files, stdinContent); strings.TrimSpace(userPrompt) != "" {
+		messages = append(messages, llm.UserText(userPrompt))
+	}
+
+	in := llm.TokenCountInput{
+		System:   baseSystem,
+		Tools:    llm.ToolSpecsForRequest(engine.Tools(), settings.Search),
+		Messages: messages,
+	}
+	if settings.SystemPrompt != baseSystem {
+		in.SystemWithSkills = settings.SystemPrompt
+	}
+
+	report := countTokensReport{
+		Provider:      settings.Provider,
+		Model:         settings.Model,
+		ToolCount:     len(in.Tools),
+		ContextWindow: llm.InputLimitForProviderModel(settings.Provider, settings.Model),
+	}
+	if agent != nil {
+		report.Agent = agent.Name
+	}
+	if countTokensEstimate {
+		report.TokenBreakdown = llm.EstimateTokenBreakdown(in)
+	} else {
+		report.TokenBreakdown, err = llm.CountTokenBreakdown(ctx, provider, in)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			report.Warning = fmt.Sprintf("%v; showing estimate", err)
+		}
+	}
+	if countTokensPerTool {
+		report.PerTool = estimatePerToolTokens(in.Tools)
+	}
+
+	if countTokensJSON {
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	if report.Warning != "" {
+		fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", report.Warning)
+	}
+	return printCountTokensReport(cmd.OutOrStdout(), report)
+}
+
+// estimatePerToolTokens estimates each tool's schema size, largest first.
+func estimatePerToolTokens(specs []llm.ToolSpec) []countTokensTool {
+	out := make([]countTokensTool, 0, len(specs))
+	for _, spec := range specs {
+		out = append(out, countTokensTool{Name: spec.Name, Tokens: llm.EstimateToolTokens([]llm.ToolSpec{spec})})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Tokens != out[j].Tokens {
+			return out[i].Tokens > out[j].Tokens
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func printCountTokensReport(out io.Writer, report countTokensReport) error {
+	model := report.Provider
+	if report.Model != "" {
+		model += ":" + report.Model
+	}
+	method := "estimated"
+	if report.Exact {
+		method = "counted by provider"
+	}
+	fmt.Fprintf(out, "Model: %s (%s)\n", model, method)
+	if report.Agent != "" {
+		fmt.Fprintf(out, "Agent: %s\n", report.Agent)
+	}
+	fmt.Fprintln(out)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Section\tTokens\tShare\n")
+	row := func(name string, n int) {
+		share := "-"
+		if report.Total > 0 {
+			share = fmt.Sprintf("%.1f%%", float64(n)*100/float64(report.Total))
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", name, n, share)
+	}
+	row("System prompt", report.System)
+	row("Skills metadata", report.Skills)
+	row(fmt.Sprintf("Tool schemas (%d)", report.ToolCount), report.Tools)
+	row("Messages", report.Messages)
+	fmt.Fprintf(w, "Total\t%d\t\n", report.Total)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if report.ContextWindow > 0 {
+		fmt.Fprintf(out, "\nContext window: %s (%.1f%% used)\n", formatTokens(report.ContextWindow),
+			float64(report.Total)*100/float64(report.ContextWindow))
+	}
+
+	if len(report.PerTool) > 0 {
+		fmt.Fprintln(out, "\nTools (estimated):")
+		w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, tool := range report.PerTool {
+			fmt.Fprintf(w, "  %s\t%d\n", tool.Name, tool.Tokens)
+		}
+		return w.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/samsaffron/term-llm/internal/llm"
+)
+
+func TestEstimatePerToolTokensSortsLargestFirst(t *testing.T) {
+	got := estimatePerToolTokens([]llm.ToolSpec{
+		{Name: "small"},
+		{Name: "large", Description: strings.Repeat("describe ", 40)},
+		{Name: "medium", Description: "A tool with a short description"},
+	})
+	if len(got) != 3 {
+		t.Fatalf("len = %d, want 3", len(got))
+	}
+	if got[0].Name != "large" || got[1].Name != "medium" || got[2].Name != "small" {
+		t.Fatalf("order = %+v", got)
+	}
+}
+
+func TestPrintCountTokensReport(t *testing.T) {
+	var buf bytes.Buffer
+	err := printCountTokensReport(&buf, countTokensReport{
+		Provider: "anthropic",
+		Model:    "claude-sonnet-4-6",
+		Agent:    "developer",
+		TokenBreakdown: llm.TokenBreakdown{
+			System: 1000, Skills: 500, Tools: 8000, Messages: 500, Total: 10000, Exact: true,
+		},
+		ToolCount:     12,
+		ContextWindow: 200000,
+		PerTool:       []countTokensTool{{Name: "shell", Tokens: 900}},
+	})
+	if err != nil {
+		t.Fatalf("printCountTokensReport: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"Model: anthropic:claude-sonnet-4-6 (counted by provider)",
+		"Agent: developer",
+		"Tool schemas (12)  8000    80.0%",
+		"Total",
+		"Context window: 200k (5.0% used)",
+		"shell  900",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+}
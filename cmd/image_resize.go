@@ -2,10 +2,11 @@ package cmd
 
 import (
 	"bytes"
+	"fmt"
 	"image"
 	_ "image/gif"
 	"image/jpeg"
-	_ "image/png"
+	"image/png"
 	"log"
 	"math"
 
@@ -79,3 +80,29 @@ func resizeImageForLLM(data []byte, mediaType string) ([]byte, string) {
 	log.Printf("[web] resizeImageForLLM: all attempts failed — sending original (%d bytes)", len(data))
 	return data, mediaType
 }
+
+// convertImageFormat re-encodes image data as png or jpeg. Data already in the
+// wanted format is returned unchanged.
+func convertImageFormat(data []byte, mediaType, format string) ([]byte, string, error) {
+	want := "image/" + format
+	if mediaType == want {
+		return data, mediaType, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode %s: %w", mediaType, err)
+	}
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	default:
+		return nil, "", fmt.Errorf("unsupported output format %q", format)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), want, nil
+}
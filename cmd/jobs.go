@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -34,6 +35,9 @@ var (
 	jobsRunsOffset         int
 	jobsEventsLimit        int
 	jobsEventsOffset       int
+	jobsHooksLimit         int
+	jobsHooksOffset        int
+	jobsArtifactsDownload  string
 )
 
 var jobsCmd = &cobra.Command{
@@ -152,6 +156,43 @@ var jobsRunCancelCmd = &cobra.Command{
 	ValidArgsFunction: runsArgCompletion,
 }
 
+var jobsRunChainCmd = &cobra.Command{
+	Use:               "chain <run-id>",
+	Short:             "Show the upstream/downstream chain of a run",
+	Args:              cobra.ExactArgs(1),
+	RunE:              runJobsRunChain,
+	ValidArgsFunction: runsArgCompletion,
+}
+
+var jobsRunArtifactsCmd = &cobra.Command{
+	Use:               "artifacts <run-id>",
+	Short:             "List or download run artifacts",
+	Args:              cobra.ExactArgs(1),
+	RunE:              runJobsRunArtifacts,
+	ValidArgsFunction: runsArgCompletion,
+}
+
+var jobsHooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Webhook delivery operations",
+}
+
+var jobsHooksListCmd = &cobra.Command{
+	Use:               "list <job-id-or-name>",
+	Short:             "List webhook deliveries for a job",
+	Args:              cobra.ExactArgs(1),
+	RunE:              runJobsHooksList,
+	ValidArgsFunction: jobsArgCompletion,
+}
+
+var jobsHooksReplayCmd = &cobra.Command{
+	Use:               "replay <run-id>",
+	Short:             "Replay the webhook delivery of a run",
+	Args:              cobra.ExactArgs(1),
+	RunE:              runJobsHooksReplay,
+	ValidArgsFunction: runsArgCompletion,
+}
+
 var jobsRunEventsCmd = &cobra.Command{
 	Use:               "events <run-id>",
 	Short:             "List run events",
@@ -180,6 +221,11 @@ func init() {
 	jobsRunEventsCmd.Flags().IntVar(&jobsEventsLimit, "limit", 200, "Max events to return")
 	jobsRunEventsCmd.Flags().IntVar(&jobsEventsOffset, "offset", 0, "Pagination offset")
 
+	jobsRunArtifactsCmd.Flags().StringVar(&jobsArtifactsDownload, "download", "", "Download all artifacts into this directory")
+
+	jobsHooksListCmd.Flags().IntVar(&jobsHooksLimit, "limit", 50, "Max deliveries to return")
+	jobsHooksListCmd.Flags().IntVar(&jobsHooksOffset, "offset", 0, "Pagination offset")
+
 	jobsCmd.Flags().BoolVar(&jobsListAll, "all", false, "Show all jobs, including completed once-off and finished agent jobs")
 	jobsListCmd.Flags().BoolVar(&jobsListAll, "all", false, "Show all jobs, including completed once-off and finished agent jobs")
 
@@ -194,10 +240,16 @@ func init() {
 	jobsCmd.AddCommand(jobsRunsCmd)
 	jobsCmd.AddCommand(jobsActiveCmd)
 	jobsCmd.AddCommand(jobsRunCmd)
+	jobsCmd.AddCommand(jobsHooksCmd)
 
 	jobsRunCmd.AddCommand(jobsRunGetCmd)
 	jobsRunCmd.AddCommand(jobsRunCancelCmd)
 	jobsRunCmd.AddCommand(jobsRunEventsCmd)
+	jobsRunCmd.AddCommand(jobsRunChainCmd)
+	jobsRunCmd.AddCommand(jobsRunArtifactsCmd)
+
+	jobsHooksCmd.AddCommand(jobsHooksListCmd)
+	jobsHooksCmd.AddCommand(jobsHooksReplayCmd)
 
 	rootCmd.AddCommand(jobsCmd)
 }
@@ -230,6 +282,10 @@ type jobsRunEventsListResponse struct {
 	Data []jobsV2RunEvent `json:"data"`
 }
 
+type jobsRunArtifactsListResponse struct {
+	Data []jobsV2Artifact `json:"data"`
+}
+
 const jobsActiveRunsPageSize = 10
 
 type openAIErrorResponse struct {
@@ -239,7 +295,12 @@ type openAIErrorResponse struct {
 }
 
 func newJobsClient() (*jobsClient, error) {
-	base := strings.TrimSpace(jobsServerURL)
+	return newServeAPIClient(jobsServerURL, jobsToken, jobsTimeout)
+}
+
+// newServeAPIClient returns a client for a serve instance's HTTP API.
+func newServeAPIClient(serverURL, token string, timeout time.Duration) (*jobsClient, error) {
+	base := strings.TrimSpace(serverURL)
 	if base == "" {
 		base = "http://127.0.0.1:8080"
 	}
@@ -247,18 +308,17 @@ func newJobsClient() (*jobsClient, error) {
 	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
 		return nil, fmt.Errorf("invalid --server %q: must start with http:// or https://", base)
 	}
-	timeout := jobsTimeout
 	if timeout <= 0 {
 		timeout = 15 * time.Second
 	}
 	return &jobsClient{
 		baseURL: base,
-		token:   strings.TrimSpace(jobsToken),
+		token:   strings.TrimSpace(token),
 		http:    &http.Client{Timeout: timeout},
 	}, nil
 }
 
-func (c *jobsClient) do(ctx context.Context, method, path string, body []byte, out any) error {
+func (c *jobsClient) send(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
 	url := c.baseURL + path
 	var reader io.Reader
 	if len(body) > 0 {
@@ -266,7 +326,7 @@ func (c *jobsClient) do(ctx context.Context, method, path string, body []byte, o
 	}
 	req, err := http.NewRequestWithContext(ctx, method, url, reader)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if len(body) > 0 {
 		req.Header.Set("Content-Type", "application/json")
@@ -274,8 +334,27 @@ func (c *jobsClient) do(ctx context.Context, method, path string, body []byte, o
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
+	return c.http.Do(req)
+}
+
+// jobsAPIError is an error response from the jobs server.
+type jobsAPIError struct {
+	Status  int
+	Message string
+}
+
+func (e *jobsAPIError) Error() string { return e.Message }
+
+func jobsResponseError(status int, respBody []byte) error {
+	var apiErr openAIErrorResponse
+	if err := json.Unmarshal(respBody, &apiErr); err == nil && strings.TrimSpace(apiErr.Error.Message) != "" {
+		return &jobsAPIError{Status: status, Message: apiErr.Error.Message}
+	}
+	return &jobsAPIError{Status: status, Message: fmt.Sprintf("request failed (%d): %s", status, strings.TrimSpace(string(respBody)))}
+}
 
-	resp, err := c.http.Do(req)
+func (c *jobsClient) do(ctx context.Context, method, path string, body []byte, out any) error {
+	resp, err := c.send(ctx, method, path, body)
 	if err != nil {
 		return err
 	}
@@ -286,11 +365,7 @@ func (c *jobsClient) do(ctx context.Context, method, path string, body []byte, o
 		return err
 	}
 	if resp.StatusCode >= 400 {
-		var apiErr openAIErrorResponse
-		if err := json.Unmarshal(respBody, &apiErr); err == nil && strings.TrimSpace(apiErr.Error.Message) != "" {
-			return fmt.Errorf("%s", apiErr.Error.Message)
-		}
-		return fmt.Errorf("request failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		return jobsResponseError(resp.StatusCode, respBody)
 	}
 	if out == nil || len(respBody) == 0 {
 		return nil
@@ -301,6 +376,21 @@ func (c *jobsClient) do(ctx context.Context, method, path string, body []byte, o
 	return nil
 }
 
+// download streams a non-JSON response body, such as a run artifact, to w.
+func (c *jobsClient) download(ctx context.Context, path string, w io.Writer) error {
+	resp, err := c.send(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return jobsResponseError(resp.StatusCode, respBody)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
 func (c *jobsClient) listJobs(ctx context.Context) ([]jobsV2Job, error) {
 	var resp jobsListResponse
 	if err := c.do(ctx, http.MethodGet, "/v2/jobs?limit=500", nil, &resp); err != nil {
@@ -407,12 +497,16 @@ func (c *jobsClient) resolveJobID(ctx context.Context, ref string) (string, erro
 		return "", err
 	}
 	exactIDs := make([]string, 0)
+	exactKeys := make([]string, 0)
 	exactNames := make([]string, 0)
 	prefixIDs := make([]string, 0)
 	for _, job := range jobs {
 		if job.ID == ref {
 			exactIDs = append(exactIDs, job.ID)
 		}
+		if job.Key == ref {
+			exactKeys = append(exactKeys, job.ID)
+		}
 		if job.Name == ref {
 			exactNames = append(exactNames, job.ID)
 		}
@@ -423,6 +517,9 @@ func (c *jobsClient) resolveJobID(ctx context.Context, ref string) (string, erro
 	if len(exactIDs) == 1 {
 		return exactIDs[0], nil
 	}
+	if len(exactKeys) == 1 {
+		return exactKeys[0], nil
+	}
 	if len(exactNames) == 1 {
 		return exactNames[0], nil
 	}
@@ -873,9 +970,13 @@ func runJobsRuns(cmd *cobra.Command, args []string) error {
 		fmt.Println("No runs found.")
 		return nil
 	}
-	fmt.Printf("%-24s %-24s %-8s %-20s %-20s\n", "RUN_ID", "JOB_ID", "STATUS", "TRIGGER", "SCHEDULED_FOR")
+	fmt.Printf("%-24s %-24s %-8s %-20s %-20s %-24s\n", "RUN_ID", "JOB_ID", "STATUS", "TRIGGER", "SCHEDULED_FOR", "PARENT_RUN")
 	for _, run := range items {
-		fmt.Printf("%-24s %-24s %-8s %-20s %-20s\n", run.ID, run.JobID, run.Status, run.Trigger, run.ScheduledFor.Local().Format(time.RFC3339))
+		parent := run.ParentRunID
+		if parent == "" {
+			parent = "-"
+		}
+		fmt.Printf("%-24s %-24s %-8s %-20s %-20s %-24s\n", run.ID, run.JobID, run.Status, run.Trigger, run.ScheduledFor.Local().Format(time.RFC3339), parent)
 	}
 	return nil
 }
@@ -904,6 +1005,64 @@ func runJobsRunCancel(cmd *cobra.Command, args []string) error {
 	return printJSON(run)
 }
 
+func runJobsRunChain(cmd *cobra.Command, args []string) error {
+	client, err := newJobsClient()
+	if err != nil {
+		return err
+	}
+	var chain jobsV2RunChain
+	if err := client.do(cmd.Context(), http.MethodGet, "/v2/runs/"+strings.TrimSpace(args[0])+"/chain", nil, &chain); err != nil {
+		return err
+	}
+	if jobsJSON {
+		return printJSON(chain)
+	}
+	printJobsRunChain(os.Stdout, chain)
+	return nil
+}
+
+// printJobsRunChain renders a run chain as an indented tree rooted at the
+// oldest known upstream run, marking the requested run with "*".
+func printJobsRunChain(w io.Writer, chain jobsV2RunChain) {
+	runs := make([]jobsV2Run, 0, len(chain.Upstream)+1+len(chain.Downstream))
+	runs = append(runs, chain.Upstream...)
+	runs = append(runs, chain.Run)
+	runs = append(runs, chain.Downstream...)
+
+	known := make(map[string]bool, len(runs))
+	for _, run := range runs {
+		known[run.ID] = true
+	}
+	children := make(map[string][]jobsV2Run)
+	var roots []jobsV2Run
+	for _, run := range runs {
+		if run.ParentRunID == "" || !known[run.ParentRunID] {
+			roots = append(roots, run)
+			continue
+		}
+		children[run.ParentRunID] = append(children[run.ParentRunID], run)
+	}
+
+	var walk func(run jobsV2Run, depth int)
+	walk = func(run jobsV2Run, depth int) {
+		marker := " "
+		if run.ID == chain.RunID {
+			marker = "*"
+		}
+		prefix := strings.Repeat("   ", depth)
+		if depth > 0 {
+			prefix = strings.Repeat("   ", depth-1) + "└─ "
+		}
+		fmt.Fprintf(w, "%s %s%-24s %-24s %-10s %s\n", marker, prefix, run.ID, run.JobID, run.Status, run.Trigger)
+		for _, child := range children[run.ID] {
+			walk(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+}
+
 func runJobsRunEvents(cmd *cobra.Command, args []string) error {
 	client, err := newJobsClient()
 	if err != nil {
@@ -932,6 +1091,112 @@ func runJobsRunEvents(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runJobsRunArtifacts(cmd *cobra.Command, args []string) error {
+	client, err := newJobsClient()
+	if err != nil {
+		return err
+	}
+	runID := strings.TrimSpace(args[0])
+	var resp jobsRunArtifactsListResponse
+	if err := client.do(cmd.Context(), http.MethodGet, "/v2/runs/"+runID+"/artifacts", nil, &resp); err != nil {
+		return err
+	}
+	if jobsArtifactsDownload != "" {
+		for _, a := range resp.Data {
+			if !filepath.IsLocal(filepath.FromSlash(a.Path)) {
+				return fmt.Errorf("refusing to write artifact outside %s: %s", jobsArtifactsDownload, a.Path)
+			}
+			dest := filepath.Join(jobsArtifactsDownload, filepath.FromSlash(a.Path))
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return err
+			}
+			f, err := os.Create(dest)
+			if err != nil {
+				return err
+			}
+			err = client.download(cmd.Context(), jobsV2ArtifactURL("", runID, a.Path), f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return fmt.Errorf("download %s: %w", a.Path, err)
+			}
+			if !jobsJSON {
+				fmt.Println(dest)
+			}
+		}
+	}
+	if jobsJSON {
+		return printJSON(resp.Data)
+	}
+	if jobsArtifactsDownload != "" {
+		return nil
+	}
+	if len(resp.Data) == 0 {
+		fmt.Println("No artifacts found.")
+		return nil
+	}
+	fmt.Printf("%-10s %-26s %-14s %s\n", "SIZE", "CONTENT_TYPE", "SHA256", "PATH")
+	for _, a := range resp.Data {
+		fmt.Printf("%-10s %-26s %-14s %s\n", formatBytes(a.SizeBytes), truncateCell(a.ContentType, 26), a.SHA256[:min(12, len(a.SHA256))], a.Path)
+	}
+	return nil
+}
+
+func runJobsHooksList(cmd *cobra.Command, args []string) error {
+	client, err := newJobsClient()
+	if err != nil {
+		return err
+	}
+	jobID, err := client.resolveJobID(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/v2/jobs/%s/hooks?limit=%d&offset=%d", jobID, jobsHooksLimit, jobsHooksOffset)
+	var resp jobsRunEventsListResponse
+	if err := client.do(cmd.Context(), http.MethodGet, path, nil, &resp); err != nil {
+		return err
+	}
+	if jobsJSON {
+		return printJSON(resp.Data)
+	}
+	if len(resp.Data) == 0 {
+		fmt.Println("No webhook deliveries found.")
+		return nil
+	}
+	fmt.Printf("%-20s %-24s %-20s %-38s %-24s\n", "RECEIVED_AT", "RUN_ID", "EVENT", "DELIVERY_ID", "REPLAY_OF")
+	for _, ev := range resp.Data {
+		var data struct {
+			DeliveryID string `json:"delivery_id"`
+			Event      string `json:"event"`
+			ReplayOf   string `json:"replay_of"`
+		}
+		_ = json.Unmarshal(ev.Data, &data)
+		event := data.Event
+		if event == "" {
+			event = "-"
+		}
+		replayOf := data.ReplayOf
+		if replayOf == "" {
+			replayOf = "-"
+		}
+		fmt.Printf("%-20s %-24s %-20s %-38s %-24s\n", ev.CreatedAt.Local().Format(time.RFC3339), ev.RunID, truncateCell(event, 20), truncateCell(data.DeliveryID, 38), replayOf)
+	}
+	return nil
+}
+
+func runJobsHooksReplay(cmd *cobra.Command, args []string) error {
+	client, err := newJobsClient()
+	if err != nil {
+		return err
+	}
+	var delivery jobsV2WebhookDelivery
+	if err := client.do(cmd.Context(), http.MethodPost, "/v2/runs/"+strings.TrimSpace(args[0])+"/replay", nil, &delivery); err != nil {
+		return err
+	}
+	return printJSON(delivery)
+}
+
 func jobsArgCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel()
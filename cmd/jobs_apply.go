@@ -0,0 +1,711 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/samsaffron/term-llm/internal/tools"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	jobsApplyFiles  []string
+	jobsApplyDryRun bool
+	jobsApplyPrune  bool
+)
+
+var jobsApplyCmd = &cobra.Command{
+	Use:   "apply -f <file-or-dir>",
+	Short: "Reconcile job definitions from YAML/JSON files",
+	Long: `Create, update and disable jobs so the server matches a set of definition files.
+
+Each definition needs a stable "key". Jobs are matched by key, so renaming a
+job updates it in place. An existing job without a key that has the same name
+is adopted. Keyed jobs that are no longer in the files are disabled, or
+deleted with --prune. Jobs without a key are never touched.
+
+A file may hold a single job, a list of jobs, a {"jobs": [...]} document, or
+several YAML documents. Directories are searched recursively for .yaml, .yml
+and .json files.
+
+Examples:
+  term-llm jobs apply -f jobs.yaml --dry-run
+  term-llm jobs apply -f jobs/ --prune
+  term-llm jobs export > jobs.yaml`,
+	Args: cobra.NoArgs,
+	RunE: runJobsApply,
+}
+
+var jobsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print job definitions in jobs apply format",
+	Long: `Print all job definitions as YAML (or JSON with --json) that jobs apply accepts.
+
+Jobs without a key are exported with their name as the key. Agent jobs queued
+by queue_agent are skipped.`,
+	Args: cobra.NoArgs,
+	RunE: runJobsExport,
+}
+
+func init() {
+	jobsApplyCmd.Flags().StringArrayVarP(&jobsApplyFiles, "file", "f", nil, "Definition file or directory (repeatable)")
+	jobsApplyCmd.Flags().BoolVar(&jobsApplyDryRun, "dry-run", false, "Show what would change without changing anything")
+	jobsApplyCmd.Flags().BoolVar(&jobsApplyPrune, "prune", false, "Delete keyed jobs that are not in the files instead of disabling them")
+	_ = jobsApplyCmd.MarkFlagRequired("file")
+
+	jobsCmd.AddCommand(jobsApplyCmd)
+	jobsCmd.AddCommand(jobsExportCmd)
+}
+
+const (
+	jobsApplyCreate  = "create"
+	jobsApplyUpdate  = "update"
+	jobsApplyDisable = "disable"
+	jobsApplyDelete  = "delete"
+)
+
+// jobsApplySpec is one job definition loaded from a file.
+type jobsApplySpec struct {
+	Request jobsV2JobRequest
+	Source  string
+}
+
+type jobsApplyChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+type jobsApplyAction struct {
+	Op      string            `json:"op"`
+	Key     string            `json:"key"`
+	Name    string            `json:"name"`
+	JobID   string            `json:"job_id,omitempty"`
+	Source  string            `json:"source,omitempty"`
+	Changes []jobsApplyChange `json:"changes,omitempty"`
+
+	body map[string]any
+}
+
+type jobsApplyPlan struct {
+	Actions   []jobsApplyAction `json:"actions"`
+	Unchanged int               `json:"unchanged"`
+}
+
+func runJobsApply(cmd *cobra.Command, args []string) error {
+	specs, err := loadJobsApplySpecs(jobsApplyFiles)
+	if err != nil {
+		return err
+	}
+	client, err := newJobsClient()
+	if err != nil {
+		return err
+	}
+	current, err := client.listAllJobs(cmd.Context())
+	if err != nil {
+		return err
+	}
+	plan, err := planJobsApply(specs, current, jobsApplyPrune)
+	if err != nil {
+		return err
+	}
+	if !jobsApplyDryRun {
+		if err := client.applyJobsPlan(cmd.Context(), plan); err != nil {
+			return err
+		}
+	}
+	if jobsJSON {
+		return printJSON(plan)
+	}
+	printJobsApplyPlan(os.Stdout, plan, jobsApplyDryRun)
+	return nil
+}
+
+func runJobsExport(cmd *cobra.Command, args []string) error {
+	client, err := newJobsClient()
+	if err != nil {
+		return err
+	}
+	jobs, err := client.listAllJobs(cmd.Context())
+	if err != nil {
+		return err
+	}
+	doc := map[string]any{"jobs": exportJobsApplyDefinitions(jobs)}
+	if jobsJSON {
+		return printJSON(doc)
+	}
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// listAllJobs pages through every job definition on the server.
+func (c *jobsClient) listAllJobs(ctx context.Context) ([]jobsV2Job, error) {
+	const pageSize = 200
+	var all []jobsV2Job
+	for offset := 0; ; offset += pageSize {
+		var resp struct {
+			Data  []jobsV2Job `json:"data"`
+			Total int         `json:"total"`
+		}
+		if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v2/jobs?limit=%d&offset=%d", pageSize, offset), nil, &resp); err != nil {
+			return nil, err
+		}
+		all = append(all, resp.Data...)
+		if len(resp.Data) < pageSize || len(all) >= resp.Total {
+			return all, nil
+		}
+	}
+}
+
+func loadJobsApplySpecs(paths []string) ([]jobsApplySpec, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != p && strings.HasPrefix(d.Name(), ".") {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".yaml", ".yml", ".json":
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+
+	var specs []jobsApplySpec
+	seenKeys := make(map[string]string)
+	seenNames := make(map[string]string)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		fileSpecs, err := parseJobsApplyFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for i, req := range fileSpecs {
+			source := fmt.Sprintf("%s[%d]", file, i)
+			req.Key = strings.TrimSpace(req.Key)
+			req.Name = strings.TrimSpace(req.Name)
+			if req.Key == "" {
+				return nil, fmt.Errorf("%s: key is required", source)
+			}
+			if err := validateJobsV2Key(req.Key); err != nil {
+				return nil, fmt.Errorf("%s: %w", source, err)
+			}
+			if req.Name == "" {
+				return nil, fmt.Errorf("%s: name is required", source)
+			}
+			if prev, ok := seenKeys[req.Key]; ok {
+				return nil, fmt.Errorf("%s: duplicate key %q (also in %s)", source, req.Key, prev)
+			}
+			if prev, ok := seenNames[req.Name]; ok {
+				return nil, fmt.Errorf("%s: duplicate name %q (also in %s)", source, req.Name, prev)
+			}
+			seenKeys[req.Key] = source
+			seenNames[req.Name] = source
+			specs = append(specs, jobsApplySpec{Request: req, Source: source})
+		}
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no job definitions found in %s", strings.Join(paths, ", "))
+	}
+	return specs, nil
+}
+
+// parseJobsApplyFile accepts a job, a list of jobs, or {"jobs": [...]}, in
+// one or more YAML documents (JSON is valid YAML).
+func parseJobsApplyFile(data []byte) ([]jobsV2JobRequest, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var out []jobsV2JobRequest
+	for {
+		var doc any
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		doc = normalizeYAMLValue(doc)
+		if m, ok := doc.(map[string]any); ok {
+			if jobs, ok := m["jobs"]; ok && len(m) == 1 {
+				doc = jobs
+			}
+		}
+		var items []any
+		switch v := doc.(type) {
+		case nil:
+			continue
+		case []any:
+			items = v
+		default:
+			items = []any{v}
+		}
+		for _, item := range items {
+			encoded, err := json.Marshal(item)
+			if err != nil {
+				return nil, err
+			}
+			var req jobsV2JobRequest
+			jsonDec := json.NewDecoder(bytes.NewReader(encoded))
+			jsonDec.DisallowUnknownFields()
+			if err := jsonDec.Decode(&req); err != nil {
+				return nil, fmt.Errorf("invalid job definition: %w", err)
+			}
+			out = append(out, req)
+		}
+	}
+}
+
+// planJobsApply diffs the desired specs against the server's jobs. Optional
+// fields a spec leaves out are compared against what a new job would get: enabled,
+// timeout_seconds, concurrency_policy, max_concurrent_runs and misfire_policy
+// against the server defaults, and schedule_timezone, retry_policy, labels
+// and artifacts against unset. Removing a field from the file resets it.
+func planJobsApply(specs []jobsApplySpec, current []jobsV2Job, prune bool) (jobsApplyPlan, error) {
+	byKey := make(map[string]jobsV2Job)
+	byName := make(map[string]jobsV2Job)
+	refs := make(map[string]string)
+	for _, job := range current {
+		if job.Key != "" {
+			byKey[job.Key] = job
+		}
+		byName[job.Name] = job
+	}
+	// Mirror lookupJobIDByRef precedence (ID, then key, then name) so
+	// after-trigger references compare equal to the stored job ID.
+	for _, job := range current {
+		refs[job.Name] = job.ID
+	}
+	for _, job := range current {
+		if job.Key != "" {
+			refs[job.Key] = job.ID
+		}
+	}
+	for _, job := range current {
+		refs[job.ID] = job.ID
+	}
+
+	specs, err := orderJobsApplySpecs(specs)
+	if err != nil {
+		return jobsApplyPlan{}, err
+	}
+
+	var plan jobsApplyPlan
+	wanted := make(map[string]bool)
+	for _, spec := range specs {
+		req := spec.Request
+		wanted[req.Key] = true
+		existing, ok := byKey[req.Key]
+		if !ok {
+			if named, found := byName[req.Name]; found {
+				if named.Key != "" {
+					return jobsApplyPlan{}, fmt.Errorf("%s: name %q is already used by job %s with key %q", spec.Source, req.Name, named.ID, named.Key)
+				}
+				existing, ok = named, true
+			}
+		}
+		if !ok {
+			body, err := jobsApplyBody(req)
+			if err != nil {
+				return jobsApplyPlan{}, fmt.Errorf("%s: %w", spec.Source, err)
+			}
+			plan.Actions = append(plan.Actions, jobsApplyAction{Op: jobsApplyCreate, Key: req.Key, Name: req.Name, Source: spec.Source, body: body})
+			continue
+		}
+		changes, body, err := diffJobsApplySpec(req, existing, refs)
+		if err != nil {
+			return jobsApplyPlan{}, fmt.Errorf("%s: %w", spec.Source, err)
+		}
+		if len(changes) == 0 {
+			plan.Unchanged++
+			continue
+		}
+		plan.Actions = append(plan.Actions, jobsApplyAction{Op: jobsApplyUpdate, Key: req.Key, Name: req.Name, JobID: existing.ID, Source: spec.Source, Changes: changes, body: body})
+	}
+
+	var stale []jobsV2Job
+	for _, job := range current {
+		if job.Key != "" && !wanted[job.Key] {
+			stale = append(stale, job)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Key < stale[j].Key })
+	for _, job := range stale {
+		switch {
+		case prune:
+			plan.Actions = append(plan.Actions, jobsApplyAction{Op: jobsApplyDelete, Key: job.Key, Name: job.Name, JobID: job.ID})
+		case job.Enabled:
+			plan.Actions = append(plan.Actions, jobsApplyAction{
+				Op: jobsApplyDisable, Key: job.Key, Name: job.Name, JobID: job.ID,
+				Changes: []jobsApplyChange{{Field: "enabled", Old: "true", New: "false"}},
+				body:    map[string]any{"enabled": false},
+			})
+		default:
+			plan.Unchanged++
+		}
+	}
+	return plan, nil
+}
+
+// orderJobsApplySpecs puts after-triggered jobs behind the jobs they chain
+// from, so an upstream created in the same apply exists when its downstream
+// is created.
+func orderJobsApplySpecs(specs []jobsApplySpec) ([]jobsApplySpec, error) {
+	index := make(map[string]int)
+	for i, spec := range specs {
+		index[spec.Request.Key] = i
+		if _, ok := index[spec.Request.Name]; !ok {
+			index[spec.Request.Name] = i
+		}
+	}
+	upstream := func(spec jobsApplySpec) (int, bool) {
+		if spec.Request.TriggerType != jobsV2TriggerAfter {
+			return 0, false
+		}
+		var cfg jobsV2TriggerConfig
+		if err := json.Unmarshal(spec.Request.TriggerConfig, &cfg); err != nil {
+			return 0, false
+		}
+		i, ok := index[strings.TrimSpace(cfg.JobID)]
+		return i, ok
+	}
+
+	ordered := make([]jobsApplySpec, 0, len(specs))
+	state := make([]int, len(specs)) // 0 = pending, 1 = visiting, 2 = done
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case 1:
+			return fmt.Errorf("%s: after trigger chain forms a cycle", specs[i].Source)
+		case 2:
+			return nil
+		}
+		state[i] = 1
+		if up, ok := upstream(specs[i]); ok {
+			if err := visit(up); err != nil {
+				return err
+			}
+		}
+		state[i] = 2
+		ordered = append(ordered, specs[i])
+		return nil
+	}
+	for i := range specs {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// jobsApplyBody converts a spec to the JSON object sent to the server,
+// keeping only the fields the spec sets.
+func jobsApplyBody(req jobsV2JobRequest) (map[string]any, error) {
+	encoded, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]any
+	if err := json.Unmarshal(encoded, &body); err != nil {
+		return nil, err
+	}
+	for field, value := range body {
+		if value == nil || value == "" || value == float64(0) {
+			delete(body, field)
+		}
+	}
+	return body, nil
+}
+
+func diffJobsApplySpec(req jobsV2JobRequest, existing jobsV2Job, refs map[string]string) ([]jobsApplyChange, map[string]any, error) {
+	want, err := jobsApplyBody(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := want["enabled"]; !ok {
+		want["enabled"] = true
+	}
+	have, err := jobsApplyBody(jobsV2JobToRequest(existing))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, field := range []string{"retry_policy", "labels", "artifacts"} {
+		if _, ok := want[field]; !ok && have[field] != nil {
+			want[field] = nil
+		}
+	}
+	for field, value := range map[string]any{
+		"schedule_timezone":   "",
+		"concurrency_policy":  jobsV2DefaultConcurrencyPolicy,
+		"max_concurrent_runs": float64(jobsV2DefaultMaxConcurrentRuns),
+		"timeout_seconds":     float64(jobsV2DefaultTimeoutSeconds),
+		"misfire_policy":      jobsV2DefaultMisfirePolicy,
+	} {
+		if _, ok := want[field]; !ok {
+			want[field] = value
+		}
+	}
+	if existing.Key == "" {
+		delete(have, "key")
+	}
+
+	fields := make([]string, 0, len(want))
+	for field := range want {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var changes []jobsApplyChange
+	patch := make(map[string]any)
+	for _, field := range fields {
+		oldValue, newValue := have[field], want[field]
+		if field == "trigger_config" {
+			triggerType := existing.TriggerType
+			if req.TriggerType != "" {
+				triggerType = req.TriggerType
+			}
+			scheduleTZ, _ := want["schedule_timezone"].(string)
+			oldValue = normalizeJobsApplyTriggerConfig(existing.TriggerType, existing.ScheduleTimezone, oldValue, refs)
+			newValue = normalizeJobsApplyTriggerConfig(triggerType, scheduleTZ, newValue, refs)
+		}
+		oldText, newText := jobsApplyValueText(oldValue), jobsApplyValueText(newValue)
+		if oldText == newText {
+			continue
+		}
+		changes = append(changes, jobsApplyChange{Field: field, Old: oldText, New: newText})
+		patch[field] = want[field]
+	}
+	return changes, patch, nil
+}
+
+// normalizeJobsApplyTriggerConfig applies the server's trigger defaults so
+// omitted fields compare equal, and resolves an after-trigger job_id
+// reference to the stored job ID. The server never returns an inline webhook
+// secret, so it is left out of the comparison: a missing or redacted secret
+// counts as unchanged.
+func normalizeJobsApplyTriggerConfig(tt jobsV2TriggerType, scheduleTZ string, value any, refs map[string]string) any {
+	if fields, ok := value.(map[string]any); ok && tt == jobsV2TriggerWebhook {
+		stripped := make(map[string]any, len(fields))
+		for field, v := range fields {
+			if field != "secret" && field != "secret_set" {
+				stripped[field] = v
+			}
+		}
+		if env, _ := stripped["secret_env"].(string); strings.TrimSpace(env) == "" {
+			// Satisfy validation so defaults still apply.
+			stripped["secret"] = "unset"
+		}
+		value = stripped
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	cfg, err := parseTriggerConfig(tt, encoded, scheduleTZ)
+	if err != nil {
+		return value
+	}
+	if id, ok := refs[strings.TrimSpace(cfg.JobID)]; ok {
+		cfg.JobID = id
+	}
+	cfg.Secret = ""
+	encoded, err = json.Marshal(cfg)
+	if err != nil {
+		return value
+	}
+	var out any
+	_ = json.Unmarshal(encoded, &out)
+	return out
+}
+
+func jobsApplyValueText(value any) string {
+	if value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	// json.Marshal sorts map keys, giving a stable comparison.
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+func (c *jobsClient) applyJobsPlan(ctx context.Context, plan jobsApplyPlan) error {
+	for i := range plan.Actions {
+		action := &plan.Actions[i]
+		var err error
+		switch action.Op {
+		case jobsApplyCreate:
+			payload, _ := json.Marshal(action.body)
+			var job jobsV2Job
+			err = c.do(ctx, http.MethodPost, "/v2/jobs", payload, &job)
+			action.JobID = job.ID
+		case jobsApplyUpdate, jobsApplyDisable:
+			payload, _ := json.Marshal(action.body)
+			err = c.do(ctx, http.MethodPatch, "/v2/jobs/"+action.JobID, payload, nil)
+		case jobsApplyDelete:
+			err = c.do(ctx, http.MethodDelete, "/v2/jobs/"+action.JobID, nil, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s (key %s): %w", action.Op, action.Name, action.Key, err)
+		}
+	}
+	return nil
+}
+
+func printJobsApplyPlan(w io.Writer, plan jobsApplyPlan, dryRun bool) {
+	counts := make(map[string]int)
+	for _, action := range plan.Actions {
+		counts[action.Op]++
+		symbol := map[string]string{jobsApplyCreate: "+", jobsApplyUpdate: "~", jobsApplyDisable: "-", jobsApplyDelete: "x"}[action.Op]
+		fmt.Fprintf(w, "%s %-8s %s (key %s)\n", symbol, action.Op, action.Name, action.Key)
+		for _, change := range action.Changes {
+			fmt.Fprintf(w, "    %s: %s -> %s\n", change.Field, jobsApplyChangeText(change.Old), jobsApplyChangeText(change.New))
+		}
+	}
+	verb := "Applied"
+	if dryRun {
+		verb = "Dry run"
+	}
+	fmt.Fprintf(w, "%s: %d to create, %d to update, %d to disable, %d to delete, %d unchanged.\n",
+		verb, counts[jobsApplyCreate], counts[jobsApplyUpdate], counts[jobsApplyDisable], counts[jobsApplyDelete], plan.Unchanged)
+}
+
+func jobsApplyChangeText(text string) string {
+	if text == "" {
+		return "(unset)"
+	}
+	return truncateCell(strings.ReplaceAll(text, "\n", " "), 120)
+}
+
+// jobsExportDefinition orders fields the way a person would write them.
+type jobsExportDefinition struct {
+	Key               string `yaml:"key" json:"key"`
+	Name              string `yaml:"name" json:"name"`
+	Enabled           *bool  `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	RunnerType        string `yaml:"runner_type" json:"runner_type"`
+	RunnerConfig      any    `yaml:"runner_config" json:"runner_config"`
+	TriggerType       string `yaml:"trigger_type" json:"trigger_type"`
+	TriggerConfig     any    `yaml:"trigger_config,omitempty" json:"trigger_config,omitempty"`
+	ScheduleTimezone  string `yaml:"schedule_timezone,omitempty" json:"schedule_timezone,omitempty"`
+	ConcurrencyPolicy string `yaml:"concurrency_policy,omitempty" json:"concurrency_policy,omitempty"`
+	MaxConcurrentRuns int    `yaml:"max_concurrent_runs,omitempty" json:"max_concurrent_runs,omitempty"`
+	RetryPolicy       any    `yaml:"retry_policy,omitempty" json:"retry_policy,omitempty"`
+	TimeoutSeconds    int    `yaml:"timeout_seconds,omitempty" json:"timeout_seconds,omitempty"`
+	MisfirePolicy     string `yaml:"misfire_policy,omitempty" json:"misfire_policy,omitempty"`
+	Labels            any    `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Artifacts         any    `yaml:"artifacts,omitempty" json:"artifacts,omitempty"`
+}
+
+func exportJobsApplyDefinitions(jobs []jobsV2Job) []jobsExportDefinition {
+	keyOf := func(job jobsV2Job) string {
+		if job.Key != "" {
+			return job.Key
+		}
+		return job.Name
+	}
+	keys := make(map[string]string, len(jobs))
+	for _, job := range jobs {
+		keys[job.ID] = keyOf(job)
+	}
+	decode := func(raw json.RawMessage) any {
+		if len(raw) == 0 || string(raw) == "null" {
+			return nil
+		}
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return string(raw)
+		}
+		return v
+	}
+
+	out := make([]jobsExportDefinition, 0, len(jobs))
+	for _, job := range jobs {
+		var labels map[string]string
+		if json.Unmarshal(job.Labels, &labels) == nil && labels[tools.QueueAgentEphemeralJobLabelKey] == tools.QueueAgentEphemeralJobLabelValue {
+			continue
+		}
+		var runnerConfig map[string]any
+		if err := json.Unmarshal(job.RunnerConfig, &runnerConfig); err == nil {
+			// notify_origin is bound to the server it was queued on and is
+			// stripped from API writes anyway.
+			delete(runnerConfig, "notify_origin")
+		}
+		triggerConfig := decode(job.TriggerConfig)
+		if cfg, ok := triggerConfig.(map[string]any); ok {
+			switch job.TriggerType {
+			case jobsV2TriggerAfter:
+				if id, _ := cfg["job_id"].(string); keys[id] != "" {
+					cfg["job_id"] = keys[id]
+				}
+			case jobsV2TriggerWebhook:
+				// Inline secrets never leave the server; applying the
+				// export keeps the stored one.
+				delete(cfg, "secret")
+				delete(cfg, "secret_set")
+			}
+		}
+		def := jobsExportDefinition{
+			Key:               keyOf(job),
+			Name:              job.Name,
+			RunnerType:        string(job.RunnerType),
+			RunnerConfig:      runnerConfig,
+			TriggerType:       string(job.TriggerType),
+			TriggerConfig:     triggerConfig,
+			ScheduleTimezone:  job.ScheduleTimezone,
+			ConcurrencyPolicy: job.ConcurrencyPolicy,
+			MaxConcurrentRuns: job.MaxConcurrentRuns,
+			RetryPolicy:       decode(job.RetryPolicy),
+			TimeoutSeconds:    job.TimeoutSeconds,
+			MisfirePolicy:     job.MisfirePolicy,
+			Labels:            decode(job.Labels),
+			Artifacts:         decode(job.Artifacts),
+		}
+		if !job.Enabled {
+			disabled := false
+			def.Enabled = &disabled
+		}
+		out = append(out, def)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+func TestParseJobsApplyFileShapes(t *testing.T) {
+	input := `key: one
+name: one
+runner_type: program
+runner_config: {command: "true"}
+trigger_type: manual
+---
+- {key: two, name: two, runner_type: program, runner_config: {command: "true"}, trigger_type: manual}
+---
+jobs:
+  - {key: three, name: three, runner_type: program, runner_config: {command: "true"}, trigger_type: manual}
+`
+	reqs, err := parseJobsApplyFile([]byte(input))
+	if err != nil {
+		t.Fatalf("parseJobsApplyFile: %v", err)
+	}
+	if len(reqs) != 3 || reqs[0].Key != "one" || reqs[1].Key != "two" || reqs[2].Key != "three" {
+		t.Fatalf("reqs = %+v", reqs)
+	}
+	if _, err := parseJobsApplyFile([]byte("key: x\nname: x\nschedule: daily\n")); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Fatalf("unknown field err = %v", err)
+	}
+}
+
+func TestRunJobsApplyReconcilesAgainstServer(t *testing.T) {
+	mgr, write, apply := newJobsApplyTestServer(t)
+
+	// An unkeyed job with a matching name is adopted; a keyed job missing
+	// from the file is disabled.
+	legacy, err := mgr.CreateJob(jobsV2Job{Name: "nightly-report", Enabled: true, RunnerType: jobsV2RunnerProgram,
+		RunnerConfig: json.RawMessage(`{"command":"echo","args":["old"]}`), TriggerType: jobsV2TriggerManual, TriggerConfig: json.RawMessage(`{}`)})
+	if err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	stale, err := mgr.CreateJob(jobsV2Job{Key: "retired", Name: "retired", Enabled: true, RunnerType: jobsV2RunnerProgram,
+		RunnerConfig: json.RawMessage(`{"command":"true"}`), TriggerType: jobsV2TriggerManual, TriggerConfig: json.RawMessage(`{}`)})
+	if err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+
+	// The downstream job is listed first; apply must create its upstream
+	// before it.
+	write(`jobs:
+  - key: publish
+    name: publish
+    runner_type: program
+    runner_config: {command: "true"}
+    trigger_type: after
+    trigger_config: {job_id: build}
+  - key: build
+    name: build
+    runner_type: program
+    runner_config: {command: make}
+    trigger_type: manual
+  - key: report
+    name: nightly-report
+    runner_type: program
+    runner_config: {command: echo, args: [new]}
+    trigger_type: manual
+`)
+	out := apply(true, false)
+	for _, want := range []string{"+ create   build", "+ create   publish", "~ update   nightly-report", `runner_config: {"args":["old"],"command":"echo"} -> {"args":["new"],"command":"echo"}`, "- disable  retired", "Dry run: 2 to create, 1 to update, 1 to disable"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("dry run output missing %q:\n%s", want, out)
+		}
+	}
+	if jobs, _, _ := mgr.ListJobs(100, 0); len(jobs) != 2 {
+		t.Fatalf("dry run changed jobs: %d", len(jobs))
+	}
+
+	apply(false, false)
+	adopted, err := mgr.GetJob(legacy.ID)
+	if err != nil || adopted.Key != "report" || !strings.Contains(string(adopted.RunnerConfig), "new") {
+		t.Fatalf("adopted job = %+v, %v", adopted, err)
+	}
+	if got, _ := mgr.GetJob(stale.ID); got.Enabled {
+		t.Fatal("stale keyed job still enabled")
+	}
+	buildID, err := mgr.lookupJobIDByRef("build")
+	if err != nil {
+		t.Fatalf("lookup build: %v", err)
+	}
+	publishID, _ := mgr.lookupJobIDByRef("publish")
+	publish, _ := mgr.GetJob(publishID)
+	if !strings.Contains(string(publish.TriggerConfig), buildID) {
+		t.Fatalf("publish trigger_config = %s, want upstream %s", publish.TriggerConfig, buildID)
+	}
+
+	// Re-applying the same file is a no-op, and a rename keeps the job.
+	if out := apply(false, false); !strings.Contains(out, "0 to create, 0 to update, 0 to disable, 0 to delete, 4 unchanged") {
+		t.Fatalf("idempotent apply output:\n%s", out)
+	}
+	write(`- {key: build, name: build-all, runner_type: program, runner_config: {command: make}, trigger_type: manual}`)
+	out = apply(false, true)
+	if !strings.Contains(out, "name: build -> build-all") || !strings.Contains(out, "x delete   publish") {
+		t.Fatalf("rename/prune output:\n%s", out)
+	}
+	renamed, err := mgr.GetJob(buildID)
+	if err != nil || renamed.Name != "build-all" {
+		t.Fatalf("renamed job = %+v, %v", renamed, err)
+	}
+	if jobs, _, _ := mgr.ListJobs(100, 0); len(jobs) != 1 {
+		t.Fatalf("jobs after prune = %d, want 1", len(jobs))
+	}
+}
+
+func TestRunJobsApplyResetsRemovedFields(t *testing.T) {
+	mgr, write, apply := newJobsApplyTestServer(t)
+
+	write(`- key: nightly
+  name: nightly
+  runner_type: program
+  runner_config: {command: "true"}
+  trigger_type: cron
+  trigger_config: {expression: "0 3 * * *", timezone: UTC}
+  schedule_timezone: America/New_York
+  concurrency_policy: allow
+  max_concurrent_runs: 3
+  timeout_seconds: 60
+  misfire_policy: run
+`)
+	apply(false, false)
+	id, err := mgr.lookupJobIDByRef("nightly")
+	if err != nil {
+		t.Fatalf("lookup nightly: %v", err)
+	}
+	if job, _ := mgr.GetJob(id); job.ScheduleTimezone != "America/New_York" || job.TimeoutSeconds != 60 {
+		t.Fatalf("created job = %+v", job)
+	}
+
+	// Dropping the fields resets them to what a new job would get.
+	write(`- key: nightly
+  name: nightly
+  runner_type: program
+  runner_config: {command: "true"}
+  trigger_type: cron
+  trigger_config: {expression: "0 3 * * *", timezone: UTC}
+`)
+	out := apply(false, false)
+	for _, want := range []string{"schedule_timezone: America/New_York -> (unset)", "concurrency_policy: allow -> forbid", "max_concurrent_runs: 3 -> 1", "timeout_seconds: 60 -> 300", "misfire_policy: run -> skip"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("apply output missing %q:\n%s", want, out)
+		}
+	}
+	job, err := mgr.GetJob(id)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if job.ScheduleTimezone != "" || job.ConcurrencyPolicy != jobsV2DefaultConcurrencyPolicy || job.MaxConcurrentRuns != jobsV2DefaultMaxConcurrentRuns ||
+		job.TimeoutSeconds != jobsV2DefaultTimeoutSeconds || job.MisfirePolicy != jobsV2DefaultMisfirePolicy {
+		t.Fatalf("job after removing fields = %+v, want server defaults", job)
+	}
+	if out := apply(false, false); !strings.Contains(out, "0 to update") || !strings.Contains(out, "1 unchanged") {
+		t.Fatalf("re-apply output:\n%s", out)
+	}
+}
+
+// newJobsApplyTestServer points the jobs CLI at an in-memory server and
+// returns helpers to write the definition file and run jobs apply.
+func newJobsApplyTestServer(t *testing.T) (*jobsV2Manager, func(string), func(dryRun, prune bool) string) {
+	t.Helper()
+	mgr, err := newJobsV2Manager(":memory:", 0, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager: %v", err)
+	}
+	t.Cleanup(func() { _ = mgr.Close() })
+	s := &serveServer{jobsV2: mgr}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/jobs", s.handleJobsV2)
+	mux.HandleFunc("/v2/jobs/", s.handleJobV2ByID)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	oldServerURL, oldToken, oldTimeout, oldJSON := jobsServerURL, jobsToken, jobsTimeout, jobsJSON
+	oldFiles, oldDryRun, oldPrune := jobsApplyFiles, jobsApplyDryRun, jobsApplyPrune
+	jobsServerURL, jobsToken, jobsTimeout, jobsJSON = srv.URL, "", 5*time.Second, false
+	t.Cleanup(func() {
+		jobsServerURL, jobsToken, jobsTimeout, jobsJSON = oldServerURL, oldToken, oldTimeout, oldJSON
+		jobsApplyFiles, jobsApplyDryRun, jobsApplyPrune = oldFiles, oldDryRun, oldPrune
+	})
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "jobs.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	apply := func(dryRun, prune bool) string {
+		t.Helper()
+		jobsApplyFiles, jobsApplyDryRun, jobsApplyPrune = []string{dir}, dryRun, prune
+		cmd := &cobra.Command{}
+		cmd.SetContext(context.Background())
+		var runErr error
+		out := captureStdout(t, func() { runErr = runJobsApply(cmd, nil) })
+		if runErr != nil {
+			t.Fatalf("runJobsApply: %v\n%s", runErr, out)
+		}
+		return out
+	}
+
+	return mgr, write, apply
+}
+
+func TestExportJobsApplyDefinitionsUsesKeys(t *testing.T) {
+	defs := exportJobsApplyDefinitions([]jobsV2Job{
+		{ID: "job_a", Key: "build", Name: "Build", Enabled: true, RunnerType: jobsV2RunnerProgram, RunnerConfig: json.RawMessage(`{"command":"make"}`), TriggerType: jobsV2TriggerManual},
+		{ID: "job_b", Name: "publish", RunnerType: jobsV2RunnerProgram, RunnerConfig: json.RawMessage(`{"command":"true"}`), TriggerType: jobsV2TriggerAfter, TriggerConfig: json.RawMessage(`{"job_id":"job_a"}`)},
+		{ID: "job_c", Name: "queued", RunnerType: jobsV2RunnerLLM, RunnerConfig: json.RawMessage(`{}`), TriggerType: jobsV2TriggerOnce, Labels: json.RawMessage(tools.QueueAgentEphemeralJobLabelsJSON)},
+	})
+	if len(defs) != 2 {
+		t.Fatalf("defs = %+v, want ephemeral job skipped", defs)
+	}
+	if defs[0].Key != "build" || defs[0].Enabled != nil {
+		t.Fatalf("build def = %+v", defs[0])
+	}
+	if defs[1].Key != "publish" || defs[1].Enabled == nil || *defs[1].Enabled {
+		t.Fatalf("publish def = %+v", defs[1])
+	}
+	if cfg, _ := defs[1].TriggerConfig.(map[string]any); cfg["job_id"] != "build" {
+		t.Fatalf("publish trigger_config = %v, want upstream key", defs[1].TriggerConfig)
+	}
+}
+
+func TestJobsApplyIgnoresRedactedWebhookSecret(t *testing.T) {
+	mgr, err := newJobsV2Manager(":memory:", 0, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+	created, err := mgr.CreateJob(jobsV2Job{Key: "hook", Name: "hook", Enabled: true, RunnerType: jobsV2RunnerProgram,
+		RunnerConfig: json.RawMessage(`{"command":"true"}`), TriggerType: jobsV2TriggerWebhook, TriggerConfig: json.RawMessage(`{"secret":"s3cret","events":["push"]}`)})
+	if err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	// Read the job back the way the CLI sees it over the API.
+	encoded, err := json.Marshal(created)
+	if err != nil {
+		t.Fatalf("marshal job: %v", err)
+	}
+	var listed jobsV2Job
+	if err := json.Unmarshal(encoded, &listed); err != nil {
+		t.Fatalf("unmarshal job: %v", err)
+	}
+
+	defs := exportJobsApplyDefinitions([]jobsV2Job{listed})
+	if cfg, _ := defs[0].TriggerConfig.(map[string]any); cfg["secret"] != nil || cfg["secret_set"] != nil || cfg["events"] == nil {
+		t.Fatalf("exported trigger_config = %v, want secret stripped", defs[0].TriggerConfig)
+	}
+
+	for _, triggerConfig := range []string{`{"events":["push"]}`, `{"events":["push"],"secret_set":true}`, `{"events":["push"],"secret":"s3cret"}`} {
+		spec := jobsApplySpec{Source: "jobs.yaml[0]", Request: jobsV2JobRequest{Key: "hook", Name: "hook", RunnerType: jobsV2RunnerProgram,
+			RunnerConfig: json.RawMessage(`{"command":"true"}`), TriggerType: jobsV2TriggerWebhook, TriggerConfig: json.RawMessage(triggerConfig)}}
+		plan, err := planJobsApply([]jobsApplySpec{spec}, []jobsV2Job{listed}, false)
+		if err != nil {
+			t.Fatalf("planJobsApply(%s): %v", triggerConfig, err)
+		}
+		if len(plan.Actions) != 0 || plan.Unchanged != 1 {
+			t.Fatalf("plan for %s = %+v, want unchanged", triggerConfig, plan)
+		}
+	}
+}
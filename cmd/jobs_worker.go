@@ -0,0 +1,389 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	jobsWorkerLabels      []string
+	jobsWorkerName        string
+	jobsWorkerConcurrency int
+	jobsWorkerLease       time.Duration
+	jobsWorkerApproval    string
+	jobsWorkerYolo        bool
+)
+
+var jobsWorkerCmd = &cobra.Command{
+	Use:   "worker",
+	Short: "Run jobs for a remote server as a labeled worker",
+	Long: `Claim and run jobs from a jobs server over HTTP.
+
+A job is routed to remote workers by listing the labels it needs under
+labels.worker_labels; a worker only claims runs whose labels it has all of.
+Jobs without worker_labels stay on the server's own workers.
+
+The worker holds a lease on each run and renews it while the run is going.
+If the worker disappears, the server fails the run as worker_lost once the
+lease expires and queues it again.
+
+Interrupting the worker stops it claiming new runs and waits for active
+runs to finish.
+
+Examples:
+  term-llm jobs worker --server https://jobs.example.com --label gpu
+  term-llm jobs worker --label repo:term-llm --label region=eu --concurrency 2`,
+	Args: cobra.NoArgs,
+	RunE: runJobsWorker,
+}
+
+var jobsWorkersCmd = &cobra.Command{
+	Use:   "workers",
+	Short: "List job workers and the runs they hold",
+	Args:  cobra.NoArgs,
+	RunE:  runJobsWorkers,
+}
+
+func init() {
+	jobsWorkerCmd.Flags().StringArrayVar(&jobsWorkerLabels, "label", nil, "Worker label matched against labels.worker_labels (repeatable)")
+	jobsWorkerCmd.Flags().StringVar(&jobsWorkerName, "name", "", "Display name (defaults to the hostname)")
+	jobsWorkerCmd.Flags().IntVar(&jobsWorkerConcurrency, "concurrency", 1, "Number of runs to execute at once")
+	jobsWorkerCmd.Flags().DurationVar(&jobsWorkerLease, "lease", jobsV2DefaultLeaseDuration, "Lease requested per run; renewed every third of it")
+	jobsWorkerCmd.Flags().StringVar(&jobsWorkerApproval, "approval", "", "Approval mode for LLM jobs (defaults to serve.approval_mode)")
+	jobsWorkerCmd.Flags().BoolVar(&jobsWorkerYolo, "yolo", false, "Auto-approve all tool calls in LLM jobs")
+
+	jobsCmd.AddCommand(jobsWorkerCmd)
+	jobsCmd.AddCommand(jobsWorkersCmd)
+}
+
+const (
+	jobsWorkerClaimWait      = 25 * time.Second
+	jobsWorkerErrorDelay     = 5 * time.Second
+	jobsWorkerProgressFlush  = time.Second
+	jobsWorkerCompleteTries  = 5
+	jobsWorkerMaxBatchEvents = 200
+)
+
+// jobsRemoteWorker claims runs from a jobs server and executes them with
+// the same runners the server uses.
+type jobsRemoteWorker struct {
+	client      *jobsClient
+	claimClient *jobsClient
+	id          string
+	name        string
+	labels      []string
+	lease       time.Duration
+	runners     map[jobsV2RunnerType]jobsV2Runner
+	logf        func(format string, args ...any)
+}
+
+func runJobsWorker(cmd *cobra.Command, args []string) error {
+	if jobsWorkerConcurrency <= 0 {
+		return fmt.Errorf("invalid --concurrency %d (must be > 0)", jobsWorkerConcurrency)
+	}
+	labels, err := normalizeJobsV2WorkerLabels(jobsWorkerLabels)
+	if err != nil {
+		return err
+	}
+	if len(labels) == 0 {
+		return fmt.Errorf("at least one --label is required: remote workers only run jobs routed with labels.worker_labels")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	approval, err := resolveCommandApprovalMode(cmd, approvalSurfaceServe, cfg, nil, jobsWorkerApproval, false, jobsWorkerYolo)
+	if err != nil {
+		return err
+	}
+	if err := preflightHeadlessApproval(cfg, approval); err != nil {
+		return err
+	}
+	client, err := newJobsClient()
+	if err != nil {
+		return err
+	}
+
+	name := strings.TrimSpace(jobsWorkerName)
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	worker := &jobsRemoteWorker{
+		client:      client,
+		claimClient: client.withTimeout(jobsWorkerClaimWait + client.http.Timeout),
+		id:          "rworker_" + randomSuffix(),
+		name:        name,
+		labels:      labels,
+		lease:       jobsWorkerLease,
+		runners: map[jobsV2RunnerType]jobsV2Runner{
+			jobsV2RunnerProgram: &jobsV2ProgramRunner{},
+			jobsV2RunnerLLM:     &jobsV2LLMRunner{exec: newServeJobsExecutor(cfg, approval)},
+		},
+		logf: log.New(cmd.ErrOrStderr(), "", log.LstdFlags).Printf,
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	worker.logf("jobs worker %s (%s) labels=%s concurrency=%d server=%s", worker.id, name, strings.Join(labels, ","), jobsWorkerConcurrency, client.baseURL)
+	worker.Run(ctx, jobsWorkerConcurrency)
+	return nil
+}
+
+func (c *jobsClient) withTimeout(timeout time.Duration) *jobsClient {
+	clone := *c
+	clone.http = &http.Client{Timeout: timeout}
+	return &clone
+}
+
+// Run claims and executes runs on concurrency loops until ctx ends, then
+// waits for active runs.
+func (w *jobsRemoteWorker) Run(ctx context.Context, concurrency int) {
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				claim, ok, err := w.claim(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						w.logf("claim failed: %v", err)
+						sleepContext(ctx, jobsWorkerErrorDelay)
+					}
+					continue
+				}
+				if ok {
+					w.execute(claim)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *jobsRemoteWorker) claim(ctx context.Context) (jobsV2ClaimResponse, bool, error) {
+	body, _ := json.Marshal(jobsV2ClaimRequest{
+		WorkerID:     w.id,
+		Name:         w.name,
+		Labels:       w.labels,
+		LeaseSeconds: int(w.lease / time.Second),
+		WaitSeconds:  int(jobsWorkerClaimWait / time.Second),
+	})
+	resp, err := w.claimClient.send(ctx, http.MethodPost, "/v2/workers/claim", body)
+	if err != nil {
+		return jobsV2ClaimResponse{}, false, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return jobsV2ClaimResponse{}, false, err
+	}
+	if resp.StatusCode >= 400 {
+		return jobsV2ClaimResponse{}, false, jobsResponseError(resp.StatusCode, respBody)
+	}
+	if resp.StatusCode == http.StatusNoContent || len(respBody) == 0 {
+		return jobsV2ClaimResponse{}, false, nil
+	}
+	var claim jobsV2ClaimResponse
+	if err := json.Unmarshal(respBody, &claim); err != nil {
+		return jobsV2ClaimResponse{}, false, fmt.Errorf("decode claim: %w", err)
+	}
+	return claim, true, nil
+}
+
+// execute runs a claimed job while renewing its lease and streaming progress,
+// then reports the outcome. A lost lease abandons the run without reporting:
+// the server has already failed and re-queued it.
+func (w *jobsRemoteWorker) execute(claim jobsV2ClaimResponse) {
+	run, job := claim.Run, claim.Job
+	w.logf("run %s (%s) started", run.ID, job.Name)
+
+	timeout := time.Duration(job.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var pending []jobsV2ProgressEvent
+	var leaseLost bool
+	flush := func() {
+		mu.Lock()
+		batch := pending
+		pending = nil
+		mu.Unlock()
+		for len(batch) > 0 {
+			n := min(len(batch), jobsWorkerMaxBatchEvents)
+			body, _ := json.Marshal(jobsV2ProgressRequest{WorkerID: w.id, Events: batch[:n]})
+			if err := w.client.do(context.Background(), http.MethodPost, "/v2/runs/"+run.ID+"/progress", body, nil); err != nil {
+				w.logf("run %s: progress update failed: %v", run.ID, err)
+			}
+			batch = batch[n:]
+		}
+	}
+	pw := func(eventType, message string, data any) {
+		ev := jobsV2ProgressEvent{EventType: eventType, Message: message}
+		if data != nil {
+			if encoded, err := json.Marshal(data); err == nil {
+				ev.Data = encoded
+			}
+		}
+		mu.Lock()
+		// Only the latest partial response matters.
+		if eventType == "response_flush" && len(pending) > 0 && pending[len(pending)-1].EventType == eventType {
+			pending[len(pending)-1] = ev
+		} else {
+			pending = append(pending, ev)
+		}
+		mu.Unlock()
+	}
+
+	done := make(chan struct{})
+	var loops sync.WaitGroup
+	loops.Add(1)
+	go func() {
+		defer loops.Done()
+		heartbeat := time.NewTicker(max(claim.LeaseDuration()/3, time.Second))
+		defer heartbeat.Stop()
+		progress := time.NewTicker(jobsWorkerProgressFlush)
+		defer progress.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-progress.C:
+				flush()
+			case <-heartbeat.C:
+				var hb jobsV2HeartbeatResponse
+				body, _ := json.Marshal(jobsV2HeartbeatRequest{WorkerID: w.id, LeaseSeconds: claim.LeaseSeconds})
+				err := w.client.do(context.Background(), http.MethodPost, "/v2/runs/"+run.ID+"/heartbeat", body, &hb)
+				var respErr *jobsAPIError
+				switch {
+				case errors.As(err, &respErr) && respErr.Status == http.StatusConflict:
+					w.logf("run %s: lease lost, abandoning run", run.ID)
+					mu.Lock()
+					leaseLost = true
+					mu.Unlock()
+					cancel()
+				case err != nil:
+					w.logf("run %s: heartbeat failed: %v", run.ID, err)
+				case hb.Cancel:
+					w.logf("run %s: cancellation requested", run.ID)
+					cancel()
+				}
+			}
+		}
+	}()
+
+	result, runErr := w.runJob(ctx, job, pw)
+	close(done)
+	loops.Wait()
+	flush()
+
+	mu.Lock()
+	lost := leaseLost
+	mu.Unlock()
+	if lost {
+		return
+	}
+	status, runErr := jobsV2RunOutcome(ctx, result, runErr)
+	req := jobsV2CompleteRequest{WorkerID: w.id, Status: status, Result: jobsV2RemoteResultFrom(result)}
+	if runErr != nil {
+		req.Error = runErr.Error()
+	}
+	body, _ := json.Marshal(req)
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		err := w.client.do(context.Background(), http.MethodPost, "/v2/runs/"+run.ID+"/complete", body, nil)
+		var respErr *jobsAPIError
+		if err == nil || (errors.As(err, &respErr) && respErr.Status < http.StatusInternalServerError) || attempt >= jobsWorkerCompleteTries {
+			if err != nil {
+				w.logf("run %s: reporting %s failed: %v", run.ID, status, err)
+			} else {
+				w.logf("run %s (%s) %s", run.ID, job.Name, status)
+			}
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (w *jobsRemoteWorker) runJob(ctx context.Context, job jobsV2Job, pw progressWriter) (jobsV2RunResult, error) {
+	runner, ok := w.runners[job.RunnerType]
+	if !ok {
+		return jobsV2RunResult{}, fmt.Errorf("unknown runner type: %s", job.RunnerType)
+	}
+	return runner.Run(ctx, job, pw)
+}
+
+func (c jobsV2ClaimResponse) LeaseDuration() time.Duration {
+	return jobsV2LeaseDuration(c.LeaseSeconds)
+}
+
+// jobsV2RunOutcome maps a runner's return to the run status the server's own
+// workers would record for it.
+func jobsV2RunOutcome(ctx context.Context, result jobsV2RunResult, runErr error) (jobsV2RunStatus, error) {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded), result.ExitReason == exitReasonTimeout:
+		return jobsV2RunTimedOut, context.DeadlineExceeded
+	case errors.Is(ctx.Err(), context.Canceled), result.ExitReason == exitReasonCancelled:
+		return jobsV2RunCancelled, context.Canceled
+	case runErr != nil:
+		return jobsV2RunFailed, runErr
+	default:
+		return jobsV2RunSucceeded, nil
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+func runJobsWorkers(cmd *cobra.Command, args []string) error {
+	client, err := newJobsClient()
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Data []jobsV2Worker `json:"data"`
+	}
+	if err := client.do(cmd.Context(), http.MethodGet, "/v2/workers", nil, &resp); err != nil {
+		return err
+	}
+	if jobsJSON {
+		return printJSON(resp.Data)
+	}
+	fmt.Printf("%-24s %-20s %-7s %-32s %-6s %-20s\n", "WORKER_ID", "NAME", "REMOTE", "LABELS", "ACTIVE", "LAST_SEEN")
+	for _, worker := range resp.Data {
+		lastSeen := "-"
+		if worker.LastSeenAt != nil {
+			lastSeen = worker.LastSeenAt.Local().Format(time.RFC3339)
+		}
+		labels := strings.Join(worker.Labels, ",")
+		if labels == "" {
+			labels = "-"
+		}
+		fmt.Printf("%-24s %-20s %-7v %-32s %-6d %-20s\n", truncateCell(worker.ID, 24), truncateCell(worker.Name, 20), worker.Remote, truncateCell(labels, 32), len(worker.ActiveRuns), lastSeen)
+	}
+	return nil
+}
@@ -63,7 +63,11 @@ create media, and automate recurring work—from your terminal or browser.`,
 	SilenceErrors:     true,
 	SilenceUsage:      true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		return startProfiling()
+		if err := startProfiling(); err != nil {
+			return err
+		}
+		startTracing()
+		return nil
 	},
 	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
 		return stopProfiling()
@@ -202,7 +206,9 @@ func executeWithArgs(args []string) error {
 		return nil
 	}
 	rootCmd.SetArgs(normalizeShellCompletionArgs(args))
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	stopTracing()
+	return err
 }
 
 func detectShell() string {
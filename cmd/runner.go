@@ -497,7 +497,7 @@ func (r *cmdRunner) resolveSettings(cfg *config.Config, agent *agents.Agent, req
 	localLaunch := req.Platform == runpkg.PlatformConsole || req.Platform == runpkg.PlatformChat || req.Platform == runpkg.PlatformExec
 	if explicitBinding || localLaunch {
 		settings.PrimaryWorkspace = settings.BaseDir
-	} else if req.Platform == runpkg.PlatformWeb || req.Platform == runpkg.PlatformTelegram {
+	} else if req.Platform == runpkg.PlatformWeb || req.Platform == runpkg.PlatformTelegram || req.Platform == runpkg.PlatformSlack || req.Platform == runpkg.PlatformDiscord || req.Platform == runpkg.PlatformEmail || req.Platform == runpkg.PlatformMatrix {
 		// ResolveSettingsInDir uses process CWD for project-sensitive prompt setup,
 		// but an unbound daemon runtime must not retain that ambient directory as a
 		// tool-path or process-execution default. A later explicit session/worktree
@@ -738,6 +738,14 @@ func templatePlatform(platform string) string {
 		return "web"
 	case runpkg.PlatformTelegram:
 		return "telegram"
+	case runpkg.PlatformSlack:
+		return "slack"
+	case runpkg.PlatformDiscord:
+		return "discord"
+	case runpkg.PlatformEmail:
+		return "email"
+	case runpkg.PlatformMatrix:
+		return "matrix"
 	case runpkg.PlatformChat:
 		return "chat"
 	case runpkg.PlatformExec:
@@ -749,7 +757,7 @@ func templatePlatform(platform string) string {
 
 func sessionModeForPlatform(platform string) session.SessionMode {
 	switch templatePlatform(platform) {
-	case "chat", "web", "telegram":
+	case "chat", "web", "telegram", "slack", "discord", "email", "matrix":
 		return session.ModeChat
 	case "exec":
 		return session.ModeExec
@@ -764,6 +772,14 @@ func sessionOriginForPlatform(platform string) session.SessionOrigin {
 		return session.OriginWeb
 	case "telegram":
 		return session.OriginTelegram
+	case "slack":
+		return session.OriginSlack
+	case "discord":
+		return session.OriginDiscord
+	case "email":
+		return session.OriginEmail
+	case "matrix":
+		return session.OriginMatrix
 	default:
 		return session.OriginTUI
 	}
@@ -16,8 +16,10 @@ import (
 
 	webpush "github.com/SherClockHolmes/webpush-go"
 	"github.com/samsaffron/term-llm/internal/agents"
+	"github.com/samsaffron/term-llm/internal/batch"
 	"github.com/samsaffron/term-llm/internal/config"
 	"github.com/samsaffron/term-llm/internal/filetrack"
+	"github.com/samsaffron/term-llm/internal/hub"
 	"github.com/samsaffron/term-llm/internal/llm"
 	"github.com/samsaffron/term-llm/internal/mentions"
 	runpkg "github.com/samsaffron/term-llm/internal/run"
@@ -27,6 +29,8 @@ import (
 	"github.com/samsaffron/term-llm/internal/signal"
 	"github.com/samsaffron/term-llm/internal/skills"
 	"github.com/samsaffron/term-llm/internal/tools"
+	"github.com/samsaffron/term-llm/internal/tracing"
+	"github.com/samsaffron/term-llm/internal/webhooks"
 	"github.com/samsaffron/term-llm/internal/widgets"
 	"github.com/spf13/cobra"
 )
@@ -60,8 +64,9 @@ var (
 	serveApproval               string
 	serveYolo                   bool
 	serveAuto                   bool
-	serveTelegramCarryoverChars int
+	serveCarryoverChars         int
 	serveJobsWorkers            int
+	serveJobsWorkerLabels       []string
 	serveSetup                  bool
 	serveVerbose                bool
 	serveFilterServerTools      bool
@@ -77,7 +82,11 @@ var (
 	serveHubNodeName            string
 	serveHubConnect             string
 	serveHubRegister            bool
+	serveHubAdvertise           bool
 	serveHubRegistrationToken   string
+	serveKeysDB                 string
+	serveMetricsEnabled         bool
+	serveWebhooksDB             string
 )
 
 const (
@@ -88,7 +97,7 @@ const (
 
 var serveCmd = &cobra.Command{
 	Use:   "serve <platform> [platform...]",
-	Short: "Run the agent as a server (web, api, jobs, Telegram, or any combination)",
+	Short: "Run the agent as a server (web, api, jobs, Telegram, Slack, Discord, Matrix, email, or any combination)",
 	Long: `Run term-llm as a server on one or more platforms simultaneously.
 
 Available platforms:
@@ -96,6 +105,10 @@ Available platforms:
   api        HTTP server with API endpoints only (no UI)
   jobs       HTTP server with async job runner
   telegram   Telegram bot
+  slack      Slack bot (Socket Mode or Events API)
+  discord    Discord bot (gateway)
+  matrix     Matrix bot (client-server API)
+  email      Email (IMAP or LMTP in, SMTP out)
 
 Platforms are specified as positional arguments. If none are given, the
 serve.platforms list from config.yaml is used.
@@ -105,6 +118,10 @@ Examples:
   term-llm serve api             # API only (no chat UI)
   term-llm serve telegram        # Telegram bot only
   term-llm serve telegram web    # both platforms
+  term-llm serve slack web       # Slack bot plus web UI
+  term-llm serve discord         # Discord bot only
+  term-llm serve matrix          # Matrix bot only
+  term-llm serve email           # answer mail sent to the bot mailbox
   term-llm serve web --base-path /chat
   term-llm serve web --title "My Lab"
 
@@ -113,8 +130,13 @@ All HTTP routes are mounted under --base-path (default /ui):
   POST {base}/v1/chat/completions
   POST {base}/v1/messages
   POST {base}/v1/transcribe
+  POST {base}/v1/embeddings
+  POST {base}/v1/images/generations
+  POST {base}/v1/images/edits
+  POST {base}/v1/audio/speech
   GET  {base}/v1/models
   GET  {base}/healthz
+  GET  {base}/metrics                (with --metrics)
   GET  {base}/                       (web UI)
   GET  {base}/images/:file
 
@@ -127,10 +149,23 @@ Jobs endpoints (also under base-path):
   POST   {base}/v2/jobs/:id/trigger
   POST   {base}/v2/jobs/:id/pause
   POST   {base}/v2/jobs/:id/resume
+  POST   {base}/v2/jobs/:id/hooks       (HMAC-signed, no token)
+  GET    {base}/v2/jobs/:id/hooks
   GET    {base}/v2/runs
   GET    {base}/v2/runs/:id
   GET    {base}/v2/runs/:id/events
   POST   {base}/v2/runs/:id/cancel
+  POST   {base}/v2/runs/:id/replay
+  GET    {base}/v2/runs/:id/artifacts
+  GET    {base}/v2/runs/:id/artifacts/<path>
+  GET    {base}/v2/workers
+  POST   {base}/v2/workers/claim        (remote workers)
+  POST   {base}/v2/runs/:id/heartbeat   (remote workers)
+  POST   {base}/v2/runs/:id/progress    (remote workers)
+  POST   {base}/v2/runs/:id/complete    (remote workers)
+
+Besides --token, clients may authenticate with named API keys that carry
+their own scopes and quotas; see 'term-llm serve keys'.
 
 Use --setup to configure credentials for the selected platforms.`,
 	ValidArgsFunction: servePlatformCompletion,
@@ -155,8 +190,9 @@ func init() {
 	serveCmd.Flags().IntVar(&serveSessionMax, "session-max", 1000, "Max stateful sessions in memory")
 
 	serveCmd.Flags().BoolVar(&serveSetup, "setup", false, "Re-run setup wizard for selected platforms")
-	serveCmd.Flags().IntVar(&serveTelegramCarryoverChars, "telegram-carryover-chars", 4000, "Characters of previous Telegram session context to carry into replacement sessions (0 disables)")
+	serveCmd.Flags().IntVar(&serveCarryoverChars, "telegram-carryover-chars", 4000, "Characters of previous session context to carry into replacement Telegram and Matrix sessions (0 disables)")
 	serveCmd.Flags().IntVar(&serveJobsWorkers, "jobs-workers", 4, "Number of concurrent job workers for --platform jobs")
+	serveCmd.Flags().StringArrayVar(&serveJobsWorkerLabels, "jobs-worker-label", nil, "Label this server's job workers match against labels.worker_labels (repeatable)")
 	serveCmd.Flags().StringVar(&serveSidebarSessions, "sidebar-sessions", "all", "Default web sidebar session categories: all or a comma-separated list like chat,web,ask,plan,exec")
 	serveCmd.Flags().BoolVar(&serveVerbose, "verbose", false, "Log API request/response summaries to stderr")
 	serveCmd.Flags().StringArrayVar(&serveToolMap, "tool-map", nil, "Map client tool name to server tool (repeatable, format ClientName:ServerName)")
@@ -170,8 +206,12 @@ func init() {
 	serveCmd.Flags().StringVar(&serveHubNodeID, "hub-node-id", "", "This node's id on the hub (used with --hub-url)")
 	serveCmd.Flags().StringVar(&serveHubNodeName, "hub-node-name", "", "This node's display name on the hub (used with --hub-url)")
 	serveCmd.Flags().StringVar(&serveHubConnect, "hub-connect", "direct", "Hub connection mode for this node: direct or reverse")
-	serveCmd.Flags().BoolVar(&serveHubRegister, "hub-register", false, "Register this reverse node with the Hub before connecting")
+	serveCmd.Flags().BoolVar(&serveHubRegister, "hub-register", false, "Register this node's token with the Hub (reverse nodes before connecting, advertised nodes after discovery)")
+	serveCmd.Flags().BoolVar(&serveHubAdvertise, "hub-advertise", false, "Advertise this node on the local network over mDNS so hubs started with --mdns discover it")
 	serveCmd.Flags().StringVar(&serveHubRegistrationToken, "hub-registration-token", "", "Hub registration token for --hub-register (defaults to $TERM_LLM_HUB_REGISTRATION_TOKEN)")
+	serveCmd.Flags().BoolVar(&serveMetricsEnabled, "metrics", false, "Expose Prometheus metrics at {base}/metrics (behind auth)")
+	serveCmd.Flags().StringVar(&serveKeysDB, "keys-db", "", "Named API key store (default: <data-dir>/serve_keys.db; see 'serve keys')")
+	serveCmd.Flags().StringVar(&serveWebhooksDB, "webhooks-db", "", "Outbound webhook delivery log (default: <data-dir>/serve_webhooks.db; see 'serve webhooks')")
 
 	AddCommonFlags(serveCmd,
 		CommonCoreFlags|CommonSearch|CommonNativeSearch|CommonMaxTurns|CommonAgent,
@@ -203,6 +243,10 @@ func servePlatformCompletion(cmd *cobra.Command, args []string, toComplete strin
 		"api\tHTTP server with API endpoints only (no UI)",
 		"jobs\tAsync job runner with HTTP management API",
 		"telegram\tTelegram bot",
+		"slack\tSlack bot",
+		"discord\tDiscord bot",
+		"matrix\tMatrix bot",
+		"email\tEmail (IMAP/LMTP + SMTP)",
 	}
 
 	// Filter out already-selected platforms
@@ -247,8 +291,8 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 	if serveSessionMax <= 0 {
 		return fmt.Errorf("invalid --session-max %d (must be > 0)", serveSessionMax)
 	}
-	if serveTelegramCarryoverChars < 0 {
-		return fmt.Errorf("invalid --telegram-carryover-chars %d (must be >= 0)", serveTelegramCarryoverChars)
+	if serveCarryoverChars < 0 {
+		return fmt.Errorf("invalid --telegram-carryover-chars %d (must be >= 0)", serveCarryoverChars)
 	}
 	if serveJobsWorkers <= 0 {
 		return fmt.Errorf("invalid --jobs-workers %d (must be > 0)", serveJobsWorkers)
@@ -323,6 +367,10 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 	hasWeb := platformContains(platformNames, "web")
 	hasAPI := platformContains(platformNames, "api")
 	hasTelegram := platformContains(platformNames, "telegram")
+	hasSlack := platformContains(platformNames, "slack")
+	hasDiscord := platformContains(platformNames, "discord")
+	hasMatrix := platformContains(platformNames, "matrix")
+	hasEmail := platformContains(platformNames, "email")
 
 	// Auto-generate VAPID keys for web push if not already configured.
 	if hasWeb && (cfg.Serve.WebPush.VAPIDPublicKey == "" || cfg.Serve.WebPush.VAPIDPrivateKey == "") {
@@ -366,7 +414,7 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 	}
 
 	var agent *agents.Agent
-	if hasWeb || hasAPI || hasTelegram {
+	if hasWeb || hasAPI || hasTelegram || hasSlack || hasDiscord || hasMatrix || hasEmail {
 		agent, err = LoadAgent(serveAgent, cfg)
 		if err != nil {
 			return err
@@ -452,6 +500,22 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 	if serveDebug || serveVerbose {
 		approvalErrWriter = cmd.ErrOrStderr()
 	}
+	var metrics *serveMetrics
+	if serveMetricsEnabled && (hasWeb || hasAPI || hasJobs) {
+		metrics = newServeMetrics()
+		llm.SetStreamObserver(metrics)
+		defer llm.SetStreamObserver(nil)
+	}
+	var webhookDispatcher *webhooks.Dispatcher
+	if hasWeb || hasAPI || hasJobs {
+		dispatcher, stopWebhooks, err := startServeWebhooks(ctx, cfg, serveWebhooksDB)
+		if err != nil {
+			return err
+		}
+		defer stopWebhooks()
+		webhookDispatcher = dispatcher
+	}
+
 	runtimeFactory := func(ctx context.Context, providerName string, providerModel string) (*serveRuntime, error) {
 		runner := &cmdRunner{baseCfg: cfg, defaults: cmdRunnerOptions{
 			Provider:            serveProvider,
@@ -510,7 +574,10 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 		}
 
 		if runtime.toolMgr != nil {
-			runtime.toolMgr.ApprovalMgr.GuardianEventFunc = runtime.emitGuardianReview
+			runtime.toolMgr.ApprovalMgr.GuardianEventFunc = func(event tools.GuardianEvent) {
+				metrics.observeGuardian(event)
+				runtime.emitGuardianReview(event)
+			}
 			imageBaseURL := ""
 			if hasWeb {
 				imageBaseURL = strings.TrimRight(serveBasePath, "/") + "/images/"
@@ -520,13 +587,24 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 				runtime.toolMgr.ApprovalMgr.IgnoreProjectApprovals = true
 				runtime.toolMgr.ApprovalMgr.DebugApproval = serveDebug
 				runtime.toolMgr.ApprovalMgr.PromptUIFunc = func(path string, isWrite bool, isShell bool, workDir string) (tools.ApprovalResult, error) {
-					return runtime.awaitApproval(path, isWrite, isShell, workDir)
+					result, err := runtime.awaitApproval(path, isWrite, isShell, workDir)
+					kind := "file"
+					if isShell {
+						kind = "shell"
+					}
+					metrics.observeApproval(kind, !result.Cancelled, err)
+					return result, err
 				}
 			}
 			if hasWeb {
-				runtime.toolMgr.ApprovalMgr.WorkspacePromptFunc = runtime.awaitWorkspaceApproval
+				runtime.toolMgr.ApprovalMgr.WorkspacePromptFunc = func(workspace string) (tools.WorkspaceApprovalResult, error) {
+					result, err := runtime.awaitWorkspaceApproval(workspace)
+					metrics.observeApproval("workspace", result.Approved, err)
+					return result, err
+				}
 			}
 		}
+		runtime.webhooks = webhookDispatcher
 		runtime.Touch()
 		return runtime, nil
 	}
@@ -547,19 +625,19 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 
 	// Build the serve.Settings used by non-web platforms.
 	serveSettings := serve.Settings{
-		SystemPrompt:           settings.SystemPrompt,
-		IdleTimeout:            serveSessionTTL,
-		TelegramCarryoverChars: serveTelegramCarryoverChars,
-		MaxTurns:               settings.MaxTurns,
-		Debug:                  serveDebug,
-		DebugRaw:               debugRaw,
-		Search:                 settings.Search,
-		ForceExternalSearch:    forceExternalSearch,
-		Tools:                  settings.Tools,
-		MCP:                    settings.MCP,
-		Agent:                  agentName,
-		PlatformMessages:       agentPlatformMsgs,
-		Store:                  store,
+		SystemPrompt:        settings.SystemPrompt,
+		IdleTimeout:         serveSessionTTL,
+		CarryoverChars:      serveCarryoverChars,
+		MaxTurns:            settings.MaxTurns,
+		Debug:               serveDebug,
+		DebugRaw:            debugRaw,
+		Search:              settings.Search,
+		ForceExternalSearch: forceExternalSearch,
+		Tools:               settings.Tools,
+		MCP:                 settings.MCP,
+		Agent:               agentName,
+		PlatformMessages:    agentPlatformMsgs,
+		Store:               store,
 		Runner: newCmdRunner(cfg, cmdRunnerOptions{
 			Provider:            serveProvider,
 			Tools:               serveTools,
@@ -589,13 +667,28 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 			if err != nil {
 				return nil, err
 			}
-			return &serve.SessionRuntime{
+			sessionRuntime := &serve.SessionRuntime{
 				Engine:       rt.engine,
 				Provider:     rt.provider,
 				ProviderName: rt.provider.Name(),
 				ModelName:    rt.defaultModel,
 				Cleanup:      rt.Close,
-			}, nil
+			}
+			if rt.toolMgr != nil && !resolvedYolo {
+				sessionRuntime.SetApprovalHandler = func(handler serve.ApprovalHandler) {
+					prompt := platformApprovalPrompt(handler)
+					rt.toolMgr.ApprovalMgr.PromptUIFunc = func(path string, isWrite bool, isShell bool, workDir string) (tools.ApprovalResult, error) {
+						result, err := prompt(path, isWrite, isShell, workDir)
+						kind := "file"
+						if isShell {
+							kind = "shell"
+						}
+						metrics.observeApproval(kind, !result.Cancelled, err)
+						return result, err
+					}
+				}
+			}
+			return sessionRuntime, nil
 		},
 	}
 
@@ -611,6 +704,14 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 			// Handled by the HTTP serveServer below.
 		case "telegram":
 			platforms = append(platforms, serve.NewTelegramPlatform(cfg.Serve.Telegram))
+		case "slack":
+			platforms = append(platforms, serve.NewSlackPlatform(cfg.Serve.Slack))
+		case "discord":
+			platforms = append(platforms, serve.NewDiscordPlatform(cfg.Serve.Discord))
+		case "matrix":
+			platforms = append(platforms, serve.NewMatrixPlatform(cfg.Serve.Matrix))
+		case "email":
+			platforms = append(platforms, serve.NewEmailPlatform(cfg.Serve.Email))
 		default:
 			return fmt.Errorf("unknown platform: %s", name)
 		}
@@ -626,6 +727,9 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 	}
 
 	hasHTTP := hasWeb || hasAPI || hasJobs
+	if serveHubAdvertise && !hasHTTP {
+		return fmt.Errorf("--hub-advertise requires the web, api, or jobs platform")
+	}
 
 	var s *serveServer
 	registeredHubURL := ""
@@ -659,6 +763,7 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 				locationSharingDisabled: locationSharingDisabled,
 				sidebarSessions:         append([]string(nil), sidebarSessions...),
 				agentName:               agentName,
+				approvalMode:            serveApproval,
 				corsOrigins:             append([]string(nil), serveCORSOrigins...),
 				filesDir:                resolveFilesDir(serveFilesDir, cfg),
 				writeDirs:               resolveServeWriteDirs(serveWriteDirs, cfg),
@@ -677,13 +782,37 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 			skillsConfig:   effectiveSkillsConfig,
 			runtimeFactory: runtimeFactory,
 			widgetsMgr:     widgetsMgr,
+			metrics:        metrics,
+			webhooks:       webhookDispatcher,
+		}
+		if requireAuth {
+			keyStore, err := openServeKeyStore(serveKeysDB)
+			if err != nil {
+				return err
+			}
+			if keyStore != nil {
+				s.apiKeys = newServeAPIKeys(keyStore)
+			}
 		}
 		if hasJobs {
-			jobsV2, err = newServeJobsV2Manager(cfg, serveJobsWorkers, resolvedApproval, s.notifyJobsV2RunDone)
+			batchRunner, err := newServeBatchRunner(cfg, s.apiKeys)
+			if err != nil {
+				return fmt.Errorf("initialize batch runner: %w", err)
+			}
+			jobsV2, err = newServeJobsV2Manager(cfg, serveJobsWorkers, resolvedApproval, s.notifyJobsV2RunDone, batchRunner, s.apiKeys)
 			if err != nil {
 				return fmt.Errorf("initialize jobs v2 manager: %w", err)
 			}
+			if err := jobsV2.SetWorkerLabels(serveJobsWorkerLabels); err != nil {
+				_ = jobsV2.Close()
+				return fmt.Errorf("invalid --jobs-worker-label: %w", err)
+			}
 			s.jobsV2 = jobsV2
+			s.batchFiles = batchRunner.files
+		}
+		metrics.registerGauges(s)
+		if s.webhooks != nil && s.jobsV2 != nil {
+			s.jobsV2.AddRunObserver(s.observeJobRunWebhook)
 		}
 		sessionMgr.onEvict = func(rt *serveRuntime) {
 			for _, rid := range rt.getResponseIDs() {
@@ -697,17 +826,31 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 			runWebRTCPeer(ctx, s)
 		}
 
-		reverseHubURL := strings.TrimSpace(serveHubURL)
-		reverseHubNodeID := strings.TrimSpace(serveHubNodeID)
+		hubURL := strings.TrimSpace(serveHubURL)
+		hubNodeID := strings.TrimSpace(serveHubNodeID)
+		if serveHubAdvertise {
+			if serveHubConnect == "reverse" {
+				return fmt.Errorf("--hub-advertise cannot be combined with --hub-connect reverse (advertised nodes are dialed directly)")
+			}
+			if isLoopbackHost(serveHost) {
+				return fmt.Errorf("--hub-advertise requires a --host reachable from the network (got %q)", serveHost)
+			}
+			if hubNodeID == "" {
+				hostname, _ := os.Hostname()
+				if hubNodeID, err = hub.SlugID(hostname); err != nil {
+					return fmt.Errorf("--hub-advertise: set --hub-node-id: %w", err)
+				}
+			}
+		}
 		if serveHubRegister {
-			if serveHubConnect != "reverse" {
-				return fmt.Errorf("--hub-register requires --hub-connect reverse")
+			if serveHubConnect != "reverse" && !serveHubAdvertise {
+				return fmt.Errorf("--hub-register requires --hub-connect reverse or --hub-advertise")
 			}
-			if reverseHubURL == "" || reverseHubNodeID == "" || token == "" || hubRegistrationToken == "" {
+			if hubURL == "" || hubNodeID == "" || token == "" || hubRegistrationToken == "" {
 				return fmt.Errorf("--hub-register requires --hub-url, --hub-node-id, --hub-registration-token, and a bearer --token")
 			}
 		}
-		if serveHubConnect == "reverse" && (reverseHubURL == "" || reverseHubNodeID == "" || token == "") {
+		if serveHubConnect == "reverse" && (hubURL == "" || hubNodeID == "" || token == "") {
 			return fmt.Errorf("--hub-connect reverse requires --hub-url, --hub-node-id, and a bearer --token")
 		}
 
@@ -715,11 +858,32 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 			return err
 		}
 
+		if serveHubAdvertise {
+			svc := hub.MDNSService{
+				ID:           hubNodeID,
+				Name:         strings.TrimSpace(serveHubNodeName),
+				BasePath:     serveBasePath,
+				Port:         servePort,
+				Capabilities: s.capabilityList(),
+				IPs:          hubAdvertiseIPs(serveHost),
+			}
+			go func() {
+				if err := hub.AdvertiseMDNS(ctx, svc); err != nil {
+					log.Printf("hub advertise: %v", err)
+				}
+			}()
+			fmt.Fprintf(cmd.ErrOrStderr(), "hub advertise: advertising %s over mDNS (%s)\n", hubNodeID, hub.MDNSServiceType)
+		}
+
 		if serveHubRegister {
-			if err := registerServeHubNode(ctx, nil, reverseHubURL, hubRegistrationToken, hubRegisterNodeRequest{
-				ID:         reverseHubNodeID,
+			register := registerServeHubNode
+			if serveHubAdvertise {
+				register = registerAdvertisedServeHubNode
+			}
+			if err := register(ctx, nil, hubURL, hubRegistrationToken, hubRegisterNodeRequest{
+				ID:         hubNodeID,
 				Name:       strings.TrimSpace(serveHubNodeName),
-				Connection: "reverse",
+				Connection: serveHubConnect,
 				BasePath:   serveBasePath,
 				Token:      token,
 			}); err != nil {
@@ -728,15 +892,15 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 				cancel()
 				return err
 			}
-			registeredHubURL = reverseHubURL
-			registeredHubNodeID = reverseHubNodeID
-			fmt.Fprintf(cmd.ErrOrStderr(), "hub registration: registered %s with %s\n", reverseHubNodeID, reverseHubURL)
+			registeredHubURL = hubURL
+			registeredHubNodeID = hubNodeID
+			fmt.Fprintf(cmd.ErrOrStderr(), "hub registration: registered %s with %s\n", hubNodeID, hubURL)
 		}
 
 		if serveHubConnect == "reverse" {
 			localBase := localHubConnectBase(serveHost, servePort)
-			go runHubReverseConnector(ctx, reverseHubURL, reverseHubNodeID, token, localBase, serveBasePath, newHubReverseLocalClient())
-			fmt.Fprintf(cmd.ErrOrStderr(), "hub reverse: connecting %s to %s\n", reverseHubNodeID, reverseHubURL)
+			go runHubReverseConnector(ctx, hubURL, hubNodeID, token, localBase, serveBasePath, newHubReverseLocalClient())
+			fmt.Fprintf(cmd.ErrOrStderr(), "hub reverse: connecting %s to %s\n", hubNodeID, hubURL)
 		}
 
 		fmt.Fprintf(cmd.ErrOrStderr(), "term-llm serve listening on http://%s:%d\n", serveHost, servePort)
@@ -755,6 +919,21 @@ func runServeLegacy(parentCtx context.Context, cmd *cobra.Command, args []string
 		if hasJobs {
 			fmt.Fprintf(cmd.ErrOrStderr(), "jobs workers: %d\n", serveJobsWorkers)
 		}
+		if s.webhooks != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "webhooks: %d endpoints\n", len(cfg.Serve.Webhooks))
+		}
+		if metrics != nil {
+			metricsBase := strings.TrimRight(s.cfg.basePath, "/")
+			if s.jobsV2 != nil && !s.cfg.ui && !s.cfg.api {
+				metricsBase = ""
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "metrics: %s/metrics\n", metricsBase)
+		}
+		if s.apiKeys != nil {
+			if keys, err := s.apiKeys.store.List(ctx, false); err == nil {
+				fmt.Fprintf(cmd.ErrOrStderr(), "api keys: %d active\n", len(keys))
+			}
+		}
 		if modelName != "" {
 			fmt.Fprintf(cmd.ErrOrStderr(), "model: %s\n", modelName)
 		}
@@ -898,7 +1077,7 @@ func singleServeTemplatePlatform(platforms []string) string {
 	unique := make(map[string]struct{})
 	for _, p := range platforms {
 		switch p {
-		case "web", "api", "telegram", "jobs":
+		case "web", "api", "telegram", "slack", "discord", "matrix", "email", "jobs":
 			unique[p] = struct{}{}
 		}
 	}
@@ -1041,6 +1220,7 @@ type serveServerConfig struct {
 	locationSharingDisabled bool
 	sidebarSessions         []string
 	agentName               string
+	approvalMode            string
 	corsOrigins             []string
 	filesDir                string   // opt-in directory for serving arbitrary files (videos, PDFs, etc)
 	writeDirs               []string // tool write-dirs (CLI + config); tool-reported files inside these are trusted sources for ensureFileServeable
@@ -1157,6 +1337,7 @@ type serveServer struct {
 	cfg                      serveServerConfig
 	sessionMgr               *serveSessionManager
 	jobsV2                   *jobsV2Manager
+	batchFiles               *batch.FileStore
 	cfgRef                   *config.Config
 	store                    session.Store
 	server                   *http.Server
@@ -1192,6 +1373,9 @@ type serveServer struct {
 	titleProviderFactory     func(*config.Config) (llm.Provider, error)
 	pathNotesProviderFactory func(providerName, model string) (llm.Provider, error)
 	widgetsMgr               *widgets.Manager
+	apiKeys                  *serveAPIKeys        // nil when no key store exists
+	metrics                  *serveMetrics        // nil unless --metrics
+	webhooks                 *webhooks.Dispatcher // nil unless serve.webhooks is configured
 	indexHTMLOnce            sync.Once
 	cachedIndexHTML          []byte
 	worktreeRootOnce         sync.Once
@@ -1263,19 +1447,36 @@ func (s *serveServer) httpHandler() http.Handler {
 	inner := http.NewServeMux()
 
 	inner.HandleFunc("/healthz", s.handleHealth)
+	if s.metrics != nil {
+		inner.Handle("/metrics", s.auth(s.metrics.handler().ServeHTTP))
+	}
 	inner.HandleFunc("/v1/providers", s.auth(s.cors(s.handleProviders)))
 	inner.HandleFunc("/v1/models", s.auth(s.cors(s.handleModels)))
 	inner.HandleFunc("/v1/mentions/search", s.auth(s.cors(s.handleMentionSearch)))
 	inner.HandleFunc("/v1/responses", s.auth(s.cors(s.handleResponses)))
 	inner.HandleFunc("/v1/responses/", s.auth(s.cors(s.handleResponseByID)))
+	inner.HandleFunc("/v1/responses/input_tokens", s.auth(s.cors(s.handleResponsesInputTokens)))
 	inner.HandleFunc("/v1/chat/completions", s.auth(s.cors(s.handleChatCompletions)))
 	inner.HandleFunc("/v1/messages", s.auth(s.cors(s.handleAnthropicMessages)))
+	inner.HandleFunc("/v1/messages/count_tokens", s.auth(s.cors(s.handleAnthropicCountTokens)))
 	inner.HandleFunc("/v1/transcribe", s.auth(s.cors(s.handleTranscribe)))
+	inner.HandleFunc("/v1/embeddings", s.auth(s.cors(s.handleEmbeddings)))
+	inner.HandleFunc("/v1/images/generations", s.auth(s.cors(s.handleImagesGenerations)))
+	inner.HandleFunc("/v1/images/edits", s.auth(s.cors(s.handleImagesEdits)))
+	inner.HandleFunc("/v1/audio/speech", s.auth(s.cors(s.handleAudioSpeech)))
 	if s.jobsV2 != nil {
 		inner.HandleFunc("/v2/jobs", s.auth(s.cors(s.handleJobsV2)))
-		inner.HandleFunc("/v2/jobs/", s.auth(s.cors(s.handleJobV2ByID)))
+		inner.HandleFunc("/v2/jobs/", s.jobsV2WebhookAuth(s.auth(s.cors(s.handleJobV2ByID))))
 		inner.HandleFunc("/v2/runs", s.auth(s.cors(s.handleRunsV2)))
 		inner.HandleFunc("/v2/runs/", s.auth(s.cors(s.handleRunV2ByID)))
+		inner.HandleFunc("/v2/workers", s.auth(s.cors(s.handleWorkersV2)))
+		inner.HandleFunc("/v2/workers/", s.auth(s.cors(s.handleWorkersV2)))
+	}
+	if s.jobsV2 != nil && s.batchFiles != nil {
+		inner.HandleFunc("/v1/files", s.auth(s.cors(s.handleBatchFiles)))
+		inner.HandleFunc("/v1/files/", s.auth(s.cors(s.handleBatchFileByID)))
+		inner.HandleFunc("/v1/batches", s.auth(s.cors(s.handleBatches)))
+		inner.HandleFunc("/v1/batches/", s.auth(s.cors(s.handleBatchByID)))
 	}
 
 	inner.HandleFunc("/images/", s.auth(s.cors(s.handleImage)))
@@ -1307,7 +1508,7 @@ func (s *serveServer) httpHandler() http.Handler {
 	// the canonical /v2/* API paths. The shared base-path wrapper is still used
 	// for web/UI surfaces where the browser and API must live under one prefix.
 	if s.jobsV2 != nil && !s.cfg.ui && !s.cfg.api {
-		return inner
+		return tracing.Middleware(s.metrics.instrument(inner))
 	}
 
 	// Outer mux: mount everything under basePath.
@@ -1315,7 +1516,7 @@ func (s *serveServer) httpHandler() http.Handler {
 	// Go's ServeMux auto-redirects basePath (no slash) → basePath/.
 	prefix := s.cfg.basePath
 	mux := http.NewServeMux()
-	mux.Handle(prefix+"/", http.StripPrefix(prefix, inner))
+	mux.Handle(prefix+"/", http.StripPrefix(prefix, s.metrics.instrument(inner)))
 
 	if s.cfg.ui {
 		mux.HandleFunc("/", s.cors(func(w http.ResponseWriter, r *http.Request) {
@@ -1323,7 +1524,7 @@ func (s *serveServer) httpHandler() http.Handler {
 		}))
 	}
 
-	return mux
+	return tracing.Middleware(mux)
 }
 
 // contextWithShutdown returns a derived context that is cancelled when either
@@ -1413,6 +1614,9 @@ func (s *serveServer) Stop(ctx context.Context) error {
 			return err
 		}
 		closeFileTrackingStore()
+		if s.apiKeys != nil {
+			_ = s.apiKeys.store.Close()
+		}
 		close(errCh)
 		for err := range errCh {
 			if err != nil {
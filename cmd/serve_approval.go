@@ -6,6 +6,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/samsaffron/term-llm/internal/serve"
 	"github.com/samsaffron/term-llm/internal/tools"
 )
 
@@ -65,18 +66,6 @@ func (p *servePendingApproval) snapshot() serveApprovalPrompt {
 		}
 	}
 
-	title := "Access Request"
-	switch {
-	case p.IsWorkspace:
-		title = "Allow workspace access?"
-	case p.IsShell:
-		title = "Shell Command Request"
-	case p.IsWrite:
-		title = "Write Access Request"
-	default:
-		title = "Read Access Request"
-	}
-
 	return serveApprovalPrompt{
 		ApprovalID:          p.ApprovalID,
 		Path:                p.Path,
@@ -84,13 +73,82 @@ func (p *servePendingApproval) snapshot() serveApprovalPrompt {
 		IsShell:             p.IsShell,
 		IsWorkspace:         p.IsWorkspace,
 		WorkDir:             p.WorkDir,
-		Title:               title,
+		Title:               serveApprovalTitle(p.IsWorkspace, p.IsShell, p.IsWrite),
 		Options:             options,
 		ResumeAutoAvailable: p.ResumeAutoAvailable,
 		CreatedAt:           p.CreatedAt.UnixMilli(),
 	}
 }
 
+func serveApprovalTitle(isWorkspace, isShell, isWrite bool) string {
+	switch {
+	case isWorkspace:
+		return "Allow workspace access?"
+	case isShell:
+		return "Shell Command Request"
+	case isWrite:
+		return "Write Access Request"
+	default:
+		return "Read Access Request"
+	}
+}
+
+// serveApprovalOptions builds the choices offered for one approval prompt.
+func serveApprovalOptions(target string, isWrite, isShell, isWorkspace bool, workDir string) []tools.ApprovalOption {
+	if isWorkspace {
+		return tools.BuildWorkspaceOptions(target)
+	}
+	if isShell {
+		dir := workDir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		repoInfo := tools.DetectGitRepo(dir)
+		var repoInfoPtr *tools.GitRepoInfo
+		if repoInfo.IsRepo {
+			repoInfoPtr = &repoInfo
+		}
+		return tools.BuildShellOptions(target, repoInfoPtr)
+	}
+	repoInfo := tools.DetectGitRepo(target)
+	var repoInfoPtr *tools.GitRepoInfo
+	if repoInfo.IsRepo {
+		repoInfoPtr = &repoInfo
+	}
+	return tools.BuildFileOptions(target, repoInfoPtr, isWrite)
+}
+
+// platformApprovalPrompt adapts a messaging platform's approval handler to
+// the approval manager's prompt callback. A dismissed prompt cancels the
+// tool call, as it does in the web UI.
+func platformApprovalPrompt(handler serve.ApprovalHandler) func(target string, isWrite bool, isShell bool, workDir string) (tools.ApprovalResult, error) {
+	return func(target string, isWrite bool, isShell bool, workDir string) (tools.ApprovalResult, error) {
+		cancelled := tools.ApprovalResult{Choice: tools.ApprovalChoiceCancelled, Cancelled: true}
+		options := serveApprovalOptions(target, isWrite, isShell, false, workDir)
+		choice, err := handler(serve.ApprovalRequest{
+			Title:   serveApprovalTitle(false, isShell, isWrite),
+			Target:  target,
+			WorkDir: workDir,
+			IsShell: isShell,
+			IsWrite: isWrite,
+			Options: options,
+		})
+		if err != nil {
+			return cancelled, err
+		}
+		if choice < 0 || choice >= len(options) {
+			return cancelled, nil
+		}
+		opt := options[choice]
+		return tools.ApprovalResult{
+			Choice:     opt.Choice,
+			Path:       opt.Path,
+			Pattern:    opt.Pattern,
+			SaveToRepo: opt.SaveToRepo,
+		}, nil
+	}
+}
+
 func (rt *serveRuntime) awaitApproval(target string, isWrite bool, isShell bool, workDir string) (tools.ApprovalResult, error) {
 	return rt.awaitApprovalRequest(target, isWrite, isShell, false, workDir)
 }
@@ -110,29 +168,7 @@ func (rt *serveRuntime) awaitWorkspaceApproval(workspace string) (tools.Workspac
 
 func (rt *serveRuntime) awaitApprovalRequest(target string, isWrite bool, isShell bool, isWorkspace bool, workDir string) (tools.ApprovalResult, error) {
 	approvalID := "appr_" + randomSuffix()
-
-	var options []tools.ApprovalOption
-	if isWorkspace {
-		options = tools.BuildWorkspaceOptions(target)
-	} else if isShell {
-		dir := workDir
-		if dir == "" {
-			dir, _ = os.Getwd()
-		}
-		repoInfo := tools.DetectGitRepo(dir)
-		var repoInfoPtr *tools.GitRepoInfo
-		if repoInfo.IsRepo {
-			repoInfoPtr = &repoInfo
-		}
-		options = tools.BuildShellOptions(target, repoInfoPtr)
-	} else {
-		repoInfo := tools.DetectGitRepo(target)
-		var repoInfoPtr *tools.GitRepoInfo
-		if repoInfo.IsRepo {
-			repoInfoPtr = &repoInfo
-		}
-		options = tools.BuildFileOptions(target, repoInfoPtr, isWrite)
-	}
+	options := serveApprovalOptions(target, isWrite, isShell, isWorkspace, workDir)
 
 	rt.approvalMu.Lock()
 
@@ -0,0 +1,861 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/batch"
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/jobs"
+	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/usage"
+)
+
+const (
+	serveBatchDefaultConcurrency  = 4
+	serveBatchDefaultPollInterval = 30 * time.Second
+	serveBatchCompletionWindow    = 24 * time.Hour
+	serveBatchProgressInterval    = 5 * time.Second
+	serveBatchCancelTimeout       = 30 * time.Second
+)
+
+// serveBatchRetryPolicy lets a batch interrupted by a crash resume from its
+// checkpoint instead of failing outright.
+var serveBatchRetryPolicy = json.RawMessage(`{"max_attempts":3,"backoff":"fixed","initial_delay":"10s"}`)
+
+// jobsV2BatchRunner runs batch jobs created through POST /v1/batches. Lines
+// go to a provider's native batch API where one is available; the rest run
+// as one model call each, bounded per provider across all batches.
+type jobsV2BatchRunner struct {
+	cfg   *config.Config
+	files *batch.FileStore
+	// recordUsage returns the quota recorder for a serve API key, or nil.
+	recordUsage func(keyID string) func(usage.LogEntry)
+	// call, backend and logUsage are replaced in tests.
+	call         func(ctx context.Context, provider llm.Provider, endpoint string, req llm.Request) (json.RawMessage, error)
+	backend      func(cfg *config.Config, target serveBatchTarget) (batch.Backend, string)
+	logUsage     func(usage.LogEntry)
+	pollInterval time.Duration
+
+	mu     sync.Mutex
+	limits map[string]chan struct{}
+}
+
+func newJobsV2BatchRunner(cfg *config.Config, files *batch.FileStore) *jobsV2BatchRunner {
+	poll := serveBatchDefaultPollInterval
+	if d, err := time.ParseDuration(strings.TrimSpace(cfg.Serve.Batch.PollInterval)); err == nil && d > 0 {
+		poll = d
+	}
+	return &jobsV2BatchRunner{
+		cfg:          cfg,
+		files:        files,
+		call:         serveBatchCall,
+		backend:      serveBatchNativeBackend,
+		logUsage:     func(entry usage.LogEntry) { _ = usage.DefaultLogger().Log(entry) },
+		pollInterval: poll,
+		limits:       make(map[string]chan struct{}),
+	}
+}
+
+// resumesAfterShutdown reports that an interrupted batch run can simply be
+// queued again: finished lines are checkpointed and native batches keep
+// running at the provider.
+func (r *jobsV2BatchRunner) resumesAfterShutdown() bool { return true }
+
+// limit returns the semaphore bounding local calls to provider.
+func (r *jobsV2BatchRunner) limit(provider string) chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if sem, ok := r.limits[provider]; ok {
+		return sem
+	}
+	n := serveBatchDefaultConcurrency
+	if v := r.cfg.Serve.Batch.Concurrency[provider]; v > 0 {
+		n = v
+	} else if v := r.cfg.Serve.Batch.Concurrency["default"]; v > 0 {
+		n = v
+	}
+	sem := make(chan struct{}, n)
+	r.limits[provider] = sem
+	return sem
+}
+
+func (r *jobsV2BatchRunner) Run(ctx context.Context, job jobsV2Job, pw progressWriter) (jobsV2RunResult, error) {
+	if r.files == nil {
+		return jobsV2RunResult{}, fmt.Errorf("batch runner is not configured")
+	}
+	var bc jobs.BatchConfig
+	if err := json.Unmarshal(job.RunnerConfig, &bc); err != nil {
+		return jobsV2RunResult{}, fmt.Errorf("invalid batch runner config: %w", err)
+	}
+	_, fh, err := r.files.Open(bc.InputFileID)
+	if err != nil {
+		return jobsV2RunResult{}, fmt.Errorf("open input file %s: %w", bc.InputFileID, err)
+	}
+	reqs, err := batch.ParseRequests(fh, bc.Endpoint)
+	fh.Close()
+	if err != nil {
+		return jobsV2RunResult{}, fmt.Errorf("read input file %s: %w", bc.InputFileID, err)
+	}
+	dir, err := r.files.WorkDir(job.ID)
+	if err != nil {
+		return jobsV2RunResult{}, fmt.Errorf("create batch work dir: %w", err)
+	}
+	b, err := openServeBatchRun(r, job, bc, reqs, dir, pw)
+	if err != nil {
+		return jobsV2RunResult{}, err
+	}
+	defer b.close()
+
+	if bc.APIKeyName != "" {
+		attribution := usage.Attribution{APIKey: bc.APIKeyName}
+		if r.recordUsage != nil && bc.APIKeyID != "" {
+			attribution.Record = r.recordUsage(bc.APIKeyID)
+		}
+		ctx = usage.WithAttribution(ctx, attribution)
+	}
+	// The completion window runs from when the batch was created, so a
+	// resumed run keeps the original deadline.
+	created := job.CreatedAt
+	if created.IsZero() {
+		created = time.Now()
+	}
+	workCtx, cancel := context.WithDeadline(ctx, created.Add(serveBatchCompletionWindow))
+	defer cancel()
+	b.run(workCtx)
+
+	if ctx.Err() != nil && jobsV2ShuttingDown(ctx) {
+		return b.result(b.summary()), ctx.Err()
+	}
+	return b.finish(errors.Is(workCtx.Err(), context.DeadlineExceeded))
+}
+
+// serveBatchTarget is the provider and model a request line resolves to.
+type serveBatchTarget struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
+}
+
+// resolveServeBatchTarget reads a line's model, which is either
+// "provider:model" or a model of the default provider.
+func resolveServeBatchTarget(cfg *config.Config, body json.RawMessage) (serveBatchTarget, error) {
+	var head struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(body, &head); err != nil {
+		return serveBatchTarget{}, fmt.Errorf("invalid body: %w", err)
+	}
+	model := strings.TrimSpace(head.Model)
+	if strings.Contains(model, ":") {
+		provider, name, err := llm.ParseProviderModel(model, cfg)
+		if err != nil {
+			return serveBatchTarget{}, err
+		}
+		return serveBatchTarget{Provider: provider, Model: name}, nil
+	}
+	if strings.TrimSpace(cfg.DefaultProvider) == "" {
+		return serveBatchTarget{}, fmt.Errorf("model must be given as provider:model when no default provider is configured")
+	}
+	return serveBatchTarget{Provider: cfg.DefaultProvider, Model: model}, nil
+}
+
+// serveBatchLLMRequest converts a request line body into a single model
+// turn. Server tools and sessions are not available to batch lines; client
+// tools are returned as tool calls, as with any API request.
+func serveBatchLLMRequest(endpoint string, body json.RawMessage) (llm.Request, error) {
+	req := llm.Request{MaxTurns: 1, Ephemeral: true, ParallelToolCalls: true}
+	var (
+		tools       []llm.ToolSpec
+		toolChoice  json.RawMessage
+		parallel    *bool
+		temperature *float32
+		topP        *float32
+	)
+	switch endpoint {
+	case batch.EndpointChatCompletions:
+		var chat chatCompletionsRequest
+		if err := json.Unmarshal(body, &chat); err != nil {
+			return llm.Request{}, fmt.Errorf("invalid body: %w", err)
+		}
+		if len(chat.Messages) == 0 {
+			return llm.Request{}, fmt.Errorf("messages is required")
+		}
+		messages, _, err := parseChatMessages(chat.Messages)
+		if err != nil {
+			return llm.Request{}, err
+		}
+		req.Messages = messages
+		req.MaxOutputTokens = chat.MaxTokens
+		tools = chatToolsToSpecs(chat.Tools)
+		toolChoice, parallel, temperature, topP = chat.ToolChoice, chat.ParallelToolCalls, chat.Temperature, chat.TopP
+	case batch.EndpointResponses:
+		var resp responsesCreateRequest
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return llm.Request{}, fmt.Errorf("invalid body: %w", err)
+		}
+		if resp.PreviousResponseID != "" {
+			return llm.Request{}, fmt.Errorf("previous_response_id is not supported in batches")
+		}
+		messages, _, err := parseResponsesInput(resp.Input)
+		if err != nil {
+			return llm.Request{}, err
+		}
+		if len(messages) == 0 {
+			return llm.Request{}, fmt.Errorf("input is required")
+		}
+		req.Messages = messages
+		req.MaxOutputTokens = resp.MaxOutputTokens
+		_, _, _, tools = parseRequestedTools(resp.Tools)
+		req.ReasoningEffort = normalizeReasoningEffort(resp.ReasoningEffort)
+		if resp.Reasoning != nil {
+			if effort := normalizeReasoningEffort(resp.Reasoning.Effort); effort != "" {
+				req.ReasoningEffort = effort
+			}
+		}
+		toolChoice, parallel, temperature, topP = resp.ToolChoice, resp.ParallelToolCalls, resp.Temperature, resp.TopP
+	default:
+		return llm.Request{}, fmt.Errorf("unsupported endpoint %q", endpoint)
+	}
+	if len(tools) > 0 {
+		req.Tools = tools
+		req.ToolChoice = parseToolChoice(toolChoice)
+	}
+	if parallel != nil {
+		req.ParallelToolCalls = *parallel
+	}
+	if temperature != nil {
+		req.Temperature = *temperature
+		req.TemperatureSet = true
+	}
+	if topP != nil {
+		req.TopP = *topP
+		req.TopPSet = true
+	}
+	return req, nil
+}
+
+// serveBatchCall runs one request line and returns the response body the
+// matching endpoint would have returned.
+func serveBatchCall(ctx context.Context, provider llm.Provider, endpoint string, req llm.Request) (json.RawMessage, error) {
+	stream, err := llm.NewEngine(provider, nil).Stream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	var result serveRunResult
+	collected, err := llm.CollectTextStream(stream, func(event llm.Event) error {
+		if event.Type == llm.EventToolCall && event.Tool != nil {
+			result.ToolCalls = append(result.ToolCalls, *event.Tool)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Text.WriteString(collected.Text)
+	result.Usage = collected.Usage
+	result.SessionUsage = collected.Usage
+	model := firstNonEmpty(req.Model, provider.Name())
+	if endpoint == batch.EndpointResponses {
+		return json.Marshal(responsesFinalResponse(result, model, "resp_"+randomSuffix(), time.Now().Unix()))
+	}
+	return json.Marshal(chatCompletionFinalResponse(result, model))
+}
+
+// serveBatchNativeBackend returns the native batch API for target and the
+// model name to send it, or nil when lines for target must run locally.
+// Only API-key credentials work with batch APIs, and models carrying
+// term-llm suffixes (reasoning effort, -thinking, -1m) have no native name.
+func serveBatchNativeBackend(cfg *config.Config, target serveBatchTarget) (batch.Backend, string) {
+	pc, err := cfg.GetResolvedProviderConfig(target.Provider)
+	if err != nil || pc == nil {
+		return nil, ""
+	}
+	if err := pc.ResolveForInference(); err != nil || pc.ResolvedAPIKey == "" {
+		return nil, ""
+	}
+	model := firstNonEmpty(target.Model, pc.Model)
+	if model == "" {
+		return nil, ""
+	}
+	switch config.InferProviderType(target.Provider, pc.Type) {
+	case config.ProviderTypeOpenAI:
+		if base, effort := llm.BaseModelAndEffortForProvider("openai", model); effort != "" || base != model {
+			return nil, ""
+		}
+		return &batch.OpenAI{APIKey: pc.ResolvedAPIKey}, model
+	case config.ProviderTypeAnthropic:
+		switch pc.Credentials {
+		case "", llm.AnthropicCredAuto, llm.AnthropicCredAPIKey, llm.AnthropicCredEnv:
+		default:
+			return nil, ""
+		}
+		if base, effort := llm.BaseModelAndEffortForProvider("anthropic", model); effort != "" || base != model {
+			return nil, ""
+		}
+		if strings.HasSuffix(model, "-thinking") || strings.HasSuffix(model, "-1m") {
+			return nil, ""
+		}
+		return &batch.Anthropic{APIKey: pc.ResolvedAPIKey, BaseURL: pc.BaseURL}, model
+	}
+	return nil, ""
+}
+
+// serveBatchWithModel returns body with its model replaced.
+func serveBatchWithModel(body json.RawMessage, model string) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	name, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	fields["model"] = name
+	return json.Marshal(fields)
+}
+
+// serveBatchRemote is a group of lines submitted to a provider batch API.
+type serveBatchRemote struct {
+	serveBatchTarget
+	Backend   string       `json:"backend"`
+	ID        string       `json:"id"`
+	Status    string       `json:"status,omitempty"`
+	Counts    batch.Counts `json:"request_counts"`
+	CustomIDs []string     `json:"custom_ids,omitempty"`
+}
+
+// serveBatchSummary is a batch run's progress, stored as the run response.
+type serveBatchSummary struct {
+	RequestCounts batch.Counts       `json:"request_counts"`
+	Usage         batch.Usage        `json:"usage"`
+	OutputFileID  string             `json:"output_file_id,omitempty"`
+	ErrorFileID   string             `json:"error_file_id,omitempty"`
+	Expired       bool               `json:"expired,omitempty"`
+	Native        []serveBatchRemote `json:"native,omitempty"`
+}
+
+type serveBatchItem struct {
+	req    batch.Request
+	target serveBatchTarget
+}
+
+// serveBatchRun is one run of a batch job. Finished lines are appended to
+// results.jsonl and submitted native batches recorded in native.json in the
+// work dir, so a later run of the same job picks up where this one stopped.
+type serveBatchRun struct {
+	runner    *jobsV2BatchRunner
+	job       jobsV2Job
+	bc        jobs.BatchConfig
+	conf      *config.Config
+	reqs      []batch.Request
+	dir       string
+	pw        progressWriter
+	resolveMu sync.Mutex
+
+	mu           sync.Mutex
+	providers    map[serveBatchTarget]llm.Provider
+	results      map[string]batch.Result
+	log          *os.File
+	native       []*serveBatchRemote
+	lastProgress time.Time
+}
+
+func openServeBatchRun(r *jobsV2BatchRunner, job jobsV2Job, bc jobs.BatchConfig, reqs []batch.Request, dir string, pw progressWriter) (*serveBatchRun, error) {
+	b := &serveBatchRun{
+		runner:    r,
+		job:       job,
+		bc:        bc,
+		conf:      cloneConfigForServeJob(r.cfg),
+		reqs:      reqs,
+		dir:       dir,
+		pw:        pw,
+		providers: make(map[serveBatchTarget]llm.Provider),
+		results:   make(map[string]batch.Result),
+	}
+	wanted := make(map[string]bool, len(reqs))
+	for _, req := range reqs {
+		wanted[req.CustomID] = true
+	}
+	logPath := filepath.Join(dir, "results.jsonl")
+	if f, err := os.Open(logPath); err == nil {
+		prior, err := batch.ReadResults(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read batch checkpoint: %w", err)
+		}
+		for _, res := range prior {
+			if wanted[res.CustomID] {
+				b.results[res.CustomID] = res
+			}
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "native.json")); err == nil {
+		if err := json.Unmarshal(data, &b.native); err != nil {
+			return nil, fmt.Errorf("read batch checkpoint: %w", err)
+		}
+	}
+
+	// Rewrite the log before appending so a line torn by a crash does not
+	// swallow the next one.
+	tmp := logPath + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("write batch checkpoint: %w", err)
+	}
+	for _, req := range reqs {
+		if res, ok := b.results[req.CustomID]; ok {
+			if err := batch.WriteResult(f, res); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("write batch checkpoint: %w", err)
+			}
+		}
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("write batch checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, logPath); err != nil {
+		return nil, fmt.Errorf("write batch checkpoint: %w", err)
+	}
+	b.log, err = os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open batch checkpoint: %w", err)
+	}
+	return b, nil
+}
+
+func (b *serveBatchRun) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.log != nil {
+		_ = b.log.Close()
+		b.log = nil
+	}
+}
+
+// run sends every unfinished line on its way and waits until all of them
+// have a result or ctx is done.
+func (b *serveBatchRun) run(ctx context.Context) {
+	nativeEnabled := b.runner.cfg.Serve.Batch.Native == nil || *b.runner.cfg.Serve.Batch.Native
+	if b.bc.Native != nil && !*b.bc.Native {
+		nativeEnabled = false
+	}
+
+	type nativeGroup struct {
+		backend batch.Backend
+		model   string
+		items   []serveBatchItem
+	}
+	backends := make(map[serveBatchTarget]*nativeGroup)
+	nativeBackend := func(target serveBatchTarget) *nativeGroup {
+		group, ok := backends[target]
+		if !ok {
+			group = &nativeGroup{}
+			if nativeEnabled {
+				group.backend, group.model = b.runner.backend(b.conf, target)
+			}
+			backends[target] = group
+		}
+		return group
+	}
+
+	type resumedRemote struct {
+		backend batch.Backend
+		remote  *serveBatchRemote
+	}
+	var resumed []resumedRemote
+	submitted := make(map[string]bool)
+	for _, remote := range b.native {
+		if remote.Status == "done" {
+			continue
+		}
+		group := nativeBackend(remote.serveBatchTarget)
+		if group.backend == nil || group.backend.Name() != remote.Backend {
+			// The provider changed since submission; run the lines again.
+			continue
+		}
+		for _, id := range remote.CustomIDs {
+			submitted[id] = true
+		}
+		resumed = append(resumed, resumedRemote{backend: group.backend, remote: remote})
+	}
+
+	var groups []*nativeGroup
+	local := make(map[string][]serveBatchItem)
+	for _, req := range b.reqs {
+		if _, done := b.results[req.CustomID]; done || submitted[req.CustomID] {
+			continue
+		}
+		target, err := resolveServeBatchTarget(b.conf, req.Body)
+		if err != nil {
+			b.record(batch.ErrorResult(req.CustomID, batch.CodeInvalidRequest, err.Error()))
+			continue
+		}
+		item := serveBatchItem{req: req, target: target}
+		if group := nativeBackend(target); group.backend != nil {
+			if body, err := serveBatchWithModel(req.Body, group.model); err == nil {
+				nreq := req
+				nreq.Body = body
+				if group.backend.Supports(b.bc.Endpoint, nreq) {
+					if len(group.items) == 0 {
+						groups = append(groups, group)
+					}
+					group.items = append(group.items, serveBatchItem{req: nreq, target: target})
+					continue
+				}
+			}
+		}
+		local[target.Provider] = append(local[target.Provider], item)
+	}
+
+	// Nothing records results concurrently until the goroutines start.
+	var wg sync.WaitGroup
+	for _, r := range resumed {
+		wg.Add(1)
+		go func(r resumedRemote) {
+			defer wg.Done()
+			b.awaitNative(ctx, r.backend, r.remote)
+		}(r)
+	}
+	for _, group := range groups {
+		wg.Add(1)
+		go func(group *nativeGroup) {
+			defer wg.Done()
+			b.runNative(ctx, group.backend, group.items)
+		}(group)
+	}
+	for provider, items := range local {
+		wg.Add(1)
+		go func(provider string, items []serveBatchItem) {
+			defer wg.Done()
+			b.runLocal(ctx, provider, items)
+		}(provider, items)
+	}
+	wg.Wait()
+	b.progress(true)
+}
+
+// runLocal runs items one model call each, bounded by provider's limit.
+func (b *serveBatchRun) runLocal(ctx context.Context, provider string, items []serveBatchItem) {
+	sem := b.runner.limit(provider)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for _, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		wg.Add(1)
+		go func(item serveBatchItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if res, ok := b.callLocal(ctx, item); ok {
+				b.record(res)
+			}
+		}(item)
+	}
+}
+
+// callLocal runs one line. It reports false when ctx ended the call, which
+// leaves the line unfinished rather than failed.
+func (b *serveBatchRun) callLocal(ctx context.Context, item serveBatchItem) (batch.Result, bool) {
+	customID := item.req.CustomID
+	req, err := serveBatchLLMRequest(b.bc.Endpoint, item.req.Body)
+	if err != nil {
+		return batch.ErrorResult(customID, batch.CodeInvalidRequest, err.Error()), true
+	}
+	req.Model = item.target.Model
+	provider, err := b.provider(item.target)
+	if err != nil {
+		return batch.ErrorResult(customID, batch.CodeInvalidRequest, err.Error()), true
+	}
+	body, err := b.runner.call(ctx, provider, b.bc.Endpoint, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return batch.Result{}, false
+		}
+		return batch.ErrorResult(customID, batch.CodeProviderError, err.Error()), true
+	}
+	return batch.Result{
+		ID:       batch.NewID("batch_req_"),
+		CustomID: customID,
+		Response: &batch.Response{StatusCode: 200, RequestID: "req_" + randomSuffix(), Body: body},
+	}, true
+}
+
+// provider returns the shared provider for target. Creating providers
+// resolves credentials into the run's config, so it is serialized.
+func (b *serveBatchRun) provider(target serveBatchTarget) (llm.Provider, error) {
+	b.resolveMu.Lock()
+	defer b.resolveMu.Unlock()
+	if p, ok := b.providers[target]; ok {
+		return p, nil
+	}
+	p, err := llm.NewProviderByName(b.conf, target.Provider, target.Model)
+	if err != nil {
+		return nil, err
+	}
+	b.providers[target] = p
+	return p, nil
+}
+
+// runNative submits items as one provider batch and waits for it. If the
+// provider refuses the batch, the items run locally instead.
+func (b *serveBatchRun) runNative(ctx context.Context, backend batch.Backend, items []serveBatchItem) {
+	reqs := make([]batch.Request, len(items))
+	remote := &serveBatchRemote{serveBatchTarget: items[0].target, Backend: backend.Name(), CustomIDs: make([]string, len(items))}
+	for i, item := range items {
+		reqs[i] = item.req
+		remote.CustomIDs[i] = item.req.CustomID
+	}
+	id, err := backend.Submit(ctx, b.bc.Endpoint, reqs)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		b.pw("phase", fmt.Sprintf("%s batch API refused %d requests, running them one at a time: %v", backend.Name(), len(items), err), map[string]any{"backend": backend.Name(), "provider": remote.Provider})
+		b.runLocal(ctx, remote.Provider, items)
+		return
+	}
+	remote.ID = id
+	b.mu.Lock()
+	b.native = append(b.native, remote)
+	b.saveNativeLocked()
+	b.mu.Unlock()
+	b.pw("phase", fmt.Sprintf("submitted %d requests to the %s batch API as %s", len(items), backend.Name(), id), map[string]any{"backend": backend.Name(), "provider": remote.Provider, "remote_id": id})
+	b.awaitNative(ctx, backend, remote)
+}
+
+// awaitNative polls a provider batch until it ends, then records its
+// results. When the batch is cancelled (not when the server is shutting
+// down) the provider batch is cancelled too.
+func (b *serveBatchRun) awaitNative(ctx context.Context, backend batch.Backend, remote *serveBatchRemote) {
+	reqs := make([]batch.Request, len(remote.CustomIDs))
+	for i, id := range remote.CustomIDs {
+		reqs[i] = batch.Request{CustomID: id}
+	}
+	ticker := time.NewTicker(b.runner.pollInterval)
+	defer ticker.Stop()
+	for {
+		if done := b.pollNative(ctx, backend, remote, reqs); done {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			if !jobsV2ShuttingDown(ctx) {
+				cancelCtx, cancel := context.WithTimeout(context.Background(), serveBatchCancelTimeout)
+				if err := backend.Cancel(cancelCtx, remote.ID); err != nil {
+					log.Printf("batch %s: cancel %s batch %s: %v", b.job.ID, backend.Name(), remote.ID, err)
+				}
+				cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollNative checks a provider batch once and records its results if it
+// has ended. It reports whether the batch is finished with.
+func (b *serveBatchRun) pollNative(ctx context.Context, backend batch.Backend, remote *serveBatchRemote, reqs []batch.Request) bool {
+	st, err := backend.Poll(ctx, remote.ID)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("batch %s: poll %s batch %s: %v", b.job.ID, backend.Name(), remote.ID, err)
+		}
+		return false
+	}
+	b.mu.Lock()
+	changed := remote.Status != st.Status || remote.Counts != st.Counts
+	remote.Status, remote.Counts = st.Status, st.Counts
+	b.mu.Unlock()
+	if changed {
+		b.progress(true)
+	}
+	if !st.Done {
+		return false
+	}
+	results, err := backend.Results(ctx, remote.ID, reqs)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("batch %s: fetch results of %s batch %s: %v", b.job.ID, backend.Name(), remote.ID, err)
+		}
+		return false
+	}
+	seen := make(map[string]bool, len(results))
+	for _, res := range results {
+		seen[res.CustomID] = true
+		b.logNativeUsage(remote.Provider, res)
+		b.record(res)
+	}
+	msg := firstNonEmpty(st.Error, fmt.Sprintf("%s batch %s returned no result for this request", backend.Name(), remote.ID))
+	for _, id := range remote.CustomIDs {
+		if !seen[id] {
+			b.record(batch.ErrorResult(id, batch.CodeProviderError, msg))
+		}
+	}
+	b.mu.Lock()
+	remote.Status = "done"
+	b.saveNativeLocked()
+	b.mu.Unlock()
+	return true
+}
+
+// logNativeUsage logs a native result's tokens, which never pass through
+// an engine.
+func (b *serveBatchRun) logNativeUsage(provider string, res batch.Result) {
+	u := batch.ResultUsage(res)
+	if u.InputTokens == 0 && u.OutputTokens == 0 {
+		return
+	}
+	var body struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(res.Response.Body, &body)
+	entry := usage.LogEntry{
+		Timestamp:       time.Now(),
+		Model:           body.Model,
+		Provider:        provider,
+		InputTokens:     u.InputTokens - u.CachedInputTokens,
+		OutputTokens:    u.OutputTokens,
+		CacheReadTokens: u.CachedInputTokens,
+		APIKey:          b.bc.APIKeyName,
+	}
+	b.runner.logUsage(entry)
+	if b.runner.recordUsage != nil && b.bc.APIKeyID != "" {
+		if record := b.runner.recordUsage(b.bc.APIKeyID); record != nil {
+			record(entry)
+		}
+	}
+}
+
+// record stores a line's result, first one wins.
+func (b *serveBatchRun) record(res batch.Result) {
+	b.mu.Lock()
+	if _, ok := b.results[res.CustomID]; ok {
+		b.mu.Unlock()
+		return
+	}
+	b.results[res.CustomID] = res
+	if b.log != nil {
+		if err := batch.WriteResult(b.log, res); err != nil {
+			log.Printf("batch %s: write checkpoint: %v", b.job.ID, err)
+		}
+	}
+	b.mu.Unlock()
+	b.progress(false)
+}
+
+// saveNativeLocked writes native.json. b.mu must be held.
+func (b *serveBatchRun) saveNativeLocked() {
+	data, err := json.Marshal(b.native)
+	if err == nil {
+		path := filepath.Join(b.dir, "native.json")
+		if err = os.WriteFile(path+".tmp", data, 0o600); err == nil {
+			err = os.Rename(path+".tmp", path)
+		}
+	}
+	if err != nil {
+		log.Printf("batch %s: write checkpoint: %v", b.job.ID, err)
+	}
+}
+
+// progress publishes the summary, at most every serveBatchProgressInterval
+// unless force is set.
+func (b *serveBatchRun) progress(force bool) {
+	b.mu.Lock()
+	if !force && time.Since(b.lastProgress) < serveBatchProgressInterval {
+		b.mu.Unlock()
+		return
+	}
+	b.lastProgress = time.Now()
+	b.mu.Unlock()
+	summary := b.summary()
+	n := summary.RequestCounts
+	b.pw("progress_update", fmt.Sprintf("%d of %d requests finished (%d failed)", n.Completed+n.Failed, n.Total, n.Failed), summary)
+}
+
+func (b *serveBatchRun) summary() serveBatchSummary {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := serveBatchSummary{RequestCounts: batch.Counts{Total: len(b.reqs)}}
+	for _, res := range b.results {
+		if res.Failed() {
+			s.RequestCounts.Failed++
+		} else {
+			s.RequestCounts.Completed++
+		}
+		s.Usage.Add(batch.ResultUsage(res))
+	}
+	for _, remote := range b.native {
+		r := *remote
+		r.CustomIDs = nil
+		s.Native = append(s.Native, r)
+	}
+	return s
+}
+
+func (b *serveBatchRun) result(summary serveBatchSummary) jobsV2RunResult {
+	data, _ := json.Marshal(summary)
+	return jobsV2RunResult{
+		Response:     string(data),
+		InputTokens:  summary.Usage.InputTokens,
+		OutputTokens: summary.Usage.OutputTokens,
+	}
+}
+
+// finish writes the output file, with a line per request in input order,
+// and an error file holding only the failed lines. Lines that never ran
+// are failed as cancelled or expired.
+func (b *serveBatchRun) finish(expired bool) (jobsV2RunResult, error) {
+	code, msg := batch.CodeCancelled, "batch was cancelled before this request finished"
+	if expired {
+		code, msg = batch.CodeExpired, "batch expired before this request finished"
+	}
+	for _, req := range b.reqs {
+		b.record(batch.ErrorResult(req.CustomID, code, msg))
+	}
+	b.close()
+
+	var out, errs strings.Builder
+	failed := false
+	for _, req := range b.reqs {
+		res := b.results[req.CustomID]
+		if err := batch.WriteResult(&out, res); err != nil {
+			return jobsV2RunResult{}, err
+		}
+		if res.Failed() {
+			failed = true
+			if err := batch.WriteResult(&errs, res); err != nil {
+				return jobsV2RunResult{}, err
+			}
+		}
+	}
+	summary := b.summary()
+	summary.Expired = expired
+	output, err := b.runner.files.Create(b.job.ID+"_output.jsonl", batch.PurposeBatchOutput, b.bc.APIKeyID, strings.NewReader(out.String()))
+	if err != nil {
+		return b.result(summary), fmt.Errorf("write batch output: %w", err)
+	}
+	summary.OutputFileID = output.ID
+	if failed {
+		errFile, err := b.runner.files.Create(b.job.ID+"_errors.jsonl", batch.PurposeBatchOutput, b.bc.APIKeyID, strings.NewReader(errs.String()))
+		if err != nil {
+			return b.result(summary), fmt.Errorf("write batch errors: %w", err)
+		}
+		summary.ErrorFileID = errFile.ID
+	}
+	if err := b.runner.files.RemoveWorkDir(b.job.ID); err != nil {
+		log.Printf("batch %s: remove work dir: %v", b.job.ID, err)
+	}
+	return b.result(summary), nil
+}
@@ -0,0 +1,542 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/apikeys"
+	"github.com/samsaffron/term-llm/internal/batch"
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/jobs"
+	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/usage"
+)
+
+// fakeBatchBackend is a provider batch API that finishes on the first poll
+// with a result for every line except those listed in drop.
+type fakeBatchBackend struct {
+	mu        sync.Mutex
+	submitted [][]batch.Request
+	polled    []string
+	cancelled []string
+	done      bool
+	drop      map[string]bool
+}
+
+func (f *fakeBatchBackend) Name() string { return "fake" }
+
+func (f *fakeBatchBackend) Supports(endpoint string, req batch.Request) bool {
+	return !strings.Contains(string(req.Body), "local-only")
+}
+
+func (f *fakeBatchBackend) Submit(ctx context.Context, endpoint string, reqs []batch.Request) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.submitted = append(f.submitted, reqs)
+	return fmt.Sprintf("remote-%d", len(f.submitted)), nil
+}
+
+func (f *fakeBatchBackend) Poll(ctx context.Context, remoteID string) (batch.RemoteStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.polled = append(f.polled, remoteID)
+	return batch.RemoteStatus{Status: "ended", Done: f.done}, nil
+}
+
+func (f *fakeBatchBackend) Results(ctx context.Context, remoteID string, reqs []batch.Request) ([]batch.Result, error) {
+	var results []batch.Result
+	for _, req := range reqs {
+		if f.drop[req.CustomID] {
+			continue
+		}
+		results = append(results, batch.Result{
+			ID:       "native_" + req.CustomID,
+			CustomID: req.CustomID,
+			Response: &batch.Response{StatusCode: 200, Body: json.RawMessage(`{"model":"native-model","usage":{"prompt_tokens":10,"completion_tokens":2}}`)},
+		})
+	}
+	return results, nil
+}
+
+func (f *fakeBatchBackend) Cancel(ctx context.Context, remoteID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cancelled = append(f.cancelled, remoteID)
+	return nil
+}
+
+func newTestBatchRunner(t *testing.T) (*jobsV2BatchRunner, string) {
+	t.Helper()
+	root := t.TempDir()
+	files, err := batch.OpenFileStore(root)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	r := newJobsV2BatchRunner(&config.Config{DefaultProvider: "debug"}, files)
+	r.pollInterval = 10 * time.Millisecond
+	r.logUsage = func(usage.LogEntry) {}
+	r.backend = func(*config.Config, serveBatchTarget) (batch.Backend, string) { return nil, "" }
+	r.call = func(ctx context.Context, provider llm.Provider, endpoint string, req llm.Request) (json.RawMessage, error) {
+		text := req.Messages[len(req.Messages)-1].Parts[0].Text
+		if text == "fail" {
+			return nil, errors.New("upstream exploded")
+		}
+		return json.Marshal(map[string]any{
+			"model":   req.Model,
+			"choices": []any{map[string]any{"message": map[string]any{"content": "echo " + text}}},
+			"usage":   map[string]any{"prompt_tokens": 3, "completion_tokens": 1},
+		})
+	}
+	return r, root
+}
+
+func testBatchLine(customID, content string) string {
+	return fmt.Sprintf(`{"custom_id":%q,"method":"POST","url":"/v1/chat/completions","body":{"model":"debug:m","messages":[{"role":"user","content":%q}]}}`, customID, content)
+}
+
+func testBatchJob(t *testing.T, r *jobsV2BatchRunner, lines ...string) jobsV2Job {
+	t.Helper()
+	f, err := r.files.Create("input.jsonl", batch.PurposeBatch, "", strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatalf("Create input: %v", err)
+	}
+	raw, err := json.Marshal(jobs.BatchConfig{InputFileID: f.ID, Endpoint: batch.EndpointChatCompletions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jobsV2Job{ID: "job_" + randomSuffix(), RunnerType: jobsV2RunnerBatch, RunnerConfig: raw, CreatedAt: time.Now()}
+}
+
+func noopProgress(string, string, any) {}
+
+func readTestBatchOutput(t *testing.T, r *jobsV2BatchRunner, fileID string) []batch.Result {
+	t.Helper()
+	_, fh, err := r.files.Open(fileID)
+	if err != nil {
+		t.Fatalf("Open(%s): %v", fileID, err)
+	}
+	defer fh.Close()
+	results, err := batch.ReadResults(fh)
+	if err != nil {
+		t.Fatalf("ReadResults: %v", err)
+	}
+	return results
+}
+
+func decodeTestBatchSummary(t *testing.T, result jobsV2RunResult) serveBatchSummary {
+	t.Helper()
+	var s serveBatchSummary
+	if err := json.Unmarshal([]byte(result.Response), &s); err != nil {
+		t.Fatalf("decode summary %q: %v", result.Response, err)
+	}
+	return s
+}
+
+func TestJobsV2BatchRunner_WritesOutputAndErrorFiles(t *testing.T) {
+	r, root := newTestBatchRunner(t)
+	job := testBatchJob(t, r,
+		testBatchLine("a", "one"),
+		testBatchLine("b", "fail"),
+		`{"custom_id":"c","method":"POST","url":"/v1/chat/completions","body":{"model":"debug:m"}}`,
+		testBatchLine("d", "two"),
+	)
+
+	result, err := r.Run(context.Background(), job, noopProgress)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	summary := decodeTestBatchSummary(t, result)
+	if summary.RequestCounts != (batch.Counts{Total: 4, Completed: 2, Failed: 2}) {
+		t.Fatalf("counts = %+v", summary.RequestCounts)
+	}
+	if summary.Usage.InputTokens != 6 || result.InputTokens != 6 || result.OutputTokens != 2 {
+		t.Fatalf("usage = %+v, result = %+v", summary.Usage, result)
+	}
+
+	out := readTestBatchOutput(t, r, summary.OutputFileID)
+	var ids []string
+	for _, res := range out {
+		ids = append(ids, res.CustomID)
+	}
+	if strings.Join(ids, ",") != "a,b,c,d" {
+		t.Fatalf("output order = %v, want input order", ids)
+	}
+	if out[1].Error == nil || out[1].Error.Code != batch.CodeProviderError || !strings.Contains(out[1].Error.Message, "upstream exploded") {
+		t.Fatalf("failed call result = %+v", out[1])
+	}
+	if out[2].Error == nil || out[2].Error.Code != batch.CodeInvalidRequest {
+		t.Fatalf("invalid line result = %+v", out[2])
+	}
+	if !strings.Contains(string(out[3].Response.Body), "echo two") {
+		t.Fatalf("response body = %s", out[3].Response.Body)
+	}
+
+	errs := readTestBatchOutput(t, r, summary.ErrorFileID)
+	if len(errs) != 2 || errs[0].CustomID != "b" || errs[1].CustomID != "c" {
+		t.Fatalf("error file = %+v", errs)
+	}
+	if _, err := os.Stat(filepath.Join(root, "work", job.ID)); !os.IsNotExist(err) {
+		t.Fatalf("work dir not removed: %v", err)
+	}
+}
+
+func TestJobsV2BatchRunner_UsesNativeBackend(t *testing.T) {
+	r, _ := newTestBatchRunner(t)
+	fake := &fakeBatchBackend{done: true, drop: map[string]bool{"b": true}}
+	r.backend = func(cfg *config.Config, target serveBatchTarget) (batch.Backend, string) {
+		return fake, "native-model"
+	}
+	var logged []usage.LogEntry
+	r.logUsage = func(entry usage.LogEntry) { logged = append(logged, entry) }
+	var localCalls []string
+	call := r.call
+	r.call = func(ctx context.Context, provider llm.Provider, endpoint string, req llm.Request) (json.RawMessage, error) {
+		localCalls = append(localCalls, req.Messages[0].Parts[0].Text)
+		return call(ctx, provider, endpoint, req)
+	}
+	job := testBatchJob(t, r, testBatchLine("a", "one"), testBatchLine("b", "two"), testBatchLine("c", "local-only"))
+
+	result, err := r.Run(context.Background(), job, noopProgress)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(fake.submitted) != 1 || len(fake.submitted[0]) != 2 {
+		t.Fatalf("submitted = %+v, want one batch of two lines", fake.submitted)
+	}
+	if !strings.Contains(string(fake.submitted[0][0].Body), `"model":"native-model"`) {
+		t.Fatalf("native body = %s, want provider model name", fake.submitted[0][0].Body)
+	}
+	if strings.Join(localCalls, ",") != "local-only" {
+		t.Fatalf("local calls = %v, want only the unsupported line", localCalls)
+	}
+	if len(logged) != 1 || logged[0].Provider != "debug" || logged[0].InputTokens != 10 {
+		t.Fatalf("logged usage = %+v", logged)
+	}
+
+	summary := decodeTestBatchSummary(t, result)
+	if summary.RequestCounts != (batch.Counts{Total: 3, Completed: 2, Failed: 1}) {
+		t.Fatalf("counts = %+v", summary.RequestCounts)
+	}
+	if len(summary.Native) != 1 || summary.Native[0].ID != "remote-1" || summary.Native[0].Status != "done" {
+		t.Fatalf("native = %+v", summary.Native)
+	}
+	out := readTestBatchOutput(t, r, summary.OutputFileID)
+	if out[0].ID != "native_a" || out[1].Error == nil || out[1].Error.Code != batch.CodeProviderError {
+		t.Fatalf("output = %+v", out)
+	}
+}
+
+func TestJobsV2BatchRunner_ResumesFromCheckpoint(t *testing.T) {
+	r, _ := newTestBatchRunner(t)
+	fake := &fakeBatchBackend{done: true}
+	r.backend = func(cfg *config.Config, target serveBatchTarget) (batch.Backend, string) {
+		return fake, "m"
+	}
+	var localCalls []string
+	r.call = func(ctx context.Context, provider llm.Provider, endpoint string, req llm.Request) (json.RawMessage, error) {
+		localCalls = append(localCalls, req.Messages[0].Parts[0].Text)
+		return json.RawMessage(`{}`), nil
+	}
+	job := testBatchJob(t, r, testBatchLine("a", "one"), testBatchLine("b", "two"), testBatchLine("c", "local-only"))
+
+	dir, err := r.files.WorkDir(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prior := batch.Result{ID: "prior_a", CustomID: "a", Response: &batch.Response{StatusCode: 200, Body: json.RawMessage(`{}`)}}
+	var log bytes.Buffer
+	_ = batch.WriteResult(&log, prior)
+	log.WriteString(`{"id":"torn","custom_id":"c"`)
+	if err := os.WriteFile(filepath.Join(dir, "results.jsonl"), log.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	native := `[{"provider":"debug","model":"m","backend":"fake","id":"remote-9","custom_ids":["b"]}]`
+	if err := os.WriteFile(filepath.Join(dir, "native.json"), []byte(native), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := r.Run(context.Background(), job, noopProgress)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(fake.submitted) != 0 || len(fake.polled) == 0 || fake.polled[0] != "remote-9" {
+		t.Fatalf("submitted = %v, polled = %v; want the checkpointed batch resumed", fake.submitted, fake.polled)
+	}
+	if strings.Join(localCalls, ",") != "local-only" {
+		t.Fatalf("local calls = %v, want only the unfinished line", localCalls)
+	}
+	out := readTestBatchOutput(t, r, decodeTestBatchSummary(t, result).OutputFileID)
+	if len(out) != 3 || out[0].ID != "prior_a" || out[1].ID != "native_b" || out[2].Failed() {
+		t.Fatalf("output = %+v", out)
+	}
+}
+
+func TestJobsV2BatchRunner_CancelStopsRemoteBatchButShutdownDoesNot(t *testing.T) {
+	for _, shutdown := range []bool{false, true} {
+		t.Run(fmt.Sprintf("shutdown=%v", shutdown), func(t *testing.T) {
+			r, root := newTestBatchRunner(t)
+			fake := &fakeBatchBackend{}
+			r.backend = func(cfg *config.Config, target serveBatchTarget) (batch.Backend, string) {
+				return fake, "m"
+			}
+			job := testBatchJob(t, r, testBatchLine("a", "one"))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if shutdown {
+				ctx = context.WithValue(ctx, jobsV2ShutdownContextKey{}, func() bool { return true })
+			}
+			go func() {
+				for {
+					fake.mu.Lock()
+					polled := len(fake.polled)
+					fake.mu.Unlock()
+					if polled > 0 {
+						cancel()
+						return
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			}()
+			result, err := r.Run(ctx, job, noopProgress)
+
+			if shutdown {
+				if !errors.Is(err, context.Canceled) || len(fake.cancelled) != 0 {
+					t.Fatalf("Run err = %v, cancelled = %v; want interrupted run and remote batch left running", err, fake.cancelled)
+				}
+				if _, err := os.Stat(filepath.Join(root, "work", job.ID, "native.json")); err != nil {
+					t.Fatalf("checkpoint missing after shutdown: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if len(fake.cancelled) != 1 || fake.cancelled[0] != "remote-1" {
+				t.Fatalf("cancelled = %v, want remote-1", fake.cancelled)
+			}
+			out := readTestBatchOutput(t, r, decodeTestBatchSummary(t, result).OutputFileID)
+			if len(out) != 1 || out[0].Error == nil || out[0].Error.Code != batch.CodeCancelled {
+				t.Fatalf("output = %+v", out)
+			}
+		})
+	}
+}
+
+func TestServeBatchNativeBackend(t *testing.T) {
+	cfg := &config.Config{Providers: map[string]config.ProviderConfig{
+		"openai":    {Type: config.ProviderTypeOpenAI, APIKey: "sk-test"},
+		"anthropic": {Type: config.ProviderTypeAnthropic, APIKey: "ak-test"},
+		"claude":    {Type: config.ProviderTypeAnthropic, Credentials: "oauth"},
+	}}
+	tests := []struct {
+		target  serveBatchTarget
+		backend string
+	}{
+		{serveBatchTarget{Provider: "openai", Model: "gpt-5-mini"}, "openai"},
+		{serveBatchTarget{Provider: "openai", Model: "gpt-5-mini-high"}, ""},
+		{serveBatchTarget{Provider: "anthropic", Model: "claude-haiku-4-5"}, "anthropic"},
+		{serveBatchTarget{Provider: "anthropic", Model: "claude-sonnet-4-5-thinking"}, ""},
+		{serveBatchTarget{Provider: "claude", Model: "claude-haiku-4-5"}, ""},
+		{serveBatchTarget{Provider: "debug", Model: "x"}, ""},
+	}
+	for _, tt := range tests {
+		backend, model := serveBatchNativeBackend(cfg, tt.target)
+		got := ""
+		if backend != nil {
+			got = backend.Name()
+			if model != tt.target.Model {
+				t.Errorf("%+v: model = %q", tt.target, model)
+			}
+		}
+		if got != tt.backend {
+			t.Errorf("%+v: backend = %q, want %q", tt.target, got, tt.backend)
+		}
+	}
+}
+
+func TestServeBatchesHTTPFlow(t *testing.T) {
+	r, _ := newTestBatchRunner(t)
+	mgr, err := newJobsV2ManagerWithRunners(":memory:", 1, map[jobsV2RunnerType]jobsV2Runner{jobsV2RunnerBatch: r}, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2ManagerWithRunners: %v", err)
+	}
+	defer mgr.Close()
+	srv := &serveServer{cfgRef: r.cfg, jobsV2: mgr, batchFiles: r.files}
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	_ = mw.WriteField("purpose", "batch")
+	part, _ := mw.CreateFormFile("file", "rows.jsonl")
+	part.Write([]byte(testBatchLine("a", "one") + "\n" + testBatchLine("b", "fail") + "\n"))
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/files", &form)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rr := httptest.NewRecorder()
+	srv.handleBatchFiles(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("upload status = %d: %s", rr.Code, rr.Body.String())
+	}
+	var file batch.File
+	_ = json.Unmarshal(rr.Body.Bytes(), &file)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/batches", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		srv.handleBatches(rr, req)
+		return rr
+	}
+	if rr := create(`{"input_file_id":"` + file.ID + `","endpoint":"/v1/responses","completion_window":"24h"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("mismatched endpoint status = %d, want 400", rr.Code)
+	}
+	rr = create(`{"input_file_id":"` + file.ID + `","endpoint":"/v1/chat/completions","completion_window":"24h"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("create status = %d: %s", rr.Code, rr.Body.String())
+	}
+	var created serveBatchObject
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.Object != "batch" || created.RunID == "" {
+		t.Fatalf("created = %+v", created)
+	}
+	waitForJobsV2RunStatus(t, mgr, created.RunID, jobsV2RunSucceeded)
+
+	rr = httptest.NewRecorder()
+	srv.handleBatchByID(rr, httptest.NewRequest(http.MethodGet, "/v1/batches/"+created.ID, nil))
+	var got serveBatchObject
+	_ = json.Unmarshal(rr.Body.Bytes(), &got)
+	if got.Status != "completed" || got.RequestCounts != (batch.Counts{Total: 2, Completed: 1, Failed: 1}) || got.OutputFileID == "" || got.ErrorFileID == "" {
+		t.Fatalf("batch = %+v", got)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.handleBatchFileByID(rr, httptest.NewRequest(http.MethodGet, "/v1/files/"+got.ErrorFileID+"/content", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"custom_id":"b"`) || strings.Contains(rr.Body.String(), `"custom_id":"a"`) {
+		t.Fatalf("error file = %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	srv.handleBatches(rr, httptest.NewRequest(http.MethodGet, "/v1/batches", nil))
+	var list struct {
+		Data    []serveBatchObject `json:"data"`
+		HasMore bool               `json:"has_more"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &list)
+	if len(list.Data) != 1 || list.Data[0].ID != created.ID || list.HasMore {
+		t.Fatalf("list = %+v", list)
+	}
+}
+
+func TestServeBatchesAreScopedToAPIKeys(t *testing.T) {
+	r, _ := newTestBatchRunner(t)
+	mgr, err := newJobsV2ManagerWithRunners(":memory:", 1, map[jobsV2RunnerType]jobsV2Runner{jobsV2RunnerBatch: r}, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2ManagerWithRunners: %v", err)
+	}
+	defer mgr.Close()
+	srv := &serveServer{cfgRef: r.cfg, jobsV2: mgr, batchFiles: r.files}
+	alice := &serveAPIKeyGrant{key: apikeys.Key{ID: "key_alice", Name: "alice"}}
+	bob := &serveAPIKeyGrant{key: apikeys.Key{ID: "key_bob", Name: "bob"}}
+	send := func(grant *serveAPIKeyGrant, handler http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		handler(rr, req.WithContext(withServeAPIKey(req.Context(), grant)))
+		return rr
+	}
+	listLen := func(rr *httptest.ResponseRecorder) int {
+		t.Helper()
+		var list struct {
+			Data []json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+			t.Fatalf("decode list %s: %v", rr.Body.String(), err)
+		}
+		return len(list.Data)
+	}
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	_ = mw.WriteField("purpose", "batch")
+	part, _ := mw.CreateFormFile("file", "rows.jsonl")
+	part.Write([]byte(testBatchLine("a", "one") + "\n"))
+	mw.Close()
+	upload := httptest.NewRequest(http.MethodPost, "/v1/files", &form)
+	upload.Header.Set("Content-Type", mw.FormDataContentType())
+	rr := send(alice, srv.handleBatchFiles, upload)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("upload status = %d: %s", rr.Code, rr.Body.String())
+	}
+	var file batch.File
+	_ = json.Unmarshal(rr.Body.Bytes(), &file)
+
+	createBody := `{"input_file_id":"` + file.ID + `","endpoint":"/v1/chat/completions","completion_window":"24h"}`
+	createReq := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/v1/batches", strings.NewReader(createBody))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+	if rr := send(bob, srv.handleBatches, createReq()); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bob batch from alice's file: status = %d, want 400", rr.Code)
+	}
+	rr = send(alice, srv.handleBatches, createReq())
+	if rr.Code != http.StatusOK {
+		t.Fatalf("create status = %d: %s", rr.Code, rr.Body.String())
+	}
+	var created serveBatchObject
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+	waitForJobsV2RunStatus(t, mgr, created.RunID, jobsV2RunSucceeded)
+	rr = send(alice, srv.handleBatchByID, httptest.NewRequest(http.MethodGet, "/v1/batches/"+created.ID, nil))
+	var got serveBatchObject
+	_ = json.Unmarshal(rr.Body.Bytes(), &got)
+	if got.OutputFileID == "" {
+		t.Fatalf("batch = %+v", got)
+	}
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/v1/files/"+file.ID, nil),
+		httptest.NewRequest(http.MethodGet, "/v1/files/"+got.OutputFileID+"/content", nil),
+		httptest.NewRequest(http.MethodDelete, "/v1/files/"+file.ID, nil),
+	} {
+		if rr := send(bob, srv.handleBatchFileByID, req); rr.Code != http.StatusNotFound {
+			t.Fatalf("bob %s %s: status = %d, want 404", req.Method, req.URL.Path, rr.Code)
+		}
+	}
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/v1/batches/"+created.ID, nil),
+		httptest.NewRequest(http.MethodPost, "/v1/batches/"+created.ID+"/cancel", nil),
+	} {
+		if rr := send(bob, srv.handleBatchByID, req); rr.Code != http.StatusNotFound {
+			t.Fatalf("bob %s %s: status = %d, want 404", req.Method, req.URL.Path, rr.Code)
+		}
+	}
+	if n := listLen(send(bob, srv.handleBatchFiles, httptest.NewRequest(http.MethodGet, "/v1/files", nil))); n != 0 {
+		t.Fatalf("bob sees %d files, want 0", n)
+	}
+	if n := listLen(send(bob, srv.handleBatches, httptest.NewRequest(http.MethodGet, "/v1/batches", nil))); n != 0 {
+		t.Fatalf("bob sees %d batches, want 0", n)
+	}
+
+	if n := listLen(send(alice, srv.handleBatchFiles, httptest.NewRequest(http.MethodGet, "/v1/files", nil))); n != 2 {
+		t.Fatalf("alice sees %d files, want input and output", n)
+	}
+	if rr := send(alice, srv.handleBatchFileByID, httptest.NewRequest(http.MethodGet, "/v1/files/"+got.OutputFileID+"/content", nil)); rr.Code != http.StatusOK {
+		t.Fatalf("alice output content: status = %d", rr.Code)
+	}
+	if n := listLen(send(nil, srv.handleBatches, httptest.NewRequest(http.MethodGet, "/v1/batches", nil))); n != 1 {
+		t.Fatalf("server token sees %d batches, want 1", n)
+	}
+	if rr := send(nil, srv.handleBatchFileByID, httptest.NewRequest(http.MethodGet, "/v1/files/"+file.ID, nil)); rr.Code != http.StatusOK {
+		t.Fatalf("server token file lookup: status = %d", rr.Code)
+	}
+}
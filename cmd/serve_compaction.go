@@ -109,5 +109,6 @@ func (rt *serveRuntime) compactSession(ctx context.Context, sessionID string) (*
 		rt.engine.SetContextEstimateBaseline(0, 0)
 	}
 	rt.refreshSideQuestionSnapshot(compacted)
+	rt.emitCompactionWebhook(sessionID, "manual", result)
 	return result, nil
 }
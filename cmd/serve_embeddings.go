@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/embedding"
+	"github.com/samsaffron/term-llm/internal/llm"
+)
+
+const (
+	maxEmbeddingsRequestBytes = 16 << 20
+	// maxEmbeddingsInputs matches OpenAI's per-request input limit; larger
+	// provider-side batching happens below it.
+	maxEmbeddingsInputs = 2048
+)
+
+var newServeEmbeddingProvider = embedding.NewEmbeddingProvider
+
+type embeddingsRequest struct {
+	Model          string          `json:"model"`
+	Input          json.RawMessage `json:"input"`
+	Dimensions     int             `json:"dimensions,omitempty"`
+	EncodingFormat string          `json:"encoding_format,omitempty"`
+	User           string          `json:"user,omitempty"`
+}
+
+// parseEmbeddingsInput accepts a string or a list of strings. Token arrays
+// are rejected: they only make sense for the tokenizer of one provider.
+func parseEmbeddingsInput(raw json.RawMessage) ([]string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, fmt.Errorf("input is required")
+	}
+	var texts []string
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		texts = []string{single}
+	} else if err := json.Unmarshal(raw, &texts); err != nil {
+		if raw[0] == '[' {
+			return nil, fmt.Errorf("input must be a string or an array of strings; token arrays are not supported")
+		}
+		return nil, fmt.Errorf("input must be a string or an array of strings")
+	}
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("input must not be empty")
+	}
+	if len(texts) > maxEmbeddingsInputs {
+		return nil, fmt.Errorf("input has %d items; at most %d are allowed per request", len(texts), maxEmbeddingsInputs)
+	}
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("input[%d] is empty", i)
+		}
+	}
+	return texts, nil
+}
+
+// resolveServeEmbeddingModel maps a requested model to a provider spec for
+// embedding.NewEmbeddingProvider. Lookup order: embed.aliases, an explicit
+// provider or provider:model, a well-known model name, and finally the
+// configured default provider with the requested model.
+func resolveServeEmbeddingModel(cfg *config.Config, requested string) (string, error) {
+	requested = strings.TrimSpace(requested)
+	if target, ok := cfg.Embed.Aliases[requested]; ok && requested != "" {
+		return strings.TrimSpace(target), nil
+	}
+	if provider, _, found := strings.Cut(requested, ":"); found && embedding.IsProvider(provider) {
+		return requested, nil
+	}
+	if embedding.IsProvider(requested) {
+		return requested, nil
+	}
+	if provider := embedding.ProviderForModel(requested); provider != "" {
+		return provider + ":" + requested, nil
+	}
+
+	defaultSpec := strings.TrimSpace(cfg.Embed.Provider)
+	if defaultSpec == "" {
+		defaultSpec = embedding.InferEmbeddingProvider(cfg)
+	}
+	if requested == "" {
+		return defaultSpec, nil
+	}
+	provider, _, _ := strings.Cut(defaultSpec, ":")
+	if provider == "" {
+		return "", fmt.Errorf("unknown embedding model %q and no default embedding provider is configured", requested)
+	}
+	return provider + ":" + requested, nil
+}
+
+func (s *serveServer) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxEmbeddingsRequestBytes)
+	var req embeddingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid json")
+		return
+	}
+	texts, err := parseEmbeddingsInput(req.Input)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	switch req.EncodingFormat {
+	case "", "float", "base64":
+	default:
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "encoding_format must be float or base64")
+		return
+	}
+	if req.Dimensions < 0 {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "dimensions must be positive")
+		return
+	}
+
+	spec, err := resolveServeEmbeddingModel(s.cfgRef, req.Model)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	providerName, model, _ := strings.Cut(spec, ":")
+	if err := checkAPIKeyProvider(r.Context(), providerName); err != nil {
+		writeOpenAIError(w, http.StatusForbidden, "permission_error", err.Error())
+		return
+	}
+	provider, err := newServeEmbeddingProvider(s.cfgRef, spec)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if model == "" {
+		model = provider.DefaultModel()
+	}
+
+	data := make([]map[string]any, 0, len(texts))
+	var promptTokens int64
+	responseModel := ""
+	batchSize := embedding.MaxBatchSize(providerName)
+	for start := 0; start < len(texts); start += batchSize {
+		batch := texts[start:min(start+batchSize, len(texts))]
+		result, err := provider.Embed(r.Context(), embedding.EmbedRequest{Texts: batch, Model: model, Dimensions: req.Dimensions})
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadGateway, "server_error", err.Error())
+			return
+		}
+		if len(result.Embeddings) != len(batch) {
+			writeOpenAIError(w, http.StatusBadGateway, "server_error", fmt.Sprintf("embedding provider returned %d vectors for %d inputs", len(result.Embeddings), len(batch)))
+			return
+		}
+		if responseModel == "" {
+			responseModel = result.Model
+		}
+		// Providers without usage reporting are estimated so callers can
+		// still account for every request.
+		if result.Usage != nil && (result.Usage.PromptTokens > 0 || result.Usage.TotalTokens > 0) {
+			promptTokens += max(result.Usage.PromptTokens, result.Usage.TotalTokens)
+		} else {
+			for _, text := range batch {
+				promptTokens += int64(llm.EstimateTokens(text))
+			}
+		}
+		for _, emb := range result.Embeddings {
+			var vector any = emb.Vector
+			if req.EncodingFormat == "base64" {
+				vector = encodeEmbeddingBase64(emb.Vector)
+			}
+			data = append(data, map[string]any{
+				"object":    "embedding",
+				"index":     start + emb.Index,
+				"embedding": vector,
+			})
+		}
+	}
+	if responseModel == "" {
+		responseModel = model
+	}
+	recordAPIKeyTokens(r.Context(), providerName, responseModel, promptTokens)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object": "list",
+		"data":   data,
+		"model":  strings.TrimPrefix(responseModel, "models/"),
+		"usage": map[string]any{
+			"prompt_tokens": promptTokens,
+			"total_tokens":  promptTokens,
+		},
+	})
+}
+
+// encodeEmbeddingBase64 packs a vector as little-endian float32, the layout
+// OpenAI clients expect for encoding_format=base64.
+func encodeEmbeddingBase64(vector []float64) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
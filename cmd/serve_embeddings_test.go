@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/embedding"
+)
+
+type fakeServeEmbeddingProvider struct {
+	batches []int
+	model   string
+}
+
+func (p *fakeServeEmbeddingProvider) Name() string         { return "fake" }
+func (p *fakeServeEmbeddingProvider) DefaultModel() string { return "fake-default" }
+
+func (p *fakeServeEmbeddingProvider) Embed(ctx context.Context, req embedding.EmbedRequest) (*embedding.EmbeddingResult, error) {
+	p.batches = append(p.batches, len(req.Texts))
+	p.model = req.Model
+	result := &embedding.EmbeddingResult{Model: "models/" + req.Model}
+	for i, text := range req.Texts {
+		result.Embeddings = append(result.Embeddings, embedding.Embedding{Index: i, Text: text, Vector: []float64{float64(len(text)), 0.5}})
+	}
+	return result, nil
+}
+
+func TestResolveServeEmbeddingModel(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Embed.Provider = "ollama"
+	cfg.Embed.Aliases = map[string]string{"small": "openai:text-embedding-3-small"}
+
+	tests := map[string]string{
+		"":                     "ollama",
+		"small":                "openai:text-embedding-3-small",
+		"voyage":               "voyage",
+		"jina:jina-clip-v2":    "jina:jina-clip-v2",
+		"gemini-embedding-001": "gemini:gemini-embedding-001",
+		"mxbai-embed-large":    "ollama:mxbai-embed-large",
+	}
+	for requested, want := range tests {
+		got, err := resolveServeEmbeddingModel(cfg, requested)
+		if err != nil || got != want {
+			t.Errorf("resolveServeEmbeddingModel(%q) = %q, %v; want %q", requested, got, err, want)
+		}
+	}
+
+	if _, err := resolveServeEmbeddingModel(&config.Config{}, "mxbai-embed-large"); err == nil {
+		t.Fatal("expected error without a default embedding provider")
+	}
+}
+
+func TestHandleEmbeddingsBatchesAndAccountsUsage(t *testing.T) {
+	fake := &fakeServeEmbeddingProvider{}
+	var gotSpec string
+	old := newServeEmbeddingProvider
+	newServeEmbeddingProvider = func(cfg *config.Config, spec string) (embedding.EmbeddingProvider, error) {
+		gotSpec = spec
+		return fake, nil
+	}
+	t.Cleanup(func() { newServeEmbeddingProvider = old })
+
+	s := &serveServer{cfgRef: &config.Config{}}
+	inputs := make([]string, 150)
+	for i := range inputs {
+		inputs[i] = fmt.Sprintf("text number %d", i)
+	}
+	body, _ := json.Marshal(map[string]any{"model": "gemini-embedding-001", "input": inputs})
+	rr := httptest.NewRecorder()
+	s.handleEmbeddings(rr, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(string(body))))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", rr.Code, rr.Body.String())
+	}
+	if gotSpec != "gemini:gemini-embedding-001" || fake.model != "gemini-embedding-001" {
+		t.Fatalf("spec = %q model = %q", gotSpec, fake.model)
+	}
+	if len(fake.batches) != 2 || fake.batches[0] != 100 || fake.batches[1] != 50 {
+		t.Fatalf("batches = %v, want [100 50]", fake.batches)
+	}
+
+	var resp struct {
+		Object string `json:"object"`
+		Model  string `json:"model"`
+		Data   []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+			TotalTokens  int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Object != "list" || resp.Model != "gemini-embedding-001" || len(resp.Data) != 150 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if last := resp.Data[149]; last.Index != 149 || last.Embedding[0] != float64(len(inputs[149])) {
+		t.Fatalf("last embedding = %+v", last)
+	}
+	if resp.Usage.PromptTokens == 0 || resp.Usage.TotalTokens != resp.Usage.PromptTokens {
+		t.Fatalf("usage = %+v, want estimated tokens", resp.Usage)
+	}
+}
+
+func TestHandleEmbeddingsBase64AndValidation(t *testing.T) {
+	old := newServeEmbeddingProvider
+	newServeEmbeddingProvider = func(cfg *config.Config, spec string) (embedding.EmbeddingProvider, error) {
+		return &fakeServeEmbeddingProvider{}, nil
+	}
+	t.Cleanup(func() { newServeEmbeddingProvider = old })
+	s := &serveServer{cfgRef: &config.Config{}}
+
+	rr := httptest.NewRecorder()
+	s.handleEmbeddings(rr, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"model":"openai","input":"abc","encoding_format":"base64"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Data []struct {
+			Embedding string `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || len(resp.Data) != 1 {
+		t.Fatalf("decode: %v body = %s", err, rr.Body.String())
+	}
+	raw, err := base64.StdEncoding.DecodeString(resp.Data[0].Embedding)
+	if err != nil || len(raw) != 8 {
+		t.Fatalf("base64 embedding = %q, %v", resp.Data[0].Embedding, err)
+	}
+	if got := math.Float32frombits(binary.LittleEndian.Uint32(raw[4:])); got != 0.5 {
+		t.Fatalf("second component = %v, want 0.5", got)
+	}
+
+	for body, want := range map[string]string{
+		`{"input":[[1,2,3]]}`:                      "token arrays are not supported",
+		`{"input":[]}`:                             "must not be empty",
+		`{"input":["ok",""]}`:                      "input[1] is empty",
+		`{"input":"x","encoding_format":"binary"}`: "encoding_format",
+	} {
+		rr := httptest.NewRecorder()
+		s.handleEmbeddings(rr, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("%s: status = %d body = %s", body, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -144,3 +144,76 @@ func (s *serveServer) handleSessionFileChangeContent(w http.ResponseWriter, r *h
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write(content.Data)
 }
+
+type sessionFileChangeRevertRequest struct {
+	Path  string `json:"path"`
+	Hunks []int  `json:"hunks,omitempty"`
+	From  int64  `json:"from,omitempty"`
+	To    int64  `json:"to,omitempty"`
+}
+
+// handleSessionFileChangeRevert serves POST /v1/sessions/{id}/file-changes/revert:
+// restores one file (or the hunks listed by index in its diff) to the recorded
+// snapshot on disk. From/To narrow the diff to a range of change sequences.
+// The revert is recorded as a new change, so the listing reflects it.
+func (s *serveServer) handleSessionFileChangeRevert(w http.ResponseWriter, r *http.Request, sessionID string) {
+	store := s.fileTrackStore()
+	if store == nil {
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "file tracking is not enabled")
+		return
+	}
+	var req sessionFileChangeRevertRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Path) == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "path is required")
+		return
+	}
+	if !s.fileChangeSessionExists(r.Context(), sessionID) {
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "session not found")
+		return
+	}
+	// Holding the runtime lock keeps a new response from editing the file
+	// while it is being restored.
+	if s.sessionMgr != nil {
+		if rt, ok := s.sessionMgr.Get(sessionID); ok && rt != nil {
+			if !rt.mu.TryLock() {
+				writeOpenAIError(w, http.StatusConflict, "conflict_error", "session is busy; retry after the active response finishes")
+				return
+			}
+			defer rt.mu.Unlock()
+		}
+	}
+
+	change, err := store.Revert(r.Context(), filetrack.RevertRequest{
+		SessionID: sessionID,
+		Path:      req.Path,
+		Range:     filetrack.SeqRange{From: req.From, To: req.To},
+		Hunks:     req.Hunks,
+	})
+	switch {
+	case errors.Is(err, filetrack.ErrNothingToRevert):
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", err.Error())
+		return
+	case errors.Is(err, filetrack.ErrRevertConflict):
+		writeOpenAIError(w, http.StatusConflict, "conflict_error", err.Error())
+		return
+	case errors.Is(err, filetrack.ErrInvalidHunk):
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	case errors.Is(err, filetrack.ErrRevertUnavailable):
+		writeOpenAIError(w, http.StatusUnprocessableEntity, "invalid_request_error", err.Error())
+		return
+	case err != nil:
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", "failed to revert file change")
+		return
+	}
+	resp := map[string]any{"path": req.Path, "reverted": true}
+	if change != nil {
+		resp["path"] = change.Path
+		resp["seq"] = change.Seq
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
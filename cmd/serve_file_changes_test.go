@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -403,3 +404,63 @@ func TestSessionFileChangesRequireLiveSession(t *testing.T) {
 		t.Fatalf("deleted session content status = %d, want 404", code)
 	}
 }
+
+func TestSessionFileChangeRevertEndpoint(t *testing.T) {
+	srv, store := newFileChangesTestServer(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("one\nTWO\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.RecordChange(ctx, filetrack.ChangeRecord{
+		SessionID: "sess-1", Path: path,
+		Before: []byte("one\ntwo\n"), After: []byte("one\nTWO\n"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(body string) (int, map[string]any) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/sessions/sess-1/file-changes/revert", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		srv.handleSessionByID(rr, req)
+		var out map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return rr.Code, out
+	}
+
+	if code, _ := post(`{}`); code != http.StatusBadRequest {
+		t.Fatalf("missing path status = %d, want 400", code)
+	}
+	for _, hunks := range [][]int{{5}, {}} {
+		invalid, _ := json.Marshal(map[string]any{"path": path, "hunks": hunks})
+		if code, out := post(string(invalid)); code != http.StatusBadRequest {
+			t.Fatalf("hunks %v status = %d, want 400 body = %v", hunks, code, out)
+		}
+	}
+	if data, _ := os.ReadFile(path); string(data) != "one\nTWO\n" {
+		t.Fatalf("file after rejected reverts = %q", data)
+	}
+	body, _ := json.Marshal(map[string]any{"path": path, "hunks": []int{0}})
+	code, out := post(string(body))
+	if code != http.StatusOK || out["reverted"] != true {
+		t.Fatalf("revert status = %d, body = %v", code, out)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "one\ntwo\n" {
+		t.Fatalf("file after revert = %q", data)
+	}
+	if code, _ := getSessionPath(t, srv, "/v1/sessions/sess-1/file-changes/diff?path="+path); code != http.StatusNotFound {
+		t.Fatalf("diff after revert status = %d, want 404", code)
+	}
+	if code, _ := post(string(body)); code != http.StatusNotFound {
+		t.Fatalf("second revert status = %d, want 404", code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/sessions/sess-1/file-changes/revert", nil)
+	rr := httptest.NewRecorder()
+	srv.handleSessionByID(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET revert status = %d, want 405", rr.Code)
+	}
+}
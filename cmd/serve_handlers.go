@@ -25,6 +25,7 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"github.com/samsaffron/term-llm/internal/apikeys"
 	"github.com/samsaffron/term-llm/internal/config"
 	"github.com/samsaffron/term-llm/internal/image"
 	"github.com/samsaffron/term-llm/internal/llm"
@@ -48,7 +49,7 @@ func (s *serveServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	resp := map[string]any{"status": "ok"}
-	// Identity fields (agent, version, capabilities) are only reported to
+	// Identity fields (agent, version, capabilities, labels) are only reported to
 	// trusted callers — a valid bearer token, or any caller when auth is
 	// disabled — so the unauthenticated health probe stays anonymous. The hub
 	// prober sends the node token and uses these for its dashboard.
@@ -56,6 +57,11 @@ func (s *serveServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 		resp["agent"] = s.cfg.agentName
 		resp["version"] = Version
 		resp["capabilities"] = s.capabilityList()
+		if s.jobsV2 != nil {
+			if labels := s.jobsV2.localWorkerLabels(); len(labels) > 0 {
+				resp["labels"] = labels
+			}
+		}
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
@@ -1928,6 +1934,16 @@ func (s *serveServer) handleSessionByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if suffix == "file-changes/revert" {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		s.handleSessionFileChangeRevert(w, r, sessionID)
+		return
+	}
+
 	if suffix == "file-changes" || suffix == "file-changes/diff" || suffix == "file-changes/content" {
 		if r.Method != http.MethodGet {
 			w.Header().Set("Allow", "GET")
@@ -2592,11 +2608,17 @@ func (s *serveServer) auth(next http.HandlerFunc) http.HandlerFunc {
 			}
 		}
 
-		if gotToken == "" || subtle.ConstantTimeCompare([]byte(gotToken), []byte(s.cfg.token)) != 1 {
-			writeOpenAIError(w, http.StatusUnauthorized, "invalid_api_key", "invalid authentication credentials")
+		if gotToken != "" && subtle.ConstantTimeCompare([]byte(gotToken), []byte(s.cfg.token)) == 1 {
+			next(w, r)
 			return
 		}
-		next(w, r)
+		if s.apiKeys != nil && strings.HasPrefix(gotToken, apikeys.KeyPrefix) {
+			if keyed, ok := s.authenticateAPIKey(w, r, gotToken); ok {
+				next(w, keyed)
+			}
+			return
+		}
+		writeOpenAIError(w, http.StatusUnauthorized, "invalid_api_key", "invalid authentication credentials")
 	}
 }
 
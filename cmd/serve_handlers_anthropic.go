@@ -72,44 +72,15 @@ func (s *serveServer) handleAnthropicMessages(w http.ResponseWriter, r *http.Req
 	if !stateful {
 		defer runtime.Close()
 	}
+	if err := s.checkAPIKeyRun(ctx, runtimeProviderKey(runtime)); err != nil {
+		writeAnthropicError(w, http.StatusForbidden, "permission_error", err.Error())
+		return
+	}
 
 	search := runtime.search
-	requestedTools := parseAnthropicRequestedToolNames(req.Tools)
 	toolChoice := parseAnthropicToolChoice(req.ToolChoice)
 
-	// Merge requested server tools (engine can execute) with client tools
-	// (passthrough). Server tools win on name collision so the engine executes
-	// its own version. Client tools the engine doesn't recognise are forwarded
-	// to the LLM and returned as tool_use blocks for the client to handle.
-	//
-	// ToolMap targets (e.g. "search" when mapping "WebSearch" → "search") are
-	// excluded from the server list — the LLM will see the client's tool name
-	// and the engine redirects execution via ToolMap.
-	serverTools := []llm.ToolSpec(nil)
-	if len(requestedTools) > 0 {
-		serverTools = runtime.selectTools(requestedTools)
-	}
-	mappedTargets := make(map[string]bool)
-	if runtime.toolMap != nil {
-		for name := range requestedTools {
-			if mapped, ok := runtime.toolMap[name]; ok {
-				mappedTargets[mapped] = true
-			}
-		}
-	}
-	serverNames := make(map[string]bool, len(serverTools))
-	tools := make([]llm.ToolSpec, 0, len(serverTools)+len(req.Tools))
-	for _, t := range serverTools {
-		serverNames[t.Name] = true
-		if !mappedTargets[t.Name] {
-			tools = append(tools, t)
-		}
-	}
-	for _, ct := range anthropicToolsToSpecs(req.Tools) {
-		if !serverNames[ct.Name] {
-			tools = append(tools, ct)
-		}
-	}
+	tools := anthropicRequestTools(runtime, req.Tools)
 	if len(tools) == 0 {
 		toolChoice = llm.ToolChoice{}
 	}
@@ -183,6 +154,45 @@ func (s *serveServer) handleAnthropicMessages(w http.ResponseWriter, r *http.Req
 	writeJSON(w, http.StatusOK, anthropicMessagesFinalResponse(result, model))
 }
 
+// anthropicRequestTools merges requested server tools (engine can execute)
+// with client tools (passthrough). Server tools win on name collision so the
+// engine executes its own version. Client tools the engine doesn't recognise
+// are forwarded to the LLM and returned as tool_use blocks for the client to
+// handle.
+//
+// ToolMap targets (e.g. "search" when mapping "WebSearch" → "search") are
+// excluded from the server list — the LLM will see the client's tool name
+// and the engine redirects execution via ToolMap.
+func anthropicRequestTools(runtime *serveRuntime, reqTools []anthropicToolDef) []llm.ToolSpec {
+	requestedTools := parseAnthropicRequestedToolNames(reqTools)
+	serverTools := []llm.ToolSpec(nil)
+	if len(requestedTools) > 0 {
+		serverTools = runtime.selectTools(requestedTools)
+	}
+	mappedTargets := make(map[string]bool)
+	if runtime.toolMap != nil {
+		for name := range requestedTools {
+			if mapped, ok := runtime.toolMap[name]; ok {
+				mappedTargets[mapped] = true
+			}
+		}
+	}
+	serverNames := make(map[string]bool, len(serverTools))
+	tools := make([]llm.ToolSpec, 0, len(serverTools)+len(reqTools))
+	for _, t := range serverTools {
+		serverNames[t.Name] = true
+		if !mappedTargets[t.Name] {
+			tools = append(tools, t)
+		}
+	}
+	for _, ct := range anthropicToolsToSpecs(reqTools) {
+		if !serverNames[ct.Name] {
+			tools = append(tools, ct)
+		}
+	}
+	return tools
+}
+
 func (s *serveServer) streamAnthropicMessages(ctx context.Context, w http.ResponseWriter, runtime *serveRuntime, stateful bool, replaceHistory bool, inputMessages []llm.Message, llmReq llm.Request, sessionID string) {
 	w = newStreamingResponseWriter(w, serveStreamWriteTimeout)
 	flusher, ok := w.(http.Flusher)
@@ -0,0 +1,455 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/batch"
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/jobs"
+	"github.com/samsaffron/term-llm/internal/session"
+)
+
+const (
+	maxBatchFileUploadBytes = 200 << 20
+	batchFileFormMemory     = 32 << 20
+)
+
+// newServeBatchRunner opens the batch file store under the data dir and
+// returns the runner for batch jobs.
+func newServeBatchRunner(cfg *config.Config, apiKeys *serveAPIKeys) (*jobsV2BatchRunner, error) {
+	dataDir, err := session.GetDataDir()
+	if err != nil {
+		return nil, fmt.Errorf("resolve batch data dir: %w", err)
+	}
+	files, err := batch.OpenFileStore(batch.DefaultDir(dataDir))
+	if err != nil {
+		return nil, err
+	}
+	runner := newJobsV2BatchRunner(cfg, files)
+	if apiKeys != nil {
+		runner.recordUsage = apiKeys.recorder
+	}
+	return runner, nil
+}
+
+// batchOwner returns the API key that owns the files and batches a request
+// creates, or "" for the server token.
+func batchOwner(r *http.Request) string {
+	if grant := serveAPIKeyFromContext(r.Context()); grant != nil {
+		return grant.key.ID
+	}
+	return ""
+}
+
+// canAccessBatchResource reports whether a request may see a file or batch
+// owned by owner. API keys only see their own; the server token sees all.
+func canAccessBatchResource(r *http.Request, owner string) bool {
+	caller := batchOwner(r)
+	return caller == "" || caller == owner
+}
+
+// handleBatchFiles serves GET and POST /v1/files for batch input files.
+func (s *serveServer) handleBatchFiles(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		files, err := s.batchFiles.List(strings.TrimSpace(r.URL.Query().Get("purpose")), batchOwner(r))
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": files})
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, maxBatchFileUploadBytes+(1<<20))
+		if err := r.ParseMultipartForm(batchFileFormMemory); err != nil {
+			if r.MultipartForm != nil {
+				_ = r.MultipartForm.RemoveAll()
+			}
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "request must be multipart/form-data with a file of at most 200MB")
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		if purpose := strings.TrimSpace(r.FormValue("purpose")); purpose != batch.PurposeBatch {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("purpose must be %q", batch.PurposeBatch))
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "file is required")
+			return
+		}
+		defer file.Close()
+		created, err := s.batchFiles.Create(header.Filename, batch.PurposeBatch, batchOwner(r), file)
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, created)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+// handleBatchFileByID serves /v1/files/{id} and /v1/files/{id}/content.
+func (s *serveServer) handleBatchFileByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/files/"), "/"), "/")
+	if parts[0] == "" || len(parts) > 2 || (len(parts) == 2 && parts[1] != "content") {
+		http.NotFound(w, r)
+		return
+	}
+	id := parts[0]
+	if len(parts) == 2 {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		f, fh, err := s.batchFiles.Open(id)
+		if err != nil {
+			writeBatchFileError(w, err)
+			return
+		}
+		defer fh.Close()
+		if !canAccessBatchResource(r, f.Owner) {
+			writeBatchFileError(w, batch.ErrNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/jsonl")
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.Copy(w, fh)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "GET, DELETE")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	f, err := s.batchFiles.Get(id)
+	if err == nil && !canAccessBatchResource(r, f.Owner) {
+		err = batch.ErrNotFound
+	}
+	if err != nil {
+		writeBatchFileError(w, err)
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, f)
+		return
+	}
+	if err := s.batchFiles.Delete(id); err != nil {
+		writeBatchFileError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"id": id, "object": "file", "deleted": true})
+}
+
+func writeBatchFileError(w http.ResponseWriter, err error) {
+	if errors.Is(err, batch.ErrNotFound) {
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "file not found")
+		return
+	}
+	writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+}
+
+type serveBatchCreateRequest struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	// Native is a term-llm extension; false keeps every line off provider
+	// batch APIs.
+	Native *bool `json:"native,omitempty"`
+}
+
+// serveBatchObject is a batch in the shape of the OpenAI Batch API. Its ID
+// is the ID of the job that runs it.
+type serveBatchObject struct {
+	ID               string             `json:"id"`
+	Object           string             `json:"object"`
+	Endpoint         string             `json:"endpoint"`
+	InputFileID      string             `json:"input_file_id"`
+	CompletionWindow string             `json:"completion_window"`
+	Status           string             `json:"status"`
+	OutputFileID     string             `json:"output_file_id,omitempty"`
+	ErrorFileID      string             `json:"error_file_id,omitempty"`
+	Errors           *serveBatchErrors  `json:"errors,omitempty"`
+	CreatedAt        int64              `json:"created_at"`
+	ExpiresAt        int64              `json:"expires_at"`
+	InProgressAt     *int64             `json:"in_progress_at,omitempty"`
+	CompletedAt      *int64             `json:"completed_at,omitempty"`
+	FailedAt         *int64             `json:"failed_at,omitempty"`
+	ExpiredAt        *int64             `json:"expired_at,omitempty"`
+	CancelledAt      *int64             `json:"cancelled_at,omitempty"`
+	RequestCounts    batch.Counts       `json:"request_counts"`
+	Usage            batch.Usage        `json:"usage"`
+	Metadata         map[string]string  `json:"metadata,omitempty"`
+	Native           []serveBatchRemote `json:"native,omitempty"`
+	RunID            string             `json:"run_id,omitempty"`
+}
+
+type serveBatchErrors struct {
+	Object string        `json:"object"`
+	Data   []batch.Error `json:"data"`
+}
+
+// serveBatchFromJob describes a batch job and its latest run.
+func serveBatchFromJob(job jobsV2Job) serveBatchObject {
+	var bc jobs.BatchConfig
+	_ = json.Unmarshal(job.RunnerConfig, &bc)
+	obj := serveBatchObject{
+		ID:               job.ID,
+		Object:           "batch",
+		Endpoint:         bc.Endpoint,
+		InputFileID:      bc.InputFileID,
+		CompletionWindow: firstNonEmpty(bc.CompletionWindow, "24h"),
+		Status:           "validating",
+		CreatedAt:        job.CreatedAt.Unix(),
+		ExpiresAt:        job.CreatedAt.Add(serveBatchCompletionWindow).Unix(),
+		Metadata:         bc.Metadata,
+	}
+	run := job.LastRun
+	if run == nil {
+		return obj
+	}
+	obj.RunID = run.ID
+	var summary serveBatchSummary
+	if json.Unmarshal([]byte(run.Response), &summary) == nil {
+		obj.RequestCounts = summary.RequestCounts
+		obj.Usage = summary.Usage
+		obj.OutputFileID = summary.OutputFileID
+		obj.ErrorFileID = summary.ErrorFileID
+		obj.Native = summary.Native
+	}
+	if run.StartedAt != nil {
+		obj.InProgressAt = unixPtr(*run.StartedAt)
+	}
+	var finished *int64
+	if run.FinishedAt != nil {
+		finished = unixPtr(*run.FinishedAt)
+	}
+	switch run.Status {
+	case jobsV2RunQueued, jobsV2RunClaimed:
+		if run.Attempt > 1 {
+			obj.Status = "in_progress"
+		}
+	case jobsV2RunRunning:
+		obj.Status = "in_progress"
+	case jobsV2RunCancelRequested:
+		obj.Status = "cancelling"
+	case jobsV2RunSucceeded:
+		if summary.Expired {
+			obj.Status, obj.ExpiredAt = "expired", finished
+		} else {
+			obj.Status, obj.CompletedAt = "completed", finished
+		}
+	case jobsV2RunTimedOut:
+		obj.Status, obj.ExpiredAt = "expired", finished
+	case jobsV2RunCancelled:
+		obj.Status, obj.CancelledAt = "cancelled", finished
+	default:
+		obj.Status, obj.FailedAt = "failed", finished
+		obj.Errors = &serveBatchErrors{Object: "list", Data: []batch.Error{{Code: "batch_failed", Message: run.Error}}}
+	}
+	return obj
+}
+
+// batchFromJob converts a batch job using its full last run; job lists and
+// lookups only carry run summaries, without the response holding the batch
+// summary.
+func (s *serveServer) batchFromJob(job jobsV2Job) serveBatchObject {
+	if job.LastRun != nil {
+		if run, err := s.jobsV2.GetRun(job.LastRun.ID); err == nil {
+			job.LastRun = &run
+		}
+	}
+	return serveBatchFromJob(job)
+}
+
+func unixPtr(t time.Time) *int64 {
+	v := t.Unix()
+	return &v
+}
+
+// handleBatches serves GET and POST /v1/batches.
+func (s *serveServer) handleBatches(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		limit, err := parseNonNegativeIntQuery(r, "limit", 20)
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		offset, err := parseNonNegativeIntQuery(r, "offset", 0)
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		list, total, err := s.jobsV2.ListJobsByRunner(jobsV2RunnerBatch, batchOwner(r), min(max(limit, 1), 100), offset)
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		data := make([]serveBatchObject, 0, len(list))
+		for _, job := range list {
+			data = append(data, s.batchFromJob(job))
+		}
+		resp := map[string]any{"object": "list", "data": data, "has_more": offset+len(data) < total}
+		if len(data) > 0 {
+			resp["first_id"], resp["last_id"] = data[0].ID, data[len(data)-1].ID
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case http.MethodPost:
+		s.createBatch(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+	}
+}
+
+func (s *serveServer) createBatch(w http.ResponseWriter, r *http.Request) {
+	if err := requireJSONContentType(r); err != nil {
+		writeOpenAIError(w, http.StatusUnsupportedMediaType, "invalid_request_error", err.Error())
+		return
+	}
+	var req serveBatchCreateRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if window := strings.TrimSpace(req.CompletionWindow); window != "" && window != "24h" {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", `completion_window must be "24h"`)
+		return
+	}
+	if !batch.ValidEndpoint(req.Endpoint) {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("endpoint must be %s or %s", batch.EndpointChatCompletions, batch.EndpointResponses))
+		return
+	}
+	input, fh, err := s.batchFiles.Open(strings.TrimSpace(req.InputFileID))
+	if err == nil && !canAccessBatchResource(r, input.Owner) {
+		fh.Close()
+		err = batch.ErrNotFound
+	}
+	if err != nil {
+		if errors.Is(err, batch.ErrNotFound) {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "input_file_id does not name an uploaded file")
+			return
+		}
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	reqs, err := batch.ParseRequests(fh, req.Endpoint)
+	fh.Close()
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	// Check the key's provider scope up front; lines whose model does not
+	// resolve fail individually when the batch runs.
+	providers := make(map[string]bool)
+	for _, line := range reqs {
+		if target, err := resolveServeBatchTarget(s.cfgRef, line.Body); err == nil {
+			providers[target.Provider] = true
+		}
+	}
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := checkAPIKeyProvider(r.Context(), name); err != nil {
+			writeOpenAIError(w, http.StatusForbidden, "permission_error", err.Error())
+			return
+		}
+	}
+
+	bc := jobs.BatchConfig{
+		InputFileID:      input.ID,
+		Endpoint:         req.Endpoint,
+		CompletionWindow: "24h",
+		Metadata:         req.Metadata,
+		Native:           req.Native,
+	}
+	if grant := serveAPIKeyFromContext(r.Context()); grant != nil {
+		bc.APIKeyID, bc.APIKeyName = grant.key.ID, grant.key.Name
+	}
+	runnerConfig, err := json.Marshal(bc)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	job, err := s.jobsV2.CreateJob(jobsV2Job{
+		Name:           fmt.Sprintf("batch %s (%s)", input.Filename, randomSuffix()),
+		Enabled:        true,
+		RunnerType:     jobsV2RunnerBatch,
+		RunnerConfig:   runnerConfig,
+		TriggerType:    jobsV2TriggerManual,
+		TriggerConfig:  json.RawMessage(`{}`),
+		RetryPolicy:    serveBatchRetryPolicy,
+		TimeoutSeconds: int((serveBatchCompletionWindow + time.Hour) / time.Second),
+	})
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	run, err := s.jobsV2.TriggerJob(job.ID)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	job.LastRun = &run
+	writeJSON(w, http.StatusOK, serveBatchFromJob(job))
+}
+
+// handleBatchByID serves GET /v1/batches/{id} and POST
+// /v1/batches/{id}/cancel.
+func (s *serveServer) handleBatchByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1/batches/"), "/"), "/")
+	if parts[0] == "" || len(parts) > 2 || (len(parts) == 2 && parts[1] != "cancel") {
+		http.NotFound(w, r)
+		return
+	}
+	job, err := s.jobsV2.GetJob(parts[0])
+	if err == nil && job.RunnerType == jobsV2RunnerBatch {
+		var bc jobs.BatchConfig
+		_ = json.Unmarshal(job.RunnerConfig, &bc)
+		if !canAccessBatchResource(r, bc.APIKeyID) {
+			err = batch.ErrNotFound
+		}
+	}
+	if err != nil || job.RunnerType != jobsV2RunnerBatch {
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "batch not found")
+		return
+	}
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		writeJSON(w, http.StatusOK, s.batchFromJob(job))
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	if job.LastRun != nil {
+		run, err := s.jobsV2.CancelRun(job.LastRun.ID)
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+		job.LastRun = &run
+	}
+	writeJSON(w, http.StatusOK, serveBatchFromJob(job))
+}
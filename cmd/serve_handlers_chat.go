@@ -60,6 +60,10 @@ func (s *serveServer) handleChatCompletions(w http.ResponseWriter, r *http.Reque
 	if !stateful {
 		defer runtime.Close()
 	}
+	if err := s.checkAPIKeyRun(ctx, runtimeProviderKey(runtime)); err != nil {
+		writeOpenAIError(w, http.StatusForbidden, "permission_error", err.Error())
+		return
+	}
 
 	populateMissingToolResultNames(messages, runtime.snapshotHistory())
 
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/samsaffron/term-llm/internal/llm"
+)
+
+// responsesInputTokensRequest is the body of POST /v1/responses/input_tokens.
+type responsesInputTokensRequest struct {
+	Model              string            `json:"model"`
+	Instructions       string            `json:"instructions,omitempty"`
+	Input              json.RawMessage   `json:"input"`
+	Tools              []json.RawMessage `json:"tools,omitempty"`
+	IncludeServerTools bool              `json:"include_server_tools,omitempty"`
+}
+
+// handleAnthropicCountTokens implements POST /v1/messages/count_tokens. The
+// body is a Messages request; the count covers what the server would send to
+// its provider, including the server system prompt and requested server tools.
+func (s *serveServer) handleAnthropicCountTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeAnthropicError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	if err := requireJSONContentType(r); err != nil {
+		writeAnthropicError(w, http.StatusUnsupportedMediaType, "invalid_request_error", err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.responseTimeout())
+	defer cancel()
+
+	var req anthropicMessagesRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if len(req.Messages) == 0 {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", "messages is required")
+		return
+	}
+	messages, err := parseAnthropicMessages(req.Messages)
+	if err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	// A stateless runtime reflects the server's provider, system prompt and
+	// tools without touching any session.
+	runtime, _, err := s.runtimeForRequest(ctx, "")
+	if err != nil {
+		writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	defer runtime.Close()
+	if err := s.checkAPIKeyRun(ctx, runtimeProviderKey(runtime)); err != nil {
+		writeAnthropicError(w, http.StatusForbidden, "permission_error", err.Error())
+		return
+	}
+
+	// Like /v1/messages, the client's model name is not forwarded: the
+	// server's provider answers with its configured model.
+	breakdown, err := countServeTokens(ctx, runtime, "", parseAnthropicSystem(req.System), messages, anthropicRequestTools(runtime, req.Tools))
+	if err != nil {
+		writeAnthropicError(w, http.StatusBadGateway, "api_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"input_tokens": breakdown.Total,
+		"breakdown":    breakdown,
+	})
+}
+
+// handleResponsesInputTokens implements POST /v1/responses/input_tokens, the
+// OpenAI Responses equivalent of /v1/messages/count_tokens.
+func (s *serveServer) handleResponsesInputTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	if err := requireJSONContentType(r); err != nil {
+		writeOpenAIError(w, http.StatusUnsupportedMediaType, "invalid_request_error", err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), s.responseTimeout())
+	defer cancel()
+
+	var req responsesInputTokensRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	inputMessages, _, err := parseResponsesInput(req.Input)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	// A stateless runtime reflects the server's provider, system prompt and
+	// tools without touching any session.
+	runtime, _, err := s.runtimeForRequest(ctx, "")
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	defer runtime.Close()
+	if err := s.checkAPIKeyRun(ctx, runtimeProviderKey(runtime)); err != nil {
+		writeOpenAIError(w, http.StatusForbidden, "permission_error", err.Error())
+		return
+	}
+
+	// System input items join instructions as the system prompt, matching
+	// how providers receive them.
+	system := strings.TrimSpace(req.Instructions)
+	messages := make([]llm.Message, 0, len(inputMessages))
+	for _, msg := range inputMessages {
+		if msg.Role != llm.RoleSystem {
+			messages = append(messages, msg)
+			continue
+		}
+		if text := strings.TrimSpace(llm.MessageText(msg)); text != "" {
+			system = strings.TrimSpace(system + "\n\n" + text)
+		}
+	}
+	_, _, requestedTools, passthroughTools := parseRequestedTools(req.Tools)
+	serverTools := responseServerTools(runtime, requestedTools, req.IncludeServerTools)
+	tools := appendResponsePassthroughTools(serverTools, passthroughTools, runtime.toolMap)
+
+	breakdown, err := countServeTokens(ctx, runtime, strings.TrimSpace(req.Model), system, messages, tools)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadGateway, "server_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object":       "response.input_tokens",
+		"input_tokens": breakdown.Total,
+		"breakdown":    breakdown,
+	})
+}
+
+// countServeTokens counts a request the way runtime would send it: the
+// request's system prompt replaces the server's when present.
+func countServeTokens(ctx context.Context, runtime *serveRuntime, model, system string, messages []llm.Message, tools []llm.ToolSpec) (llm.TokenBreakdown, error) {
+	if system == "" {
+		system = runtime.systemPrompt
+	}
+	return llm.CountTokenBreakdown(ctx, runtime.provider, llm.TokenCountInput{
+		Model:    model,
+		System:   system,
+		Tools:    tools,
+		Messages: messages,
+	})
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type countTokensResponse struct {
+	Object      string `json:"object"`
+	InputTokens int    `json:"input_tokens"`
+	Breakdown   struct {
+		System   int  `json:"system"`
+		Tools    int  `json:"tools"`
+		Messages int  `json:"messages"`
+		Total    int  `json:"total"`
+		Exact    bool `json:"exact"`
+	} `json:"breakdown"`
+}
+
+func postCountTokens(t *testing.T, h http.Handler, path, body string) countTokensResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("%s status = %d, want 200; body: %s", path, rr.Code, rr.Body.String())
+	}
+	var out countTokensResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return out
+}
+
+func TestHandleAnthropicCountTokens(t *testing.T) {
+	srv := newTestServeServerWithToolMap(nil)
+	h := srv.httpHandler()
+
+	plain := postCountTokens(t, h, "/v1/messages/count_tokens", `{
+		"model": "test",
+		"system": "Be brief and precise.",
+		"messages": [{"role": "user", "content": "How many tokens is this?"}]
+	}`)
+	if plain.Breakdown.Exact {
+		t.Fatal("mock provider has no counter; breakdown should be estimated")
+	}
+	if plain.Breakdown.System == 0 || plain.Breakdown.Messages == 0 || plain.Breakdown.Tools != 0 {
+		t.Fatalf("breakdown = %+v", plain.Breakdown)
+	}
+	if plain.InputTokens != plain.Breakdown.Total {
+		t.Fatalf("input_tokens = %d, total = %d", plain.InputTokens, plain.Breakdown.Total)
+	}
+
+	withTools := postCountTokens(t, h, "/v1/messages/count_tokens", `{
+		"model": "test",
+		"system": "Be brief and precise.",
+		"messages": [{"role": "user", "content": "How many tokens is this?"}],
+		"tools": [
+			{"name": "echo", "input_schema": {"type": "object"}},
+			{"name": "client_tool", "description": "Client tool", "input_schema": {"type": "object", "properties": {"q": {"type": "string"}}}}
+		]
+	}`)
+	if withTools.Breakdown.Tools == 0 || withTools.InputTokens <= plain.InputTokens {
+		t.Fatalf("tools not counted: plain=%+v withTools=%+v", plain.Breakdown, withTools.Breakdown)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages/count_tokens", strings.NewReader(`{"model":"test","messages":[]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("empty messages status = %d, want 400", rr.Code)
+	}
+}
+
+func TestHandleResponsesInputTokens(t *testing.T) {
+	srv := newTestServeServerWithToolMap(nil)
+	h := srv.httpHandler()
+
+	out := postCountTokens(t, h, "/v1/responses/input_tokens", `{
+		"model": "mock-model",
+		"instructions": "Be brief.",
+		"input": [
+			{"type": "message", "role": "system", "content": "Answer in English."},
+			{"type": "message", "role": "user", "content": "How many tokens is this?"}
+		],
+		"include_server_tools": true
+	}`)
+	if out.Object != "response.input_tokens" {
+		t.Fatalf("object = %q", out.Object)
+	}
+	if out.Breakdown.System == 0 || out.Breakdown.Messages == 0 || out.Breakdown.Tools == 0 {
+		t.Fatalf("breakdown = %+v", out.Breakdown)
+	}
+	if out.InputTokens != out.Breakdown.Total {
+		t.Fatalf("input_tokens = %d, total = %d", out.InputTokens, out.Breakdown.Total)
+	}
+}
@@ -367,6 +367,10 @@ func (s *serveServer) handleResolvedResponses(w http.ResponseWriter, r *http.Req
 		req.Model = swapPlan.requestedModel
 		req.ReasoningEffort = swapPlan.requestedEffort
 	}
+	if err := s.checkAPIKeyRun(ctx, firstNonEmpty(reqProvider, defaultProvider)); err != nil {
+		writeOpenAIError(w, http.StatusForbidden, "permission_error", err.Error())
+		return
+	}
 
 	handleRuntimeErr := func(err error) bool {
 		if err == nil {
@@ -42,6 +42,9 @@ type hubServer struct {
 	// delegations is the cross-node delegation ledger; nil disables the
 	// /api/delegations endpoints.
 	delegations *hub.DelegationStore
+	// mdns is the mDNS resolver when --mdns is set; it also vouches for direct
+	// nodes self-registering their token. Nil disables direct registration.
+	mdns *hub.MDNSResolver
 	// nodeAPIClient performs hub -> node jobs API calls for delegations. It
 	// shares the proxy's direct-dial transport (no env proxy: requests carry
 	// node tokens) but, unlike streaming proxy traffic, gets a whole-request
@@ -53,6 +56,11 @@ type hubServer struct {
 	token             string
 	registrationToken string
 	passkey           *hubPasskeyRuntime
+	// metrics serves /metrics when --metrics is set; nil otherwise.
+	metrics *hubMetrics
+	// audit records proxy, registration, delegation and auth activity; nil
+	// disables auditing and /api/audit.
+	audit *hub.AuditLog
 }
 
 func newHubServer(registry *hub.Registry, store *hub.Store) *hubServer {
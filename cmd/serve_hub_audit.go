@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/hub"
+	"github.com/samsaffron/term-llm/internal/passkeyauth"
+	"github.com/spf13/cobra"
+)
+
+// Hub audit log: every proxied node request, node add/remove/registration,
+// reverse connection, delegation create/cancel, federated search, passkey
+// account change, and any denied request is recorded as one JSON line in
+// <data-dir>/hub/audit.jsonl (rotated by size). Reads the dashboard polls
+// (node and delegation lists) and static assets fetched through the proxy
+// are not recorded. Query it with GET /api/audit, the dashboard's Audit
+// panel, or `serve hub audit`.
+
+const (
+	hubAuditDefaultLimit = 100
+	hubAuditMaxLimit     = 1000
+)
+
+// hubAuditState travels on the request context so inner layers can fill in
+// what the audit middleware cannot see from outside: the authenticated
+// principal and the delegation a create produced.
+type hubAuditState struct {
+	principal    *passkeyauth.Principal
+	node         string
+	delegationID string
+}
+
+type hubAuditStateKey struct{}
+
+func hubAuditStateFrom(ctx context.Context) *hubAuditState {
+	st, _ := ctx.Value(hubAuditStateKey{}).(*hubAuditState)
+	return st
+}
+
+// noteHubAuditDelegation records the delegation (and its target node) a
+// request acted on.
+func noteHubAuditDelegation(ctx context.Context, d hub.Delegation) {
+	if st := hubAuditStateFrom(ctx); st != nil {
+		st.delegationID = d.ID
+		st.node = d.TargetNode
+	}
+}
+
+// auditMiddleware records audited requests after they complete. It sits
+// outside auth so denied requests are recorded too.
+func (s *hubServer) auditMiddleware(next http.Handler) http.Handler {
+	if s.audit == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := &hubAuditState{}
+		r = r.WithContext(context.WithValue(r.Context(), hubAuditStateKey{}, st))
+		start := time.Now()
+		rec := &serveMetricsRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+			if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				status = http.StatusSwitchingProtocols
+			}
+		}
+		action, node, ok := hubAuditAction(r)
+		if !ok && status != http.StatusUnauthorized && status != http.StatusForbidden {
+			return
+		}
+		if !ok {
+			action = "request"
+		}
+		if st.node != "" {
+			node = st.node
+		}
+		e := hub.AuditEvent{
+			Time:         start,
+			Actor:        s.hubAuditActor(r, st),
+			Action:       action,
+			Method:       r.Method,
+			Route:        r.URL.Path,
+			Node:         node,
+			DelegationID: st.delegationID,
+			Status:       status,
+			RemoteAddr:   hubAuditRemoteAddr(r),
+			DurationMS:   time.Since(start).Milliseconds(),
+		}
+		if err := s.audit.Record(e); err != nil {
+			log.Printf("hub audit: %v", err)
+		}
+	})
+}
+
+// hubAuditAction classifies a request path (already stripped of the base
+// path). ok is false for routes that are only recorded when denied.
+func hubAuditAction(r *http.Request) (action, node string, ok bool) {
+	p := r.URL.Path
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+	switch {
+	case strings.HasPrefix(p, "/node/"):
+		rest := strings.TrimPrefix(p, "/node/")
+		node, sub, _ := strings.Cut(rest, "/")
+		if readOnly && hubAuditStaticAsset(sub) {
+			return "", node, false
+		}
+		return "proxy", node, true
+	case p == "/api/nodes" && r.Method == http.MethodPost:
+		return "node.add", "", true
+	case p == "/api/nodes/test" && r.Method == http.MethodPost:
+		return "node.test", "", true
+	case strings.HasPrefix(p, "/api/nodes/") && r.Method == http.MethodDelete:
+		return "node.remove", strings.TrimPrefix(p, "/api/nodes/"), true
+	case p == "/api/register-node":
+		return "node.register", "", !readOnly
+	case strings.HasPrefix(p, "/api/register-node/"):
+		return "node.unregister", strings.TrimPrefix(p, "/api/register-node/"), !readOnly
+	case p == "/api/connect":
+		return "node.connect", strings.TrimSpace(r.Header.Get(hubNodeIDHeader)), true
+	case p == "/api/delegations" && r.Method == http.MethodPost:
+		return "delegation.create", "", true
+	case strings.HasPrefix(p, "/api/delegations/") && strings.HasSuffix(p, "/cancel"):
+		return "delegation.cancel", "", true
+	case p == "/api/search":
+		return "search", "", true
+	case strings.HasPrefix(p, "/api/auth/") && !readOnly:
+		return "auth." + strings.ReplaceAll(strings.Trim(strings.TrimPrefix(p, "/api/auth/"), "/"), "/", "."), "", true
+	}
+	return "", "", false
+}
+
+// hubAuditStaticAsset reports whether a proxied path is a static asset the
+// node UI loads on every page view.
+func hubAuditStaticAsset(p string) bool {
+	switch strings.ToLower(path.Ext(p)) {
+	case ".js", ".mjs", ".css", ".map", ".png", ".jpg", ".jpeg", ".gif", ".svg", ".ico", ".webp", ".woff", ".woff2", ".ttf", ".webmanifest":
+		return true
+	}
+	return false
+}
+
+func (s *hubServer) hubAuditActor(r *http.Request, st *hubAuditState) string {
+	if st.principal != nil {
+		if st.principal.SessionID == "" {
+			return "hub-token"
+		}
+		id := st.principal.CredentialRecordID
+		if s.passkey != nil {
+			for _, c := range s.passkey.store.Credentials() {
+				if c.RecordID == id && strings.TrimSpace(c.DisplayName) != "" {
+					return "passkey:" + c.DisplayName
+				}
+			}
+		}
+		return "passkey:" + id
+	}
+	if nodeID := strings.TrimSpace(r.Header.Get(hubNodeIDHeader)); nodeID != "" && hubNodeAuthRoute(r) {
+		return "node:" + nodeID
+	}
+	if hubRegistrationRoute(r) {
+		return "registration"
+	}
+	if s.authMode != "passkey" && s.requireAuth && hubBearerTokenMatches(r, s.token) {
+		return "hub-token"
+	}
+	return "anonymous"
+}
+
+func hubAuditRemoteAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (s *hubServer) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.audit == nil {
+		http.Error(w, "audit log is disabled", http.StatusServiceUnavailable)
+		return
+	}
+	query := r.URL.Query()
+	q := hub.AuditQuery{
+		Actor:   strings.TrimSpace(query.Get("actor")),
+		Action:  strings.TrimSpace(query.Get("action")),
+		Node:    strings.TrimSpace(query.Get("node")),
+		Route:   strings.TrimSpace(query.Get("route")),
+		Outcome: strings.TrimSpace(query.Get("outcome")),
+		Limit:   hubAuditDefaultLimit,
+	}
+	if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		q.Limit = min(n, hubAuditMaxLimit)
+	}
+	if raw := strings.TrimSpace(query.Get("since")); raw != "" {
+		since, err := parseHubAuditSince(raw, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q.Since = since
+	}
+	events, err := s.audit.Query(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []hub.AuditEvent{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"events": events})
+}
+
+// parseHubAuditSince accepts a lookback duration ("24h") or an RFC 3339
+// timestamp.
+func parseHubAuditSince(raw string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(raw); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q (expected a duration like 24h or an RFC 3339 time)", raw)
+}
+
+// defaultHubAuditFile is where the audit log lives when --audit-log is not
+// given: beside the node store.
+func defaultHubAuditFile(nodesFile string) string {
+	return filepath.Join(filepath.Dir(nodesFile), "audit.jsonl")
+}
+
+var (
+	serveHubAuditFile    string
+	serveHubAuditSince   time.Duration
+	serveHubAuditActor   string
+	serveHubAuditAction  string
+	serveHubAuditNode    string
+	serveHubAuditOutcome string
+	serveHubAuditLimit   int
+	serveHubAuditJSON    bool
+)
+
+var serveHubAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query the hub audit log",
+	Long: `Query the hub audit log: who proxied into which node, registered or
+removed nodes, started or cancelled delegations, searched sessions, changed
+passkeys, or was denied. Reads the log files directly, so the hub does not
+need to be running.
+
+Examples:
+  term-llm serve hub audit
+  term-llm serve hub audit --since 24h --outcome denied
+  term-llm serve hub audit --node jarvis --action proxy --limit 20
+  term-llm serve hub audit --actor passkey: --json`,
+	Args: cobra.NoArgs,
+	RunE: runServeHubAudit,
+}
+
+func init() {
+	serveHubCmd.AddCommand(serveHubAuditCmd)
+	serveHubAuditCmd.Flags().StringVar(&serveHubAuditFile, "file", "", "Audit log path (default: <data-dir>/hub/audit.jsonl)")
+	serveHubAuditCmd.Flags().DurationVar(&serveHubAuditSince, "since", 0, "Only events within this duration (e.g. 24h)")
+	serveHubAuditCmd.Flags().StringVar(&serveHubAuditActor, "actor", "", "Only events whose actor contains this text (e.g. passkey:, node:jarvis)")
+	serveHubAuditCmd.Flags().StringVar(&serveHubAuditAction, "action", "", "Only this action or action family (e.g. proxy, node, delegation.create)")
+	serveHubAuditCmd.Flags().StringVar(&serveHubAuditNode, "node", "", "Only events for this node id")
+	serveHubAuditCmd.Flags().StringVar(&serveHubAuditOutcome, "outcome", "", "Only this outcome: ok, denied or error")
+	serveHubAuditCmd.Flags().IntVar(&serveHubAuditLimit, "limit", 50, "Maximum events to show, newest first (0 = all)")
+	serveHubAuditCmd.Flags().BoolVar(&serveHubAuditJSON, "json", false, "Output as JSON")
+}
+
+func runServeHubAudit(cmd *cobra.Command, args []string) error {
+	switch serveHubAuditOutcome {
+	case "", hub.AuditOutcomeOK, hub.AuditOutcomeDenied, hub.AuditOutcomeError:
+	default:
+		return fmt.Errorf("invalid --outcome %q (expected ok, denied or error)", serveHubAuditOutcome)
+	}
+	file := strings.TrimSpace(serveHubAuditFile)
+	if file == "" {
+		nodesFile, err := defaultHubNodesFile()
+		if err != nil {
+			return fmt.Errorf("resolve hub audit log: %w", err)
+		}
+		file = defaultHubAuditFile(nodesFile)
+	}
+	q := hub.AuditQuery{
+		Actor:   serveHubAuditActor,
+		Action:  serveHubAuditAction,
+		Node:    serveHubAuditNode,
+		Outcome: serveHubAuditOutcome,
+		Limit:   serveHubAuditLimit,
+	}
+	if serveHubAuditSince > 0 {
+		q.Since = time.Now().Add(-serveHubAuditSince)
+	}
+	events, err := hub.ReadAuditLog(file, 0, q)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if serveHubAuditJSON {
+		if events == nil {
+			events = []hub.AuditEvent{}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(events)
+	}
+	if len(events) == 0 {
+		fmt.Fprintf(out, "No audit events in %s.\n", file)
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tACTOR\tACTION\tNODE\tROUTE\tSTATUS\tOUTCOME")
+	for _, e := range events {
+		node := e.Node
+		if e.DelegationID != "" {
+			node += " (" + e.DelegationID + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s %s\t%d\t%s\n",
+			e.Time.Local().Format(time.DateTime), e.Actor, e.Action, node, e.Method, e.Route, e.Status, e.Outcome)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/samsaffron/term-llm/internal/hub"
+)
+
+func TestHubAuditRecordsProxyDeniedAndSkipsPolls(t *testing.T) {
+	s := hubWithBackend(t, "/chat", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/healthz") {
+			io.WriteString(w, `{"status":"ok"}`)
+			return
+		}
+		io.WriteString(w, "ok")
+	})
+	s.requireAuth = true
+	s.token = "hub-secret"
+	s.audit = hub.NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	t.Cleanup(func() { s.audit.Close() })
+	h := s.handler()
+
+	do := func(method, path string, authed bool) {
+		req := httptest.NewRequest(method, path, nil)
+		if authed {
+			req.Header.Set("Authorization", "Bearer hub-secret")
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	do(http.MethodGet, "/node/alpha/v1/models", true)
+	do(http.MethodGet, "/node/alpha/assets/app.js", true)
+	do(http.MethodGet, "/api/nodes", true)
+	do(http.MethodGet, "/api/nodes", false)
+
+	events, err := s.audit.Query(hub.AuditQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %+v, want proxy + denied only", events)
+	}
+	denied, proxy := events[0], events[1]
+	if proxy.Action != "proxy" || proxy.Node != "alpha" || proxy.Actor != "hub-token" || proxy.Status != http.StatusOK || proxy.Outcome != hub.AuditOutcomeOK {
+		t.Fatalf("proxy event = %+v", proxy)
+	}
+	if denied.Route != "/api/nodes" || denied.Actor != "anonymous" || denied.Outcome != hub.AuditOutcomeDenied {
+		t.Fatalf("denied event = %+v", denied)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/audit?outcome=denied", nil)
+	req.Header.Set("Authorization", "Bearer hub-secret")
+	h.ServeHTTP(rec, req)
+	var resp struct {
+		Events []hub.AuditEvent `json:"events"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/audit = %d %s", rec.Code, rec.Body.String())
+	}
+	if len(resp.Events) != 1 || resp.Events[0].Route != "/api/nodes" {
+		t.Fatalf("/api/audit events = %+v", resp.Events)
+	}
+	if strings.Contains(rec.Body.String(), "hub-secret") || strings.Contains(rec.Body.String(), "tkn-123") {
+		t.Fatal("audit response leaked a token")
+	}
+}
+
+func TestHubAuditRecordsDelegations(t *testing.T) {
+	s, _ := newDelegationHub(t)
+	s.audit = hub.NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	t.Cleanup(func() { s.audit.Close() })
+
+	_, d := createDelegation(t, s, "alpha", "alpha-token", map[string]any{"target_node": "beta", "prompt": "x"})
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, delegationRequest(http.MethodPost, "/api/delegations/"+d.ID+"/cancel", "gamma", "gamma-token", map[string]any{}))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("foreign cancel status = %d", rec.Code)
+	}
+
+	events, err := s.audit.Query(hub.AuditQuery{Action: "delegation"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("delegation events = %+v", events)
+	}
+	cancel, create := events[0], events[1]
+	if create.Action != "delegation.create" || create.Actor != "node:alpha" || create.DelegationID != d.ID || create.Node != "beta" {
+		t.Fatalf("create event = %+v", create)
+	}
+	if cancel.Action != "delegation.cancel" || cancel.Actor != "node:gamma" || cancel.DelegationID != d.ID || cancel.Outcome != hub.AuditOutcomeDenied {
+		t.Fatalf("cancel event = %+v", cancel)
+	}
+}
+
+func TestServeHubAuditCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l := hub.NewAuditLog(path, 0, 0)
+	_ = l.Record(hub.AuditEvent{Actor: "hub-token", Action: "proxy", Node: "alpha", Method: "GET", Route: "/node/alpha/", Status: 200})
+	_ = l.Record(hub.AuditEvent{Actor: "anonymous", Action: "request", Method: "GET", Route: "/api/nodes", Status: 401})
+	l.Close()
+
+	oldFile, oldOutcome := serveHubAuditFile, serveHubAuditOutcome
+	t.Cleanup(func() { serveHubAuditFile, serveHubAuditOutcome = oldFile, oldOutcome })
+	serveHubAuditFile = path
+	serveHubAuditOutcome = "denied"
+
+	var out bytes.Buffer
+	serveHubAuditCmd.SetOut(&out)
+	t.Cleanup(func() { serveHubAuditCmd.SetOut(nil) })
+	if err := runServeHubAudit(serveHubAuditCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "/api/nodes") || strings.Contains(out.String(), "/node/alpha/") {
+		t.Fatalf("output = %s", out.String())
+	}
+
+	serveHubAuditOutcome = "bogus"
+	if err := runServeHubAudit(serveHubAuditCmd, nil); err == nil {
+		t.Fatal("expected invalid --outcome error")
+	}
+}
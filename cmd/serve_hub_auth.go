@@ -8,6 +8,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/samsaffron/term-llm/internal/tracing"
 )
 
 const hubAuthCookieName = "term_llm_hub_token"
@@ -18,6 +20,9 @@ func (s *hubServer) handler() http.Handler {
 		s.registerPasskeyRoutes(mux)
 	}
 	mux.HandleFunc("/healthz", s.handleHubHealth)
+	if s.metrics != nil {
+		mux.Handle("/metrics", s.metrics.registry.Handler())
+	}
 	mux.HandleFunc("/api/nodes/test", s.handleTestNode)
 	mux.HandleFunc("/api/registration-info", s.handleRegistrationInfo)
 	mux.HandleFunc("/api/register-node/", s.handleRegisterNode)
@@ -26,10 +31,12 @@ func (s *hubServer) handler() http.Handler {
 	mux.HandleFunc("/api/nodes", s.handleNodes)
 	mux.HandleFunc("/api/delegations/", s.handleDelegationItem)
 	mux.HandleFunc("/api/delegations", s.handleDelegations)
+	mux.HandleFunc("/api/search", s.handleSearch)
+	mux.HandleFunc("/api/audit", s.handleAudit)
 	mux.HandleFunc("/api/connect", s.handleReverseConnect)
 	mux.HandleFunc("/node/", s.handleNodeProxy)
 	mux.HandleFunc("/", s.handleIndex)
-	return s.mountBasePath(s.auth(mux))
+	return tracing.Middleware(s.mountBasePath(s.auditMiddleware(s.auth(mux))))
 }
 
 func (s *hubServer) auth(next http.Handler) http.Handler {
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"net"
 	"net/http"
 	"net/url"
@@ -24,6 +25,7 @@ var (
 	serveHubPort                  int
 	serveHubConfig                string
 	serveHubContain               bool
+	serveHubMDNS                  bool
 	serveHubNodesFile             string
 	serveHubAuthMode              string
 	serveHubToken                 string
@@ -35,6 +37,10 @@ var (
 	serveHubRecoveryTokenFile     string
 	serveHubPrintBootstrapToken   bool
 	serveHubPasskeyTrustedProxies []string
+	serveHubMetrics               bool
+	serveHubWebhooksDB            string
+	serveHubAuditLog              string
+	serveHubAuditMaxMB            int
 )
 
 var serveHubCmd = &cobra.Command{
@@ -42,8 +48,9 @@ var serveHubCmd = &cobra.Command{
 	Short: "Run the term-llm Hub: one dashboard over many term-llm web nodes",
 	Long: `Run the term-llm Hub, a launcher and control plane over many term-llm web
 nodes (serves). Nodes are discovered from a static config file (--config),
-from local contain workspaces, and from nodes added in the dashboard UI
-(persisted to a local JSON store).
+from local contain workspaces, from nodes added in the dashboard UI
+(persisted to a local JSON store), and with --mdns from nodes advertising
+themselves on the local network (serve --hub-advertise).
 
 The dashboard lists every node with live reachability, latency, and any
 detected agent/version/capabilities, and opens a node's full web UI through
@@ -56,14 +63,17 @@ Routes (root-mounted by default; when --base-path is set, prefix each route):
   POST /api/nodes         add a node to the local store
   DELETE /api/nodes/<id>  remove a local-store node
   POST /api/nodes/test    probe a node spec without persisting it
-  POST /api/register-node register/update a reverse node (registration token)
-  DELETE /api/register-node/<id> deregister a reverse node (registration token)
+  POST /api/register-node register/update a reverse or mDNS node (registration token)
+  DELETE /api/register-node/<id> deregister a registered node (registration token)
   GET  /api/connect       reverse-node websocket endpoint (node auth)
   ANY  /node/<id>/...     reverse proxy to that node's serve
   POST /api/delegations   create a cross-node delegation (node auth)
   GET  /api/delegations   list delegations
   GET  /api/delegations/<id>         delegation status
   POST /api/delegations/<id>/cancel  cancel (originating node only)
+  GET  /api/search        search sessions on every reachable node
+  GET  /api/audit         query the audit log (also: serve hub audit)
+  GET  /metrics           node health in Prometheus format (with --metrics)
 
 Config file (--config), YAML or JSON:
   nodes:
@@ -194,8 +204,21 @@ func runServeHub(cmd *cobra.Command, args []string) error {
 	if serveHubContain {
 		resolvers = append(resolvers, hub.NewContainResolver())
 	}
+	// mDNS resolves last: configured, stored and contain nodes all win over an
+	// advertisement claiming the same ID.
+	var mdnsResolver *hub.MDNSResolver
+	if serveHubMDNS {
+		mdnsResolver = hub.NewMDNSResolver()
+		resolvers = append(resolvers, mdnsResolver)
+		go func() {
+			if err := mdnsResolver.Run(cmd.Context()); err != nil {
+				log.Printf("hub mdns: %v", err)
+			}
+		}()
+	}
 
 	s := newHubServer(hub.NewRegistry(resolvers...), store)
+	s.mdns = mdnsResolver
 	s.requireAuth = requireAuth
 	s.authMode = authMode
 	s.token = token
@@ -203,6 +226,37 @@ func runServeHub(cmd *cobra.Command, args []string) error {
 	s.basePath = hubBasePath
 	// The delegation ledger lives beside the node store (same private dir).
 	s.delegations = hub.NewDelegationStore(filepath.Join(filepath.Dir(nodesFile), "delegations.json"))
+	if serveHubMetrics {
+		s.metrics = newHubMetrics(s)
+	}
+	if auditFile := strings.TrimSpace(serveHubAuditLog); auditFile != "off" {
+		if auditFile == "" {
+			auditFile = defaultHubAuditFile(nodesFile)
+		}
+		s.audit = hub.NewAuditLog(auditFile, int64(serveHubAuditMaxMB)<<20, hub.DefaultAuditMaxFiles)
+		defer s.audit.Close()
+	}
+	// Delegation webhooks use the same serve.webhooks endpoints as the nodes
+	// but a separate delivery log, so a node on this machine never picks up
+	// the hub's deliveries. An unreadable config only disables them.
+	var webhookCount int
+	if cfg, err := loadConfig(); err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "warning: webhooks disabled: %v\n", err)
+	} else {
+		webhooksDB := strings.TrimSpace(serveHubWebhooksDB)
+		if webhooksDB == "" {
+			webhooksDB = filepath.Join(filepath.Dir(nodesFile), "webhooks.db")
+		}
+		dispatcher, stopWebhooks, err := startServeWebhooks(cmd.Context(), cfg, webhooksDB)
+		if err != nil {
+			return err
+		}
+		defer stopWebhooks()
+		if dispatcher != nil {
+			s.delegations.SetObserver(delegationWebhookObserver(dispatcher))
+			webhookCount = len(cfg.Serve.Webhooks)
+		}
+	}
 	bootstrapDisplay := ""
 	if authMode == "passkey" {
 		authFile := strings.TrimSpace(serveHubPasskeyAuthFile)
@@ -292,6 +346,15 @@ func runServeHub(cmd *cobra.Command, args []string) error {
 	if s.registrationToken != "" {
 		fmt.Fprintln(out, "  registration: enabled")
 	}
+	if s.mdns != nil {
+		fmt.Fprintf(out, "  mdns: browsing %s\n", hub.MDNSServiceType)
+	}
+	if webhookCount > 0 {
+		fmt.Fprintf(out, "  webhooks: %d endpoints (delegation.updated)\n", webhookCount)
+	}
+	if s.audit != nil {
+		fmt.Fprintf(out, "  audit log: %s\n", s.audit.Path())
+	}
 	return srv.ListenAndServe()
 }
 
@@ -373,6 +436,7 @@ func init() {
 	serveHubCmd.Flags().IntVar(&serveHubPort, "port", 8090, "Port to bind")
 	serveHubCmd.Flags().StringVar(&serveHubConfig, "config", "", "Path to a static nodes config file (YAML or JSON)")
 	serveHubCmd.Flags().BoolVar(&serveHubContain, "contain", true, "Discover nodes from local contain workspaces")
+	serveHubCmd.Flags().BoolVar(&serveHubMDNS, "mdns", false, "Discover nodes advertising themselves on the local network over mDNS (serve --hub-advertise)")
 	serveHubCmd.Flags().StringVar(&serveHubNodesFile, "nodes-file", "", "Path to the JSON store for dashboard-added nodes (default: <data-dir>/hub/nodes.json)")
 	serveHubCmd.Flags().StringVar(&serveHubAuthMode, "auth", "bearer", "Hub auth mode: bearer, passkey, or none (none is loopback-only)")
 	serveHubCmd.Flags().StringVar(&serveHubToken, "token", "", "Hub bearer token (auto-generated in bearer mode; optional explicit API token in passkey mode)")
@@ -383,5 +447,9 @@ func init() {
 	serveHubCmd.Flags().StringVar(&serveHubRecoveryTokenFile, "passkey-recovery-token-file", "", "Private file containing a short-lived passkey recovery secret")
 	serveHubCmd.Flags().BoolVar(&serveHubPrintBootstrapToken, "print-passkey-bootstrap-token", false, "Print a generated first-passkey setup code even when output is not interactive (unsafe for service logs)")
 	serveHubCmd.Flags().StringSliceVar(&serveHubPasskeyTrustedProxies, "passkey-trusted-proxy", nil, "Trusted reverse-proxy IP or CIDR allowed to supply X-Forwarded-For (repeatable)")
+	serveHubCmd.Flags().BoolVar(&serveHubMetrics, "metrics", false, "Expose Prometheus node health metrics at /metrics (behind hub auth)")
+	serveHubCmd.Flags().StringVar(&serveHubWebhooksDB, "webhooks-db", "", "Delegation webhook delivery log (default: <data-dir>/hub/webhooks.db; inspect with 'serve webhooks --db')")
+	serveHubCmd.Flags().StringVar(&serveHubAuditLog, "audit-log", "", "Audit log of proxy, registration, delegation and auth activity (default: <data-dir>/hub/audit.jsonl; \"off\" disables)")
+	serveHubCmd.Flags().IntVar(&serveHubAuditMaxMB, "audit-max-mb", 10, "Rotate the audit log at this size in MB, keeping 5 rotated files")
 	serveHubCmd.Flags().StringVar(&serveHubRegistrationTokenFlag, "registration-token", "", "Token that allows reverse nodes to self-register (defaults to $TERM_LLM_HUB_REGISTRATION_TOKEN; empty disables registration)")
 }
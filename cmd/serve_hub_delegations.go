@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/json"
@@ -13,6 +14,9 @@ import (
 	"time"
 
 	"github.com/samsaffron/term-llm/internal/hub"
+	"github.com/samsaffron/term-llm/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Cross-node delegation: a node asks the hub to run a prompt as a jobs-v2 LLM
@@ -23,7 +27,8 @@ import (
 //
 // Routes (see hubServer.handler):
 //
-//	POST /api/delegations             create + trigger (node auth)
+//	POST /api/delegations             create + trigger (node auth); without
+//	                                  target_node the hub selects the node(s)
 //	GET  /api/delegations             list (node auth or same-origin GET)
 //	GET  /api/delegations/{id}        status, refreshed from the target run
 //	POST /api/delegations/{id}/cancel cancel target run (origin node only)
@@ -40,8 +45,15 @@ const (
 	// In-flight caps: per-target comes from the node's delegation policy.
 	hubDelegationHubMaxInFlight    = 32
 	hubDelegationOriginMaxInFlight = 8
+
+	// hubDelegationMaxFanOut bounds how many nodes one request may fan out to.
+	hubDelegationMaxFanOut = 4
 )
 
+// hubTracer spans delegations; the delegating node's traceparent header is
+// their parent and the target job run continues from the job label.
+var hubTracer = tracing.Tracer("hub")
+
 // authenticateNode resolves the caller's node identity from the claimed node
 // id header plus bearer token. Failures are deliberately uniform so callers
 // cannot probe which node ids exist; nodes without a stored token can never
@@ -108,6 +120,12 @@ type hubDelegationCreateRequest struct {
 	// working on a delegated job) for depth/loop enforcement. Verified
 	// against the ledger.
 	ParentDelegationID string `json:"parent_delegation_id"`
+	// Labels, MaxQueueDepth and FanOut apply when TargetNode is empty: the
+	// hub picks the least-loaded eligible node(s) itself. FanOut > 1 starts
+	// the same prompt on that many nodes; the first to succeed wins.
+	Labels        []string `json:"labels"`
+	MaxQueueDepth *int     `json:"max_queue_depth"`
+	FanOut        int      `json:"fan_out"`
 }
 
 func (s *hubServer) handleDelegations(w http.ResponseWriter, r *http.Request) {
@@ -216,123 +234,249 @@ func (s *hubServer) handleCreateDelegation(w http.ResponseWriter, r *http.Reques
 	}
 	targetID := strings.TrimSpace(req.TargetNode)
 	prompt := strings.TrimSpace(req.Prompt)
-	if targetID == "" || prompt == "" {
-		http.Error(w, "target_node and prompt are required", http.StatusBadRequest)
+	if prompt == "" {
+		http.Error(w, "prompt is required", http.StatusBadRequest)
 		return
 	}
-	if targetID == origin.ID {
-		http.Error(w, "cannot delegate to the originating node", http.StatusBadRequest)
+	selecting := targetID == ""
+	if !selecting && (len(req.Labels) > 0 || req.MaxQueueDepth != nil || req.FanOut > 1) {
+		http.Error(w, "labels, max_queue_depth and fan_out let the hub choose the target; omit target_node to use them", http.StatusBadRequest)
 		return
 	}
-	if !origin.CanDelegateTo(targetID) {
-		http.Error(w, fmt.Sprintf("node %q may not delegate to %q", origin.ID, targetID), http.StatusForbidden)
+	if req.FanOut < 0 || req.FanOut > hubDelegationMaxFanOut {
+		http.Error(w, fmt.Sprintf("fan_out must be between 1 and %d", hubDelegationMaxFanOut), http.StatusBadRequest)
 		return
 	}
-	target, ok := s.registry.Lookup(targetID)
-	if !ok {
-		http.Error(w, fmt.Sprintf("unknown target node %q", targetID), http.StatusNotFound)
+	if req.MaxQueueDepth != nil && *req.MaxQueueDepth < 0 {
+		http.Error(w, "max_queue_depth must not be negative", http.StatusBadRequest)
 		return
 	}
-	if err := target.AcceptsDelegationFrom(origin.ID); err != nil {
-		http.Error(w, err.Error(), http.StatusForbidden)
-		return
+
+	timeout := req.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = hubDelegationDefaultTimeout
 	}
-	cwd, err := resolveDelegationCwd(target.DelegationWorkdir(), req.Cwd)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	if timeout < hubDelegationMinTimeout {
+		timeout = hubDelegationMinTimeout
 	}
+	if timeout > hubDelegationDefaultTimeout {
+		timeout = hubDelegationDefaultTimeout
+	}
+	agentName := strings.TrimSpace(req.AgentName)
+	if agentName == "" {
+		agentName = hubDelegationDefaultAgent
+	}
+	model := strings.TrimSpace(req.Model)
 
-	depth := 1
-	chain := []string{origin.ID, target.ID}
+	var parent *hub.Delegation
 	if parentID := strings.TrimSpace(req.ParentDelegationID); parentID != "" {
-		parent, found, err := s.delegations.Get(parentID)
+		found, ok, err := s.delegations.Get(parentID)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if !found {
+		if !ok {
 			http.Error(w, fmt.Sprintf("unknown parent_delegation_id %q", parentID), http.StatusBadRequest)
 			return
 		}
-		if parent.TargetNode != origin.ID {
+		if found.TargetNode != origin.ID {
 			http.Error(w, "parent_delegation_id does not belong to the delegating node", http.StatusForbidden)
 			return
 		}
-		for _, hop := range parent.Chain {
-			if hop == target.ID {
-				http.Error(w, fmt.Sprintf("delegation loop: node %q already appears in chain %v", target.ID, parent.Chain), http.StatusConflict)
+		parent = &found
+	}
+
+	var targets []hub.Node
+	if selecting {
+		requirements := hub.DelegationRequirements{
+			Agent:         agentName,
+			Model:         model,
+			Labels:        req.Labels,
+			MaxQueueDepth: req.MaxQueueDepth,
+		}
+		if parent != nil {
+			requirements.Exclude = parent.Chain
+		}
+		selected, err := s.selectDelegationTargets(r.Context(), origin, requirements, max(req.FanOut, 1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		targets = selected
+	} else {
+		if targetID == origin.ID {
+			http.Error(w, "cannot delegate to the originating node", http.StatusBadRequest)
+			return
+		}
+		if !origin.CanDelegateTo(targetID) {
+			http.Error(w, fmt.Sprintf("node %q may not delegate to %q", origin.ID, targetID), http.StatusForbidden)
+			return
+		}
+		target, ok := s.registry.Lookup(targetID)
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown target node %q", targetID), http.StatusNotFound)
+			return
+		}
+		targets = []hub.Node{target}
+	}
+
+	fanOut := len(targets) > 1
+	groupID := ""
+	if fanOut {
+		id, err := hub.NewDelegationID()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		groupID = "grp_" + strings.TrimPrefix(id, "dlg_")
+	}
+
+	var (
+		started  []hub.Delegation
+		firstErr error
+	)
+	for i, target := range targets {
+		d, status, err := s.planDelegation(r.Context(), origin, target, parent, req.Cwd, agentName, model)
+		if err != nil {
+			if i == 0 {
+				http.Error(w, err.Error(), status)
 				return
 			}
+			// Later fan-out members are best effort: a cap reached partway
+			// through just narrows the fan-out.
+			break
+		}
+		d.Prompt = prompt
+		d.GroupID = groupID
+		d, err = s.startDelegation(r.Context(), target, d, prompt, timeout)
+		if err != nil && !errors.Is(err, errDelegationNotStarted) && i == 0 && !fanOut {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("delegate to node %q: %w", target.ID, err)
+		}
+		if d.ID != "" {
+			started = append(started, d)
 		}
-		depth = parent.Depth + 1
-		chain = append(append([]string{}, parent.Chain...), target.ID)
 	}
-	if depth > hub.DefaultDelegationMaxDepth {
-		http.Error(w, fmt.Sprintf("delegation depth %d exceeds the maximum of %d", depth, hub.DefaultDelegationMaxDepth), http.StatusForbidden)
+
+	if !fanOut {
+		if firstErr != nil {
+			http.Error(w, firstErr.Error(), http.StatusBadGateway)
+			return
+		}
+		noteHubAuditDelegation(r.Context(), started[0])
+		writeJSON(w, http.StatusCreated, map[string]any{"delegation": started[0]})
 		return
 	}
-
-	if err := s.checkDelegationCaps(r.Context(), origin, target); err != nil {
-		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	winner := -1
+	for i, d := range started {
+		if d.Status != hub.DelegationStatusError {
+			winner = i
+			break
+		}
+	}
+	if winner < 0 {
+		msg := "fan-out delegation failed on every node"
+		if firstErr != nil {
+			msg = firstErr.Error()
+		}
+		http.Error(w, msg, http.StatusBadGateway)
 		return
 	}
+	noteHubAuditDelegation(r.Context(), started[winner])
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"delegation":  started[winner],
+		"delegations": started,
+		"group_id":    groupID,
+	})
+}
 
-	timeout := req.TimeoutSeconds
-	if timeout <= 0 {
-		timeout = hubDelegationDefaultTimeout
+// planDelegation validates one target for a delegation from origin and
+// returns the pending record to start, with the HTTP status for a refusal.
+// The record's prompt and group are filled in by the caller.
+func (s *hubServer) planDelegation(ctx context.Context, origin, target hub.Node, parent *hub.Delegation, requestedCwd, agentName, model string) (hub.Delegation, int, error) {
+	if err := target.AcceptsDelegationFrom(origin.ID); err != nil {
+		return hub.Delegation{}, http.StatusForbidden, err
 	}
-	if timeout < hubDelegationMinTimeout {
-		timeout = hubDelegationMinTimeout
+	cwd, err := resolveDelegationCwd(target.DelegationWorkdir(), requestedCwd)
+	if err != nil {
+		return hub.Delegation{}, http.StatusBadRequest, err
 	}
-	if timeout > hubDelegationDefaultTimeout {
-		timeout = hubDelegationDefaultTimeout
+	depth := 1
+	chain := []string{origin.ID, target.ID}
+	parentID := ""
+	if parent != nil {
+		for _, hop := range parent.Chain {
+			if hop == target.ID {
+				return hub.Delegation{}, http.StatusConflict, fmt.Errorf("delegation loop: node %q already appears in chain %v", target.ID, parent.Chain)
+			}
+		}
+		depth = parent.Depth + 1
+		chain = append(append([]string{}, parent.Chain...), target.ID)
+		parentID = parent.ID
 	}
-	agentName := strings.TrimSpace(req.AgentName)
-	if agentName == "" {
-		agentName = hubDelegationDefaultAgent
+	if depth > hub.DefaultDelegationMaxDepth {
+		return hub.Delegation{}, http.StatusForbidden, fmt.Errorf("delegation depth %d exceeds the maximum of %d", depth, hub.DefaultDelegationMaxDepth)
+	}
+	if err := s.checkDelegationCaps(ctx, origin, target); err != nil {
+		return hub.Delegation{}, http.StatusTooManyRequests, err
 	}
 	if err := target.AcceptsDelegationAgent(agentName); err != nil {
-		http.Error(w, err.Error(), http.StatusForbidden)
-		return
+		return hub.Delegation{}, http.StatusForbidden, err
 	}
-	model := strings.TrimSpace(req.Model)
 	if err := target.AcceptsDelegationModel(model); err != nil {
-		http.Error(w, err.Error(), http.StatusForbidden)
-		return
+		return hub.Delegation{}, http.StatusForbidden, err
 	}
-
 	id, err := hub.NewDelegationID()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return hub.Delegation{}, http.StatusInternalServerError, err
 	}
 	now := time.Now().UTC()
-	d := hub.Delegation{
+	return hub.Delegation{
 		ID:         id,
 		OriginNode: origin.ID,
 		TargetNode: target.ID,
 		AgentName:  agentName,
-		Prompt:     prompt,
 		Model:      model,
 		Cwd:        cwd,
 		Status:     hub.DelegationStatusPending,
 		Depth:      depth,
 		Chain:      chain,
-		ParentID:   strings.TrimSpace(req.ParentDelegationID),
+		ParentID:   parentID,
 		CreatedAt:  now,
 		UpdatedAt:  now,
-	}
+	}, http.StatusOK, nil
+}
 
+// errDelegationNotStarted marks a startDelegation failure after the record
+// reached the ledger: the returned record reflects how far the target got.
+var errDelegationNotStarted = errors.New("delegation not started")
+
+// startDelegation records d in the ledger, then creates and triggers its job
+// on the target. A ledger failure returns an empty record; a target failure
+// returns the stored record (status error, or pending/running when the job
+// is known) and an error wrapping errDelegationNotStarted.
+func (s *hubServer) startDelegation(ctx context.Context, target hub.Node, d hub.Delegation, prompt string, timeout int) (hub.Delegation, error) {
 	if err := s.delegations.Add(d); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return hub.Delegation{}, err
 	}
 
-	jobID, runID, err := s.createDelegationJob(r.Context(), target, d, prompt, timeout)
+	ctx, span := hubTracer.Start(ctx, "hub.delegate", trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+		attribute.String("term_llm.hub.delegation_id", d.ID),
+		attribute.String("term_llm.hub.origin_node", d.OriginNode),
+		attribute.String("term_llm.hub.target_node", d.TargetNode),
+		attribute.String("gen_ai.agent.name", d.AgentName),
+		attribute.Int("term_llm.hub.depth", d.Depth),
+	))
+	jobID, runID, err := s.createDelegationJob(ctx, target, d, prompt, timeout)
+	tracing.EndSpan(span, err)
 	d.JobID = jobID
 	d.RunID = runID
 	if err != nil {
+		d.Error = err.Error()
+		d.Status = hub.DelegationStatusError
 		updated, updateErr := s.delegations.Update(d.ID, func(rec *hub.Delegation) {
 			rec.JobID = jobID
 			rec.RunID = runID
@@ -351,22 +495,14 @@ func (s *hubServer) handleCreateDelegation(w http.ResponseWriter, r *http.Reques
 		if updateErr == nil {
 			d = updated
 		}
-		http.Error(w, fmt.Sprintf("delegate to node %q: %v", target.ID, err), http.StatusBadGateway)
-		return
+		return d, fmt.Errorf("%w: %w", errDelegationNotStarted, err)
 	}
-	d.Status = hub.DelegationStatusRunning
-	updated, err := s.delegations.Update(d.ID, func(rec *hub.Delegation) {
+	return s.delegations.Update(d.ID, func(rec *hub.Delegation) {
 		rec.JobID = jobID
 		rec.RunID = runID
 		rec.Status = hub.DelegationStatusRunning
 		rec.Error = ""
 	})
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	d = updated
-	writeJSON(w, http.StatusCreated, map[string]any{"delegation": d})
 }
 
 func (s *hubServer) handleCancelDelegation(w http.ResponseWriter, r *http.Request, id string) {
@@ -384,6 +520,7 @@ func (s *hubServer) handleCancelDelegation(w http.ResponseWriter, r *http.Reques
 		http.Error(w, fmt.Sprintf("unknown delegation %q", id), http.StatusNotFound)
 		return
 	}
+	noteHubAuditDelegation(r.Context(), d)
 	if d.OriginNode != requester.ID {
 		http.Error(w, "only the originating node may cancel a delegation", http.StatusForbidden)
 		return
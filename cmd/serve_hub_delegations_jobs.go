@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/samsaffron/term-llm/internal/hub"
+	"github.com/samsaffron/term-llm/internal/tracing"
 )
 
 // jobsV2HubDelegationLabel is the trusted label shape the hub writes onto
@@ -21,6 +22,9 @@ type jobsV2HubDelegationLabel struct {
 	Origin string   `json:"origin"`
 	Depth  int      `json:"depth"`
 	Chain  []string `json:"chain"`
+	// TraceParent carries the delegating span across the asynchronous job
+	// boundary so the target run joins the origin's trace.
+	TraceParent string `json:"traceparent,omitempty"`
 }
 
 func hubDelegationLabelFromJobLabels(labels json.RawMessage) jobsV2HubDelegationLabel {
@@ -81,12 +85,16 @@ type hubNodeJobsRun struct {
 // error means the job is known, but no run could be confirmed.
 func (s *hubServer) createDelegationJob(ctx context.Context, target hub.Node, d hub.Delegation, prompt string, timeoutSeconds int) (jobID, runID string, err error) {
 	jobName := "hub-delegation-" + d.ID
-	labels, err := json.Marshal(map[string]any{"hub_delegation": map[string]any{
+	label := map[string]any{
 		"id":     d.ID,
 		"origin": d.OriginNode,
 		"depth":  d.Depth,
 		"chain":  d.Chain,
-	}})
+	}
+	if traceparent := tracing.TraceParent(ctx); traceparent != "" {
+		label["traceparent"] = traceparent
+	}
+	labels, err := json.Marshal(map[string]any{"hub_delegation": label})
 	if err != nil {
 		return "", "", fmt.Errorf("encode delegation labels: %w", err)
 	}
@@ -206,6 +214,7 @@ func (s *hubServer) doNodeJSON(ctx context.Context, node hub.Node, method, path
 	if node.Token != "" {
 		req.Header.Set("Authorization", "Bearer "+node.Token)
 	}
+	tracing.Inject(ctx, req.Header)
 	var resp *http.Response
 	if node.UsesReverseConnection() {
 		resp, err = s.reverse.do(ctx, node, req)
@@ -52,6 +52,78 @@ func (s *hubServer) checkDelegationCaps(ctx context.Context, origin, target hub.
 	return err
 }
 
+// selectDelegationTargets picks up to n nodes for a delegation whose origin
+// named requirements instead of a target. Only nodes origin may delegate to
+// are probed; the rest of the ranking (reachability, labels, in-flight load
+// against each node's cap) is hub.SelectDelegationTargets.
+func (s *hubServer) selectDelegationTargets(ctx context.Context, origin hub.Node, req hub.DelegationRequirements, n int) ([]hub.Node, error) {
+	nodes, _ := s.registry.Nodes()
+	allowed := make([]hub.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if node.ID != origin.ID && origin.CanDelegateTo(node.ID) {
+			allowed = append(allowed, node)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("node %q may not delegate to any registered node", origin.ID)
+	}
+	_, _, byTarget, err := s.delegations.ActiveCounts()
+	if err != nil {
+		return nil, err
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	views := s.probeNodeViews(probeCtx, allowed)
+	candidates := make([]hub.DelegationCandidate, 0, len(allowed))
+	for i, node := range allowed {
+		candidates = append(candidates, hub.DelegationCandidate{Node: node, Status: views[i].Status, InFlight: byTarget[node.ID]})
+	}
+	selected, rejected := hub.SelectDelegationTargets(origin, candidates, req, n)
+	if len(selected) == 0 {
+		reasons := make([]string, 0, len(rejected))
+		for _, err := range rejected {
+			reasons = append(reasons, err.Error())
+		}
+		return nil, fmt.Errorf("no eligible node for this delegation: %s", strings.Join(reasons, "; "))
+	}
+	targets := make([]hub.Node, 0, len(selected))
+	for _, c := range selected {
+		targets = append(targets, c.Node)
+	}
+	return targets, nil
+}
+
+// cancelDelegationGroup cancels the still-active siblings of a fan-out
+// delegation once winner has succeeded. It is best effort: a sibling whose
+// cancel fails is left to finish and its result is simply ignored.
+func (s *hubServer) cancelDelegationGroup(ctx context.Context, winner hub.Delegation) {
+	records, err := s.delegations.List()
+	if err != nil {
+		return
+	}
+	for _, d := range records {
+		if d.GroupID != winner.GroupID || d.ID == winner.ID || hub.DelegationStatusTerminal(d.Status) {
+			continue
+		}
+		if d.RunID != "" {
+			target, ok := s.registry.Lookup(d.TargetNode)
+			if !ok {
+				continue
+			}
+			if err := s.doNodeJSON(ctx, target, http.MethodPost,
+				"/v2/runs/"+url.PathEscape(d.RunID)+"/cancel", map[string]any{}, nil); err != nil {
+				continue
+			}
+		}
+		_, _ = s.delegations.Update(d.ID, func(rec *hub.Delegation) {
+			if !hub.DelegationStatusTerminal(rec.Status) {
+				rec.Status = hub.DelegationStatusCancelRequested
+				rec.Error = fmt.Sprintf("fan-out group %s was won by delegation %s", winner.GroupID, winner.ID)
+			}
+		})
+	}
+}
+
 // refreshActiveDelegations re-polls active records in priority order so stale
 // entries can stop pinning in-flight caps, but keeps the cap-recovery path
 // bounded with a small worker pool and short child deadlines.
@@ -222,6 +294,9 @@ func (s *hubServer) refreshDelegation(ctx context.Context, d *hub.Delegation) er
 		return err
 	}
 	*d = updated
+	if d.GroupID != "" && d.Status == hub.DelegationStatusSucceeded {
+		s.cancelDelegationGroup(ctx, *d)
+	}
 	return nil
 }
 
@@ -15,6 +15,7 @@ import (
 	"github.com/samsaffron/term-llm/internal/hub"
 	"github.com/samsaffron/term-llm/internal/llm"
 	"github.com/samsaffron/term-llm/internal/tools"
+	"github.com/samsaffron/term-llm/internal/tracing"
 )
 
 // fakeTargetJobs fakes the slice of a node's jobs-v2 API the hub drives:
@@ -26,6 +27,7 @@ type fakeTargetJobs struct {
 	jobs        map[string]hubNodeJobsJob
 	jobRuns     map[string]string
 	lastAuth    string
+	lastTrace   string
 	lastJobBody map[string]any
 	runStatus   string
 	runResponse string
@@ -44,10 +46,14 @@ func newFakeTargetJobs(t *testing.T) *fakeTargetJobs {
 	t.Helper()
 	f := &fakeTargetJobs{runStatus: "running", jobs: map[string]hubNodeJobsJob{}, jobRuns: map[string]string{}}
 	mux := http.NewServeMux()
+	mux.HandleFunc("/chat/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "capabilities": []string{"web", "jobs"}})
+	})
 	mux.HandleFunc("/chat/v2/jobs", func(w http.ResponseWriter, r *http.Request) {
 		f.mu.Lock()
 		defer f.mu.Unlock()
 		f.lastAuth = r.Header.Get("Authorization")
+		f.lastTrace = r.Header.Get("traceparent")
 		if r.Method == http.MethodGet {
 			jobs := make([]hubNodeJobsJob, 0, len(f.jobs))
 			for _, job := range f.jobs {
@@ -144,7 +150,8 @@ func (f *fakeTargetJobs) setRun(status, response, errText string) {
 
 // newDelegationHub builds a hub over a fake fleet: alpha and beta accept
 // delegations (workdir /work), closed has no workdir, restricted may only
-// delegate to gamma, tokenless has no stored token.
+// delegate to gamma, tokenless has no stored token. gamma and anyagent carry
+// routing labels for hub-selected delegations.
 func newDelegationHub(t *testing.T) (*hubServer, *fakeTargetJobs) {
 	t.Helper()
 	fake := newFakeTargetJobs(t)
@@ -153,9 +160,9 @@ func newDelegationHub(t *testing.T) (*hubServer, *fakeTargetJobs) {
 			Delegation: &hub.DelegationPolicy{Enabled: true, Workdir: "/work"}},
 		{ID: "beta", Name: "Beta", URL: fake.server.URL, BasePath: "/chat", Token: "beta-token",
 			Delegation: &hub.DelegationPolicy{Enabled: true, Workdir: "/work", MaxInFlight: 1, AllowedModels: []string{"haiku"}}},
-		{ID: "gamma", Name: "Gamma", URL: fake.server.URL, BasePath: "/chat", Token: "gamma-token",
+		{ID: "gamma", Name: "Gamma", URL: fake.server.URL, BasePath: "/chat", Token: "gamma-token", Labels: []string{"gpu"},
 			Delegation: &hub.DelegationPolicy{Enabled: true, Workdir: "/work"}},
-		{ID: "anyagent", Name: "AnyAgent", URL: fake.server.URL, BasePath: "/chat", Token: "anyagent-token",
+		{ID: "anyagent", Name: "AnyAgent", URL: fake.server.URL, BasePath: "/chat", Token: "anyagent-token", Labels: []string{"gpu", "repo:x"},
 			Delegation: &hub.DelegationPolicy{Enabled: true, Workdir: "/work", AllowedAgents: []string{"*"}}},
 		{ID: "pathy", Name: "Pathy", URL: fake.server.URL, BasePath: "/chat", Token: "pathy-token",
 			Delegation: &hub.DelegationPolicy{Enabled: true, Workdir: "/work", AllowedAgents: []string{"skills/custom-agent"}}},
@@ -283,6 +290,41 @@ func TestHubDelegationCreateHappyPath(t *testing.T) {
 	}
 }
 
+func TestHubDelegationPropagatesTraceContext(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{File: filepath.Join(t.TempDir(), "spans.jsonl")})
+	if err != nil {
+		t.Fatalf("tracing setup: %v", err)
+	}
+	t.Cleanup(func() { _ = shutdown(context.Background()) })
+
+	s, fake := newDelegationHub(t)
+	const traceID = "0af7651916cd43dd8448eb211c80319c"
+	originParent := "00-" + traceID + "-b7ad6b7169203331-01"
+	req := delegationRequest(http.MethodPost, "/api/delegations", "alpha", "alpha-token", map[string]any{
+		"target_node": "beta",
+		"prompt":      "profile the slow query",
+	})
+	req.Header.Set("traceparent", originParent)
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d body=%q", rec.Code, rec.Body.String())
+	}
+
+	// The target sees the hub's delegation span as parent, in the origin's
+	// trace, both on the wire and in the label its job run resumes from.
+	if !strings.HasPrefix(fake.lastTrace, "00-"+traceID+"-") || fake.lastTrace == originParent {
+		t.Fatalf("target traceparent = %q, want a hub span in trace %s", fake.lastTrace, traceID)
+	}
+	labels, _ := json.Marshal(fake.lastJobBody["labels"])
+	label := hubDelegationLabelFromJobLabels(json.RawMessage(labels))
+	if label.TraceParent != fake.lastTrace {
+		t.Fatalf("label traceparent = %q, want %q", label.TraceParent, fake.lastTrace)
+	}
+}
+
 func TestHubDelegationPolicyDeny(t *testing.T) {
 	s, _ := newDelegationHub(t)
 
@@ -514,6 +556,95 @@ func TestHubDelegationInFlightCaps(t *testing.T) {
 	}
 }
 
+func TestHubDelegationSelectsTargetByRequirements(t *testing.T) {
+	s, _ := newDelegationHub(t)
+
+	rec, d := createDelegation(t, s, "alpha", "alpha-token", map[string]any{"prompt": "x", "labels": []string{"repo:x"}})
+	if rec.Code != http.StatusCreated || d.TargetNode != "anyagent" {
+		t.Fatalf("repo:x selection: status %d target %q body %s", rec.Code, d.TargetNode, rec.Body.String())
+	}
+
+	// gamma is the only other gpu node; once it has work, max_queue_depth 0
+	// rules out both.
+	rec, _ = createDelegation(t, s, "alpha", "alpha-token", map[string]any{"target_node": "gamma", "prompt": "x"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("explicit gamma: status %d body %s", rec.Code, rec.Body.String())
+	}
+	rec, _ = createDelegation(t, s, "alpha", "alpha-token", map[string]any{"prompt": "x", "labels": []string{"gpu"}, "max_queue_depth": 0})
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "max_queue_depth") {
+		t.Fatalf("busy gpu nodes: status %d body %s", rec.Code, rec.Body.String())
+	}
+	rec, d = createDelegation(t, s, "alpha", "alpha-token", map[string]any{"prompt": "x", "labels": []string{"gpu"}, "max_queue_depth": 1})
+	if rec.Code != http.StatusCreated || d.TargetNode != "gamma" && d.TargetNode != "anyagent" {
+		t.Fatalf("gpu selection: status %d target %q body %s", rec.Code, d.TargetNode, rec.Body.String())
+	}
+
+	rec, _ = createDelegation(t, s, "alpha", "alpha-token", map[string]any{"prompt": "x", "labels": []string{"tpu"}})
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `lacks label "tpu"`) {
+		t.Fatalf("no tpu node: status %d body %s", rec.Code, rec.Body.String())
+	}
+	rec, _ = createDelegation(t, s, "alpha", "alpha-token", map[string]any{"target_node": "beta", "prompt": "x", "labels": []string{"gpu"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("labels with target_node: status %d, want 400", rec.Code)
+	}
+	rec, _ = createDelegation(t, s, "alpha", "alpha-token", map[string]any{"prompt": "x", "fan_out": hubDelegationMaxFanOut + 1})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("oversized fan_out: status %d, want 400", rec.Code)
+	}
+}
+
+func TestHubDelegationFanOutFirstSuccessWins(t *testing.T) {
+	s, fake := newDelegationHub(t)
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, delegationRequest(http.MethodPost, "/api/delegations", "alpha", "alpha-token",
+		map[string]any{"prompt": "race", "labels": []string{"gpu"}, "fan_out": 2}))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d body %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Delegation  hub.Delegation   `json:"delegation"`
+		Delegations []hub.Delegation `json:"delegations"`
+		GroupID     string           `json:"group_id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Delegations) != 2 || resp.GroupID == "" {
+		t.Fatalf("fan-out response = %+v", resp)
+	}
+	targets := map[string]bool{}
+	for _, d := range resp.Delegations {
+		if d.GroupID != resp.GroupID {
+			t.Fatalf("member %s group = %q, want %q", d.ID, d.GroupID, resp.GroupID)
+		}
+		targets[d.TargetNode] = true
+	}
+	if !targets["gamma"] || !targets["anyagent"] {
+		t.Fatalf("fan-out targets = %v", targets)
+	}
+
+	fake.setRun("succeeded", "first!", "")
+	winner := resp.Delegations[0]
+	rec = httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, delegationRequest(http.MethodGet, "/api/delegations/"+winner.ID, "alpha", "alpha-token", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get winner: %d %s", rec.Code, rec.Body.String())
+	}
+	loser, _, err := s.delegations.Get(resp.Delegations[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loser.Status != hub.DelegationStatusCancelRequested || !strings.Contains(loser.Error, winner.ID) {
+		t.Fatalf("loser = %+v, want cancel_requested naming the winner", loser)
+	}
+	fake.mu.Lock()
+	cancelled := append([]string(nil), fake.cancelled...)
+	fake.mu.Unlock()
+	if len(cancelled) != 1 || cancelled[0] != loser.RunID {
+		t.Fatalf("cancelled runs = %v, want [%s]", cancelled, loser.RunID)
+	}
+}
+
 func TestResolveDelegationCwd(t *testing.T) {
 	cases := []struct {
 		workdir, requested, want string
@@ -28,6 +28,8 @@ type hubIndexView struct {
 	CanAddNodes   bool
 	PasskeyAuth   bool
 	AuthScriptURL string
+	// AuditEnabled toggles the Audit panel.
+	AuditEnabled bool
 }
 
 func (s *hubServer) handleIndex(w http.ResponseWriter, r *http.Request) {
@@ -46,5 +48,6 @@ func (s *hubServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 		CanAddNodes:   s.store != nil,
 		PasskeyAuth:   s.passkey != nil,
 		AuthScriptURL: s.publicPath("/auth/hub_auth.js"),
+		AuditEnabled:  s.audit != nil,
 	})
 }
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/metrics"
+)
+
+// hubMetricsProbeTTL lets the per-node gauges of one scrape share a single
+// round of probes.
+const hubMetricsProbeTTL = 2 * time.Second
+
+// hubMetrics exposes node health for `serve hub --metrics`. Node labels are
+// registry node IDs, which operators control; the registry caps them like
+// any other label.
+type hubMetrics struct {
+	registry *metrics.Registry
+	hub      *hubServer
+
+	mu       sync.Mutex
+	probedAt time.Time
+	views    []hubNodeView
+}
+
+func newHubMetrics(s *hubServer) *hubMetrics {
+	m := &hubMetrics{registry: metrics.NewRegistry(), hub: s}
+	m.registry.NewGaugeFunc(metrics.Options{Name: "term_llm_hub_nodes", Help: "Nodes known to the hub."}, func(emit func(float64, ...string)) {
+		emit(float64(len(m.nodeViews())))
+	})
+	m.registry.NewGaugeFunc(metrics.Options{Name: "term_llm_hub_node_up", Help: "Whether a node answered its health probe (1) or not (0).", Labels: []string{"node"}}, func(emit func(float64, ...string)) {
+		for _, view := range m.nodeViews() {
+			up := 0.0
+			if view.Status.Reachable {
+				up = 1
+			}
+			emit(up, view.ID)
+		}
+	})
+	m.registry.NewGaugeFunc(metrics.Options{Name: "term_llm_hub_node_probe_latency_seconds", Help: "Health probe round-trip of reachable nodes.", Labels: []string{"node"}}, func(emit func(float64, ...string)) {
+		for _, view := range m.nodeViews() {
+			if view.Status.Reachable {
+				emit(float64(view.Status.LatencyMS)/1000, view.ID)
+			}
+		}
+	})
+	return m
+}
+
+// nodeViews returns node probe results, probing at most once per TTL.
+func (m *hubMetrics) nodeViews() []hubNodeView {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.probedAt.IsZero() && time.Since(m.probedAt) < hubMetricsProbeTTL {
+		return m.views
+	}
+	nodes, _ := m.hub.registry.Nodes()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	m.views = m.hub.probeNodeViews(ctx, nodes)
+	m.probedAt = time.Now()
+	return m.views
+}
@@ -51,6 +51,9 @@ type hubNodeView struct {
 	BasePath       string `json:"base_path"`
 	ProxyPath      string `json:"proxy_path"`
 	NewSessionPath string `json:"new_session_path"`
+	// Capabilities are the labels the node advertised (mDNS/registration);
+	// live probe capabilities are in Status.
+	Capabilities []string `json:"capabilities,omitempty"`
 	// HasToken reports whether the hub holds a bearer token for this node
 	// (without it, a token-guarded node will answer 401 through the proxy).
 	HasToken    bool                 `json:"has_token"`
@@ -70,6 +73,17 @@ func (s *hubServer) collectNodes(ctx context.Context) ([]hubNodeView, error) {
 	nodes, err := s.registry.Nodes()
 	probeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
+	views := s.probeNodeViews(probeCtx, nodes)
+	s.collectNodeSessionViews(probeCtx, nodes, views)
+	for i := range views {
+		views[i].Diagnostics = hubNodeDiagnostics(nodes[i], views[i], nodes)
+	}
+	return views, err
+}
+
+// probeNodeViews probes nodes concurrently, using the reverse connection for
+// reverse nodes, and returns their views in node order.
+func (s *hubServer) probeNodeViews(probeCtx context.Context, nodes []hub.Node) []hubNodeView {
 	statuses := s.prober.ProbeAll(probeCtx, nodes)
 	views := make([]hubNodeView, 0, len(nodes))
 	for _, n := range nodes {
@@ -82,6 +96,7 @@ func (s *hubServer) collectNodes(ctx context.Context) ([]hubNodeView, error) {
 			URL:            n.URL,
 			BasePath:       n.BasePath,
 			ProxyPath:      proxyPath,
+			Capabilities:   n.Capabilities,
 			NewSessionPath: proxyPath + "?new=1",
 			HasToken:       n.Token != "",
 			Status:         statuses[n.ID],
@@ -96,11 +111,7 @@ func (s *hubServer) collectNodes(ctx context.Context) ([]hubNodeView, error) {
 		}
 		views = append(views, view)
 	}
-	s.collectNodeSessionViews(probeCtx, nodes, views)
-	for i := range views {
-		views[i].Diagnostics = hubNodeDiagnostics(nodes[i], views[i], nodes)
-	}
-	return views, err
+	return views
 }
 
 const (
@@ -187,22 +198,30 @@ func (s *hubServer) fetchHubNodeSessions(ctx context.Context, n hub.Node) (*hubN
 }
 
 func (s *hubServer) newHubNodeSessionsRequest(ctx context.Context, n hub.Node) (*http.Request, error) {
+	return s.newHubNodeGetRequest(ctx, n, "/v1/sessions/status", nil)
+}
+
+// newHubNodeGetRequest builds an authenticated GET for path (relative to the
+// node's base path), addressed for the node's transport.
+func (s *hubServer) newHubNodeGetRequest(ctx context.Context, n hub.Node, path string, query url.Values) (*http.Request, error) {
+	var u *url.URL
 	if n.UsesReverseConnection() {
-		u := &url.URL{
+		u = &url.URL{
 			Scheme: "http",
 			Host:   "reverse.local",
-			Path:   hubJoinBasePath(n.BasePath, "/v1/sessions/status"),
+			Path:   hubJoinBasePath(n.BasePath, path),
 		}
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	} else {
+		parsed, err := url.Parse(n.BaseURL() + path)
 		if err != nil {
 			return nil, err
 		}
-		if n.Token != "" {
-			req.Header.Set("Authorization", "Bearer "+n.Token)
-		}
-		return req, nil
+		u = parsed
+	}
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.BaseURL()+"/v1/sessions/status", nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -301,11 +320,13 @@ func (s *hubServer) probeReverseNode(ctx context.Context, n hub.Node, connectedA
 		Version      string   `json:"version"`
 		Agent        string   `json:"agent"`
 		Capabilities []string `json:"capabilities"`
+		Labels       []string `json:"labels"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil {
 		st.Version = body.Version
 		st.Agent = body.Agent
 		st.Capabilities = body.Capabilities
+		st.Labels = body.Labels
 	}
 	return st
 }
@@ -762,5 +762,8 @@ func (s *hubServer) authenticatePasskeyRequest(r *http.Request) (passkeyauth.Pri
 }
 
 func withHubPrincipal(r *http.Request, p passkeyauth.Principal) *http.Request {
+	if st := hubAuditStateFrom(r.Context()); st != nil {
+		st.principal = &p
+	}
 	return r.WithContext(context.WithValue(r.Context(), hubPrincipalKey{}, p))
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -123,23 +124,34 @@ func (s *hubServer) handleRegisterNode(w http.ResponseWriter, r *http.Request) {
 	if connection == "" {
 		connection = "reverse"
 	}
-	if connection != "reverse" {
-		http.Error(w, "registration currently supports reverse nodes only", http.StatusBadRequest)
-		return
-	}
 
-	node := hub.Node{
-		ID:         strings.TrimSpace(req.ID),
-		Name:       strings.TrimSpace(req.Name),
-		Connection: "reverse",
-		BasePath:   strings.TrimSpace(req.BasePath),
-		Token:      strings.TrimSpace(req.Token),
+	var node hub.Node
+	switch connection {
+	case "reverse":
+		node = hub.Node{
+			ID:         strings.TrimSpace(req.ID),
+			Name:       strings.TrimSpace(req.Name),
+			Connection: "reverse",
+			BasePath:   strings.TrimSpace(req.BasePath),
+		}
+	case "direct":
+		advertised, status, err := s.advertisedDirectNode(r, req)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		node = advertised
+	default:
+		http.Error(w, "registration supports reverse nodes, or direct nodes advertised over mDNS", http.StatusBadRequest)
+		return
 	}
+	node.Token = strings.TrimSpace(req.Token)
+	node.Registered = true
 	if err := node.Normalize(); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if existing, ok := s.registry.Lookup(node.ID); ok && existing.Source != hub.SourceLocal {
+	if existing, ok := s.registry.Lookup(node.ID); ok && existing.Source != hub.SourceLocal && existing.Source != hub.SourceMDNS {
 		http.Error(w, fmt.Sprintf("node id %q is owned by %s and cannot be replaced by registration", node.ID, existing.Source), http.StatusConflict)
 		return
 	}
@@ -149,25 +161,70 @@ func (s *hubServer) handleRegisterNode(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	log.Printf("hub registration: %s reverse node %q", map[bool]string{true: "created", false: "updated"}[created], stored.ID)
+	log.Printf("hub registration: %s %s node %q", map[bool]string{true: "created", false: "updated"}[created], stored.Connection, stored.ID)
 	status := http.StatusOK
 	if created {
 		status = http.StatusCreated
 	}
 	writeJSON(w, status, map[string]any{
 		"node": hubNodeView{
-			ID:         stored.ID,
-			Name:       stored.Name,
-			Source:     stored.Source,
-			Connection: stored.Connection,
-			BasePath:   stored.BasePath,
-			ProxyPath:  s.hubPath("/node/" + stored.ID + "/"),
-			HasToken:   stored.Token != "",
+			ID:           stored.ID,
+			Name:         stored.Name,
+			Source:       stored.Source,
+			Connection:   stored.Connection,
+			URL:          stored.URL,
+			BasePath:     stored.BasePath,
+			Capabilities: stored.Capabilities,
+			ProxyPath:    s.hubPath("/node/" + stored.ID + "/"),
+			HasToken:     stored.Token != "",
 		},
 		"created": created,
 	})
 }
 
+// advertisedDirectNode builds the node for a direct registration. Direct
+// nodes must currently be advertised over mDNS, and the request must come from
+// one of the advertised addresses: the hub dials that address with the token,
+// so a spoofed advertisement cannot collect another node's token. The URL,
+// base path and capabilities come from the advertisement, not the request.
+func (s *hubServer) advertisedDirectNode(r *http.Request, req hubRegisterNodeRequest) (hub.Node, int, error) {
+	if s.mdns == nil {
+		return hub.Node{}, http.StatusBadRequest, fmt.Errorf("direct registration requires a hub started with --mdns")
+	}
+	id := strings.TrimSpace(req.ID)
+	svc, ok := s.mdns.Service(id)
+	if !ok {
+		return hub.Node{}, http.StatusNotFound, fmt.Errorf("node %q is not advertised over mDNS", id)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	remote := net.ParseIP(host)
+	var addr net.IP
+	for _, ip := range svc.IPs {
+		if ip.Equal(remote) {
+			addr = ip
+			break
+		}
+	}
+	if addr == nil {
+		return hub.Node{}, http.StatusForbidden, fmt.Errorf("direct registration for %q must come from an advertised address", id)
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = svc.Name
+	}
+	return hub.Node{
+		ID:           svc.ID,
+		Name:         name,
+		Connection:   "direct",
+		URL:          hub.MDNSServiceURL(svc, addr),
+		BasePath:     svc.BasePath,
+		Capabilities: svc.Capabilities,
+	}, http.StatusOK, nil
+}
+
 func (s *hubServer) handleUnregisterNode(w http.ResponseWriter, r *http.Request) {
 	id := strings.TrimPrefix(r.URL.Path, "/api/register-node/")
 	if id == "" || strings.Contains(id, "/") || hub.ValidateID(id) != nil {
@@ -197,8 +254,8 @@ func (s *hubServer) handleUnregisterNode(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	for _, existing := range storedNodes {
-		if existing.ID == id && !existing.UsesReverseConnection() {
-			http.Error(w, fmt.Sprintf("node %q is not a reverse registered node", id), http.StatusForbidden)
+		if existing.ID == id && !existing.UsesReverseConnection() && !existing.Registered {
+			http.Error(w, fmt.Sprintf("node %q is not a registered node", id), http.StatusForbidden)
 			return
 		}
 	}
@@ -209,7 +266,7 @@ func (s *hubServer) handleUnregisterNode(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if removed {
-		log.Printf("hub registration: removed reverse node %q", id)
+		log.Printf("hub registration: removed node %q", id)
 	}
 	writeJSON(w, http.StatusOK, map[string]any{"id": id, "removed": removed})
 }
@@ -281,6 +338,42 @@ func registerServeHubNode(ctx context.Context, client *http.Client, hubURL, regi
 	return nil
 }
 
+// registerAdvertisedServeHubNode registers a node advertised over mDNS. The
+// hub only accepts a direct registration once it has seen the node's
+// announcement, so a node that just started retries briefly.
+func registerAdvertisedServeHubNode(ctx context.Context, client *http.Client, hubURL, registrationToken string, req hubRegisterNodeRequest) error {
+	var err error
+	for attempt := 0; attempt < hubAdvertiseRegisterAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(hubAdvertiseRegisterDelay):
+			}
+		}
+		if err = registerServeHubNode(ctx, client, hubURL, registrationToken, req); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+var (
+	hubAdvertiseRegisterAttempts = 5
+	hubAdvertiseRegisterDelay    = time.Second
+)
+
+// hubAdvertiseIPs returns the addresses to advertise for a serve bound to
+// host: the bind address itself when it is a specific IP, otherwise nil so
+// the advertiser uses every non-loopback interface address.
+func hubAdvertiseIPs(host string) []net.IP {
+	ip := net.ParseIP(strings.TrimSpace(host))
+	if ip == nil || ip.IsUnspecified() {
+		return nil
+	}
+	return []net.IP{ip}
+}
+
 func unregisterServeHubNode(ctx context.Context, client *http.Client, hubURL, registrationToken, id string) error {
 	if client == nil {
 		client = http.DefaultClient
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/hub"
+)
+
+// Federated session search: GET /api/search?q=... fans the query out to every
+// reachable node's /v1/sessions/search with that node's token, then merges
+// the per-node rankings into one list whose hits open through the
+// /node/<id>/ proxy. Slow or failing nodes are reported per node instead of
+// failing the whole search.
+
+const (
+	hubSearchDefaultLimit = 20
+	hubSearchMaxLimit     = 50
+	hubSearchMaxBytes     = 1 << 20
+)
+
+// hubSearchNodeTimeout bounds each node's search request; a var so tests can
+// shorten it.
+var hubSearchNodeTimeout = 4 * time.Second
+
+// hubSearchHit is one session match, attributed to the node that holds it.
+type hubSearchHit struct {
+	NodeID        string `json:"node_id"`
+	NodeName      string `json:"node_name"`
+	SessionID     string `json:"session_id"`
+	ShortTitle    string `json:"short_title"`
+	LongTitle     string `json:"long_title,omitempty"`
+	Snippet       string `json:"snippet,omitempty"`
+	Archived      bool   `json:"archived,omitempty"`
+	LastMessageAt int64  `json:"last_message_at"`
+	MessageCount  int    `json:"message_count"`
+	ResumePath    string `json:"resume_path"`
+	// rank is the hit's position in its node's own result list.
+	rank int
+}
+
+// hubSearchNodeResult reports how one node answered: "ok", "unreachable",
+// "timeout" or "error".
+type hubSearchNodeResult struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Hits   int    `json:"hits"`
+	Error  string `json:"error,omitempty"`
+}
+
+type hubNodeSearchEntry struct {
+	ID            string `json:"id"`
+	ShortTitle    string `json:"short_title"`
+	LongTitle     string `json:"long_title"`
+	Archived      bool   `json:"archived"`
+	LastMessageAt int64  `json:"last_message_at"`
+	MessageCount  int    `json:"message_count"`
+	Snippet       string `json:"snippet"`
+}
+
+func (s *hubServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit := hubSearchDefaultLimit
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+			limit = min(parsed, hubSearchMaxLimit)
+		}
+	}
+	params := url.Values{"q": {query}, "limit": {strconv.Itoa(limit)}}
+	if archived := strings.TrimSpace(r.URL.Query().Get("include_archived")); archived != "" {
+		params.Set("include_archived", archived)
+	}
+
+	nodes, resolverErr := s.registry.Nodes()
+	probeCtx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	views := s.probeNodeViews(probeCtx, nodes)
+	cancel()
+
+	hits, results := s.searchNodes(r.Context(), nodes, views, params)
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	resp := map[string]any{"query": query, "results": hits, "nodes": results}
+	if resolverErr != nil {
+		resp["resolver_error"] = resolverErr.Error()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// searchNodes queries every reachable node concurrently and merges the hits:
+// each node's best match first, then each node's second, and so on, with
+// recency breaking ties within a rank. Node rankings are not comparable
+// across nodes, so interleaving keeps one busy node from drowning the rest.
+func (s *hubServer) searchNodes(ctx context.Context, nodes []hub.Node, views []hubNodeView, params url.Values) ([]hubSearchHit, []hubSearchNodeResult) {
+	results := make([]hubSearchNodeResult, len(nodes))
+	perNode := make([][]hubSearchHit, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		results[i] = hubSearchNodeResult{ID: n.ID, Name: n.Name}
+		if !views[i].Status.Reachable {
+			results[i].Status = "unreachable"
+			results[i].Error = views[i].Status.Error
+			continue
+		}
+		wg.Add(1)
+		go func(i int, n hub.Node) {
+			defer wg.Done()
+			nodeCtx, cancel := context.WithTimeout(ctx, hubSearchNodeTimeout)
+			defer cancel()
+			hits, err := s.searchNode(nodeCtx, n, params)
+			switch {
+			case err != nil && errors.Is(nodeCtx.Err(), context.DeadlineExceeded):
+				results[i].Status = "timeout"
+				results[i].Error = fmt.Sprintf("no answer within %s", hubSearchNodeTimeout)
+			case err != nil:
+				results[i].Status = "error"
+				results[i].Error = err.Error()
+			default:
+				results[i].Status = "ok"
+				results[i].Hits = len(hits)
+				perNode[i] = hits
+			}
+		}(i, n)
+	}
+	wg.Wait()
+
+	merged := []hubSearchHit{}
+	for _, hits := range perNode {
+		merged = append(merged, hits...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		if a.LastMessageAt != b.LastMessageAt {
+			return a.LastMessageAt > b.LastMessageAt
+		}
+		return a.NodeID < b.NodeID
+	})
+	return merged, results
+}
+
+func (s *hubServer) searchNode(ctx context.Context, n hub.Node, params url.Values) ([]hubSearchHit, error) {
+	req, err := s.newHubNodeGetRequest(ctx, n, "/v1/sessions/search", params)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.doHubNodeRequest(ctx, n, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("session search returned %s", resp.Status)
+	}
+	var body struct {
+		Sessions []hubNodeSearchEntry `json:"sessions"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, hubSearchMaxBytes)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode session search: %w", err)
+	}
+	hits := make([]hubSearchHit, 0, len(body.Sessions))
+	for _, entry := range body.Sessions {
+		if strings.TrimSpace(entry.ID) == "" {
+			continue
+		}
+		hits = append(hits, hubSearchHit{
+			NodeID:        n.ID,
+			NodeName:      n.Name,
+			SessionID:     entry.ID,
+			ShortTitle:    hubNodeSessionTitle(hubNodeSessionStatus{ID: entry.ID, ShortTitle: entry.ShortTitle, LongTitle: entry.LongTitle}),
+			LongTitle:     strings.TrimSpace(entry.LongTitle),
+			Snippet:       entry.Snippet,
+			Archived:      entry.Archived,
+			LastMessageAt: entry.LastMessageAt,
+			MessageCount:  entry.MessageCount,
+			ResumePath:    s.hubPath("/node/" + n.ID + "/" + url.PathEscape(entry.ID)),
+			rank:          len(hits),
+		})
+	}
+	return hits, nil
+}
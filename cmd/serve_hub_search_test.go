@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/hub"
+)
+
+// searchBackend fakes a node that answers healthz and session search with the
+// given session ids, each stamped with lastMessageAt.
+func searchBackend(t *testing.T, token string, lastMessageAt int64, ids ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chat/healthz":
+			io.WriteString(w, `{"status":"ok"}`)
+		case "/chat/v1/sessions/search":
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("q") != "s3 upload" {
+				http.Error(w, "unexpected query "+r.URL.RawQuery, http.StatusBadRequest)
+				return
+			}
+			sessions := []map[string]any{}
+			for _, id := range ids {
+				sessions = append(sessions, map[string]any{
+					"id": id, "short_title": "Title " + id, "last_message_at": lastMessageAt,
+					"message_count": 3, "snippet": "debugging the **S3** upload",
+				})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"sessions": sessions})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHubSearchFansOutAndMerges(t *testing.T) {
+	oldTimeout := hubSearchNodeTimeout
+	hubSearchNodeTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { hubSearchNodeTimeout = oldTimeout })
+
+	alpha := searchBackend(t, "alpha-token", 100, "a1", "a2")
+	beta := searchBackend(t, "beta-token", 200, "b1")
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chat/healthz" {
+			io.WriteString(w, `{"status":"ok"}`)
+			return
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	t.Cleanup(slow.Close)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	nodes := []hub.Node{
+		{ID: "alpha", Name: "Alpha", URL: alpha.URL, BasePath: "/chat", Token: "alpha-token"},
+		{ID: "beta", Name: "Beta", URL: beta.URL, BasePath: "/chat", Token: "beta-token"},
+		{ID: "wrongtoken", Name: "Wrong", URL: beta.URL, BasePath: "/chat", Token: "stale-token"},
+		{ID: "slow", Name: "Slow", URL: slow.URL, BasePath: "/chat"},
+		{ID: "down", Name: "Down", URL: down.URL, BasePath: "/chat"},
+	}
+	s := newHubServer(hub.NewRegistry(fakeHubResolver{nodes: nodes}), nil)
+	s.basePath = "/hub"
+
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/hub/api/search?q=s3+upload", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	for _, token := range []string{"alpha-token", "beta-token", "stale-token"} {
+		if strings.Contains(rec.Body.String(), token) {
+			t.Fatalf("node token %q leaked into search response", token)
+		}
+	}
+	var resp struct {
+		Results []hubSearchHit        `json:"results"`
+		Nodes   []hubSearchNodeResult `json:"nodes"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, hit := range resp.Results {
+		order = append(order, hit.NodeID+"/"+hit.SessionID)
+	}
+	// Best hit per node first (newest first within a rank), then the rest.
+	if got := strings.Join(order, ","); got != "beta/b1,alpha/a1,alpha/a2" {
+		t.Fatalf("merged order = %s", got)
+	}
+	if resp.Results[0].ResumePath != "/hub/node/beta/b1" || resp.Results[0].Snippet == "" {
+		t.Fatalf("first hit = %+v", resp.Results[0])
+	}
+	statuses := map[string]string{}
+	for _, n := range resp.Nodes {
+		statuses[n.ID] = n.Status
+	}
+	want := map[string]string{"alpha": "ok", "beta": "ok", "wrongtoken": "error", "slow": "timeout", "down": "unreachable"}
+	if fmt.Sprint(statuses) != fmt.Sprint(want) {
+		t.Fatalf("node statuses = %v, want %v", statuses, want)
+	}
+}
+
+func TestHubSearchRequiresQueryAndHubAuth(t *testing.T) {
+	s := newHubServer(hub.NewRegistry(fakeHubResolver{}), nil)
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/search", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("missing q: status = %d", rec.Code)
+	}
+
+	s.requireAuth = true
+	s.token = "hub-secret"
+	rec = httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/search?q=x", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated: status = %d", rec.Code)
+	}
+}
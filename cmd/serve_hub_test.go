@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"strings"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/samsaffron/term-llm/internal/hub"
 )
@@ -712,6 +714,90 @@ func TestHubUnregisterNodeRejectsDirectLocalNode(t *testing.T) {
 	}
 }
 
+func TestHubRegisterNodeDirectRequiresMDNSAdvertisement(t *testing.T) {
+	store := hub.NewStore(filepath.Join(t.TempDir(), "nodes.json"))
+	mdns := hub.NewMDNSResolver()
+	s := newHubServer(hub.NewRegistry(store, mdns), store)
+	s.registrationToken = "reg-secret"
+	h := s.handler()
+
+	register := func(remoteAddr string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/register-node", strings.NewReader(`{"id":"studio","connection":"direct","base_path":"/ignored","token":"node-token"}`))
+		req.Header.Set("Authorization", "Bearer reg-secret")
+		req.RemoteAddr = remoteAddr
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	s.mdns = nil
+	if rec := register("192.168.1.20:40000"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("direct registration without --mdns status = %d, want 400", rec.Code)
+	}
+	s.mdns = mdns
+	if rec := register("192.168.1.20:40000"); rec.Code != http.StatusNotFound {
+		t.Fatalf("unadvertised direct registration status = %d, want 404", rec.Code)
+	}
+
+	packet, err := hub.MDNSAnnouncement(hub.MDNSService{
+		ID:           "studio",
+		Name:         "Studio",
+		BasePath:     "/chat",
+		Port:         8081,
+		Capabilities: []string{"web", "jobs"},
+		IPs:          []net.IP{net.IPv4(192, 168, 1, 20)},
+	}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mdns.Observe(packet, net.IPv4(192, 168, 1, 20))
+	if n, ok := s.registry.Lookup("studio"); !ok || n.Source != hub.SourceMDNS || n.Token != "" {
+		t.Fatalf("advertised node before registration = %+v ok=%v", n, ok)
+	}
+
+	// Only the advertised address may hand over the token the hub will send there.
+	if rec := register("192.168.1.99:40000"); rec.Code != http.StatusForbidden {
+		t.Fatalf("spoofed direct registration status = %d, want 403", rec.Code)
+	}
+	rec := register("192.168.1.20:40000")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("direct registration status = %d body=%q", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "node-token") {
+		t.Fatalf("registration response leaked token: %s", rec.Body.String())
+	}
+	n, ok := s.registry.Lookup("studio")
+	if !ok || n.Source != hub.SourceLocal || n.Token != "node-token" || n.URL != "http://192.168.1.20:8081" || n.BasePath != "/chat" || n.Connection != "direct" {
+		t.Fatalf("registered node = %+v ok=%v", n, ok)
+	}
+	if strings.Join(n.Capabilities, ",") != "web,jobs" {
+		t.Fatalf("capabilities = %v", n.Capabilities)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "/api/register-node/studio", nil)
+	req.Header.Set("Authorization", "Bearer reg-secret")
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"removed":true`) {
+		t.Fatalf("unregister direct status = %d body=%q", rec.Code, rec.Body.String())
+	}
+	if n, ok := s.registry.Lookup("studio"); !ok || n.Source != hub.SourceMDNS || n.Token != "" {
+		t.Fatalf("node after deregistration = %+v ok=%v", n, ok)
+	}
+}
+
+func TestHubAdvertiseIPs(t *testing.T) {
+	if ips := hubAdvertiseIPs("0.0.0.0"); ips != nil {
+		t.Fatalf("unspecified host = %v, want all interfaces", ips)
+	}
+	if ips := hubAdvertiseIPs("studio.lan"); ips != nil {
+		t.Fatalf("host name = %v, want all interfaces", ips)
+	}
+	if ips := hubAdvertiseIPs("192.168.1.20"); len(ips) != 1 || !ips[0].Equal(net.IPv4(192, 168, 1, 20)) {
+		t.Fatalf("specific host = %v", ips)
+	}
+}
+
 func TestUnregisterServeHubNodeClient(t *testing.T) {
 	var gotAuth string
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,355 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/image"
+	"github.com/samsaffron/term-llm/internal/mediautil"
+)
+
+const (
+	maxServeImageCount       = 4
+	maxServeImageInputs      = 16
+	maxImageEditUploadBytes  = 50 << 20
+	maxImageGenerationsBytes = 1 << 20
+)
+
+var newServeImageProvider = image.NewImageProvider
+
+// serveImageAspectRatios are the normalized ratios an OpenAI WxH size is
+// snapped to.
+var serveImageAspectRatios = []string{"1:1", "4:3", "3:4", "3:2", "2:3", "16:9", "9:16"}
+
+type imagesGenerationRequest struct {
+	Prompt         string `json:"prompt"`
+	Model          string `json:"model,omitempty"`
+	N              int    `json:"n,omitempty"`
+	Size           string `json:"size,omitempty"`
+	Quality        string `json:"quality,omitempty"`
+	ResponseFormat string `json:"response_format,omitempty"`
+	OutputFormat   string `json:"output_format,omitempty"`
+	User           string `json:"user,omitempty"`
+}
+
+// imagesOptions holds the fields shared by generations and edits after
+// validation.
+type imagesOptions struct {
+	n              int
+	size           string
+	aspectRatio    string
+	responseFormat string
+	outputFormat   string
+}
+
+// resolveServeImageProvider maps a requested model to a provider spec for
+// image.NewImageProvider: an explicit provider or provider:model, a
+// well-known model name, or the configured default provider with the
+// requested model. An empty model uses image.provider.
+func resolveServeImageProvider(cfg *config.Config, requested string) string {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		return ""
+	}
+	if provider, _, _ := strings.Cut(requested, ":"); image.IsProvider(provider) {
+		return requested
+	}
+	if provider := image.ProviderForModel(requested); provider != "" {
+		return provider + ":" + requested
+	}
+	defaultProvider, _, _ := strings.Cut(firstNonEmpty(cfg.Image.Provider, config.DefaultImageProvider), ":")
+	return defaultProvider + ":" + requested
+}
+
+// parseServeImageSize maps an OpenAI size such as 1536x1024 onto the size
+// tier and aspect ratio the image providers take. 1K, 2K and 4K are accepted
+// as-is; "auto" leaves both to the provider.
+func parseServeImageSize(size string) (string, string, error) {
+	size = strings.TrimSpace(size)
+	if size == "" || strings.EqualFold(size, "auto") {
+		return "", "", nil
+	}
+	if tier := strings.ToUpper(size); image.ValidateSize(tier) == nil {
+		return tier, "", nil
+	}
+	ws, hs, ok := strings.Cut(strings.ToLower(size), "x")
+	w, werr := strconv.Atoi(ws)
+	h, herr := strconv.Atoi(hs)
+	if !ok || werr != nil || herr != nil || w <= 0 || h <= 0 {
+		return "", "", fmt.Errorf("invalid size %q: use WIDTHxHEIGHT, 1K, 2K, 4K or auto", size)
+	}
+
+	want := math.Log(float64(w) / float64(h))
+	aspectRatio, best := "1:1", math.Inf(1)
+	for _, ratio := range serveImageAspectRatios {
+		rw, rh, _ := strings.Cut(ratio, ":")
+		x, _ := strconv.Atoi(rw)
+		y, _ := strconv.Atoi(rh)
+		if diff := math.Abs(want - math.Log(float64(x)/float64(y))); diff < best {
+			aspectRatio, best = ratio, diff
+		}
+	}
+	switch long := max(w, h); {
+	case long <= 1536:
+		return "1K", aspectRatio, nil
+	case long <= 2560:
+		return "2K", aspectRatio, nil
+	default:
+		return "4K", aspectRatio, nil
+	}
+}
+
+func parseImagesOptions(n int, size, responseFormat, outputFormat string) (imagesOptions, error) {
+	opts := imagesOptions{n: n, responseFormat: responseFormat, outputFormat: strings.ToLower(outputFormat)}
+	if opts.n == 0 {
+		opts.n = 1
+	}
+	if opts.n < 1 || opts.n > maxServeImageCount {
+		return opts, fmt.Errorf("n must be between 1 and %d", maxServeImageCount)
+	}
+	switch opts.responseFormat {
+	case "":
+		opts.responseFormat = "b64_json"
+	case "b64_json", "url":
+	default:
+		return opts, fmt.Errorf("response_format must be b64_json or url")
+	}
+	switch opts.outputFormat {
+	case "", "png", "jpeg":
+	case "jpg":
+		opts.outputFormat = "jpeg"
+	default:
+		return opts, fmt.Errorf("output_format must be png or jpeg")
+	}
+	var err error
+	opts.size, opts.aspectRatio, err = parseServeImageSize(size)
+	return opts, err
+}
+
+func (s *serveServer) handleImagesGenerations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageGenerationsBytes)
+	var req imagesGenerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid json")
+		return
+	}
+	prompt := strings.TrimSpace(req.Prompt)
+	if prompt == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "prompt is required")
+		return
+	}
+	opts, err := parseImagesOptions(req.N, req.Size, req.ResponseFormat, req.OutputFormat)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	spec := resolveServeImageProvider(s.cfgRef, req.Model)
+	if err := checkAPIKeyProvider(r.Context(), firstNonEmpty(spec, s.cfgRef.Image.Provider, config.DefaultImageProvider)); err != nil {
+		writeOpenAIError(w, http.StatusForbidden, "permission_error", err.Error())
+		return
+	}
+	provider, err := newServeImageProvider(s.cfgRef, spec)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	start := time.Now()
+	results := make([]*image.ImageResult, 0, opts.n)
+	for range opts.n {
+		result, err := provider.Generate(r.Context(), image.GenerateRequest{Prompt: prompt, Size: opts.size, AspectRatio: opts.aspectRatio})
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadGateway, "server_error", "image generation failed: "+err.Error())
+			return
+		}
+		results = append(results, result)
+	}
+	s.writeImagesResponse(w, r, "generations", provider.Name(), req.User, start, results, opts)
+}
+
+func (s *serveServer) handleImagesEdits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageEditUploadBytes)
+	if err := r.ParseMultipartForm(maxImageEditUploadBytes); err != nil {
+		if r.MultipartForm != nil {
+			_ = r.MultipartForm.RemoveAll()
+		}
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "request must be multipart/form-data with an image file")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	prompt := strings.TrimSpace(r.FormValue("prompt"))
+	if prompt == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "prompt is required")
+		return
+	}
+	if len(r.MultipartForm.File["mask"]) > 0 {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "mask is not supported; describe the change in the prompt instead")
+		return
+	}
+	n := 0
+	if raw := strings.TrimSpace(r.FormValue("n")); raw != "" {
+		var err error
+		if n, err = strconv.Atoi(raw); err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "n must be an integer")
+			return
+		}
+	}
+	opts, err := parseImagesOptions(n, r.FormValue("size"), r.FormValue("response_format"), r.FormValue("output_format"))
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	files := append(r.MultipartForm.File["image"], r.MultipartForm.File["image[]"]...)
+	if len(files) == 0 {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "image is required")
+		return
+	}
+	if len(files) > maxServeImageInputs {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("at most %d images are allowed", maxServeImageInputs))
+		return
+	}
+	inputs := make([]image.InputImage, 0, len(files))
+	for _, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "failed to read image")
+			return
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil || len(data) == 0 {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "image file is empty")
+			return
+		}
+		ext := imageExtensionForContentType(http.DetectContentType(data))
+		if ext == "" {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("%s is not a png, jpeg, gif or webp image", header.Filename))
+			return
+		}
+		// Providers detect the MIME type from the path, so trust the bytes
+		// over the client's filename.
+		name := strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename))
+		inputs = append(inputs, image.InputImage{Data: data, Path: firstNonEmpty(name, "image") + ext})
+	}
+
+	spec := resolveServeImageProvider(s.cfgRef, r.FormValue("model"))
+	if err := checkAPIKeyProvider(r.Context(), firstNonEmpty(spec, s.cfgRef.Image.Provider, config.DefaultImageProvider)); err != nil {
+		writeOpenAIError(w, http.StatusForbidden, "permission_error", err.Error())
+		return
+	}
+	provider, err := newServeImageProvider(s.cfgRef, spec)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if !provider.SupportsEdit() {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("provider %s does not support image editing", provider.Name()))
+		return
+	}
+	if len(inputs) > 1 && !provider.SupportsMultiImage() {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("provider %s does not support multiple input images", provider.Name()))
+		return
+	}
+
+	start := time.Now()
+	results := make([]*image.ImageResult, 0, opts.n)
+	for range opts.n {
+		result, err := provider.Edit(r.Context(), image.EditRequest{Prompt: prompt, InputImages: inputs, Size: opts.size, AspectRatio: opts.aspectRatio})
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadGateway, "server_error", "image editing failed: "+err.Error())
+			return
+		}
+		results = append(results, result)
+	}
+	s.writeImagesResponse(w, r, "edits", provider.Name(), r.FormValue("user"), start, results, opts)
+}
+
+func imageExtensionForContentType(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	default:
+		return ""
+	}
+}
+
+// writeImagesResponse converts results to the requested output format and
+// returns them inline or as /images/ URLs, logging one usage line per request.
+func (s *serveServer) writeImagesResponse(w http.ResponseWriter, r *http.Request, operation, providerName, user string, start time.Time, results []*image.ImageResult, opts imagesOptions) {
+	data := make([]map[string]any, 0, len(results))
+	var totalBytes int
+	outputFormat := opts.outputFormat
+	for _, result := range results {
+		payload, mimeType := result.Data, result.MimeType
+		if mimeType == "" {
+			mimeType = http.DetectContentType(payload)
+		}
+		if opts.outputFormat != "" {
+			var err error
+			if payload, mimeType, err = convertImageFormat(payload, mimeType, opts.outputFormat); err != nil {
+				writeOpenAIError(w, http.StatusBadGateway, "server_error", "convert image: "+err.Error())
+				return
+			}
+		}
+		outputFormat = strings.TrimPrefix(mimeType, "image/")
+		totalBytes += len(payload)
+
+		if opts.responseFormat == "url" {
+			name := fmt.Sprintf("api-%s-%s%s", time.Now().Format("20060102-150405"), randomSuffix(), imageExtensionForContentType(mimeType))
+			if _, err := mediautil.SaveFile(payload, s.imageOutputDir(), "", name, "image"); err != nil {
+				writeOpenAIError(w, http.StatusInternalServerError, "server_error", err.Error())
+				return
+			}
+			data = append(data, map[string]any{"url": serveRequestOrigin(r) + s.cfg.imagesRoute() + name})
+			continue
+		}
+		data = append(data, map[string]any{"b64_json": base64.StdEncoding.EncodeToString(payload)})
+	}
+
+	log.Printf("[serve] images.%s provider=%s images=%d bytes=%d user=%q duration=%s", operation, providerName, len(results), totalBytes, user, time.Since(start).Round(time.Millisecond))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"created":       time.Now().Unix(),
+		"data":          data,
+		"output_format": outputFormat,
+	})
+}
+
+// serveRequestOrigin returns the scheme and host the client used to reach
+// the server, for building absolute URLs.
+func serveRequestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/image"
+)
+
+func TestParseServeImageSize(t *testing.T) {
+	tests := []struct {
+		size, tier, ratio string
+	}{
+		{"", "", ""},
+		{"auto", "", ""},
+		{"2k", "2K", ""},
+		{"1024x1024", "1K", "1:1"},
+		{"1536x1024", "1K", "3:2"},
+		{"1024x1792", "2K", "9:16"},
+		{"3840x2160", "4K", "16:9"},
+	}
+	for _, tt := range tests {
+		tier, ratio, err := parseServeImageSize(tt.size)
+		if err != nil || tier != tt.tier || ratio != tt.ratio {
+			t.Errorf("parseServeImageSize(%q) = %q, %q, %v; want %q, %q", tt.size, tier, ratio, err, tt.tier, tt.ratio)
+		}
+	}
+	for _, size := range []string{"big", "0x10", "10x"} {
+		if _, _, err := parseServeImageSize(size); err == nil {
+			t.Errorf("parseServeImageSize(%q) succeeded, want error", size)
+		}
+	}
+}
+
+func TestResolveServeImageProvider(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Image.Provider = "venice"
+	tests := map[string]string{
+		"":                 "",
+		"debug":            "debug",
+		"openai:my-model":  "openai:my-model",
+		"gpt-image-1":      "openai:gpt-image-1",
+		"imagen-4.0":       "gemini:imagen-4.0",
+		"some-venice-mode": "venice:some-venice-mode",
+	}
+	for requested, want := range tests {
+		if got := resolveServeImageProvider(cfg, requested); got != want {
+			t.Errorf("resolveServeImageProvider(%q) = %q, want %q", requested, got, want)
+		}
+	}
+}
+
+func stubServeImageProvider(t *testing.T) *string {
+	t.Helper()
+	var gotSpec string
+	old := newServeImageProvider
+	newServeImageProvider = func(cfg *config.Config, spec string) (image.ImageProvider, error) {
+		gotSpec = spec
+		return image.NewDebugProvider(0), nil
+	}
+	t.Cleanup(func() { newServeImageProvider = old })
+	return &gotSpec
+}
+
+func TestHandleImagesGenerations(t *testing.T) {
+	gotSpec := stubServeImageProvider(t)
+	outputDir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.Image.OutputDir = outputDir
+	s := &serveServer{cfg: serveServerConfig{basePath: "/ui"}, cfgRef: cfg}
+
+	rr := httptest.NewRecorder()
+	s.handleImagesGenerations(rr, httptest.NewRequest(http.MethodPost, "/v1/images/generations", strings.NewReader(`{"model":"gpt-image-1","prompt":"a cat","n":2,"output_format":"jpeg"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", rr.Code, rr.Body.String())
+	}
+	if *gotSpec != "openai:gpt-image-1" {
+		t.Fatalf("spec = %q", *gotSpec)
+	}
+	var resp struct {
+		Data []struct {
+			B64JSON string `json:"b64_json"`
+			URL     string `json:"url"`
+		} `json:"data"`
+		OutputFormat string `json:"output_format"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Data) != 2 || resp.OutputFormat != "jpeg" {
+		t.Fatalf("resp = %+v", resp)
+	}
+	raw, err := base64.StdEncoding.DecodeString(resp.Data[0].B64JSON)
+	if err != nil || http.DetectContentType(raw) != "image/jpeg" {
+		t.Fatalf("b64_json is not a jpeg: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/images/generations", strings.NewReader(`{"prompt":"a dog","response_format":"url"}`))
+	req.Host = "example.test"
+	s.handleImagesGenerations(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("url status = %d body = %s", rr.Code, rr.Body.String())
+	}
+	resp.Data = nil
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || len(resp.Data) != 1 {
+		t.Fatalf("decode url response: %v body = %s", err, rr.Body.String())
+	}
+	name, ok := strings.CutPrefix(resp.Data[0].URL, "http://example.test/ui/images/")
+	if !ok || !strings.HasSuffix(name, ".png") {
+		t.Fatalf("url = %q", resp.Data[0].URL)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, name)); err != nil {
+		t.Fatalf("saved image: %v", err)
+	}
+
+	for body, want := range map[string]string{
+		`{"prompt":""}`:                           "prompt is required",
+		`{"prompt":"x","n":5}`:                    "n must be between",
+		`{"prompt":"x","size":"huge"}`:            "invalid size",
+		`{"prompt":"x","response_format":"file"}`: "response_format",
+		`{"prompt":"x","output_format":"webp"}`:   "output_format",
+	} {
+		rr := httptest.NewRecorder()
+		s.handleImagesGenerations(rr, httptest.NewRequest(http.MethodPost, "/v1/images/generations", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("%s: status = %d body = %s", body, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestHandleImagesEdits(t *testing.T) {
+	stubServeImageProvider(t)
+	s := &serveServer{cfgRef: &config.Config{}}
+	png, err := image.NewDebugProvider(0).Generate(t.Context(), image.GenerateRequest{Prompt: "input"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newRequest := func(fields map[string]string, files ...string) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for k, v := range fields {
+			_ = mw.WriteField(k, v)
+		}
+		for _, field := range files {
+			fw, _ := mw.CreateFormFile(field, "input.bin")
+			_, _ = fw.Write(png.Data)
+		}
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/images/edits", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return req
+	}
+
+	rr := httptest.NewRecorder()
+	s.handleImagesEdits(rr, newRequest(map[string]string{"prompt": "make it blue"}, "image"))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"b64_json"`) {
+		t.Fatalf("status = %d body = %s", rr.Code, rr.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		substr string
+	}{
+		{"mask", newRequest(map[string]string{"prompt": "x"}, "image", "mask"), "mask is not supported"},
+		{"no image", newRequest(map[string]string{"prompt": "x"}), "image is required"},
+		{"multi image", newRequest(map[string]string{"prompt": "x"}, "image[]", "image[]"), "multiple input images"},
+		{"json body", httptest.NewRequest(http.MethodPost, "/v1/images/edits", strings.NewReader(`{}`)), "multipart/form-data"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		s.handleImagesEdits(rr, tt.req)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), tt.substr) {
+			t.Errorf("%s: status = %d body = %s", tt.name, rr.Code, rr.Body.String())
+		}
+	}
+}
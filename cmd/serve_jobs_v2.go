@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -26,6 +27,10 @@ import (
 	runpkg "github.com/samsaffron/term-llm/internal/run"
 	"github.com/samsaffron/term-llm/internal/session"
 	"github.com/samsaffron/term-llm/internal/tools"
+	"github.com/samsaffron/term-llm/internal/tracing"
+	"github.com/samsaffron/term-llm/internal/usage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	_ "modernc.org/sqlite"
 )
 
@@ -44,10 +49,14 @@ type serveJobsExecutor func(ctx context.Context, cfg jobsV2LLMConfig, onEvent fu
 const (
 	jobsV2RunnerLLM     jobsV2RunnerType = jobsV2RunnerType(jobs.RunnerLLM)
 	jobsV2RunnerProgram jobsV2RunnerType = jobsV2RunnerType(jobs.RunnerProgram)
+	jobsV2RunnerBatch   jobsV2RunnerType = jobsV2RunnerType(jobs.RunnerBatch)
 
-	jobsV2TriggerManual jobsV2TriggerType = jobsV2TriggerType(jobs.TriggerManual)
-	jobsV2TriggerOnce   jobsV2TriggerType = jobsV2TriggerType(jobs.TriggerOnce)
-	jobsV2TriggerCron   jobsV2TriggerType = jobsV2TriggerType(jobs.TriggerCron)
+	jobsV2TriggerManual  jobsV2TriggerType = jobsV2TriggerType(jobs.TriggerManual)
+	jobsV2TriggerOnce    jobsV2TriggerType = jobsV2TriggerType(jobs.TriggerOnce)
+	jobsV2TriggerCron    jobsV2TriggerType = jobsV2TriggerType(jobs.TriggerCron)
+	jobsV2TriggerAfter   jobsV2TriggerType = jobsV2TriggerType(jobs.TriggerAfter)
+	jobsV2TriggerWatch   jobsV2TriggerType = jobsV2TriggerType(jobs.TriggerWatch)
+	jobsV2TriggerWebhook jobsV2TriggerType = jobsV2TriggerType(jobs.TriggerWebhook)
 
 	jobsV2RunQueued          jobsV2RunStatus = jobsV2RunStatus(jobs.RunQueued)
 	jobsV2RunClaimed         jobsV2RunStatus = jobsV2RunStatus(jobs.RunClaimed)
@@ -63,6 +72,15 @@ const (
 	jobsV2MisfireRun  = "run"
 )
 
+// Defaults CreateJob fills in for omitted fields. jobs apply compares
+// against them, so dropping a field from a definition resets it.
+const (
+	jobsV2DefaultTimeoutSeconds    = 300
+	jobsV2DefaultConcurrencyPolicy = "forbid"
+	jobsV2DefaultMaxConcurrentRuns = 1
+	jobsV2DefaultMisfirePolicy     = jobsV2MisfireSkip
+)
+
 const (
 	exitReasonNatural    = jobs.ExitReasonNatural
 	exitReasonMaxTurns   = jobs.ExitReasonMaxTurns
@@ -85,10 +103,35 @@ type jobsV2TriggerConfig struct {
 	RunAt      string `json:"run_at,omitempty"`
 	Expression string `json:"expression,omitempty"`
 	Timezone   string `json:"timezone,omitempty"`
+
+	// After-trigger fields: fire when a run of JobID reaches OnStatus
+	// (succeeded, failed, any). PassUpstream exposes the upstream run's
+	// response and session to the downstream prompt; see serve_jobs_v2_chain.go.
+	JobID        string `json:"job_id,omitempty"`
+	OnStatus     string `json:"on_status,omitempty"`
+	PassUpstream bool   `json:"pass_upstream,omitempty"`
+
+	// Watch-trigger fields: poll Dir for changes to files matching Patterns
+	// (doublestar globs relative to Dir) and not Ignore; see
+	// serve_jobs_v2_watch.go.
+	Dir          string   `json:"dir,omitempty"`
+	Patterns     []string `json:"patterns,omitempty"`
+	Ignore       []string `json:"ignore,omitempty"`
+	Debounce     string   `json:"debounce,omitempty"`
+	PollInterval string   `json:"poll_interval,omitempty"`
+
+	// Webhook-trigger fields: deliveries to POST /v2/jobs/{id}/hooks must be
+	// signed with Secret (or the value of the SecretEnv variable); see
+	// serve_jobs_v2_webhook.go.
+	Secret         string   `json:"secret,omitempty"`
+	SecretEnv      string   `json:"secret_env,omitempty"`
+	SignatureStyle string   `json:"signature_style,omitempty"`
+	Events         []string `json:"events,omitempty"`
 }
 
 type jobsV2Job struct {
 	ID                string            `json:"id"`
+	Key               string            `json:"key,omitempty"`
 	Name              string            `json:"name"`
 	Enabled           bool              `json:"enabled"`
 	RunnerType        jobsV2RunnerType  `json:"runner_type"`
@@ -102,26 +145,39 @@ type jobsV2Job struct {
 	TimeoutSeconds    int               `json:"timeout_seconds,omitempty"`
 	MisfirePolicy     string            `json:"misfire_policy,omitempty"`
 	Labels            json.RawMessage   `json:"labels,omitempty"`
+	Artifacts         json.RawMessage   `json:"artifacts,omitempty"`
 	NextRunAt         *time.Time        `json:"next_run_at,omitempty"`
 	LastRun           *jobsV2Run        `json:"last_run,omitempty"`
 	CreatedAt         time.Time         `json:"created_at"`
 	UpdatedAt         time.Time         `json:"updated_at"`
 }
 
+// MarshalJSON redacts the webhook secret, so API responses, the CLI and
+// remote workers only ever see trigger_config.secret_set.
+func (j jobsV2Job) MarshalJSON() ([]byte, error) {
+	type plainJob jobsV2Job
+	out := plainJob(j)
+	out.TriggerConfig = redactJobsV2WebhookSecret(j.TriggerConfig)
+	return json.Marshal(out)
+}
+
 type jobsV2JobRequest struct {
-	Name              string            `json:"name"`
-	Enabled           *bool             `json:"enabled,omitempty"`
-	RunnerType        jobsV2RunnerType  `json:"runner_type"`
-	RunnerConfig      json.RawMessage   `json:"runner_config"`
-	TriggerType       jobsV2TriggerType `json:"trigger_type"`
-	TriggerConfig     json.RawMessage   `json:"trigger_config"`
-	ScheduleTimezone  string            `json:"schedule_timezone,omitempty"`
-	ConcurrencyPolicy string            `json:"concurrency_policy,omitempty"`
-	MaxConcurrentRuns int               `json:"max_concurrent_runs,omitempty"`
-	RetryPolicy       json.RawMessage   `json:"retry_policy,omitempty"`
-	TimeoutSeconds    int               `json:"timeout_seconds,omitempty"`
-	MisfirePolicy     string            `json:"misfire_policy,omitempty"`
-	Labels            json.RawMessage   `json:"labels,omitempty"`
+	Key           string            `json:"key,omitempty"`
+	Name          string            `json:"name"`
+	Enabled       *bool             `json:"enabled,omitempty"`
+	RunnerType    jobsV2RunnerType  `json:"runner_type"`
+	RunnerConfig  json.RawMessage   `json:"runner_config"`
+	TriggerType   jobsV2TriggerType `json:"trigger_type"`
+	TriggerConfig json.RawMessage   `json:"trigger_config"`
+	// ScheduleTimezone is a pointer so a patch can clear it with "".
+	ScheduleTimezone  *string         `json:"schedule_timezone,omitempty"`
+	ConcurrencyPolicy string          `json:"concurrency_policy,omitempty"`
+	MaxConcurrentRuns int             `json:"max_concurrent_runs,omitempty"`
+	RetryPolicy       json.RawMessage `json:"retry_policy,omitempty"`
+	TimeoutSeconds    int             `json:"timeout_seconds,omitempty"`
+	MisfirePolicy     string          `json:"misfire_policy,omitempty"`
+	Labels            json.RawMessage `json:"labels,omitempty"`
+	Artifacts         json.RawMessage `json:"artifacts,omitempty"`
 }
 
 func (req jobsV2JobRequest) toJob(defaultEnabled bool) jobsV2Job {
@@ -129,39 +185,51 @@ func (req jobsV2JobRequest) toJob(defaultEnabled bool) jobsV2Job {
 	if req.Enabled != nil {
 		enabled = *req.Enabled
 	}
+	var scheduleTimezone string
+	if req.ScheduleTimezone != nil {
+		scheduleTimezone = *req.ScheduleTimezone
+	}
 	return jobsV2Job{
+		Key:               req.Key,
 		Name:              req.Name,
 		Enabled:           enabled,
 		RunnerType:        req.RunnerType,
 		RunnerConfig:      req.RunnerConfig,
 		TriggerType:       req.TriggerType,
 		TriggerConfig:     req.TriggerConfig,
-		ScheduleTimezone:  req.ScheduleTimezone,
+		ScheduleTimezone:  scheduleTimezone,
 		ConcurrencyPolicy: req.ConcurrencyPolicy,
 		MaxConcurrentRuns: req.MaxConcurrentRuns,
 		RetryPolicy:       req.RetryPolicy,
 		TimeoutSeconds:    req.TimeoutSeconds,
 		MisfirePolicy:     req.MisfirePolicy,
 		Labels:            req.Labels,
+		Artifacts:         req.Artifacts,
 	}
 }
 
 func jobsV2JobToRequest(req jobsV2Job) jobsV2JobRequest {
 	enabled := req.Enabled
+	var scheduleTimezone *string
+	if req.ScheduleTimezone != "" {
+		scheduleTimezone = &req.ScheduleTimezone
+	}
 	return jobsV2JobRequest{
+		Key:               req.Key,
 		Name:              req.Name,
 		Enabled:           &enabled,
 		RunnerType:        req.RunnerType,
 		RunnerConfig:      req.RunnerConfig,
 		TriggerType:       req.TriggerType,
 		TriggerConfig:     req.TriggerConfig,
-		ScheduleTimezone:  req.ScheduleTimezone,
+		ScheduleTimezone:  scheduleTimezone,
 		ConcurrencyPolicy: req.ConcurrencyPolicy,
 		MaxConcurrentRuns: req.MaxConcurrentRuns,
 		RetryPolicy:       req.RetryPolicy,
 		TimeoutSeconds:    req.TimeoutSeconds,
 		MisfirePolicy:     req.MisfirePolicy,
 		Labels:            req.Labels,
+		Artifacts:         req.Artifacts,
 	}
 }
 
@@ -187,8 +255,12 @@ type jobsV2Run struct {
 	TurnCount    int             `json:"turn_count,omitempty"`
 	InputTokens  int             `json:"input_tokens,omitempty"`
 	OutputTokens int             `json:"output_tokens,omitempty"`
-	CreatedAt    time.Time       `json:"created_at"`
-	UpdatedAt    time.Time       `json:"updated_at"`
+	ParentRunID  string          `json:"parent_run_id,omitempty"`
+	// TriggerPayload carries trigger-specific input for the run, such as the
+	// paths that changed for a watch trigger. Omitted from run summaries.
+	TriggerPayload json.RawMessage `json:"trigger_payload,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
 }
 
 type jobsV2RunEvent struct {
@@ -224,6 +296,23 @@ type jobsV2Runner interface {
 	Run(ctx context.Context, job jobsV2Job, pw progressWriter) (jobsV2RunResult, error)
 }
 
+// jobsV2ResumableRunner is implemented by runners that checkpoint their
+// progress. A run interrupted by worker shutdown goes back to the queue
+// instead of being cancelled, and picks up where it stopped.
+type jobsV2ResumableRunner interface {
+	resumesAfterShutdown() bool
+}
+
+type jobsV2ShutdownContextKey struct{}
+
+// jobsV2ShuttingDown reports whether ctx belongs to a run whose worker is
+// shutting down. Runners use it to tell shutdown apart from cancellation
+// once ctx is done.
+func jobsV2ShuttingDown(ctx context.Context) bool {
+	closed, _ := ctx.Value(jobsV2ShutdownContextKey{}).(func() bool)
+	return closed != nil && closed()
+}
+
 type jobsV2RunDoneNotifier func(ctx context.Context, run jobsV2Run, job jobsV2Job, status jobsV2RunStatus, result jobsV2RunResult, exitReason string, truncated bool, errText string) error
 
 type jobsV2ProgramRunner struct{}
@@ -266,6 +355,7 @@ func (r *jobsV2ProgramRunner) Run(ctx context.Context, job jobsV2Job, pw progres
 		TimeoutSeconds:    job.TimeoutSeconds,
 		MisfirePolicy:     job.MisfirePolicy,
 		Labels:            job.Labels,
+		Artifacts:         job.Artifacts,
 		NextRunAt:         job.NextRunAt,
 		CreatedAt:         job.CreatedAt,
 		UpdatedAt:         job.UpdatedAt,
@@ -287,8 +377,12 @@ func (r *jobsV2ProgramRunner) Run(ctx context.Context, job jobsV2Job, pw progres
 
 type jobsV2LLMRunner struct {
 	exec serveJobsExecutor
+	// recordUsage returns the quota recorder for a serve API key, or nil.
+	recordUsage func(keyID string) func(usage.LogEntry)
 }
 
+type jobsV2ChangeDetection = jobs.ChangeDetection
+
 type jobsV2NotifyOrigin struct {
 	Origin         string `json:"origin,omitempty"`
 	SessionID      string `json:"session_id,omitempty"`
@@ -306,6 +400,9 @@ type jobsV2LLMConfig struct {
 	SessionName    string              `json:"session_name,omitempty"`
 	NotifyWhenDone bool                `json:"notify_when_done,omitempty"`
 	NotifyOrigin   *jobsV2NotifyOrigin `json:"notify_origin,omitempty"`
+	// NotifyOnChange suppresses completion notifications for successful runs
+	// whose output matches the previous successful run.
+	NotifyOnChange *jobsV2ChangeDetection `json:"notify_on_change,omitempty"`
 
 	// cwd is REQUIRED: it roots this run's file/shell tools at a directory so a
 	// job never silently inherits the jobs server's process working directory.
@@ -325,6 +422,12 @@ type jobsV2LLMConfig struct {
 	Search          bool     `json:"search,omitempty"`
 	SystemMessage   string   `json:"system_message,omitempty"`
 	Skills          string   `json:"skills,omitempty"`
+
+	// APIKeyID and APIKeyName attribute run usage to the serve API key that
+	// created or last configured the job. Set by the server, never trusted
+	// from a key's request.
+	APIKeyID   string `json:"api_key_id,omitempty"`
+	APIKeyName string `json:"api_key_name,omitempty"`
 }
 
 func (c jobsV2LLMConfig) sessionPersistenceEnabled() bool {
@@ -387,6 +490,13 @@ func (r *jobsV2LLMRunner) Run(ctx context.Context, job jobsV2Job, pw progressWri
 	if delegationID := hubDelegationIDFromJobLabels(job.Labels); delegationID != "" {
 		ctx = tools.WithHubDelegationID(ctx, delegationID)
 	}
+	if cfg.APIKeyName != "" {
+		attribution := usage.Attribution{APIKey: cfg.APIKeyName}
+		if r.recordUsage != nil && cfg.APIKeyID != "" {
+			attribution.Record = r.recordUsage(cfg.APIKeyID)
+		}
+		ctx = usage.WithAttribution(ctx, attribution)
+	}
 	var thinkingBuilder strings.Builder
 	var thinkingItemID string
 	var responseBuilder strings.Builder
@@ -519,8 +629,25 @@ type jobsV2Manager struct {
 	workerWake    chan struct{}
 	wg            sync.WaitGroup
 	cancels       map[string]context.CancelFunc
-	notifyCtx     context.Context
-	notifyCancel  context.CancelFunc
+	// watchers holds the running file watcher per enabled watch-triggered
+	// job, keyed by job ID. Guarded by mu.
+	watchers     map[string]*jobsV2Watcher
+	notifyCtx    context.Context
+	notifyCancel context.CancelFunc
+	// artifactDir stores captured run artifacts as <artifactDir>/<run_id>/<path>.
+	// Empty disables capture. artifactDirTemp marks a per-process directory
+	// created for an in-memory database, removed on Close.
+	artifactDir     string
+	artifactDirTemp bool
+	// workerLabels are the labels this process's own workers advertise for
+	// labels.worker_labels routing. Guarded by mu.
+	workerLabels []string
+	// runObservers are called with each run that reaches a final status.
+	// Guarded by mu.
+	runObservers []func(jobsV2Run)
+	// remoteWake is closed (and cleared) whenever runs may have been queued,
+	// waking remote workers long-polling for a claim. Guarded by mu.
+	remoteWake chan struct{}
 }
 
 const jobsV2Schema = `
@@ -539,6 +666,8 @@ CREATE TABLE IF NOT EXISTS jobs_v2 (
 	timeout_seconds INTEGER NOT NULL DEFAULT 300,
 	misfire_policy TEXT NOT NULL DEFAULT 'skip',
 	labels TEXT,
+	artifacts TEXT,
+	key TEXT,
 	next_run_at TIMESTAMP,
 	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
@@ -566,6 +695,8 @@ CREATE TABLE IF NOT EXISTS job_runs_v2 (
 	turn_count INTEGER NOT NULL DEFAULT 0,
 	input_tokens INTEGER NOT NULL DEFAULT 0,
 	output_tokens INTEGER NOT NULL DEFAULT 0,
+	parent_run_id TEXT,
+	trigger_payload TEXT,
 	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
@@ -584,6 +715,17 @@ CREATE TABLE IF NOT EXISTS job_run_events_v2 (
 
 CREATE INDEX IF NOT EXISTS idx_job_run_events_v2_run_id_id ON job_run_events_v2(run_id, id);
 DROP INDEX IF EXISTS idx_job_run_events_v2_run_id;
+
+CREATE TABLE IF NOT EXISTS job_run_artifacts_v2 (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id TEXT NOT NULL REFERENCES job_runs_v2(id) ON DELETE CASCADE,
+	path TEXT NOT NULL,
+	size_bytes INTEGER NOT NULL,
+	content_type TEXT,
+	sha256 TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE(run_id, path)
+);
 `
 
 func newJobsV2Manager(dbPath string, workers int, llmExec serveJobsExecutor) (*jobsV2Manager, error) {
@@ -591,6 +733,15 @@ func newJobsV2Manager(dbPath string, workers int, llmExec serveJobsExecutor) (*j
 }
 
 func newJobsV2ManagerWithNotifier(dbPath string, workers int, llmExec serveJobsExecutor, notifyDone jobsV2RunDoneNotifier) (*jobsV2Manager, error) {
+	return newJobsV2ManagerWithRunners(dbPath, workers, map[jobsV2RunnerType]jobsV2Runner{
+		jobsV2RunnerProgram: &jobsV2ProgramRunner{},
+		jobsV2RunnerLLM:     &jobsV2LLMRunner{exec: llmExec},
+	}, notifyDone)
+}
+
+// newJobsV2ManagerWithRunners creates a manager whose workers start with
+// runners already registered, so recovered runs never miss their runner.
+func newJobsV2ManagerWithRunners(dbPath string, workers int, runners map[jobsV2RunnerType]jobsV2Runner, notifyDone jobsV2RunDoneNotifier) (*jobsV2Manager, error) {
 	if workers < 0 {
 		workers = 1
 	}
@@ -635,6 +786,11 @@ func newJobsV2ManagerWithNotifier(dbPath string, workers int, llmExec serveJobsE
 		`ALTER TABLE job_runs_v2 ADD COLUMN input_tokens INTEGER NOT NULL DEFAULT 0`,
 		`ALTER TABLE job_runs_v2 ADD COLUMN output_tokens INTEGER NOT NULL DEFAULT 0`,
 		`ALTER TABLE job_runs_v2 ADD COLUMN session_id TEXT`,
+		`ALTER TABLE job_runs_v2 ADD COLUMN parent_run_id TEXT`,
+		`ALTER TABLE job_runs_v2 ADD COLUMN trigger_payload TEXT`,
+		`ALTER TABLE jobs_v2 ADD COLUMN artifacts TEXT`,
+		`ALTER TABLE jobs_v2 ADD COLUMN key TEXT`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_v2_key ON jobs_v2(key) WHERE key IS NOT NULL`,
 	}
 	for _, migration := range migrations {
 		_, _ = db.Exec(migration)
@@ -651,6 +807,7 @@ func newJobsV2ManagerWithNotifier(dbPath string, workers int, llmExec serveJobsE
 	}{
 		{name: jobsV2RunSummaryIndexName, sql: jobsV2RunSummaryIndexSQL},
 		{name: jobsV2RunGlobalSummaryIndexName, sql: jobsV2RunGlobalSummaryIndexSQL},
+		{name: jobsV2RunParentIndexName, sql: jobsV2RunParentIndexSQL},
 	} {
 		if _, err := db.Exec(index.sql); err != nil {
 			db.Close()
@@ -658,6 +815,21 @@ func newJobsV2ManagerWithNotifier(dbPath string, workers int, llmExec serveJobsE
 		}
 	}
 	_, _ = db.Exec(`DROP INDEX IF EXISTS idx_job_runs_v2_job_id`)
+	for _, legacy := range jobsV2LegacyRunSummaryIndexNames {
+		_, _ = db.Exec(`DROP INDEX IF EXISTS ` + legacy)
+	}
+
+	artifactDir, artifactDirTemp := "", false
+	if dbPath == ":memory:" {
+		artifactDir, err = os.MkdirTemp("", "jobs_v2_artifacts-")
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("create jobs artifact dir: %w", err)
+		}
+		artifactDirTemp = true
+	} else {
+		artifactDir = filepath.Join(filepath.Dir(dbPath), "jobs_v2_artifacts")
+	}
 
 	notifyCtx, notifyCancel := context.WithCancel(context.Background())
 	mgr := &jobsV2Manager{
@@ -672,16 +844,16 @@ func newJobsV2ManagerWithNotifier(dbPath string, workers int, llmExec serveJobsE
 		retentionEventDays:  30,
 		retentionMaxRunsJob: 1000,
 		cleanupInterval:     time.Hour,
-		runners: map[jobsV2RunnerType]jobsV2Runner{
-			jobsV2RunnerProgram: &jobsV2ProgramRunner{},
-			jobsV2RunnerLLM:     &jobsV2LLMRunner{exec: llmExec},
-		},
-		done:          make(chan struct{}),
-		schedulerWake: make(chan struct{}, 1),
-		workerWake:    make(chan struct{}, max(1, workers)),
-		cancels:       make(map[string]context.CancelFunc),
-		notifyCtx:     notifyCtx,
-		notifyCancel:  notifyCancel,
+		runners:             runners,
+		done:                make(chan struct{}),
+		schedulerWake:       make(chan struct{}, 1),
+		workerWake:          make(chan struct{}, max(1, workers)),
+		cancels:             make(map[string]context.CancelFunc),
+		watchers:            make(map[string]*jobsV2Watcher),
+		notifyCtx:           notifyCtx,
+		notifyCancel:        notifyCancel,
+		artifactDir:         artifactDir,
+		artifactDirTemp:     artifactDirTemp,
 	}
 
 	if err := mgr.recoverRuns(); err != nil {
@@ -706,7 +878,7 @@ func newJobsV2ManagerWithNotifier(dbPath string, workers int, llmExec serveJobsE
 }
 
 func execJobsV2Schema(db *sql.DB) error {
-	stmts := strings.Split(jobsV2Schema, ";")
+	stmts := strings.Split(jobsV2Schema+";"+jobsV2WorkersSchema, ";")
 	for _, stmt := range stmts {
 		stmt = strings.TrimSpace(stmt)
 		if stmt == "" {
@@ -720,7 +892,7 @@ func execJobsV2Schema(db *sql.DB) error {
 }
 
 func (m *jobsV2Manager) recoverRuns() error {
-	rows, err := m.db.Query(`SELECT id, job_id, attempt, trigger, scheduled_for, status, worker_id, session_id, started_at, finished_at, exit_code, error, stdout, stderr, thinking, response, exit_reason, truncated, turn_count, input_tokens, output_tokens, created_at, updated_at FROM job_runs_v2 WHERE status IN (?, ?) ORDER BY created_at ASC`, jobsV2RunClaimed, jobsV2RunRunning)
+	rows, err := m.db.Query(`SELECT `+jobsV2RunFullColumns+` FROM job_runs_v2 WHERE status IN (?, ?) ORDER BY created_at ASC`, jobsV2RunClaimed, jobsV2RunRunning)
 	if err != nil {
 		return fmt.Errorf("load interrupted runs: %w", err)
 	}
@@ -910,6 +1082,9 @@ func (m *jobsV2Manager) CloseContext(ctx context.Context) error {
 	}()
 	select {
 	case <-waitDone:
+		if m.artifactDirTemp {
+			_ = os.RemoveAll(m.artifactDir)
+		}
 		return m.db.Close()
 	case <-ctx.Done():
 		return ctx.Err()
@@ -938,6 +1113,14 @@ func (m *jobsV2Manager) schedulerLoop() {
 			resetTimer(timer, jobsV2SchedulerErrorDelay)
 			continue
 		}
+		if err := m.reconcileWatchers(); err != nil {
+			resetTimer(timer, jobsV2SchedulerErrorDelay)
+			continue
+		}
+		if err := m.expireWorkerLeases(now); err != nil {
+			resetTimer(timer, jobsV2SchedulerErrorDelay)
+			continue
+		}
 		if err := m.maybeRunCleanup(now); err != nil {
 			resetTimer(timer, jobsV2SchedulerErrorDelay)
 			continue
@@ -1014,6 +1197,14 @@ func (m *jobsV2Manager) nextSchedulerDelayWithError(now time.Time) (time.Duratio
 		delay = minDuration(delay, nextRun.Time.UTC().Sub(now.UTC()))
 	}
 
+	nextExpiry, ok, err := m.nextLeaseExpiry()
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		delay = minDuration(delay, nextExpiry.UTC().Sub(now.UTC()))
+	}
+
 	if m.cleanupInterval > 0 {
 		m.mu.Lock()
 		lastCleanup := m.lastCleanupCompleted
@@ -1138,7 +1329,10 @@ func (m *jobsV2Manager) pruneOldData(now time.Time) error {
 		}
 	}
 
-	return nil
+	if err := m.pruneWorkers(now); err != nil {
+		return err
+	}
+	return m.pruneArtifacts(now)
 }
 
 func (m *jobsV2Manager) workerLoop() {
@@ -1199,13 +1393,14 @@ func (m *jobsV2Manager) nextWorkerDelayWithError(now time.Time) (time.Duration,
 	}
 	delay := idleDelay
 
-	var nextRun sql.NullTime
-	err := m.db.QueryRow(`SELECT scheduled_for FROM job_runs_v2 WHERE status = ? ORDER BY scheduled_for ASC LIMIT 1`, jobsV2RunQueued).Scan(&nextRun)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+	// Only runs this process may take count: a due run routed to remote
+	// workers must not keep the local loop spinning.
+	_, nextRun, ok, err := nextEligibleQueuedRun(m.db, m.localWorkerLabels(), false, nil)
+	if err != nil {
 		return 0, err
 	}
-	if err == nil && nextRun.Valid {
-		delay = minDuration(delay, nextRun.Time.UTC().Sub(now.UTC()))
+	if ok {
+		delay = minDuration(delay, nextRun.UTC().Sub(now.UTC()))
 	}
 
 	return clampJobsV2Delay(delay, jobsV2WorkerMinDelay, idleDelay), nil
@@ -1225,6 +1420,7 @@ func (m *jobsV2Manager) notifyScheduler() {
 }
 
 func (m *jobsV2Manager) notifyWorkers(n int) {
+	m.wakeRemoteWorkers()
 	if n <= 0 {
 		n = 1
 	}
@@ -1238,7 +1434,7 @@ func (m *jobsV2Manager) notifyWorkers(n int) {
 }
 
 func (m *jobsV2Manager) scheduleDueRuns(now time.Time) error {
-	rows, err := m.db.Query(`SELECT id, name, enabled, runner_type, runner_config, trigger_type, trigger_config, schedule_timezone, concurrency_policy, max_concurrent_runs, retry_policy, timeout_seconds, misfire_policy, labels, next_run_at, created_at, updated_at FROM jobs_v2 WHERE enabled = 1 AND next_run_at IS NOT NULL AND next_run_at <= ? ORDER BY next_run_at ASC LIMIT 200`, now.UTC())
+	rows, err := m.db.Query(`SELECT `+jobsV2JobColumns+` FROM jobs_v2 WHERE enabled = 1 AND next_run_at IS NOT NULL AND next_run_at <= ? ORDER BY next_run_at ASC LIMIT 200`, now.UTC())
 	if err != nil {
 		return err
 	}
@@ -1362,7 +1558,11 @@ func (m *jobsV2Manager) claimNextRun() (jobsV2Run, bool, error) {
 	defer func() { _ = tx.Rollback() }()
 
 	now := time.Now().UTC()
-	row := tx.QueryRow(`SELECT id, job_id, attempt, trigger, scheduled_for, status, worker_id, session_id, started_at, finished_at, exit_code, error, stdout, stderr, thinking, response, exit_reason, truncated, turn_count, input_tokens, output_tokens, created_at, updated_at FROM job_runs_v2 WHERE status = ? AND scheduled_for <= ? ORDER BY scheduled_for ASC LIMIT 1`, jobsV2RunQueued, now)
+	runID, _, ok, err := nextEligibleQueuedRun(tx, m.localWorkerLabels(), false, &now)
+	if err != nil || !ok {
+		return jobsV2Run{}, false, err
+	}
+	row := tx.QueryRow(`SELECT `+jobsV2RunFullColumns+` FROM job_runs_v2 WHERE id = ?`, runID)
 	run, err := scanRunV2(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -1408,6 +1608,25 @@ func (m *jobsV2Manager) requeueClaimedRunAfterShutdown(runID string) {
 	}
 }
 
+// requeueRunningRunAfterShutdown returns a resumable run interrupted by
+// shutdown to the queue. A run whose cancellation was requested is left for
+// recovery to settle.
+func (m *jobsV2Manager) requeueRunningRunAfterShutdown(runID string) {
+	res, err := m.db.Exec(`UPDATE job_runs_v2 SET status = ?, worker_id = NULL, started_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ? AND worker_id = ?`, jobsV2RunQueued, runID, jobsV2RunRunning, m.workerID)
+	if err != nil {
+		log.Printf("jobs v2: failed to requeue interrupted run %q during shutdown: %v", runID, err)
+		return
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return
+	}
+	if err := m.addRunEvent(runID, "requeued", "run returned to queue during worker shutdown; it resumes from its checkpoint", map[string]any{"worker_id": m.workerID}); err != nil {
+		log.Printf("jobs v2: failed to record shutdown requeue event for run %q: %v", runID, err)
+	}
+}
+
+var jobsTracer = tracing.Tracer("jobs")
+
 func (m *jobsV2Manager) executeRun(run jobsV2Run) {
 	// Avoid turning a claim into a terminal failure when shutdown had already won
 	// before this worker began admission. Keep the check below as well: shutdown
@@ -1417,12 +1636,11 @@ func (m *jobsV2Manager) executeRun(run jobsV2Run) {
 		return
 	}
 
-	job, err := m.GetJob(run.JobID)
+	job, err := m.prepareRunJob(run)
 	if err != nil {
-		m.finishRunWithRetry(run.ID, jobsV2RunFailed, jobsV2RunResult{}, fmt.Errorf("load job: %w", err), run.Attempt)
+		m.finishRunWithRetry(run.ID, jobsV2RunFailed, jobsV2RunResult{}, err, run.Attempt)
 		return
 	}
-
 	runner, ok := m.runners[job.RunnerType]
 	if !ok {
 		m.finishRunWithRetry(run.ID, jobsV2RunFailed, jobsV2RunResult{}, fmt.Errorf("unknown runner type: %s", job.RunnerType), run.Attempt)
@@ -1462,29 +1680,37 @@ func (m *jobsV2Manager) executeRun(run jobsV2Run) {
 	}
 	_ = m.addRunEvent(run.ID, "running", "run started", map[string]any{"worker_id": m.workerID})
 
-	// Build a progress writer that writes events and flushes partial response to DB.
-	pw := func(eventType, message string, data any) {
-		switch eventType {
-		case "response_flush":
-			// Update response column so GET /v2/runs/{id} shows partial output.
-			_, _ = m.db.Exec(`UPDATE job_runs_v2 SET response = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, message, run.ID)
-		case "progress_update":
-			if data != nil {
-				if payload, err := json.Marshal(data); err == nil {
-					_, _ = m.db.Exec(`UPDATE job_runs_v2 SET response = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, string(payload), run.ID)
-				}
-			}
-			_ = m.addRunEvent(run.ID, eventType, message, data)
-		default:
-			_ = m.addRunEvent(run.ID, eventType, message, data)
-		}
+	// Delegated runs continue the delegating node's trace; others start one.
+	spanParent := tracing.ContextWithTraceParent(ctx, hubDelegationLabelFromJobLabels(job.Labels).TraceParent)
+	runCtx, span := jobsTracer.Start(spanParent, "job.run", trace.WithAttributes(
+		attribute.String("term_llm.job.id", job.ID),
+		attribute.String("term_llm.job.name", job.Name),
+		attribute.String("term_llm.job.run_id", run.ID),
+		attribute.String("term_llm.job.runner_type", string(job.RunnerType)),
+		attribute.String("term_llm.job.trigger", run.Trigger),
+		attribute.Int("term_llm.job.attempt", run.Attempt),
+	))
+	runCtx = context.WithValue(runCtx, jobsV2ShutdownContextKey{}, m.isClosed)
+	result, runErr := runner.Run(runCtx, job, m.runProgressWriter(run.ID))
+	span.SetAttributes(
+		attribute.String("term_llm.session_id", result.SessionID),
+		attribute.Int("gen_ai.usage.input_tokens", result.InputTokens),
+		attribute.Int("gen_ai.usage.output_tokens", result.OutputTokens),
+	)
+	if result.ExitReason != "" {
+		span.SetAttributes(attribute.String("term_llm.job.exit_reason", result.ExitReason))
 	}
-	result, runErr := runner.Run(ctx, job, pw)
+	tracing.EndSpan(span, runErr)
+	m.captureRunArtifacts(run.ID, job, started)
 	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 		m.finishRunWithRetry(run.ID, jobsV2RunTimedOut, result, context.DeadlineExceeded, run.Attempt)
 		return
 	}
 	if errors.Is(ctx.Err(), context.Canceled) {
+		if resumable, ok := runner.(jobsV2ResumableRunner); ok && resumable.resumesAfterShutdown() && m.isClosed() {
+			m.requeueRunningRunAfterShutdown(run.ID)
+			return
+		}
 		m.finishRunWithRetry(run.ID, jobsV2RunCancelled, result, context.Canceled, run.Attempt)
 		return
 	}
@@ -1503,6 +1729,54 @@ func (m *jobsV2Manager) executeRun(run jobsV2Run) {
 	m.finishRunWithRetry(run.ID, jobsV2RunSucceeded, result, nil, run.Attempt)
 }
 
+// runProgressWriter records a run's progress events and flushes partial
+// responses to the run row.
+func (m *jobsV2Manager) runProgressWriter(runID string) progressWriter {
+	return func(eventType, message string, data any) {
+		switch eventType {
+		case "response_flush":
+			// Update response column so GET /v2/runs/{id} shows partial output.
+			_, _ = m.db.Exec(`UPDATE job_runs_v2 SET response = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, message, runID)
+		case "progress_update":
+			if data != nil {
+				if payload, err := json.Marshal(data); err == nil {
+					_, _ = m.db.Exec(`UPDATE job_runs_v2 SET response = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, string(payload), runID)
+				}
+			}
+			_ = m.addRunEvent(runID, eventType, message, data)
+		default:
+			_ = m.addRunEvent(runID, eventType, message, data)
+		}
+	}
+}
+
+// prepareRunJob loads the job for run and applies the upstream, watch or
+// webhook context the run was queued with.
+func (m *jobsV2Manager) prepareRunJob(run jobsV2Run) (jobsV2Job, error) {
+	job, err := m.GetJob(run.JobID)
+	if err != nil {
+		return jobsV2Job{}, fmt.Errorf("load job: %w", err)
+	}
+	if run.ParentRunID != "" {
+		if err := m.applyUpstreamContext(&job, run.ParentRunID); err != nil {
+			return jobsV2Job{}, fmt.Errorf("load upstream run: %w", err)
+		}
+	}
+	if len(run.TriggerPayload) > 0 {
+		var err error
+		switch job.TriggerType {
+		case jobsV2TriggerWatch:
+			err = applyWatchContext(&job, run.TriggerPayload)
+		case jobsV2TriggerWebhook:
+			err = applyWebhookContext(&job, run.TriggerPayload)
+		}
+		if err != nil {
+			return jobsV2Job{}, fmt.Errorf("apply %s context: %w", job.TriggerType, err)
+		}
+	}
+	return job, nil
+}
+
 func (m *jobsV2Manager) finishRunWithRetry(runID string, status jobsV2RunStatus, result jobsV2RunResult, runErr error, policyAttempt int) {
 	deadline := time.Now().Add(jobsV2FinishRunRetryWindow)
 	delay := jobsV2FinishRunRetryDelay
@@ -1590,6 +1864,12 @@ func (m *jobsV2Manager) finishRun(runID string, status jobsV2RunStatus, result j
 		"output_tokens": result.OutputTokens,
 	})
 	m.enqueueRunDoneNotification(run, status, result, exitReason, truncated, errText)
+	m.mu.Lock()
+	observers := m.runObservers
+	m.mu.Unlock()
+	for _, observe := range observers {
+		observe(run)
+	}
 
 	if status == jobsV2RunFailed || status == jobsV2RunTimedOut {
 		job, err := m.GetJob(run.JobID)
@@ -1597,10 +1877,17 @@ func (m *jobsV2Manager) finishRun(runID string, status jobsV2RunStatus, result j
 			return nil
 		}
 		policy := decodeRetryPolicy(job.RetryPolicy)
-		if attempt < policy.MaxAttempts {
-			delay := computeRetryDelay(policy, attempt)
+		maxAttempts, delay := policy.MaxAttempts, computeRetryDelay(policy, attempt)
+		if exitReason == exitReasonWorkerLost && m.hasLease(runID) {
+			// A remote worker vanished mid-run; that says nothing about the
+			// job, so re-queue it straight away even without a retry policy.
+			maxAttempts, delay = max(maxAttempts, jobsV2WorkerLostMaxAttempts), 0
+		}
+		if attempt < maxAttempts {
 			retryID := "run_" + randomSuffix()
-			_, _ = m.db.Exec(`INSERT INTO job_runs_v2 (id, job_id, attempt, trigger, scheduled_for, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`, retryID, job.ID, attempt+1, "retry", now.Add(delay), jobsV2RunQueued)
+			// Retries keep the upstream link and trigger payload so chained and
+			// watch context survives a retry.
+			_, _ = m.db.Exec(`INSERT INTO job_runs_v2 (id, job_id, attempt, trigger, scheduled_for, status, parent_run_id, trigger_payload, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`, retryID, job.ID, attempt+1, "retry", now.Add(delay), jobsV2RunQueued, nullableString(run.ParentRunID), nullableRaw(run.TriggerPayload))
 			_ = m.addRunEvent(runID, "retry_scheduled", "retry run scheduled", map[string]any{
 				"retry_run_id": retryID,
 				"next_attempt": attempt + 1,
@@ -1611,9 +1898,13 @@ func (m *jobsV2Manager) finishRun(runID string, status jobsV2RunStatus, result j
 				"attempt": attempt + 1,
 			})
 			m.notifyWorkers(1)
+			return nil
 		}
 	}
 
+	// Downstream jobs only see the final outcome: a failed attempt that is
+	// about to be retried returned above.
+	m.triggerDownstreamJobs(run)
 	return nil
 }
 
@@ -1626,6 +1917,32 @@ func jobsV2NotifyTerminalStatus(status jobsV2RunStatus) bool {
 	}
 }
 
+// AddRunObserver installs a callback for runs reaching a final status.
+func (m *jobsV2Manager) AddRunObserver(fn func(jobsV2Run)) {
+	m.mu.Lock()
+	m.runObservers = append(m.runObservers, fn)
+	m.mu.Unlock()
+}
+
+// ActiveRunCounts returns how many runs are queued, claimed and running.
+func (m *jobsV2Manager) ActiveRunCounts() (map[jobsV2RunStatus]int, error) {
+	rows, err := m.db.Query(`SELECT status, COUNT(1) FROM job_runs_v2 WHERE status IN (?, ?, ?) GROUP BY status`, jobsV2RunQueued, jobsV2RunClaimed, jobsV2RunRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[jobsV2RunStatus]int, 3)
+	for rows.Next() {
+		var status jobsV2RunStatus
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
 func (m *jobsV2Manager) enqueueRunDoneNotification(run jobsV2Run, status jobsV2RunStatus, result jobsV2RunResult, exitReason string, truncated bool, errText string) {
 	if m == nil || m.notifyDone == nil || !jobsV2NotifyTerminalStatus(status) {
 		return
@@ -1856,45 +2173,71 @@ func validateJobsV2MisfirePolicy(policy string) error {
 	}
 }
 
+var jobsV2KeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]{0,127}$`)
+
+// validateJobsV2Key accepts an empty key (unmanaged job) or a short
+// identifier that is safe to use in file names and URLs.
+func validateJobsV2Key(key string) error {
+	if key == "" || jobsV2KeyPattern.MatchString(key) {
+		return nil
+	}
+	return fmt.Errorf("key must start with a letter or digit and contain only letters, digits, '.', '_', '/' or '-' (max 128 characters)")
+}
+
 func (m *jobsV2Manager) CreateJob(req jobsV2Job) (jobsV2Job, error) {
 	if strings.TrimSpace(req.Name) == "" {
 		return jobsV2Job{}, fmt.Errorf("name is required")
 	}
-	if req.RunnerType != jobsV2RunnerLLM && req.RunnerType != jobsV2RunnerProgram {
-		return jobsV2Job{}, fmt.Errorf("runner_type must be one of: llm, program")
+	if req.RunnerType != jobsV2RunnerLLM && req.RunnerType != jobsV2RunnerProgram && req.RunnerType != jobsV2RunnerBatch {
+		return jobsV2Job{}, fmt.Errorf("runner_type must be one of: llm, program, batch")
 	}
 	if err := validateJobsV2RunnerConfig(req.RunnerType, req.RunnerConfig); err != nil {
 		return jobsV2Job{}, err
 	}
-	if req.TriggerType != jobsV2TriggerManual && req.TriggerType != jobsV2TriggerOnce && req.TriggerType != jobsV2TriggerCron {
-		return jobsV2Job{}, fmt.Errorf("trigger_type must be one of: manual, once, cron")
+	if !validJobsV2TriggerType(req.TriggerType) {
+		return jobsV2Job{}, fmt.Errorf("trigger_type must be one of: manual, once, cron, after, watch, webhook")
 	}
 	if req.MaxConcurrentRuns <= 0 {
-		req.MaxConcurrentRuns = 1
+		req.MaxConcurrentRuns = jobsV2DefaultMaxConcurrentRuns
 	}
 	if req.TimeoutSeconds <= 0 {
-		req.TimeoutSeconds = 300
+		req.TimeoutSeconds = jobsV2DefaultTimeoutSeconds
 	}
 	if req.ConcurrencyPolicy == "" {
-		req.ConcurrencyPolicy = "forbid"
+		req.ConcurrencyPolicy = jobsV2DefaultConcurrencyPolicy
 	}
 	req.MisfirePolicy = strings.TrimSpace(req.MisfirePolicy)
 	if req.MisfirePolicy == "" {
-		req.MisfirePolicy = jobsV2MisfireSkip
+		req.MisfirePolicy = jobsV2DefaultMisfirePolicy
 	}
 	if err := validateJobsV2MisfirePolicy(req.MisfirePolicy); err != nil {
 		return jobsV2Job{}, err
 	}
+	if err := validateJobsV2ArtifactPolicy(req.Artifacts, req.RunnerConfig); err != nil {
+		return jobsV2Job{}, err
+	}
+	if _, err := jobsV2RequiredWorkerLabels(req.Labels); err != nil {
+		return jobsV2Job{}, err
+	}
+	req.Key = strings.TrimSpace(req.Key)
+	if err := validateJobsV2Key(req.Key); err != nil {
+		return jobsV2Job{}, err
+	}
 
 	cfg, err := parseTriggerConfig(req.TriggerType, req.TriggerConfig, req.ScheduleTimezone)
 	if err != nil {
 		return jobsV2Job{}, err
 	}
+	id := "job_" + randomSuffix()
+	if req.TriggerType == jobsV2TriggerAfter {
+		if req.TriggerConfig, err = m.resolveAfterTriggerConfig(id, cfg); err != nil {
+			return jobsV2Job{}, err
+		}
+	}
 	next := initialNextRun(req.TriggerType, cfg, req.ScheduleTimezone)
 
 	now := time.Now().UTC()
-	id := "job_" + randomSuffix()
-	_, err = m.db.Exec(`INSERT INTO jobs_v2 (id, name, enabled, runner_type, runner_config, trigger_type, trigger_config, schedule_timezone, concurrency_policy, max_concurrent_runs, retry_policy, timeout_seconds, misfire_policy, labels, next_run_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+	_, err = m.db.Exec(`INSERT INTO jobs_v2 (id, name, enabled, runner_type, runner_config, trigger_type, trigger_config, schedule_timezone, concurrency_policy, max_concurrent_runs, retry_policy, timeout_seconds, misfire_policy, labels, artifacts, key, next_run_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		id,
 		req.Name,
 		boolToInt(req.Enabled),
@@ -1909,6 +2252,8 @@ func (m *jobsV2Manager) CreateJob(req jobsV2Job) (jobsV2Job, error) {
 		req.TimeoutSeconds,
 		req.MisfirePolicy,
 		nullableRaw(req.Labels),
+		nullableRaw(req.Artifacts),
+		nullableString(req.Key),
 		next,
 		now,
 		now,
@@ -1925,7 +2270,7 @@ func (m *jobsV2Manager) CreateJob(req jobsV2Job) (jobsV2Job, error) {
 }
 
 func (m *jobsV2Manager) GetJob(id string) (jobsV2Job, error) {
-	row := m.db.QueryRow(`SELECT id, name, enabled, runner_type, runner_config, trigger_type, trigger_config, schedule_timezone, concurrency_policy, max_concurrent_runs, retry_policy, timeout_seconds, misfire_policy, labels, next_run_at, created_at, updated_at FROM jobs_v2 WHERE id = ?`, id)
+	row := m.db.QueryRow(`SELECT `+jobsV2JobColumns+` FROM jobs_v2 WHERE id = ?`, id)
 	job, err := scanJobV2(row)
 	if err != nil {
 		return jobsV2Job{}, err
@@ -1937,6 +2282,19 @@ func (m *jobsV2Manager) GetJob(id string) (jobsV2Job, error) {
 }
 
 func (m *jobsV2Manager) ListJobs(limit, offset int) ([]jobsV2Job, int, error) {
+	return m.listJobs("", nil, limit, offset)
+}
+
+// ListJobsByRunner lists the jobs of one runner type, newest first. A
+// non-empty apiKeyID limits the list to jobs configured by that API key.
+func (m *jobsV2Manager) ListJobsByRunner(runnerType jobsV2RunnerType, apiKeyID string, limit, offset int) ([]jobsV2Job, int, error) {
+	if apiKeyID != "" {
+		return m.listJobs(`WHERE runner_type = ? AND json_valid(runner_config) AND json_extract(runner_config, '$.api_key_id') = ?`, []any{runnerType, apiKeyID}, limit, offset)
+	}
+	return m.listJobs(`WHERE runner_type = ?`, []any{runnerType}, limit, offset)
+}
+
+func (m *jobsV2Manager) listJobs(where string, args []any, limit, offset int) ([]jobsV2Job, int, error) {
 	if limit <= 0 {
 		limit = 50
 	}
@@ -1947,10 +2305,10 @@ func (m *jobsV2Manager) ListJobs(limit, offset int) ([]jobsV2Job, int, error) {
 		offset = 0
 	}
 	var total int
-	if err := m.db.QueryRow(`SELECT COUNT(1) FROM jobs_v2`).Scan(&total); err != nil {
+	if err := m.db.QueryRow(`SELECT COUNT(1) FROM jobs_v2 `+where, args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
-	rows, err := m.db.Query(`SELECT id, name, enabled, runner_type, runner_config, trigger_type, trigger_config, schedule_timezone, concurrency_policy, max_concurrent_runs, retry_policy, timeout_seconds, misfire_policy, labels, next_run_at, created_at, updated_at FROM jobs_v2 ORDER BY created_at DESC LIMIT ? OFFSET ?`, limit, offset)
+	rows, err := m.db.Query(`SELECT `+jobsV2JobColumns+` FROM jobs_v2 `+where+` ORDER BY created_at DESC LIMIT ? OFFSET ?`, append(args, limit, offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -2037,6 +2395,12 @@ func (m *jobsV2Manager) UpdateJobPatch(id string, req jobsV2JobRequest) (jobsV2J
 	if strings.TrimSpace(req.Name) != "" {
 		current.Name = req.Name
 	}
+	if key := strings.TrimSpace(req.Key); key != "" {
+		if err := validateJobsV2Key(key); err != nil {
+			return jobsV2Job{}, err
+		}
+		current.Key = key
+	}
 	if req.RunnerType != "" {
 		current.RunnerType = req.RunnerType
 	}
@@ -2047,10 +2411,14 @@ func (m *jobsV2Manager) UpdateJobPatch(id string, req jobsV2JobRequest) (jobsV2J
 		current.TriggerType = req.TriggerType
 	}
 	if len(req.TriggerConfig) > 0 {
-		current.TriggerConfig = req.TriggerConfig
+		if current.TriggerType == jobsV2TriggerWebhook {
+			current.TriggerConfig = keepJobsV2WebhookSecret(current.TriggerConfig, req.TriggerConfig)
+		} else {
+			current.TriggerConfig = req.TriggerConfig
+		}
 	}
-	if req.ScheduleTimezone != "" {
-		current.ScheduleTimezone = req.ScheduleTimezone
+	if req.ScheduleTimezone != nil {
+		current.ScheduleTimezone = *req.ScheduleTimezone
 	}
 	if req.ConcurrencyPolicy != "" {
 		current.ConcurrencyPolicy = req.ConcurrencyPolicy
@@ -2070,23 +2438,40 @@ func (m *jobsV2Manager) UpdateJobPatch(id string, req jobsV2JobRequest) (jobsV2J
 	if len(req.Labels) > 0 {
 		current.Labels = req.Labels
 	}
+	if len(req.Artifacts) > 0 {
+		current.Artifacts = req.Artifacts
+	}
 	if req.Enabled != nil {
 		current.Enabled = *req.Enabled
 	}
 
+	if !validJobsV2TriggerType(current.TriggerType) {
+		return jobsV2Job{}, fmt.Errorf("trigger_type must be one of: manual, once, cron, after, watch, webhook")
+	}
 	cfg, err := parseTriggerConfig(current.TriggerType, current.TriggerConfig, current.ScheduleTimezone)
 	if err != nil {
 		return jobsV2Job{}, err
 	}
+	if current.TriggerType == jobsV2TriggerAfter {
+		if current.TriggerConfig, err = m.resolveAfterTriggerConfig(id, cfg); err != nil {
+			return jobsV2Job{}, err
+		}
+	}
 	if err := validateJobsV2MisfirePolicy(current.MisfirePolicy); err != nil {
 		return jobsV2Job{}, err
 	}
 	if err := validateJobsV2RunnerConfig(current.RunnerType, current.RunnerConfig); err != nil {
 		return jobsV2Job{}, err
 	}
+	if err := validateJobsV2ArtifactPolicy(current.Artifacts, current.RunnerConfig); err != nil {
+		return jobsV2Job{}, err
+	}
+	if _, err := jobsV2RequiredWorkerLabels(current.Labels); err != nil {
+		return jobsV2Job{}, err
+	}
 	next := initialNextRun(current.TriggerType, cfg, current.ScheduleTimezone)
 
-	_, err = m.db.Exec(`UPDATE jobs_v2 SET name = ?, enabled = ?, runner_type = ?, runner_config = ?, trigger_type = ?, trigger_config = ?, schedule_timezone = ?, concurrency_policy = ?, max_concurrent_runs = ?, retry_policy = ?, timeout_seconds = ?, misfire_policy = ?, labels = ?, next_run_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+	_, err = m.db.Exec(`UPDATE jobs_v2 SET name = ?, enabled = ?, runner_type = ?, runner_config = ?, trigger_type = ?, trigger_config = ?, schedule_timezone = ?, concurrency_policy = ?, max_concurrent_runs = ?, retry_policy = ?, timeout_seconds = ?, misfire_policy = ?, labels = ?, artifacts = ?, key = ?, next_run_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
 		current.Name,
 		boolToInt(current.Enabled),
 		current.RunnerType,
@@ -2100,6 +2485,8 @@ func (m *jobsV2Manager) UpdateJobPatch(id string, req jobsV2JobRequest) (jobsV2J
 		current.TimeoutSeconds,
 		current.MisfirePolicy,
 		nullableRaw(current.Labels),
+		nullableRaw(current.Artifacts),
+		nullableString(current.Key),
 		next,
 		id,
 	)
@@ -2159,7 +2546,7 @@ func (m *jobsV2Manager) TriggerJob(id string) (jobsV2Run, error) {
 }
 
 func (m *jobsV2Manager) GetRun(id string) (jobsV2Run, error) {
-	row := m.db.QueryRow(`SELECT id, job_id, attempt, trigger, scheduled_for, status, worker_id, session_id, started_at, finished_at, exit_code, error, stdout, stderr, thinking, response, exit_reason, truncated, turn_count, input_tokens, output_tokens, created_at, updated_at FROM job_runs_v2 WHERE id = ?`, id)
+	row := m.db.QueryRow(`SELECT `+jobsV2RunFullColumns+` FROM job_runs_v2 WHERE id = ?`, id)
 	return scanRunV2(row)
 }
 
@@ -2171,17 +2558,32 @@ func (m *jobsV2Manager) ListRunSummaries(jobID string, limit, offset int) ([]job
 	return m.listRuns(jobID, limit, offset, false)
 }
 
-const jobsV2RunSummaryIndexName = "idx_job_runs_v2_summary_by_job_created"
+const jobsV2RunSummaryIndexName = "idx_job_runs_v2_summary_by_job_created_at"
+
+const jobsV2RunSummaryIndexSQL = "CREATE INDEX IF NOT EXISTS " + jobsV2RunSummaryIndexName + " ON job_runs_v2(job_id, created_at DESC, id, attempt, trigger, scheduled_for, status, worker_id, session_id, started_at, finished_at, exit_code, error, exit_reason, truncated, turn_count, input_tokens, output_tokens, parent_run_id, updated_at)"
+
+const jobsV2RunGlobalSummaryIndexName = "idx_job_runs_v2_summary_created_at"
 
-const jobsV2RunSummaryIndexSQL = "CREATE INDEX IF NOT EXISTS " + jobsV2RunSummaryIndexName + " ON job_runs_v2(job_id, created_at DESC, id, attempt, trigger, scheduled_for, status, worker_id, session_id, started_at, finished_at, exit_code, error, exit_reason, truncated, turn_count, input_tokens, output_tokens, updated_at)"
+const jobsV2RunGlobalSummaryIndexSQL = "CREATE INDEX IF NOT EXISTS " + jobsV2RunGlobalSummaryIndexName + " ON job_runs_v2(created_at DESC, id, job_id, attempt, trigger, scheduled_for, status, worker_id, session_id, started_at, finished_at, exit_code, error, exit_reason, truncated, turn_count, input_tokens, output_tokens, parent_run_id, updated_at)"
 
-const jobsV2RunGlobalSummaryIndexName = "idx_job_runs_v2_summary_created"
+const jobsV2RunParentIndexName = "idx_job_runs_v2_parent_run_id"
 
-const jobsV2RunGlobalSummaryIndexSQL = "CREATE INDEX IF NOT EXISTS " + jobsV2RunGlobalSummaryIndexName + " ON job_runs_v2(created_at DESC, id, job_id, attempt, trigger, scheduled_for, status, worker_id, session_id, started_at, finished_at, exit_code, error, exit_reason, truncated, turn_count, input_tokens, output_tokens, updated_at)"
+const jobsV2RunParentIndexSQL = "CREATE INDEX IF NOT EXISTS " + jobsV2RunParentIndexName + " ON job_runs_v2(parent_run_id) WHERE parent_run_id IS NOT NULL"
 
-const jobsV2RunFullColumns = "id, job_id, attempt, trigger, scheduled_for, status, worker_id, session_id, started_at, finished_at, exit_code, error, stdout, stderr, thinking, response, exit_reason, truncated, turn_count, input_tokens, output_tokens, created_at, updated_at"
+// jobsV2LegacyRunSummaryIndexNames lists covering indexes superseded by the
+// ones above. CREATE INDEX IF NOT EXISTS never widens an existing index, so a
+// new summary column needs new index names and the old ones dropped.
+var jobsV2LegacyRunSummaryIndexNames = []string{
+	"idx_job_runs_v2_summary_by_job_created",
+	"idx_job_runs_v2_summary_created",
+}
+
+// jobsV2JobColumns is the column list scanned by scanJobV2.
+const jobsV2JobColumns = "id, name, enabled, runner_type, runner_config, trigger_type, trigger_config, schedule_timezone, concurrency_policy, max_concurrent_runs, retry_policy, timeout_seconds, misfire_policy, labels, artifacts, key, next_run_at, created_at, updated_at"
+
+const jobsV2RunFullColumns = "id, job_id, attempt, trigger, scheduled_for, status, worker_id, session_id, started_at, finished_at, exit_code, error, stdout, stderr, thinking, response, exit_reason, truncated, turn_count, input_tokens, output_tokens, parent_run_id, trigger_payload, created_at, updated_at"
 
-const jobsV2RunSummaryColumns = "id, job_id, attempt, trigger, scheduled_for, status, worker_id, session_id, started_at, finished_at, exit_code, error, exit_reason, truncated, turn_count, input_tokens, output_tokens, created_at, updated_at"
+const jobsV2RunSummaryColumns = "id, job_id, attempt, trigger, scheduled_for, status, worker_id, session_id, started_at, finished_at, exit_code, error, exit_reason, truncated, turn_count, input_tokens, output_tokens, parent_run_id, created_at, updated_at"
 
 func (m *jobsV2Manager) listRuns(jobID string, limit, offset int, includeOutput bool) ([]jobsV2Run, int, error) {
 	if limit <= 0 {
@@ -2287,7 +2689,7 @@ func scanJobV2(scanner interface{ Scan(dest ...any) error }) (jobsV2Job, error)
 	var runnerConfig string
 	var triggerConfig string
 	var scheduleTZ sql.NullString
-	var retryPolicy, labels sql.NullString
+	var retryPolicy, labels, artifacts, key sql.NullString
 	var nextRun sql.NullTime
 	err := scanner.Scan(
 		&job.ID,
@@ -2304,6 +2706,8 @@ func scanJobV2(scanner interface{ Scan(dest ...any) error }) (jobsV2Job, error)
 		&job.TimeoutSeconds,
 		&job.MisfirePolicy,
 		&labels,
+		&artifacts,
+		&key,
 		&nextRun,
 		&job.CreatedAt,
 		&job.UpdatedAt,
@@ -2312,6 +2716,7 @@ func scanJobV2(scanner interface{ Scan(dest ...any) error }) (jobsV2Job, error)
 		return jobsV2Job{}, err
 	}
 	job.Enabled = enabled == 1
+	job.Key = key.String
 	job.RunnerType = jobsV2RunnerType(runnerType)
 	job.TriggerType = jobsV2TriggerType(triggerType)
 	job.RunnerConfig = json.RawMessage(runnerConfig)
@@ -2325,6 +2730,9 @@ func scanJobV2(scanner interface{ Scan(dest ...any) error }) (jobsV2Job, error)
 	if labels.Valid {
 		job.Labels = json.RawMessage(labels.String)
 	}
+	if artifacts.Valid {
+		job.Artifacts = json.RawMessage(artifacts.String)
+	}
 	if nextRun.Valid {
 		t := nextRun.Time.UTC()
 		job.NextRunAt = &t
@@ -2350,6 +2758,8 @@ func scanRunV2(scanner interface{ Scan(dest ...any) error }) (jobsV2Run, error)
 	var turnCount sql.NullInt64
 	var inputTokens sql.NullInt64
 	var outputTokens sql.NullInt64
+	var parentRunID sql.NullString
+	var triggerPayload sql.NullString
 	err := scanner.Scan(
 		&run.ID,
 		&run.JobID,
@@ -2372,6 +2782,8 @@ func scanRunV2(scanner interface{ Scan(dest ...any) error }) (jobsV2Run, error)
 		&turnCount,
 		&inputTokens,
 		&outputTokens,
+		&parentRunID,
+		&triggerPayload,
 		&run.CreatedAt,
 		&run.UpdatedAt,
 	)
@@ -2379,6 +2791,12 @@ func scanRunV2(scanner interface{ Scan(dest ...any) error }) (jobsV2Run, error)
 		return jobsV2Run{}, err
 	}
 	applyRunV2NullableFields(&run, status, workerID, sessionID, startedAt, finishedAt, exitCode, errText, exitReason, truncatedInt, turnCount, inputTokens, outputTokens)
+	if parentRunID.Valid {
+		run.ParentRunID = parentRunID.String
+	}
+	if triggerPayload.Valid {
+		run.TriggerPayload = json.RawMessage(triggerPayload.String)
+	}
 	if stdout.Valid {
 		run.Stdout = stdout.String
 	}
@@ -2408,6 +2826,7 @@ func scanRunSummaryV2(scanner interface{ Scan(dest ...any) error }) (jobsV2Run,
 	var turnCount sql.NullInt64
 	var inputTokens sql.NullInt64
 	var outputTokens sql.NullInt64
+	var parentRunID sql.NullString
 	err := scanner.Scan(
 		&run.ID,
 		&run.JobID,
@@ -2426,6 +2845,7 @@ func scanRunSummaryV2(scanner interface{ Scan(dest ...any) error }) (jobsV2Run,
 		&turnCount,
 		&inputTokens,
 		&outputTokens,
+		&parentRunID,
 		&run.CreatedAt,
 		&run.UpdatedAt,
 	)
@@ -2433,6 +2853,9 @@ func scanRunSummaryV2(scanner interface{ Scan(dest ...any) error }) (jobsV2Run,
 		return jobsV2Run{}, err
 	}
 	applyRunV2NullableFields(&run, status, workerID, sessionID, startedAt, finishedAt, exitCode, errText, exitReason, truncatedInt, turnCount, inputTokens, outputTokens)
+	if parentRunID.Valid {
+		run.ParentRunID = parentRunID.String
+	}
 	return run, nil
 }
 
@@ -2509,6 +2932,29 @@ func parseTriggerConfig(tt jobsV2TriggerType, raw json.RawMessage, scheduleTZ st
 			return cfg, err
 		}
 		return cfg, nil
+	case jobsV2TriggerAfter:
+		cfg.JobID = strings.TrimSpace(cfg.JobID)
+		if cfg.JobID == "" {
+			return cfg, fmt.Errorf("trigger_config.job_id is required for after trigger")
+		}
+		cfg.OnStatus = strings.TrimSpace(cfg.OnStatus)
+		if cfg.OnStatus == "" {
+			cfg.OnStatus = jobsV2AfterSucceeded
+		}
+		if err := validateJobsV2AfterStatus(cfg.OnStatus); err != nil {
+			return cfg, err
+		}
+		return cfg, nil
+	case jobsV2TriggerWatch:
+		if err := validateJobsV2WatchConfig(&cfg); err != nil {
+			return cfg, err
+		}
+		return cfg, nil
+	case jobsV2TriggerWebhook:
+		if err := validateJobsV2WebhookConfig(&cfg); err != nil {
+			return cfg, err
+		}
+		return cfg, nil
 	default:
 		return cfg, fmt.Errorf("unsupported trigger type: %s", tt)
 	}
@@ -2722,6 +3168,25 @@ func (s *serveServer) handleJobsV2(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// apiKeyMayUseJob checks jobID against the request's API key scopes. It
+// writes the error response and returns false when the key may not act on
+// the job; requests with the server token always pass.
+func (s *serveServer) apiKeyMayUseJob(w http.ResponseWriter, r *http.Request, jobID string) bool {
+	if serveAPIKeyFromContext(r.Context()) == nil {
+		return true
+	}
+	job, err := s.jobsV2.GetJob(jobID)
+	if err != nil {
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "job not found")
+		return false
+	}
+	if err := s.checkAPIKeyJobAccess(r.Context(), job); err != nil {
+		writeOpenAIError(w, http.StatusForbidden, "permission_error", err.Error())
+		return false
+	}
+	return true
+}
+
 func (s *serveServer) handleJobV2ByID(w http.ResponseWriter, r *http.Request) {
 	if s.jobsV2 == nil {
 		http.NotFound(w, r)
@@ -2739,13 +3204,15 @@ func (s *serveServer) handleJobV2ByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	jobID := parts[0]
-
 	if len(parts) == 2 && parts[1] == "trigger" {
 		if r.Method != http.MethodPost {
 			w.Header().Set("Allow", "POST")
 			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
 			return
 		}
+		if !s.apiKeyMayUseJob(w, r, jobID) {
+			return
+		}
 		run, err := s.jobsV2.TriggerJob(jobID)
 		if err != nil {
 			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
@@ -2754,12 +3221,51 @@ func (s *serveServer) handleJobV2ByID(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusAccepted, run)
 		return
 	}
+	if len(parts) == 2 && parts[1] == "hooks" {
+		switch r.Method {
+		case http.MethodPost:
+			s.handleJobV2WebhookDelivery(w, r, jobID)
+		case http.MethodGet:
+			if !s.apiKeyMayUseJob(w, r, jobID) {
+				return
+			}
+			offset, err := parseNonNegativeIntQuery(r, "offset", 0)
+			if err != nil {
+				writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+				return
+			}
+			limit, err := parseNonNegativeIntQuery(r, "limit", 100)
+			if err != nil {
+				writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+				return
+			}
+			items, total, err := s.jobsV2.ListWebhookDeliveries(jobID, limit, offset)
+			if err != nil {
+				writeOpenAIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{
+				"object": "list",
+				"data":   items,
+				"total":  total,
+				"offset": offset,
+				"limit":  limit,
+			})
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		}
+		return
+	}
 	if len(parts) == 2 && parts[1] == "pause" {
 		if r.Method != http.MethodPost {
 			w.Header().Set("Allow", "POST")
 			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
 			return
 		}
+		if !s.apiKeyMayUseJob(w, r, jobID) {
+			return
+		}
 		updated, err := s.jobsV2.UpdateJob(jobID, jobsV2Job{Enabled: false})
 		if err != nil {
 			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
@@ -2774,6 +3280,9 @@ func (s *serveServer) handleJobV2ByID(w http.ResponseWriter, r *http.Request) {
 			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
 			return
 		}
+		if !s.apiKeyMayUseJob(w, r, jobID) {
+			return
+		}
 		updated, err := s.jobsV2.UpdateJob(jobID, jobsV2Job{Enabled: true})
 		if err != nil {
 			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
@@ -2806,6 +3315,26 @@ func (s *serveServer) handleJobV2ByID(w http.ResponseWriter, r *http.Request) {
 			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
 			return
 		}
+		if serveAPIKeyFromContext(r.Context()) != nil {
+			// Keys are checked against the merged runner, so a patch that
+			// leaves the runner alone cannot re-arm a job outside their scopes.
+			current, err := s.jobsV2.GetJob(jobID)
+			if err != nil {
+				writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "job not found")
+				return
+			}
+			runnerType, runnerConfig := current.RunnerType, current.RunnerConfig
+			if req.RunnerType != "" {
+				runnerType = req.RunnerType
+			}
+			if len(req.RunnerConfig) > 0 {
+				runnerConfig = req.RunnerConfig
+			}
+			if req.RunnerConfig, err = s.scopeAPIKeyJob(r.Context(), runnerType, runnerConfig); err != nil {
+				writeOpenAIError(w, http.StatusForbidden, "permission_error", err.Error())
+				return
+			}
+		}
 		job, err := s.jobsV2.UpdateJobPatch(jobID, req)
 		if err != nil {
 			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
@@ -2813,6 +3342,9 @@ func (s *serveServer) handleJobV2ByID(w http.ResponseWriter, r *http.Request) {
 		}
 		writeJSON(w, http.StatusOK, job)
 	case http.MethodDelete:
+		if !s.apiKeyMayUseJob(w, r, jobID) {
+			return
+		}
 		cancelActive := strings.EqualFold(strings.TrimSpace(r.URL.Query().Get("cancel_active")), "true")
 		if err := s.jobsV2.DeleteJob(jobID, cancelActive); err != nil {
 			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
@@ -2849,6 +3381,11 @@ func (s *serveServer) handleCreateJobV2(w http.ResponseWriter, r *http.Request)
 			}
 		}
 	}
+	var err error
+	if jobReq.RunnerConfig, err = s.scopeAPIKeyJob(r.Context(), jobReq.RunnerType, jobReq.RunnerConfig); err != nil {
+		writeOpenAIError(w, http.StatusForbidden, "permission_error", err.Error())
+		return
+	}
 	job, err := s.jobsV2.CreateJob(jobReq)
 	if err != nil {
 		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
@@ -2979,6 +3516,60 @@ func (s *serveServer) handleRunV2ByID(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if len(parts) >= 2 && parts[1] == "artifacts" {
+		s.handleRunV2Artifacts(w, r, runID, strings.Join(parts[2:], "/"))
+		return
+	}
+	if len(parts) == 2 && (parts[1] == "heartbeat" || parts[1] == "progress" || parts[1] == "complete") {
+		s.handleRunV2WorkerAction(w, r, runID, parts[1])
+		return
+	}
+	if len(parts) == 2 && parts[1] == "chain" {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		chain, err := s.jobsV2.RunChain(runID)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "run not found")
+			return
+		}
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, chain)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "replay" {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		if serveAPIKeyFromContext(r.Context()) != nil {
+			run, err := s.jobsV2.GetRun(runID)
+			if err != nil {
+				writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "run not found")
+				return
+			}
+			if !s.apiKeyMayUseJob(w, r, run.JobID) {
+				return
+			}
+		}
+		delivery, err := s.jobsV2.ReplayWebhookRun(runID)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "run not found")
+			return
+		}
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusAccepted, delivery)
+		return
+	}
 	if len(parts) == 2 && parts[1] == "cancel" {
 		if r.Method != http.MethodPost {
 			w.Header().Set("Allow", "POST")
@@ -3010,8 +3601,19 @@ func (s *serveServer) handleRunV2ByID(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, run)
 }
 
-func newServeJobsV2Manager(cfg *config.Config, workers int, approval resolvedApprovalMode, notifyDone jobsV2RunDoneNotifier) (*jobsV2Manager, error) {
-	return newJobsV2ManagerWithNotifier("", workers, newServeJobsExecutor(cfg, approval), notifyDone)
+func newServeJobsV2Manager(cfg *config.Config, workers int, approval resolvedApprovalMode, notifyDone jobsV2RunDoneNotifier, batchRunner *jobsV2BatchRunner, apiKeys *serveAPIKeys) (*jobsV2Manager, error) {
+	llmRunner := &jobsV2LLMRunner{exec: newServeJobsExecutor(cfg, approval)}
+	if apiKeys != nil {
+		llmRunner.recordUsage = apiKeys.recorder
+	}
+	runners := map[jobsV2RunnerType]jobsV2Runner{
+		jobsV2RunnerProgram: &jobsV2ProgramRunner{},
+		jobsV2RunnerLLM:     llmRunner,
+	}
+	if batchRunner != nil {
+		runners[jobsV2RunnerBatch] = batchRunner
+	}
+	return newJobsV2ManagerWithRunners("", workers, runners, notifyDone)
 }
 
 func queryBool(r *http.Request, key string) bool {
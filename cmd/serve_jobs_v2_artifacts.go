@@ -0,0 +1,414 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/samsaffron/term-llm/internal/jobs"
+)
+
+type jobsV2ArtifactPolicy = jobs.ArtifactPolicy
+type jobsV2Artifact = jobs.Artifact
+
+const (
+	jobsV2ArtifactDefaultMaxFiles      = 100
+	jobsV2ArtifactDefaultMaxFileBytes  = 50 << 20
+	jobsV2ArtifactDefaultMaxTotalBytes = 200 << 20
+	// jobsV2ArtifactMtimeSlack tolerates coarse filesystem timestamps when
+	// deciding whether a file was written during the run.
+	jobsV2ArtifactMtimeSlack = 2 * time.Second
+	// jobsV2ArtifactSweepGrace keeps the orphan sweep away from run
+	// directories that a capture may still be filling.
+	jobsV2ArtifactSweepGrace = 10 * time.Minute
+	// jobsV2ArtifactNotifyLimit bounds the artifact links in a notification.
+	jobsV2ArtifactNotifyLimit = 5
+)
+
+func parseJobsV2ArtifactPolicy(raw json.RawMessage) (jobsV2ArtifactPolicy, error) {
+	var policy jobsV2ArtifactPolicy
+	if len(raw) == 0 || string(raw) == "null" {
+		return policy, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&policy); err != nil {
+		return policy, fmt.Errorf("invalid artifacts: %w", err)
+	}
+	return policy, nil
+}
+
+func validateJobsV2ArtifactPolicy(raw json.RawMessage, runnerConfig json.RawMessage) error {
+	policy, err := parseJobsV2ArtifactPolicy(raw)
+	if err != nil {
+		return err
+	}
+	for _, pattern := range policy.Paths {
+		if pattern == "" || strings.HasPrefix(pattern, "/") || filepath.IsAbs(pattern) {
+			return fmt.Errorf("artifacts.paths must be relative globs, got %q", pattern)
+		}
+		for _, part := range strings.Split(pattern, "/") {
+			if part == ".." {
+				return fmt.Errorf("artifacts.paths must stay inside cwd, got %q", pattern)
+			}
+		}
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("artifacts.paths: invalid glob %q", pattern)
+		}
+	}
+	if policy.MaxFiles < 0 || policy.MaxFileBytes < 0 || policy.MaxTotalBytes < 0 || policy.KeepRuns < 0 || policy.KeepDays < 0 {
+		return fmt.Errorf("artifacts limits must be non-negative")
+	}
+	if len(policy.Paths) > 0 && jobsV2RunnerCwd(runnerConfig) == "" {
+		return fmt.Errorf("artifacts require runner_config.cwd")
+	}
+	return nil
+}
+
+func jobsV2RunnerCwd(runnerConfig json.RawMessage) string {
+	var cfg struct {
+		Cwd string `json:"cwd"`
+	}
+	_ = json.Unmarshal(runnerConfig, &cfg)
+	return strings.TrimSpace(cfg.Cwd)
+}
+
+// captureRunArtifacts copies files matching the job's artifact globs that
+// were written during the run from its cwd into the artifact store.
+// Failures are recorded as run events and never fail the run itself.
+func (m *jobsV2Manager) captureRunArtifacts(runID string, job jobsV2Job, started time.Time) {
+	if m.artifactDir == "" || len(job.Artifacts) == 0 {
+		return
+	}
+	policy, err := parseJobsV2ArtifactPolicy(job.Artifacts)
+	if err != nil || len(policy.Paths) == 0 {
+		return
+	}
+	cwd := jobsV2RunnerCwd(job.RunnerConfig)
+	if cwd == "" {
+		return
+	}
+	maxFiles := policy.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = jobsV2ArtifactDefaultMaxFiles
+	}
+	maxFileBytes := policy.MaxFileBytes
+	if maxFileBytes <= 0 {
+		maxFileBytes = jobsV2ArtifactDefaultMaxFileBytes
+	}
+	maxTotalBytes := policy.MaxTotalBytes
+	if maxTotalBytes <= 0 {
+		maxTotalBytes = jobsV2ArtifactDefaultMaxTotalBytes
+	}
+
+	var captured []jobsV2Artifact
+	var totalBytes int64
+	var skipped []string
+	since := started.Add(-jobsV2ArtifactMtimeSlack)
+	walkErr := filepath.WalkDir(cwd, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && p != cwd {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		// Symlinks are skipped so a job cannot publish files from outside cwd.
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(cwd, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if !matchJobsV2WatchGlobs(policy.Paths, rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().Before(since) {
+			return nil
+		}
+		if len(captured) >= maxFiles {
+			skipped = append(skipped, rel+": max_files reached")
+			return fs.SkipAll
+		}
+		if info.Size() > maxFileBytes || totalBytes+info.Size() > maxTotalBytes {
+			skipped = append(skipped, rel+": size limit exceeded")
+			return nil
+		}
+		artifact, err := m.storeRunArtifact(runID, rel, p)
+		if err != nil {
+			skipped = append(skipped, rel+": "+err.Error())
+			return nil
+		}
+		captured = append(captured, artifact)
+		totalBytes += artifact.SizeBytes
+		return nil
+	})
+	if walkErr != nil {
+		_ = m.addRunEvent(runID, "artifacts_failed", walkErr.Error(), nil)
+	}
+	if len(captured) > 0 {
+		_ = m.addRunEvent(runID, "artifacts_captured", fmt.Sprintf("captured %d artifact(s)", len(captured)), map[string]any{"count": len(captured), "bytes": totalBytes})
+	}
+	if len(skipped) > 0 {
+		_ = m.addRunEvent(runID, "artifacts_skipped", fmt.Sprintf("skipped %d artifact(s)", len(skipped)), map[string]any{"skipped": skipped})
+	}
+}
+
+func (m *jobsV2Manager) storeRunArtifact(runID, rel, src string) (jobsV2Artifact, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return jobsV2Artifact{}, err
+	}
+	defer in.Close()
+
+	dest := m.artifactPath(runID, rel)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return jobsV2Artifact{}, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".artifact-*")
+	if err != nil {
+		return jobsV2Artifact{}, err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	hash := sha256.New()
+	var sniff [512]byte
+	n, _ := io.ReadFull(in, sniff[:])
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.MultiReader(bytes.NewReader(sniff[:n]), in))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return jobsV2Artifact{}, err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return jobsV2Artifact{}, err
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(rel))
+	if contentType == "" {
+		contentType = http.DetectContentType(sniff[:n])
+	}
+	artifact := jobsV2Artifact{RunID: runID, Path: rel, SizeBytes: size, ContentType: contentType, SHA256: hex.EncodeToString(hash.Sum(nil))}
+	res, err := m.db.Exec(`INSERT INTO job_run_artifacts_v2 (run_id, path, size_bytes, content_type, sha256, created_at) VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(run_id, path) DO UPDATE SET size_bytes = excluded.size_bytes, content_type = excluded.content_type, sha256 = excluded.sha256`,
+		runID, rel, size, contentType, artifact.SHA256)
+	if err != nil {
+		return jobsV2Artifact{}, err
+	}
+	artifact.ID, _ = res.LastInsertId()
+	return artifact, nil
+}
+
+// artifactPath maps a stored artifact to its file. rel comes from the
+// artifact table, which only ever holds paths produced by filepath.Rel under
+// the run's cwd, so it cannot escape the run directory.
+func (m *jobsV2Manager) artifactPath(runID, rel string) string {
+	return filepath.Join(m.artifactDir, runID, filepath.FromSlash(rel))
+}
+
+// ListRunArtifacts returns the artifacts captured for runID. It returns
+// sql.ErrNoRows when the run does not exist.
+func (m *jobsV2Manager) ListRunArtifacts(runID string) ([]jobsV2Artifact, error) {
+	var exists int
+	if err := m.db.QueryRow(`SELECT 1 FROM job_runs_v2 WHERE id = ?`, runID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.Query(`SELECT id, run_id, path, size_bytes, content_type, sha256, created_at FROM job_run_artifacts_v2 WHERE run_id = ? ORDER BY path ASC`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	artifacts := make([]jobsV2Artifact, 0)
+	for rows.Next() {
+		var a jobsV2Artifact
+		var contentType sql.NullString
+		if err := rows.Scan(&a.ID, &a.RunID, &a.Path, &a.SizeBytes, &contentType, &a.SHA256, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.ContentType = contentType.String
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, rows.Err()
+}
+
+// OpenRunArtifact opens a stored artifact by its relative path.
+func (m *jobsV2Manager) OpenRunArtifact(runID, rel string) (jobsV2Artifact, *os.File, error) {
+	var a jobsV2Artifact
+	var contentType sql.NullString
+	err := m.db.QueryRow(`SELECT id, run_id, path, size_bytes, content_type, sha256, created_at FROM job_run_artifacts_v2 WHERE run_id = ? AND path = ?`, runID, rel).
+		Scan(&a.ID, &a.RunID, &a.Path, &a.SizeBytes, &contentType, &a.SHA256, &a.CreatedAt)
+	if err != nil {
+		return jobsV2Artifact{}, nil, err
+	}
+	a.ContentType = contentType.String
+	f, err := os.Open(m.artifactPath(a.RunID, a.Path))
+	if err != nil {
+		return jobsV2Artifact{}, nil, err
+	}
+	return a, f, nil
+}
+
+// pruneArtifacts applies each job's keep_runs/keep_days policy and then
+// removes stored files whose artifact rows are gone, including those of runs
+// deleted by run retention.
+func (m *jobsV2Manager) pruneArtifacts(now time.Time) error {
+	rows, err := m.db.Query(`SELECT id, artifacts FROM jobs_v2 WHERE artifacts IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	policies := make(map[string]jobsV2ArtifactPolicy)
+	for rows.Next() {
+		var id, raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+		if policy, err := parseJobsV2ArtifactPolicy(json.RawMessage(raw)); err == nil {
+			policies[id] = policy
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for jobID, policy := range policies {
+		if policy.KeepDays > 0 {
+			cutoff := now.Add(-time.Duration(policy.KeepDays) * 24 * time.Hour)
+			if _, err := m.db.Exec(`DELETE FROM job_run_artifacts_v2 WHERE run_id IN (SELECT id FROM job_runs_v2 WHERE job_id = ? AND created_at < ?)`, jobID, cutoff); err != nil {
+				return err
+			}
+		}
+		if policy.KeepRuns > 0 {
+			if _, err := m.db.Exec(`
+				DELETE FROM job_run_artifacts_v2
+				WHERE run_id IN (
+					SELECT id FROM job_runs_v2
+					WHERE job_id = ?
+					  AND id IN (SELECT run_id FROM job_run_artifacts_v2)
+					ORDER BY created_at DESC, rowid DESC
+					LIMIT -1 OFFSET ?
+				)`, jobID, policy.KeepRuns); err != nil {
+				return err
+			}
+		}
+	}
+	return m.sweepOrphanArtifacts(now)
+}
+
+func (m *jobsV2Manager) sweepOrphanArtifacts(now time.Time) error {
+	if m.artifactDir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(m.artifactDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) < jobsV2ArtifactSweepGrace {
+			continue
+		}
+		runID := entry.Name()
+		var exists int
+		err = m.db.QueryRow(`SELECT 1 FROM job_run_artifacts_v2 WHERE run_id = ? LIMIT 1`, runID).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = os.RemoveAll(filepath.Join(m.artifactDir, runID))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatJobsV2ArtifactLinks renders download links for a completion
+// notification, relative to the serve base path.
+func formatJobsV2ArtifactLinks(basePath, runID string, artifacts []jobsV2Artifact) string {
+	if len(artifacts) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Artifacts:")
+	for i, a := range artifacts {
+		if i == jobsV2ArtifactNotifyLimit {
+			fmt.Fprintf(&b, "\n- and %d more: %s/v2/runs/%s/artifacts", len(artifacts)-i, basePath, runID)
+			break
+		}
+		fmt.Fprintf(&b, "\n- %s: %s", a.Path, jobsV2ArtifactURL(basePath, runID, a.Path))
+	}
+	return b.String()
+}
+
+func jobsV2ArtifactURL(basePath, runID, rel string) string {
+	parts := strings.Split(rel, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return basePath + "/v2/runs/" + runID + "/artifacts/" + strings.Join(parts, "/")
+}
+
+func (s *serveServer) handleRunV2Artifacts(w http.ResponseWriter, r *http.Request, runID string, rel string) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	if rel == "" {
+		artifacts, err := s.jobsV2.ListRunArtifacts(runID)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "run not found")
+			return
+		}
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"object": "list",
+			"data":   artifacts,
+		})
+		return
+	}
+	artifact, f, err := s.jobsV2.OpenRunArtifact(runID, rel)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, fs.ErrNotExist) {
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "artifact not found")
+		return
+	}
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+		return
+	}
+	defer f.Close()
+	if artifact.ContentType != "" {
+		w.Header().Set("Content-Type", artifact.ContentType)
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(artifact.Path)}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+artifact.SHA256+`"`)
+	http.ServeContent(w, r, path.Base(artifact.Path), artifact.CreatedAt, f)
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateJobsV2ArtifactPolicy(t *testing.T) {
+	cwd := json.RawMessage(`{"command":"true","cwd":"/srv/app"}`)
+	if err := validateJobsV2ArtifactPolicy(json.RawMessage(`{"paths":["out/**/*.json","report.md"],"keep_runs":3}`), cwd); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if err := validateJobsV2ArtifactPolicy(nil, json.RawMessage(`{"command":"true"}`)); err != nil {
+		t.Fatalf("validate empty policy: %v", err)
+	}
+	for _, tc := range []struct {
+		raw    string
+		config json.RawMessage
+		want   string
+	}{
+		{raw: `{"paths":["/etc/passwd"]}`, config: cwd, want: "relative"},
+		{raw: `{"paths":["../secrets/*"]}`, config: cwd, want: "inside cwd"},
+		{raw: `{"paths":["[bad"]}`, config: cwd, want: "invalid glob"},
+		{raw: `{"paths":["*"],"keep_runs":-1}`, config: cwd, want: "non-negative"},
+		{raw: `{"paths":["*"],"keep":1}`, config: cwd, want: "unknown field"},
+		{raw: `{"paths":["*"]}`, config: json.RawMessage(`{"command":"true"}`), want: "cwd"},
+	} {
+		if err := validateJobsV2ArtifactPolicy(json.RawMessage(tc.raw), tc.config); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("validate(%s) err = %v, want %q", tc.raw, err, tc.want)
+		}
+	}
+}
+
+func TestJobsV2RunArtifactsCapturedListedAndDownloaded(t *testing.T) {
+	cwd := t.TempDir()
+	if err := os.WriteFile(filepath.Join(cwd, "stale.txt"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(cwd, "stale.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr, err := newJobsV2Manager(":memory:", 1, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager failed: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+
+	runnerConfig, _ := json.Marshal(map[string]any{
+		"command": "sh",
+		"args":    []string{"-c", "mkdir -p out && printf '{\"ok\":true}' > out/result.json && printf %032d 0 > out/big.bin && echo ignored > notes.log && touch stale.txt.unused"},
+		"cwd":     cwd,
+	})
+	job, err := mgr.CreateJob(jobsV2Job{
+		Name:           "build",
+		Enabled:        true,
+		RunnerType:     jobsV2RunnerProgram,
+		RunnerConfig:   runnerConfig,
+		TriggerType:    jobsV2TriggerManual,
+		TriggerConfig:  json.RawMessage(`{}`),
+		TimeoutSeconds: 30,
+		Artifacts:      json.RawMessage(`{"paths":["out/**","*.txt"],"max_file_bytes":16,"keep_runs":1}`),
+	})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	first, err := mgr.TriggerJob(job.ID)
+	if err != nil {
+		t.Fatalf("trigger: %v", err)
+	}
+	waitForJobsV2RunStatus(t, mgr, first.ID, jobsV2RunSucceeded)
+
+	artifacts, err := mgr.ListRunArtifacts(first.ID)
+	if err != nil {
+		t.Fatalf("ListRunArtifacts: %v", err)
+	}
+	if len(artifacts) != 1 || artifacts[0].Path != "out/result.json" || artifacts[0].SizeBytes != 11 || artifacts[0].ContentType != "application/json" {
+		t.Fatalf("artifacts = %+v, want only out/result.json (stale.txt unchanged, big.bin over limit)", artifacts)
+	}
+	if _, err := mgr.ListRunArtifacts("run_missing"); err == nil {
+		t.Fatal("ListRunArtifacts for unknown run succeeded")
+	}
+
+	srv := &serveServer{jobsV2: mgr}
+	rr := httptest.NewRecorder()
+	srv.handleRunV2ByID(rr, httptest.NewRequest(http.MethodGet, "/v2/runs/"+first.ID+"/artifacts/out/result.json", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"ok":true}` {
+		t.Fatalf("download status = %d body = %q", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Disposition"); !strings.Contains(got, "result.json") {
+		t.Fatalf("Content-Disposition = %q", got)
+	}
+	rr = httptest.NewRecorder()
+	srv.handleRunV2ByID(rr, httptest.NewRequest(http.MethodGet, "/v2/runs/"+first.ID+"/artifacts/../../jobs_v2.db", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("traversal download status = %d, want 404", rr.Code)
+	}
+
+	links := formatJobsV2ArtifactLinks("/ui", first.ID, artifacts)
+	if !strings.Contains(links, "/ui/v2/runs/"+first.ID+"/artifacts/out/result.json") {
+		t.Fatalf("links = %q", links)
+	}
+
+	// keep_runs=1 drops the first run's artifacts once a second run captures.
+	second, err := mgr.TriggerJob(job.ID)
+	if err != nil {
+		t.Fatalf("trigger: %v", err)
+	}
+	waitForJobsV2RunStatus(t, mgr, second.ID, jobsV2RunSucceeded)
+	if err := mgr.pruneArtifacts(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("pruneArtifacts: %v", err)
+	}
+	if artifacts, _ := mgr.ListRunArtifacts(first.ID); len(artifacts) != 0 {
+		t.Fatalf("first run artifacts after prune = %+v", artifacts)
+	}
+	if _, err := os.Stat(filepath.Join(mgr.artifactDir, first.ID)); !os.IsNotExist(err) {
+		t.Fatalf("first run artifact dir still present: %v", err)
+	}
+	if artifacts, _ := mgr.ListRunArtifacts(second.ID); len(artifacts) != 1 {
+		t.Fatalf("second run artifacts after prune = %+v", artifacts)
+	}
+}
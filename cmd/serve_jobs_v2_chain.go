@@ -0,0 +1,330 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// After-trigger statuses accepted in trigger_config.on_status.
+const (
+	jobsV2AfterSucceeded = "succeeded"
+	jobsV2AfterFailed    = "failed"
+	jobsV2AfterAny       = "any"
+)
+
+// jobsV2MaxChainDepth bounds upstream/downstream walks so a corrupted or
+// hand-edited database cannot turn a chain lookup into an unbounded loop.
+const jobsV2MaxChainDepth = 32
+
+// Placeholders expanded in an llm downstream job's instructions when its
+// after trigger sets pass_upstream.
+const (
+	jobsV2UpstreamResponsePlaceholder  = "{{upstream_response}}"
+	jobsV2UpstreamSessionIDPlaceholder = "{{upstream_session_id}}"
+	jobsV2UpstreamRunIDPlaceholder     = "{{upstream_run_id}}"
+	jobsV2UpstreamStatusPlaceholder    = "{{upstream_status}}"
+	jobsV2UpstreamJobPlaceholder       = "{{upstream_job}}"
+)
+
+// jobsV2RunChain is the causal chain around a run: the upstream runs that led
+// to it (root first) and every run it transitively triggered.
+type jobsV2RunChain struct {
+	Object     string      `json:"object"`
+	RunID      string      `json:"run_id"`
+	Upstream   []jobsV2Run `json:"upstream"`
+	Run        jobsV2Run   `json:"run"`
+	Downstream []jobsV2Run `json:"downstream"`
+}
+
+func validJobsV2TriggerType(tt jobsV2TriggerType) bool {
+	switch tt {
+	case jobsV2TriggerManual, jobsV2TriggerOnce, jobsV2TriggerCron, jobsV2TriggerAfter, jobsV2TriggerWatch, jobsV2TriggerWebhook:
+		return true
+	default:
+		return false
+	}
+}
+
+func validateJobsV2AfterStatus(status string) error {
+	switch status {
+	case jobsV2AfterSucceeded, jobsV2AfterFailed, jobsV2AfterAny:
+		return nil
+	default:
+		return fmt.Errorf("trigger_config.on_status must be one of: succeeded, failed, any")
+	}
+}
+
+// jobsV2AfterStatusMatches reports whether an upstream run that finished with
+// status should fire an after trigger waiting for onStatus. Timeouts count as
+// failures; cancellations only match "any".
+func jobsV2AfterStatusMatches(onStatus string, status jobsV2RunStatus) bool {
+	switch onStatus {
+	case "", jobsV2AfterSucceeded:
+		return status == jobsV2RunSucceeded
+	case jobsV2AfterFailed:
+		return status == jobsV2RunFailed || status == jobsV2RunTimedOut
+	case jobsV2AfterAny:
+		return jobsV2NotifyTerminalStatus(status)
+	default:
+		return false
+	}
+}
+
+// resolveAfterTriggerConfig canonicalizes trigger_config.job_id (which may
+// name the upstream job) to its ID and rejects references that would make a
+// chain loop back to selfID.
+func (m *jobsV2Manager) resolveAfterTriggerConfig(selfID string, cfg jobsV2TriggerConfig) (json.RawMessage, error) {
+	upstreamID, err := m.lookupJobIDByRef(cfg.JobID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("trigger_config.job_id: job %q not found", cfg.JobID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if upstreamID == selfID {
+		return nil, fmt.Errorf("trigger_config.job_id cannot reference the job itself")
+	}
+
+	current := upstreamID
+	for depth := 0; ; depth++ {
+		if depth >= jobsV2MaxChainDepth {
+			return nil, fmt.Errorf("job chain is deeper than %d jobs", jobsV2MaxChainDepth)
+		}
+		var triggerType, triggerConfig string
+		err := m.db.QueryRow(`SELECT trigger_type, trigger_config FROM jobs_v2 WHERE id = ?`, current).Scan(&triggerType, &triggerConfig)
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if jobsV2TriggerType(triggerType) != jobsV2TriggerAfter {
+			break
+		}
+		var parent jobsV2TriggerConfig
+		if err := json.Unmarshal([]byte(triggerConfig), &parent); err != nil {
+			break
+		}
+		current = strings.TrimSpace(parent.JobID)
+		if current == selfID {
+			return nil, fmt.Errorf("trigger_config.job_id would create a job chain cycle")
+		}
+		if current == "" {
+			break
+		}
+	}
+
+	cfg.JobID = upstreamID
+	return json.Marshal(cfg)
+}
+
+// lookupJobIDByRef resolves a job ID, key or name, in that order of
+// precedence, so chains declared in jobs apply files can refer to keys.
+func (m *jobsV2Manager) lookupJobIDByRef(ref string) (string, error) {
+	var id string
+	err := m.db.QueryRow(`SELECT id FROM jobs_v2 WHERE id = ? OR key = ? OR name = ? ORDER BY id = ? DESC, key IS ? DESC LIMIT 1`, ref, ref, ref, ref, ref).Scan(&id)
+	return id, err
+}
+
+// triggerDownstreamJobs queues a run of every enabled after-triggered job
+// waiting on upstream's job and final status. Concurrency limits apply as for
+// any other trigger; a downstream job at its limit is skipped and recorded on
+// the upstream run.
+func (m *jobsV2Manager) triggerDownstreamJobs(upstream jobsV2Run) {
+	rows, err := m.db.Query(`SELECT `+jobsV2JobColumns+` FROM jobs_v2 WHERE enabled = 1 AND trigger_type = ? AND json_valid(trigger_config) AND json_extract(trigger_config, '$.job_id') = ? ORDER BY created_at ASC`, jobsV2TriggerAfter, upstream.JobID)
+	if err != nil {
+		log.Printf("jobs v2: failed to load downstream jobs of %q: %v", upstream.JobID, err)
+		return
+	}
+	var downstream []jobsV2Job
+	for rows.Next() {
+		job, err := scanJobV2(rows)
+		if err != nil {
+			_ = rows.Close()
+			log.Printf("jobs v2: failed to scan downstream job of %q: %v", upstream.JobID, err)
+			return
+		}
+		downstream = append(downstream, job)
+	}
+	_ = rows.Close()
+
+	now := time.Now().UTC()
+	for _, job := range downstream {
+		var cfg jobsV2TriggerConfig
+		if err := json.Unmarshal(job.TriggerConfig, &cfg); err != nil || !jobsV2AfterStatusMatches(cfg.OnStatus, upstream.Status) {
+			continue
+		}
+		runID, active, queued, err := m.enqueueRunWithConcurrencyLimit(job, string(jobsV2TriggerAfter), now, nil, func(tx *sql.Tx, runID string) error {
+			_, err := tx.Exec(`UPDATE job_runs_v2 SET parent_run_id = ? WHERE id = ?`, upstream.ID, runID)
+			return err
+		})
+		if err != nil {
+			_ = m.addRunEvent(upstream.ID, "downstream_failed", "failed to queue downstream job", map[string]any{"job_id": job.ID, "error": err.Error()})
+			continue
+		}
+		if !queued {
+			_ = m.addRunEvent(upstream.ID, "downstream_skipped", "downstream job already at its concurrency limit", map[string]any{"job_id": job.ID, "active_runs": active})
+			continue
+		}
+		_ = m.addRunEvent(upstream.ID, "downstream_queued", "downstream run queued", map[string]any{"job_id": job.ID, "run_id": runID})
+		_ = m.addRunEvent(runID, "queued", "chained run queued", map[string]any{
+			"trigger":       jobsV2TriggerAfter,
+			"attempt":       1,
+			"parent_run_id": upstream.ID,
+			"parent_job_id": upstream.JobID,
+		})
+		m.notifyWorkers(1)
+	}
+}
+
+// applyUpstreamContext exposes the upstream run to a chained run when the
+// job's after trigger sets pass_upstream. LLM instructions get the
+// {{upstream_*}} placeholders expanded (or an upstream section appended when
+// they use none); program runs get TERM_LLM_UPSTREAM_* environment variables
+// and can fetch the full upstream run from the API.
+func (m *jobsV2Manager) applyUpstreamContext(job *jobsV2Job, parentRunID string) error {
+	var cfg jobsV2TriggerConfig
+	if err := json.Unmarshal([]byte(stringOrEmptyRaw(job.TriggerConfig, "{}")), &cfg); err != nil || !cfg.PassUpstream {
+		return nil
+	}
+	upstream, err := m.GetRun(parentRunID)
+	if err != nil {
+		return err
+	}
+	upstreamJob := upstream.JobID
+	var name string
+	if err := m.db.QueryRow(`SELECT name FROM jobs_v2 WHERE id = ?`, upstream.JobID).Scan(&name); err == nil {
+		upstreamJob = name
+	}
+
+	var runnerConfig map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(stringOrEmptyRaw(job.RunnerConfig, "{}")), &runnerConfig); err != nil {
+		return fmt.Errorf("invalid runner config: %w", err)
+	}
+	switch job.RunnerType {
+	case jobsV2RunnerLLM:
+		var instructions string
+		_ = json.Unmarshal(runnerConfig["instructions"], &instructions)
+		encoded, err := json.Marshal(renderJobsV2UpstreamInstructions(instructions, upstream, upstreamJob))
+		if err != nil {
+			return err
+		}
+		runnerConfig["instructions"] = encoded
+	case jobsV2RunnerProgram:
+		var env []string
+		_ = json.Unmarshal(runnerConfig["env"], &env)
+		env = append(env,
+			"TERM_LLM_UPSTREAM_RUN_ID="+upstream.ID,
+			"TERM_LLM_UPSTREAM_JOB_ID="+upstream.JobID,
+			"TERM_LLM_UPSTREAM_STATUS="+string(upstream.Status),
+			"TERM_LLM_UPSTREAM_SESSION_ID="+upstream.SessionID,
+		)
+		encoded, err := json.Marshal(env)
+		if err != nil {
+			return err
+		}
+		runnerConfig["env"] = encoded
+	default:
+		return nil
+	}
+	rendered, err := json.Marshal(runnerConfig)
+	if err != nil {
+		return err
+	}
+	job.RunnerConfig = rendered
+	return nil
+}
+
+func renderJobsV2UpstreamInstructions(instructions string, upstream jobsV2Run, upstreamJob string) string {
+	// Program runs leave Response empty; their stdout is the useful output.
+	response := upstream.Response
+	if strings.TrimSpace(response) == "" {
+		response = upstream.Stdout
+	}
+	if strings.Contains(instructions, "{{upstream_") {
+		// A single pass keeps placeholder-looking text inside the upstream
+		// response from being expanded again.
+		return strings.NewReplacer(
+			jobsV2UpstreamResponsePlaceholder, response,
+			jobsV2UpstreamSessionIDPlaceholder, upstream.SessionID,
+			jobsV2UpstreamRunIDPlaceholder, upstream.ID,
+			jobsV2UpstreamStatusPlaceholder, string(upstream.Status),
+			jobsV2UpstreamJobPlaceholder, upstreamJob,
+		).Replace(instructions)
+	}
+	var b strings.Builder
+	b.WriteString(instructions)
+	fmt.Fprintf(&b, "\n\n---\nThis run was triggered by job %q (run %s), which finished with status %s.", upstreamJob, upstream.ID, upstream.Status)
+	if upstream.SessionID != "" {
+		fmt.Fprintf(&b, "\nUpstream session: %s", upstream.SessionID)
+	}
+	if strings.TrimSpace(response) != "" {
+		b.WriteString("\nUpstream response:\n")
+		b.WriteString(response)
+	}
+	return b.String()
+}
+
+// RunChain returns the upstream ancestors (root first) and all transitive
+// downstream runs of runID.
+func (m *jobsV2Manager) RunChain(runID string) (jobsV2RunChain, error) {
+	run, err := m.GetRun(runID)
+	if err != nil {
+		return jobsV2RunChain{}, err
+	}
+	chain := jobsV2RunChain{
+		Object:     "run_chain",
+		RunID:      run.ID,
+		Upstream:   make([]jobsV2Run, 0),
+		Run:        run,
+		Downstream: make([]jobsV2Run, 0),
+	}
+
+	parentID := run.ParentRunID
+	for depth := 0; parentID != "" && depth < jobsV2MaxChainDepth; depth++ {
+		row := m.db.QueryRow(`SELECT `+jobsV2RunSummaryColumns+` FROM job_runs_v2 WHERE id = ?`, parentID)
+		parent, err := scanRunSummaryV2(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			// The upstream run was pruned by retention; the chain starts here.
+			break
+		}
+		if err != nil {
+			return jobsV2RunChain{}, err
+		}
+		chain.Upstream = append([]jobsV2Run{parent}, chain.Upstream...)
+		parentID = parent.ParentRunID
+	}
+
+	rows, err := m.db.Query(`
+		WITH RECURSIVE downstream(id, depth) AS (
+			SELECT id, 1 FROM job_runs_v2 WHERE parent_run_id = ?
+			UNION
+			SELECT child.id, downstream.depth + 1
+			FROM job_runs_v2 child
+			JOIN downstream ON child.parent_run_id = downstream.id
+			WHERE downstream.depth < ?
+		)
+		SELECT `+jobsV2RunSummaryColumns+` FROM job_runs_v2
+		WHERE id IN (SELECT id FROM downstream)
+		ORDER BY created_at ASC, id ASC`, run.ID, jobsV2MaxChainDepth)
+	if err != nil {
+		return jobsV2RunChain{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		child, err := scanRunSummaryV2(rows)
+		if err != nil {
+			return jobsV2RunChain{}, err
+		}
+		chain.Downstream = append(chain.Downstream, child)
+	}
+	if err := rows.Err(); err != nil {
+		return jobsV2RunChain{}, err
+	}
+	return chain, nil
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/llm"
+)
+
+func TestJobsV2AfterStatusMatches(t *testing.T) {
+	tests := []struct {
+		onStatus string
+		status   jobsV2RunStatus
+		want     bool
+	}{
+		{onStatus: "", status: jobsV2RunSucceeded, want: true},
+		{onStatus: "", status: jobsV2RunFailed, want: false},
+		{onStatus: jobsV2AfterSucceeded, status: jobsV2RunSucceeded, want: true},
+		{onStatus: jobsV2AfterSucceeded, status: jobsV2RunTimedOut, want: false},
+		{onStatus: jobsV2AfterFailed, status: jobsV2RunFailed, want: true},
+		{onStatus: jobsV2AfterFailed, status: jobsV2RunTimedOut, want: true},
+		{onStatus: jobsV2AfterFailed, status: jobsV2RunCancelled, want: false},
+		{onStatus: jobsV2AfterAny, status: jobsV2RunCancelled, want: true},
+		{onStatus: jobsV2AfterAny, status: jobsV2RunSkipped, want: false},
+	}
+	for _, tc := range tests {
+		if got := jobsV2AfterStatusMatches(tc.onStatus, tc.status); got != tc.want {
+			t.Errorf("jobsV2AfterStatusMatches(%q, %q) = %v, want %v", tc.onStatus, tc.status, got, tc.want)
+		}
+	}
+}
+
+func TestJobsV2AfterTriggerValidation(t *testing.T) {
+	mgr, err := newJobsV2Manager(":memory:", 0, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager failed: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+
+	upstream, err := mgr.CreateJob(jobsV2Job{
+		Name:          "scrape",
+		Enabled:       true,
+		RunnerType:    jobsV2RunnerProgram,
+		RunnerConfig:  json.RawMessage(`{"command":"true"}`),
+		TriggerType:   jobsV2TriggerManual,
+		TriggerConfig: json.RawMessage(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("create upstream: %v", err)
+	}
+
+	if _, err := mgr.CreateJob(jobsV2Job{
+		Name:          "missing-upstream",
+		Enabled:       true,
+		RunnerType:    jobsV2RunnerProgram,
+		RunnerConfig:  json.RawMessage(`{"command":"true"}`),
+		TriggerType:   jobsV2TriggerAfter,
+		TriggerConfig: json.RawMessage(`{"job_id":"job_nope"}`),
+	}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("create with unknown upstream err = %v, want not found", err)
+	}
+	if _, err := mgr.CreateJob(jobsV2Job{
+		Name:          "bad-status",
+		Enabled:       true,
+		RunnerType:    jobsV2RunnerProgram,
+		RunnerConfig:  json.RawMessage(`{"command":"true"}`),
+		TriggerType:   jobsV2TriggerAfter,
+		TriggerConfig: json.RawMessage(`{"job_id":"scrape","on_status":"done"}`),
+	}); err == nil || !strings.Contains(err.Error(), "on_status") {
+		t.Fatalf("create with bad on_status err = %v, want on_status error", err)
+	}
+
+	// Upstream references by name are canonicalized to the job ID.
+	downstream, err := mgr.CreateJob(jobsV2Job{
+		Name:          "summarize",
+		Enabled:       true,
+		RunnerType:    jobsV2RunnerProgram,
+		RunnerConfig:  json.RawMessage(`{"command":"true"}`),
+		TriggerType:   jobsV2TriggerAfter,
+		TriggerConfig: json.RawMessage(`{"job_id":"scrape"}`),
+	})
+	if err != nil {
+		t.Fatalf("create downstream: %v", err)
+	}
+	var cfg jobsV2TriggerConfig
+	if err := json.Unmarshal(downstream.TriggerConfig, &cfg); err != nil {
+		t.Fatalf("decode trigger config: %v", err)
+	}
+	if cfg.JobID != upstream.ID || cfg.OnStatus != jobsV2AfterSucceeded {
+		t.Fatalf("trigger config = %+v, want job_id %q on_status succeeded", cfg, upstream.ID)
+	}
+	if downstream.NextRunAt != nil {
+		t.Fatalf("after-triggered job next_run_at = %v, want nil", downstream.NextRunAt)
+	}
+
+	if _, err := mgr.UpdateJobPatch(downstream.ID, jobsV2JobRequest{TriggerConfig: json.RawMessage(`{"job_id":"summarize"}`)}); err == nil || !strings.Contains(err.Error(), "itself") {
+		t.Fatalf("self-referencing update err = %v, want self reference error", err)
+	}
+	if _, err := mgr.UpdateJobPatch(upstream.ID, jobsV2JobRequest{
+		TriggerType:   jobsV2TriggerAfter,
+		TriggerConfig: json.RawMessage(`{"job_id":"summarize"}`),
+	}); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("cyclic update err = %v, want cycle error", err)
+	}
+}
+
+func TestJobsV2AfterTriggerChainsRunsWithUpstreamContext(t *testing.T) {
+	var mu sync.Mutex
+	var gotInstructions string
+	exec := func(ctx context.Context, cfg jobsV2LLMConfig, onEvent func(llm.Event)) (serveJobsExecResult, error) {
+		mu.Lock()
+		gotInstructions = cfg.Instructions
+		mu.Unlock()
+		onEvent(llm.Event{Type: llm.EventTextDelta, Text: "summary done"})
+		return serveJobsExecResult{}, nil
+	}
+	mgr, err := newJobsV2Manager(":memory:", 1, exec)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager failed: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+
+	upstream, err := mgr.CreateJob(jobsV2Job{
+		Name:           "scrape",
+		Enabled:        true,
+		RunnerType:     jobsV2RunnerProgram,
+		RunnerConfig:   json.RawMessage(`{"command":"echo","args":["scraped-data"]}`),
+		TriggerType:    jobsV2TriggerManual,
+		TriggerConfig:  json.RawMessage(`{}`),
+		TimeoutSeconds: 30,
+	})
+	if err != nil {
+		t.Fatalf("create upstream: %v", err)
+	}
+	if _, err := mgr.CreateJob(jobsV2Job{
+		Name:       "summarize",
+		Enabled:    true,
+		RunnerType: jobsV2RunnerLLM,
+		RunnerConfig: json.RawMessage(`{
+			"agent_name":"developer",
+			"instructions":"Summarize {{upstream_job}} output: {{upstream_response}}",
+			"cwd":"/tmp"
+		}`),
+		TriggerType:    jobsV2TriggerAfter,
+		TriggerConfig:  json.RawMessage(`{"job_id":"` + upstream.ID + `","pass_upstream":true}`),
+		TimeoutSeconds: 30,
+	}); err != nil {
+		t.Fatalf("create downstream: %v", err)
+	}
+	if _, err := mgr.CreateJob(jobsV2Job{
+		Name:          "on-failure",
+		Enabled:       true,
+		RunnerType:    jobsV2RunnerProgram,
+		RunnerConfig:  json.RawMessage(`{"command":"true"}`),
+		TriggerType:   jobsV2TriggerAfter,
+		TriggerConfig: json.RawMessage(`{"job_id":"scrape","on_status":"failed"}`),
+	}); err != nil {
+		t.Fatalf("create failure handler: %v", err)
+	}
+
+	upstreamRun, err := mgr.TriggerJob(upstream.ID)
+	if err != nil {
+		t.Fatalf("trigger upstream: %v", err)
+	}
+
+	srv := &serveServer{jobsV2: mgr}
+	var chain jobsV2RunChain
+	deadline := time.Now().Add(8 * time.Second)
+	for {
+		req := httptest.NewRequest(http.MethodGet, "/v2/runs/"+upstreamRun.ID+"/chain", nil)
+		rr := httptest.NewRecorder()
+		srv.handleRunV2ByID(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("chain status = %d body=%s", rr.Code, rr.Body.String())
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &chain); err != nil {
+			t.Fatalf("decode chain: %v", err)
+		}
+		if len(chain.Downstream) == 1 && chain.Downstream[0].Status == jobsV2RunSucceeded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for downstream run; chain = %+v", chain)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	child := chain.Downstream[0]
+	if child.Trigger != "after" || child.ParentRunID != upstreamRun.ID {
+		t.Fatalf("downstream run trigger=%q parent=%q, want after/%s", child.Trigger, child.ParentRunID, upstreamRun.ID)
+	}
+	mu.Lock()
+	instructions := gotInstructions
+	mu.Unlock()
+	if !strings.Contains(instructions, "Summarize scrape output: scraped-data") {
+		t.Fatalf("downstream instructions = %q, want rendered upstream context", instructions)
+	}
+
+	childChain, err := mgr.RunChain(child.ID)
+	if err != nil {
+		t.Fatalf("RunChain(child): %v", err)
+	}
+	if len(childChain.Upstream) != 1 || childChain.Upstream[0].ID != upstreamRun.ID {
+		t.Fatalf("child upstream = %+v, want [%s]", childChain.Upstream, upstreamRun.ID)
+	}
+}
+
+func TestRenderJobsV2UpstreamInstructionsAppendsContextWithoutPlaceholders(t *testing.T) {
+	upstream := jobsV2Run{ID: "run_up", Status: jobsV2RunSucceeded, SessionID: "sess-up", Response: "found {{upstream_run_id}}"}
+	got := renderJobsV2UpstreamInstructions("Notify the team.", upstream, "scrape")
+	for _, want := range []string{"Notify the team.", `job "scrape" (run run_up)`, "Upstream session: sess-up", "found {{upstream_run_id}}"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("rendered instructions = %q, want contains %q", got, want)
+		}
+	}
+
+	got = renderJobsV2UpstreamInstructions("Result: {{upstream_response}}", upstream, "scrape")
+	if got != "Result: found {{upstream_run_id}}" {
+		t.Fatalf("rendered instructions = %q, want upstream response without re-expansion", got)
+	}
+}
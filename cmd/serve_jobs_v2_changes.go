@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/samsaffron/term-llm/internal/llm"
+	diff "github.com/shogoki/gotextdiff"
+)
+
+const (
+	// jobsV2ChangeDiffMaxLines and jobsV2ChangeDiffMaxRunes keep the diff small
+	// enough for a chat notification.
+	jobsV2ChangeDiffMaxLines = 40
+	jobsV2ChangeDiffMaxRunes = 2000
+	// jobsV2ChangeJudgeMaxRunes bounds each output sent to the change judge.
+	jobsV2ChangeJudgeMaxRunes = 6000
+	jobsV2ChangeJudgeTimeout  = 20 * time.Second
+	jobsV2ChangeEventType     = "output_change"
+)
+
+// jobsV2OutputChange is the change-detection decision for one run. It is
+// stored as an output_change run event so notification retries reuse it
+// instead of asking the judge again.
+type jobsV2OutputChange struct {
+	Notify        bool   `json:"notify"`
+	PreviousRunID string `json:"previous_run_id,omitempty"`
+	Diff          string `json:"diff,omitempty"`
+	Judged        bool   `json:"judged,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// PreviousSuccessfulRun returns the most recent successful run of jobID that
+// finished before runID.
+func (m *jobsV2Manager) PreviousSuccessfulRun(jobID, runID string) (jobsV2Run, error) {
+	row := m.db.QueryRow(`SELECT `+jobsV2RunFullColumns+` FROM job_runs_v2
+		WHERE job_id = ? AND status = ? AND id <> ?
+		  AND finished_at < COALESCE((SELECT finished_at FROM job_runs_v2 WHERE id = ?), CURRENT_TIMESTAMP)
+		ORDER BY finished_at DESC, id DESC
+		LIMIT 1`, jobID, jobsV2RunSucceeded, runID, runID)
+	return scanRunV2(row)
+}
+
+func (m *jobsV2Manager) lastRunEvent(runID, eventType string) (jobsV2RunEvent, error) {
+	var ev jobsV2RunEvent
+	var message, data sql.NullString
+	err := m.db.QueryRow(`SELECT id, run_id, event_type, message, data, created_at FROM job_run_events_v2 WHERE run_id = ? AND event_type = ? ORDER BY id DESC LIMIT 1`, runID, eventType).
+		Scan(&ev.ID, &ev.RunID, &ev.EventType, &message, &data, &ev.CreatedAt)
+	if err != nil {
+		return jobsV2RunEvent{}, err
+	}
+	ev.Message = message.String
+	if data.Valid && data.String != "" {
+		ev.Data = json.RawMessage(data.String)
+	}
+	return ev, nil
+}
+
+// jobsV2RunOutputChange decides whether a successful run with
+// notify_on_change should notify, comparing it with the previous successful
+// run of the same job.
+func (s *serveServer) jobsV2RunOutputChange(ctx context.Context, run jobsV2Run, job jobsV2Job, cd jobsV2ChangeDetection, result jobsV2RunResult) (jobsV2OutputChange, error) {
+	if ev, err := s.jobsV2.lastRunEvent(run.ID, jobsV2ChangeEventType); err == nil {
+		var change jobsV2OutputChange
+		if err := json.Unmarshal(ev.Data, &change); err == nil {
+			return change, nil
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return jobsV2OutputChange{}, err
+	}
+
+	change := jobsV2OutputChange{Notify: true}
+	previous, err := s.jobsV2.PreviousSuccessfulRun(job.ID, run.ID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		change.Reason = "no previous successful run"
+	case err != nil:
+		return jobsV2OutputChange{}, err
+	default:
+		change.PreviousRunID = previous.ID
+		before := jobsV2ChangeValue(previous.Response, cd.Field)
+		after := jobsV2ChangeValue(result.Response, cd.Field)
+		change.Diff = formatJobsV2ChangeDiff(before, after)
+		if change.Diff == "" {
+			change.Notify = false
+			change.Reason = "output unchanged"
+		} else if cd.Judge {
+			meaningful, reason, err := s.judgeJobsV2OutputChange(ctx, job, cd, before, after)
+			if err != nil {
+				// Fail open: a broken judge should not hide a real change.
+				change.Reason = "judge failed: " + err.Error()
+			} else {
+				change.Judged = true
+				change.Notify = meaningful
+				change.Reason = reason
+			}
+		}
+	}
+
+	message := "output changed"
+	if !change.Notify {
+		message = "notification suppressed: " + change.Reason
+	}
+	_ = s.jobsV2.addRunEvent(run.ID, jobsV2ChangeEventType, message, change)
+	return change, nil
+}
+
+// jobsV2ChangeValue extracts the text compared between runs. With a field,
+// the response is parsed as JSON (optionally inside a ```json fence) and
+// the value at that dot path is compared; when that fails the whole
+// response is used so a malformed run still registers as a change.
+func jobsV2ChangeValue(response, field string) string {
+	if field = strings.TrimSpace(field); field != "" {
+		if decoded, ok := decodeJobsV2ResponseJSON(response); ok {
+			if value, ok := jobsV2LookupPath(decoded, field); ok {
+				if text, ok := value.(string); ok {
+					return normalizeJobsV2ChangeText(text)
+				}
+				// MarshalIndent sorts object keys, so key order in the
+				// model's output does not register as a change.
+				if encoded, err := json.MarshalIndent(value, "", "  "); err == nil {
+					return string(encoded)
+				}
+			}
+		}
+	}
+	return normalizeJobsV2ChangeText(response)
+}
+
+func decodeJobsV2ResponseJSON(response string) (any, bool) {
+	text := strings.TrimSpace(response)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if nl := strings.IndexByte(text, '\n'); nl >= 0 {
+			text = text[nl+1:]
+		}
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func normalizeJobsV2ChangeText(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// formatJobsV2ChangeDiff returns a trimmed unified diff between two outputs,
+// or "" when they are identical.
+func formatJobsV2ChangeDiff(before, after string) string {
+	if before == after {
+		return ""
+	}
+	raw := diff.Diff("previous", []byte(before+"\n"), "current", []byte(after+"\n"))
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(string(raw), "\n"), "\n") {
+		if strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	truncated := false
+	if len(lines) > jobsV2ChangeDiffMaxLines {
+		lines = lines[:jobsV2ChangeDiffMaxLines]
+		truncated = true
+	}
+	out := strings.Join(lines, "\n")
+	if utf8.RuneCountInString(out) > jobsV2ChangeDiffMaxRunes {
+		out = string([]rune(out)[:jobsV2ChangeDiffMaxRunes])
+		truncated = true
+	}
+	if truncated {
+		out += "\n... (diff truncated)"
+	}
+	return out
+}
+
+func formatJobsV2ChangeNotification(change jobsV2OutputChange) string {
+	if change.Diff == "" {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Changed since run %s:\n```diff\n%s\n```", change.PreviousRunID, change.Diff)
+	if change.Judged && change.Reason != "" {
+		b.WriteString("\nWhy it matters: " + change.Reason)
+	}
+	return b.String()
+}
+
+const jobsV2ChangeJudgeSystemPrompt = `You review the output of a recurring job that notifies a person each time its result changes.
+Decide whether the new output differs from the previous one in a way that person would want to be told about.
+Reworded text, reordered items, timestamps and other cosmetic differences are not meaningful.
+Reply with YES or NO on the first line, then one short sentence explaining why.`
+
+func (s *serveServer) judgeJobsV2OutputChange(ctx context.Context, job jobsV2Job, cd jobsV2ChangeDetection, before, after string) (bool, string, error) {
+	// The fast provider used for session titles is cheap enough to run on
+	// every changed result.
+	provider, err := s.newTitleProvider()
+	if err != nil {
+		return false, "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, jobsV2ChangeJudgeTimeout)
+	defer cancel()
+
+	var prompt bytes.Buffer
+	fmt.Fprintf(&prompt, "Job: %s\n", job.Name)
+	if criteria := strings.TrimSpace(cd.JudgeInstructions); criteria != "" {
+		fmt.Fprintf(&prompt, "What counts as meaningful: %s\n", criteria)
+	}
+	fmt.Fprintf(&prompt, "\nPrevious output:\n%s\n\nNew output:\n%s\n", jobsV2TruncateRunes(before, jobsV2ChangeJudgeMaxRunes), jobsV2TruncateRunes(after, jobsV2ChangeJudgeMaxRunes))
+
+	stream, err := provider.Stream(ctx, llm.Request{
+		Ephemeral: true,
+		Messages: []llm.Message{
+			llm.SystemText(jobsV2ChangeJudgeSystemPrompt),
+			llm.UserText(prompt.String()),
+		},
+		MaxTurns: 1,
+	})
+	if err != nil {
+		return false, "", err
+	}
+	defer stream.Close()
+	var reply strings.Builder
+	for {
+		ev, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, "", err
+		}
+		switch ev.Type {
+		case llm.EventTextDelta:
+			reply.WriteString(ev.Text)
+		case llm.EventError:
+			if ev.Err != nil {
+				return false, "", ev.Err
+			}
+			return false, "", fmt.Errorf("provider returned error event")
+		}
+	}
+	return parseJobsV2ChangeVerdict(reply.String())
+}
+
+// parseJobsV2ChangeVerdict reads the judge's YES/NO verdict. The verdict must
+// be a whole word, so replies like "Nothing changed" or "Yesterday's..." are
+// rejected rather than read as NO or YES.
+func parseJobsV2ChangeVerdict(reply string) (bool, string, error) {
+	reply = strings.TrimSpace(reply)
+	first, rest, _ := strings.Cut(reply, "\n")
+	head := strings.TrimLeft(strings.TrimSpace(first), "*# ")
+	end := strings.IndexFunc(head, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(head)
+	}
+	var meaningful bool
+	switch word := head[:end]; {
+	case strings.EqualFold(word, "YES"):
+		meaningful = true
+	case strings.EqualFold(word, "NO"):
+		meaningful = false
+	default:
+		return false, "", fmt.Errorf("unexpected judge reply %q", jobsV2TruncateRunes(reply, 80))
+	}
+	reason := strings.TrimSpace(rest)
+	if reason == "" {
+		reason = strings.TrimSpace(strings.TrimLeft(head[end:], "*.:,- "))
+	}
+	return meaningful, reason, nil
+}
+
+func jobsV2TruncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "..."
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/session"
+)
+
+func TestJobsV2ChangeValueAndDiff(t *testing.T) {
+	before := jobsV2ChangeValue("```json\n{\"checked_at\":\"09:00\",\"cves\":[{\"id\":\"CVE-1\",\"pkg\":\"a\"}]}\n```", "cves")
+	after := jobsV2ChangeValue(`{"cves":[{"pkg":"a","id":"CVE-1"}],"checked_at":"10:00"}`, "cves")
+	if before != after {
+		t.Fatalf("field values differ:\n%s\nvs\n%s", before, after)
+	}
+	if got := formatJobsV2ChangeDiff(before, after); got != "" {
+		t.Fatalf("diff of equal values = %q", got)
+	}
+	if got := jobsV2ChangeValue("not json  \r\nline two\t", "cves"); got != "not json\nline two" {
+		t.Fatalf("fallback value = %q", got)
+	}
+
+	d := formatJobsV2ChangeDiff("CVE-1\nCVE-2", "CVE-1\nCVE-3")
+	if !strings.Contains(d, "-CVE-2") || !strings.Contains(d, "+CVE-3") || strings.Contains(d, "+++") {
+		t.Fatalf("diff = %q", d)
+	}
+	long := formatJobsV2ChangeDiff("", strings.Repeat("x\n", 100))
+	if !strings.HasSuffix(long, "(diff truncated)") || strings.Count(long, "\n") > jobsV2ChangeDiffMaxLines+1 {
+		t.Fatalf("long diff not truncated: %d lines", strings.Count(long, "\n"))
+	}
+}
+
+func TestParseJobsV2ChangeVerdict(t *testing.T) {
+	for _, tc := range []struct {
+		reply      string
+		meaningful bool
+		reason     string
+	}{
+		{reply: "YES\nA new critical CVE affects openssl.", meaningful: true, reason: "A new critical CVE affects openssl."},
+		{reply: "**No** - only the timestamp changed", meaningful: false, reason: "only the timestamp changed"},
+		{reply: "yes: new advisory", meaningful: true, reason: "new advisory"},
+		{reply: "NO", meaningful: false, reason: ""},
+		{reply: "Yes, the fix version changed.", meaningful: true, reason: "the fix version changed."},
+	} {
+		meaningful, reason, err := parseJobsV2ChangeVerdict(tc.reply)
+		if err != nil || meaningful != tc.meaningful || reason != tc.reason {
+			t.Errorf("parse(%q) = %v, %q, %v; want %v, %q", tc.reply, meaningful, reason, err, tc.meaningful, tc.reason)
+		}
+	}
+	for _, reply := range []string{"Maybe?", "Notable: a new CVE was published", "Nothing changed", "Yesterday's advisory was withdrawn", "NOPE"} {
+		if _, _, err := parseJobsV2ChangeVerdict(reply); err == nil {
+			t.Errorf("parse(%q) accepted a verdict that isn't a whole YES or NO", reply)
+		}
+	}
+}
+
+func TestJobsV2NotifyOnChangeSuppressesUnchangedResults(t *testing.T) {
+	store := newServeRuntimeTestStore()
+	if err := store.Create(context.Background(), &session.Session{ID: "sess-origin", Origin: session.OriginWeb, Status: session.StatusActive}); err != nil {
+		t.Fatalf("Create session: %v", err)
+	}
+	judge := llm.NewMockProvider("judge").AddTextResponse("NO\nOnly the wording changed.")
+	srv := &serveServer{store: store, titleProviderFactory: func(*config.Config) (llm.Provider, error) { return judge, nil }}
+	mgr, err := newJobsV2ManagerWithNotifier(":memory:", 0, nil, srv.notifyJobsV2RunDone)
+	if err != nil {
+		t.Fatalf("newJobsV2ManagerWithNotifier: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+	srv.jobsV2 = mgr
+
+	runnerConfig, _ := json.Marshal(jobsV2LLMConfig{
+		AgentName:      "developer",
+		Instructions:   "check for new CVEs",
+		Cwd:            "/tmp/work",
+		NotifyWhenDone: true,
+		NotifyOrigin:   &jobsV2NotifyOrigin{Origin: "web", SessionID: "sess-origin"},
+		NotifyOnChange: &jobsV2ChangeDetection{Field: "cves"},
+	})
+	job, err := mgr.CreateJob(jobsV2Job{
+		Name:          "cve-watch",
+		Enabled:       true,
+		RunnerType:    jobsV2RunnerLLM,
+		RunnerConfig:  runnerConfig,
+		TriggerType:   jobsV2TriggerManual,
+		TriggerConfig: json.RawMessage(`{}`),
+	})
+	if err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+
+	finish := func(response string) string {
+		t.Helper()
+		run, err := mgr.TriggerJob(job.ID)
+		if err != nil {
+			t.Fatalf("TriggerJob: %v", err)
+		}
+		if err := mgr.finishRun(run.ID, jobsV2RunSucceeded, jobsV2RunResult{Response: response}, nil, run.Attempt); err != nil {
+			t.Fatalf("finishRun: %v", err)
+		}
+		waitForServeCondition(t, 2*time.Second, func() bool {
+			_, err := mgr.lastRunEvent(run.ID, jobsV2ChangeEventType)
+			return err == nil
+		}, "change detection decision")
+		return run.ID
+	}
+	messageCount := func() int {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		return len(store.messages["sess-origin"])
+	}
+
+	finish(`{"cves":["CVE-1"]}`)
+	waitForServeCondition(t, 2*time.Second, func() bool { return messageCount() == 1 }, "first result notification")
+
+	unchanged := finish(`{"cves":["CVE-1"],"note":"checked again"}`)
+	ev, err := mgr.lastRunEvent(unchanged, jobsV2ChangeEventType)
+	if err != nil || !strings.Contains(ev.Message, "suppressed") {
+		t.Fatalf("unchanged run event = %+v, %v", ev, err)
+	}
+
+	changedID := finish(`{"cves":["CVE-1","CVE-2"]}`)
+	waitForServeCondition(t, 2*time.Second, func() bool { return messageCount() == 2 }, "changed result notification")
+	store.mu.Lock()
+	text := store.messages["sess-origin"][1].TextContent
+	store.mu.Unlock()
+	if !strings.Contains(text, "Changed since run "+unchanged) || !strings.Contains(text, `+  "CVE-2"`) {
+		t.Fatalf("notification = %q, want diff against previous run", text)
+	}
+
+	// With the judge enabled, a change it rules cosmetic is suppressed.
+	var cfg map[string]any
+	_ = json.Unmarshal(runnerConfig, &cfg)
+	cfg["notify_on_change"] = map[string]any{"judge": true}
+	cfg["notify_origin"] = map[string]any{"origin": "web", "session_id": "sess-origin"}
+	updated, _ := json.Marshal(cfg)
+	if _, err := mgr.UpdateJobPatch(job.ID, jobsV2JobRequest{RunnerConfig: updated}); err != nil {
+		t.Fatalf("UpdateJobPatch: %v", err)
+	}
+	judged := finish(`{"cves":["CVE-1", "CVE-2"]}`)
+	ev, err = mgr.lastRunEvent(judged, jobsV2ChangeEventType)
+	if err != nil || !strings.Contains(ev.Message, "Only the wording changed.") {
+		t.Fatalf("judged run event = %+v, %v", ev, err)
+	}
+	if !strings.Contains(string(ev.Data), changedID) {
+		t.Fatalf("judged run compared against %s, want %s", ev.Data, changedID)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := messageCount(); got != 2 {
+		t.Fatalf("messages = %d after judged-cosmetic change, want 2", got)
+	}
+}
@@ -99,6 +99,23 @@ func (s *serveServer) notifyJobsV2RunDone(ctx context.Context, run jobsV2Run, jo
 		return nil
 	}
 	message := formatQueuedAgentDoneNotification(job.ID, cfg.AgentName, status, result, exitReason, errText)
+	if cfg.NotifyOnChange != nil && status == jobsV2RunSucceeded && s.jobsV2 != nil {
+		change, err := s.jobsV2RunOutputChange(ctx, run, job, *cfg.NotifyOnChange, result)
+		if err != nil {
+			return err
+		}
+		if !change.Notify {
+			return nil
+		}
+		if section := formatJobsV2ChangeNotification(change); section != "" {
+			message += "\n\n" + section
+		}
+	}
+	if s.jobsV2 != nil {
+		if artifacts, err := s.jobsV2.ListRunArtifacts(run.ID); err == nil && len(artifacts) > 0 {
+			message += "\n\n" + formatJobsV2ArtifactLinks(s.cfg.basePath, run.ID, artifacts)
+		}
+	}
 	switch strings.TrimSpace(origin.Origin) {
 	case tools.QueueAgentOriginWeb:
 		return s.notifyQueuedAgentWeb(ctx, run.ID, origin.SessionID, message)
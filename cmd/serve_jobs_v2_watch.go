@@ -0,0 +1,387 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const (
+	jobsV2WatchDefaultDebounce     = 2 * time.Second
+	jobsV2WatchDefaultPollInterval = time.Second
+	jobsV2WatchMinPollInterval     = 100 * time.Millisecond
+	// jobsV2WatchMaxFiles bounds a single directory snapshot so a watch on a
+	// huge tree degrades to a partial watch instead of unbounded memory.
+	jobsV2WatchMaxFiles = 50000
+	// jobsV2WatchMaxPayloadPaths bounds the changed paths stored on a run.
+	jobsV2WatchMaxPayloadPaths = 500
+)
+
+// Placeholders expanded in an llm watch job's instructions.
+const (
+	jobsV2WatchChangedPathsPlaceholder = "{{changed_paths}}"
+	jobsV2WatchDirPlaceholder          = "{{watch_dir}}"
+)
+
+// jobsV2WatchDefaultIgnore is always ignored in addition to trigger_config.ignore.
+var jobsV2WatchDefaultIgnore = []string{".git/**"}
+
+// jobsV2WatchPayload is stored in job_runs_v2.trigger_payload for watch runs.
+type jobsV2WatchPayload struct {
+	Dir       string   `json:"dir"`
+	Paths     []string `json:"paths"`
+	Total     int      `json:"total"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+// jobsV2Watcher is a running poller for one watch-triggered job. config is
+// the raw trigger_config it was started with so edits restart the watcher.
+type jobsV2Watcher struct {
+	config string
+	stop   chan struct{}
+}
+
+type jobsV2WatchFileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func validateJobsV2WatchConfig(cfg *jobsV2TriggerConfig) error {
+	dir := strings.TrimSpace(cfg.Dir)
+	if dir == "" {
+		return fmt.Errorf("trigger_config.dir is required for watch trigger")
+	}
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("trigger_config.dir must be an absolute path")
+	}
+	cfg.Dir = filepath.Clean(dir)
+	if len(cfg.Patterns) == 0 {
+		cfg.Patterns = []string{"**"}
+	}
+	for _, pattern := range cfg.Patterns {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("trigger_config.patterns: invalid glob %q", pattern)
+		}
+	}
+	for _, pattern := range cfg.Ignore {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("trigger_config.ignore: invalid glob %q", pattern)
+		}
+	}
+	if cfg.Debounce != "" {
+		d, err := time.ParseDuration(cfg.Debounce)
+		if err != nil || d < 0 {
+			return fmt.Errorf("trigger_config.debounce must be a non-negative duration")
+		}
+	}
+	if cfg.PollInterval != "" {
+		d, err := time.ParseDuration(cfg.PollInterval)
+		if err != nil || d < jobsV2WatchMinPollInterval {
+			return fmt.Errorf("trigger_config.poll_interval must be a duration of at least %s", jobsV2WatchMinPollInterval)
+		}
+	}
+	return nil
+}
+
+func jobsV2WatchDurations(cfg jobsV2TriggerConfig) (debounce, poll time.Duration) {
+	debounce = jobsV2WatchDefaultDebounce
+	if d, err := time.ParseDuration(cfg.Debounce); err == nil && d >= 0 {
+		debounce = d
+	}
+	poll = jobsV2WatchDefaultPollInterval
+	if d, err := time.ParseDuration(cfg.PollInterval); err == nil && d >= jobsV2WatchMinPollInterval {
+		poll = d
+	}
+	return debounce, poll
+}
+
+// reconcileWatchers starts a watcher for every enabled watch-triggered job
+// and stops watchers whose job was deleted, paused, or reconfigured. It runs
+// on every scheduler pass, so job mutations take effect immediately.
+func (m *jobsV2Manager) reconcileWatchers() error {
+	rows, err := m.db.Query(`SELECT id, trigger_config FROM jobs_v2 WHERE enabled = 1 AND trigger_type = ?`, string(jobsV2TriggerWatch))
+	if err != nil {
+		return err
+	}
+	want := make(map[string]string)
+	for rows.Next() {
+		var id, config string
+		if err := rows.Scan(&id, &config); err != nil {
+			rows.Close()
+			return err
+		}
+		want[id] = config
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	if m.watchers == nil {
+		m.watchers = make(map[string]*jobsV2Watcher)
+	}
+	for id, w := range m.watchers {
+		if config, ok := want[id]; !ok || config != w.config {
+			close(w.stop)
+			delete(m.watchers, id)
+		}
+	}
+	for id, config := range want {
+		if _, ok := m.watchers[id]; ok {
+			continue
+		}
+		var cfg jobsV2TriggerConfig
+		if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+			continue
+		}
+		if err := validateJobsV2WatchConfig(&cfg); err != nil {
+			continue
+		}
+		w := &jobsV2Watcher{config: config, stop: make(chan struct{})}
+		m.watchers[id] = w
+		m.wg.Add(1)
+		go m.runWatcher(id, cfg, w.stop)
+	}
+	return nil
+}
+
+func (m *jobsV2Manager) runWatcher(jobID string, cfg jobsV2TriggerConfig, stop <-chan struct{}) {
+	defer m.wg.Done()
+	debounce, poll := jobsV2WatchDurations(cfg)
+	ignore := append(append([]string(nil), jobsV2WatchDefaultIgnore...), cfg.Ignore...)
+
+	prev := snapshotJobsV2WatchDir(cfg.Dir, cfg.Patterns, ignore)
+	pending := make(map[string]struct{})
+	var lastChange time.Time
+
+	// Changes made while the server was down are picked up by comparing
+	// mtimes against the last watch run, so restarts do not lose events.
+	if since, ok := m.lastWatchRunAt(jobID); ok {
+		for path, st := range prev {
+			if st.modTime.After(since) {
+				pending[path] = struct{}{}
+			}
+		}
+		if len(pending) > 0 {
+			lastChange = time.Now()
+		}
+	}
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-m.doneChan():
+			return
+		case <-ticker.C:
+		}
+
+		cur := snapshotJobsV2WatchDir(cfg.Dir, cfg.Patterns, ignore)
+		if diffJobsV2WatchSnapshots(prev, cur, pending) {
+			lastChange = time.Now()
+		}
+		prev = cur
+		if len(pending) == 0 || time.Since(lastChange) < debounce {
+			continue
+		}
+		// When the concurrency limit is reached the paths stay pending, so a
+		// burst of changes during a run coalesces into a single follow-up run.
+		queued, err := m.enqueueWatchRun(jobID, cfg.Dir, pending)
+		if err != nil {
+			log.Printf("[jobs] watch %s: enqueue run: %v", jobID, err)
+			continue
+		}
+		if queued {
+			pending = make(map[string]struct{})
+		}
+	}
+}
+
+func (m *jobsV2Manager) lastWatchRunAt(jobID string) (time.Time, bool) {
+	var createdAt time.Time
+	err := m.db.QueryRow(`SELECT created_at FROM job_runs_v2 WHERE job_id = ? AND trigger = ? ORDER BY created_at DESC LIMIT 1`, jobID, string(jobsV2TriggerWatch)).Scan(&createdAt)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
+
+func (m *jobsV2Manager) enqueueWatchRun(jobID, dir string, pending map[string]struct{}) (bool, error) {
+	job, err := m.GetJob(jobID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return true, nil
+		}
+		return false, err
+	}
+	if !job.Enabled {
+		return true, nil
+	}
+
+	paths := make([]string, 0, len(pending))
+	for path := range pending {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	payload := jobsV2WatchPayload{Dir: dir, Paths: paths, Total: len(paths)}
+	if len(paths) > jobsV2WatchMaxPayloadPaths {
+		payload.Paths = paths[:jobsV2WatchMaxPayloadPaths]
+		payload.Truncated = true
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return false, err
+	}
+
+	runID, _, queued, err := m.enqueueRunWithConcurrencyLimit(job, string(jobsV2TriggerWatch), time.Now().UTC(), nil, func(tx *sql.Tx, runID string) error {
+		_, err := tx.Exec(`UPDATE job_runs_v2 SET trigger_payload = ? WHERE id = ?`, string(encoded), runID)
+		return err
+	})
+	if err != nil || !queued {
+		return false, err
+	}
+	_ = m.addRunEvent(runID, "queued", "watch run queued", map[string]any{"trigger": "watch", "attempt": 1, "changed": len(paths)})
+	m.notifyWorkers(1)
+	return true, nil
+}
+
+// snapshotJobsV2WatchDir returns the mtime and size of every file under dir
+// whose slash-separated relative path matches patterns and not ignore.
+func snapshotJobsV2WatchDir(dir string, patterns, ignore []string) map[string]jobsV2WatchFileState {
+	snapshot := make(map[string]jobsV2WatchFileState)
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && path != dir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if matchJobsV2WatchGlobs(ignore, rel) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !matchJobsV2WatchGlobs(patterns, rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if len(snapshot) >= jobsV2WatchMaxFiles {
+			return fs.SkipAll
+		}
+		snapshot[path] = jobsV2WatchFileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return snapshot
+}
+
+func matchJobsV2WatchGlobs(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// diffJobsV2WatchSnapshots adds created, modified, and removed paths to
+// pending and reports whether anything changed.
+func diffJobsV2WatchSnapshots(prev, cur map[string]jobsV2WatchFileState, pending map[string]struct{}) bool {
+	changed := false
+	for path, st := range cur {
+		if old, ok := prev[path]; !ok || !old.modTime.Equal(st.modTime) || old.size != st.size {
+			pending[path] = struct{}{}
+			changed = true
+		}
+	}
+	for path := range prev {
+		if _, ok := cur[path]; !ok {
+			pending[path] = struct{}{}
+			changed = true
+		}
+	}
+	return changed
+}
+
+// applyWatchContext hands the changed paths of a watch run to the runner:
+// llm jobs get them in their instructions, program jobs in their environment.
+func applyWatchContext(job *jobsV2Job, payload json.RawMessage) error {
+	var watch jobsV2WatchPayload
+	if err := json.Unmarshal(payload, &watch); err != nil {
+		return fmt.Errorf("invalid watch payload: %w", err)
+	}
+	var runnerConfig map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(stringOrEmptyRaw(job.RunnerConfig, "{}")), &runnerConfig); err != nil {
+		return fmt.Errorf("invalid runner config: %w", err)
+	}
+	switch job.RunnerType {
+	case jobsV2RunnerLLM:
+		var instructions string
+		_ = json.Unmarshal(runnerConfig["instructions"], &instructions)
+		encoded, err := json.Marshal(renderJobsV2WatchInstructions(instructions, watch))
+		if err != nil {
+			return err
+		}
+		runnerConfig["instructions"] = encoded
+	case jobsV2RunnerProgram:
+		var env []string
+		_ = json.Unmarshal(runnerConfig["env"], &env)
+		env = append(env,
+			"TERM_LLM_WATCH_DIR="+watch.Dir,
+			"TERM_LLM_CHANGED_PATHS="+strings.Join(watch.Paths, "\n"),
+		)
+		encoded, err := json.Marshal(env)
+		if err != nil {
+			return err
+		}
+		runnerConfig["env"] = encoded
+	default:
+		return nil
+	}
+	rendered, err := json.Marshal(runnerConfig)
+	if err != nil {
+		return err
+	}
+	job.RunnerConfig = rendered
+	return nil
+}
+
+func renderJobsV2WatchInstructions(instructions string, watch jobsV2WatchPayload) string {
+	list := "- " + strings.Join(watch.Paths, "\n- ")
+	if watch.Truncated {
+		list += fmt.Sprintf("\n(%d more not shown)", watch.Total-len(watch.Paths))
+	}
+	if strings.Contains(instructions, jobsV2WatchChangedPathsPlaceholder) || strings.Contains(instructions, jobsV2WatchDirPlaceholder) {
+		return strings.NewReplacer(
+			jobsV2WatchChangedPathsPlaceholder, list,
+			jobsV2WatchDirPlaceholder, watch.Dir,
+		).Replace(instructions)
+	}
+	return fmt.Sprintf("%s\n\n---\nThis run was triggered by changes under %s:\n%s", instructions, watch.Dir, list)
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateJobsV2WatchConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg := jobsV2TriggerConfig{Dir: dir + "/sub/.."}
+	if err := validateJobsV2WatchConfig(&cfg); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if cfg.Dir != dir || len(cfg.Patterns) != 1 || cfg.Patterns[0] != "**" {
+		t.Fatalf("normalized config = %+v, want cleaned dir and default pattern", cfg)
+	}
+
+	for _, tc := range []struct {
+		cfg  jobsV2TriggerConfig
+		want string
+	}{
+		{cfg: jobsV2TriggerConfig{}, want: "dir is required"},
+		{cfg: jobsV2TriggerConfig{Dir: "relative/path"}, want: "absolute"},
+		{cfg: jobsV2TriggerConfig{Dir: dir, Patterns: []string{"[bad"}}, want: "patterns"},
+		{cfg: jobsV2TriggerConfig{Dir: dir, Ignore: []string{"[bad"}}, want: "ignore"},
+		{cfg: jobsV2TriggerConfig{Dir: dir, Debounce: "soon"}, want: "debounce"},
+		{cfg: jobsV2TriggerConfig{Dir: dir, PollInterval: "10ms"}, want: "poll_interval"},
+	} {
+		cfg := tc.cfg
+		if err := validateJobsV2WatchConfig(&cfg); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("validate(%+v) err = %v, want %q", tc.cfg, err, tc.want)
+		}
+	}
+}
+
+func TestSnapshotJobsV2WatchDirAppliesPatternsAndIgnore(t *testing.T) {
+	dir := t.TempDir()
+	for _, rel := range []string{"main.go", "docs/readme.md", "vendor/lib/lib.go", ".git/HEAD", "internal/x.go"} {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(rel), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot := snapshotJobsV2WatchDir(dir, []string{"**/*.go"}, append([]string{"vendor/**"}, jobsV2WatchDefaultIgnore...))
+	var got []string
+	for path := range snapshot {
+		rel, _ := filepath.Rel(dir, path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	if len(got) != 2 || snapshot[filepath.Join(dir, "main.go")].size == 0 || snapshot[filepath.Join(dir, "internal", "x.go")].size == 0 {
+		t.Fatalf("snapshot paths = %v, want main.go and internal/x.go", got)
+	}
+
+	pending := make(map[string]struct{})
+	next := map[string]jobsV2WatchFileState{
+		filepath.Join(dir, "main.go"): {modTime: time.Now().Add(time.Hour), size: 1},
+		filepath.Join(dir, "new.go"):  {modTime: time.Now(), size: 1},
+	}
+	if !diffJobsV2WatchSnapshots(snapshot, next, pending) {
+		t.Fatal("diff reported no changes")
+	}
+	for _, rel := range []string{"main.go", "new.go", "internal/x.go"} {
+		if _, ok := pending[filepath.Join(dir, filepath.FromSlash(rel))]; !ok {
+			t.Errorf("pending missing %s: %v", rel, pending)
+		}
+	}
+}
+
+func TestRenderJobsV2WatchInstructions(t *testing.T) {
+	watch := jobsV2WatchPayload{Dir: "/srv/app", Paths: []string{"/srv/app/a.go", "/srv/app/b.go"}, Total: 3, Truncated: true}
+	got := renderJobsV2WatchInstructions("Review {{changed_paths}} in {{watch_dir}}", watch)
+	if got != "Review - /srv/app/a.go\n- /srv/app/b.go\n(1 more not shown) in /srv/app" {
+		t.Fatalf("rendered = %q", got)
+	}
+	got = renderJobsV2WatchInstructions("Review the changes.", watch)
+	if !strings.HasPrefix(got, "Review the changes.\n\n---\nThis run was triggered by changes under /srv/app:") || !strings.Contains(got, "- /srv/app/b.go") {
+		t.Fatalf("rendered = %q, want appended change list", got)
+	}
+}
+
+func TestJobsV2WatchTriggerQueuesRunWithChangedPaths(t *testing.T) {
+	dir := t.TempDir()
+	mgr, err := newJobsV2Manager(":memory:", 1, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager failed: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+
+	triggerConfig, _ := json.Marshal(jobsV2TriggerConfig{Dir: dir, Patterns: []string{"*.txt"}, Debounce: "0s", PollInterval: "100ms"})
+	job, err := mgr.CreateJob(jobsV2Job{
+		Name:           "on-change",
+		Enabled:        true,
+		RunnerType:     jobsV2RunnerProgram,
+		RunnerConfig:   json.RawMessage(`{"command":"sh","args":["-c","printf '%s|%s' \"$TERM_LLM_WATCH_DIR\" \"$TERM_LLM_CHANGED_PATHS\""]}`),
+		TriggerType:    jobsV2TriggerWatch,
+		TriggerConfig:  triggerConfig,
+		TimeoutSeconds: 30,
+	})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	if job.NextRunAt != nil {
+		t.Fatalf("watch job next_run_at = %v, want nil", job.NextRunAt)
+	}
+
+	target := filepath.Join(dir, "notes.txt")
+	deadline := time.Now().Add(8 * time.Second)
+	for i := 0; ; i++ {
+		// Keep touching the file until the watcher's first snapshot has been
+		// taken and a change is observed.
+		if err := os.WriteFile(target, []byte(fmt.Sprintf("edit %d", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.WriteFile(filepath.Join(dir, "ignored.md"), []byte(fmt.Sprintf("edit %d", i)), 0644)
+		runs, _, err := mgr.ListRuns(job.ID, 10, 0)
+		if err != nil {
+			t.Fatalf("ListRuns: %v", err)
+		}
+		var run *jobsV2Run
+		for j := range runs {
+			if runs[j].Status == jobsV2RunSucceeded {
+				run = &runs[j]
+			}
+		}
+		if run != nil {
+			if run.Trigger != "watch" {
+				t.Fatalf("run trigger = %q, want watch", run.Trigger)
+			}
+			if run.Stdout != dir+"|"+target {
+				t.Fatalf("run stdout = %q, want %q", run.Stdout, dir+"|"+target)
+			}
+			var payload jobsV2WatchPayload
+			if err := json.Unmarshal(run.TriggerPayload, &payload); err != nil {
+				t.Fatalf("decode trigger payload %q: %v", run.TriggerPayload, err)
+			}
+			if payload.Dir != dir || len(payload.Paths) != 1 || payload.Paths[0] != target {
+				t.Fatalf("trigger payload = %+v", payload)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for watch run; runs = %+v", runs)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Pausing the job stops its watcher.
+	disabled := false
+	if _, err := mgr.UpdateJobPatch(job.ID, jobsV2JobRequest{Enabled: &disabled}); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		mgr.mu.Lock()
+		_, running := mgr.watchers[job.ID]
+		mgr.mu.Unlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("watcher still running after job was paused")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
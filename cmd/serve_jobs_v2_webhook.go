@@ -0,0 +1,617 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature styles accepted in trigger_config.signature_style.
+const (
+	// jobsV2WebhookStyleGitHub expects X-Hub-Signature-256: sha256=<hex> and
+	// reads the event and delivery ID from X-GitHub-Event/X-GitHub-Delivery.
+	jobsV2WebhookStyleGitHub = "github"
+	// jobsV2WebhookStyleGeneric expects X-Signature-256 with an optional
+	// sha256= prefix and reads X-Event-Type/X-Delivery-ID.
+	jobsV2WebhookStyleGeneric = "generic"
+)
+
+const (
+	jobsV2WebhookMaxBodyBytes = 1 << 20
+	// jobsV2WebhookInlinePayloadBytes caps the payload copied into
+	// TERM_LLM_WEBHOOK_PAYLOAD; a single environment string above 128 KiB
+	// fails exec with E2BIG on Linux. TERM_LLM_WEBHOOK_PAYLOAD_FILE always
+	// carries the full payload.
+	jobsV2WebhookInlinePayloadBytes = 64 << 10
+	// jobsV2WebhookDeliveryEvent is the run event recorded for every accepted
+	// delivery and replay; listing these gives the delivery history of a job.
+	jobsV2WebhookDeliveryEvent = "webhook_delivery"
+)
+
+var (
+	errJobsV2WebhookNotFound  = errors.New("webhook not found")
+	errJobsV2WebhookSignature = errors.New("invalid webhook signature")
+	errJobsV2WebhookPayload   = errors.New("webhook payload must be valid JSON")
+	errJobsV2WebhookDisabled  = errors.New("job is disabled")
+	errJobsV2WebhookBusy      = errors.New("job already has the maximum number of active runs")
+	errJobsV2WebhookDuplicate = errors.New("webhook delivery already received")
+)
+
+// jobsV2WebhookTemplatePattern matches {{webhook_event}},
+// {{webhook_delivery_id}}, {{webhook_payload}} and {{webhook.<path>}}, where
+// path is a dot-separated list of object keys and array indexes.
+var jobsV2WebhookTemplatePattern = regexp.MustCompile(`\{\{\s*(webhook_event|webhook_delivery_id|webhook_payload|webhook\.[A-Za-z0-9_\-.]+)\s*\}\}`)
+
+// jobsV2WebhookPayload is stored in job_runs_v2.trigger_payload for webhook runs.
+type jobsV2WebhookPayload struct {
+	Event      string          `json:"event,omitempty"`
+	DeliveryID string          `json:"delivery_id"`
+	Payload    json.RawMessage `json:"payload"`
+	ReplayOf   string          `json:"replay_of,omitempty"`
+}
+
+// jobsV2WebhookDelivery is the response to a delivery or replay.
+type jobsV2WebhookDelivery struct {
+	Object     string `json:"object"`
+	JobID      string `json:"job_id"`
+	DeliveryID string `json:"delivery_id"`
+	Event      string `json:"event,omitempty"`
+	Status     string `json:"status"`
+	RunID      string `json:"run_id,omitempty"`
+	ReplayOf   string `json:"replay_of,omitempty"`
+}
+
+func validateJobsV2WebhookConfig(cfg *jobsV2TriggerConfig) error {
+	cfg.SignatureStyle = strings.ToLower(strings.TrimSpace(cfg.SignatureStyle))
+	if cfg.SignatureStyle == "" {
+		cfg.SignatureStyle = jobsV2WebhookStyleGitHub
+	}
+	switch cfg.SignatureStyle {
+	case jobsV2WebhookStyleGitHub, jobsV2WebhookStyleGeneric:
+	default:
+		return fmt.Errorf("trigger_config.signature_style must be one of: github, generic")
+	}
+	if cfg.Secret == "" && strings.TrimSpace(cfg.SecretEnv) == "" {
+		return fmt.Errorf("trigger_config.secret or trigger_config.secret_env is required for webhook trigger")
+	}
+	if cfg.Secret != "" && cfg.SecretEnv != "" {
+		return fmt.Errorf("trigger_config.secret and trigger_config.secret_env are mutually exclusive")
+	}
+	return nil
+}
+
+func jobsV2WebhookSecret(cfg jobsV2TriggerConfig) (string, error) {
+	if cfg.Secret != "" {
+		return cfg.Secret, nil
+	}
+	secret := os.Getenv(strings.TrimSpace(cfg.SecretEnv))
+	if secret == "" {
+		return "", fmt.Errorf("webhook secret env %s is not set", cfg.SecretEnv)
+	}
+	return secret, nil
+}
+
+// redactJobsV2WebhookSecret replaces trigger_config.secret with
+// "secret_set": true. The secret is write-only: nothing read back from the
+// API, including exports, carries it.
+func redactJobsV2WebhookSecret(raw json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return raw
+	}
+	if _, ok := fields["secret"]; !ok {
+		return raw
+	}
+	delete(fields, "secret")
+	fields["secret_set"] = json.RawMessage("true")
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return raw
+	}
+	return redacted
+}
+
+// keepJobsV2WebhookSecret carries the stored secret into an updated webhook
+// trigger config that names neither secret nor secret_env, so a config read
+// back from the API, where the secret is redacted, can be sent back as is.
+func keepJobsV2WebhookSecret(stored, next json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	var cfg, prev jobsV2TriggerConfig
+	if json.Unmarshal(next, &fields) != nil || json.Unmarshal(next, &cfg) != nil {
+		return next
+	}
+	_ = json.Unmarshal(stored, &prev)
+	delete(fields, "secret_set")
+	if cfg.Secret == "" && strings.TrimSpace(cfg.SecretEnv) == "" && prev.Secret != "" {
+		fields["secret"], _ = json.Marshal(prev.Secret)
+	}
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return next
+	}
+	return merged
+}
+
+// verifyJobsV2WebhookSignature checks the HMAC-SHA256 of body against the
+// signature header for style.
+func verifyJobsV2WebhookSignature(style, secret string, header http.Header, body []byte) error {
+	var got string
+	switch style {
+	case jobsV2WebhookStyleGeneric:
+		got = strings.TrimPrefix(strings.TrimSpace(header.Get("X-Signature-256")), "sha256=")
+	default:
+		sig := strings.TrimSpace(header.Get("X-Hub-Signature-256"))
+		if !strings.HasPrefix(sig, "sha256=") {
+			return errJobsV2WebhookSignature
+		}
+		got = strings.TrimPrefix(sig, "sha256=")
+	}
+	gotMAC, err := hex.DecodeString(got)
+	if err != nil || len(gotMAC) == 0 {
+		return errJobsV2WebhookSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(gotMAC, mac.Sum(nil)) {
+		return errJobsV2WebhookSignature
+	}
+	return nil
+}
+
+// jobsV2WebhookDeliveryMeta reads the event and delivery ID of a delivery.
+// Senders that omit the delivery ID get one derived from the body, so a
+// resent body is still recognised as the same delivery.
+func jobsV2WebhookDeliveryMeta(style string, header http.Header, body []byte) (event, deliveryID string) {
+	switch style {
+	case jobsV2WebhookStyleGeneric:
+		event = header.Get("X-Event-Type")
+		deliveryID = header.Get("X-Delivery-ID")
+	default:
+		event = header.Get("X-GitHub-Event")
+		deliveryID = header.Get("X-GitHub-Delivery")
+	}
+	event = strings.TrimSpace(event)
+	deliveryID = strings.TrimSpace(deliveryID)
+	if deliveryID == "" {
+		sum := sha256.Sum256(body)
+		deliveryID = "dlv_" + hex.EncodeToString(sum[:12])
+	}
+	return event, deliveryID
+}
+
+// DeliverWebhook verifies a signed delivery for a webhook-triggered job and
+// queues a run carrying its payload. Deliveries for events the job does not
+// subscribe to, GitHub ping events and delivery IDs the job has already
+// received are acknowledged without a run.
+func (m *jobsV2Manager) DeliverWebhook(jobID string, header http.Header, body []byte) (jobsV2WebhookDelivery, error) {
+	job, err := m.GetJob(jobID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return jobsV2WebhookDelivery{}, errJobsV2WebhookNotFound
+		}
+		return jobsV2WebhookDelivery{}, err
+	}
+	if job.TriggerType != jobsV2TriggerWebhook {
+		return jobsV2WebhookDelivery{}, errJobsV2WebhookNotFound
+	}
+	cfg, err := parseTriggerConfig(job.TriggerType, job.TriggerConfig, job.ScheduleTimezone)
+	if err != nil {
+		return jobsV2WebhookDelivery{}, err
+	}
+	secret, err := jobsV2WebhookSecret(cfg)
+	if err != nil {
+		// The sender can't fix server configuration, and the endpoint is
+		// unauthenticated: log the cause and answer like a bad signature.
+		log.Printf("jobs v2: webhook delivery for job %s rejected: %v", job.ID, err)
+		return jobsV2WebhookDelivery{}, errJobsV2WebhookSignature
+	}
+	if err := verifyJobsV2WebhookSignature(cfg.SignatureStyle, secret, header, body); err != nil {
+		return jobsV2WebhookDelivery{}, err
+	}
+	if !json.Valid(body) {
+		return jobsV2WebhookDelivery{}, errJobsV2WebhookPayload
+	}
+	if !job.Enabled {
+		return jobsV2WebhookDelivery{}, errJobsV2WebhookDisabled
+	}
+
+	event, deliveryID := jobsV2WebhookDeliveryMeta(cfg.SignatureStyle, header, body)
+	delivery := jobsV2WebhookDelivery{Object: "webhook_delivery", JobID: job.ID, DeliveryID: deliveryID, Event: event}
+	if event == "ping" || (len(cfg.Events) > 0 && !slices.Contains(cfg.Events, event)) {
+		delivery.Status = "ignored"
+		return delivery, nil
+	}
+	runID, err := m.enqueueWebhookRun(job, jobsV2WebhookPayload{Event: event, DeliveryID: deliveryID, Payload: json.RawMessage(body)})
+	if errors.Is(err, errJobsV2WebhookDuplicate) {
+		// Redeliveries and replayed requests carry a valid signature, so
+		// the delivery ID is what keeps them from running the job again.
+		delivery.Status = "duplicate"
+		delivery.RunID = runID
+		return delivery, nil
+	}
+	if err != nil {
+		return jobsV2WebhookDelivery{}, err
+	}
+	delivery.Status = "queued"
+	delivery.RunID = runID
+	return delivery, nil
+}
+
+// ReplayWebhookRun queues a new run with the delivery payload of an earlier
+// webhook run. Replays are authenticated by the jobs API token rather than a
+// signature.
+func (m *jobsV2Manager) ReplayWebhookRun(runID string) (jobsV2WebhookDelivery, error) {
+	run, err := m.GetRun(runID)
+	if err != nil {
+		return jobsV2WebhookDelivery{}, err
+	}
+	job, err := m.GetJob(run.JobID)
+	if err != nil {
+		return jobsV2WebhookDelivery{}, err
+	}
+	var payload jobsV2WebhookPayload
+	if job.TriggerType != jobsV2TriggerWebhook || len(run.TriggerPayload) == 0 || json.Unmarshal(run.TriggerPayload, &payload) != nil || payload.DeliveryID == "" {
+		return jobsV2WebhookDelivery{}, fmt.Errorf("run %s was not triggered by a webhook delivery", run.ID)
+	}
+	if !job.Enabled {
+		return jobsV2WebhookDelivery{}, errJobsV2WebhookDisabled
+	}
+	payload.ReplayOf = run.ID
+	newRunID, err := m.enqueueWebhookRun(job, payload)
+	if err != nil {
+		return jobsV2WebhookDelivery{}, err
+	}
+	return jobsV2WebhookDelivery{
+		Object:     "webhook_delivery",
+		JobID:      job.ID,
+		DeliveryID: payload.DeliveryID,
+		Event:      payload.Event,
+		Status:     "queued",
+		RunID:      newRunID,
+		ReplayOf:   run.ID,
+	}, nil
+}
+
+// enqueueWebhookRun queues a run for payload and records its delivery event
+// in the same transaction. A delivery ID the job has already received
+// returns the earlier run ID with errJobsV2WebhookDuplicate unless the
+// payload is a replay.
+func (m *jobsV2Manager) enqueueWebhookRun(job jobsV2Job, payload jobsV2WebhookPayload) (string, error) {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	message := "webhook delivery received"
+	if payload.ReplayOf != "" {
+		message = "webhook delivery replayed"
+	}
+	event, err := json.Marshal(map[string]any{
+		"delivery_id": payload.DeliveryID,
+		"event":       payload.Event,
+		"bytes":       len(payload.Payload),
+		"replay_of":   payload.ReplayOf,
+	})
+	if err != nil {
+		return "", err
+	}
+	var seenRunID string
+	checkSeen := func(tx *sql.Tx) error {
+		if payload.ReplayOf != "" {
+			return nil
+		}
+		err := tx.QueryRow(`SELECT e.run_id FROM job_run_events_v2 e JOIN job_runs_v2 r ON r.id = e.run_id
+			WHERE r.job_id = ? AND e.event_type = ? AND json_valid(e.data) AND json_extract(e.data, '$.delivery_id') = ?
+			ORDER BY e.id ASC LIMIT 1`, job.ID, jobsV2WebhookDeliveryEvent, payload.DeliveryID).Scan(&seenRunID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return errJobsV2WebhookDuplicate
+	}
+	runID, _, queued, err := m.enqueueRunWithConcurrencyLimit(job, string(jobsV2TriggerWebhook), time.Now().UTC(), checkSeen, func(tx *sql.Tx, runID string) error {
+		if err := checkSeen(tx); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE job_runs_v2 SET trigger_payload = ? WHERE id = ?`, string(encoded), runID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO job_run_events_v2 (run_id, event_type, message, data, created_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+			runID, jobsV2WebhookDeliveryEvent, message, string(event))
+		return err
+	})
+	if errors.Is(err, errJobsV2WebhookDuplicate) {
+		return seenRunID, err
+	}
+	if err != nil {
+		return "", err
+	}
+	if !queued {
+		return "", errJobsV2WebhookBusy
+	}
+	_ = m.addRunEvent(runID, "queued", "webhook run queued", map[string]any{"trigger": "webhook", "attempt": 1})
+	m.notifyWorkers(1)
+	return runID, nil
+}
+
+// ListWebhookDeliveries returns the webhook_delivery events recorded across
+// the runs of jobID, newest first.
+func (m *jobsV2Manager) ListWebhookDeliveries(jobID string, limit, offset int) ([]jobsV2RunEvent, int, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	var total int
+	if err := m.db.QueryRow(`SELECT COUNT(1) FROM job_run_events_v2 e JOIN job_runs_v2 r ON r.id = e.run_id WHERE r.job_id = ? AND e.event_type = ?`, jobID, jobsV2WebhookDeliveryEvent).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := m.db.Query(`SELECT e.id, e.run_id, e.event_type, e.message, e.data, e.created_at FROM job_run_events_v2 e JOIN job_runs_v2 r ON r.id = e.run_id WHERE r.job_id = ? AND e.event_type = ? ORDER BY e.id DESC LIMIT ? OFFSET ?`, jobID, jobsV2WebhookDeliveryEvent, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := make([]jobsV2RunEvent, 0)
+	for rows.Next() {
+		var ev jobsV2RunEvent
+		var message, data sql.NullString
+		if err := rows.Scan(&ev.ID, &ev.RunID, &ev.EventType, &message, &data, &ev.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		ev.Message = message.String
+		if data.Valid && data.String != "" {
+			ev.Data = json.RawMessage(data.String)
+		}
+		events = append(events, ev)
+	}
+	return events, total, rows.Err()
+}
+
+// applyWebhookContext exposes a webhook run's payload to the runner as
+// template variables: llm jobs in their instructions, program jobs in their
+// args and environment. Program jobs always get the payload as a file; only
+// small payloads are also inlined, since the environment is size-limited.
+func applyWebhookContext(job *jobsV2Job, raw json.RawMessage) error {
+	var payload jobsV2WebhookPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	var runnerConfig map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(stringOrEmptyRaw(job.RunnerConfig, "{}")), &runnerConfig); err != nil {
+		return fmt.Errorf("invalid runner config: %w", err)
+	}
+	switch job.RunnerType {
+	case jobsV2RunnerLLM:
+		var instructions string
+		_ = json.Unmarshal(runnerConfig["instructions"], &instructions)
+		encoded, err := json.Marshal(renderJobsV2WebhookInstructions(instructions, payload))
+		if err != nil {
+			return err
+		}
+		runnerConfig["instructions"] = encoded
+	case jobsV2RunnerProgram:
+		var args []string
+		_ = json.Unmarshal(runnerConfig["args"], &args)
+		for i, arg := range args {
+			args[i] = renderJobsV2WebhookTemplate(arg, payload)
+		}
+		var env []string
+		_ = json.Unmarshal(runnerConfig["env"], &env)
+		env = append(env,
+			"TERM_LLM_WEBHOOK_EVENT="+payload.Event,
+			"TERM_LLM_WEBHOOK_DELIVERY_ID="+payload.DeliveryID,
+		)
+		if len(payload.Payload) <= jobsV2WebhookInlinePayloadBytes {
+			env = append(env, "TERM_LLM_WEBHOOK_PAYLOAD="+string(payload.Payload))
+		}
+		var envFiles map[string]string
+		_ = json.Unmarshal(runnerConfig["env_files"], &envFiles)
+		if envFiles == nil {
+			envFiles = map[string]string{}
+		}
+		envFiles["TERM_LLM_WEBHOOK_PAYLOAD_FILE"] = string(payload.Payload)
+		encodedArgs, err := json.Marshal(args)
+		if err != nil {
+			return err
+		}
+		encodedEnv, err := json.Marshal(env)
+		if err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			runnerConfig["args"] = encodedArgs
+		}
+		encodedEnvFiles, err := json.Marshal(envFiles)
+		if err != nil {
+			return err
+		}
+		runnerConfig["env"] = encodedEnv
+		runnerConfig["env_files"] = encodedEnvFiles
+	default:
+		return nil
+	}
+	rendered, err := json.Marshal(runnerConfig)
+	if err != nil {
+		return err
+	}
+	job.RunnerConfig = rendered
+	return nil
+}
+
+func renderJobsV2WebhookInstructions(instructions string, payload jobsV2WebhookPayload) string {
+	if jobsV2WebhookTemplatePattern.MatchString(instructions) {
+		return renderJobsV2WebhookTemplate(instructions, payload)
+	}
+	var b strings.Builder
+	b.WriteString(instructions)
+	b.WriteString("\n\n---\nThis run was triggered by a webhook delivery")
+	if payload.Event != "" {
+		fmt.Fprintf(&b, " for event %q", payload.Event)
+	}
+	fmt.Fprintf(&b, " (delivery %s).\nPayload:\n", payload.DeliveryID)
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, payload.Payload, "", "  "); err == nil {
+		b.Write(indented.Bytes())
+	} else {
+		b.Write(payload.Payload)
+	}
+	return b.String()
+}
+
+// renderJobsV2WebhookTemplate expands webhook placeholders in a single pass,
+// so placeholder-looking text inside the payload is never expanded again.
+// Unknown paths expand to an empty string.
+func renderJobsV2WebhookTemplate(text string, payload jobsV2WebhookPayload) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	var decoded any
+	dec := json.NewDecoder(bytes.NewReader(payload.Payload))
+	dec.UseNumber()
+	_ = dec.Decode(&decoded)
+	return jobsV2WebhookTemplatePattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(match, "{{"), "}}"))
+		switch name {
+		case "webhook_event":
+			return payload.Event
+		case "webhook_delivery_id":
+			return payload.DeliveryID
+		case "webhook_payload":
+			return string(payload.Payload)
+		}
+		return jobsV2WebhookLookup(decoded, strings.TrimPrefix(name, "webhook."))
+	})
+}
+
+func jobsV2WebhookLookup(value any, path string) string {
+	value, ok := jobsV2LookupPath(value, path)
+	if !ok {
+		return ""
+	}
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(encoded)
+	}
+}
+
+// jobsV2LookupPath walks a decoded JSON value by dot path. Array elements
+// are addressed by index, e.g. "labels.0.name".
+func jobsV2LookupPath(value any, path string) (any, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []any:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, false
+			}
+			value = v[idx]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// jobsV2WebhookDeliveryJobID reports whether r is a webhook delivery
+// (POST /v2/jobs/{id}/hooks) and returns the job ID.
+func jobsV2WebhookDeliveryJobID(r *http.Request) (string, bool) {
+	if r.Method != http.MethodPost {
+		return "", false
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v2/jobs/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "hooks" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// jobsV2WebhookAuth routes signed webhook deliveries around token auth: the
+// sender (GitHub, CI) cannot present the API token, and the HMAC signature
+// authenticates the request instead. Everything else goes through next.
+func (s *serveServer) jobsV2WebhookAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if jobID, ok := jobsV2WebhookDeliveryJobID(r); ok && s.jobsV2 != nil {
+			s.handleJobV2WebhookDelivery(w, r, jobID)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *serveServer) handleJobV2WebhookDelivery(w http.ResponseWriter, r *http.Request, jobID string) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, jobsV2WebhookMaxBodyBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeOpenAIError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "webhook payload too large")
+			return
+		}
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "failed to read webhook payload")
+		return
+	}
+	delivery, err := s.jobsV2.DeliverWebhook(jobID, r.Header, body)
+	switch {
+	case err == nil:
+	case errors.Is(err, errJobsV2WebhookNotFound), errors.Is(err, errJobsV2WebhookSignature):
+		// Unknown jobs answer like bad signatures so unsigned requests
+		// can't probe which job IDs exist.
+		writeOpenAIError(w, http.StatusUnauthorized, "invalid_api_key", errJobsV2WebhookSignature.Error())
+		return
+	case errors.Is(err, errJobsV2WebhookPayload):
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	case errors.Is(err, errJobsV2WebhookDisabled):
+		writeOpenAIError(w, http.StatusConflict, "invalid_request_error", err.Error())
+		return
+	case errors.Is(err, errJobsV2WebhookBusy):
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate_limited", err.Error())
+		return
+	default:
+		log.Printf("jobs v2: webhook delivery for job %s failed: %v", jobID, err)
+		writeOpenAIError(w, http.StatusInternalServerError, "internal_error", "webhook delivery failed")
+		return
+	}
+	status := http.StatusAccepted
+	if delivery.Status == "ignored" || delivery.Status == "duplicate" {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, delivery)
+}
@@ -0,0 +1,442 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signJobsV2Webhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyJobsV2WebhookSignature(t *testing.T) {
+	body := []byte(`{"action":"completed"}`)
+	sig := signJobsV2Webhook("s3cret", body)
+
+	github := http.Header{}
+	github.Set("X-Hub-Signature-256", sig)
+	if err := verifyJobsV2WebhookSignature(jobsV2WebhookStyleGitHub, "s3cret", github, body); err != nil {
+		t.Fatalf("github signature rejected: %v", err)
+	}
+	if err := verifyJobsV2WebhookSignature(jobsV2WebhookStyleGitHub, "other", github, body); err == nil {
+		t.Fatal("github signature with wrong secret accepted")
+	}
+	bare := http.Header{}
+	bare.Set("X-Hub-Signature-256", strings.TrimPrefix(sig, "sha256="))
+	if err := verifyJobsV2WebhookSignature(jobsV2WebhookStyleGitHub, "s3cret", bare, body); err == nil {
+		t.Fatal("github signature without sha256= prefix accepted")
+	}
+
+	generic := http.Header{}
+	generic.Set("X-Signature-256", strings.TrimPrefix(sig, "sha256="))
+	if err := verifyJobsV2WebhookSignature(jobsV2WebhookStyleGeneric, "s3cret", generic, body); err != nil {
+		t.Fatalf("generic bare signature rejected: %v", err)
+	}
+	if err := verifyJobsV2WebhookSignature(jobsV2WebhookStyleGeneric, "s3cret", generic, []byte(`{"action":"tampered"}`)); err == nil {
+		t.Fatal("generic signature over a different body accepted")
+	}
+}
+
+func TestRenderJobsV2WebhookTemplate(t *testing.T) {
+	payload := jobsV2WebhookPayload{
+		Event:      "workflow_run",
+		DeliveryID: "d-1",
+		Payload:    json.RawMessage(`{"workflow_run":{"conclusion":"failure","run_number":1234567890123,"head_commit":{"message":"{{webhook_event}}"}},"labels":[{"name":"bug"}],"draft":false}`),
+	}
+	got := renderJobsV2WebhookTemplate("{{webhook_event}}/{{ webhook_delivery_id }}: {{webhook.workflow_run.conclusion}} #{{webhook.workflow_run.run_number}} {{webhook.labels.0.name}} draft={{webhook.draft}} missing=[{{webhook.nope.x}}] msg={{webhook.workflow_run.head_commit.message}}", payload)
+	want := "workflow_run/d-1: failure #1234567890123 bug draft=false missing=[] msg={{webhook_event}}"
+	if got != want {
+		t.Fatalf("rendered = %q, want %q", got, want)
+	}
+
+	appended := renderJobsV2WebhookInstructions("Triage the failure.", payload)
+	for _, want := range []string{"Triage the failure.", `event "workflow_run" (delivery d-1)`, `"conclusion": "failure"`} {
+		if !strings.Contains(appended, want) {
+			t.Fatalf("instructions = %q, want contains %q", appended, want)
+		}
+	}
+}
+
+func TestJobsV2WebhookDeliveryQueuesRunAndReplays(t *testing.T) {
+	mgr, err := newJobsV2Manager(":memory:", 1, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager failed: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+
+	if _, err := mgr.CreateJob(jobsV2Job{
+		Name:          "no-secret",
+		Enabled:       true,
+		RunnerType:    jobsV2RunnerProgram,
+		RunnerConfig:  json.RawMessage(`{"command":"true"}`),
+		TriggerType:   jobsV2TriggerWebhook,
+		TriggerConfig: json.RawMessage(`{}`),
+	}); err == nil || !strings.Contains(err.Error(), "secret") {
+		t.Fatalf("create without secret err = %v, want secret error", err)
+	}
+	job, err := mgr.CreateJob(jobsV2Job{
+		Name:              "triage",
+		Enabled:           true,
+		RunnerType:        jobsV2RunnerProgram,
+		RunnerConfig:      json.RawMessage(`{"command":"sh","args":["-c","printf '%s|%s|%s' \"$1\" \"$TERM_LLM_WEBHOOK_EVENT\" \"$TERM_LLM_WEBHOOK_DELIVERY_ID\"","triage","{{webhook.workflow_run.conclusion}}"]}`),
+		TriggerType:       jobsV2TriggerWebhook,
+		TriggerConfig:     json.RawMessage(`{"secret":"s3cret","events":["workflow_run"]}`),
+		ConcurrencyPolicy: "allow",
+		MaxConcurrentRuns: 4,
+		TimeoutSeconds:    30,
+	})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	srv := &serveServer{cfg: serveServerConfig{requireAuth: true, token: "api-token"}, jobsV2: mgr}
+	handler := srv.jobsV2WebhookAuth(srv.auth(srv.handleJobV2ByID))
+	deliver := func(event, sig string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v2/jobs/"+job.ID+"/hooks", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", event)
+		req.Header.Set("X-GitHub-Delivery", "delivery-1")
+		req.Header.Set("X-Hub-Signature-256", sig)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+
+	body := []byte(`{"workflow_run":{"conclusion":"failure"}}`)
+	if rr := deliver("workflow_run", signJobsV2Webhook("wrong", body), body); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("bad signature status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	if rr := deliver("issues", signJobsV2Webhook("s3cret", body), body); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"ignored"`) {
+		t.Fatalf("unsubscribed event status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	rr := deliver("workflow_run", signJobsV2Webhook("s3cret", body), body)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("delivery status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	var delivery jobsV2WebhookDelivery
+	if err := json.Unmarshal(rr.Body.Bytes(), &delivery); err != nil {
+		t.Fatalf("decode delivery: %v", err)
+	}
+	if delivery.Status != "queued" || delivery.RunID == "" || delivery.DeliveryID != "delivery-1" {
+		t.Fatalf("delivery = %+v", delivery)
+	}
+
+	run := waitForJobsV2RunStatus(t, mgr, delivery.RunID, jobsV2RunSucceeded)
+	if run.Stdout != "failure|workflow_run|delivery-1" {
+		t.Fatalf("run stdout = %q, want rendered webhook context", run.Stdout)
+	}
+
+	// Listing deliveries requires the API token.
+	req := httptest.NewRequest(http.MethodGet, "/v2/jobs/"+job.ID+"/hooks", nil)
+	rr = httptest.NewRecorder()
+	handler(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated list status = %d", rr.Code)
+	}
+
+	replay, err := mgr.ReplayWebhookRun(delivery.RunID)
+	if err != nil {
+		t.Fatalf("ReplayWebhookRun: %v", err)
+	}
+	if replay.ReplayOf != delivery.RunID || replay.DeliveryID != "delivery-1" {
+		t.Fatalf("replay = %+v", replay)
+	}
+	replayed := waitForJobsV2RunStatus(t, mgr, replay.RunID, jobsV2RunSucceeded)
+	if replayed.Stdout != run.Stdout {
+		t.Fatalf("replayed stdout = %q, want %q", replayed.Stdout, run.Stdout)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v2/jobs/"+job.ID+"/hooks", nil)
+	req.Header.Set("Authorization", "Bearer api-token")
+	rr = httptest.NewRecorder()
+	handler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("list status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	var list jobsRunEventsListResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(list.Data) != 2 || list.Data[0].RunID != replay.RunID || !strings.Contains(string(list.Data[0].Data), delivery.RunID) {
+		t.Fatalf("deliveries = %+v, want replay first then original", list.Data)
+	}
+}
+
+func TestJobsV2WebhookIgnoresRepeatedDeliveries(t *testing.T) {
+	mgr, err := newJobsV2Manager(":memory:", 1, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager failed: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+
+	job, err := mgr.CreateJob(jobsV2Job{
+		Name:              "dedupe",
+		Enabled:           true,
+		RunnerType:        jobsV2RunnerProgram,
+		RunnerConfig:      json.RawMessage(`{"command":"true"}`),
+		TriggerType:       jobsV2TriggerWebhook,
+		TriggerConfig:     json.RawMessage(`{"secret":"s3cret"}`),
+		ConcurrencyPolicy: "allow",
+		MaxConcurrentRuns: 8,
+		TimeoutSeconds:    30,
+	})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	srv := &serveServer{cfg: serveServerConfig{requireAuth: true, token: "api-token"}, jobsV2: mgr}
+	handler := srv.jobsV2WebhookAuth(srv.auth(srv.handleJobV2ByID))
+	deliver := func(deliveryID string, body []byte) (int, jobsV2WebhookDelivery) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v2/jobs/"+job.ID+"/hooks", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", "push")
+		if deliveryID != "" {
+			req.Header.Set("X-GitHub-Delivery", deliveryID)
+		}
+		req.Header.Set("X-Hub-Signature-256", signJobsV2Webhook("s3cret", body))
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		var delivery jobsV2WebhookDelivery
+		if err := json.Unmarshal(rr.Body.Bytes(), &delivery); err != nil {
+			t.Fatalf("decode delivery: %v body=%s", err, rr.Body.String())
+		}
+		return rr.Code, delivery
+	}
+	runCount := func() int {
+		t.Helper()
+		_, total, err := mgr.ListRuns(job.ID, 100, 0)
+		if err != nil {
+			t.Fatalf("ListRuns: %v", err)
+		}
+		return total
+	}
+
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	code, first := deliver("delivery-1", body)
+	if code != http.StatusAccepted || first.Status != "queued" {
+		t.Fatalf("first delivery = %d %+v", code, first)
+	}
+	// A GitHub redelivery or a captured request sent again carries the same
+	// delivery ID and a valid signature.
+	code, again := deliver("delivery-1", body)
+	if code != http.StatusOK || again.Status != "duplicate" || again.RunID != first.RunID {
+		t.Fatalf("repeated delivery = %d %+v, want 200 duplicate of %s", code, again, first.RunID)
+	}
+	if n := runCount(); n != 1 {
+		t.Fatalf("runs after repeated delivery = %d, want 1", n)
+	}
+
+	// Replays are explicit and still queue a run, and don't make the
+	// original delivery ID deliverable again.
+	if _, err := mgr.ReplayWebhookRun(first.RunID); err != nil {
+		t.Fatalf("ReplayWebhookRun: %v", err)
+	}
+	if code, again := deliver("delivery-1", body); code != http.StatusOK || again.Status != "duplicate" {
+		t.Fatalf("delivery after replay = %d %+v", code, again)
+	}
+	if code, other := deliver("delivery-2", body); code != http.StatusAccepted || other.Status != "queued" {
+		t.Fatalf("new delivery ID = %d %+v", code, other)
+	}
+
+	// Without a delivery header the ID is derived from the body.
+	if code, d := deliver("", []byte(`{"n":1}`)); code != http.StatusAccepted || d.Status != "queued" {
+		t.Fatalf("headerless delivery = %d %+v", code, d)
+	}
+	if code, d := deliver("", []byte(`{"n":1}`)); code != http.StatusOK || d.Status != "duplicate" {
+		t.Fatalf("repeated headerless delivery = %d %+v", code, d)
+	}
+	if code, d := deliver("", []byte(`{"n":2}`)); code != http.StatusAccepted || d.Status != "queued" {
+		t.Fatalf("different headerless delivery = %d %+v", code, d)
+	}
+	if n := runCount(); n != 5 {
+		t.Fatalf("runs = %d, want 5", n)
+	}
+}
+
+func TestJobsV2WebhookSecretIsWriteOnly(t *testing.T) {
+	mgr, err := newJobsV2Manager(":memory:", 1, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager failed: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+	srv := &serveServer{jobsV2: mgr}
+
+	call := func(handler http.HandlerFunc, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		if strings.Contains(rr.Body.String(), "s3cret") {
+			t.Fatalf("%s %s leaks the secret: %s", method, path, rr.Body.String())
+		}
+		return rr
+	}
+
+	rr := call(srv.handleJobsV2, http.MethodPost, "/v2/jobs", `{"name":"hook","runner_type":"program","runner_config":{"command":"true"},"trigger_type":"webhook","trigger_config":{"secret":"s3cret"}}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	var job jobsV2Job
+	if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	if !strings.Contains(string(job.TriggerConfig), `"secret_set":true`) {
+		t.Fatalf("trigger_config = %s, want secret_set", job.TriggerConfig)
+	}
+	call(srv.handleJobsV2, http.MethodGet, "/v2/jobs", "")
+	call(srv.handleJobV2ByID, http.MethodGet, "/v2/jobs/"+job.ID, "")
+
+	// Sending the redacted config back, or a config without any secret,
+	// keeps the stored secret.
+	patch, err := json.Marshal(map[string]any{"trigger_config": json.RawMessage(job.TriggerConfig)})
+	if err != nil {
+		t.Fatalf("marshal patch: %v", err)
+	}
+	if rr := call(srv.handleJobV2ByID, http.MethodPatch, "/v2/jobs/"+job.ID, string(patch)); rr.Code != http.StatusOK {
+		t.Fatalf("patch redacted status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	if rr := call(srv.handleJobV2ByID, http.MethodPatch, "/v2/jobs/"+job.ID, `{"trigger_config":{"events":["push"]}}`); rr.Code != http.StatusOK {
+		t.Fatalf("patch without secret status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	stored, err := mgr.GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	cfg, err := parseTriggerConfig(stored.TriggerType, stored.TriggerConfig, stored.ScheduleTimezone)
+	if err != nil {
+		t.Fatalf("parseTriggerConfig: %v", err)
+	}
+	if cfg.Secret != "s3cret" || !slices.Equal(cfg.Events, []string{"push"}) || strings.Contains(string(stored.TriggerConfig), "secret_set") {
+		t.Fatalf("stored trigger_config = %s, want secret kept and events updated", stored.TriggerConfig)
+	}
+
+	// Switching to secret_env drops the stored secret.
+	if rr := call(srv.handleJobV2ByID, http.MethodPatch, "/v2/jobs/"+job.ID, `{"trigger_config":{"secret_env":"HOOK_SECRET"}}`); rr.Code != http.StatusOK {
+		t.Fatalf("patch secret_env status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	if stored, _ = mgr.GetJob(job.ID); strings.Contains(string(stored.TriggerConfig), "s3cret") {
+		t.Fatalf("stored trigger_config = %s, want secret replaced by secret_env", stored.TriggerConfig)
+	}
+}
+
+func TestJobsV2WebhookRejectionsDoNotRevealJobs(t *testing.T) {
+	mgr, err := newJobsV2Manager(":memory:", 1, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager failed: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+
+	unsetEnv, err := mgr.CreateJob(jobsV2Job{
+		Name:          "unset-env",
+		Enabled:       true,
+		RunnerType:    jobsV2RunnerProgram,
+		RunnerConfig:  json.RawMessage(`{"command":"true"}`),
+		TriggerType:   jobsV2TriggerWebhook,
+		TriggerConfig: json.RawMessage(`{"secret_env":"TERM_LLM_TEST_UNSET_WEBHOOK_SECRET"}`),
+	})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	manual, err := mgr.CreateJob(jobsV2Job{
+		Name:         "manual",
+		Enabled:      true,
+		RunnerType:   jobsV2RunnerProgram,
+		RunnerConfig: json.RawMessage(`{"command":"true"}`),
+		TriggerType:  jobsV2TriggerManual,
+	})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	srv := &serveServer{cfg: serveServerConfig{requireAuth: true, token: "api-token"}, jobsV2: mgr}
+	handler := srv.jobsV2WebhookAuth(srv.auth(srv.handleJobV2ByID))
+	body := []byte(`{}`)
+	var first string
+	for _, jobID := range []string{"missing-job", manual.ID, unsetEnv.ID} {
+		req := httptest.NewRequest(http.MethodPost, "/v2/jobs/"+jobID+"/hooks", strings.NewReader(string(body)))
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", signJobsV2Webhook("guess", body))
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("job %s status = %d body=%s, want 401", jobID, rr.Code, rr.Body.String())
+		}
+		if strings.Contains(rr.Body.String(), "TERM_LLM_TEST_UNSET_WEBHOOK_SECRET") {
+			t.Fatalf("job %s response leaks secret env name: %s", jobID, rr.Body.String())
+		}
+		if first == "" {
+			first = rr.Body.String()
+		} else if rr.Body.String() != first {
+			t.Fatalf("job %s response = %s, want the same as %s", jobID, rr.Body.String(), first)
+		}
+	}
+}
+
+func TestJobsV2WebhookLargePayloadIsPassedAsFile(t *testing.T) {
+	mgr, err := newJobsV2Manager(":memory:", 1, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager failed: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+
+	job, err := mgr.CreateJob(jobsV2Job{
+		Name:           "large",
+		Enabled:        true,
+		RunnerType:     jobsV2RunnerProgram,
+		RunnerConfig:   json.RawMessage(`{"command":"sh","args":["-c","printf '%s|' \"${TERM_LLM_WEBHOOK_PAYLOAD:-unset}\"; wc -c < \"$TERM_LLM_WEBHOOK_PAYLOAD_FILE\" | tr -d ' '"]}`),
+		TriggerType:    jobsV2TriggerWebhook,
+		TriggerConfig:  json.RawMessage(`{"secret":"s3cret"}`),
+		TimeoutSeconds: 30,
+	})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	body := []byte(`{"blob":"` + strings.Repeat("x", 200<<10) + `"}`)
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+	header.Set("X-GitHub-Delivery", "delivery-large")
+	header.Set("X-Hub-Signature-256", signJobsV2Webhook("s3cret", body))
+	delivery, err := mgr.DeliverWebhook(job.ID, header, body)
+	if err != nil {
+		t.Fatalf("DeliverWebhook: %v", err)
+	}
+
+	run := waitForJobsV2RunStatus(t, mgr, delivery.RunID, jobsV2RunSucceeded)
+	if want := fmt.Sprintf("unset|%d\n", len(body)); run.Stdout != want {
+		t.Fatalf("run stdout = %q, want %q", run.Stdout, want)
+	}
+}
+
+func waitForJobsV2RunStatus(t *testing.T, mgr *jobsV2Manager, runID string, status jobsV2RunStatus) jobsV2Run {
+	t.Helper()
+	deadline := time.Now().Add(8 * time.Second)
+	for {
+		run, err := mgr.GetRun(runID)
+		if err != nil {
+			t.Fatalf("GetRun(%s): %v", runID, err)
+		}
+		if run.Status == status {
+			return run
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for run %s to reach %s; run = %+v", runID, status, run)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,754 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// jobsV2WorkerLabelsKey is the job label listing the worker labels a run
+	// needs. Jobs without it only run on the server's own workers.
+	jobsV2WorkerLabelsKey = "worker_labels"
+
+	jobsV2DefaultLeaseDuration = time.Minute
+	jobsV2MinLeaseDuration     = 10 * time.Second
+	jobsV2MaxLeaseDuration     = 10 * time.Minute
+	jobsV2MaxClaimWait         = 30 * time.Second
+	// jobsV2ClaimPollDelay bounds a long-poll wait between wakeups, so runs
+	// queued with a future scheduled_for (retries) are still picked up.
+	jobsV2ClaimPollDelay = 5 * time.Second
+	// jobsV2WorkerLostMaxAttempts is how many attempts a run gets when remote
+	// workers keep losing it, regardless of the job's retry policy.
+	jobsV2WorkerLostMaxAttempts = 3
+	jobsV2WorkerRetentionDays   = 7
+)
+
+var (
+	jobsV2WorkerLabelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:=/-]{0,63}$`)
+	jobsV2WorkerIDPattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+	errJobsV2LeaseLost        = errors.New("run lease is not held by this worker")
+	errJobsV2CompletionStatus = errors.New("status must be one of: succeeded, failed, timed_out, cancelled")
+)
+
+const jobsV2WorkersSchema = `
+CREATE TABLE IF NOT EXISTS job_workers_v2 (
+	id TEXT PRIMARY KEY,
+	name TEXT,
+	labels TEXT,
+	last_seen_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS job_run_leases_v2 (
+	run_id TEXT PRIMARY KEY REFERENCES job_runs_v2(id) ON DELETE CASCADE,
+	worker_id TEXT NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_run_leases_v2_expires_at ON job_run_leases_v2(expires_at);
+`
+
+// jobsV2Worker describes a worker for GET /v2/workers.
+type jobsV2Worker struct {
+	ID         string              `json:"id"`
+	Name       string              `json:"name,omitempty"`
+	Labels     []string            `json:"labels"`
+	Remote     bool                `json:"remote"`
+	LastSeenAt *time.Time          `json:"last_seen_at,omitempty"`
+	ActiveRuns []jobsV2WorkerLease `json:"active_runs"`
+}
+
+type jobsV2WorkerLease struct {
+	RunID     string    `json:"run_id"`
+	JobID     string    `json:"job_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type jobsV2ClaimRequest struct {
+	WorkerID     string   `json:"worker_id"`
+	Name         string   `json:"name,omitempty"`
+	Labels       []string `json:"labels,omitempty"`
+	LeaseSeconds int      `json:"lease_seconds,omitempty"`
+	WaitSeconds  int      `json:"wait_seconds,omitempty"`
+}
+
+// jobsV2ClaimResponse hands a remote worker its run. Job already has the
+// upstream, watch or webhook context applied.
+type jobsV2ClaimResponse struct {
+	Run            jobsV2Run `json:"run"`
+	Job            jobsV2Job `json:"job"`
+	LeaseSeconds   int       `json:"lease_seconds"`
+	LeaseExpiresAt time.Time `json:"lease_expires_at"`
+}
+
+type jobsV2HeartbeatRequest struct {
+	WorkerID     string `json:"worker_id"`
+	LeaseSeconds int    `json:"lease_seconds,omitempty"`
+}
+
+type jobsV2HeartbeatResponse struct {
+	Cancel         bool      `json:"cancel"`
+	LeaseExpiresAt time.Time `json:"lease_expires_at"`
+}
+
+type jobsV2ProgressEvent struct {
+	EventType string          `json:"event_type"`
+	Message   string          `json:"message,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+type jobsV2ProgressRequest struct {
+	WorkerID string                `json:"worker_id"`
+	Events   []jobsV2ProgressEvent `json:"events"`
+}
+
+// jobsV2RemoteResult is jobsV2RunResult on the wire.
+type jobsV2RemoteResult struct {
+	ExitCode     int    `json:"exit_code"`
+	Stdout       string `json:"stdout,omitempty"`
+	Stderr       string `json:"stderr,omitempty"`
+	Thinking     string `json:"thinking,omitempty"`
+	Response     string `json:"response,omitempty"`
+	SessionID    string `json:"session_id,omitempty"`
+	TurnCount    int    `json:"turn_count,omitempty"`
+	InputTokens  int    `json:"input_tokens,omitempty"`
+	OutputTokens int    `json:"output_tokens,omitempty"`
+	ExitReason   string `json:"exit_reason,omitempty"`
+	Truncated    bool   `json:"truncated,omitempty"`
+}
+
+type jobsV2CompleteRequest struct {
+	WorkerID string             `json:"worker_id"`
+	Status   jobsV2RunStatus    `json:"status"`
+	Error    string             `json:"error,omitempty"`
+	Result   jobsV2RemoteResult `json:"result"`
+}
+
+func (r jobsV2RemoteResult) runResult() jobsV2RunResult {
+	return jobsV2RunResult{
+		ExitCode:     r.ExitCode,
+		Stdout:       r.Stdout,
+		Stderr:       r.Stderr,
+		Thinking:     r.Thinking,
+		Response:     r.Response,
+		SessionID:    r.SessionID,
+		TurnCount:    r.TurnCount,
+		InputTokens:  r.InputTokens,
+		OutputTokens: r.OutputTokens,
+		ExitReason:   r.ExitReason,
+		Truncated:    r.Truncated,
+	}
+}
+
+func jobsV2RemoteResultFrom(r jobsV2RunResult) jobsV2RemoteResult {
+	return jobsV2RemoteResult{
+		ExitCode:     r.ExitCode,
+		Stdout:       r.Stdout,
+		Stderr:       r.Stderr,
+		Thinking:     r.Thinking,
+		Response:     r.Response,
+		SessionID:    r.SessionID,
+		TurnCount:    r.TurnCount,
+		InputTokens:  r.InputTokens,
+		OutputTokens: r.OutputTokens,
+		ExitReason:   r.ExitReason,
+		Truncated:    r.Truncated,
+	}
+}
+
+// normalizeJobsV2WorkerLabels trims, de-duplicates and sorts worker labels.
+func normalizeJobsV2WorkerLabels(labels []string) ([]string, error) {
+	seen := make(map[string]bool, len(labels))
+	out := make([]string, 0, len(labels))
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" || seen[label] {
+			continue
+		}
+		if !jobsV2WorkerLabelPattern.MatchString(label) {
+			return nil, fmt.Errorf("invalid worker label %q: use letters, digits and '.', '_', ':', '=', '/', '-' (max 64 characters)", label)
+		}
+		seen[label] = true
+		out = append(out, label)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// jobsV2RequiredWorkerLabels returns the labels.worker_labels of a job, given
+// either as a list or a comma-separated string.
+func jobsV2RequiredWorkerLabels(labels json.RawMessage) ([]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	var parsed map[string]json.RawMessage
+	if err := json.Unmarshal(labels, &parsed); err != nil {
+		return nil, nil
+	}
+	raw, ok := parsed[jobsV2WorkerLabelsKey]
+	if !ok || string(raw) == "null" {
+		return nil, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		var joined string
+		if err := json.Unmarshal(raw, &joined); err != nil {
+			return nil, fmt.Errorf("labels.%s must be a list of strings or a comma-separated string", jobsV2WorkerLabelsKey)
+		}
+		list = strings.Split(joined, ",")
+	}
+	required, err := normalizeJobsV2WorkerLabels(list)
+	if err != nil {
+		return nil, fmt.Errorf("labels.%s: %w", jobsV2WorkerLabelsKey, err)
+	}
+	return required, nil
+}
+
+// jobsV2WorkerCanRun reports whether a worker with labels have may run a job
+// needing required. Jobs without worker labels stay on the server's own
+// workers, so remote workers only take work that was routed to them.
+func jobsV2WorkerCanRun(required, have []string, remote bool) bool {
+	if len(required) == 0 {
+		return !remote
+	}
+	for _, label := range required {
+		i := sort.SearchStrings(have, label)
+		if i >= len(have) || have[i] != label {
+			return false
+		}
+	}
+	return true
+}
+
+func jobsV2LeaseDuration(seconds int) time.Duration {
+	if seconds <= 0 {
+		return jobsV2DefaultLeaseDuration
+	}
+	lease := time.Duration(seconds) * time.Second
+	if lease < jobsV2MinLeaseDuration {
+		return jobsV2MinLeaseDuration
+	}
+	if lease > jobsV2MaxLeaseDuration {
+		return jobsV2MaxLeaseDuration
+	}
+	return lease
+}
+
+func (m *jobsV2Manager) localWorkerLabels() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.workerLabels
+}
+
+// SetWorkerLabels sets the labels advertised by this process's own workers.
+func (m *jobsV2Manager) SetWorkerLabels(labels []string) error {
+	normalized, err := normalizeJobsV2WorkerLabels(labels)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.workerLabels = normalized
+	m.mu.Unlock()
+	m.notifyWorkers(m.workers)
+	return nil
+}
+
+type jobsV2Querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// nextEligibleQueuedRun returns the oldest queued run a worker with labels
+// may take. With dueBy set, only runs scheduled by then are considered.
+func nextEligibleQueuedRun(q jobsV2Querier, labels []string, remote bool, dueBy *time.Time) (string, time.Time, bool, error) {
+	query := `SELECT r.id, r.scheduled_for, j.labels FROM job_runs_v2 r JOIN jobs_v2 j ON j.id = r.job_id WHERE r.status = ?`
+	args := []any{jobsV2RunQueued}
+	if dueBy != nil {
+		query += ` AND r.scheduled_for <= ?`
+		args = append(args, dueBy.UTC())
+	}
+	rows, err := q.Query(query+` ORDER BY r.scheduled_for ASC, r.id ASC`, args...)
+	if err != nil {
+		return "", time.Time{}, false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var scheduledFor time.Time
+		var jobLabels sql.NullString
+		if err := rows.Scan(&id, &scheduledFor, &jobLabels); err != nil {
+			return "", time.Time{}, false, err
+		}
+		// Jobs are validated on write; a label that no longer parses falls
+		// back to the server's own workers rather than stranding the run.
+		required, _ := jobsV2RequiredWorkerLabels(json.RawMessage(jobLabels.String))
+		if jobsV2WorkerCanRun(required, labels, remote) {
+			return id, scheduledFor, true, nil
+		}
+	}
+	return "", time.Time{}, false, rows.Err()
+}
+
+func (m *jobsV2Manager) touchWorker(id, name string, labels []string) error {
+	encoded, _ := json.Marshal(labels)
+	_, err := m.db.Exec(`INSERT INTO job_workers_v2 (id, name, labels, last_seen_at, created_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name, labels = excluded.labels, last_seen_at = excluded.last_seen_at`,
+		id, nullableString(name), string(encoded), time.Now().UTC())
+	return err
+}
+
+// ClaimRunForWorker hands the oldest eligible queued run to a remote worker
+// under a lease. The run goes straight to running; the worker must
+// heartbeat before the lease expires or the run is failed as worker_lost.
+func (m *jobsV2Manager) ClaimRunForWorker(req jobsV2ClaimRequest) (jobsV2ClaimResponse, bool, error) {
+	lease := jobsV2LeaseDuration(req.LeaseSeconds)
+	if err := m.touchWorker(req.WorkerID, req.Name, req.Labels); err != nil {
+		return jobsV2ClaimResponse{}, false, err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return jobsV2ClaimResponse{}, false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+	runID, _, ok, err := nextEligibleQueuedRun(tx, req.Labels, true, &now)
+	if err != nil || !ok {
+		return jobsV2ClaimResponse{}, false, err
+	}
+	res, err := tx.Exec(`UPDATE job_runs_v2 SET status = ?, worker_id = ?, started_at = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?`, jobsV2RunRunning, req.WorkerID, now, runID, jobsV2RunQueued)
+	if err != nil {
+		return jobsV2ClaimResponse{}, false, err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return jobsV2ClaimResponse{}, false, nil
+	}
+	expires := now.Add(lease)
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO job_run_leases_v2 (run_id, worker_id, expires_at) VALUES (?, ?, ?)`, runID, req.WorkerID, expires); err != nil {
+		return jobsV2ClaimResponse{}, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return jobsV2ClaimResponse{}, false, err
+	}
+
+	run, err := m.GetRun(runID)
+	if err != nil {
+		return jobsV2ClaimResponse{}, false, err
+	}
+	_ = m.addRunEvent(run.ID, "claimed", "run claimed by remote worker", map[string]any{"worker_id": req.WorkerID, "labels": req.Labels})
+	_ = m.addRunEvent(run.ID, "running", "run started", map[string]any{"worker_id": req.WorkerID, "lease_expires_at": expires})
+
+	job, err := m.prepareRunJob(run)
+	if err != nil {
+		m.finishRunWithRetry(run.ID, jobsV2RunFailed, jobsV2RunResult{}, err, run.Attempt)
+		m.releaseLease(run.ID)
+		return jobsV2ClaimResponse{}, false, nil
+	}
+	return jobsV2ClaimResponse{Run: run, Job: job, LeaseSeconds: int(lease / time.Second), LeaseExpiresAt: expires}, true, nil
+}
+
+// WaitForRemoteWork blocks until runs may have been queued, ctx ends, or
+// wait elapses. It returns false once the wait is over.
+func (m *jobsV2Manager) WaitForRemoteWork(ctx context.Context, wait time.Duration) bool {
+	m.mu.Lock()
+	if m.remoteWake == nil {
+		m.remoteWake = make(chan struct{})
+	}
+	wake := m.remoteWake
+	m.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer stopTimer(timer)
+	select {
+	case <-wake:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	case <-m.doneChan():
+		return false
+	}
+}
+
+func (m *jobsV2Manager) wakeRemoteWorkers() {
+	m.mu.Lock()
+	if m.remoteWake != nil {
+		close(m.remoteWake)
+		m.remoteWake = nil
+	}
+	m.mu.Unlock()
+}
+
+// leaseHolder checks that workerID holds the lease on an active run.
+func (m *jobsV2Manager) leaseHolder(runID, workerID string) (jobsV2Run, error) {
+	var holder string
+	err := m.db.QueryRow(`SELECT worker_id FROM job_run_leases_v2 WHERE run_id = ?`, runID).Scan(&holder)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && holder != workerID) {
+		return jobsV2Run{}, errJobsV2LeaseLost
+	}
+	if err != nil {
+		return jobsV2Run{}, err
+	}
+	run, err := m.GetRun(runID)
+	if err != nil {
+		return jobsV2Run{}, err
+	}
+	if run.Status != jobsV2RunRunning && run.Status != jobsV2RunCancelRequested {
+		return jobsV2Run{}, errJobsV2LeaseLost
+	}
+	return run, nil
+}
+
+// RenewLease extends a remote worker's lease and reports whether the run
+// has been asked to cancel.
+func (m *jobsV2Manager) RenewLease(runID string, req jobsV2HeartbeatRequest) (jobsV2HeartbeatResponse, error) {
+	run, err := m.leaseHolder(runID, req.WorkerID)
+	if err != nil {
+		return jobsV2HeartbeatResponse{}, err
+	}
+	now := time.Now().UTC()
+	expires := now.Add(jobsV2LeaseDuration(req.LeaseSeconds))
+	res, err := m.db.Exec(`UPDATE job_run_leases_v2 SET expires_at = ? WHERE run_id = ? AND worker_id = ?`, expires, runID, req.WorkerID)
+	if err != nil {
+		return jobsV2HeartbeatResponse{}, err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return jobsV2HeartbeatResponse{}, errJobsV2LeaseLost
+	}
+	_, _ = m.db.Exec(`UPDATE job_workers_v2 SET last_seen_at = ? WHERE id = ?`, now, req.WorkerID)
+	m.notifyScheduler()
+	return jobsV2HeartbeatResponse{Cancel: run.Status == jobsV2RunCancelRequested, LeaseExpiresAt: expires}, nil
+}
+
+// RecordRemoteProgress applies progress events reported by a remote worker
+// the same way a local run's progress writer does.
+func (m *jobsV2Manager) RecordRemoteProgress(runID string, req jobsV2ProgressRequest) error {
+	if _, err := m.leaseHolder(runID, req.WorkerID); err != nil {
+		return err
+	}
+	pw := m.runProgressWriter(runID)
+	for _, ev := range req.Events {
+		var data any
+		if len(ev.Data) > 0 {
+			data = ev.Data
+		}
+		pw(ev.EventType, ev.Message, data)
+	}
+	return nil
+}
+
+// CompleteRemoteRun records the outcome a remote worker reports and
+// releases its lease.
+func (m *jobsV2Manager) CompleteRemoteRun(runID string, req jobsV2CompleteRequest) error {
+	var runErr error
+	switch req.Status {
+	case jobsV2RunSucceeded:
+	case jobsV2RunFailed:
+		runErr = errors.New("run failed on remote worker")
+		if msg := strings.TrimSpace(req.Error); msg != "" {
+			runErr = errors.New(msg)
+		}
+	case jobsV2RunTimedOut:
+		runErr = context.DeadlineExceeded
+	case jobsV2RunCancelled:
+		runErr = context.Canceled
+	default:
+		return errJobsV2CompletionStatus
+	}
+	run, err := m.leaseHolder(runID, req.WorkerID)
+	if err != nil {
+		return err
+	}
+	if err := m.finishRun(runID, req.Status, req.Result.runResult(), runErr, run.Attempt); err != nil {
+		return err
+	}
+	m.releaseLease(runID)
+	return nil
+}
+
+func (m *jobsV2Manager) releaseLease(runID string) {
+	_, _ = m.db.Exec(`DELETE FROM job_run_leases_v2 WHERE run_id = ?`, runID)
+}
+
+func (m *jobsV2Manager) hasLease(runID string) bool {
+	var one int
+	return m.db.QueryRow(`SELECT 1 FROM job_run_leases_v2 WHERE run_id = ?`, runID).Scan(&one) == nil
+}
+
+// expireWorkerLeases fails runs whose remote worker stopped heartbeating.
+// finishRun re-queues them as worker_lost.
+func (m *jobsV2Manager) expireWorkerLeases(now time.Time) error {
+	rows, err := m.db.Query(`SELECT run_id, worker_id FROM job_run_leases_v2 WHERE expires_at <= ?`, now.UTC())
+	if err != nil {
+		return err
+	}
+	type expired struct{ runID, workerID string }
+	var leases []expired
+	for rows.Next() {
+		var l expired
+		if err := rows.Scan(&l.runID, &l.workerID); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		leases = append(leases, l)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for _, l := range leases {
+		run, err := m.GetRun(l.runID)
+		if err != nil {
+			m.releaseLease(l.runID)
+			continue
+		}
+		if run.Status == jobsV2RunRunning || run.Status == jobsV2RunCancelRequested {
+			_ = m.addRunEvent(run.ID, "lease_expired", "remote worker stopped heartbeating", map[string]any{"worker_id": l.workerID})
+			result := jobsV2RunResult{
+				Response:     run.Response,
+				SessionID:    run.SessionID,
+				ExitReason:   exitReasonWorkerLost,
+				TurnCount:    run.TurnCount,
+				InputTokens:  run.InputTokens,
+				OutputTokens: run.OutputTokens,
+			}
+			if err := m.finishRun(run.ID, jobsV2RunFailed, result, fmt.Errorf("worker %s lease expired", l.workerID), run.Attempt); err != nil {
+				return err
+			}
+		}
+		m.releaseLease(l.runID)
+	}
+	return nil
+}
+
+// nextLeaseExpiry returns when the earliest remote lease expires.
+func (m *jobsV2Manager) nextLeaseExpiry() (time.Time, bool, error) {
+	var next sql.NullTime
+	err := m.db.QueryRow(`SELECT MIN(expires_at) FROM job_run_leases_v2`).Scan(&next)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return next.Time, next.Valid, nil
+}
+
+// ListWorkers returns this process's own workers followed by remote workers
+// seen within the retention window.
+func (m *jobsV2Manager) ListWorkers() ([]jobsV2Worker, error) {
+	leases := make(map[string][]jobsV2WorkerLease)
+	rows, err := m.db.Query(`SELECT l.worker_id, l.run_id, r.job_id, l.expires_at FROM job_run_leases_v2 l JOIN job_runs_v2 r ON r.id = l.run_id ORDER BY l.expires_at`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var workerID string
+		var lease jobsV2WorkerLease
+		if err := rows.Scan(&workerID, &lease.RunID, &lease.JobID, &lease.ExpiresAt); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		leases[workerID] = append(leases[workerID], lease)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	local := jobsV2Worker{ID: m.workerID, Name: "local", Labels: m.localWorkerLabels(), ActiveRuns: []jobsV2WorkerLease{}}
+	if local.Labels == nil {
+		local.Labels = []string{}
+	}
+	activeRows, err := m.db.Query(`SELECT id, job_id FROM job_runs_v2 WHERE worker_id = ? AND status IN (?, ?, ?)`, m.workerID, jobsV2RunClaimed, jobsV2RunRunning, jobsV2RunCancelRequested)
+	if err != nil {
+		return nil, err
+	}
+	for activeRows.Next() {
+		var lease jobsV2WorkerLease
+		if err := activeRows.Scan(&lease.RunID, &lease.JobID); err != nil {
+			_ = activeRows.Close()
+			return nil, err
+		}
+		local.ActiveRuns = append(local.ActiveRuns, lease)
+	}
+	if err := activeRows.Close(); err != nil {
+		return nil, err
+	}
+	workers := []jobsV2Worker{local}
+
+	remoteRows, err := m.db.Query(`SELECT id, name, labels, last_seen_at FROM job_workers_v2 ORDER BY last_seen_at DESC, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer remoteRows.Close()
+	for remoteRows.Next() {
+		var w jobsV2Worker
+		var name, labels sql.NullString
+		var lastSeen time.Time
+		if err := remoteRows.Scan(&w.ID, &name, &labels, &lastSeen); err != nil {
+			return nil, err
+		}
+		w.Name = name.String
+		w.Remote = true
+		w.LastSeenAt = &lastSeen
+		_ = json.Unmarshal([]byte(labels.String), &w.Labels)
+		if w.Labels == nil {
+			w.Labels = []string{}
+		}
+		w.ActiveRuns = leases[w.ID]
+		if w.ActiveRuns == nil {
+			w.ActiveRuns = []jobsV2WorkerLease{}
+		}
+		workers = append(workers, w)
+	}
+	return workers, remoteRows.Err()
+}
+
+func (m *jobsV2Manager) pruneWorkers(now time.Time) error {
+	cutoff := now.Add(-jobsV2WorkerRetentionDays * 24 * time.Hour)
+	_, err := m.db.Exec(`DELETE FROM job_workers_v2 WHERE last_seen_at < ? AND id NOT IN (SELECT worker_id FROM job_run_leases_v2)`, cutoff.UTC())
+	return err
+}
+
+// rejectAPIKeyWorker refuses worker endpoints to API keys. A worker receives
+// the full runner config of every run it claims, whoever queued it, so only
+// the server token may act as one.
+func rejectAPIKeyWorker(w http.ResponseWriter, r *http.Request) bool {
+	grant := serveAPIKeyFromContext(r.Context())
+	if grant == nil {
+		return false
+	}
+	writeOpenAIError(w, http.StatusForbidden, "permission_error", fmt.Sprintf("api key %q cannot act as a job worker; workers use the server token", grant.key.Name))
+	return true
+}
+
+func (s *serveServer) handleWorkersV2(w http.ResponseWriter, r *http.Request) {
+	if s.jobsV2 == nil {
+		http.NotFound(w, r)
+		return
+	}
+	if rejectAPIKeyWorker(w, r) {
+		return
+	}
+	switch strings.Trim(strings.TrimPrefix(r.URL.Path, "/v2/workers"), "/") {
+	case "":
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		workers, err := s.jobsV2.ListWorkers()
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": workers})
+	case "claim":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+		var req jobsV2ClaimRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid json")
+			return
+		}
+		req.WorkerID = strings.TrimSpace(req.WorkerID)
+		if !jobsV2WorkerIDPattern.MatchString(req.WorkerID) || req.WorkerID == s.jobsV2.workerID {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "worker_id must be a unique identifier of letters, digits, '.', '_' or '-'")
+			return
+		}
+		labels, err := normalizeJobsV2WorkerLabels(req.Labels)
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		req.Labels = labels
+		wait := min(time.Duration(max(req.WaitSeconds, 0))*time.Second, jobsV2MaxClaimWait)
+		deadline := time.Now().Add(wait)
+		for {
+			claim, ok, err := s.jobsV2.ClaimRunForWorker(req)
+			if err != nil {
+				writeOpenAIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+				return
+			}
+			if ok {
+				writeJSON(w, http.StatusOK, claim)
+				return
+			}
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			if !s.jobsV2.WaitForRemoteWork(r.Context(), min(remaining, jobsV2ClaimPollDelay)) && r.Context().Err() != nil {
+				return
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleRunV2WorkerAction serves the heartbeat, progress and complete calls a
+// remote worker makes on a run it holds.
+func (s *serveServer) handleRunV2WorkerAction(w http.ResponseWriter, r *http.Request, runID, action string) {
+	if rejectAPIKeyWorker(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+	var resp any = map[string]any{"ok": true}
+	var err error
+	switch action {
+	case "heartbeat":
+		var req jobsV2HeartbeatRequest
+		if !decodeJobsV2WorkerRequest(w, r, &req) {
+			return
+		}
+		resp, err = s.jobsV2.RenewLease(runID, req)
+	case "progress":
+		var req jobsV2ProgressRequest
+		if !decodeJobsV2WorkerRequest(w, r, &req) {
+			return
+		}
+		err = s.jobsV2.RecordRemoteProgress(runID, req)
+	case "complete":
+		var req jobsV2CompleteRequest
+		if !decodeJobsV2WorkerRequest(w, r, &req) {
+			return
+		}
+		err = s.jobsV2.CompleteRemoteRun(runID, req)
+	}
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusOK, resp)
+	case errors.Is(err, errJobsV2CompletionStatus):
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+	case errors.Is(err, errJobsV2LeaseLost):
+		writeOpenAIError(w, http.StatusConflict, "invalid_request_error", err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "run not found")
+	default:
+		writeOpenAIError(w, http.StatusInternalServerError, "internal_error", err.Error())
+	}
+}
+
+func decodeJobsV2WorkerRequest(w http.ResponseWriter, r *http.Request, out any) bool {
+	if err := json.NewDecoder(r.Body).Decode(out); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid json")
+		return false
+	}
+	return true
+}
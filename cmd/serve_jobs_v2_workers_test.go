@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobsV2WorkerLabelRouting(t *testing.T) {
+	required, err := jobsV2RequiredWorkerLabels(json.RawMessage(`{"worker_labels":"gpu, region=eu"}`))
+	if err != nil || strings.Join(required, ",") != "gpu,region=eu" {
+		t.Fatalf("required = %v, %v", required, err)
+	}
+	if _, err := jobsV2RequiredWorkerLabels(json.RawMessage(`{"worker_labels":["bad label"]}`)); err == nil {
+		t.Fatal("expected invalid label error")
+	}
+	if got, _ := jobsV2RequiredWorkerLabels(json.RawMessage(`{"team":"infra"}`)); got != nil {
+		t.Fatalf("unrouted labels = %v", got)
+	}
+
+	for _, tc := range []struct {
+		required, have []string
+		remote, want   bool
+	}{
+		{nil, nil, false, true},
+		{nil, []string{"gpu"}, true, false},
+		{[]string{"gpu"}, []string{"gpu", "region=eu"}, true, true},
+		{[]string{"gpu", "region=us"}, []string{"gpu", "region=eu"}, true, false},
+		{[]string{"gpu"}, nil, false, false},
+	} {
+		if got := jobsV2WorkerCanRun(tc.required, tc.have, tc.remote); got != tc.want {
+			t.Errorf("jobsV2WorkerCanRun(%v, %v, %v) = %v, want %v", tc.required, tc.have, tc.remote, got, tc.want)
+		}
+	}
+}
+
+func newJobsV2WorkersTestServer(t *testing.T) (*jobsV2Manager, *httptest.Server) {
+	t.Helper()
+	mgr, err := newJobsV2Manager(":memory:", 0, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager failed: %v", err)
+	}
+	t.Cleanup(func() { _ = mgr.Close() })
+	s := &serveServer{jobsV2: mgr}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/workers", s.handleWorkersV2)
+	mux.HandleFunc("/v2/workers/", s.handleWorkersV2)
+	mux.HandleFunc("/v2/runs/", s.handleRunV2ByID)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return mgr, srv
+}
+
+func createRoutedJobsV2Job(t *testing.T, mgr *jobsV2Manager, name, labels string) jobsV2Job {
+	t.Helper()
+	job, err := mgr.CreateJob(jobsV2Job{
+		Name:          name,
+		Enabled:       true,
+		RunnerType:    jobsV2RunnerProgram,
+		RunnerConfig:  json.RawMessage(`{"command":"echo","args":["remote"]}`),
+		TriggerType:   jobsV2TriggerManual,
+		TriggerConfig: json.RawMessage(`{}`),
+		Labels:        json.RawMessage(labels),
+	})
+	if err != nil {
+		t.Fatalf("CreateJob failed: %v", err)
+	}
+	return job
+}
+
+func postJobsV2Worker(t *testing.T, url string, body any) (int, []byte) {
+	t.Helper()
+	encoded, _ := json.Marshal(body)
+	resp, err := http.Post(url, "application/json", bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("POST %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, respBody
+}
+
+func TestJobsV2RemoteWorkerLeaseLifecycle(t *testing.T) {
+	mgr, srv := newJobsV2WorkersTestServer(t)
+
+	if _, err := mgr.CreateJob(jobsV2Job{Name: "routed", Enabled: true, RunnerType: jobsV2RunnerProgram, RunnerConfig: json.RawMessage(`{"command":"true"}`),
+		TriggerType: jobsV2TriggerManual, TriggerConfig: json.RawMessage(`{}`), Labels: json.RawMessage(`{"worker_labels":["bad label"]}`)}); err == nil {
+		t.Fatal("expected CreateJob to reject invalid worker_labels")
+	}
+	local := createRoutedJobsV2Job(t, mgr, "local", `{}`)
+	gpu := createRoutedJobsV2Job(t, mgr, "gpu", `{"worker_labels":["gpu"]}`)
+	if _, err := mgr.TriggerJob(local.ID); err != nil {
+		t.Fatalf("TriggerJob local: %v", err)
+	}
+	gpuRun, err := mgr.TriggerJob(gpu.ID)
+	if err != nil {
+		t.Fatalf("TriggerJob gpu: %v", err)
+	}
+
+	// The server's own worker has no labels, so it only sees the local job.
+	claimedLocal, ok, err := mgr.claimNextRun()
+	if err != nil || !ok || claimedLocal.JobID != local.ID {
+		t.Fatalf("local claim = %+v, %v, %v", claimedLocal, ok, err)
+	}
+	if _, ok, _ := mgr.claimNextRun(); ok {
+		t.Fatal("local worker claimed a run routed to gpu workers")
+	}
+
+	status, _ := postJobsV2Worker(t, srv.URL+"/v2/workers/claim", jobsV2ClaimRequest{WorkerID: "rworker_cpu", Labels: []string{"cpu"}})
+	if status != http.StatusNoContent {
+		t.Fatalf("cpu claim status = %d, want 204", status)
+	}
+	status, body := postJobsV2Worker(t, srv.URL+"/v2/workers/claim", jobsV2ClaimRequest{WorkerID: "rworker_gpu", Name: "gpu-box", Labels: []string{"gpu"}, LeaseSeconds: 30})
+	if status != http.StatusOK {
+		t.Fatalf("gpu claim status = %d: %s", status, body)
+	}
+	var claim jobsV2ClaimResponse
+	if err := json.Unmarshal(body, &claim); err != nil {
+		t.Fatalf("decode claim: %v", err)
+	}
+	if claim.Run.ID != gpuRun.ID || claim.Run.Status != jobsV2RunRunning || claim.Job.ID != gpu.ID || claim.LeaseSeconds != 30 {
+		t.Fatalf("claim = %+v", claim)
+	}
+
+	runURL := srv.URL + "/v2/runs/" + gpuRun.ID
+	if status, _ := postJobsV2Worker(t, runURL+"/heartbeat", jobsV2HeartbeatRequest{WorkerID: "rworker_other"}); status != http.StatusConflict {
+		t.Fatalf("foreign heartbeat status = %d, want 409", status)
+	}
+	status, body = postJobsV2Worker(t, runURL+"/heartbeat", jobsV2HeartbeatRequest{WorkerID: "rworker_gpu"})
+	var hb jobsV2HeartbeatResponse
+	if status != http.StatusOK || json.Unmarshal(body, &hb) != nil || hb.Cancel {
+		t.Fatalf("heartbeat = %d %s", status, body)
+	}
+	status, body = postJobsV2Worker(t, runURL+"/progress", jobsV2ProgressRequest{WorkerID: "rworker_gpu", Events: []jobsV2ProgressEvent{{EventType: "stdout", Message: "remote\n"}}})
+	if status != http.StatusOK {
+		t.Fatalf("progress status = %d: %s", status, body)
+	}
+
+	workers, err := mgr.ListWorkers()
+	if err != nil || len(workers) != 3 || workers[0].Remote || len(workers[0].ActiveRuns) != 1 {
+		t.Fatalf("workers = %+v, %v", workers, err)
+	}
+	for _, worker := range workers[1:] {
+		if want := map[string]int{"rworker_gpu": 1, "rworker_cpu": 0}[worker.ID]; !worker.Remote || len(worker.ActiveRuns) != want {
+			t.Fatalf("remote worker %s = %+v", worker.ID, worker)
+		}
+	}
+
+	if status, _ := postJobsV2Worker(t, runURL+"/complete", jobsV2CompleteRequest{WorkerID: "rworker_gpu", Status: jobsV2RunQueued}); status != http.StatusBadRequest {
+		t.Fatalf("invalid complete status = %d, want 400", status)
+	}
+	status, body = postJobsV2Worker(t, runURL+"/complete", jobsV2CompleteRequest{WorkerID: "rworker_gpu", Status: jobsV2RunSucceeded, Result: jobsV2RemoteResult{Stdout: "remote\n"}})
+	if status != http.StatusOK {
+		t.Fatalf("complete status = %d: %s", status, body)
+	}
+	done, err := mgr.GetRun(gpuRun.ID)
+	if err != nil || done.Status != jobsV2RunSucceeded || done.Stdout != "remote\n" {
+		t.Fatalf("completed run = %+v, %v", done, err)
+	}
+	if mgr.hasLease(gpuRun.ID) {
+		t.Fatal("lease not released after completion")
+	}
+	if status, _ := postJobsV2Worker(t, runURL+"/heartbeat", jobsV2HeartbeatRequest{WorkerID: "rworker_gpu"}); status != http.StatusConflict {
+		t.Fatalf("heartbeat after completion status = %d, want 409", status)
+	}
+	events, _, err := mgr.ListRunEvents(gpuRun.ID, 0, 100, 0)
+	if err != nil {
+		t.Fatalf("ListRunEvents: %v", err)
+	}
+	var types []string
+	for _, ev := range events {
+		types = append(types, ev.EventType)
+	}
+	if got := strings.Join(types, ","); !strings.Contains(got, "claimed") || !strings.Contains(got, "stdout") {
+		t.Fatalf("event types = %s", got)
+	}
+}
+
+func TestJobsV2ExpiredLeaseRequeuesRun(t *testing.T) {
+	mgr, _ := newJobsV2WorkersTestServer(t)
+	job := createRoutedJobsV2Job(t, mgr, "flaky-host", `{"worker_labels":"gpu"}`)
+	run, err := mgr.TriggerJob(job.ID)
+	if err != nil {
+		t.Fatalf("TriggerJob: %v", err)
+	}
+	if _, ok, err := mgr.ClaimRunForWorker(jobsV2ClaimRequest{WorkerID: "rworker_a", Labels: []string{"gpu"}}); !ok || err != nil {
+		t.Fatalf("claim = %v, %v", ok, err)
+	}
+
+	if err := mgr.expireWorkerLeases(time.Now().Add(time.Minute + time.Second)); err != nil {
+		t.Fatalf("expireWorkerLeases: %v", err)
+	}
+	lost, err := mgr.GetRun(run.ID)
+	if err != nil || lost.Status != jobsV2RunFailed || lost.ExitReason != exitReasonWorkerLost {
+		t.Fatalf("lost run = %+v, %v", lost, err)
+	}
+	if mgr.hasLease(run.ID) {
+		t.Fatal("expired lease not released")
+	}
+	if err := mgr.CompleteRemoteRun(run.ID, jobsV2CompleteRequest{WorkerID: "rworker_a", Status: jobsV2RunSucceeded}); err == nil {
+		t.Fatal("late completion from lost worker accepted")
+	}
+
+	// Without a retry policy the run is still re-queued for another worker.
+	retry, ok, err := mgr.ClaimRunForWorker(jobsV2ClaimRequest{WorkerID: "rworker_b", Labels: []string{"gpu"}})
+	if err != nil || !ok || retry.Run.Attempt != 2 || retry.Run.ID == run.ID {
+		t.Fatalf("retry claim = %+v, %v, %v", retry.Run, ok, err)
+	}
+}
+
+func TestJobsRemoteWorkerRunsClaimedJob(t *testing.T) {
+	mgr, srv := newJobsV2WorkersTestServer(t)
+	job := createRoutedJobsV2Job(t, mgr, "remote-echo", `{"worker_labels":["linux"]}`)
+	run, err := mgr.TriggerJob(job.ID)
+	if err != nil {
+		t.Fatalf("TriggerJob: %v", err)
+	}
+
+	client := &jobsClient{baseURL: srv.URL, http: &http.Client{Timeout: 5 * time.Second}}
+	worker := &jobsRemoteWorker{
+		client:      client,
+		claimClient: client.withTimeout(time.Minute),
+		id:          "rworker_test",
+		labels:      []string{"linux"},
+		lease:       jobsV2DefaultLeaseDuration,
+		runners:     map[jobsV2RunnerType]jobsV2Runner{jobsV2RunnerProgram: &jobsV2ProgramRunner{}},
+		logf:        log.New(io.Discard, "", 0).Printf,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		worker.Run(ctx, 1)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := mgr.GetRun(run.ID)
+		if err != nil {
+			t.Fatalf("GetRun: %v", err)
+		}
+		if got.Status == jobsV2RunSucceeded {
+			if got.WorkerID != "rworker_test" || strings.TrimSpace(got.Stdout) != "remote" {
+				t.Fatalf("run = %+v", got)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("run status = %s, want succeeded", got.Status)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
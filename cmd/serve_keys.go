@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/apikeys"
+	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/tools"
+	"github.com/samsaffron/term-llm/internal/usage"
+)
+
+// serveAPIKeyGrant is an authenticated named API key attached to a request
+// context. Requests authenticated with the server --token carry none and
+// are unrestricted.
+type serveAPIKeyGrant struct {
+	key         apikeys.Key
+	attribution usage.Attribution
+}
+
+type serveAPIKeyContextKey struct{}
+
+func withServeAPIKey(ctx context.Context, grant *serveAPIKeyGrant) context.Context {
+	if grant == nil {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, serveAPIKeyContextKey{}, grant)
+	return usage.WithAttribution(ctx, grant.attribution)
+}
+
+func serveAPIKeyFromContext(ctx context.Context) *serveAPIKeyGrant {
+	if ctx == nil {
+		return nil
+	}
+	grant, _ := ctx.Value(serveAPIKeyContextKey{}).(*serveAPIKeyGrant)
+	return grant
+}
+
+// serveAPIKeys enforces named API keys for serve: scope checks, per-key
+// request rate limits and token/spend quotas.
+type serveAPIKeys struct {
+	store   *apikeys.Store
+	pricing *usage.PricingFetcher
+	now     func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*hubTokenBucket
+}
+
+func newServeAPIKeys(store *apikeys.Store) *serveAPIKeys {
+	return &serveAPIKeys{
+		store:   store,
+		pricing: usage.NewPricingFetcher(),
+		now:     time.Now,
+		buckets: make(map[string]*hubTokenBucket),
+	}
+}
+
+// allowRequest applies the key's requests-per-minute limit.
+func (k *serveAPIKeys) allowRequest(key apikeys.Key) bool {
+	rpm := key.Quota.RequestsPerMinute
+	if rpm <= 0 {
+		return true
+	}
+	now := k.now()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	bucket, ok := k.buckets[key.ID]
+	if !ok || bucket.burst != float64(rpm) {
+		b := newHubTokenBucket(float64(rpm), float64(rpm), now)
+		bucket = &b
+		k.buckets[key.ID] = bucket
+	}
+	return bucket.allow(now)
+}
+
+// recorder returns the usage callback for a key. Cost is computed from
+// local pricing data so spend quotas never wait on the network.
+func (k *serveAPIKeys) recorder(keyID string) func(usage.LogEntry) {
+	return func(entry usage.LogEntry) {
+		u := apikeys.Usage{
+			InputTokens:  int64(entry.InputTokens + entry.CacheReadTokens + entry.CacheWriteTokens),
+			OutputTokens: int64(entry.OutputTokens),
+			CostUSD:      entry.CostUSD,
+		}
+		if u.CostUSD == 0 && entry.TrackedExternallyBy == "" {
+			cost, err := k.pricing.CalculateCostLocal(usage.UsageEntry{
+				Model:            entry.Model,
+				InputTokens:      entry.InputTokens,
+				OutputTokens:     entry.OutputTokens,
+				CacheReadTokens:  entry.CacheReadTokens,
+				CacheWriteTokens: entry.CacheWriteTokens,
+			})
+			if err == nil {
+				u.CostUSD = cost
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := k.store.RecordUsage(ctx, keyID, u); err != nil {
+			log.Printf("[serve] api key %s: %v", keyID, err)
+		}
+	}
+}
+
+// authenticateAPIKey checks a presented key against the store and its
+// endpoint scope, rate limit and quota. On success it returns the request
+// with the key attached; otherwise it writes the error response.
+func (s *serveServer) authenticateAPIKey(w http.ResponseWriter, r *http.Request, secret string) (*http.Request, bool) {
+	key, err := s.apiKeys.store.Authenticate(r.Context(), secret)
+	switch {
+	case errors.Is(err, apikeys.ErrRevoked):
+		writeOpenAIError(w, http.StatusUnauthorized, "invalid_api_key", "api key has been revoked")
+		return nil, false
+	case err != nil:
+		if !errors.Is(err, apikeys.ErrNotFound) {
+			log.Printf("[serve] api key lookup failed: %v", err)
+		}
+		writeOpenAIError(w, http.StatusUnauthorized, "invalid_api_key", "invalid authentication credentials")
+		return nil, false
+	}
+	if !key.Scopes.AllowsEndpoint(r.URL.Path) {
+		writeOpenAIError(w, http.StatusForbidden, "permission_error", fmt.Sprintf("api key %q is not allowed to use %s", key.Name, r.URL.Path))
+		return nil, false
+	}
+	if !s.apiKeys.allowRequest(key) {
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, 60/key.Quota.RequestsPerMinute)))
+		writeOpenAIError(w, http.StatusTooManyRequests, "rate_limit_exceeded", fmt.Sprintf("api key %q is limited to %d requests per minute", key.Name, key.Quota.RequestsPerMinute))
+		return nil, false
+	}
+	if key.Quota.Tokens > 0 || key.Quota.SpendUSD > 0 {
+		used, err := s.apiKeys.store.QuotaUsage(r.Context(), key)
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, "server_error", "failed to read api key usage")
+			return nil, false
+		}
+		if msg := apikeys.ExceededQuota(key.Quota, used); msg != "" {
+			writeOpenAIError(w, http.StatusTooManyRequests, "insufficient_quota", fmt.Sprintf("api key %q: %s", key.Name, msg))
+			return nil, false
+		}
+	}
+	if err := s.apiKeys.store.RecordUsage(r.Context(), key.ID, apikeys.Usage{Requests: 1}); err != nil {
+		log.Printf("[serve] api key %s: %v", key.ID, err)
+	}
+
+	grant := &serveAPIKeyGrant{
+		key:         key,
+		attribution: usage.Attribution{APIKey: key.Name, Record: s.apiKeys.recorder(key.ID)},
+	}
+	return r.WithContext(withServeAPIKey(r.Context(), grant)), true
+}
+
+// checkAPIKeyRun checks an agent run against the request key's agent,
+// provider and approval-mode scopes.
+func (s *serveServer) checkAPIKeyRun(ctx context.Context, provider string) error {
+	grant := serveAPIKeyFromContext(ctx)
+	if grant == nil {
+		return nil
+	}
+	scopes := grant.key.Scopes
+	if !scopes.AllowsAgent(s.cfg.agentName) {
+		return fmt.Errorf("api key %q is not allowed to use agent %q", grant.key.Name, s.cfg.agentName)
+	}
+	if !scopes.AllowsApprovalMode(s.cfg.approvalMode) {
+		return fmt.Errorf("api key %q is not allowed to run with approval mode %q", grant.key.Name, s.cfg.approvalMode)
+	}
+	return checkAPIKeyProvider(ctx, provider)
+}
+
+// scopeAPIKeyJob checks a job's runner against the request key's scopes and
+// returns the runner config to store. Keys cannot configure program jobs,
+// which run arbitrary commands outside every scope. LLM jobs must stay within
+// the key's agent, provider, tool and approval-mode scopes; their tools are
+// narrowed to the tool scope and the key is stamped on the config so run
+// usage counts against its quotas. Jobs without an agent run the server's
+// agent.
+func (s *serveServer) scopeAPIKeyJob(ctx context.Context, runnerType jobsV2RunnerType, runnerConfig json.RawMessage) (json.RawMessage, error) {
+	grant := serveAPIKeyFromContext(ctx)
+	if grant == nil {
+		return runnerConfig, nil
+	}
+	switch runnerType {
+	case jobsV2RunnerLLM:
+	case jobsV2RunnerBatch:
+		// /v1/batches checks the providers a batch uses; raw batch jobs
+		// would skip that.
+		return nil, fmt.Errorf("api keys create batches through /v1/batches")
+	default:
+		return nil, fmt.Errorf("api key %q is not allowed to configure %s jobs", grant.key.Name, runnerType)
+	}
+	var llmCfg jobsV2LLMConfig
+	_ = json.Unmarshal(runnerConfig, &llmCfg)
+	scopes := grant.key.Scopes
+	agentName := firstNonEmpty(strings.TrimSpace(llmCfg.AgentName), s.cfg.agentName)
+	if !scopes.AllowsAgent(agentName) {
+		return nil, fmt.Errorf("api key %q is not allowed to use agent %q", grant.key.Name, agentName)
+	}
+	if !scopes.AllowsApprovalMode(s.cfg.approvalMode) {
+		return nil, fmt.Errorf("api key %q is not allowed to run with approval mode %q", grant.key.Name, s.cfg.approvalMode)
+	}
+	// The provider a job falls back to depends on its agent and the server
+	// flags, so restricted keys must name one.
+	provider := strings.TrimSpace(llmCfg.Provider)
+	if len(scopes.Providers) > 0 && provider == "" {
+		return nil, fmt.Errorf("api key %q must set runner_config.provider to one of: %s", grant.key.Name, strings.Join(scopes.Providers, ", "))
+	}
+	if err := checkAPIKeyProvider(ctx, provider); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(stringOrEmptyRaw(runnerConfig, "{}")), &fields); err != nil {
+		return nil, fmt.Errorf("invalid llm runner config: %w", err)
+	}
+	if len(scopes.Tools) > 0 {
+		allowed := append([]string(nil), scopes.Tools...)
+		if requested := tools.ParseToolsFlag(llmCfg.Tools); len(requested) > 0 {
+			allowed = intersectAllowedToolNames(requested, allowed)
+		}
+		if len(allowed) == 0 {
+			return nil, fmt.Errorf("api key %q is not allowed to use tools %q", grant.key.Name, llmCfg.Tools)
+		}
+		fields["tools"], _ = json.Marshal(strings.Join(allowed, ","))
+	}
+	fields["api_key_id"], _ = json.Marshal(grant.key.ID)
+	fields["api_key_name"], _ = json.Marshal(grant.key.Name)
+	return json.Marshal(fields)
+}
+
+// checkAPIKeyJobAccess checks an existing job against the request key's
+// scopes before the key triggers, pauses, resumes or deletes it or reads its
+// deliveries. Unlike on create, tools are not narrowed: a job that would run
+// tools outside the key's tool scope is refused.
+func (s *serveServer) checkAPIKeyJobAccess(ctx context.Context, job jobsV2Job) error {
+	grant := serveAPIKeyFromContext(ctx)
+	if grant == nil {
+		return nil
+	}
+	scoped, err := s.scopeAPIKeyJob(ctx, job.RunnerType, job.RunnerConfig)
+	if err != nil {
+		return err
+	}
+	var stored, narrowed jobsV2LLMConfig
+	_ = json.Unmarshal(job.RunnerConfig, &stored)
+	_ = json.Unmarshal(scoped, &narrowed)
+	have, want := tools.ParseToolsFlag(stored.Tools), tools.ParseToolsFlag(narrowed.Tools)
+	slices.Sort(have)
+	slices.Sort(want)
+	if !slices.Equal(have, want) {
+		return fmt.Errorf("api key %q is not allowed to use the tools of job %s", grant.key.Name, job.ID)
+	}
+	return nil
+}
+
+// checkAPIKeyProvider checks a provider against the request key's provider
+// scope.
+func checkAPIKeyProvider(ctx context.Context, provider string) error {
+	grant := serveAPIKeyFromContext(ctx)
+	if grant == nil || grant.key.Scopes.AllowsProvider(provider) {
+		return nil
+	}
+	return fmt.Errorf("api key %q is not allowed to use provider %q", grant.key.Name, provider)
+}
+
+// applyServeAPIKeyToolScope narrows a request's executable tools to the
+// key's tool scope.
+func applyServeAPIKeyToolScope(ctx context.Context, req *llm.Request) {
+	grant := serveAPIKeyFromContext(ctx)
+	if grant == nil || len(grant.key.Scopes.Tools) == 0 {
+		return
+	}
+	allowed := append([]string(nil), grant.key.Scopes.Tools...)
+	if req.AllowedToolsPresent {
+		allowed = intersectAllowedToolNames(allowed, req.AllowedTools)
+	}
+	req.AllowedTools = allowed
+	req.AllowedToolsPresent = true
+}
+
+// recordAPIKeyTokens attributes usage that does not pass through an LLM
+// engine, such as embeddings, to the request key.
+func recordAPIKeyTokens(ctx context.Context, provider, model string, inputTokens int64) {
+	grant := serveAPIKeyFromContext(ctx)
+	if grant == nil || grant.attribution.Record == nil || inputTokens <= 0 {
+		return
+	}
+	grant.attribution.Record(usage.LogEntry{
+		Timestamp:   time.Now(),
+		Provider:    provider,
+		Model:       strings.TrimPrefix(model, "models/"),
+		InputTokens: int(inputTokens),
+		APIKey:      grant.key.Name,
+	})
+}
@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/apikeys"
+	"github.com/samsaffron/term-llm/internal/session"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveKeysDBFlag      string
+	serveKeysEndpoints   []string
+	serveKeysAgents      []string
+	serveKeysProviders   []string
+	serveKeysTools       []string
+	serveKeysApprovals   []string
+	serveKeysRPM         int
+	serveKeysTokenQuota  int64
+	serveKeysSpendQuota  float64
+	serveKeysQuotaPeriod string
+	serveKeysListAll     bool
+	serveKeysListJSON    bool
+)
+
+var serveKeysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage named API keys for serve",
+	Long: `Manage named API keys that authenticate to term-llm serve alongside the
+server --token.
+
+Each key can be scoped to endpoints, agents, providers, tools and approval
+modes, and limited by a request rate and a token or spend quota per day or
+month. Usage made with a key is attributed to its name in the usage logs
+(see 'term-llm usage --api-key <name>').
+
+Keys are stored hashed in <data-dir>/serve_keys.db; serve loads the store at
+startup when it exists. Changes take effect immediately on a running server.
+
+Examples:
+  term-llm serve keys create alice
+  term-llm serve keys create ci --endpoint /v1/chat/completions --provider anthropic --rpm 30 --spend-quota 5
+  term-llm serve keys create bot --endpoint '/v2/*' --agent reviewer --approval prompt --token-quota 2000000 --quota-period month
+  term-llm serve keys list
+  term-llm serve keys revoke ci`,
+}
+
+var serveKeysCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create an API key and print it once",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runServeKeysCreate,
+}
+
+var serveKeysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API keys with their scopes, quotas and current usage",
+	Args:  cobra.NoArgs,
+	RunE:  runServeKeysList,
+}
+
+var serveKeysRevokeCmd = &cobra.Command{
+	Use:   "revoke <id|name>",
+	Short: "Revoke an API key",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runServeKeysRevoke,
+}
+
+func init() {
+	serveCmd.AddCommand(serveKeysCmd)
+	serveKeysCmd.AddCommand(serveKeysCreateCmd, serveKeysListCmd, serveKeysRevokeCmd)
+
+	serveKeysCmd.PersistentFlags().StringVar(&serveKeysDBFlag, "db", "", "Key store path (default: <data-dir>/serve_keys.db)")
+
+	serveKeysCreateCmd.Flags().StringArrayVar(&serveKeysEndpoints, "endpoint", nil, "Allowed endpoint path below the base path, e.g. /v1/chat/completions or /v2/* (repeatable)")
+	serveKeysCreateCmd.Flags().StringArrayVar(&serveKeysAgents, "agent", nil, "Allowed agent name (repeatable)")
+	serveKeysCreateCmd.Flags().StringArrayVar(&serveKeysProviders, "provider", nil, "Allowed provider, or provider:model (repeatable)")
+	serveKeysCreateCmd.Flags().StringArrayVar(&serveKeysTools, "tool", nil, "Tool the key's runs may execute (repeatable)")
+	serveKeysCreateCmd.Flags().StringArrayVar(&serveKeysApprovals, "approval", nil, "Allowed server approval mode: prompt, auto or yolo (repeatable)")
+	serveKeysCreateCmd.Flags().IntVar(&serveKeysRPM, "rpm", 0, "Requests per minute (0 = unlimited)")
+	serveKeysCreateCmd.Flags().Int64Var(&serveKeysTokenQuota, "token-quota", 0, "Input plus output tokens per quota period (0 = unlimited)")
+	serveKeysCreateCmd.Flags().Float64Var(&serveKeysSpendQuota, "spend-quota", 0, "Spend in USD per quota period (0 = unlimited)")
+	serveKeysCreateCmd.Flags().StringVar(&serveKeysQuotaPeriod, "quota-period", apikeys.PeriodDay, "Quota period: day or month (UTC)")
+
+	serveKeysListCmd.Flags().BoolVar(&serveKeysListAll, "all", false, "Include revoked keys")
+	serveKeysListCmd.Flags().BoolVar(&serveKeysListJSON, "json", false, "Output as JSON")
+}
+
+// resolveServeKeysPath returns the key store path for an explicit flag value
+// or the default under the data directory.
+func resolveServeKeysPath(flagValue string) (string, error) {
+	if path := strings.TrimSpace(flagValue); path != "" {
+		return path, nil
+	}
+	dataDir, err := session.GetDataDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve data directory: %w", err)
+	}
+	return apikeys.DefaultPath(dataDir), nil
+}
+
+// openServeKeyStore opens the key store for serve. A missing store at the
+// default path means no keys were ever created, so it returns nil rather
+// than creating an empty database.
+func openServeKeyStore(flagValue string) (*apikeys.Store, error) {
+	path, err := resolveServeKeysPath(flagValue)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(flagValue) == "" {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+	}
+	store, err := apikeys.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open api key store: %w", err)
+	}
+	return store, nil
+}
+
+func openServeKeysCLIStore() (*apikeys.Store, error) {
+	path, err := resolveServeKeysPath(serveKeysDBFlag)
+	if err != nil {
+		return nil, err
+	}
+	return apikeys.Open(path)
+}
+
+func runServeKeysCreate(cmd *cobra.Command, args []string) error {
+	store, err := openServeKeysCLIStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	key, secret, err := store.Create(cmd.Context(), apikeys.CreateOptions{
+		Name: args[0],
+		Scopes: apikeys.Scopes{
+			Endpoints:     serveKeysEndpoints,
+			Agents:        serveKeysAgents,
+			Providers:     serveKeysProviders,
+			Tools:         serveKeysTools,
+			ApprovalModes: serveKeysApprovals,
+		},
+		Quota: apikeys.Quota{
+			RequestsPerMinute: serveKeysRPM,
+			Tokens:            serveKeysTokenQuota,
+			SpendUSD:          serveKeysSpendQuota,
+			Period:            serveKeysQuotaPeriod,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Created API key %s (%s)\n", key.Name, key.ID)
+	fmt.Fprintf(out, "scopes: %s\n", formatServeKeyScopes(key.Scopes))
+	fmt.Fprintf(out, "quota:  %s\n", formatServeKeyQuota(key.Quota))
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, secret)
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "This key is shown once. Send it as: Authorization: Bearer <key>")
+	return nil
+}
+
+// serveKeyListEntry is one key in 'serve keys list --json'.
+type serveKeyListEntry struct {
+	apikeys.Key
+	PeriodUsage apikeys.Usage `json:"period_usage"`
+}
+
+func runServeKeysList(cmd *cobra.Command, args []string) error {
+	store, err := openServeKeysCLIStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	keys, err := store.List(cmd.Context(), serveKeysListAll)
+	if err != nil {
+		return err
+	}
+	entries := make([]serveKeyListEntry, 0, len(keys))
+	for _, key := range keys {
+		used, err := store.QuotaUsage(cmd.Context(), key)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, serveKeyListEntry{Key: key, PeriodUsage: used})
+	}
+
+	out := cmd.OutOrStdout()
+	if serveKeysListJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(out, "No API keys. Create one with: term-llm serve keys create <name>")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPREFIX\tSCOPES\tQUOTA\tUSAGE\tLAST USED")
+	for _, e := range entries {
+		name := e.Name
+		if e.Revoked() {
+			name += " (revoked)"
+		}
+		lastUsed := "never"
+		if !e.LastUsedAt.IsZero() {
+			lastUsed = e.LastUsedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s…\t%s\t%s\t%s\t%s\n",
+			e.ID, name, e.Prefix, formatServeKeyScopes(e.Scopes), formatServeKeyQuota(e.Quota), formatServeKeyUsage(e.Quota, e.PeriodUsage), lastUsed)
+	}
+	return w.Flush()
+}
+
+func runServeKeysRevoke(cmd *cobra.Command, args []string) error {
+	store, err := openServeKeysCLIStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	key, err := store.Revoke(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Revoked API key %s (%s)\n", key.Name, key.ID)
+	return nil
+}
+
+func formatServeKeyScopes(scopes apikeys.Scopes) string {
+	var parts []string
+	add := func(label string, values []string) {
+		if len(values) > 0 {
+			parts = append(parts, label+"="+strings.Join(values, ","))
+		}
+	}
+	add("endpoints", scopes.Endpoints)
+	add("agents", scopes.Agents)
+	add("providers", scopes.Providers)
+	add("tools", scopes.Tools)
+	add("approval", scopes.ApprovalModes)
+	if len(parts) == 0 {
+		return "all"
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatServeKeyQuota(quota apikeys.Quota) string {
+	var parts []string
+	if quota.RequestsPerMinute > 0 {
+		parts = append(parts, fmt.Sprintf("%d rpm", quota.RequestsPerMinute))
+	}
+	if quota.Tokens > 0 {
+		parts = append(parts, fmt.Sprintf("%s tokens/%s", formatTokens(int(quota.Tokens)), quota.Period))
+	}
+	if quota.SpendUSD > 0 {
+		parts = append(parts, fmt.Sprintf("$%.2f/%s", quota.SpendUSD, quota.Period))
+	}
+	if len(parts) == 0 {
+		return "unlimited"
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatServeKeyUsage(quota apikeys.Quota, used apikeys.Usage) string {
+	period := firstNonEmpty(quota.Period, apikeys.PeriodDay)
+	return fmt.Sprintf("%d req, %s tokens, $%.2f this %s", used.Requests, formatTokens(int(used.TotalTokens())), used.CostUSD, period)
+}
@@ -0,0 +1,369 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/samsaffron/term-llm/internal/apikeys"
+	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+func newTestServeAPIKeys(t *testing.T) *serveAPIKeys {
+	t.Helper()
+	store, err := apikeys.Open(filepath.Join(t.TempDir(), "keys.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return newServeAPIKeys(store)
+}
+
+func TestServeAuthMiddlewareAPIKeys(t *testing.T) {
+	keys := newTestServeAPIKeys(t)
+	ctx := context.Background()
+	create := func(opts apikeys.CreateOptions) (apikeys.Key, string) {
+		t.Helper()
+		key, secret, err := keys.store.Create(ctx, opts)
+		if err != nil {
+			t.Fatalf("Create(%s): %v", opts.Name, err)
+		}
+		return key, secret
+	}
+	_, open := create(apikeys.CreateOptions{Name: "alice"})
+	_, scoped := create(apikeys.CreateOptions{Name: "ci", Scopes: apikeys.Scopes{Endpoints: []string{"/v1/chat/completions"}}})
+	_, limited := create(apikeys.CreateOptions{Name: "bot", Quota: apikeys.Quota{RequestsPerMinute: 1}})
+	quotaKey, quota := create(apikeys.CreateOptions{Name: "capped", Quota: apikeys.Quota{Tokens: 100}})
+	_, revoked := create(apikeys.CreateOptions{Name: "old"})
+	if _, err := keys.store.Revoke(ctx, "old"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if err := keys.store.RecordUsage(ctx, quotaKey.ID, apikeys.Usage{InputTokens: 80, OutputTokens: 20}); err != nil {
+		t.Fatalf("RecordUsage: %v", err)
+	}
+
+	srv := &serveServer{cfg: serveServerConfig{requireAuth: true, token: "secret"}, apiKeys: keys}
+	var gotKey string
+	h := srv.auth(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = ""
+		if grant := serveAPIKeyFromContext(r.Context()); grant != nil {
+			gotKey = grant.key.Name
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	do := func(path, token string) (int, string) {
+		gotKey = ""
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		var body struct {
+			Error struct {
+				Type string `json:"type"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr.Code, body.Error.Type
+	}
+
+	if code, _ := do("/v1/responses", "secret"); code != http.StatusNoContent || gotKey != "" {
+		t.Fatalf("server token: status = %d key = %q, want 204 without key", code, gotKey)
+	}
+	if code, _ := do("/v1/responses", open); code != http.StatusNoContent || gotKey != "alice" {
+		t.Fatalf("open key: status = %d key = %q, want 204 alice", code, gotKey)
+	}
+	if code, typ := do("/v1/responses", apikeys.KeyPrefix+"unknown"); code != http.StatusUnauthorized || typ != "invalid_api_key" {
+		t.Fatalf("unknown key: status = %d type = %q, want 401 invalid_api_key", code, typ)
+	}
+	if code, _ := do("/v1/responses", revoked); code != http.StatusUnauthorized {
+		t.Fatalf("revoked key: status = %d, want 401", code)
+	}
+	if code, typ := do("/v1/responses", scoped); code != http.StatusForbidden || typ != "permission_error" {
+		t.Fatalf("out-of-scope endpoint: status = %d type = %q, want 403 permission_error", code, typ)
+	}
+	if code, _ := do("/v1/chat/completions", scoped); code != http.StatusNoContent || gotKey != "ci" {
+		t.Fatalf("in-scope endpoint: status = %d key = %q, want 204 ci", code, gotKey)
+	}
+	if code, _ := do("/v1/responses", limited); code != http.StatusNoContent {
+		t.Fatalf("first limited request: status = %d, want 204", code)
+	}
+	if code, typ := do("/v1/responses", limited); code != http.StatusTooManyRequests || typ != "rate_limit_exceeded" {
+		t.Fatalf("second limited request: status = %d type = %q, want 429 rate_limit_exceeded", code, typ)
+	}
+	if code, typ := do("/v1/responses", quota); code != http.StatusTooManyRequests || typ != "insufficient_quota" {
+		t.Fatalf("exhausted quota: status = %d type = %q, want 429 insufficient_quota", code, typ)
+	}
+
+	key, err := keys.store.Get(ctx, "alice")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	used, err := keys.store.QuotaUsage(ctx, key)
+	if err != nil {
+		t.Fatalf("QuotaUsage: %v", err)
+	}
+	if used.Requests != 1 || key.LastUsedAt.IsZero() {
+		t.Fatalf("alice usage = %+v last used = %v, want one recorded request", used, key.LastUsedAt)
+	}
+}
+
+func TestCheckAPIKeyRun(t *testing.T) {
+	srv := &serveServer{cfg: serveServerConfig{agentName: "reviewer", approvalMode: "prompt"}}
+	withKey := func(scopes apikeys.Scopes) context.Context {
+		return withServeAPIKey(context.Background(), &serveAPIKeyGrant{key: apikeys.Key{Name: "ci", Scopes: scopes}})
+	}
+
+	if err := srv.checkAPIKeyRun(context.Background(), "openai"); err != nil {
+		t.Fatalf("no key: %v", err)
+	}
+	if err := srv.checkAPIKeyRun(withKey(apikeys.Scopes{Agents: []string{"reviewer"}, Providers: []string{"anthropic"}, ApprovalModes: []string{"prompt"}}), "anthropic:claude-sonnet-4-5"); err != nil {
+		t.Fatalf("in-scope run: %v", err)
+	}
+	for name, scopes := range map[string]apikeys.Scopes{
+		"agent":    {Agents: []string{"writer"}},
+		"approval": {ApprovalModes: []string{"yolo"}},
+		"provider": {Providers: []string{"openai"}},
+	} {
+		if err := srv.checkAPIKeyRun(withKey(scopes), "anthropic"); err == nil {
+			t.Fatalf("%s scope: expected error", name)
+		}
+	}
+
+}
+
+func TestAPIKeyJobScopes(t *testing.T) {
+	mgr, err := newJobsV2Manager(":memory:", 1, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+	srv := &serveServer{jobsV2: mgr, cfg: serveServerConfig{agentName: "reviewer", approvalMode: "prompt"}}
+	scoped := &serveAPIKeyGrant{key: apikeys.Key{ID: "key_ci", Name: "ci", Scopes: apikeys.Scopes{
+		Agents:    []string{"reviewer"},
+		Providers: []string{"anthropic"},
+		Tools:     []string{"read_file", "grep"},
+	}}}
+	send := func(grant *serveAPIKeyGrant, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(withServeAPIKey(req.Context(), grant))
+		rr := httptest.NewRecorder()
+		if path == "/v2/jobs" {
+			srv.handleJobsV2(rr, req)
+		} else {
+			srv.handleJobV2ByID(rr, req)
+		}
+		return rr
+	}
+	llmJob := func(name, runnerConfig string) string {
+		return `{"name":"` + name + `","runner_type":"llm","runner_config":` + runnerConfig + `,"trigger_type":"manual"}`
+	}
+
+	for name, body := range map[string]string{
+		"program":      `{"name":"shell","runner_type":"program","runner_config":{"command":"sh","args":["-c","id"]},"trigger_type":"manual"}`,
+		"agent":        llmJob("agent", `{"agent_name":"writer","instructions":"hi","cwd":"/tmp","provider":"anthropic"}`),
+		"provider":     llmJob("provider", `{"agent_name":"reviewer","instructions":"hi","cwd":"/tmp","provider":"openai:gpt-5"}`),
+		"no provider":  llmJob("no-provider", `{"agent_name":"reviewer","instructions":"hi","cwd":"/tmp"}`),
+		"tools":        llmJob("tools", `{"agent_name":"reviewer","instructions":"hi","cwd":"/tmp","provider":"anthropic","tools":"shell"}`),
+		"batch runner": `{"name":"batch","runner_type":"batch","runner_config":{"input_file_id":"file_1","endpoint":"/v1/chat/completions"},"trigger_type":"manual"}`,
+	} {
+		if rr := send(scoped, http.MethodPost, "/v2/jobs", body); rr.Code != http.StatusForbidden {
+			t.Fatalf("%s job: status = %d, want 403 body=%s", name, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := send(scoped, http.MethodPost, "/v2/jobs", llmJob("review", `{"agent_name":"reviewer","instructions":"hi","cwd":"/tmp","provider":"anthropic:claude-sonnet-4-5","tools":"grep,shell","api_key_id":"key_other"}`))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("in-scope job: status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	var created jobsV2Job
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	var cfg jobsV2LLMConfig
+	if err := json.Unmarshal(created.RunnerConfig, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Tools != "grep" || cfg.APIKeyID != "key_ci" || cfg.APIKeyName != "ci" {
+		t.Fatalf("stored runner config = %+v, want tools narrowed and key stamped", cfg)
+	}
+
+	// A program job configured with the server token stays out of a key's
+	// reach even when the patch leaves the runner alone.
+	rr = send(nil, http.MethodPost, "/v2/jobs", `{"name":"admin-shell","runner_type":"program","runner_config":{"command":"true"},"trigger_type":"manual"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("token program job: status = %d body=%s", rr.Code, rr.Body.String())
+	}
+	var program jobsV2Job
+	_ = json.Unmarshal(rr.Body.Bytes(), &program)
+	if rr := send(scoped, http.MethodPatch, "/v2/jobs/"+program.ID, `{"trigger_type":"cron","trigger_config":{"expression":"* * * * *"}}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("patch program job: status = %d, want 403 body=%s", rr.Code, rr.Body.String())
+	}
+	if rr := send(scoped, http.MethodPatch, "/v2/jobs/"+created.ID, `{"runner_type":"program","runner_config":{"command":"sh"}}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("patch to program runner: status = %d, want 403 body=%s", rr.Code, rr.Body.String())
+	}
+
+	// Nor can the key run, re-arm or inspect it any other way.
+	if _, err := mgr.UpdateJob(program.ID, jobsV2Job{Enabled: false}); err != nil {
+		t.Fatalf("pause program job: %v", err)
+	}
+	for _, action := range []struct{ method, path string }{
+		{http.MethodPost, "/v2/jobs/" + program.ID + "/trigger"},
+		{http.MethodPost, "/v2/jobs/" + program.ID + "/resume"},
+		{http.MethodPost, "/v2/jobs/" + program.ID + "/pause"},
+		{http.MethodGet, "/v2/jobs/" + program.ID + "/hooks"},
+		{http.MethodDelete, "/v2/jobs/" + program.ID},
+	} {
+		if rr := send(scoped, action.method, action.path, ""); rr.Code != http.StatusForbidden {
+			t.Fatalf("%s %s: status = %d, want 403 body=%s", action.method, action.path, rr.Code, rr.Body.String())
+		}
+	}
+	if job, _ := mgr.GetJob(program.ID); job.Enabled {
+		t.Fatal("scoped key resumed the program job")
+	}
+	if _, total, _ := mgr.ListRuns(program.ID, 10, 0); total != 0 {
+		t.Fatalf("scoped key triggered %d program runs", total)
+	}
+
+	// An llm job the server created with tools beyond the key's scope is
+	// refused too; the key's own job can be triggered.
+	rr = send(nil, http.MethodPost, "/v2/jobs", llmJob("wide", `{"agent_name":"reviewer","instructions":"hi","cwd":"/tmp","provider":"anthropic"}`))
+	var wide jobsV2Job
+	_ = json.Unmarshal(rr.Body.Bytes(), &wide)
+	if rr := send(scoped, http.MethodPost, "/v2/jobs/"+wide.ID+"/trigger", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("trigger unscoped tools job: status = %d, want 403 body=%s", rr.Code, rr.Body.String())
+	}
+	if rr := send(scoped, http.MethodPost, "/v2/jobs/"+created.ID+"/trigger", ""); rr.Code != http.StatusAccepted {
+		t.Fatalf("trigger own job: status = %d body=%s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAPIKeysCannotActAsJobWorkers(t *testing.T) {
+	mgr, err := newJobsV2Manager(":memory:", 0, nil)
+	if err != nil {
+		t.Fatalf("newJobsV2Manager: %v", err)
+	}
+	defer func() { _ = mgr.Close() }()
+	srv := &serveServer{jobsV2: mgr}
+	scoped := &serveAPIKeyGrant{key: apikeys.Key{ID: "key_ci", Name: "ci", Scopes: apikeys.Scopes{Providers: []string{"anthropic"}}}}
+	send := func(grant *serveAPIKeyGrant, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(withServeAPIKey(req.Context(), grant))
+		rr := httptest.NewRecorder()
+		if strings.HasPrefix(path, "/v2/runs/") {
+			srv.handleRunV2ByID(rr, req)
+		} else {
+			srv.handleWorkersV2(rr, req)
+		}
+		return rr
+	}
+
+	for _, call := range []struct{ method, path, body string }{
+		{http.MethodGet, "/v2/workers", ""},
+		{http.MethodPost, "/v2/workers/claim", `{"worker_id":"ci-worker"}`},
+		{http.MethodPost, "/v2/runs/run_1/heartbeat", `{"worker_id":"ci-worker"}`},
+		{http.MethodPost, "/v2/runs/run_1/complete", `{"worker_id":"ci-worker","status":"succeeded"}`},
+	} {
+		if rr := send(scoped, call.method, call.path, call.body); rr.Code != http.StatusForbidden {
+			t.Fatalf("%s %s: status = %d, want 403 body=%s", call.method, call.path, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := send(nil, http.MethodPost, "/v2/workers/claim", `{"worker_id":"token-worker"}`); rr.Code != http.StatusNoContent {
+		t.Fatalf("server token claim: status = %d, want 204 body=%s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestJobsV2LLMRunnerAttributesAPIKeyUsage(t *testing.T) {
+	var recordedKey string
+	var attribution usage.Attribution
+	runner := &jobsV2LLMRunner{
+		exec: func(ctx context.Context, cfg jobsV2LLMConfig, onEvent func(llm.Event)) (serveJobsExecResult, error) {
+			attribution, _ = usage.AttributionFromContext(ctx)
+			return serveJobsExecResult{}, nil
+		},
+		recordUsage: func(keyID string) func(usage.LogEntry) {
+			recordedKey = keyID
+			return func(usage.LogEntry) {}
+		},
+	}
+	job := jobsV2Job{ID: "job_1", Name: "review", RunnerConfig: json.RawMessage(`{"agent_name":"reviewer","instructions":"hi","cwd":"/tmp","api_key_id":"key_ci","api_key_name":"ci"}`)}
+	if _, err := runner.Run(context.Background(), job, nil); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if attribution.APIKey != "ci" || attribution.Record == nil || recordedKey != "key_ci" {
+		t.Fatalf("attribution = %+v recorded key = %q, want ci with a recorder", attribution, recordedKey)
+	}
+}
+
+func TestApplyServeAPIKeyToolScope(t *testing.T) {
+	ctx := withServeAPIKey(context.Background(), &serveAPIKeyGrant{key: apikeys.Key{Scopes: apikeys.Scopes{Tools: []string{"read_file", "grep"}}}})
+
+	req := llm.Request{}
+	applyServeAPIKeyToolScope(ctx, &req)
+	if !req.AllowedToolsPresent || !slices.Equal(req.AllowedTools, []string{"read_file", "grep"}) {
+		t.Fatalf("allowed tools = %v (present=%v), want key tools", req.AllowedTools, req.AllowedToolsPresent)
+	}
+
+	req = llm.Request{AllowedTools: []string{"grep", "shell"}, AllowedToolsPresent: true}
+	applyServeAPIKeyToolScope(ctx, &req)
+	if !slices.Equal(req.AllowedTools, []string{"grep"}) {
+		t.Fatalf("allowed tools = %v, want intersection [grep]", req.AllowedTools)
+	}
+
+	req = llm.Request{}
+	applyServeAPIKeyToolScope(context.Background(), &req)
+	if req.AllowedToolsPresent {
+		t.Fatal("request without key should not be restricted")
+	}
+}
+
+func TestServeKeysCommands(t *testing.T) {
+	oldDB, oldEndpoints, oldRPM, oldAll := serveKeysDBFlag, serveKeysEndpoints, serveKeysRPM, serveKeysListAll
+	t.Cleanup(func() {
+		serveKeysDBFlag, serveKeysEndpoints, serveKeysRPM, serveKeysListAll = oldDB, oldEndpoints, oldRPM, oldAll
+	})
+	serveKeysDBFlag = filepath.Join(t.TempDir(), "keys.db")
+	serveKeysEndpoints = []string{"/v1/*"}
+	serveKeysRPM = 30
+	serveKeysListAll = false
+
+	run := func(fn func(*cobra.Command, []string) error, args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetOut(&out)
+		cmd.SetContext(context.Background())
+		if err := fn(cmd, args); err != nil {
+			t.Fatalf("command %v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	created := run(runServeKeysCreate, "ci")
+	if !strings.Contains(created, apikeys.KeyPrefix) || !strings.Contains(created, "endpoints=/v1/*") || !strings.Contains(created, "30 rpm") {
+		t.Fatalf("create output missing key or scopes:\n%s", created)
+	}
+	listed := run(runServeKeysList)
+	if !strings.Contains(listed, "ci") || strings.Contains(listed, "(revoked)") {
+		t.Fatalf("list output:\n%s", listed)
+	}
+	run(runServeKeysRevoke, "ci")
+	if listed := run(runServeKeysList); !strings.Contains(listed, "No API keys") {
+		t.Fatalf("list after revoke:\n%s", listed)
+	}
+	serveKeysListAll = true
+	if listed := run(runServeKeysList); !strings.Contains(listed, "ci (revoked)") {
+		t.Fatalf("list --all after revoke:\n%s", listed)
+	}
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/metrics"
+	"github.com/samsaffron/term-llm/internal/tools"
+	"github.com/samsaffron/term-llm/internal/usage"
+)
+
+// serveMetrics is the opt-in Prometheus instrumentation for serve. Every
+// method is a no-op on a nil receiver so call sites need no enabled checks.
+//
+// Label values are bounded: route is the matched mux pattern (never the raw
+// path), method and outcome labels come from fixed sets, and provider,
+// model and tool labels are capped per metric by the registry.
+type serveMetrics struct {
+	registry *metrics.Registry
+	pricing  *usage.PricingFetcher
+
+	httpRequests *metrics.Counter
+	httpDuration *metrics.Histogram
+
+	llmRequests *metrics.Counter
+	llmDuration *metrics.Histogram
+	llmTTFT     *metrics.Histogram
+	llmTokens   *metrics.Counter
+	llmCost     *metrics.Counter
+
+	toolCalls    *metrics.Counter
+	toolDuration *metrics.Histogram
+	approvals    *metrics.Counter
+
+	jobRuns        *metrics.Counter
+	jobRunDuration *metrics.Histogram
+}
+
+func newServeMetrics() *serveMetrics {
+	r := metrics.NewRegistry()
+	m := &serveMetrics{registry: r, pricing: usage.NewPricingFetcher()}
+	m.httpRequests = r.NewCounter(metrics.Options{Name: "term_llm_http_requests_total", Help: "HTTP requests by route pattern, method and status code.", Labels: []string{"route", "method", "code"}})
+	m.httpDuration = r.NewHistogram(metrics.Options{Name: "term_llm_http_request_duration_seconds", Help: "HTTP request latency by route pattern and method, including streamed responses.", Labels: []string{"route", "method"}}, nil)
+	m.llmRequests = r.NewCounter(metrics.Options{Name: "term_llm_llm_requests_total", Help: "LLM engine streams by provider, model and outcome (ok, error, cancelled).", Labels: []string{"provider", "model", "outcome"}})
+	m.llmDuration = r.NewHistogram(metrics.Options{Name: "term_llm_llm_request_duration_seconds", Help: "LLM engine stream duration by provider and model, including agentic tool loops.", Labels: []string{"provider", "model"}}, nil)
+	m.llmTTFT = r.NewHistogram(metrics.Options{Name: "term_llm_llm_time_to_first_token_seconds", Help: "Delay before the first text, reasoning or tool call event by provider and model.", Labels: []string{"provider", "model"}}, []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30, 60})
+	m.llmTokens = r.NewCounter(metrics.Options{Name: "term_llm_llm_tokens_total", Help: "Tokens by provider, model and type (input, output, cache_read, cache_write).", Labels: []string{"provider", "model", "type"}})
+	m.llmCost = r.NewCounter(metrics.Options{Name: "term_llm_llm_cost_usd_total", Help: "Estimated spend in USD by provider and model, from local pricing data.", Labels: []string{"provider", "model"}})
+	m.toolCalls = r.NewCounter(metrics.Options{Name: "term_llm_tool_calls_total", Help: "Tool executions by tool and outcome (success, error).", Labels: []string{"tool", "outcome"}})
+	m.toolDuration = r.NewHistogram(metrics.Options{Name: "term_llm_tool_call_duration_seconds", Help: "Tool execution duration by tool.", Labels: []string{"tool"}}, []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300})
+	m.approvals = r.NewCounter(metrics.Options{Name: "term_llm_approval_decisions_total", Help: "Approval decisions by kind (file, shell, workspace, guardian) and outcome (approved, denied, warning, error).", Labels: []string{"kind", "outcome"}})
+	m.jobRuns = r.NewCounter(metrics.Options{Name: "term_llm_jobs_runs_finished_total", Help: "Finished job runs by final status.", Labels: []string{"status"}})
+	m.jobRunDuration = r.NewHistogram(metrics.Options{Name: "term_llm_jobs_run_duration_seconds", Help: "Job run duration from start to finish by final status.", Labels: []string{"status"}}, nil)
+	return m
+}
+
+// registerGauges adds the scrape-time gauges that read server state.
+func (m *serveMetrics) registerGauges(s *serveServer) {
+	if m == nil {
+		return
+	}
+	m.registry.NewGaugeFunc(metrics.Options{Name: "term_llm_sessions_active", Help: "Sessions with an in-memory runtime."}, func(emit func(float64, ...string)) {
+		if s.sessionMgr != nil {
+			emit(float64(s.sessionMgr.Len()))
+		}
+	})
+	m.registry.NewGaugeFunc(metrics.Options{Name: "term_llm_runs_active", Help: "Sessions with a run in progress."}, func(emit func(float64, ...string)) {
+		active := map[string]bool{}
+		if s.sessionMgr != nil {
+			active = s.sessionMgr.ActiveSessionIDs()
+		}
+		if s.responseRuns != nil {
+			for id := range s.responseRuns.ActiveSessionIDs() {
+				active[id] = true
+			}
+		}
+		emit(float64(len(active)))
+	})
+	if s.jobsV2 != nil {
+		m.registry.NewGaugeFunc(metrics.Options{Name: "term_llm_jobs_runs", Help: "Job runs waiting or in progress by status (queued, claimed, running).", Labels: []string{"status"}}, func(emit func(float64, ...string)) {
+			counts, err := s.jobsV2.ActiveRunCounts()
+			if err != nil {
+				return
+			}
+			for _, status := range []jobsV2RunStatus{jobsV2RunQueued, jobsV2RunClaimed, jobsV2RunRunning} {
+				emit(float64(counts[status]), string(status))
+			}
+		})
+		s.jobsV2.AddRunObserver(m.observeJobRun)
+	}
+}
+
+func (m *serveMetrics) handler() http.Handler {
+	return m.registry.Handler()
+}
+
+// instrument records request counts and latency for a mux. The route label
+// is the pattern the mux matched, which ServeMux stores on the request.
+func (m *serveMetrics) instrument(next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &serveMetricsRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		route := r.Pattern
+		if route == "" {
+			route = metrics.OverflowLabel
+		}
+		method := serveMetricsMethod(r.Method)
+		m.httpRequests.Inc(route, method, strconv.Itoa(rec.status))
+		m.httpDuration.Observe(time.Since(start).Seconds(), route, method)
+	})
+}
+
+func serveMetricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return metrics.OverflowLabel
+}
+
+// serveMetricsRecorder captures the response status while keeping the
+// streaming and hijacking interfaces handlers rely on.
+type serveMetricsRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *serveMetricsRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *serveMetricsRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *serveMetricsRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *serveMetricsRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("response does not support hijacking")
+}
+
+func (r *serveMetricsRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+// ObserveStream implements llm.StreamObserver.
+func (m *serveMetrics) ObserveStream(stats llm.StreamStats) {
+	if m == nil {
+		return
+	}
+	m.llmRequests.Inc(stats.Provider, stats.Model, stats.Outcome)
+	m.llmDuration.Observe(stats.Duration.Seconds(), stats.Provider, stats.Model)
+	if stats.TimeToFirstToken > 0 {
+		m.llmTTFT.Observe(stats.TimeToFirstToken.Seconds(), stats.Provider, stats.Model)
+	}
+	for _, tokens := range []struct {
+		kind  string
+		count int
+	}{
+		{"input", stats.InputTokens},
+		{"output", stats.OutputTokens},
+		{"cache_read", stats.CacheReadTokens},
+		{"cache_write", stats.CacheWriteTokens},
+	} {
+		if tokens.count > 0 {
+			m.llmTokens.Add(float64(tokens.count), stats.Provider, stats.Model, tokens.kind)
+		}
+	}
+	if stats.InputTokens > 0 || stats.OutputTokens > 0 {
+		cost, err := m.pricing.CalculateCostLocal(usage.UsageEntry{
+			Model:            stats.Model,
+			InputTokens:      stats.InputTokens,
+			OutputTokens:     stats.OutputTokens,
+			CacheReadTokens:  stats.CacheReadTokens,
+			CacheWriteTokens: stats.CacheWriteTokens,
+		})
+		if err == nil && cost > 0 {
+			m.llmCost.Add(cost, stats.Provider, stats.Model)
+		}
+	}
+}
+
+// ObserveTool implements llm.StreamObserver.
+func (m *serveMetrics) ObserveTool(stats llm.ToolStats) {
+	if m == nil {
+		return
+	}
+	outcome := "success"
+	if !stats.Success {
+		outcome = "error"
+	}
+	m.toolCalls.Inc(stats.Tool, outcome)
+	m.toolDuration.Observe(stats.Duration.Seconds(), stats.Tool)
+}
+
+// observeApproval records a human approval decision.
+func (m *serveMetrics) observeApproval(kind string, approved bool, err error) {
+	if m == nil {
+		return
+	}
+	outcome := "approved"
+	switch {
+	case err != nil && !errors.Is(err, context.Canceled):
+		outcome = "error"
+	case err != nil || !approved:
+		outcome = "denied"
+	}
+	m.approvals.Inc(kind, outcome)
+}
+
+// observeGuardian records an auto-mode guardian review.
+func (m *serveMetrics) observeGuardian(event tools.GuardianEvent) {
+	if m == nil || event.Outcome == "" {
+		return
+	}
+	m.approvals.Inc("guardian", string(event.Outcome))
+}
+
+func (m *serveMetrics) observeJobRun(run jobsV2Run) {
+	if m == nil {
+		return
+	}
+	m.jobRuns.Inc(string(run.Status))
+	if run.StartedAt != nil && run.FinishedAt != nil {
+		m.jobRunDuration.Observe(run.FinishedAt.Sub(*run.StartedAt).Seconds(), string(run.Status))
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/tools"
+)
+
+func TestServeMetricsEndpoint(t *testing.T) {
+	m := newServeMetrics()
+	srv := &serveServer{
+		cfg:     serveServerConfig{basePath: "/ui", requireAuth: true, token: "secret"},
+		metrics: m,
+	}
+	m.registerGauges(srv)
+	h := srv.httpHandler()
+
+	do := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do("/ui/healthz", ""); rr.Code != http.StatusOK {
+		t.Fatalf("/healthz status = %d", rr.Code)
+	}
+	do("/ui/v1/sessions/abc/events", "")
+	if rr := do("/ui/metrics", ""); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated /metrics status = %d, want 401", rr.Code)
+	}
+
+	rr := do("/ui/metrics", "secret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("/metrics status = %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		`term_llm_http_requests_total{route="/healthz",method="GET",code="200"} 1`,
+		`term_llm_http_requests_total{route="/v1/sessions/",method="GET",code="401"} 1`,
+		`term_llm_http_request_duration_seconds_count{route="/healthz",method="GET"} 1`,
+		"term_llm_sessions_active",
+		"term_llm_runs_active 0",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "/v1/sessions/abc") {
+		t.Fatalf("raw request path leaked into route label:\n%s", body)
+	}
+}
+
+func TestServeMetricsRecorderKeepsFlusher(t *testing.T) {
+	m := newServeMetrics()
+	var flushed bool
+	h := m.instrument(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("instrumented writer is not an http.Flusher")
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.WriteHeader(http.StatusTeapot)
+		flusher.Flush()
+		flushed = true
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/x", nil))
+	if !flushed {
+		t.Fatal("handler did not run")
+	}
+	if got := m.httpRequests.Value("other", "POST", "202"); got != 1 {
+		t.Fatalf("requests{code=202} = %v, want the first status code", got)
+	}
+}
+
+func TestServeMetricsObservers(t *testing.T) {
+	m := newServeMetrics()
+	m.ObserveStream(llm.StreamStats{
+		Provider: "anthropic", Model: "claude-sonnet-4-5", Outcome: "ok",
+		Duration: 3 * time.Second, TimeToFirstToken: 400 * time.Millisecond,
+		InputTokens: 1000, OutputTokens: 200,
+	})
+	m.ObserveTool(llm.ToolStats{Tool: "shell", Duration: time.Second, Success: false})
+	m.observeApproval("shell", true, nil)
+	m.observeApproval("file", false, nil)
+	m.observeApproval("workspace", false, errors.New("no transport"))
+	m.observeGuardian(tools.GuardianEvent{Outcome: tools.GuardianDenied})
+	m.observeJobRun(jobsV2Run{Status: jobsV2RunFailed})
+
+	checks := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"llm requests", m.llmRequests.Value("anthropic", "claude-sonnet-4-5", "ok"), 1},
+		{"input tokens", m.llmTokens.Value("anthropic", "claude-sonnet-4-5", "input"), 1000},
+		{"output tokens", m.llmTokens.Value("anthropic", "claude-sonnet-4-5", "output"), 200},
+		{"ttft", float64(m.llmTTFT.Count("anthropic", "claude-sonnet-4-5")), 1},
+		{"tool errors", m.toolCalls.Value("shell", "error"), 1},
+		{"shell approved", m.approvals.Value("shell", "approved"), 1},
+		{"file denied", m.approvals.Value("file", "denied"), 1},
+		{"workspace error", m.approvals.Value("workspace", "error"), 1},
+		{"guardian denied", m.approvals.Value("guardian", "denied"), 1},
+		{"job runs failed", m.jobRuns.Value("failed"), 1},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	var nilMetrics *serveMetrics
+	nilMetrics.ObserveStream(llm.StreamStats{})
+	nilMetrics.observeApproval("file", true, nil)
+	if h := nilMetrics.instrument(http.NotFoundHandler()); h == nil {
+		t.Fatal("nil metrics should pass the handler through")
+	}
+}
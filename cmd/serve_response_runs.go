@@ -18,6 +18,7 @@ import (
 	"github.com/samsaffron/term-llm/internal/runboundary"
 	"github.com/samsaffron/term-llm/internal/session"
 	"github.com/samsaffron/term-llm/internal/tools"
+	"github.com/samsaffron/term-llm/internal/webhooks"
 )
 
 type responseRunEvent struct {
@@ -150,6 +151,7 @@ type responseRun struct {
 	nextSubscriberID   int
 	cancel             context.CancelFunc
 	cancelRequested    bool
+	webhooks           *webhooks.Dispatcher // nil unless serve.webhooks is configured
 }
 
 type startResponseRunOptions struct {
@@ -160,6 +162,7 @@ type startResponseRunOptions struct {
 	idempotencyKey            string
 	onDone                    func()
 	runtimeSetup              func(*llm.Request) error
+	apiKey                    *serveAPIKeyGrant
 }
 
 type responseRunContextKey struct{}
@@ -696,6 +699,7 @@ func (r *responseRun) appendEventLocked(event string, payload map[string]any, te
 		Event:    event,
 		Data:     data,
 	}, terminal)
+	r.emitWebhookLocked(event, payload)
 	return nil
 }
 
@@ -1389,6 +1393,7 @@ type responseRunManager struct {
 	terminalRetention  time.Duration
 	runWG              sync.WaitGroup
 	closed             bool
+	webhooks           *webhooks.Dispatcher // copied onto each run; nil when disabled
 }
 
 const (
@@ -1549,6 +1554,9 @@ func (s *serveServer) ensureResponseRuns() *responseRunManager {
 		if s.responseRuns == nil {
 			s.responseRuns = newServeResponseRunManager()
 		}
+		if s.webhooks != nil {
+			s.responseRuns.webhooks = s.webhooks
+		}
 	})
 	return s.responseRuns
 }
@@ -1601,6 +1609,7 @@ func (m *responseRunManager) createOrGetByIdempotency(run *responseRun, idempote
 	}
 	m.nextEpochBySession[run.sessionID] = nextEpoch
 	run.runEpoch = nextEpoch
+	run.webhooks = m.webhooks
 	m.runs[run.id] = run
 	if key != "" {
 		m.idempotencyByKey[key] = run.id
@@ -2651,6 +2660,7 @@ func (s *serveServer) startResponseRun(runtime *serveRuntime, stateful bool, rep
 	//  - serve.response_timeout bounds active execution, excluding time spent
 	//    waiting for a person to answer an interactive prompt.
 	runCtx, runTimer := newResponseRunTimer(s.responseTimeout())
+	runCtx = withServeAPIKey(runCtx, options.apiKey)
 	cancel := runTimer.stop
 	run := newResponseRun(respID, sessionID, options.previousResponseID, model, created, cancel)
 	for i := len(inputMessages) - 1; i >= 0; i-- {
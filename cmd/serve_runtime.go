@@ -19,6 +19,7 @@ import (
 	"github.com/samsaffron/term-llm/internal/mcp"
 	"github.com/samsaffron/term-llm/internal/session"
 	"github.com/samsaffron/term-llm/internal/tools"
+	"github.com/samsaffron/term-llm/internal/webhooks"
 )
 
 type serveRuntime struct {
@@ -79,6 +80,7 @@ type serveRuntime struct {
 	lastInjectedPlatform string
 	sideQuestion         sideQuestionRuntime
 	sideProviderFactory  func(providerKey, model string) (llm.Provider, error)
+	webhooks             *webhooks.Dispatcher // nil unless serve.webhooks is configured
 }
 
 type runtimeInterruptState struct {
@@ -1177,6 +1179,7 @@ func (rt *serveRuntime) runOnce(ctx context.Context, stateful bool, replaceHisto
 	// Serve requests explicitly opt into the planner only when this runtime has
 	// an active MCP selection. Auxiliary requests sharing the engine stay out.
 	req.EnableToolDiscovery = req.EnableToolDiscovery || (rt.mcpManager != nil && strings.TrimSpace(rt.mcpSetting) != "")
+	applyServeAPIKeyToolScope(ctx, &req)
 	restoreAllowedTools := rt.applyRequestAllowedTools(&req)
 	defer restoreAllowedTools()
 	rt.Touch()
@@ -1568,6 +1571,7 @@ func (rt *serveRuntime) runOnce(ctx context.Context, stateful bool, replaceHisto
 		}
 		rt.engine.SetContextEstimateBaseline(0, 0)
 		persistPlatformInjectionLocked()
+		rt.emitCompactionWebhook(req.SessionID, "auto", result)
 		return nil
 	})
 	defer rt.engine.SetCompactionCallback(nil)
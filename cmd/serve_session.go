@@ -526,6 +526,13 @@ func (m *serveSessionManager) BeginSwap(ctx context.Context, id string, create f
 	}
 }
 
+// Len returns the number of sessions with an in-memory runtime.
+func (m *serveSessionManager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
 // ActiveSessionIDs returns the set of session IDs that currently have an
 // active run (activeInterrupt != nil). Unlike Get, this does NOT touch
 // runtimes, so it won't extend their TTL.
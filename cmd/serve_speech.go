@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/audio"
+	"github.com/samsaffron/term-llm/internal/config"
+)
+
+const (
+	maxSpeechInputChars   = 4096
+	maxSpeechRequestBytes = 64 << 10
+)
+
+type serveSpeechProvider interface {
+	Generate(ctx context.Context, req audio.Request) (*audio.Result, error)
+}
+
+// newServeSpeechProvider builds the named TTS provider from config.
+var newServeSpeechProvider = func(cfg *config.Config, name string) (serveSpeechProvider, error) {
+	switch name {
+	case "venice":
+		apiKey, err := veniceAudioAPIKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return audio.NewVeniceProvider(apiKey), nil
+	case "gemini":
+		apiKey, err := geminiAudioAPIKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return audio.NewGeminiProvider(apiKey), nil
+	case "elevenlabs":
+		apiKey, err := elevenLabsAudioAPIKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return audio.NewElevenLabsProvider(apiKey), nil
+	default:
+		return nil, fmt.Errorf("unsupported audio provider %q (allowed: venice, gemini, elevenlabs)", name)
+	}
+}
+
+// openAISpeechVoices are the voice names OpenAI clients send. Venice has
+// close matches for some; other providers fall back to the configured voice.
+var openAISpeechVoices = map[string]string{
+	"alloy": "af_alloy", "ash": "", "ballad": "", "coral": "", "echo": "am_echo", "fable": "bm_fable",
+	"nova": "af_nova", "onyx": "am_onyx", "sage": "", "shimmer": "", "verse": "",
+}
+
+// speechFormatMediaTypes maps OpenAI response_format values to the media
+// types accepted in an Accept header.
+var speechFormatMediaTypes = map[string][]string{
+	"mp3":  {"audio/mpeg", "audio/mp3"},
+	"opus": {"audio/opus", "audio/ogg"},
+	"aac":  {"audio/aac"},
+	"flac": {"audio/flac", "audio/x-flac"},
+	"wav":  {"audio/wav", "audio/x-wav", "audio/wave"},
+	"pcm":  {"audio/pcm", "audio/l16"},
+}
+
+type speechRequest struct {
+	Model          string  `json:"model,omitempty"`
+	Input          string  `json:"input"`
+	Voice          string  `json:"voice,omitempty"`
+	Instructions   string  `json:"instructions,omitempty"`
+	ResponseFormat string  `json:"response_format,omitempty"`
+	Speed          float64 `json:"speed,omitempty"`
+}
+
+// serveSpeechTarget is a resolved TTS provider, model and voice.
+type serveSpeechTarget struct {
+	provider string
+	model    string
+	voice    string
+}
+
+// resolveServeSpeechTarget maps an OpenAI speech model and voice onto a
+// configured TTS provider. model may name a provider, provider:model, or a
+// model of one provider; anything else (tts-1, gpt-4o-mini-tts) uses
+// audio.provider with its configured model.
+func resolveServeSpeechTarget(cfg *config.Config, model, voice string) serveSpeechTarget {
+	model = strings.TrimSpace(model)
+	var t serveSpeechTarget
+	switch provider, providerModel, _ := strings.Cut(model, ":"); {
+	case provider == "venice" || provider == "gemini" || provider == "elevenlabs":
+		t.provider, t.model = provider, providerModel
+	case slices.Contains(audio.VeniceModels, model):
+		t.provider, t.model = "venice", model
+	case slices.Contains(audio.GeminiModels, model) || strings.HasPrefix(model, "gemini-"):
+		t.provider, t.model = "gemini", model
+	case slices.Contains(audio.ElevenLabsModels, model) || strings.HasPrefix(model, "eleven_"):
+		t.provider, t.model = "elevenlabs", model
+	default:
+		t.provider = firstNonEmpty(cfg.Audio.Provider, config.DefaultAudioProvider)
+	}
+
+	voice = strings.TrimSpace(voice)
+	if mapped, ok := openAISpeechVoices[strings.ToLower(voice)]; ok {
+		voice = ""
+		if t.provider == "venice" {
+			voice = mapped
+		}
+	}
+	switch t.provider {
+	case "venice":
+		t.model = firstNonEmpty(t.model, cfg.Audio.Venice.Model, audio.DefaultModel)
+		t.voice = firstNonEmpty(voice, cfg.Audio.Venice.Voice, audio.DefaultVoice)
+	case "gemini":
+		t.model = firstNonEmpty(t.model, cfg.Audio.Gemini.Model, config.DefaultAudioGeminiModel)
+		t.voice = firstNonEmpty(voice, cfg.Audio.Gemini.Voice, config.DefaultAudioGeminiVoice)
+	case "elevenlabs":
+		t.model = firstNonEmpty(t.model, cfg.Audio.ElevenLabs.Model, config.DefaultAudioElevenLabsModel)
+		t.voice = firstNonEmpty(voice, cfg.Audio.ElevenLabs.Voice, config.DefaultAudioElevenLabsVoice)
+	}
+	return t
+}
+
+// speechProviderFormat returns the provider-specific format for an OpenAI
+// response_format, or false when the provider cannot produce it. Native
+// provider formats such as ElevenLabs' mp3_22050_32 are passed through.
+func speechProviderFormat(provider, format string) (string, bool) {
+	switch provider {
+	case "venice":
+		return format, slices.Contains(audio.VeniceFormats, format)
+	case "gemini":
+		return format, slices.Contains(audio.GeminiFormats, format)
+	case "elevenlabs":
+		native := map[string]string{"mp3": "mp3_44100_128", "opus": "opus_48000_128", "pcm": "pcm_24000", "wav": "wav_44100"}[format]
+		if native != "" {
+			return native, true
+		}
+		return format, slices.Contains(audio.ElevenLabsFormats, format)
+	}
+	return "", false
+}
+
+func speechProviderDefaultFormat(cfg *config.Config, provider string) string {
+	switch provider {
+	case "gemini":
+		return firstNonEmpty(cfg.Audio.Gemini.Format, config.DefaultAudioGeminiFormat)
+	case "elevenlabs":
+		return firstNonEmpty(cfg.Audio.ElevenLabs.Format, config.DefaultAudioElevenLabsFormat)
+	default:
+		return firstNonEmpty(cfg.Audio.Venice.Format, audio.DefaultFormat)
+	}
+}
+
+// negotiateSpeechFormat picks the provider format for a request. An explicit
+// response_format must be supported; otherwise the first supported type in
+// the Accept header wins, then mp3 as OpenAI defaults, then the provider's
+// configured format.
+func negotiateSpeechFormat(cfg *config.Config, provider, requested, accept string) (string, error) {
+	if requested = strings.ToLower(strings.TrimSpace(requested)); requested != "" {
+		if format, ok := speechProviderFormat(provider, requested); ok {
+			return format, nil
+		}
+		return "", fmt.Errorf("response_format %q is not supported by audio provider %s", requested, provider)
+	}
+	for _, mediaType := range acceptedMediaTypes(accept) {
+		for generic, types := range speechFormatMediaTypes {
+			if slices.Contains(types, mediaType) {
+				if format, ok := speechProviderFormat(provider, generic); ok {
+					return format, nil
+				}
+			}
+		}
+	}
+	if format, ok := speechProviderFormat(provider, "mp3"); ok {
+		return format, nil
+	}
+	return speechProviderDefaultFormat(cfg, provider), nil
+}
+
+// acceptedMediaTypes returns the media types of an Accept header in
+// preference order, dropping wildcards and q=0 entries.
+func acceptedMediaTypes(accept string) []string {
+	type entry struct {
+		mediaType string
+		q         float64
+	}
+	var entries []entry
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || strings.HasSuffix(mediaType, "/*") || mediaType == "*/*" {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(raw, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			entries = append(entries, entry{mediaType: strings.ToLower(mediaType), q: q})
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	types := make([]string, len(entries))
+	for i, e := range entries {
+		types[i] = e.mediaType
+	}
+	return types
+}
+
+func (s *serveServer) handleAudioSpeech(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSpeechRequestBytes)
+	var req speechRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid json")
+		return
+	}
+	input := strings.TrimSpace(req.Input)
+	if input == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "input is required")
+		return
+	}
+	if n := len([]rune(input)); n > maxSpeechInputChars {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("input is %d characters; at most %d are allowed", n, maxSpeechInputChars))
+		return
+	}
+	if err := audio.ValidateSpeed(req.Speed); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	target := resolveServeSpeechTarget(s.cfgRef, req.Model, req.Voice)
+	if err := checkAPIKeyProvider(r.Context(), target.provider); err != nil {
+		writeOpenAIError(w, http.StatusForbidden, "permission_error", err.Error())
+		return
+	}
+	speed := req.Speed
+	switch target.provider {
+	case "gemini":
+		if speed != 0 && speed != audio.DefaultSpeed {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "speed is not supported by audio provider gemini; use instructions for pacing")
+			return
+		}
+		speed = 0
+	case "elevenlabs":
+		if strings.TrimSpace(req.Instructions) != "" {
+			writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "instructions are not supported by audio provider elevenlabs")
+			return
+		}
+	}
+	format, err := negotiateSpeechFormat(s.cfgRef, target.provider, req.ResponseFormat, r.Header.Get("Accept"))
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	provider, err := newServeSpeechProvider(s.cfgRef, target.provider)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	start := time.Now()
+	result, err := provider.Generate(r.Context(), audio.Request{
+		Input:          input,
+		Model:          target.model,
+		Voice:          target.voice,
+		Prompt:         strings.TrimSpace(req.Instructions),
+		ResponseFormat: format,
+		Speed:          speed,
+	})
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadGateway, "server_error", "speech generation failed: "+err.Error())
+		return
+	}
+	log.Printf("[serve] audio.speech provider=%s model=%s voice=%s format=%s chars=%d bytes=%d duration=%s", target.provider, target.model, target.voice, format, len([]rune(input)), len(result.Data), time.Since(start).Round(time.Millisecond))
+
+	w.Header().Set("Content-Type", firstNonEmpty(result.MimeType, audio.MimeTypeForFormat(format)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(result.Data)))
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(result.Data)
+}
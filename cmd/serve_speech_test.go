@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/samsaffron/term-llm/internal/audio"
+	"github.com/samsaffron/term-llm/internal/config"
+)
+
+type fakeServeSpeechProvider struct {
+	provider string
+	req      audio.Request
+}
+
+func (p *fakeServeSpeechProvider) Generate(ctx context.Context, req audio.Request) (*audio.Result, error) {
+	p.req = req
+	return &audio.Result{Data: []byte("audio:" + req.ResponseFormat), Format: req.ResponseFormat}, nil
+}
+
+func TestResolveServeSpeechTarget(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Audio.Gemini.Voice = "Puck"
+	tests := []struct {
+		model, voice string
+		want         serveSpeechTarget
+	}{
+		{"tts-1", "alloy", serveSpeechTarget{"venice", "tts-kokoro", "af_alloy"}},
+		{"gpt-4o-mini-tts", "coral", serveSpeechTarget{"venice", "tts-kokoro", "af_sky"}},
+		{"gemini", "nova", serveSpeechTarget{"gemini", "gemini-3.1-flash-tts-preview", "Puck"}},
+		{"gemini-2.5-flash-preview-tts", "Kore", serveSpeechTarget{"gemini", "gemini-2.5-flash-preview-tts", "Kore"}},
+		{"elevenlabs:eleven_v3", "", serveSpeechTarget{"elevenlabs", "eleven_v3", "JBFqnCBsd6RMkjVDRZzb"}},
+	}
+	for _, tt := range tests {
+		if got := resolveServeSpeechTarget(cfg, tt.model, tt.voice); got != tt.want {
+			t.Errorf("resolveServeSpeechTarget(%q, %q) = %+v, want %+v", tt.model, tt.voice, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateSpeechFormat(t *testing.T) {
+	cfg := &config.Config{}
+	tests := []struct {
+		provider, requested, accept, want string
+	}{
+		{"venice", "", "", "mp3"},
+		{"venice", "flac", "audio/wav", "flac"},
+		{"venice", "", "audio/wav;q=0.5, audio/ogg", "opus"},
+		{"venice", "", "audio/*, audio/x-wav", "wav"},
+		{"gemini", "", "", "wav"},
+		{"gemini", "", "audio/mpeg, audio/L16;q=0.1", "pcm"},
+		{"elevenlabs", "opus", "", "opus_48000_128"},
+		{"elevenlabs", "mp3_22050_32", "", "mp3_22050_32"},
+	}
+	for _, tt := range tests {
+		got, err := negotiateSpeechFormat(cfg, tt.provider, tt.requested, tt.accept)
+		if err != nil || got != tt.want {
+			t.Errorf("negotiateSpeechFormat(%q, %q, %q) = %q, %v; want %q", tt.provider, tt.requested, tt.accept, got, err, tt.want)
+		}
+	}
+	if _, err := negotiateSpeechFormat(cfg, "gemini", "mp3", ""); err == nil {
+		t.Fatal("expected error for mp3 on gemini")
+	}
+}
+
+func TestHandleAudioSpeech(t *testing.T) {
+	fake := &fakeServeSpeechProvider{}
+	old := newServeSpeechProvider
+	newServeSpeechProvider = func(cfg *config.Config, name string) (serveSpeechProvider, error) {
+		fake.provider = name
+		return fake, nil
+	}
+	t.Cleanup(func() { newServeSpeechProvider = old })
+	s := &serveServer{cfgRef: &config.Config{}}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/audio/speech", strings.NewReader(`{"model":"tts-1","input":"Hello there","voice":"onyx","instructions":"calm","speed":1.25}`))
+	req.Header.Set("Accept", "audio/flac")
+	s.handleAudioSpeech(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d body = %s", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "audio/flac" {
+		t.Fatalf("content-type = %q", got)
+	}
+	if rr.Body.String() != "audio:flac" || rr.Header().Get("Content-Length") != "10" {
+		t.Fatalf("body = %q length = %q", rr.Body.String(), rr.Header().Get("Content-Length"))
+	}
+	if fake.provider != "venice" || fake.req.Voice != "am_onyx" || fake.req.Prompt != "calm" || fake.req.Speed != 1.25 {
+		t.Fatalf("provider = %q request = %+v", fake.provider, fake.req)
+	}
+
+	for body, want := range map[string]string{
+		`{"input":""}`: "input is required",
+		`{"input":"` + strings.Repeat("a", maxSpeechInputChars+1) + `"}`: "at most 4096",
+		`{"input":"hi","speed":9}`:                                       "invalid speed",
+		`{"model":"gemini","input":"hi","speed":2}`:                      "speed is not supported",
+		`{"model":"gemini","input":"hi","response_format":"mp3"}`:        "not supported by audio provider gemini",
+		`{"model":"elevenlabs","input":"hi","instructions":"whisper"}`:   "instructions are not supported",
+	} {
+		rr := httptest.NewRecorder()
+		s.handleAudioSpeech(rr, httptest.NewRequest(http.MethodPost, "/v1/audio/speech", strings.NewReader(body)))
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("%.60s: status = %d body = %s", body, rr.Code, rr.Body.String())
+		}
+	}
+}
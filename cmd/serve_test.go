@@ -323,7 +323,7 @@ func TestResolvePlatforms(t *testing.T) {
 		{name: "single telegram", args: []string{"telegram"}, want: []string{"telegram"}},
 		{name: "multiple platforms", args: []string{"telegram", "web"}, want: []string{"telegram", "web"}},
 		{name: "all three", args: []string{"web", "jobs", "telegram"}, want: []string{"web", "jobs", "telegram"}},
-		{name: "unknown platform", args: []string{"slack"}, wantErr: `unknown platform "slack"`},
+		{name: "unknown platform", args: []string{"irc"}, wantErr: `unknown platform "irc"`},
 		{name: "mixed valid and invalid", args: []string{"web", "invalid"}, wantErr: `unknown platform "invalid"`},
 		{name: "case insensitive", args: []string{"WEB", "Telegram"}, want: []string{"web", "telegram"}},
 		{name: "dedup", args: []string{"telegram", "telegram", "web"}, want: []string{"telegram", "web"}},
@@ -332,7 +332,7 @@ func TestResolvePlatforms(t *testing.T) {
 		{name: "args override config", args: []string{"web"}, configPlatform: []string{"telegram"}, want: []string{"web"}},
 		{name: "config fallback", args: nil, configPlatform: []string{"telegram", "web"}, want: []string{"telegram", "web"}},
 		{name: "config dedup", args: nil, configPlatform: []string{"web", "web"}, want: []string{"web"}},
-		{name: "config unknown", args: nil, configPlatform: []string{"slack"}, wantErr: `unknown platform "slack"`},
+		{name: "config unknown", args: nil, configPlatform: []string{"irc"}, wantErr: `unknown platform "irc"`},
 	}
 
 	for _, tt := range tests {
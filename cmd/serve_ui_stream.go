@@ -27,6 +27,9 @@ func (s *serveServer) streamUIResponses(w http.ResponseWriter, r *http.Request,
 }
 
 func (s *serveServer) streamResponseRun(ctx context.Context, w http.ResponseWriter, runtime *serveRuntime, stateful bool, replaceHistory bool, inputMessages []llm.Message, llmReq llm.Request, sessionID string, options startResponseRunOptions) bool {
+	if options.apiKey == nil {
+		options.apiKey = serveAPIKeyFromContext(ctx)
+	}
 	run, err := s.startResponseRun(runtime, stateful, replaceHistory, inputMessages, llmReq, sessionID, options)
 	if err != nil {
 		if options.modelSwap != nil && options.modelSwap.plan.enabled {
@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/hub"
+	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/session"
+	"github.com/samsaffron/term-llm/internal/webhooks"
+)
+
+// serveWebhookEndpoints converts and validates the serve.webhooks config.
+func serveWebhookEndpoints(entries []config.ServeWebhook) ([]webhooks.Endpoint, error) {
+	endpoints := make([]webhooks.Endpoint, 0, len(entries))
+	for _, entry := range entries {
+		endpoints = append(endpoints, webhooks.Endpoint{
+			Name:   strings.TrimSpace(entry.Name),
+			URL:    strings.TrimSpace(entry.URL),
+			Secret: entry.Secret,
+			Events: entry.Events,
+		})
+	}
+	if err := webhooks.ValidateEndpoints(endpoints); err != nil {
+		return nil, fmt.Errorf("serve.webhooks: %w", err)
+	}
+	return endpoints, nil
+}
+
+// resolveServeWebhooksPath returns the delivery log path for an explicit
+// flag value or the default under the data directory.
+func resolveServeWebhooksPath(flagValue string) (string, error) {
+	if path := strings.TrimSpace(flagValue); path != "" {
+		return path, nil
+	}
+	dataDir, err := session.GetDataDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve data directory: %w", err)
+	}
+	return webhooks.DefaultPath(dataDir), nil
+}
+
+// startServeWebhooks opens the delivery log and starts delivering in the
+// background. With no webhooks configured it returns a nil dispatcher, on
+// which every emit is a no-op. stop waits for in-flight deliveries to be
+// recorded and closes the log.
+func startServeWebhooks(ctx context.Context, cfg *config.Config, dbFlag string) (dispatcher *webhooks.Dispatcher, stop func(), err error) {
+	stop = func() {}
+	if cfg == nil || len(cfg.Serve.Webhooks) == 0 {
+		return nil, stop, nil
+	}
+	endpoints, err := serveWebhookEndpoints(cfg.Serve.Webhooks)
+	if err != nil {
+		return nil, stop, err
+	}
+	path, err := resolveServeWebhooksPath(dbFlag)
+	if err != nil {
+		return nil, stop, err
+	}
+	store, err := webhooks.Open(path)
+	if err != nil {
+		return nil, stop, err
+	}
+	dispatcher = webhooks.NewDispatcher(store, endpoints)
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatcher.Run(runCtx)
+	}()
+	return dispatcher, func() {
+		cancel()
+		<-done
+		_ = store.Close()
+	}, nil
+}
+
+// Event payloads. Each is the "data" object of the delivered event.
+
+type webhookResponseData struct {
+	ResponseID string            `json:"response_id"`
+	SessionID  string            `json:"session_id,omitempty"`
+	Model      string            `json:"model,omitempty"`
+	Status     string            `json:"status"`
+	ErrorType  string            `json:"error_type,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Usage      *webhookUsageData `json:"usage,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+type webhookUsageData struct {
+	InputTokens       int `json:"input_tokens"`
+	OutputTokens      int `json:"output_tokens"`
+	CachedInputTokens int `json:"cached_input_tokens,omitempty"`
+	CacheWriteTokens  int `json:"cache_write_tokens,omitempty"`
+}
+
+func webhookUsage(u llm.Usage) *webhookUsageData {
+	if u.IsZero() {
+		return nil
+	}
+	return &webhookUsageData{
+		InputTokens:       u.InputTokens,
+		OutputTokens:      u.OutputTokens,
+		CachedInputTokens: u.CachedInputTokens,
+		CacheWriteTokens:  u.CacheWriteTokens,
+	}
+}
+
+type webhookApprovalData struct {
+	SessionID  string `json:"session_id,omitempty"`
+	ResponseID string `json:"response_id"`
+	ApprovalID string `json:"approval_id"`
+	Kind       string `json:"kind"`
+	Title      string `json:"title,omitempty"`
+	Target     string `json:"target"`
+	WorkDir    string `json:"work_dir,omitempty"`
+}
+
+type webhookAskUserData struct {
+	SessionID  string `json:"session_id,omitempty"`
+	ResponseID string `json:"response_id"`
+	CallID     string `json:"call_id"`
+	Questions  any    `json:"questions"`
+}
+
+type webhookJobRunData struct {
+	JobID        string     `json:"job_id"`
+	JobName      string     `json:"job_name,omitempty"`
+	RunID        string     `json:"run_id"`
+	Trigger      string     `json:"trigger,omitempty"`
+	Attempt      int        `json:"attempt"`
+	Status       string     `json:"status"`
+	ExitReason   string     `json:"exit_reason,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	SessionID    string     `json:"session_id,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	InputTokens  int        `json:"input_tokens,omitempty"`
+	OutputTokens int        `json:"output_tokens,omitempty"`
+}
+
+type webhookDelegationData struct {
+	DelegationID   string `json:"delegation_id"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+	OriginNode     string `json:"origin_node,omitempty"`
+	TargetNode     string `json:"target_node"`
+	AgentName      string `json:"agent_name,omitempty"`
+	ParentID       string `json:"parent_id,omitempty"`
+	Depth          int    `json:"depth"`
+	JobID          string `json:"job_id,omitempty"`
+	RunID          string `json:"run_id,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+type webhookCompactionData struct {
+	SessionID      string            `json:"session_id"`
+	Trigger        string            `json:"trigger"` // manual or auto
+	OriginalCount  int               `json:"original_messages"`
+	CompactedCount int               `json:"compacted_messages"`
+	Model          string            `json:"model,omitempty"`
+	Usage          *webhookUsageData `json:"usage,omitempty"`
+}
+
+// emitWebhookLocked forwards run events that webhooks subscribe to. Approval
+// and ask_user prompts pass through the run's event log, so this one hook
+// covers them too. Must be called with r.mu held.
+func (r *responseRun) emitWebhookLocked(event string, payload map[string]any) {
+	if r.webhooks == nil {
+		return
+	}
+	switch event {
+	case webhooks.EventResponseCompleted, webhooks.EventResponseFailed:
+		r.webhooks.Emit(event, webhookResponseData{
+			ResponseID: r.id,
+			SessionID:  r.sessionID,
+			Model:      r.model,
+			Status:     r.status,
+			ErrorType:  r.errorType,
+			Error:      r.errorMessage,
+			Usage:      webhookUsage(r.usage),
+			CreatedAt:  time.Unix(r.created, 0).UTC(),
+		})
+	case "response.approval.prompt":
+		kind := "file"
+		switch {
+		case boolValue(payload["is_workspace"]):
+			kind = "workspace"
+		case boolValue(payload["is_shell"]):
+			kind = "shell"
+		}
+		r.webhooks.Emit(webhooks.EventApprovalPending, webhookApprovalData{
+			SessionID:  r.sessionID,
+			ResponseID: r.id,
+			ApprovalID: stringValue(payload["approval_id"]),
+			Kind:       kind,
+			Title:      stringValue(payload["title"]),
+			Target:     stringValue(payload["path"]),
+			WorkDir:    stringValue(payload["work_dir"]),
+		})
+	case "response.ask_user.prompt":
+		r.webhooks.Emit(webhooks.EventAskUserPending, webhookAskUserData{
+			SessionID:  r.sessionID,
+			ResponseID: r.id,
+			CallID:     stringValue(payload["call_id"]),
+			Questions:  payload["questions"],
+		})
+	}
+}
+
+func boolValue(v any) bool {
+	b, _ := v.(bool)
+	return b
+}
+
+// observeJobRunWebhook emits job_run.finished for a run in a final status.
+func (s *serveServer) observeJobRunWebhook(run jobsV2Run) {
+	data := webhookJobRunData{
+		JobID:        run.JobID,
+		RunID:        run.ID,
+		Trigger:      run.Trigger,
+		Attempt:      run.Attempt,
+		Status:       string(run.Status),
+		ExitReason:   run.ExitReason,
+		Error:        run.Error,
+		SessionID:    run.SessionID,
+		StartedAt:    run.StartedAt,
+		FinishedAt:   run.FinishedAt,
+		InputTokens:  run.InputTokens,
+		OutputTokens: run.OutputTokens,
+	}
+	if job, err := s.jobsV2.GetJob(run.JobID); err == nil {
+		data.JobName = job.Name
+	}
+	s.webhooks.Emit(webhooks.EventJobRunFinished, data)
+}
+
+// emitCompactionWebhook emits session.compacted after a compaction has been
+// applied to sessionID.
+func (rt *serveRuntime) emitCompactionWebhook(sessionID, trigger string, result *llm.CompactionResult) {
+	if rt.webhooks == nil || result == nil {
+		return
+	}
+	rt.webhooks.Emit(webhooks.EventSessionCompacted, webhookCompactionData{
+		SessionID:      sessionID,
+		Trigger:        trigger,
+		OriginalCount:  result.OriginalCount,
+		CompactedCount: result.CompactedCount,
+		Model:          result.Model,
+		Usage:          webhookUsage(result.Usage),
+	})
+}
+
+// delegationWebhookObserver emits delegation.updated whenever a hub
+// delegation is created or changes status.
+func delegationWebhookObserver(dispatcher *webhooks.Dispatcher) func(previous string, d hub.Delegation) {
+	return func(previous string, d hub.Delegation) {
+		dispatcher.Emit(webhooks.EventDelegationUpdated, webhookDelegationData{
+			DelegationID:   d.ID,
+			Status:         d.Status,
+			PreviousStatus: previous,
+			OriginNode:     d.OriginNode,
+			TargetNode:     d.TargetNode,
+			AgentName:      d.AgentName,
+			ParentID:       d.ParentID,
+			Depth:          d.Depth,
+			JobID:          d.JobID,
+			RunID:          d.RunID,
+			Error:          d.Error,
+		})
+	}
+}
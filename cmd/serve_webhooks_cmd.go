@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/webhooks"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveWebhooksDBFlag       string
+	serveWebhooksListStatus   string
+	serveWebhooksListEndpoint string
+	serveWebhooksListEvent    string
+	serveWebhooksListLimit    int
+	serveWebhooksListJSON     bool
+)
+
+var serveWebhooksCmd = &cobra.Command{
+	Use:   "webhooks",
+	Short: "Inspect and retry outbound webhook deliveries",
+	Long: `Inspect and retry the outbound webhooks configured under serve.webhooks.
+
+Serve records every delivery in <data-dir>/serve_webhooks.db before sending
+it, retries failures with exponential backoff (up to 10 attempts over about
+two hours), and keeps finished deliveries for 7 days. The hub keeps its
+delegation deliveries in <data-dir>/hub/webhooks.db; pass --db to inspect it.
+
+Examples:
+  term-llm serve webhooks list
+  term-llm serve webhooks list --status failed --endpoint pagerduty
+  term-llm serve webhooks show whd_0123456789abcdef
+  term-llm serve webhooks retry whd_0123456789abcdef
+  term-llm serve webhooks test pagerduty`,
+}
+
+var serveWebhooksListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recent deliveries, newest first",
+	Args:  cobra.NoArgs,
+	RunE:  runServeWebhooksList,
+}
+
+var serveWebhooksShowCmd = &cobra.Command{
+	Use:   "show <delivery-id>",
+	Short: "Show a delivery and its payload",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runServeWebhooksShow,
+}
+
+var serveWebhooksRetryCmd = &cobra.Command{
+	Use:   "retry <delivery-id>",
+	Short: "Queue a delivery again; a running server sends it within seconds",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runServeWebhooksRetry,
+}
+
+var serveWebhooksTestCmd = &cobra.Command{
+	Use:   "test <endpoint>",
+	Short: "Send a signed ping event to a configured endpoint now",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runServeWebhooksTest,
+}
+
+func init() {
+	serveCmd.AddCommand(serveWebhooksCmd)
+	serveWebhooksCmd.AddCommand(serveWebhooksListCmd, serveWebhooksShowCmd, serveWebhooksRetryCmd, serveWebhooksTestCmd)
+
+	serveWebhooksCmd.PersistentFlags().StringVar(&serveWebhooksDBFlag, "db", "", "Delivery log path (default: <data-dir>/serve_webhooks.db)")
+
+	serveWebhooksListCmd.Flags().StringVar(&serveWebhooksListStatus, "status", "", "Only deliveries in this state: pending, delivered or failed")
+	serveWebhooksListCmd.Flags().StringVar(&serveWebhooksListEndpoint, "endpoint", "", "Only deliveries to this endpoint name")
+	serveWebhooksListCmd.Flags().StringVar(&serveWebhooksListEvent, "event", "", "Only deliveries of this event type")
+	serveWebhooksListCmd.Flags().IntVar(&serveWebhooksListLimit, "limit", 50, "Maximum deliveries to show (0 = all)")
+	serveWebhooksListCmd.Flags().BoolVar(&serveWebhooksListJSON, "json", false, "Output as JSON")
+}
+
+// openServeWebhooksCLIStore opens an existing delivery log. Unlike serve it
+// never creates one, so a typo in --db is reported instead of hidden.
+func openServeWebhooksCLIStore() (*webhooks.Store, error) {
+	path, err := resolveServeWebhooksPath(serveWebhooksDBFlag)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no webhook delivery log at %s (configure serve.webhooks and start serve)", path)
+	}
+	return webhooks.Open(path)
+}
+
+func runServeWebhooksList(cmd *cobra.Command, args []string) error {
+	switch serveWebhooksListStatus {
+	case "", webhooks.StatusPending, webhooks.StatusDelivered, webhooks.StatusFailed:
+	default:
+		return fmt.Errorf("invalid --status %q (expected pending, delivered or failed)", serveWebhooksListStatus)
+	}
+	store, err := openServeWebhooksCLIStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	deliveries, err := store.List(cmd.Context(), webhooks.ListOptions{
+		Status:   serveWebhooksListStatus,
+		Endpoint: serveWebhooksListEndpoint,
+		Event:    serveWebhooksListEvent,
+		Limit:    serveWebhooksListLimit,
+	})
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if serveWebhooksListJSON {
+		if deliveries == nil {
+			deliveries = []webhooks.Delivery{}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(deliveries)
+	}
+	if len(deliveries) == 0 {
+		fmt.Fprintln(out, "No webhook deliveries.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tEVENT\tENDPOINT\tSTATUS\tATTEMPTS\tLAST\tCREATED")
+	for _, d := range deliveries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			d.ID, d.EventType, d.Endpoint, formatWebhookStatus(d), d.Attempts, formatWebhookLastResult(d), d.CreatedAt.Local().Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+func runServeWebhooksShow(cmd *cobra.Command, args []string) error {
+	store, err := openServeWebhooksCLIStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	d, err := store.Get(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "id:        %s\n", d.ID)
+	fmt.Fprintf(out, "event:     %s (%s)\n", d.EventType, d.EventID)
+	fmt.Fprintf(out, "endpoint:  %s %s\n", d.Endpoint, d.URL)
+	fmt.Fprintf(out, "status:    %s\n", formatWebhookStatus(d))
+	fmt.Fprintf(out, "attempts:  %d\n", d.Attempts)
+	if d.Attempts > 0 {
+		fmt.Fprintf(out, "last:      %s\n", formatWebhookLastResult(d))
+	}
+	if d.LastError != "" {
+		fmt.Fprintf(out, "error:     %s\n", d.LastError)
+	}
+	fmt.Fprintf(out, "created:   %s\n", d.CreatedAt.Local().Format(time.DateTime))
+	if !d.DeliveredAt.IsZero() {
+		fmt.Fprintf(out, "delivered: %s\n", d.DeliveredAt.Local().Format(time.DateTime))
+	}
+	fmt.Fprintln(out)
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, d.Payload, "", "  "); err != nil {
+		pretty.Reset()
+		pretty.Write(d.Payload)
+	}
+	fmt.Fprintln(out, pretty.String())
+	return nil
+}
+
+func runServeWebhooksRetry(cmd *cobra.Command, args []string) error {
+	store, err := openServeWebhooksCLIStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	d, err := store.Retry(cmd.Context(), args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Queued %s delivery %s to %s\n", d.EventType, d.ID, d.Endpoint)
+	return nil
+}
+
+func runServeWebhooksTest(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	endpoints, err := serveWebhookEndpoints(cfg.Serve.Webhooks)
+	if err != nil {
+		return err
+	}
+	var ep *webhooks.Endpoint
+	for i := range endpoints {
+		if endpoints[i].Name == args[0] {
+			ep = &endpoints[i]
+		}
+	}
+	if ep == nil {
+		return fmt.Errorf("no webhook named %q in serve.webhooks", args[0])
+	}
+
+	event, err := webhooks.NewEvent(webhooks.EventPing, map[string]any{"endpoint": ep.Name})
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	code, err := webhooks.Post(cmd.Context(), client, *ep, "test_"+event.ID, event.Type, payload)
+	if err != nil {
+		return fmt.Errorf("ping %s: %w", ep.Name, err)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Delivered ping to %s (HTTP %d)\n", ep.Name, code)
+	return nil
+}
+
+func formatWebhookStatus(d webhooks.Delivery) string {
+	if d.Status == webhooks.StatusPending && d.Attempts > 0 && !d.NextAttemptAt.IsZero() {
+		return "retrying at " + d.NextAttemptAt.Local().Format(time.TimeOnly)
+	}
+	return d.Status
+}
+
+func formatWebhookLastResult(d webhooks.Delivery) string {
+	switch {
+	case d.Attempts == 0:
+		return "-"
+	case d.LastCode > 0:
+		return "HTTP " + strconv.Itoa(d.LastCode)
+	default:
+		return "no response"
+	}
+}
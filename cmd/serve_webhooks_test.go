@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/webhooks"
+)
+
+func TestServeWebhookEndpointsValidatesConfig(t *testing.T) {
+	endpoints, err := serveWebhookEndpoints([]config.ServeWebhook{{Name: " ops ", URL: " https://ops.example/hook ", Events: []string{"response.*"}}})
+	if err != nil {
+		t.Fatalf("serveWebhookEndpoints: %v", err)
+	}
+	if endpoints[0].Name != "ops" || endpoints[0].URL != "https://ops.example/hook" {
+		t.Fatalf("endpoints = %+v", endpoints)
+	}
+	if _, err := serveWebhookEndpoints([]config.ServeWebhook{{Name: "ops", URL: "https://ops.example", Events: []string{"tool.finished"}}}); err == nil {
+		t.Fatal("unknown event should be rejected")
+	}
+}
+
+func TestResponseRunEmitsWebhooks(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	store, err := webhooks.Open(filepath.Join(t.TempDir(), "webhooks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	dispatcher := webhooks.NewDispatcher(store, []webhooks.Endpoint{{Name: "ops", URL: srv.URL}})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		dispatcher.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	mgr := newServeResponseRunManager()
+	mgr.webhooks = dispatcher
+	run := newResponseRun("resp_hook", "sess_hook", "", "mock-model", time.Now().Unix(), nil)
+	if err := mgr.create(run); err != nil {
+		t.Fatal(err)
+	}
+	// Ordinary stream events are not forwarded.
+	if err := run.appendEvent("response.output_text.delta", map[string]any{"delta": "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := run.appendEvent("response.approval.prompt", map[string]any{
+		"approval_id": "appr_1",
+		"path":        "rm -rf build",
+		"is_shell":    true,
+		"title":       "Run command",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := run.complete(map[string]any{"response": map[string]any{"id": "resp_hook", "status": "completed"}}, llm.Usage{InputTokens: 12, OutputTokens: 3}, llm.Usage{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var deliveries []webhooks.Delivery
+	deadline := time.Now().Add(5 * time.Second)
+	for len(deliveries) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for deliveries; have %+v", deliveries)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if deliveries, err = store.List(context.Background(), webhooks.ListOptions{Status: webhooks.StatusDelivered}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(deliveries) != 2 {
+		t.Fatalf("deliveries = %+v, want 2", deliveries)
+	}
+
+	byType := map[string]webhooks.Event{}
+	for _, d := range deliveries {
+		var event webhooks.Event
+		if err := json.Unmarshal(d.Payload, &event); err != nil {
+			t.Fatal(err)
+		}
+		byType[event.Type] = event
+	}
+
+	var approval webhookApprovalData
+	if err := json.Unmarshal(byType[webhooks.EventApprovalPending].Data, &approval); err != nil {
+		t.Fatal(err)
+	}
+	if approval.SessionID != "sess_hook" || approval.ResponseID != "resp_hook" || approval.ApprovalID != "appr_1" || approval.Kind != "shell" || approval.Target != "rm -rf build" {
+		t.Fatalf("approval data = %+v", approval)
+	}
+
+	var completed webhookResponseData
+	if err := json.Unmarshal(byType[webhooks.EventResponseCompleted].Data, &completed); err != nil {
+		t.Fatal(err)
+	}
+	if completed.SessionID != "sess_hook" || completed.Status != "completed" || completed.Model != "mock-model" || completed.Usage == nil || completed.Usage.InputTokens != 12 {
+		t.Fatalf("completed data = %+v", completed)
+	}
+}
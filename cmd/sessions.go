@@ -538,7 +538,7 @@ func sessionExportReasoningSurface(sess *session.Session) string {
 	if sess == nil {
 		return "chat"
 	}
-	if sess.Origin == session.OriginWeb || sess.Origin == session.OriginTelegram {
+	if sess.Origin.IsServe() {
 		return "serve"
 	}
 	switch sess.Mode {
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/tracing"
+)
+
+var traceEndpoint string
+var traceFile string
+var tracingShutdown func(context.Context) error
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&traceEndpoint, "trace-endpoint", "", "Export OpenTelemetry traces to an OTLP/HTTP collector (e.g. http://localhost:4318)")
+	rootCmd.PersistentFlags().StringVar(&traceFile, "trace-file", "", "Append OpenTelemetry spans to a JSON lines file")
+}
+
+// startTracing installs the OpenTelemetry exporter chosen by flags, the
+// tracing config section or the standard OTEL_* environment. Flags override
+// the config. It does nothing unless one of them selects an exporter, and a
+// setup failure is only a warning: tracing never stops a command from
+// running.
+func startTracing() {
+	var cfg tracing.Config
+	if loaded, err := config.LoadTracing(); err == nil {
+		cfg = tracing.Config{
+			Endpoint:    loaded.Endpoint,
+			Headers:     loaded.Headers,
+			File:        loaded.File,
+			ServiceName: loaded.ServiceName,
+			SampleRatio: loaded.SampleRatio,
+		}
+	}
+	if traceEndpoint != "" {
+		cfg.Endpoint = traceEndpoint
+	}
+	if traceFile != "" {
+		cfg.File = traceFile
+	}
+	if !cfg.HasExporter() {
+		return
+	}
+	shutdown, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: tracing disabled: %v\n", err)
+		return
+	}
+	tracingShutdown = shutdown
+}
+
+// stopTracing flushes buffered spans. It runs after every command, including
+// failed ones, so traces of errors are not lost.
+func stopTracing() {
+	if tracingShutdown == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracingShutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: trace export shutdown error: %v\n", err)
+	}
+	tracingShutdown = nil
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/samsaffron/term-llm/internal/tracing"
+)
+
+func TestStartTracingIsOptional(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	oldEndpoint, oldFile := traceEndpoint, traceFile
+	t.Cleanup(func() {
+		traceEndpoint, traceFile = oldEndpoint, oldFile
+		stopTracing()
+	})
+
+	// Nothing configured: no exporter and nothing to shut down.
+	traceEndpoint, traceFile = "", ""
+	startTracing()
+	if tracingShutdown != nil || tracing.Enabled() {
+		t.Fatal("startTracing installed an exporter without any tracing configured")
+	}
+
+	// A broken exporter is a warning, not a failed command.
+	traceFile = filepath.Join(t.TempDir(), "missing", "spans.jsonl")
+	startTracing()
+	if tracingShutdown != nil || tracing.Enabled() {
+		t.Fatal("startTracing installed an exporter after setup failed")
+	}
+}
@@ -27,6 +27,7 @@ var (
 	usageJSON              bool
 	usageBreakdown         bool
 	usageIncludeExternal   bool
+	usageAPIKey            string
 	usageCopilotScope      string
 	usageCopilotEntity     string
 	usageCopilotYear       int
@@ -67,7 +68,8 @@ Examples:
   term-llm usage --provider term-llm          # show term-llm direct API usage
   term-llm usage --since 20250101             # from Jan 1, 2025
   term-llm usage --json                       # output as JSON
-  term-llm usage --breakdown                  # show per-model breakdown`,
+  term-llm usage --breakdown                  # show per-model breakdown
+  term-llm usage --api-key ci                 # usage of the serve API key named ci`,
 	RunE: runUsage,
 }
 
@@ -78,6 +80,7 @@ func init() {
 	usageCmd.Flags().StringVar(&usageUntil, "until", "", "End date (YYYYMMDD)")
 	usageCmd.Flags().BoolVar(&usageJSON, "json", false, "Output as JSON")
 	usageCmd.Flags().BoolVar(&usageBreakdown, "breakdown", false, "Show per-model breakdown")
+	usageCmd.Flags().StringVar(&usageAPIKey, "api-key", "", "Filter term-llm usage to a named serve API key")
 	usageCmd.Flags().BoolVar(&usageIncludeExternal, "include-external", false, "Include externally-tracked term-llm usage (claude-bin and codex calls)")
 	usageCmd.Flags().StringVar(&usageCopilotScope, "copilot-scope", "user", "Copilot billing scope (user, org, enterprise)")
 	usageCmd.Flags().StringVar(&usageCopilotEntity, "copilot-entity", "", "Copilot billing entity (username, organization, or enterprise slug; defaults to authenticated user for user scope)")
@@ -174,6 +177,7 @@ func runUsage(cmd *cobra.Command, args []string) error {
 		Until:           until,
 		Provider:        providerFilter,
 		IncludeExternal: usageIncludeExternal,
+		APIKey:          usageAPIKey,
 	})
 
 	if len(filtered) == 0 {
@@ -21,11 +21,15 @@ var validCustomToolNameRE = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
 type PlatformMessagesConfig struct {
 	Web      string `yaml:"web_developer_message,omitempty"`
 	Telegram string `yaml:"telegram_developer_message,omitempty"`
+	Slack    string `yaml:"slack_developer_message,omitempty"`
+	Discord  string `yaml:"discord_developer_message,omitempty"`
+	Email    string `yaml:"email_developer_message,omitempty"`
+	Matrix   string `yaml:"matrix_developer_message,omitempty"`
 	Chat     string `yaml:"chat_developer_message,omitempty"`
 	Jobs     string `yaml:"jobs_developer_message,omitempty"`
 }
 
-// For returns the developer message for the given platform name ("web", "telegram", "chat", "jobs").
+// For returns the developer message for the given platform name ("web", "telegram", "slack", "discord", "email", "matrix", "chat", "jobs").
 // Returns empty string when no message is configured for that platform.
 func (p PlatformMessagesConfig) For(platform string) string {
 	switch platform {
@@ -33,6 +37,14 @@ func (p PlatformMessagesConfig) For(platform string) string {
 		return p.Web
 	case "telegram":
 		return p.Telegram
+	case "slack":
+		return p.Slack
+	case "discord":
+		return p.Discord
+	case "email":
+		return p.Email
+	case "matrix":
+		return p.Matrix
 	case "chat":
 		return p.Chat
 	case "jobs":
@@ -0,0 +1,517 @@
+// Package apikeys stores named serve API keys with scopes and quotas.
+//
+// Only a SHA-256 hash of each key is persisted; the plaintext is returned
+// once by Create. Usage is accumulated per key per UTC day so daily and
+// monthly quotas can be checked without replaying the usage logs.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// KeyPrefix starts every generated key so they are easy to recognise in
+// configs and secret scanners.
+const KeyPrefix = "tlk_"
+
+const schemaVersion = 1
+
+var (
+	ErrNotFound  = errors.New("api key not found")
+	ErrRevoked   = errors.New("api key has been revoked")
+	ErrDuplicate = errors.New("an active api key with that name already exists")
+)
+
+// Quota periods.
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+// Approval modes a key may be scoped to; they match the serve --approval values.
+var ApprovalModes = []string{"prompt", "auto", "yolo"}
+
+// Scopes restrict what a key may do. An empty list allows everything.
+type Scopes struct {
+	// Endpoints are request paths below the base path, such as
+	// /v1/chat/completions. A trailing * matches any suffix (/v2/*).
+	Endpoints     []string `json:"endpoints,omitempty"`
+	Agents        []string `json:"agents,omitempty"`
+	Providers     []string `json:"providers,omitempty"`
+	Tools         []string `json:"tools,omitempty"`
+	ApprovalModes []string `json:"approval_modes,omitempty"`
+}
+
+// Quota limits a key's request rate and its token and spend totals per
+// period. Zero values are unlimited.
+type Quota struct {
+	RequestsPerMinute int     `json:"requests_per_minute,omitempty"`
+	Tokens            int64   `json:"tokens,omitempty"`
+	SpendUSD          float64 `json:"spend_usd,omitempty"`
+	Period            string  `json:"period,omitempty"`
+}
+
+// Key is a stored API key without its secret.
+type Key struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	Scopes     Scopes    `json:"scopes"`
+	Quota      Quota     `json:"quota"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+	RevokedAt  time.Time `json:"revoked_at,omitzero"`
+}
+
+// Revoked reports whether the key has been revoked.
+func (k Key) Revoked() bool { return !k.RevokedAt.IsZero() }
+
+// Usage is what a key consumed over some window.
+type Usage struct {
+	Requests     int64   `json:"requests"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// TotalTokens returns input plus output tokens.
+func (u Usage) TotalTokens() int64 { return u.InputTokens + u.OutputTokens }
+
+// CreateOptions describes a new key.
+type CreateOptions struct {
+	Name   string
+	Scopes Scopes
+	Quota  Quota
+}
+
+// Store is a SQLite-backed API key store. It is safe to share between a
+// running server and the keys CLI.
+type Store struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	secret_hash TEXT NOT NULL UNIQUE,
+	scopes TEXT NOT NULL DEFAULT '{}',
+	quota TEXT NOT NULL DEFAULT '{}',
+	created_at INTEGER NOT NULL,
+	last_used_at INTEGER,
+	revoked_at INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS api_key_usage (
+	key_id TEXT NOT NULL REFERENCES api_keys(id),
+	day TEXT NOT NULL,
+	requests INTEGER NOT NULL DEFAULT 0,
+	input_tokens INTEGER NOT NULL DEFAULT 0,
+	output_tokens INTEGER NOT NULL DEFAULT 0,
+	cost_usd REAL NOT NULL DEFAULT 0,
+	PRIMARY KEY (key_id, day)
+);
+`
+
+// DefaultPath returns the key store location inside dataDir.
+func DefaultPath(dataDir string) string {
+	return filepath.Join(dataDir, "serve_keys.db")
+}
+
+// Open opens (creating if necessary) the key store at path.
+func Open(path string) (*Store, error) {
+	if path != ":memory:" {
+		if err := preparePrivateDBFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	dsn := path
+	if strings.Contains(dsn, "?") {
+		dsn += "&"
+	} else {
+		dsn += "?"
+	}
+	dsn += "_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open api key database: %w", err)
+	}
+	// Usage updates arrive from every request; one connection serializes
+	// them before they reach SQLite's single-writer lock.
+	db.SetMaxOpenConns(1)
+
+	if err := initSchema(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initialize api key schema: %w", err)
+	}
+	if path != ":memory:" {
+		if err := chmodSQLiteFiles(path); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &Store{db: db, now: time.Now}, nil
+}
+
+func preparePrivateDBFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create api key data directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("create api key database: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("close api key database: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("secure api key database permissions: %w", err)
+	}
+	return nil
+}
+
+func chmodSQLiteFiles(path string) error {
+	for _, candidate := range []string{path, path + "-wal", path + "-shm"} {
+		if err := os.Chmod(candidate, 0600); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("secure api key sqlite file permissions: %w", err)
+		}
+	}
+	return nil
+}
+
+func initSchema(db *sql.DB) error {
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+	var version int
+	err := db.QueryRow("SELECT version FROM schema_version LIMIT 1").Scan(&version)
+	if err == sql.ErrNoRows {
+		_, err = db.Exec("INSERT INTO schema_version (version) VALUES (?)", schemaVersion)
+		return err
+	}
+	return err
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Create stores a new key and returns it with its plaintext secret, which
+// is not recoverable afterwards.
+func (s *Store) Create(ctx context.Context, opts CreateOptions) (Key, string, error) {
+	name := strings.TrimSpace(opts.Name)
+	if name == "" {
+		return Key{}, "", fmt.Errorf("key name is required")
+	}
+	if err := ValidateScopes(opts.Scopes); err != nil {
+		return Key{}, "", err
+	}
+	quota, err := normalizeQuota(opts.Quota)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	var exists int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM api_keys WHERE name = ? AND revoked_at IS NULL`, name).Scan(&exists); err != nil {
+		return Key{}, "", err
+	}
+	if exists > 0 {
+		return Key{}, "", fmt.Errorf("%w: %s", ErrDuplicate, name)
+	}
+
+	id, err := randomString(6)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secretPart, err := randomString(24)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret := KeyPrefix + secretPart
+	key := Key{
+		ID:        "key_" + id,
+		Name:      name,
+		Prefix:    secret[:len(KeyPrefix)+6],
+		Scopes:    opts.Scopes,
+		Quota:     quota,
+		CreatedAt: s.now().UTC().Truncate(time.Second),
+	}
+	scopes, _ := json.Marshal(key.Scopes)
+	quotaJSON, _ := json.Marshal(key.Quota)
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO api_keys (id, name, prefix, secret_hash, scopes, quota, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		key.ID, key.Name, key.Prefix, hashSecret(secret), string(scopes), string(quotaJSON), key.CreatedAt.Unix()); err != nil {
+		return Key{}, "", fmt.Errorf("insert api key: %w", err)
+	}
+	return key, secret, nil
+}
+
+// List returns all keys, oldest first. Revoked keys are included only when
+// includeRevoked is set.
+func (s *Store) List(ctx context.Context, includeRevoked bool) ([]Key, error) {
+	query := `SELECT id, name, prefix, scopes, quota, created_at, last_used_at, revoked_at FROM api_keys`
+	if !includeRevoked {
+		query += ` WHERE revoked_at IS NULL`
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY created_at, rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []Key
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Get returns the key with the given ID, or the active key with the given
+// name.
+func (s *Store) Get(ctx context.Context, idOrName string) (Key, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT id, name, prefix, scopes, quota, created_at, last_used_at, revoked_at FROM api_keys
+		WHERE id = ? OR (name = ? AND revoked_at IS NULL) ORDER BY revoked_at IS NULL DESC LIMIT 1`, idOrName, idOrName)
+	key, err := scanKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Key{}, fmt.Errorf("%w: %s", ErrNotFound, idOrName)
+	}
+	return key, err
+}
+
+// Revoke revokes a key by ID or name. Revoking twice is not an error.
+func (s *Store) Revoke(ctx context.Context, idOrName string) (Key, error) {
+	key, err := s.Get(ctx, idOrName)
+	if err != nil {
+		return Key{}, err
+	}
+	if key.Revoked() {
+		return key, nil
+	}
+	key.RevokedAt = s.now().UTC().Truncate(time.Second)
+	if _, err := s.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = ? WHERE id = ?`, key.RevokedAt.Unix(), key.ID); err != nil {
+		return Key{}, fmt.Errorf("revoke api key: %w", err)
+	}
+	return key, nil
+}
+
+// Authenticate looks up the key for a presented secret. It returns
+// ErrNotFound for unknown secrets and ErrRevoked for revoked keys.
+func (s *Store) Authenticate(ctx context.Context, secret string) (Key, error) {
+	if !strings.HasPrefix(secret, KeyPrefix) {
+		return Key{}, ErrNotFound
+	}
+	row := s.db.QueryRowContext(ctx, `SELECT id, name, prefix, scopes, quota, created_at, last_used_at, revoked_at FROM api_keys WHERE secret_hash = ?`, hashSecret(secret))
+	key, err := scanKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Key{}, ErrNotFound
+	}
+	if err != nil {
+		return Key{}, err
+	}
+	if key.Revoked() {
+		return key, ErrRevoked
+	}
+	return key, nil
+}
+
+// RecordUsage adds usage to the key's total for the current UTC day and
+// updates its last-used time.
+func (s *Store) RecordUsage(ctx context.Context, keyID string, usage Usage) error {
+	now := s.now().UTC()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `INSERT INTO api_key_usage (key_id, day, requests, input_tokens, output_tokens, cost_usd) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(key_id, day) DO UPDATE SET
+			requests = requests + excluded.requests,
+			input_tokens = input_tokens + excluded.input_tokens,
+			output_tokens = output_tokens + excluded.output_tokens,
+			cost_usd = cost_usd + excluded.cost_usd`,
+		keyID, now.Format(time.DateOnly), usage.Requests, usage.InputTokens, usage.OutputTokens, usage.CostUSD); err != nil {
+		return fmt.Errorf("record api key usage: %w", err)
+	}
+	if usage.Requests > 0 {
+		if _, err := tx.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, now.Unix(), keyID); err != nil {
+			return fmt.Errorf("record api key usage: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// UsageSince sums a key's usage from the UTC day containing since.
+func (s *Store) UsageSince(ctx context.Context, keyID string, since time.Time) (Usage, error) {
+	var u Usage
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(requests), 0), COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0), COALESCE(SUM(cost_usd), 0)
+		FROM api_key_usage WHERE key_id = ? AND day >= ?`, keyID, since.UTC().Format(time.DateOnly)).Scan(&u.Requests, &u.InputTokens, &u.OutputTokens, &u.CostUSD)
+	return u, err
+}
+
+// PeriodStart returns the start of the quota period containing now.
+func PeriodStart(period string, now time.Time) time.Time {
+	now = now.UTC()
+	if period == PeriodMonth {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// QuotaUsage returns the key's usage in its current quota period.
+func (s *Store) QuotaUsage(ctx context.Context, key Key) (Usage, error) {
+	return s.UsageSince(ctx, key.ID, PeriodStart(key.Quota.Period, s.now()))
+}
+
+// ExceededQuota returns a description of the first exhausted quota, or ""
+// when usage is within every limit.
+func ExceededQuota(quota Quota, usage Usage) string {
+	period := quota.Period
+	if period == "" {
+		period = PeriodDay
+	}
+	if quota.Tokens > 0 && usage.TotalTokens() >= quota.Tokens {
+		return fmt.Sprintf("token quota of %d per %s exhausted", quota.Tokens, period)
+	}
+	if quota.SpendUSD > 0 && usage.CostUSD >= quota.SpendUSD {
+		return fmt.Sprintf("spend quota of $%.2f per %s exhausted", quota.SpendUSD, period)
+	}
+	return ""
+}
+
+// ValidateScopes checks scope values that have a fixed vocabulary.
+func ValidateScopes(scopes Scopes) error {
+	for _, endpoint := range scopes.Endpoints {
+		if !strings.HasPrefix(endpoint, "/") {
+			return fmt.Errorf("endpoint scope %q must start with /", endpoint)
+		}
+	}
+	for _, mode := range scopes.ApprovalModes {
+		if !slices.Contains(ApprovalModes, mode) {
+			return fmt.Errorf("invalid approval mode %q (allowed: %s)", mode, strings.Join(ApprovalModes, ", "))
+		}
+	}
+	return nil
+}
+
+func normalizeQuota(quota Quota) (Quota, error) {
+	if quota.RequestsPerMinute < 0 || quota.Tokens < 0 || quota.SpendUSD < 0 {
+		return quota, fmt.Errorf("quotas must not be negative")
+	}
+	switch quota.Period {
+	case "":
+		if quota.Tokens > 0 || quota.SpendUSD > 0 {
+			quota.Period = PeriodDay
+		}
+	case PeriodDay, PeriodMonth:
+	default:
+		return quota, fmt.Errorf("invalid quota period %q (allowed: day, month)", quota.Period)
+	}
+	return quota, nil
+}
+
+// AllowsEndpoint reports whether the scopes permit a request path.
+func (s Scopes) AllowsEndpoint(path string) bool {
+	if len(s.Endpoints) == 0 {
+		return true
+	}
+	for _, pattern := range s.Endpoints {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == pattern || path == pattern+"/" {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsAgent reports whether the scopes permit an agent name.
+func (s Scopes) AllowsAgent(agent string) bool {
+	return len(s.Agents) == 0 || slices.Contains(s.Agents, agent)
+}
+
+// AllowsProvider reports whether the scopes permit a provider. A provider
+// scope also matches provider:model names, so "anthropic" allows
+// "anthropic:claude-sonnet-4-5".
+func (s Scopes) AllowsProvider(provider string) bool {
+	if len(s.Providers) == 0 {
+		return true
+	}
+	name, _, _ := strings.Cut(provider, ":")
+	return slices.Contains(s.Providers, provider) || slices.Contains(s.Providers, name)
+}
+
+// AllowsApprovalMode reports whether the scopes permit running under an
+// approval mode.
+func (s Scopes) AllowsApprovalMode(mode string) bool {
+	return len(s.ApprovalModes) == 0 || slices.Contains(s.ApprovalModes, mode)
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanKey(row rowScanner) (Key, error) {
+	var (
+		key                   Key
+		scopes, quota         string
+		createdAt             int64
+		lastUsedAt, revokedAt sql.NullInt64
+	)
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &scopes, &quota, &createdAt, &lastUsedAt, &revokedAt); err != nil {
+		return Key{}, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return Key{}, fmt.Errorf("decode scopes for %s: %w", key.ID, err)
+	}
+	if err := json.Unmarshal([]byte(quota), &key.Quota); err != nil {
+		return Key{}, fmt.Errorf("decode quota for %s: %w", key.ID, err)
+	}
+	key.CreatedAt = time.Unix(createdAt, 0).UTC()
+	if lastUsedAt.Valid {
+		key.LastUsedAt = time.Unix(lastUsedAt.Int64, 0).UTC()
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = time.Unix(revokedAt.Int64, 0).UTC()
+	}
+	return key, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate api key: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
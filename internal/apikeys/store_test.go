@@ -0,0 +1,108 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(filepath.Join(t.TempDir(), "keys.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	key, secret, err := store.Create(ctx, CreateOptions{
+		Name:   "ci",
+		Scopes: Scopes{Endpoints: []string{"/v1/chat/completions"}, Providers: []string{"openai"}},
+		Quota:  Quota{Tokens: 1000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, KeyPrefix) || !strings.HasPrefix(secret, key.Prefix) || key.Quota.Period != PeriodDay {
+		t.Fatalf("key = %+v secret = %q", key, secret)
+	}
+	if _, _, err := store.Create(ctx, CreateOptions{Name: "ci"}); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("duplicate name err = %v", err)
+	}
+
+	got, err := store.Authenticate(ctx, secret)
+	if err != nil || got.ID != key.ID || got.Scopes.Providers[0] != "openai" {
+		t.Fatalf("Authenticate = %+v, %v", got, err)
+	}
+	if _, err := store.Authenticate(ctx, secret+"x"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("wrong secret err = %v", err)
+	}
+
+	if err := store.RecordUsage(ctx, key.ID, Usage{Requests: 1, InputTokens: 600, OutputTokens: 500, CostUSD: 0.25}); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := store.QuotaUsage(ctx, key)
+	if err != nil || usage.Requests != 1 || usage.TotalTokens() != 1100 {
+		t.Fatalf("usage = %+v, %v", usage, err)
+	}
+	if msg := ExceededQuota(key.Quota, usage); !strings.Contains(msg, "token quota of 1000 per day") {
+		t.Fatalf("ExceededQuota = %q", msg)
+	}
+
+	// A new day starts a new daily quota.
+	now = now.Add(2 * time.Hour)
+	if usage, _ := store.QuotaUsage(ctx, key); usage.TotalTokens() != 0 {
+		t.Fatalf("next-day usage = %+v", usage)
+	}
+	if monthly, _ := store.UsageSince(ctx, key.ID, PeriodStart(PeriodMonth, now.AddDate(0, 0, -1))); monthly.TotalTokens() != 1100 {
+		t.Fatalf("monthly usage = %+v", monthly)
+	}
+
+	if _, err := store.Revoke(ctx, "ci"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Authenticate(ctx, secret); !errors.Is(err, ErrRevoked) {
+		t.Fatalf("revoked err = %v", err)
+	}
+	if keys, _ := store.List(ctx, false); len(keys) != 0 {
+		t.Fatalf("active keys = %+v", keys)
+	}
+	keys, err := store.List(ctx, true)
+	if err != nil || len(keys) != 1 || !keys[0].Revoked() || keys[0].LastUsedAt.IsZero() {
+		t.Fatalf("all keys = %+v, %v", keys, err)
+	}
+	// The name is free again once the old key is revoked.
+	if _, _, err := store.Create(ctx, CreateOptions{Name: "ci"}); err != nil {
+		t.Fatalf("recreate after revoke: %v", err)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	s := Scopes{
+		Endpoints:     []string{"/v1/chat/completions", "/v2/*"},
+		Providers:     []string{"anthropic"},
+		ApprovalModes: []string{"prompt"},
+	}
+	for path, want := range map[string]bool{
+		"/v1/chat/completions": true,
+		"/v2/jobs/abc":         true,
+		"/v1/responses":        false,
+	} {
+		if got := s.AllowsEndpoint(path); got != want {
+			t.Errorf("AllowsEndpoint(%q) = %v", path, got)
+		}
+	}
+	if !s.AllowsProvider("anthropic:claude-sonnet-4-5") || s.AllowsProvider("openai") {
+		t.Error("provider scope mismatch")
+	}
+	if s.AllowsApprovalMode("yolo") || !s.AllowsAgent("anything") {
+		t.Error("approval or agent scope mismatch")
+	}
+	if err := ValidateScopes(Scopes{ApprovalModes: []string{"always"}}); err == nil {
+		t.Error("expected invalid approval mode error")
+	}
+}
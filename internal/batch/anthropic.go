@@ -0,0 +1,461 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAnthropicBaseURL is the Anthropic API root used when none is
+// configured.
+const DefaultAnthropicBaseURL = "https://api.anthropic.com"
+
+const (
+	anthropicVersion          = "2023-06-01"
+	anthropicDefaultMaxTokens = 4096
+)
+
+// Anthropic submits chat completions lines to the Anthropic Message Batches
+// API, converting each to a Messages request and each result back to a chat
+// completion. Lines that do not convert cleanly (tool results, images,
+// response_format, ...) are left for the caller to run one at a time.
+type Anthropic struct {
+	APIKey  string
+	BaseURL string
+	Client  *http.Client
+}
+
+type anthropicBatch struct {
+	ID               string `json:"id"`
+	ProcessingStatus string `json:"processing_status"`
+	ResultsURL       string `json:"results_url"`
+	RequestCounts    struct {
+		Processing int `json:"processing"`
+		Succeeded  int `json:"succeeded"`
+		Errored    int `json:"errored"`
+		Canceled   int `json:"canceled"`
+		Expired    int `json:"expired"`
+	} `json:"request_counts"`
+}
+
+func (c *Anthropic) Name() string { return "anthropic" }
+
+func (c *Anthropic) Supports(endpoint string, req Request) bool {
+	if endpoint != EndpointChatCompletions {
+		return false
+	}
+	_, err := ChatToAnthropic(req.Body)
+	return err == nil
+}
+
+func (c *Anthropic) baseURL() string {
+	if base := strings.TrimRight(strings.TrimSpace(c.BaseURL), "/"); base != "" {
+		return base
+	}
+	return DefaultAnthropicBaseURL
+}
+
+func (c *Anthropic) header() http.Header {
+	return http.Header{
+		"X-Api-Key":         {c.APIKey},
+		"Anthropic-Version": {anthropicVersion},
+	}
+}
+
+// anthropicCustomID maps a line's position to a custom_id. Anthropic only
+// accepts short [a-zA-Z0-9_-] IDs, so caller IDs are not sent as-is.
+func anthropicCustomID(i int) string {
+	return "req-" + strconv.Itoa(i)
+}
+
+func (c *Anthropic) Submit(ctx context.Context, endpoint string, reqs []Request) (string, error) {
+	if endpoint != EndpointChatCompletions {
+		return "", fmt.Errorf("anthropic batches only support %s", EndpointChatCompletions)
+	}
+	type item struct {
+		CustomID string          `json:"custom_id"`
+		Params   json.RawMessage `json:"params"`
+	}
+	items := make([]item, 0, len(reqs))
+	for i, req := range reqs {
+		params, err := ChatToAnthropic(req.Body)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", req.CustomID, err)
+		}
+		items = append(items, item{CustomID: anthropicCustomID(i), Params: params})
+	}
+	var created anthropicBatch
+	err := doJSON(ctx, c.Client, http.MethodPost, c.baseURL()+"/v1/messages/batches", c.header(), map[string]any{"requests": items}, &created)
+	if err != nil {
+		return "", fmt.Errorf("create message batch: %w", err)
+	}
+	return created.ID, nil
+}
+
+func (c *Anthropic) get(ctx context.Context, remoteID string) (anthropicBatch, error) {
+	var b anthropicBatch
+	err := doJSON(ctx, c.Client, http.MethodGet, c.baseURL()+"/v1/messages/batches/"+url.PathEscape(remoteID), c.header(), nil, &b)
+	return b, err
+}
+
+func (c *Anthropic) Poll(ctx context.Context, remoteID string) (RemoteStatus, error) {
+	b, err := c.get(ctx, remoteID)
+	if err != nil {
+		return RemoteStatus{}, err
+	}
+	n := b.RequestCounts
+	failed := n.Errored + n.Canceled + n.Expired
+	return RemoteStatus{
+		Status: b.ProcessingStatus,
+		Done:   b.ProcessingStatus == "ended",
+		Counts: Counts{Total: n.Processing + n.Succeeded + failed, Completed: n.Succeeded, Failed: failed},
+	}, nil
+}
+
+func (c *Anthropic) Results(ctx context.Context, remoteID string, reqs []Request) ([]Result, error) {
+	b, err := c.get(ctx, remoteID)
+	if err != nil {
+		return nil, err
+	}
+	if b.ResultsURL == "" {
+		return nil, errors.New("message batch has no results yet")
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, b.ResultsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header = c.header()
+	resp, err := send(c.Client, httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("download message batch results: %w", err)
+	}
+	defer resp.Body.Close()
+
+	byID := make(map[string]Request, len(reqs))
+	for i, req := range reqs {
+		byID[anthropicCustomID(i)] = req
+	}
+	var results []Result
+	err = scanLines(resp.Body, func(n int, line []byte) error {
+		var item struct {
+			CustomID string `json:"custom_id"`
+			Result   struct {
+				Type    string          `json:"type"`
+				Message json.RawMessage `json:"message"`
+				Error   struct {
+					Error struct {
+						Type    string `json:"type"`
+						Message string `json:"message"`
+					} `json:"error"`
+				} `json:"error"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal(line, &item); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		req, ok := byID[item.CustomID]
+		if !ok {
+			return nil
+		}
+		switch item.Result.Type {
+		case "succeeded":
+			body, err := AnthropicToChat(item.Result.Message)
+			if err != nil {
+				results = append(results, ErrorResult(req.CustomID, CodeProviderError, err.Error()))
+				return nil
+			}
+			results = append(results, Result{
+				ID:       NewID("batch_req_"),
+				CustomID: req.CustomID,
+				Response: &Response{StatusCode: http.StatusOK, RequestID: remoteID + "/" + item.CustomID, Body: body},
+			})
+		case "canceled":
+			results = append(results, ErrorResult(req.CustomID, CodeCancelled, "request was cancelled before it ran"))
+		case "expired":
+			results = append(results, ErrorResult(req.CustomID, CodeExpired, "request expired before it ran"))
+		default:
+			e := item.Result.Error.Error
+			code := CodeProviderError
+			if e.Type != "" {
+				code = e.Type
+			}
+			results = append(results, ErrorResult(req.CustomID, code, e.Message))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read message batch results: %w", err)
+	}
+	return results, nil
+}
+
+func (c *Anthropic) Cancel(ctx context.Context, remoteID string) error {
+	return doJSON(ctx, c.Client, http.MethodPost, c.baseURL()+"/v1/messages/batches/"+url.PathEscape(remoteID)+"/cancel", c.header(), nil, nil)
+}
+
+// anthropicChatFields are the chat completions fields ChatToAnthropic
+// understands. Requests using anything else are not converted.
+var anthropicChatFields = map[string]bool{
+	"model": true, "messages": true, "max_tokens": true, "max_completion_tokens": true,
+	"temperature": true, "top_p": true, "stop": true, "tools": true, "tool_choice": true,
+	"parallel_tool_calls": true, "stream": true, "user": true,
+}
+
+// ChatToAnthropic converts a chat completions request body to Anthropic
+// Messages parameters. It handles text conversations and function tools
+// and returns an error for anything it cannot translate faithfully.
+func ChatToAnthropic(body json.RawMessage) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	for name := range fields {
+		if !anthropicChatFields[name] {
+			return nil, fmt.Errorf("unsupported field %q", name)
+		}
+	}
+	var req struct {
+		Model    string `json:"model"`
+		Messages []struct {
+			Role      string          `json:"role"`
+			Content   json.RawMessage `json:"content"`
+			ToolCalls json.RawMessage `json:"tool_calls"`
+		} `json:"messages"`
+		MaxTokens           int             `json:"max_tokens"`
+		MaxCompletionTokens int             `json:"max_completion_tokens"`
+		Temperature         *float64        `json:"temperature"`
+		TopP                *float64        `json:"top_p"`
+		Stop                json.RawMessage `json:"stop"`
+		Tools               []struct {
+			Type     string `json:"type"`
+			Function struct {
+				Name        string          `json:"name"`
+				Description string          `json:"description"`
+				Parameters  json.RawMessage `json:"parameters"`
+			} `json:"function"`
+		} `json:"tools"`
+		ToolChoice json.RawMessage `json:"tool_choice"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Model) == "" {
+		return nil, errors.New("model is required")
+	}
+
+	params := map[string]any{"model": req.Model}
+	maxTokens := req.MaxCompletionTokens
+	if maxTokens <= 0 {
+		maxTokens = req.MaxTokens
+	}
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+	params["max_tokens"] = maxTokens
+	if req.Temperature != nil {
+		params["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		params["top_p"] = *req.TopP
+	}
+	if len(req.Stop) > 0 && string(req.Stop) != "null" {
+		var stops []string
+		if err := json.Unmarshal(req.Stop, &stops); err != nil {
+			var one string
+			if err := json.Unmarshal(req.Stop, &one); err != nil {
+				return nil, errors.New("stop must be a string or list of strings")
+			}
+			stops = []string{one}
+		}
+		params["stop_sequences"] = stops
+	}
+
+	var system []string
+	messages := make([]map[string]any, 0, len(req.Messages))
+	for i, msg := range req.Messages {
+		if len(msg.ToolCalls) > 0 && string(msg.ToolCalls) != "null" {
+			return nil, fmt.Errorf("messages[%d]: tool calls are not supported", i)
+		}
+		text, err := chatContentText(msg.Content)
+		if err != nil {
+			return nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		switch msg.Role {
+		case "system", "developer":
+			system = append(system, text)
+		case "user", "assistant":
+			messages = append(messages, map[string]any{"role": msg.Role, "content": text})
+		default:
+			return nil, fmt.Errorf("messages[%d]: role %q is not supported", i, msg.Role)
+		}
+	}
+	if len(messages) == 0 {
+		return nil, errors.New("messages must include a user message")
+	}
+	params["messages"] = messages
+	if len(system) > 0 {
+		params["system"] = strings.Join(system, "\n\n")
+	}
+
+	if len(req.Tools) > 0 {
+		tools := make([]map[string]any, 0, len(req.Tools))
+		for _, t := range req.Tools {
+			if t.Type != "function" || t.Function.Name == "" {
+				return nil, fmt.Errorf("tool type %q is not supported", t.Type)
+			}
+			schema := t.Function.Parameters
+			if len(schema) == 0 || string(schema) == "null" {
+				schema = json.RawMessage(`{"type":"object","properties":{}}`)
+			}
+			tool := map[string]any{"name": t.Function.Name, "input_schema": schema}
+			if t.Function.Description != "" {
+				tool["description"] = t.Function.Description
+			}
+			tools = append(tools, tool)
+		}
+		params["tools"] = tools
+		if choice, ok := anthropicToolChoice(req.ToolChoice); ok {
+			params["tool_choice"] = choice
+		} else {
+			return nil, errors.New("unsupported tool_choice")
+		}
+	}
+	return json.Marshal(params)
+}
+
+// chatContentText flattens chat message content, a string or a list of text
+// parts, to plain text.
+func chatContentText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", errors.New("content must be a string or a list of parts")
+	}
+	var b strings.Builder
+	for _, p := range parts {
+		if p.Type != "text" {
+			return "", fmt.Errorf("content part %q is not supported", p.Type)
+		}
+		b.WriteString(p.Text)
+	}
+	return b.String(), nil
+}
+
+func anthropicToolChoice(raw json.RawMessage) (map[string]any, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return map[string]any{"type": "auto"}, true
+	}
+	var mode string
+	if err := json.Unmarshal(raw, &mode); err == nil {
+		switch mode {
+		case "auto":
+			return map[string]any{"type": "auto"}, true
+		case "required":
+			return map[string]any{"type": "any"}, true
+		case "none":
+			return map[string]any{"type": "none"}, true
+		}
+		return nil, false
+	}
+	var named struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Type != "function" || named.Function.Name == "" {
+		return nil, false
+	}
+	return map[string]any{"type": "tool", "name": named.Function.Name}, true
+}
+
+// AnthropicToChat converts an Anthropic message to a chat completion body.
+func AnthropicToChat(raw json.RawMessage) (json.RawMessage, error) {
+	var msg struct {
+		ID      string `json:"id"`
+		Model   string `json:"model"`
+		Content []struct {
+			Type  string          `json:"type"`
+			Text  string          `json:"text"`
+			ID    string          `json:"id"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      struct {
+			InputTokens              int `json:"input_tokens"`
+			OutputTokens             int `json:"output_tokens"`
+			CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+			CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, fmt.Errorf("decode message: %w", err)
+	}
+	var text bytes.Buffer
+	var toolCalls []map[string]any
+	for _, block := range msg.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			args := string(block.Input)
+			if args == "" {
+				args = "{}"
+			}
+			toolCalls = append(toolCalls, map[string]any{
+				"id":       block.ID,
+				"type":     "function",
+				"function": map[string]any{"name": block.Name, "arguments": args},
+			})
+		}
+	}
+	message := map[string]any{"role": "assistant", "content": text.String()}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+	}
+	finishReason := "stop"
+	switch msg.StopReason {
+	case "max_tokens":
+		finishReason = "length"
+	case "tool_use":
+		finishReason = "tool_calls"
+	}
+	u := msg.Usage
+	promptTokens := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens
+	return json.Marshal(map[string]any{
+		"id":      msg.ID,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   msg.Model,
+		"choices": []map[string]any{{
+			"index":         0,
+			"message":       message,
+			"finish_reason": finishReason,
+		}},
+		"usage": map[string]any{
+			"prompt_tokens":     promptTokens,
+			"completion_tokens": u.OutputTokens,
+			"total_tokens":      promptTokens + u.OutputTokens,
+			"prompt_tokens_details": map[string]any{
+				"cached_tokens": u.CacheReadInputTokens,
+			},
+		},
+	})
+}
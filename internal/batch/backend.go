@@ -0,0 +1,139 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Backend runs request lines through a provider's own batch API. Request
+// bodies passed to a backend already name the provider's model, without a
+// term-llm "provider:" prefix.
+type Backend interface {
+	// Name identifies the API in batch status, e.g. "openai".
+	Name() string
+	// Supports reports whether req can be sent through this backend. Lines
+	// it cannot take run one at a time instead.
+	Supports(endpoint string, req Request) bool
+	// Submit creates a remote batch and returns its ID.
+	Submit(ctx context.Context, endpoint string, reqs []Request) (string, error)
+	// Poll reports a remote batch's progress.
+	Poll(ctx context.Context, remoteID string) (RemoteStatus, error)
+	// Results fetches the results of a finished remote batch. reqs must be
+	// the slice passed to Submit. Requests without a result are omitted.
+	Results(ctx context.Context, remoteID string, reqs []Request) ([]Result, error)
+	// Cancel asks the provider to stop a remote batch.
+	Cancel(ctx context.Context, remoteID string) error
+}
+
+// RemoteStatus is a provider batch's progress.
+type RemoteStatus struct {
+	Status string `json:"status"` // provider's own status word
+	Done   bool   `json:"done"`
+	Counts Counts `json:"request_counts"`
+	// Error explains why a remote batch failed as a whole.
+	Error string `json:"error,omitempty"`
+}
+
+// doJSON sends a request with an optional JSON body and decodes a JSON
+// response into out.
+func doJSON(ctx context.Context, client *http.Client, method, url string, header http.Header, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := send(client, req)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		resp.Body.Close()
+		return nil
+	}
+	if err := decodeBody(resp, out); err != nil {
+		return fmt.Errorf("%s %s: %w", method, req.URL.Path, err)
+	}
+	return nil
+}
+
+// decodeBody decodes a JSON response body into out and closes it.
+func decodeBody(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// jsonLine encodes v as one JSONL line.
+func jsonLine(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// send performs req and turns an error status into an error carrying the
+// provider's message.
+func send(client *http.Client, req *http.Request) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 400 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	msg := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+		msg = apiErr.Error.Message
+	}
+	return nil, fmt.Errorf("%s %s: HTTP %d: %s", req.Method, req.URL.Path, resp.StatusCode, msg)
+}
+
+// withoutStream returns body with any "stream" field removed; batch APIs
+// reject streaming requests.
+func withoutStream(body json.RawMessage) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+	if _, ok := fields["stream"]; !ok {
+		return body
+	}
+	delete(fields, "stream")
+	delete(fields, "stream_options")
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return out
+}
@@ -0,0 +1,195 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func chatLine(id, body string) Request {
+	return Request{CustomID: id, Method: "POST", URL: EndpointChatCompletions, Body: json.RawMessage(body)}
+}
+
+func TestOpenAIBackend(t *testing.T) {
+	var uploaded string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer sk-test" {
+			t.Errorf("Authorization = %q", got)
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/files":
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				t.Errorf("FormFile: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			uploaded = string(data)
+			fmt.Fprint(w, `{"id":"file-in"}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/batches":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["input_file_id"] != "file-in" || body["endpoint"] != EndpointChatCompletions {
+				t.Errorf("create body = %v", body)
+			}
+			fmt.Fprint(w, `{"id":"batch_1","status":"validating"}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/batches/batch_1":
+			fmt.Fprint(w, `{"id":"batch_1","status":"completed","output_file_id":"file-out","error_file_id":"file-err","request_counts":{"total":2,"completed":1,"failed":1}}`)
+		case r.URL.Path == "/files/file-out/content":
+			fmt.Fprintln(w, `{"id":"r1","custom_id":"a","response":{"status_code":200,"body":{"usage":{"prompt_tokens":3,"completion_tokens":1}}}}`)
+		case r.URL.Path == "/files/file-err/content":
+			fmt.Fprintln(w, `{"id":"r2","custom_id":"b","error":{"code":"bad","message":"nope"}}`)
+		default:
+			http.Error(w, `{"error":{"message":"unexpected"}}`, http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := &OpenAI{APIKey: "sk-test", BaseURL: srv.URL}
+	reqs := []Request{
+		chatLine("a", `{"model":"gpt","messages":[],"stream":true,"stream_options":{"include_usage":true}}`),
+		chatLine("b", `{"model":"gpt","messages":[]}`),
+	}
+	ctx := context.Background()
+	id, err := c.Submit(ctx, EndpointChatCompletions, reqs)
+	if err != nil || id != "batch_1" {
+		t.Fatalf("Submit = %q, %v", id, err)
+	}
+	if strings.Contains(uploaded, "stream") || strings.Count(uploaded, "\n") != 2 {
+		t.Fatalf("uploaded input = %q", uploaded)
+	}
+	st, err := c.Poll(ctx, id)
+	if err != nil || !st.Done || st.Counts.Failed != 1 {
+		t.Fatalf("Poll = %+v, %v", st, err)
+	}
+	results, err := c.Results(ctx, id, reqs)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("Results = %+v, %v", results, err)
+	}
+	if results[0].Failed() || !results[1].Failed() || results[1].Error.Message != "nope" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if err := c.Cancel(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "unexpected") {
+		t.Fatalf("Cancel error = %v, want provider message", err)
+	}
+}
+
+func TestAnthropicBackend(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "ak-test" || r.Header.Get("Anthropic-Version") == "" {
+			t.Errorf("missing auth headers: %v", r.Header)
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/messages/batches":
+			var body struct {
+				Requests []struct {
+					CustomID string         `json:"custom_id"`
+					Params   map[string]any `json:"params"`
+				} `json:"requests"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if len(body.Requests) != 2 || body.Requests[0].CustomID != "req-0" || body.Requests[0].Params["system"] != "be brief" {
+				t.Errorf("create body = %+v", body)
+			}
+			fmt.Fprint(w, `{"id":"msgbatch_1","processing_status":"in_progress"}`)
+		case r.URL.Path == "/v1/messages/batches/msgbatch_1":
+			fmt.Fprintf(w, `{"id":"msgbatch_1","processing_status":"ended","results_url":%q,"request_counts":{"succeeded":1,"errored":1}}`, srv.URL+"/results")
+		case r.URL.Path == "/results":
+			fmt.Fprintln(w, `{"custom_id":"req-1","result":{"type":"errored","error":{"error":{"type":"invalid_request_error","message":"too long"}}}}`)
+			fmt.Fprintln(w, `{"custom_id":"req-0","result":{"type":"succeeded","message":{"id":"msg_1","model":"claude","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":5,"output_tokens":2,"cache_read_input_tokens":3}}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Anthropic{APIKey: "ak-test", BaseURL: srv.URL}
+	reqs := []Request{
+		chatLine("first row", `{"model":"claude","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`),
+		chatLine("second row", `{"model":"claude","messages":[{"role":"user","content":[{"type":"text","text":"yo"}]}]}`),
+	}
+	ctx := context.Background()
+	id, err := c.Submit(ctx, EndpointChatCompletions, reqs)
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	st, err := c.Poll(ctx, id)
+	if err != nil || !st.Done || st.Counts != (Counts{Total: 2, Completed: 1, Failed: 1}) {
+		t.Fatalf("Poll = %+v, %v", st, err)
+	}
+	results, err := c.Results(ctx, id, reqs)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("Results = %+v, %v", results, err)
+	}
+	byID := map[string]Result{}
+	for _, r := range results {
+		byID[r.CustomID] = r
+	}
+	if r := byID["second row"]; !r.Failed() || r.Error.Code != "invalid_request_error" {
+		t.Fatalf("errored line = %+v", r)
+	}
+	ok := byID["first row"]
+	if ok.Failed() {
+		t.Fatalf("succeeded line = %+v", ok)
+	}
+	if u := ResultUsage(ok); u != (Usage{InputTokens: 8, OutputTokens: 2, CachedInputTokens: 3}) {
+		t.Fatalf("usage = %+v", u)
+	}
+}
+
+func TestAnthropicSupports(t *testing.T) {
+	c := &Anthropic{}
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`{"model":"m","messages":[{"role":"user","content":"hi"}],"max_tokens":10,"stop":"END"}`, true},
+		{`{"model":"m","messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"f"}}],"tool_choice":"required"}`, true},
+		{`{"model":"m","messages":[{"role":"user","content":"hi"}],"response_format":{"type":"json_object"}}`, false},
+		{`{"model":"m","messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"x"}}]}]}`, false},
+		{`{"model":"m","messages":[{"role":"tool","content":"x"}]}`, false},
+		{`{"messages":[{"role":"user","content":"hi"}]}`, false},
+	}
+	for _, tt := range tests {
+		if got := c.Supports(EndpointChatCompletions, chatLine("a", tt.body)); got != tt.want {
+			t.Errorf("Supports(%s) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+	if c.Supports(EndpointResponses, chatLine("a", tests[0].body)) {
+		t.Error("Supports(responses) = true, want false")
+	}
+}
+
+func TestAnthropicToChatToolUse(t *testing.T) {
+	body, err := AnthropicToChat(json.RawMessage(`{"id":"m","content":[{"type":"tool_use","id":"t1","name":"lookup","input":{"q":"x"}}],"stop_reason":"tool_use"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chat struct {
+		Choices []struct {
+			FinishReason string `json:"finish_reason"`
+			Message      struct {
+				ToolCalls []struct {
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(body, &chat); err != nil {
+		t.Fatal(err)
+	}
+	choice := chat.Choices[0]
+	if choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) != 1 ||
+		choice.Message.ToolCalls[0].Function.Name != "lookup" || choice.Message.ToolCalls[0].Function.Arguments != `{"q":"x"}` {
+		t.Fatalf("unexpected chat completion: %s", body)
+	}
+}
@@ -0,0 +1,232 @@
+// Package batch implements OpenAI-compatible batch files.
+//
+// A batch input file is JSONL: one chat completions or responses request per
+// line, each tagged with a caller-chosen custom_id. Results are written back
+// as JSONL in the same shape the OpenAI Batch API uses, so existing tooling
+// can read them. Backends submit groups of lines to a provider's own batch
+// API (OpenAI Batch, Anthropic Message Batches), which is cheaper than
+// sending them one at a time.
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Endpoints a batch may target.
+const (
+	EndpointChatCompletions = "/v1/chat/completions"
+	EndpointResponses       = "/v1/responses"
+)
+
+// Limits on input files, matching the OpenAI Batch API.
+const (
+	MaxRequests  = 50000
+	MaxLineBytes = 10 << 20
+)
+
+// Per-line error codes written by this package and its callers.
+const (
+	CodeInvalidRequest = "invalid_request"
+	CodeProviderError  = "provider_error"
+	CodeCancelled      = "batch_cancelled"
+	CodeExpired        = "batch_expired"
+)
+
+// ValidEndpoint reports whether endpoint can be used for a batch.
+func ValidEndpoint(endpoint string) bool {
+	return endpoint == EndpointChatCompletions || endpoint == EndpointResponses
+}
+
+// Request is one line of a batch input file.
+type Request struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// Result is one line of a batch output file. Exactly one of Response and
+// Error is set.
+type Result struct {
+	ID       string    `json:"id"`
+	CustomID string    `json:"custom_id"`
+	Response *Response `json:"response"`
+	Error    *Error    `json:"error"`
+}
+
+// Response is the HTTP response a request would have received.
+type Response struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// Error explains why a request produced no response.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Failed reports whether the request did not complete successfully.
+func (r Result) Failed() bool {
+	return r.Error != nil || r.Response == nil || r.Response.StatusCode >= 400
+}
+
+// ErrorResult returns a failed result for customID.
+func ErrorResult(customID, code, message string) Result {
+	return Result{ID: NewID("batch_req_"), CustomID: customID, Error: &Error{Code: code, Message: message}}
+}
+
+// Counts tallies a batch's requests.
+type Counts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// Usage totals token usage across a batch.
+type Usage struct {
+	InputTokens       int `json:"input_tokens"`
+	OutputTokens      int `json:"output_tokens"`
+	CachedInputTokens int `json:"cached_input_tokens,omitempty"`
+}
+
+// Add accumulates other into u.
+func (u *Usage) Add(other Usage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CachedInputTokens += other.CachedInputTokens
+}
+
+// ResultUsage extracts token usage from a result's response body, which may
+// be a chat completion (prompt_tokens) or a response (input_tokens). Input
+// tokens include cached tokens in both shapes.
+func ResultUsage(r Result) Usage {
+	if r.Response == nil || len(r.Response.Body) == 0 {
+		return Usage{}
+	}
+	var body struct {
+		Usage struct {
+			PromptTokens        int `json:"prompt_tokens"`
+			CompletionTokens    int `json:"completion_tokens"`
+			PromptTokensDetails struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"prompt_tokens_details"`
+			InputTokens        int `json:"input_tokens"`
+			OutputTokens       int `json:"output_tokens"`
+			InputTokensDetails struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"input_tokens_details"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(r.Response.Body, &body); err != nil {
+		return Usage{}
+	}
+	u := body.Usage
+	return Usage{
+		InputTokens:       u.PromptTokens + u.InputTokens,
+		OutputTokens:      u.CompletionTokens + u.OutputTokens,
+		CachedInputTokens: u.PromptTokensDetails.CachedTokens + u.InputTokensDetails.CachedTokens,
+	}
+}
+
+// ParseRequests reads and validates a batch input file. Every line must be
+// a POST to endpoint with a unique custom_id and a JSON object body.
+func ParseRequests(r io.Reader, endpoint string) ([]Request, error) {
+	if !ValidEndpoint(endpoint) {
+		return nil, fmt.Errorf("endpoint must be %s or %s", EndpointChatCompletions, EndpointResponses)
+	}
+	var reqs []Request
+	seen := make(map[string]bool)
+	err := scanLines(r, func(n int, line []byte) error {
+		var req Request
+		if err := json.Unmarshal(line, &req); err != nil {
+			return fmt.Errorf("line %d: invalid JSON: %w", n, err)
+		}
+		req.CustomID = strings.TrimSpace(req.CustomID)
+		switch {
+		case req.CustomID == "":
+			return fmt.Errorf("line %d: custom_id is required", n)
+		case seen[req.CustomID]:
+			return fmt.Errorf("line %d: duplicate custom_id %q", n, req.CustomID)
+		case !strings.EqualFold(req.Method, "POST"):
+			return fmt.Errorf("line %d: method must be POST", n)
+		case req.URL != endpoint:
+			return fmt.Errorf("line %d: url %q does not match batch endpoint %s", n, req.URL, endpoint)
+		}
+		if body := bytes.TrimSpace(req.Body); len(body) == 0 || body[0] != '{' {
+			return fmt.Errorf("line %d: body must be a JSON object", n)
+		}
+		if len(reqs) == MaxRequests {
+			return fmt.Errorf("batch has more than %d requests", MaxRequests)
+		}
+		seen[req.CustomID] = true
+		req.Method = "POST"
+		reqs = append(reqs, req)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(reqs) == 0 {
+		return nil, errors.New("batch input file has no requests")
+	}
+	return reqs, nil
+}
+
+// ReadResults reads a results file written by WriteResult. Reading stops at
+// the first malformed line, which after a crash is the partly written last
+// one.
+func ReadResults(r io.Reader) ([]Result, error) {
+	var results []Result
+	err := scanLines(r, func(n int, line []byte) error {
+		var res Result
+		if err := json.Unmarshal(line, &res); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		results = append(results, res)
+		return nil
+	})
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return results, nil
+	}
+	return results, err
+}
+
+// WriteResult appends one result line to w.
+func WriteResult(w io.Writer, r Result) error {
+	line, err := jsonLine(r)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(line)
+	return err
+}
+
+// scanLines calls fn with each non-blank line of r and its 1-based number.
+func scanLines(r io.Reader, fn func(n int, line []byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), MaxLineBytes)
+	n := 0
+	for sc.Scan() {
+		n++
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(n, line); err != nil {
+			return err
+		}
+	}
+	if errors.Is(sc.Err(), bufio.ErrTooLong) {
+		return fmt.Errorf("line %d: longer than %d bytes", n+1, MaxLineBytes)
+	}
+	return sc.Err()
+}
@@ -0,0 +1,132 @@
+package batch
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseRequests(t *testing.T) {
+	input := `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"m"}}
+
+{"custom_id":" b ","method":"post","url":"/v1/chat/completions","body":{"model":"m"}}
+`
+	reqs, err := ParseRequests(strings.NewReader(input), EndpointChatCompletions)
+	if err != nil {
+		t.Fatalf("ParseRequests: %v", err)
+	}
+	if len(reqs) != 2 || reqs[0].CustomID != "a" || reqs[1].CustomID != "b" || reqs[1].Method != "POST" {
+		t.Fatalf("unexpected requests: %+v", reqs)
+	}
+}
+
+func TestParseRequestsRejectsInvalidLines(t *testing.T) {
+	tests := map[string]string{
+		"bad json":      `{"custom_id":`,
+		"no custom_id":  `{"method":"POST","url":"/v1/chat/completions","body":{}}`,
+		"duplicate":     `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{}}` + "\n" + `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{}}`,
+		"method":        `{"custom_id":"a","method":"GET","url":"/v1/chat/completions","body":{}}`,
+		"url mismatch":  `{"custom_id":"a","method":"POST","url":"/v1/responses","body":{}}`,
+		"body not obj":  `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":"hi"}`,
+		"empty file":    "\n\n",
+		"line too long": `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"x":"` + strings.Repeat("x", MaxLineBytes) + `"}}`,
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseRequests(strings.NewReader(input), EndpointChatCompletions); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+	if _, err := ParseRequests(strings.NewReader(""), "/v1/embeddings"); err == nil {
+		t.Fatal("expected unsupported endpoint error")
+	}
+}
+
+func TestReadResultsStopsAtTruncatedLine(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteResult(&buf, ErrorResult("a", CodeProviderError, "boom")); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteResult(&buf, Result{ID: "r", CustomID: "b", Response: &Response{StatusCode: 200, Body: json.RawMessage(`{}`)}}); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString(`{"id":"r","custom_id":"c","resp`)
+
+	results, err := ReadResults(&buf)
+	if err != nil {
+		t.Fatalf("ReadResults: %v", err)
+	}
+	if len(results) != 2 || results[0].CustomID != "a" || !results[0].Failed() || results[1].Failed() {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestResultUsage(t *testing.T) {
+	chat := Result{Response: &Response{StatusCode: 200, Body: json.RawMessage(
+		`{"usage":{"prompt_tokens":10,"completion_tokens":3,"prompt_tokens_details":{"cached_tokens":4}}}`)}}
+	responses := Result{Response: &Response{StatusCode: 200, Body: json.RawMessage(
+		`{"usage":{"input_tokens":7,"output_tokens":2,"input_tokens_details":{"cached_tokens":1}}}`)}}
+
+	var total Usage
+	total.Add(ResultUsage(chat))
+	total.Add(ResultUsage(responses))
+	total.Add(ResultUsage(ErrorResult("x", CodeProviderError, "no body")))
+	if total != (Usage{InputTokens: 17, OutputTokens: 5, CachedInputTokens: 5}) {
+		t.Fatalf("usage = %+v", total)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := OpenFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := store.Create("../rows.jsonl", PurposeBatch, "key_a", strings.NewReader("line\n"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if in.Filename != "rows.jsonl" || in.Bytes != 5 || in.Object != "file" {
+		t.Fatalf("unexpected file: %+v", in)
+	}
+	out, err := store.Create("out.jsonl", PurposeBatchOutput, "", strings.NewReader("{}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := store.List(PurposeBatch, "")
+	if err != nil || len(files) != 1 || files[0].ID != in.ID || files[0].Owner != "key_a" {
+		t.Fatalf("List(batch) = %+v, %v", files, err)
+	}
+	if files, _ := store.List("", ""); len(files) != 2 {
+		t.Fatalf("List() returned %d files, want 2", len(files))
+	}
+	if files, _ := store.List("", "key_a"); len(files) != 1 || files[0].ID != in.ID {
+		t.Fatalf("List(owner) = %+v, want only the owned file", files)
+	}
+	if got, _ := store.Get(in.ID); got.Owner != "key_a" {
+		t.Fatalf("Get owner = %q, want key_a", got.Owner)
+	}
+
+	_, fh, err := store.Open(in.ID)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, _ := io.ReadAll(fh)
+	fh.Close()
+	if string(data) != "line\n" {
+		t.Fatalf("content = %q", data)
+	}
+
+	if err := store.Delete(out.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(out.ID); err != ErrNotFound {
+		t.Fatalf("Get after delete = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Get("../../etc/passwd"); err != ErrNotFound {
+		t.Fatalf("Get with bad ID = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,206 @@
+package batch
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for an unknown file ID.
+var ErrNotFound = errors.New("file not found")
+
+// File purposes.
+const (
+	PurposeBatch       = "batch"
+	PurposeBatchOutput = "batch_output"
+)
+
+// File describes a stored batch input or output file, in the shape of the
+// OpenAI files API.
+type File struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	// Owner identifies the API key that owns the file; empty for files
+	// created with full access. It is stored but not part of the API shape.
+	Owner string `json:"-"`
+}
+
+// fileMeta is the on-disk metadata of a file.
+type fileMeta struct {
+	File
+	Owner string `json:"owner,omitempty"`
+}
+
+// FileStore keeps batch files on disk, one content file and one metadata
+// file per ID. It also hands out scratch directories for in-progress
+// batches.
+type FileStore struct {
+	dir string
+}
+
+var fileIDPattern = regexp.MustCompile(`^file-[0-9a-f]{24}$`)
+
+// DefaultDir returns the standard file store location under dataDir.
+func DefaultDir(dataDir string) string {
+	return filepath.Join(dataDir, "batches")
+}
+
+// NewID returns a random ID with the given prefix.
+func NewID(prefix string) string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+// OpenFileStore creates dir if needed and returns a store rooted there.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "files"), 0o700); err != nil {
+		return nil, fmt.Errorf("create batch file store: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) contentPath(id string) string {
+	return filepath.Join(s.dir, "files", id+".jsonl")
+}
+
+func (s *FileStore) metaPath(id string) string {
+	return filepath.Join(s.dir, "files", id+".json")
+}
+
+// Create stores the contents of r as a new file owned by owner.
+func (s *FileStore) Create(filename, purpose, owner string, r io.Reader) (File, error) {
+	f := File{
+		ID:        NewID("file-"),
+		Object:    "file",
+		CreatedAt: time.Now().Unix(),
+		Filename:  filepath.Base(strings.TrimSpace(filename)),
+		Purpose:   purpose,
+		Owner:     owner,
+	}
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, "files"), ".upload-*")
+	if err != nil {
+		return File{}, err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return File{}, err
+	}
+	f.Bytes = n
+	meta, err := json.Marshal(fileMeta{File: f, Owner: f.Owner})
+	if err != nil {
+		return File{}, err
+	}
+	if err := os.WriteFile(s.metaPath(f.ID), meta, 0o600); err != nil {
+		return File{}, err
+	}
+	if err := os.Rename(tmp.Name(), s.contentPath(f.ID)); err != nil {
+		_ = os.Remove(s.metaPath(f.ID))
+		return File{}, err
+	}
+	return f, nil
+}
+
+// Get returns a file's metadata.
+func (s *FileStore) Get(id string) (File, error) {
+	if !fileIDPattern.MatchString(id) {
+		return File{}, ErrNotFound
+	}
+	data, err := os.ReadFile(s.metaPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return File{}, ErrNotFound
+	}
+	if err != nil {
+		return File{}, err
+	}
+	var meta fileMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return File{}, fmt.Errorf("read file %s: %w", id, err)
+	}
+	f := meta.File
+	f.Owner = meta.Owner
+	return f, nil
+}
+
+// Open returns a file's metadata and an open handle on its contents.
+func (s *FileStore) Open(id string) (File, *os.File, error) {
+	f, err := s.Get(id)
+	if err != nil {
+		return File{}, nil, err
+	}
+	fh, err := os.Open(s.contentPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return File{}, nil, ErrNotFound
+	}
+	return f, fh, err
+}
+
+// List returns stored files, newest first, optionally limited to one
+// purpose. A non-empty owner limits the list to that owner's files.
+func (s *FileStore) List(purpose, owner string) ([]File, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "files", "file-*.json"))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, len(matches))
+	for _, path := range matches {
+		f, err := s.Get(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			continue
+		}
+		if (purpose == "" || f.Purpose == purpose) && (owner == "" || f.Owner == owner) {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].CreatedAt != files[j].CreatedAt {
+			return files[i].CreatedAt > files[j].CreatedAt
+		}
+		return files[i].ID > files[j].ID
+	})
+	return files, nil
+}
+
+// Delete removes a file.
+func (s *FileStore) Delete(id string) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.contentPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Remove(s.metaPath(id))
+}
+
+// WorkDir returns a scratch directory for the batch with the given ID,
+// creating it if needed. It survives restarts so an interrupted batch can
+// resume; remove it with RemoveWorkDir once the batch is finished.
+func (s *FileStore) WorkDir(batchID string) (string, error) {
+	dir := filepath.Join(s.dir, "work", filepath.Base(batchID))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// RemoveWorkDir deletes a batch's scratch directory.
+func (s *FileStore) RemoveWorkDir(batchID string) error {
+	return os.RemoveAll(filepath.Join(s.dir, "work", filepath.Base(batchID)))
+}
@@ -0,0 +1,162 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultOpenAIBaseURL is the OpenAI API root used when none is configured.
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAI submits batches to the OpenAI Batch API, which accepts input files
+// in exactly the format this package parses.
+type OpenAI struct {
+	APIKey  string
+	BaseURL string
+	Client  *http.Client
+}
+
+type openAIBatch struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	OutputFileID  string `json:"output_file_id"`
+	ErrorFileID   string `json:"error_file_id"`
+	RequestCounts Counts `json:"request_counts"`
+	Errors        *struct {
+		Data []Error `json:"data"`
+	} `json:"errors"`
+}
+
+func (c *OpenAI) Name() string { return "openai" }
+
+func (c *OpenAI) Supports(endpoint string, req Request) bool {
+	return ValidEndpoint(endpoint)
+}
+
+func (c *OpenAI) baseURL() string {
+	if base := strings.TrimRight(strings.TrimSpace(c.BaseURL), "/"); base != "" {
+		return base
+	}
+	return DefaultOpenAIBaseURL
+}
+
+func (c *OpenAI) header() http.Header {
+	return http.Header{"Authorization": {"Bearer " + c.APIKey}}
+}
+
+func (c *OpenAI) Submit(ctx context.Context, endpoint string, reqs []Request) (string, error) {
+	var input bytes.Buffer
+	for _, req := range reqs {
+		req.Body = withoutStream(req.Body)
+		line, err := jsonLine(req)
+		if err != nil {
+			return "", err
+		}
+		input.Write(line)
+	}
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	if err := mw.WriteField("purpose", PurposeBatch); err != nil {
+		return "", err
+	}
+	part, err := mw.CreateFormFile("file", "batch.jsonl")
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(input.Bytes()); err != nil {
+		return "", err
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL()+"/files", &form)
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header = c.header()
+	httpReq.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := send(c.Client, httpReq)
+	if err != nil {
+		return "", fmt.Errorf("upload batch input: %w", err)
+	}
+	var file File
+	err = decodeBody(resp, &file)
+	if err != nil {
+		return "", fmt.Errorf("upload batch input: %w", err)
+	}
+
+	var created openAIBatch
+	err = doJSON(ctx, c.Client, http.MethodPost, c.baseURL()+"/batches", c.header(), map[string]string{
+		"input_file_id":     file.ID,
+		"endpoint":          endpoint,
+		"completion_window": "24h",
+	}, &created)
+	if err != nil {
+		return "", fmt.Errorf("create batch: %w", err)
+	}
+	return created.ID, nil
+}
+
+func (c *OpenAI) get(ctx context.Context, remoteID string) (openAIBatch, error) {
+	var b openAIBatch
+	err := doJSON(ctx, c.Client, http.MethodGet, c.baseURL()+"/batches/"+url.PathEscape(remoteID), c.header(), nil, &b)
+	return b, err
+}
+
+func (c *OpenAI) Poll(ctx context.Context, remoteID string) (RemoteStatus, error) {
+	b, err := c.get(ctx, remoteID)
+	if err != nil {
+		return RemoteStatus{}, err
+	}
+	st := RemoteStatus{Status: b.Status, Counts: b.RequestCounts}
+	switch b.Status {
+	case "completed", "expired", "cancelled":
+		st.Done = true
+	case "failed":
+		st.Done = true
+		st.Error = "batch failed"
+		if b.Errors != nil && len(b.Errors.Data) > 0 {
+			st.Error = b.Errors.Data[0].Message
+		}
+	}
+	return st, nil
+}
+
+func (c *OpenAI) Results(ctx context.Context, remoteID string, reqs []Request) ([]Result, error) {
+	b, err := c.get(ctx, remoteID)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, fileID := range []string{b.OutputFileID, b.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL()+"/files/"+url.PathEscape(fileID)+"/content", nil)
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header = c.header()
+		resp, err := send(c.Client, httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("download batch results: %w", err)
+		}
+		part, err := ReadResults(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read batch results: %w", err)
+		}
+		results = append(results, part...)
+	}
+	return results, nil
+}
+
+func (c *OpenAI) Cancel(ctx context.Context, remoteID string) error {
+	return doJSON(ctx, c.Client, http.MethodPost, c.baseURL()+"/batches/"+url.PathEscape(remoteID)+"/cancel", c.header(), nil, nil)
+}
@@ -451,6 +451,7 @@ type Config struct {
 	Providers       map[string]ProviderConfig `mapstructure:"providers"`
 	Diagnostics     DiagnosticsConfig         `mapstructure:"diagnostics"`
 	DebugLogs       DebugLogsConfig           `mapstructure:"debug_logs"`
+	Tracing         TracingConfig             `mapstructure:"tracing"`
 	Sessions        SessionsConfig            `mapstructure:"sessions"`
 	Approval        ApprovalConfig            `mapstructure:"approval"`
 	Guardian        GuardianConfig            `mapstructure:"guardian"`
@@ -509,8 +510,37 @@ type ServeConfig struct {
 	WidgetsDir             string              `mapstructure:"widgets_dir" yaml:"widgets_dir,omitempty"`
 	ResponseTimeout        string              `mapstructure:"response_timeout" yaml:"response_timeout,omitempty"` // Go duration string, e.g. "30m" or "1h"
 	Telegram               TelegramServeConfig `mapstructure:"telegram" yaml:"telegram,omitempty"`
+	Slack                  SlackServeConfig    `mapstructure:"slack" yaml:"slack,omitempty"`
+	Discord                DiscordServeConfig  `mapstructure:"discord" yaml:"discord,omitempty"`
+	Email                  EmailServeConfig    `mapstructure:"email" yaml:"email,omitempty"`
+	Matrix                 MatrixServeConfig   `mapstructure:"matrix" yaml:"matrix,omitempty"`
 	WebPush                WebPushConfig       `mapstructure:"web_push" yaml:"web_push,omitempty"`
 	MCP                    ServeMCPConfig      `mapstructure:"mcp" yaml:"mcp,omitempty"`
+	Webhooks               []ServeWebhook      `mapstructure:"webhooks" yaml:"webhooks,omitempty"`
+	Batch                  ServeBatchConfig    `mapstructure:"batch" yaml:"batch,omitempty"`
+}
+
+// ServeBatchConfig tunes the /v1/batches runner.
+type ServeBatchConfig struct {
+	// Concurrency caps in-flight requests per provider name across all
+	// running batches; the "default" entry covers unlisted providers.
+	Concurrency map[string]int `mapstructure:"concurrency" yaml:"concurrency,omitempty"`
+	// Native sends lines to OpenAI's and Anthropic's discounted batch APIs
+	// where possible (default true).
+	Native *bool `mapstructure:"native" yaml:"native,omitempty"`
+	// PollInterval is how often native batches are polled, as a Go duration
+	// string (default "30s").
+	PollInterval string `mapstructure:"poll_interval" yaml:"poll_interval,omitempty"`
+}
+
+// ServeWebhook is an outbound webhook endpoint. Serve POSTs a JSON event to
+// URL for each event type in Events (all events when empty; "response.*"
+// matches a whole group), signed with an HMAC-SHA256 of Secret.
+type ServeWebhook struct {
+	Name   string   `mapstructure:"name" yaml:"name"`
+	URL    string   `mapstructure:"url" yaml:"url"`
+	Secret string   `mapstructure:"secret" yaml:"secret,omitempty"`
+	Events []string `mapstructure:"events" yaml:"events,omitempty"`
 }
 
 // ServeMCPConfig configures the standalone term-llm serve mcp surface.
@@ -534,6 +564,87 @@ type TelegramServeConfig struct {
 	InterruptTimeout int      `mapstructure:"interrupt_timeout" yaml:"interrupt_timeout,omitempty"` // seconds, 0 = default (3)
 }
 
+// SlackServeConfig holds configuration for the Slack bot platform.
+// Setting AppToken selects Socket Mode; otherwise the Events API endpoint is
+// served on EventsListen and requests are verified with SigningSecret.
+type SlackServeConfig struct {
+	BotToken          string   `mapstructure:"bot_token" yaml:"bot_token,omitempty"`           // xoxb-...
+	AppToken          string   `mapstructure:"app_token" yaml:"app_token,omitempty"`           // xapp-..., enables Socket Mode
+	SigningSecret     string   `mapstructure:"signing_secret" yaml:"signing_secret,omitempty"` // Events API request verification
+	EventsListen      string   `mapstructure:"events_listen" yaml:"events_listen,omitempty"`   // Events API listen address, default 127.0.0.1:3000
+	AllowedUserIDs    []string `mapstructure:"allowed_user_ids" yaml:"allowed_user_ids,omitempty"`
+	AllowedChannelIDs []string `mapstructure:"allowed_channel_ids" yaml:"allowed_channel_ids,omitempty"` // empty = any channel the bot is in
+	IdleTimeout       int      `mapstructure:"idle_timeout" yaml:"idle_timeout,omitempty"`               // minutes
+	APIURL            string   `mapstructure:"api_url" yaml:"api_url,omitempty"`                         // Web API base URL, default https://slack.com/api/
+}
+
+// DiscordServeConfig holds configuration for the Discord bot platform.
+// A user may talk to the bot when listed in AllowedUserIDs or holding one of
+// AllowedRoleIDs (a guild's @everyone role ID equals the guild ID). Tool
+// approvals are limited to ApproverIDs, or AllowedUserIDs when that is empty.
+type DiscordServeConfig struct {
+	Token             string   `mapstructure:"token" yaml:"token,omitempty"`
+	AllowedUserIDs    []string `mapstructure:"allowed_user_ids" yaml:"allowed_user_ids,omitempty"`
+	AllowedRoleIDs    []string `mapstructure:"allowed_role_ids" yaml:"allowed_role_ids,omitempty"`
+	AllowedChannelIDs []string `mapstructure:"allowed_channel_ids" yaml:"allowed_channel_ids,omitempty"` // empty = any channel the bot can read
+	ApproverIDs       []string `mapstructure:"approver_ids" yaml:"approver_ids,omitempty"`
+	SessionScope      string   `mapstructure:"session_scope" yaml:"session_scope,omitempty"` // "thread" (default) or "channel"
+	IdleTimeout       int      `mapstructure:"idle_timeout" yaml:"idle_timeout,omitempty"`   // minutes
+	APIURL            string   `mapstructure:"api_url" yaml:"api_url,omitempty"`             // REST base URL, default https://discord.com/api/v10
+}
+
+// EmailServeConfig holds configuration for the email platform. Mail is
+// received by polling IMAPAddr, or by accepting LMTP deliveries on LMTPListen
+// when that is set, and replies are sent through SMTPAddr. SMTP credentials
+// default to the IMAP ones. AllowedSenders and SenderAgents entries are
+// either full addresses or "@domain".
+type EmailServeConfig struct {
+	Address        string             `mapstructure:"address" yaml:"address,omitempty"`     // the bot's address, used as From on replies
+	FromName       string             `mapstructure:"from_name" yaml:"from_name,omitempty"` // display name on replies
+	IMAPAddr       string             `mapstructure:"imap_addr" yaml:"imap_addr,omitempty"` // host:port, e.g. imap.example.com:993
+	IMAPUsername   string             `mapstructure:"imap_username" yaml:"imap_username,omitempty"`
+	IMAPPassword   string             `mapstructure:"imap_password" yaml:"imap_password,omitempty"`
+	IMAPMailbox    string             `mapstructure:"imap_mailbox" yaml:"imap_mailbox,omitempty"`   // default INBOX
+	IMAPSecurity   string             `mapstructure:"imap_security" yaml:"imap_security,omitempty"` // "tls" (default), "starttls" or "none"
+	PollInterval   int                `mapstructure:"poll_interval" yaml:"poll_interval,omitempty"` // seconds, default 60
+	LMTPListen     string             `mapstructure:"lmtp_listen" yaml:"lmtp_listen,omitempty"`     // accept LMTP here instead of polling IMAP
+	SMTPAddr       string             `mapstructure:"smtp_addr" yaml:"smtp_addr,omitempty"`         // host:port, e.g. smtp.example.com:587
+	SMTPUsername   string             `mapstructure:"smtp_username" yaml:"smtp_username,omitempty"`
+	SMTPPassword   string             `mapstructure:"smtp_password" yaml:"smtp_password,omitempty"`
+	SMTPSecurity   string             `mapstructure:"smtp_security" yaml:"smtp_security,omitempty"` // "starttls" (default), "tls" or "none"
+	AllowedSenders []string           `mapstructure:"allowed_senders" yaml:"allowed_senders,omitempty"`
+	SenderAgents   []EmailSenderAgent `mapstructure:"sender_agents" yaml:"sender_agents,omitempty"`
+	IdleTimeout    int                `mapstructure:"idle_timeout" yaml:"idle_timeout,omitempty"` // minutes a thread's history stays in memory
+}
+
+// EmailSenderAgent routes mail from Sender (an address or "@domain") to Agent.
+type EmailSenderAgent struct {
+	Sender string `mapstructure:"sender" yaml:"sender"`
+	Agent  string `mapstructure:"agent" yaml:"agent"`
+}
+
+// MatrixServeConfig holds configuration for the Matrix platform. The bot
+// logs in with an access token for an existing account. AllowedUserIDs
+// entries are Matrix IDs ("@alice:example.org") or ":example.org" for every
+// user on a homeserver.
+type MatrixServeConfig struct {
+	HomeserverURL  string            `mapstructure:"homeserver_url" yaml:"homeserver_url,omitempty"` // e.g. https://matrix.example.org
+	AccessToken    string            `mapstructure:"access_token" yaml:"access_token,omitempty"`
+	AllowedUserIDs []string          `mapstructure:"allowed_user_ids" yaml:"allowed_user_ids,omitempty"`
+	AllowedRoomIDs []string          `mapstructure:"allowed_room_ids" yaml:"allowed_room_ids,omitempty"` // empty = any room the bot is invited to
+	ApproverIDs    []string          `mapstructure:"approver_ids" yaml:"approver_ids,omitempty"`
+	SessionScope   string            `mapstructure:"session_scope" yaml:"session_scope,omitempty"` // "thread" (default) or "room"
+	RoomAgents     []MatrixRoomAgent `mapstructure:"room_agents" yaml:"room_agents,omitempty"`
+	IdleTimeout    int               `mapstructure:"idle_timeout" yaml:"idle_timeout,omitempty"` // minutes
+}
+
+// MatrixRoomAgent assigns Agent to every conversation in Room (a room ID
+// such as "!abc:example.org").
+type MatrixRoomAgent struct {
+	Room  string `mapstructure:"room" yaml:"room"`
+	Agent string `mapstructure:"agent" yaml:"agent"`
+}
+
 // AgentsConfig configures the agent system
 type AgentsConfig struct {
 	UseBuiltin  bool                       `mapstructure:"use_builtin"`  // Enable built-in agents (default true)
@@ -645,6 +756,16 @@ type DebugLogsConfig struct {
 	Dir     string `mapstructure:"dir"`     // Override default directory (defaults to ~/.local/share/term-llm/debug/)
 }
 
+// TracingConfig configures OpenTelemetry trace export. Tracing is off unless
+// an endpoint or file is set here, by flag, or via OTEL_EXPORTER_OTLP_ENDPOINT.
+type TracingConfig struct {
+	Endpoint    string            `mapstructure:"endpoint" yaml:"endpoint,omitempty"`         // OTLP/HTTP collector, e.g. http://localhost:4318
+	Headers     map[string]string `mapstructure:"headers" yaml:"headers,omitempty"`           // Extra export headers (collector auth)
+	File        string            `mapstructure:"file" yaml:"file,omitempty"`                 // Append spans as JSON lines for offline use
+	ServiceName string            `mapstructure:"service_name" yaml:"service_name,omitempty"` // service.name resource attribute (default term-llm)
+	SampleRatio float64           `mapstructure:"sample_ratio" yaml:"sample_ratio,omitempty"` // Fraction of new traces recorded (0 = all)
+}
+
 // SessionsConfig configures session storage
 type SessionsConfig struct {
 	Enabled          bool   `mapstructure:"enabled"`            // Master switch - set to false to disable all session storage
@@ -904,6 +1025,9 @@ type EmbedConfig struct {
 	Jina     EmbedJinaConfig   `mapstructure:"jina"`
 	Voyage   EmbedVoyageConfig `mapstructure:"voyage"`
 	Ollama   EmbedOllamaConfig `mapstructure:"ollama"`
+	// Aliases maps model names accepted by serve's /v1/embeddings to a
+	// provider or provider:model, e.g. small: openai:text-embedding-3-small.
+	Aliases map[string]string `mapstructure:"aliases"`
 }
 
 // EmbedOpenAIConfig configures OpenAI embedding generation
@@ -2347,6 +2471,34 @@ func GetConfigDir() (string, error) {
 	return filepath.Join(homeDir, ".config", "term-llm"), nil
 }
 
+// LoadTracing reads only the tracing section of the config file, from the
+// same locations Load searches. Unlike Load it leaves the global viper state
+// alone and resolves no credentials, so it is cheap enough to run before
+// every command. A missing config file yields the zero value.
+func LoadTracing() (TracingConfig, error) {
+	configDir, err := GetConfigDir()
+	if err != nil {
+		return TracingConfig{}, err
+	}
+	for _, dir := range []string{configDir, "."} {
+		data, err := os.ReadFile(filepath.Join(dir, "config.yaml"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return TracingConfig{}, err
+		}
+		var raw struct {
+			Tracing TracingConfig `yaml:"tracing"`
+		}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return TracingConfig{}, fmt.Errorf("failed to read tracing config: %w", err)
+		}
+		return raw.Tracing, nil
+	}
+	return TracingConfig{}, nil
+}
+
 // GetConfigPath returns the path where the config file should be located
 func GetConfigPath() (string, error) {
 	configDir, err := GetConfigDir()
@@ -2696,6 +2848,157 @@ func SetServeTelegramConfig(c TelegramServeConfig) error {
 	return writeConfigPreservingEnvCase(v)
 }
 
+// SetServeSlackConfig saves Slack bot configuration using viper.
+// Merges with existing config rather than overwriting.
+func SetServeSlackConfig(c SlackServeConfig) error {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(configPath)
+	v.SetConfigType("yaml")
+	_ = v.ReadInConfig()
+
+	v.Set("serve.slack.bot_token", c.BotToken)
+	v.Set("serve.slack.app_token", c.AppToken)
+	v.Set("serve.slack.signing_secret", c.SigningSecret)
+	v.Set("serve.slack.allowed_user_ids", c.AllowedUserIDs)
+	if len(c.AllowedChannelIDs) > 0 {
+		v.Set("serve.slack.allowed_channel_ids", c.AllowedChannelIDs)
+	}
+	if c.EventsListen != "" {
+		v.Set("serve.slack.events_listen", c.EventsListen)
+	}
+	if c.IdleTimeout > 0 {
+		v.Set("serve.slack.idle_timeout", c.IdleTimeout)
+	}
+
+	return writeConfigPreservingEnvCase(v)
+}
+
+// SetServeDiscordConfig saves Discord bot configuration using viper.
+// Merges with existing config rather than overwriting.
+func SetServeDiscordConfig(c DiscordServeConfig) error {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(configPath)
+	v.SetConfigType("yaml")
+	_ = v.ReadInConfig()
+
+	v.Set("serve.discord.token", c.Token)
+	v.Set("serve.discord.allowed_user_ids", c.AllowedUserIDs)
+	if len(c.AllowedRoleIDs) > 0 {
+		v.Set("serve.discord.allowed_role_ids", c.AllowedRoleIDs)
+	}
+	if len(c.AllowedChannelIDs) > 0 {
+		v.Set("serve.discord.allowed_channel_ids", c.AllowedChannelIDs)
+	}
+	if len(c.ApproverIDs) > 0 {
+		v.Set("serve.discord.approver_ids", c.ApproverIDs)
+	}
+	if c.SessionScope != "" {
+		v.Set("serve.discord.session_scope", c.SessionScope)
+	}
+	if c.IdleTimeout > 0 {
+		v.Set("serve.discord.idle_timeout", c.IdleTimeout)
+	}
+
+	return writeConfigPreservingEnvCase(v)
+}
+
+// SetServeEmailConfig saves email platform configuration using viper.
+func SetServeEmailConfig(c EmailServeConfig) error {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(configPath)
+	v.SetConfigType("yaml")
+	_ = v.ReadInConfig()
+
+	v.Set("serve.email.address", c.Address)
+	v.Set("serve.email.allowed_senders", c.AllowedSenders)
+	v.Set("serve.email.smtp_addr", c.SMTPAddr)
+	if c.FromName != "" {
+		v.Set("serve.email.from_name", c.FromName)
+	}
+	if c.IMAPAddr != "" {
+		v.Set("serve.email.imap_addr", c.IMAPAddr)
+	}
+	if c.IMAPUsername != "" {
+		v.Set("serve.email.imap_username", c.IMAPUsername)
+	}
+	if c.IMAPPassword != "" {
+		v.Set("serve.email.imap_password", c.IMAPPassword)
+	}
+	if c.LMTPListen != "" {
+		v.Set("serve.email.lmtp_listen", c.LMTPListen)
+	}
+	if c.SMTPUsername != "" {
+		v.Set("serve.email.smtp_username", c.SMTPUsername)
+	}
+	if c.SMTPPassword != "" {
+		v.Set("serve.email.smtp_password", c.SMTPPassword)
+	}
+
+	return writeConfigPreservingEnvCase(v)
+}
+
+// SetServeMatrixConfig saves Matrix platform configuration using viper.
+func SetServeMatrixConfig(c MatrixServeConfig) error {
+	configPath, err := GetConfigPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(configPath)
+	v.SetConfigType("yaml")
+	_ = v.ReadInConfig()
+
+	v.Set("serve.matrix.homeserver_url", c.HomeserverURL)
+	v.Set("serve.matrix.access_token", c.AccessToken)
+	v.Set("serve.matrix.allowed_user_ids", c.AllowedUserIDs)
+	if len(c.AllowedRoomIDs) > 0 {
+		v.Set("serve.matrix.allowed_room_ids", c.AllowedRoomIDs)
+	}
+	if len(c.ApproverIDs) > 0 {
+		v.Set("serve.matrix.approver_ids", c.ApproverIDs)
+	}
+	if c.SessionScope != "" {
+		v.Set("serve.matrix.session_scope", c.SessionScope)
+	}
+	if c.IdleTimeout > 0 {
+		v.Set("serve.matrix.idle_timeout", c.IdleTimeout)
+	}
+
+	return writeConfigPreservingEnvCase(v)
+}
+
 // SetServeWebPushConfig saves Web Push VAPID configuration using viper.
 func SetServeWebPushConfig(c WebPushConfig) error {
 	configPath, err := GetConfigPath()
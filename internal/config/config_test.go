@@ -115,6 +115,43 @@ func TestLoad_GuardianClassifyAllShell(t *testing.T) {
 	}
 }
 
+func TestLoadTracingReadsOnlyTracingSection(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Chdir(t.TempDir())
+	if cfg, err := LoadTracing(); err != nil || cfg.Endpoint != "" || cfg.File != "" {
+		t.Fatalf("LoadTracing without config = %+v, %v; want zero value", cfg, err)
+	}
+
+	configDir := filepath.Join(configHome, "term-llm")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("mkdir config dir: %v", err)
+	}
+	configYAML := `default_provider: anthropic
+tracing:
+  endpoint: http://localhost:4318
+  headers:
+    authorization: Bearer t
+  sample_ratio: 0.5
+`
+	if err := os.WriteFile(filepath.Join(configDir, "config.yaml"), []byte(configYAML), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := LoadTracing()
+	if err != nil {
+		t.Fatalf("LoadTracing: %v", err)
+	}
+	if cfg.Endpoint != "http://localhost:4318" || cfg.Headers["authorization"] != "Bearer t" || cfg.SampleRatio != 0.5 {
+		t.Fatalf("LoadTracing = %+v", cfg)
+	}
+	if viper.ConfigFileUsed() != "" || viper.IsSet("default_provider") {
+		t.Fatal("LoadTracing touched the global viper state")
+	}
+}
+
 func TestLoad_ProviderUseWebSocket(t *testing.T) {
 	viper.Reset()
 	defer viper.Reset()
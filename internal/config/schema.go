@@ -248,6 +248,7 @@ var keySpecs = []KeySpec{
 	def("embed.voyage.model", DefaultEmbedVoyageModel),
 	def("embed.ollama.base_url", DefaultEmbedOllamaBaseURL),
 	def("embed.ollama.model", DefaultEmbedOllamaModel),
+	optional("embed.aliases", withPlaceholder(map[string]any{})),
 
 	def("search.provider", DefaultSearchProvider),
 	def("search.fetch_provider", DefaultSearchFetchProvider),
@@ -347,6 +348,12 @@ var keySpecs = []KeySpec{
 	def("debug_logs.enabled", false),
 	def("debug_logs.dir", ""),
 
+	optional("tracing.endpoint"),
+	optional("tracing.headers", withPlaceholder(map[string]any{}), sensitive()),
+	optional("tracing.file"),
+	optional("tracing.service_name"),
+	optional("tracing.sample_ratio"),
+
 	optional("loop.approval_mode", withoutResetTemplate()),
 
 	def("serve.base_path", DefaultServeBasePath),
@@ -362,10 +369,54 @@ var keySpecs = []KeySpec{
 	optional("serve.telegram.allowed_usernames", withPlaceholder([]string{})),
 	optional("serve.telegram.idle_timeout", withPlaceholder(30)),
 	optional("serve.telegram.interrupt_timeout", withPlaceholder(3)),
+	optional("serve.slack.bot_token", sensitive()),
+	optional("serve.slack.app_token", sensitive()),
+	optional("serve.slack.signing_secret", sensitive()),
+	optional("serve.slack.events_listen", withPlaceholder("127.0.0.1:3000")),
+	optional("serve.slack.allowed_user_ids", withPlaceholder([]string{})),
+	optional("serve.slack.allowed_channel_ids", withPlaceholder([]string{})),
+	optional("serve.slack.idle_timeout", withPlaceholder(30)),
+	optional("serve.slack.api_url", withPlaceholder("https://slack.com/api/")),
+	optional("serve.discord.token", sensitive()),
+	optional("serve.discord.allowed_user_ids", withPlaceholder([]string{})),
+	optional("serve.discord.allowed_role_ids", withPlaceholder([]string{})),
+	optional("serve.discord.allowed_channel_ids", withPlaceholder([]string{})),
+	optional("serve.discord.approver_ids", withPlaceholder([]string{})),
+	optional("serve.discord.session_scope", withPlaceholder("thread")),
+	optional("serve.discord.idle_timeout", withPlaceholder(30)),
+	optional("serve.discord.api_url", withPlaceholder("https://discord.com/api/v10")),
+	optional("serve.email.address"),
+	optional("serve.email.from_name"),
+	optional("serve.email.imap_addr", withPlaceholder("imap.example.com:993")),
+	optional("serve.email.imap_username"),
+	optional("serve.email.imap_password", sensitive()),
+	optional("serve.email.imap_mailbox", withPlaceholder("INBOX")),
+	optional("serve.email.imap_security", withPlaceholder("tls")),
+	optional("serve.email.poll_interval", withPlaceholder(60)),
+	optional("serve.email.lmtp_listen"),
+	optional("serve.email.smtp_addr", withPlaceholder("smtp.example.com:587")),
+	optional("serve.email.smtp_username"),
+	optional("serve.email.smtp_password", sensitive()),
+	optional("serve.email.smtp_security", withPlaceholder("starttls")),
+	optional("serve.email.allowed_senders", withPlaceholder([]string{})),
+	optional("serve.email.sender_agents"),
+	optional("serve.email.idle_timeout", withPlaceholder(30)),
+	optional("serve.matrix.homeserver_url", withPlaceholder("https://matrix.example.org")),
+	optional("serve.matrix.access_token", sensitive()),
+	optional("serve.matrix.allowed_user_ids", withPlaceholder([]string{})),
+	optional("serve.matrix.allowed_room_ids", withPlaceholder([]string{})),
+	optional("serve.matrix.approver_ids", withPlaceholder([]string{})),
+	optional("serve.matrix.session_scope", withPlaceholder("thread")),
+	optional("serve.matrix.room_agents"),
+	optional("serve.matrix.idle_timeout", withPlaceholder(30)),
 	optional("serve.web_push.vapid_public_key", sensitive()),
 	optional("serve.web_push.vapid_private_key", sensitive()),
 	optional("serve.web_push.subject"),
 	optional("serve.mcp.approval_mode", withoutResetTemplate()),
+	optional("serve.webhooks"),
+	optional("serve.batch.concurrency"),
+	optional("serve.batch.native"),
+	optional("serve.batch.poll_interval", withPlaceholder("30s")),
 
 	def("file_tracking.enabled", false),
 	def("file_tracking.max_file_bytes", DefaultFileTrackingMaxFileBytes),
@@ -154,6 +154,48 @@ func inferEmbeddingProvider(cfg *config.Config) string {
 	return ""
 }
 
+// IsProvider reports whether name is an embedding provider accepted by
+// NewEmbeddingProvider.
+func IsProvider(name string) bool {
+	switch name {
+	case "gemini", "openai", "jina", "voyage", "ollama":
+		return true
+	}
+	return false
+}
+
+// ProviderForModel returns the provider that serves a well-known embedding
+// model name, or empty string when the name is not recognized.
+func ProviderForModel(model string) string {
+	m := strings.ToLower(strings.TrimSpace(model))
+	switch {
+	case strings.HasPrefix(m, "text-embedding-3-"), m == "text-embedding-ada-002":
+		return "openai"
+	case strings.HasPrefix(m, "gemini-embedding-"), strings.HasPrefix(m, "text-embedding-0"), strings.HasPrefix(m, "text-multilingual-embedding-"):
+		return "gemini"
+	case strings.HasPrefix(m, "jina-"):
+		return "jina"
+	case strings.HasPrefix(m, "voyage-"):
+		return "voyage"
+	}
+	return ""
+}
+
+// MaxBatchSize returns how many texts a single Embed call to provider may
+// carry.
+func MaxBatchSize(provider string) int {
+	switch provider {
+	case "openai", "jina":
+		return 2048
+	case "gemini":
+		return 100
+	case "voyage":
+		return 128
+	default:
+		return 64
+	}
+}
+
 // parseProviderModel parses "provider:model" or just "provider" from a string.
 func parseProviderModel(s string) (string, string) {
 	parts := strings.SplitN(s, ":", 2)
@@ -24,6 +24,23 @@ func TestNewEmbeddingProviderResolvesOllamaBaseURL(t *testing.T) {
 	}
 }
 
+func TestProviderForModel(t *testing.T) {
+	tests := map[string]string{
+		"text-embedding-3-small": "openai",
+		"text-embedding-ada-002": "openai",
+		"text-embedding-004":     "gemini",
+		"gemini-embedding-001":   "gemini",
+		"jina-embeddings-v3":     "jina",
+		"voyage-3.5":             "voyage",
+		"nomic-embed-text":       "",
+	}
+	for model, want := range tests {
+		if got := ProviderForModel(model); got != want {
+			t.Errorf("ProviderForModel(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
+
 func TestCosineSimilarity(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,217 @@
+package filetrack
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RevertToolName is the tool name recorded for changes made by Revert, so a
+// revert shows up in the session's history like any other edit.
+const RevertToolName = "revert"
+
+// ErrNothingToRevert means the requested path or range has no net change.
+var ErrNothingToRevert = errors.New("no recorded changes to revert")
+
+// ErrRevertUnavailable means the recorded snapshot cannot be restored, either
+// because its content was not retained or because it is a binary file.
+var ErrRevertUnavailable = errors.New("recorded content was not retained; cannot revert")
+
+// ErrRevertConflict means the file on disk no longer matches the recorded
+// change closely enough to undo it safely.
+var ErrRevertConflict = errors.New("file has changed since it was recorded")
+
+// ErrInvalidHunk means the request named no hunks, or a hunk index outside
+// the diff being reverted.
+var ErrInvalidHunk = errors.New("invalid hunk selection")
+
+// RevertRequest selects what to restore. Range narrows the diff being undone
+// (the zero value is the whole session); Hunks picks hunk indexes within that
+// diff's BuildHunks output, and nil reverts the whole file.
+type RevertRequest struct {
+	SessionID string
+	Path      string
+	Range     SeqRange
+	Hunks     []int
+}
+
+// Revert undoes a recorded file change on disk and records the revert as a
+// new change in the session. When the file still matches the recorded state
+// the snapshot is restored byte for byte; otherwise the diff's hunks are
+// reverse-applied so later unrelated edits survive, failing with
+// ErrRevertConflict when a hunk no longer matches.
+func (s *Store) Revert(ctx context.Context, req RevertRequest) (*Change, error) {
+	content, err := s.GetFileDiffContentRange(ctx, req.SessionID, req.Path, req.Range)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, ErrNothingToRevert
+	}
+	if content.Truncated || content.IsImage {
+		return nil, ErrRevertUnavailable
+	}
+
+	current, err := os.ReadFile(content.Path)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read %s: %w", content.Path, err)
+	}
+
+	if req.Hunks != nil && len(req.Hunks) == 0 {
+		return nil, fmt.Errorf("%w: no hunks listed", ErrInvalidHunk)
+	}
+
+	var next []byte
+	remove := false
+	switch {
+	case req.Hunks != nil:
+		if !exists {
+			return nil, ErrRevertConflict
+		}
+		all := BuildHunks(content.Path, content.Before, content.After)
+		selected := make([]Hunk, 0, len(req.Hunks))
+		for _, idx := range req.Hunks {
+			if idx < 0 || idx >= len(all) {
+				return nil, fmt.Errorf("%w: hunk %d out of range", ErrInvalidHunk, idx)
+			}
+			selected = append(selected, all[idx])
+		}
+		if next, err = ReverseApplyHunks(current, selected); err != nil {
+			return nil, err
+		}
+	case content.Kind == KindDelete:
+		if exists {
+			return nil, ErrRevertConflict
+		}
+		next = content.Before
+	case !exists:
+		return nil, ErrRevertConflict
+	case bytes.Equal(current, content.After):
+		next = content.Before
+		remove = content.Kind == KindCreate
+	default:
+		if next, err = ReverseApplyHunks(current, BuildHunks(content.Path, content.Before, content.After)); err != nil {
+			return nil, err
+		}
+		remove = content.Kind == KindCreate && len(next) == 0
+	}
+
+	if remove {
+		if err := os.Remove(content.Path); err != nil {
+			return nil, fmt.Errorf("remove %s: %w", content.Path, err)
+		}
+	} else if err := writeRevertedFile(content.Path, next); err != nil {
+		return nil, err
+	}
+
+	return s.RecordChange(ctx, ChangeRecord{
+		SessionID:     req.SessionID,
+		ToolName:      RevertToolName,
+		Path:          content.Path,
+		Before:        current,
+		After:         next,
+		BeforeMissing: !exists,
+		AfterMissing:  remove,
+	})
+}
+
+// writeRevertedFile writes data keeping the existing file mode, recreating
+// parent directories for files whose deletion is being undone.
+func writeRevertedFile(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	} else if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReverseApplyHunks undoes hunks (as produced by BuildHunks for before→after)
+// in current, which is expected to resemble the after side. Each hunk is
+// located at its recorded position or, failing that, the nearest offset
+// where its after-side lines match; a hunk that matches nowhere is a
+// conflict. Trailing-newline-only differences are not restored.
+func ReverseApplyHunks(current []byte, hunks []Hunk) ([]byte, error) {
+	text := string(current)
+	trailingNewline := text == "" || strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if text == "" {
+		lines = nil
+	}
+
+	// Apply bottom-up so earlier hunks keep their recorded positions.
+	ordered := append([]Hunk(nil), hunks...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].NewStart > ordered[j].NewStart })
+	for _, h := range ordered {
+		var oldSide, newSide []string
+		for _, line := range h.Lines {
+			switch line.T {
+			case "add":
+				newSide = append(newSide, line.S)
+			case "del":
+				oldSide = append(oldSide, line.S)
+			default:
+				oldSide = append(oldSide, line.S)
+				newSide = append(newSide, line.S)
+			}
+		}
+		// A unified diff with an empty new side names the line before it.
+		want := h.NewStart - 1
+		if len(newSide) == 0 {
+			want = h.NewStart
+		}
+		at := findLines(lines, newSide, want)
+		if at < 0 {
+			return nil, fmt.Errorf("%w: hunk at line %d does not match", ErrRevertConflict, h.NewStart)
+		}
+		replaced := make([]string, 0, len(lines)-len(newSide)+len(oldSide))
+		replaced = append(replaced, lines[:at]...)
+		replaced = append(replaced, oldSide...)
+		replaced = append(replaced, lines[at+len(newSide):]...)
+		lines = replaced
+	}
+
+	if len(lines) == 0 {
+		return []byte{}, nil
+	}
+	out := strings.Join(lines, "\n")
+	if trailingNewline {
+		out += "\n"
+	}
+	return []byte(out), nil
+}
+
+// findLines returns the index of needle in lines closest to want, or -1.
+func findLines(lines, needle []string, want int) int {
+	want = max(0, min(want, len(lines)))
+	matches := func(at int) bool {
+		if at < 0 || at+len(needle) > len(lines) {
+			return false
+		}
+		for i, line := range needle {
+			if lines[at+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+	for offset := 0; offset <= len(lines); offset++ {
+		if matches(want - offset) {
+			return want - offset
+		}
+		if offset > 0 && matches(want+offset) {
+			return want + offset
+		}
+	}
+	return -1
+}
@@ -0,0 +1,151 @@
+package filetrack
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTracked writes content to path and records the transition.
+func writeTracked(t *testing.T, store *Store, toolCallID, path, content string) *Change {
+	t.Helper()
+	before, err := os.ReadFile(path)
+	missing := os.IsNotExist(err)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return mustRecord(t, store, ChangeRecord{
+		SessionID: "s", ToolName: "edit_file", ToolCallID: toolCallID, Path: path,
+		Before: before, After: []byte(content), BeforeMissing: missing,
+	})
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRevertWholeFileAndTurnRange(t *testing.T) {
+	store := openTestStore(t, Options{})
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	created := filepath.Join(dir, "new.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	first := writeTracked(t, store, "call-1", path, "one\nTWO\nthree\n")
+	writeTracked(t, store, "call-2", path, "one\nTWO\nthree\nfour\n")
+	last := writeTracked(t, store, "call-2", created, "hello\n")
+
+	// Reverting only the second turn keeps the first turn's edit.
+	turn := SeqRange{From: first.Seq + 1, To: last.Seq}
+	turnChanges, err := store.ListSessionChangesRange(ctx, "s", turn)
+	if err != nil || len(turnChanges) != 2 {
+		t.Fatalf("turn changes = %+v, %v", turnChanges, err)
+	}
+	for _, c := range turnChanges {
+		if _, err := store.Revert(ctx, RevertRequest{SessionID: "s", Path: c.Path, Range: turn}); err != nil {
+			t.Fatalf("revert %s: %v", c.Path, err)
+		}
+	}
+	if got := readFile(t, path); got != "one\nTWO\nthree\n" {
+		t.Fatalf("after turn revert = %q", got)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Fatalf("created file survived revert: %v", err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Fatalf("mode = %o, want 0600", info.Mode().Perm())
+	}
+
+	// The reverts are recorded, so the cumulative view only shows turn one.
+	changes := mustList(t, store, "s")
+	if len(changes) != 1 || changes[0].Path != path || changes[0].Adds != 1 || changes[0].Dels != 1 {
+		t.Fatalf("cumulative after revert = %+v", changes)
+	}
+
+	if _, err := store.Revert(ctx, RevertRequest{SessionID: "s", Path: path}); err != nil {
+		t.Fatalf("revert file: %v", err)
+	}
+	if got := readFile(t, path); got != "one\ntwo\nthree\n" {
+		t.Fatalf("after file revert = %q", got)
+	}
+	if _, err := store.Revert(ctx, RevertRequest{SessionID: "s", Path: path}); !errors.Is(err, ErrNothingToRevert) {
+		t.Fatalf("second revert err = %v", err)
+	}
+}
+
+func TestRevertHunksKeepsLaterEdits(t *testing.T) {
+	store := openTestStore(t, Options{})
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "big.txt")
+	base := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+	if err := os.WriteFile(path, []byte(base), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeTracked(t, store, "call-1", path, "A\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nN\n")
+
+	content, err := store.GetFileDiffContent(ctx, "s", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hunks := BuildHunks(path, content.Before, content.After); len(hunks) != 2 {
+		t.Fatalf("hunks = %d, want 2", len(hunks))
+	}
+	for _, hunks := range [][]int{{2}, {-1}, {}} {
+		if _, err := store.Revert(ctx, RevertRequest{SessionID: "s", Path: path, Hunks: hunks}); !errors.Is(err, ErrInvalidHunk) {
+			t.Fatalf("revert hunks %v err = %v, want ErrInvalidHunk", hunks, err)
+		}
+	}
+	if _, err := store.Revert(ctx, RevertRequest{SessionID: "s", Path: path, Hunks: []int{1}}); err != nil {
+		t.Fatalf("revert hunk: %v", err)
+	}
+	if got := readFile(t, path); got != "A\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n" {
+		t.Fatalf("after hunk revert = %q", got)
+	}
+
+	// An untracked edit elsewhere survives a whole-file revert; one that
+	// touches the hunk itself is a conflict.
+	if err := os.WriteFile(path, []byte("A\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\nextra\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Revert(ctx, RevertRequest{SessionID: "s", Path: path}); err != nil {
+		t.Fatalf("revert with unrelated edit: %v", err)
+	}
+	if got := readFile(t, path); got != base+"extra\n" {
+		t.Fatalf("after merge revert = %q", got)
+	}
+
+	writeTracked(t, store, "call-2", path, "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\nextra\nmore\n")
+	if err := os.WriteFile(path, []byte("a\nb\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Revert(ctx, RevertRequest{SessionID: "s", Path: path}); !errors.Is(err, ErrRevertConflict) {
+		t.Fatalf("conflicting revert err = %v", err)
+	}
+}
+
+func TestRevertRestoresDeletedFile(t *testing.T) {
+	store := openTestStore(t, Options{})
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sub", "gone.txt")
+	mustRecord(t, store, ChangeRecord{SessionID: "s", Path: path, Before: []byte("kept\n"), AfterMissing: true})
+
+	if _, err := store.Revert(ctx, RevertRequest{SessionID: "s", Path: path}); err != nil {
+		t.Fatalf("revert delete: %v", err)
+	}
+	if got := readFile(t, path); got != "kept\n" {
+		t.Fatalf("restored = %q", got)
+	}
+	if _, err := store.Revert(ctx, RevertRequest{SessionID: "s", Path: path}); !errors.Is(err, ErrNothingToRevert) {
+		t.Fatalf("revert after restore err = %v", err)
+	}
+}
@@ -79,6 +79,15 @@ type Change struct {
 	IsBinary   bool
 }
 
+// SeqRange bounds a listing to the change rows with From <= seq <= To. A zero
+// bound is open, so the zero value covers the whole session.
+type SeqRange struct {
+	From int64 `json:"from,omitempty"`
+	To   int64 `json:"to,omitempty"`
+}
+
+const seqRangeSQL = `seq >= ? AND (? = 0 OR seq <= ?)`
+
 // CumulativeChange summarizes a file's net change relative to the session baseline.
 type CumulativeChange struct {
 	Path      string `json:"path"`
@@ -568,6 +577,33 @@ func (s *Store) SessionPaths(ctx context.Context, sessionID string) ([]string, e
 	return paths, rows.Err()
 }
 
+// ListChanges returns a session's change rows in sequence order. Callers use
+// the tool call IDs to group rows into turns.
+func (s *Store) ListChanges(ctx context.Context, sessionID string) ([]Change, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT seq, path, kind, COALESCE(tool_name, ''), COALESCE(tool_call_id, ''),
+			COALESCE(before_hash, ''), COALESCE(after_hash, ''), before_size, after_size,
+			adds, dels, truncated, is_binary
+		FROM file_changes WHERE session_id = ? ORDER BY seq`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("query session changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var c Change
+		if err := rows.Scan(&c.Seq, &c.Path, &c.Kind, &c.ToolName, &c.ToolCallID,
+			&c.BeforeHash, &c.AfterHash, &c.BeforeSize, &c.AfterSize,
+			&c.Adds, &c.Dels, &c.Truncated, &c.IsBinary); err != nil {
+			return nil, err
+		}
+		c.Path = normalizePath(c.Path)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
 // pathSpan is the fold of all change rows for one path: its baseline (first
 // row) and latest state (last row).
 type pathSpan struct {
@@ -581,10 +617,11 @@ type pathSpan struct {
 	lastSeq         int64
 }
 
-func (s *Store) sessionSpans(ctx context.Context, sessionID string) ([]*pathSpan, error) {
+func (s *Store) sessionSpans(ctx context.Context, sessionID string, r SeqRange) ([]*pathSpan, error) {
 	rows, err := s.db.QueryContext(ctx, `
 		SELECT seq, path, kind, COALESCE(before_hash, ''), COALESCE(after_hash, ''), is_binary
-		FROM file_changes WHERE session_id = ? ORDER BY seq`, sessionID)
+		FROM file_changes WHERE session_id = ? AND `+seqRangeSQL+` ORDER BY seq`,
+		sessionID, r.From, r.To, r.To)
 	if err != nil {
 		return nil, fmt.Errorf("query session changes: %w", err)
 	}
@@ -625,7 +662,7 @@ func (s *Store) sessionSpans(ctx context.Context, sessionID string) ([]*pathSpan
 	return result, nil
 }
 
-func (s *Store) sessionPathSpan(ctx context.Context, sessionID, path string) (*pathSpan, error) {
+func (s *Store) sessionPathSpan(ctx context.Context, sessionID, path string, r SeqRange) (*pathSpan, error) {
 	path = normalizePath(path)
 	if path == "" {
 		return nil, nil
@@ -634,8 +671,8 @@ func (s *Store) sessionPathSpan(ctx context.Context, sessionID, path string) (*p
 	if err := s.db.QueryRowContext(ctx, `
 		SELECT kind, COALESCE(before_hash, ''), is_binary
 		FROM file_changes
-		WHERE session_id = ? AND path = ?
-		ORDER BY seq ASC LIMIT 1`, sessionID, path).
+		WHERE session_id = ? AND path = ? AND `+seqRangeSQL+`
+		ORDER BY seq ASC LIMIT 1`, sessionID, path, r.From, r.To, r.To).
 		Scan(&sp.firstKind, &sp.firstBeforeHash, &sp.firstBinary); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -645,8 +682,8 @@ func (s *Store) sessionPathSpan(ctx context.Context, sessionID, path string) (*p
 	if err := s.db.QueryRowContext(ctx, `
 		SELECT seq, kind, COALESCE(after_hash, ''), is_binary
 		FROM file_changes
-		WHERE session_id = ? AND path = ?
-		ORDER BY seq DESC LIMIT 1`, sessionID, path).
+		WHERE session_id = ? AND path = ? AND `+seqRangeSQL+`
+		ORDER BY seq DESC LIMIT 1`, sessionID, path, r.From, r.To, r.To).
 		Scan(&sp.lastSeq, &sp.lastKind, &sp.lastAfterHash, &sp.lastBinary); err != nil {
 		return nil, fmt.Errorf("query last path change: %w", err)
 	}
@@ -708,7 +745,13 @@ func blobsNeeded(kind string) (needBefore, needAfter bool) {
 // ListSessionChanges returns the cumulative per-file changes for a session,
 // sorted by path. Net no-ops are omitted.
 func (s *Store) ListSessionChanges(ctx context.Context, sessionID string) ([]CumulativeChange, error) {
-	spans, err := s.sessionSpans(ctx, sessionID)
+	return s.ListSessionChangesRange(ctx, sessionID, SeqRange{})
+}
+
+// ListSessionChangesRange is ListSessionChanges restricted to the change rows
+// inside r, so the baseline is the file state before the range's first change.
+func (s *Store) ListSessionChangesRange(ctx context.Context, sessionID string, r SeqRange) ([]CumulativeChange, error) {
+	spans, err := s.sessionSpans(ctx, sessionID, r)
 	if err != nil {
 		return nil, err
 	}
@@ -776,7 +819,13 @@ func (s *Store) ListSessionChanges(ctx context.Context, sessionID string) ([]Cum
 // GetFileDiffContent returns the baseline and current contents for one path
 // in a session, or nil when the path has no net change recorded.
 func (s *Store) GetFileDiffContent(ctx context.Context, sessionID, path string) (*FileDiffContent, error) {
-	sp, err := s.sessionPathSpan(ctx, sessionID, path)
+	return s.GetFileDiffContentRange(ctx, sessionID, path, SeqRange{})
+}
+
+// GetFileDiffContentRange is GetFileDiffContent restricted to the change rows
+// inside r.
+func (s *Store) GetFileDiffContentRange(ctx context.Context, sessionID, path string, r SeqRange) (*FileDiffContent, error) {
+	sp, err := s.sessionPathSpan(ctx, sessionID, path, r)
 	if err != nil || sp == nil {
 		return nil, err
 	}
@@ -830,7 +879,7 @@ func (s *Store) GetFileDiffSide(ctx context.Context, sessionID, path, side strin
 	if side != "before" && side != "after" {
 		return nil, ErrInvalidDiffSide
 	}
-	sp, err := s.sessionPathSpan(ctx, sessionID, path)
+	sp, err := s.sessionPathSpan(ctx, sessionID, path, SeqRange{})
 	if err != nil || sp == nil {
 		return nil, err
 	}
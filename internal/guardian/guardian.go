@@ -12,8 +12,13 @@ import (
 	"time"
 
 	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("guardian")
+
 const (
 	DefaultTimeout = 90 * time.Second
 	// Bound process-local guardian review sessions so long-running auto mode does
@@ -253,6 +258,22 @@ func cleanupReviewer(reviewer *Reviewer) {
 }
 
 func (r *Reviewer) Review(ctx context.Context, req Request) (Decision, error) {
+	ctx, span := tracer.Start(ctx, "guardian.review", trace.WithAttributes(
+		attribute.String("gen_ai.tool.name", req.ToolName),
+		attribute.String("gen_ai.request.model", strings.TrimSpace(r.Model)),
+	))
+	decision, err := r.review(ctx, req)
+	span.SetAttributes(
+		attribute.String("term_llm.guardian.outcome", decision.Outcome),
+		attribute.String("term_llm.guardian.risk_level", decision.RiskLevel),
+		attribute.Int("gen_ai.usage.input_tokens", decision.Usage.InputTokens),
+		attribute.Int("gen_ai.usage.output_tokens", decision.Usage.OutputTokens),
+	)
+	tracing.EndSpan(span, err)
+	return decision, err
+}
+
+func (r *Reviewer) review(ctx context.Context, req Request) (Decision, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -0,0 +1,282 @@
+package hub
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAuditMaxBytes is the size at which the active audit file rotates.
+	DefaultAuditMaxBytes int64 = 10 << 20
+	// DefaultAuditMaxFiles is how many rotated files are kept besides the
+	// active one; the oldest is deleted on rotation.
+	DefaultAuditMaxFiles = 5
+	// auditMaxLineBytes bounds one event when reading the log back.
+	auditMaxLineBytes = 64 * 1024
+)
+
+// Audit outcomes, derived from the response status.
+const (
+	AuditOutcomeOK     = "ok"
+	AuditOutcomeDenied = "denied"
+	AuditOutcomeError  = "error"
+)
+
+// AuditEvent is one audited hub action. Events never carry tokens, request
+// bodies or prompts: only who acted, on what, and how it ended.
+type AuditEvent struct {
+	Time time.Time `json:"time"`
+	// Actor identifies the caller: "passkey:<credential>", "hub-token",
+	// "node:<id>", "registration", or "anonymous".
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	Method string `json:"method"`
+	Route  string `json:"route"`
+	Node   string `json:"node,omitempty"`
+	// DelegationID is set for delegation create and cancel.
+	DelegationID string `json:"delegation_id,omitempty"`
+	Status       int    `json:"status"`
+	Outcome      string `json:"outcome"`
+	RemoteAddr   string `json:"remote_addr,omitempty"`
+	DurationMS   int64  `json:"duration_ms"`
+}
+
+// AuditOutcomeForStatus maps an HTTP status onto an audit outcome.
+func AuditOutcomeForStatus(status int) string {
+	switch {
+	case status == 401 || status == 403:
+		return AuditOutcomeDenied
+	case status >= 400:
+		return AuditOutcomeError
+	default:
+		return AuditOutcomeOK
+	}
+}
+
+// AuditQuery filters audit events. Zero fields match everything; Actor and
+// Route match by substring, the rest exactly.
+type AuditQuery struct {
+	Since   time.Time
+	Until   time.Time
+	Actor   string
+	Action  string
+	Node    string
+	Route   string
+	Outcome string
+	// Limit caps the result (newest events win); 0 means no cap.
+	Limit int
+}
+
+func (q AuditQuery) matches(e AuditEvent) bool {
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.Time.Before(q.Until) {
+		return false
+	}
+	if q.Actor != "" && !strings.Contains(e.Actor, q.Actor) {
+		return false
+	}
+	if q.Action != "" && e.Action != q.Action && !strings.HasPrefix(e.Action, q.Action+".") {
+		return false
+	}
+	if q.Node != "" && e.Node != q.Node {
+		return false
+	}
+	if q.Route != "" && !strings.Contains(e.Route, q.Route) {
+		return false
+	}
+	if q.Outcome != "" && e.Outcome != q.Outcome {
+		return false
+	}
+	return true
+}
+
+// AuditLog appends audit events as JSON lines to a 0600 file and rotates it
+// by size: path, path.1 (newest rotated), ... path.<MaxFiles>.
+type AuditLog struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+// NewAuditLog returns a log backed by path. maxBytes and maxFiles fall back
+// to the defaults when not positive. The file is opened lazily on first
+// Record.
+func NewAuditLog(path string, maxBytes int64, maxFiles int) *AuditLog {
+	if maxBytes <= 0 {
+		maxBytes = DefaultAuditMaxBytes
+	}
+	if maxFiles <= 0 {
+		maxFiles = DefaultAuditMaxFiles
+	}
+	return &AuditLog{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+}
+
+// Path returns the active log file path.
+func (l *AuditLog) Path() string { return l.path }
+
+// Record appends e, rotating first when the active file is full.
+func (l *AuditLog) Record(e AuditEvent) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	if e.Outcome == "" {
+		e.Outcome = AuditOutcomeForStatus(e.Status)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode hub audit event: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.openLocked(); err != nil {
+		return err
+	}
+	if l.size > 0 && l.size+int64(len(data)) > l.maxBytes {
+		if err := l.rotateLocked(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write hub audit log: %w", err)
+	}
+	return nil
+}
+
+// Close closes the active file; a later Record reopens it.
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+func (l *AuditLog) openLocked() error {
+	if l.file != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return fmt.Errorf("create hub audit log dir: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("open hub audit log: %w", err)
+	}
+	// Correct an existing overly-permissive file, like the delegation ledger.
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return fmt.Errorf("secure hub audit log permissions: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat hub audit log: %w", err)
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+func (l *AuditLog) rotateLocked() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("close hub audit log: %w", err)
+	}
+	l.file = nil
+	_ = os.Remove(l.rotatedPath(l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(l.rotatedPath(i), l.rotatedPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rotate hub audit log: %w", err)
+		}
+	}
+	if err := os.Rename(l.path, l.rotatedPath(1)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("rotate hub audit log: %w", err)
+	}
+	return l.openLocked()
+}
+
+func (l *AuditLog) rotatedPath(i int) string {
+	return l.path + "." + strconv.Itoa(i)
+}
+
+// Query returns the events matching q, newest first, reading the active file
+// and every rotated file still on disk. Unparseable lines are skipped.
+func (l *AuditLog) Query(q AuditQuery) ([]AuditEvent, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return ReadAuditLog(l.path, l.maxFiles, q)
+}
+
+// ReadAuditLog queries the audit files rooted at path without an open
+// AuditLog, for offline inspection (serve hub audit). Missing files are not
+// an error.
+func ReadAuditLog(path string, maxFiles int, q AuditQuery) ([]AuditEvent, error) {
+	if maxFiles <= 0 {
+		maxFiles = DefaultAuditMaxFiles
+	}
+	var out []AuditEvent
+	// Newest file first; each file is read in order and reversed.
+	for i := 0; i <= maxFiles; i++ {
+		p := path
+		if i > 0 {
+			p = path + "." + strconv.Itoa(i)
+		}
+		events, err := readAuditFile(p, q)
+		if err != nil {
+			return nil, err
+		}
+		for j := len(events) - 1; j >= 0; j-- {
+			out = append(out, events[j])
+			if q.Limit > 0 && len(out) >= q.Limit {
+				return out, nil
+			}
+		}
+	}
+	return out, nil
+}
+
+func readAuditFile(path string, q AuditQuery) ([]AuditEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read hub audit log: %w", err)
+	}
+	defer f.Close()
+	var events []AuditEvent
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 4096), auditMaxLineBytes)
+	for scanner.Scan() {
+		var e AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if q.matches(e) {
+			events = append(events, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read hub audit log %s: %w", path, err)
+	}
+	return events, nil
+}
@@ -0,0 +1,105 @@
+package hub
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditLogRecordAndQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hub", "audit.jsonl")
+	l := NewAuditLog(path, 0, 0)
+	t.Cleanup(func() { l.Close() })
+
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	events := []AuditEvent{
+		{Time: base, Actor: "hub-token", Action: "proxy", Node: "alpha", Method: "GET", Route: "/node/alpha/", Status: 200},
+		{Time: base.Add(time.Minute), Actor: "node:beta", Action: "delegation.create", Node: "gamma", DelegationID: "dlg_1", Status: 201},
+		{Time: base.Add(2 * time.Minute), Actor: "anonymous", Action: "request", Method: "GET", Route: "/api/nodes", Status: 401},
+	}
+	for _, e := range events {
+		if err := l.Record(e); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("audit log mode = %o, want 600", perm)
+	}
+
+	all, err := l.Query(AuditQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].Action != "request" || all[2].Action != "proxy" {
+		t.Fatalf("Query all = %+v, want newest first", all)
+	}
+	if all[0].Outcome != AuditOutcomeDenied || all[1].Outcome != AuditOutcomeOK {
+		t.Fatalf("outcomes = %q %q", all[0].Outcome, all[1].Outcome)
+	}
+
+	cases := []struct {
+		name string
+		q    AuditQuery
+		want int
+	}{
+		{"action family", AuditQuery{Action: "delegation"}, 1},
+		{"action exact", AuditQuery{Action: "proxy"}, 1},
+		{"actor substring", AuditQuery{Actor: "node:"}, 1},
+		{"node", AuditQuery{Node: "alpha"}, 1},
+		{"outcome", AuditQuery{Outcome: AuditOutcomeDenied}, 1},
+		{"since", AuditQuery{Since: base.Add(30 * time.Second)}, 2},
+		{"limit", AuditQuery{Limit: 2}, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := l.Query(tc.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tc.want {
+				t.Fatalf("Query(%+v) = %d events, want %d", tc.q, len(got), tc.want)
+			}
+		})
+	}
+}
+
+func TestAuditLogRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	// Each event is ~200 bytes, so every file holds a couple of events.
+	l := NewAuditLog(path, 400, 2)
+	t.Cleanup(func() { l.Close() })
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		if err := l.Record(AuditEvent{Time: base.Add(time.Duration(i) * time.Second), Actor: "hub-token", Action: "proxy", Node: "alpha", Status: 200}); err != nil {
+			t.Fatalf("Record %d: %v", i, err)
+		}
+	}
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("expected %s: %v", p, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("rotation kept more than 2 files: %v", err)
+	}
+	got, err := ReadAuditLog(path, 2, AuditQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) == 0 || len(got) >= 20 {
+		t.Fatalf("retained %d events, want some but not all", len(got))
+	}
+	if !got[0].Time.Equal(base.Add(19 * time.Second)) {
+		t.Fatalf("newest event = %v", got[0].Time)
+	}
+	for i := 1; i < len(got); i++ {
+		if !got[i].Time.Before(got[i-1].Time) {
+			t.Fatalf("events out of order at %d: %v after %v", i, got[i].Time, got[i-1].Time)
+		}
+	}
+}
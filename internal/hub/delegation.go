@@ -217,6 +217,9 @@ type Delegation struct {
 	Depth    int      `json:"depth"`
 	Chain    []string `json:"chain,omitempty"`
 	ParentID string   `json:"parent_delegation_id,omitempty"`
+	// GroupID links the delegations of one fan-out request: the first member
+	// to succeed wins and the hub cancels the others.
+	GroupID string `json:"group_id,omitempty"`
 	// Response holds the target run's final response (truncated) once the
 	// delegation reaches a terminal status.
 	Response  string    `json:"response,omitempty"`
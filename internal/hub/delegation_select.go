@@ -0,0 +1,137 @@
+package hub
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DelegationRequirements describe what a delegation needs from its target
+// when the origin lets the hub choose the node instead of naming one.
+type DelegationRequirements struct {
+	// Agent and Model must be accepted by the target's delegation policy.
+	Agent string
+	Model string
+	// Labels must all be present on the target (see DelegationCandidate.Labels).
+	Labels []string
+	// MaxQueueDepth, when set, skips targets already running more than this
+	// many delegations; 0 asks for an idle node.
+	MaxQueueDepth *int
+	// Exclude lists node ids that may not be chosen, such as nodes already in
+	// the delegation chain.
+	Exclude []string
+}
+
+// DelegationCandidate is one node considered for a delegation, with the live
+// state the hub knows about it.
+type DelegationCandidate struct {
+	Node   Node
+	Status Status
+	// InFlight is the number of active delegations targeting the node.
+	InFlight int
+}
+
+// Labels returns every label the candidate can be matched on: its configured
+// labels, advertised capabilities, and the capabilities and worker labels its
+// latest probe reported.
+func (c DelegationCandidate) Labels() []string {
+	var labels []string
+	seen := map[string]bool{}
+	for _, group := range [][]string{c.Node.Labels, c.Node.Capabilities, c.Status.Capabilities, c.Status.Labels} {
+		for _, l := range group {
+			l = strings.TrimSpace(l)
+			if l != "" && !seen[l] {
+				seen[l] = true
+				labels = append(labels, l)
+			}
+		}
+	}
+	return labels
+}
+
+// Eligible returns nil when candidate c can accept this delegation from
+// origin, or an error saying why not.
+func (req DelegationRequirements) Eligible(origin Node, c DelegationCandidate) error {
+	n := c.Node
+	if n.ID == origin.ID {
+		return fmt.Errorf("node %q is the originating node", n.ID)
+	}
+	for _, id := range req.Exclude {
+		if id == n.ID {
+			return fmt.Errorf("node %q is already in the delegation chain", n.ID)
+		}
+	}
+	if !origin.CanDelegateTo(n.ID) {
+		return fmt.Errorf("node %q may not delegate to %q", origin.ID, n.ID)
+	}
+	if err := n.AcceptsDelegationFrom(origin.ID); err != nil {
+		return err
+	}
+	if err := n.AcceptsDelegationAgent(req.Agent); err != nil {
+		return err
+	}
+	if err := n.AcceptsDelegationModel(req.Model); err != nil {
+		return err
+	}
+	if !c.Status.Reachable {
+		return fmt.Errorf("node %q is not reachable", n.ID)
+	}
+	// Older serves report no capabilities; only an explicit list without
+	// jobs rules a node out.
+	if len(c.Status.Capabilities) > 0 && !containsString(c.Status.Capabilities, "jobs") {
+		return fmt.Errorf("node %q does not run jobs", n.ID)
+	}
+	if limit := n.DelegationMaxInFlight(); c.InFlight >= limit {
+		return fmt.Errorf("node %q already has %d delegations in flight (max %d)", n.ID, c.InFlight, limit)
+	}
+	if req.MaxQueueDepth != nil && c.InFlight > *req.MaxQueueDepth {
+		return fmt.Errorf("node %q has %d delegations in flight (max_queue_depth %d)", n.ID, c.InFlight, *req.MaxQueueDepth)
+	}
+	labels := c.Labels()
+	for _, want := range req.Labels {
+		if want = strings.TrimSpace(want); want != "" && !containsString(labels, want) {
+			return fmt.Errorf("node %q lacks label %q", n.ID, want)
+		}
+	}
+	return nil
+}
+
+// SelectDelegationTargets returns up to n eligible candidates, best first:
+// the lowest share of the node's in-flight cap, then the lowest probe
+// latency, then node id for a stable order. Rejected holds one reason per
+// ineligible candidate so callers can explain an empty selection.
+func SelectDelegationTargets(origin Node, candidates []DelegationCandidate, req DelegationRequirements, n int) (selected []DelegationCandidate, rejected []error) {
+	for _, c := range candidates {
+		if err := req.Eligible(origin, c); err != nil {
+			rejected = append(rejected, err)
+			continue
+		}
+		selected = append(selected, c)
+	}
+	load := func(c DelegationCandidate) float64 {
+		return float64(c.InFlight) / float64(c.Node.DelegationMaxInFlight())
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		a, b := selected[i], selected[j]
+		if la, lb := load(a), load(b); la != lb {
+			return la < lb
+		}
+		if a.Status.LatencyMS != b.Status.LatencyMS {
+			return a.Status.LatencyMS < b.Status.LatencyMS
+		}
+		return a.Node.ID < b.Node.ID
+	})
+	if n > 0 && len(selected) > n {
+		selected = selected[:n]
+	}
+	return selected, rejected
+}
+
+func containsString(list []string, want string) bool {
+	for _, s := range list {
+		if strings.TrimSpace(s) == want {
+			return true
+		}
+	}
+	return false
+}
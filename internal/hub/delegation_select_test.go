@@ -0,0 +1,76 @@
+package hub
+
+import (
+	"strings"
+	"testing"
+)
+
+func selectTestNode(id string, labels ...string) Node {
+	return Node{ID: id, Labels: labels, Delegation: &DelegationPolicy{Enabled: true, Workdir: "/work", MaxInFlight: 4}}
+}
+
+func TestSelectDelegationTargetsRanksByLoadThenLatency(t *testing.T) {
+	origin := selectTestNode("origin")
+	up := Status{Reachable: true, LatencyMS: 5}
+	fast := Status{Reachable: true, LatencyMS: 1}
+	candidates := []DelegationCandidate{
+		{Node: selectTestNode("busy"), Status: fast, InFlight: 2},
+		{Node: selectTestNode("slow"), Status: up},
+		{Node: selectTestNode("quick"), Status: fast},
+		{Node: selectTestNode("down"), Status: Status{}},
+	}
+	selected, rejected := SelectDelegationTargets(origin, candidates, DelegationRequirements{Agent: DefaultDelegationAgent}, 0)
+	var ids []string
+	for _, c := range selected {
+		ids = append(ids, c.Node.ID)
+	}
+	if got := strings.Join(ids, ","); got != "quick,slow,busy" {
+		t.Fatalf("order = %s", got)
+	}
+	if len(rejected) != 1 || !strings.Contains(rejected[0].Error(), "not reachable") {
+		t.Fatalf("rejected = %v", rejected)
+	}
+
+	selected, _ = SelectDelegationTargets(origin, candidates, DelegationRequirements{Agent: DefaultDelegationAgent}, 2)
+	if len(selected) != 2 {
+		t.Fatalf("limit 2 selected %d", len(selected))
+	}
+}
+
+func TestDelegationRequirementsEligible(t *testing.T) {
+	origin := selectTestNode("origin")
+	up := Status{Reachable: true, Capabilities: []string{"web", "jobs"}, Labels: []string{"repo:x"}}
+	zero := 0
+	cases := []struct {
+		name string
+		req  DelegationRequirements
+		c    DelegationCandidate
+		want string
+	}{
+		{"configured label", DelegationRequirements{Labels: []string{"gpu"}}, DelegationCandidate{Node: selectTestNode("a", "gpu"), Status: up}, ""},
+		{"probed worker label", DelegationRequirements{Labels: []string{"repo:x"}}, DelegationCandidate{Node: selectTestNode("a"), Status: up}, ""},
+		{"missing label", DelegationRequirements{Labels: []string{"gpu"}}, DelegationCandidate{Node: selectTestNode("a"), Status: up}, `lacks label "gpu"`},
+		{"origin", DelegationRequirements{}, DelegationCandidate{Node: selectTestNode("origin"), Status: up}, "originating"},
+		{"excluded", DelegationRequirements{Exclude: []string{"a"}}, DelegationCandidate{Node: selectTestNode("a"), Status: up}, "chain"},
+		{"no jobs", DelegationRequirements{}, DelegationCandidate{Node: selectTestNode("a"), Status: Status{Reachable: true, Capabilities: []string{"web"}}}, "does not run jobs"},
+		{"at cap", DelegationRequirements{}, DelegationCandidate{Node: selectTestNode("a"), Status: up, InFlight: 4}, "max 4"},
+		{"queue depth", DelegationRequirements{MaxQueueDepth: &zero}, DelegationCandidate{Node: selectTestNode("a"), Status: up, InFlight: 1}, "max_queue_depth"},
+		{"model policy", DelegationRequirements{Model: "opus"}, DelegationCandidate{Node: Node{ID: "a", Delegation: &DelegationPolicy{Enabled: true, Workdir: "/work", AllowedModels: []string{"haiku"}}}, Status: up}, "opus"},
+		{"closed", DelegationRequirements{}, DelegationCandidate{Node: Node{ID: "a"}, Status: up}, "a"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.req.Agent = DefaultDelegationAgent
+			err := tc.req.Eligible(origin, tc.c)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("Eligible = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("Eligible = %v, want error containing %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -32,6 +32,8 @@ type DelegationStore struct {
 	path string
 	// now is a hook for prune tests.
 	now func() time.Time
+	// observer is called after a record is added or its status changes.
+	observer func(previousStatus string, d Delegation)
 }
 
 // NewDelegationStore returns a store backed by the given JSON file path. The
@@ -43,6 +45,16 @@ func NewDelegationStore(path string) *DelegationStore {
 // Path returns the backing file path.
 func (s *DelegationStore) Path() string { return s.path }
 
+// SetObserver installs fn to be called after a delegation is added (with an
+// empty previous status) and after an update changes its status. fn runs
+// with the store locked, so it must be quick and must not call back into the
+// store.
+func (s *DelegationStore) SetObserver(fn func(previousStatus string, d Delegation)) {
+	s.mu.Lock()
+	s.observer = fn
+	s.mu.Unlock()
+}
+
 // delegationFile is the on-disk JSON shape.
 type delegationFile struct {
 	Delegations []Delegation `json:"delegations"`
@@ -139,7 +151,13 @@ func (s *DelegationStore) Add(d Delegation) error {
 		}
 	}
 	records = append(records, d)
-	return s.writeLocked(records)
+	if err := s.writeLocked(records); err != nil {
+		return err
+	}
+	if s.observer != nil {
+		s.observer("", d)
+	}
+	return nil
 }
 
 // Get returns a delegation by id.
@@ -171,6 +189,7 @@ func (s *DelegationStore) Update(id string, fn func(*Delegation)) (Delegation, e
 		if records[i].ID != id {
 			continue
 		}
+		previous := records[i].Status
 		fn(&records[i])
 		records[i].UpdatedAt = s.now().UTC()
 		records[i].Response = truncateForLedger(records[i].Response, delegationResponseLimit)
@@ -178,6 +197,9 @@ func (s *DelegationStore) Update(id string, fn func(*Delegation)) (Delegation, e
 		if err := s.writeLocked(records); err != nil {
 			return Delegation{}, err
 		}
+		if s.observer != nil && updated.Status != previous {
+			s.observer(previous, updated)
+		}
 		return updated, nil
 	}
 	return Delegation{}, fmt.Errorf("delegation %q not found", id)
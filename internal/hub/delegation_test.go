@@ -148,6 +148,33 @@ func TestDelegationStoreCRUD(t *testing.T) {
 	}
 }
 
+func TestDelegationStoreObserverSeesStatusChanges(t *testing.T) {
+	s := NewDelegationStore(filepath.Join(t.TempDir(), "delegations.json"))
+	var seen []string
+	s.SetObserver(func(previous string, d Delegation) {
+		seen = append(seen, previous+"->"+d.Status)
+	})
+	now := time.Now().UTC()
+
+	if err := s.Add(newTestDelegation("dlg_1", "a", "b", DelegationStatusPending, now)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := s.Update("dlg_1", func(rec *Delegation) { rec.Status = DelegationStatusRunning }); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := s.Update("dlg_1", func(rec *Delegation) { rec.RunID = "run_1" }); err != nil {
+		t.Fatalf("Update without status change: %v", err)
+	}
+	if _, err := s.Update("dlg_1", func(rec *Delegation) { rec.Status = DelegationStatusSucceeded }); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	want := []string{"->pending", "pending->running", "running->succeeded"}
+	if strings.Join(seen, ",") != strings.Join(want, ",") {
+		t.Fatalf("observed %v, want %v", seen, want)
+	}
+}
+
 func TestDelegationStoreAtomicWriteFailureLeavesExistingLedgerUntouched(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("directory permissions are not reliable on windows")
@@ -0,0 +1,492 @@
+package hub
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// MDNSServiceType is the DNS-SD service type term-llm nodes advertise.
+const MDNSServiceType = "_term-llm._tcp.local."
+
+const (
+	// mdnsTTL is the lifetime of advertised records; resolvers drop nodes
+	// that are not re-announced within it.
+	mdnsTTL = 120 * time.Second
+	// mdnsQueryInterval is how often the resolver re-browses the network.
+	mdnsQueryInterval = 30 * time.Second
+	// mdnsCacheFlush is the mDNS cache-flush bit set on unique records.
+	mdnsCacheFlush = 1 << 15
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// MDNSService is what a node advertises over mDNS. TXT records carry the node
+// ID, name, base path and capabilities — never the node token, which is only
+// handed to a hub through the registration API.
+type MDNSService struct {
+	ID           string
+	Name         string
+	BasePath     string
+	Port         int
+	Capabilities []string
+	// Host is the advertised host name (for example "studio"); the ".local."
+	// suffix is added when packing.
+	Host string
+	// IPs are advertised as A records. Empty means the resolver falls back to
+	// the packet's source address.
+	IPs []net.IP
+}
+
+// MDNSQuery returns a packed PTR query browsing for MDNSServiceType.
+func MDNSQuery() ([]byte, error) {
+	name, err := dnsmessage.NewName(MDNSServiceType)
+	if err != nil {
+		return nil, err
+	}
+	msg := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}},
+	}
+	return msg.Pack()
+}
+
+// MDNSAnnouncement returns a packed response advertising svc: a PTR record
+// for the service type plus SRV, TXT and A records for the instance. A zero
+// ttl packs a goodbye that tells resolvers to drop the node immediately.
+func MDNSAnnouncement(svc MDNSService, ttl time.Duration) ([]byte, error) {
+	if err := ValidateID(svc.ID); err != nil {
+		return nil, err
+	}
+	if svc.Port <= 0 || svc.Port > 65535 {
+		return nil, fmt.Errorf("invalid mdns port %d", svc.Port)
+	}
+	serviceName, err := dnsmessage.NewName(MDNSServiceType)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := dnsmessage.NewName(mdnsInstanceLabel(svc.ID) + "." + MDNSServiceType)
+	if err != nil {
+		return nil, err
+	}
+	host, err := dnsmessage.NewName(mdnsHostLabel(svc.Host, svc.ID) + ".local.")
+	if err != nil {
+		return nil, err
+	}
+	txt := []string{"id=" + svc.ID}
+	if svc.Name != "" {
+		txt = append(txt, "name="+svc.Name)
+	}
+	if svc.BasePath != "" {
+		txt = append(txt, "base_path="+svc.BasePath)
+	}
+	if len(svc.Capabilities) > 0 {
+		txt = append(txt, "caps="+strings.Join(svc.Capabilities, ","))
+	}
+	for _, s := range txt {
+		if len(s) > 255 {
+			return nil, fmt.Errorf("mdns txt record %q too long", s)
+		}
+	}
+
+	secs := uint32(ttl / time.Second)
+	unique := dnsmessage.ClassINET | mdnsCacheFlush
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: serviceName, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET, TTL: secs},
+			Body:   &dnsmessage.PTRResource{PTR: instance},
+		}},
+		Additionals: []dnsmessage.Resource{
+			{
+				Header: dnsmessage.ResourceHeader{Name: instance, Type: dnsmessage.TypeSRV, Class: unique, TTL: secs},
+				Body:   &dnsmessage.SRVResource{Port: uint16(svc.Port), Target: host},
+			},
+			{
+				Header: dnsmessage.ResourceHeader{Name: instance, Type: dnsmessage.TypeTXT, Class: unique, TTL: secs},
+				Body:   &dnsmessage.TXTResource{TXT: txt},
+			},
+		},
+	}
+	for _, ip := range svc.IPs {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			continue
+		}
+		msg.Additionals = append(msg.Additionals, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: host, Type: dnsmessage.TypeA, Class: unique, TTL: secs},
+			Body:   &dnsmessage.AResource{A: [4]byte(ip4)},
+		})
+	}
+	return msg.Pack()
+}
+
+// mdnsInstanceLabel turns a node ID into a single DNS label. The TXT id= record
+// stays authoritative, so the label only needs to be unique-ish and valid.
+func mdnsInstanceLabel(id string) string {
+	label := strings.ReplaceAll(id, ".", "-")
+	if len(label) > 63 {
+		label = label[:63]
+	}
+	return label
+}
+
+// mdnsHostLabel sanitizes a host name into a single DNS label, falling back to
+// the node ID.
+func mdnsHostLabel(host, id string) string {
+	host, _, _ = strings.Cut(strings.TrimSpace(host), ".")
+	if slug, err := SlugID(host); err == nil {
+		return mdnsInstanceLabel(slug)
+	}
+	return mdnsInstanceLabel(id)
+}
+
+// mdnsAnnouncedService is one service parsed from an mDNS response.
+type mdnsAnnouncedService struct {
+	MDNSService
+	TTL time.Duration
+}
+
+// parseMDNSResponse extracts term-llm services from an mDNS response. Records
+// are matched by name across the answer and additional sections; A records
+// missing from the packet fall back to src, the sender's address.
+func parseMDNSResponse(packet []byte, src net.IP) ([]mdnsAnnouncedService, error) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(packet); err != nil {
+		return nil, err
+	}
+	if !msg.Header.Response {
+		return nil, nil
+	}
+	var (
+		instances []string
+		ttls      = map[string]uint32{}
+		srvs      = map[string]*dnsmessage.SRVResource{}
+		txts      = map[string][]string{}
+		addrs     = map[string][]net.IP{}
+	)
+	records := append(append([]dnsmessage.Resource{}, msg.Answers...), msg.Additionals...)
+	for _, rr := range records {
+		name := strings.ToLower(rr.Header.Name.String())
+		switch body := rr.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if name != MDNSServiceType {
+				continue
+			}
+			instance := strings.ToLower(body.PTR.String())
+			instances = append(instances, instance)
+			ttls[instance] = rr.Header.TTL
+		case *dnsmessage.SRVResource:
+			srvs[name] = body
+		case *dnsmessage.TXTResource:
+			txts[name] = body.TXT
+		case *dnsmessage.AResource:
+			addrs[name] = append(addrs[name], net.IP(body.A[:]))
+		}
+	}
+
+	var out []mdnsAnnouncedService
+	for _, instance := range instances {
+		txt, ok := txts[instance]
+		if !ok {
+			continue
+		}
+		svc := parseMDNSTXT(txt)
+		if ValidateID(svc.ID) != nil {
+			continue
+		}
+		ttl := time.Duration(ttls[instance]) * time.Second
+		if srv := srvs[instance]; srv != nil {
+			svc.Port = int(srv.Port)
+			svc.Host = strings.TrimSuffix(srv.Target.String(), ".local.")
+			svc.IPs = addrs[strings.ToLower(srv.Target.String())]
+		} else if ttl > 0 {
+			// Without SRV there is no port to dial; goodbyes need only the ID.
+			continue
+		}
+		if len(svc.IPs) == 0 && src != nil {
+			svc.IPs = []net.IP{src}
+		}
+		out = append(out, mdnsAnnouncedService{MDNSService: svc, TTL: ttl})
+	}
+	return out, nil
+}
+
+// parseMDNSTXT reads the id/name/base_path/caps TXT keys. Unknown keys are
+// ignored so newer nodes can advertise more without breaking older hubs.
+func parseMDNSTXT(txt []string) MDNSService {
+	var svc MDNSService
+	for _, kv := range txt {
+		key, value, _ := strings.Cut(kv, "=")
+		switch strings.ToLower(key) {
+		case "id":
+			svc.ID = value
+		case "name":
+			svc.Name = value
+		case "base_path":
+			svc.BasePath = value
+		case "caps":
+			for _, c := range strings.Split(value, ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					svc.Capabilities = append(svc.Capabilities, c)
+				}
+			}
+		}
+	}
+	return svc
+}
+
+// isMDNSQueryFor reports whether packet is a query asking for the term-llm
+// service type.
+func isMDNSQueryFor(packet []byte) bool {
+	var p dnsmessage.Parser
+	h, err := p.Start(packet)
+	if err != nil || h.Response {
+		return false
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return false
+	}
+	for _, q := range questions {
+		if (q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL) && strings.EqualFold(q.Name.String(), MDNSServiceType) {
+			return true
+		}
+	}
+	return false
+}
+
+// AdvertiseMDNS advertises svc on the local network until ctx is done: it
+// announces at start, answers browse queries, and sends a goodbye on exit so
+// hubs drop the node without waiting for the TTL. Empty IPs default to this
+// machine's non-loopback IPv4 addresses and an empty Host to its host name.
+func AdvertiseMDNS(ctx context.Context, svc MDNSService) error {
+	if len(svc.IPs) == 0 {
+		svc.IPs = localIPv4s()
+	}
+	if svc.Host == "" {
+		svc.Host, _ = os.Hostname()
+	}
+	announce, err := MDNSAnnouncement(svc, mdnsTTL)
+	if err != nil {
+		return err
+	}
+	goodbye, err := MDNSAnnouncement(svc, 0)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return fmt.Errorf("listen mdns: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() {
+		_, _ = conn.WriteToUDP(goodbye, mdnsGroup)
+		_ = conn.Close()
+	})
+	defer stop()
+
+	// Announce twice, a second apart, as RFC 6762 recommends for startup.
+	go func() {
+		for i := 0; i < 2; i++ {
+			if _, err := conn.WriteToUDP(announce, mdnsGroup); err != nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("read mdns: %w", err)
+		}
+		if isMDNSQueryFor(buf[:n]) {
+			_, _ = conn.WriteToUDP(announce, mdnsGroup)
+		}
+	}
+}
+
+// localIPv4s returns the machine's non-loopback IPv4 interface addresses.
+func localIPv4s() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ip4 := ipNet.IP.To4(); ip4 != nil {
+			ips = append(ips, ip4)
+		}
+	}
+	return ips
+}
+
+// MDNSResolver discovers nodes advertising MDNSServiceType on the local
+// network. Run browses in the background and keeps a TTL-bounded cache, so
+// Nodes stays cheap enough for the per-request re-resolve. Discovered nodes
+// carry no token: a node hands its token to the hub through the registration
+// API, which stores it in the local store (resolved ahead of mDNS).
+type MDNSResolver struct {
+	// Interval is how often Run re-sends the browse query.
+	Interval time.Duration
+
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]mdnsEntry
+}
+
+type mdnsEntry struct {
+	svc     MDNSService
+	expires time.Time
+}
+
+// NewMDNSResolver returns a resolver with an empty cache; call Run to browse.
+func NewMDNSResolver() *MDNSResolver {
+	return &MDNSResolver{Interval: mdnsQueryInterval, now: time.Now, entries: map[string]mdnsEntry{}}
+}
+
+// Source implements Resolver.
+func (m *MDNSResolver) Source() string { return SourceMDNS }
+
+// Nodes implements Resolver from the cache. Services that do not form a valid
+// node (no base path, bad ID) are skipped rather than failing the source.
+func (m *MDNSResolver) Nodes() ([]Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked()
+	nodes := make([]Node, 0, len(m.entries))
+	for _, e := range m.entries {
+		n := Node{
+			ID:           e.svc.ID,
+			Name:         e.svc.Name,
+			Source:       SourceMDNS,
+			Connection:   "direct",
+			URL:          MDNSServiceURL(e.svc, nil),
+			BasePath:     e.svc.BasePath,
+			Capabilities: append([]string(nil), e.svc.Capabilities...),
+		}
+		if n.Normalize() != nil {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+// Service returns the live advertisement for a node ID.
+func (m *MDNSResolver) Service(id string) (MDNSService, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked()
+	e, ok := m.entries[id]
+	return e.svc, ok
+}
+
+// MDNSServiceURL returns the origin for svc at ip, or at its first advertised
+// address when ip is nil. It returns "" when svc has no usable address.
+func MDNSServiceURL(svc MDNSService, ip net.IP) string {
+	if ip == nil {
+		if len(svc.IPs) == 0 {
+			return ""
+		}
+		ip = svc.IPs[0]
+	}
+	return "http://" + net.JoinHostPort(ip.String(), strconv.Itoa(svc.Port))
+}
+
+// Observe feeds one received mDNS packet into the cache. Run calls it for
+// every packet on the socket; announcements refresh entries and goodbyes
+// (TTL 0) drop them. Queries and foreign services are ignored.
+func (m *MDNSResolver) Observe(packet []byte, src net.IP) {
+	services, err := parseMDNSResponse(packet, src)
+	if err != nil || len(services) == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for _, s := range services {
+		if s.TTL <= 0 {
+			delete(m.entries, s.ID)
+			continue
+		}
+		m.entries[s.ID] = mdnsEntry{svc: s.MDNSService, expires: now.Add(s.TTL)}
+	}
+}
+
+func (m *MDNSResolver) pruneLocked() {
+	now := m.now()
+	for id, e := range m.entries {
+		if !now.Before(e.expires) {
+			delete(m.entries, id)
+		}
+	}
+}
+
+// Run browses until ctx is done: it joins the mDNS group, queries for the
+// service type every Interval, and feeds every response into the cache.
+func (m *MDNSResolver) Run(ctx context.Context) error {
+	query, err := MDNSQuery()
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return fmt.Errorf("listen mdns: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	interval := m.Interval
+	if interval <= 0 {
+		interval = mdnsQueryInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("read mdns: %w", err)
+		}
+		m.Observe(buf[:n], src.IP)
+	}
+}
@@ -0,0 +1,165 @@
+package hub
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testMDNSService() MDNSService {
+	return MDNSService{
+		ID:           "studio.box",
+		Name:         "Studio",
+		BasePath:     "/chat",
+		Port:         8081,
+		Capabilities: []string{"web", "jobs"},
+		Host:         "Studio.lan",
+		IPs:          []net.IP{net.IPv4(192, 168, 1, 20)},
+	}
+}
+
+func TestMDNSAnnouncementRoundTrip(t *testing.T) {
+	packet, err := MDNSAnnouncement(testMDNSService(), mdnsTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(packet), "token") {
+		t.Fatal("announcement must not mention a token")
+	}
+	services, err := parseMDNSResponse(packet, net.IPv4(10, 0, 0, 9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 {
+		t.Fatalf("services = %+v, want 1", services)
+	}
+	got := services[0]
+	if got.ID != "studio.box" || got.Name != "Studio" || got.BasePath != "/chat" || got.Port != 8081 || got.TTL != mdnsTTL {
+		t.Fatalf("service = %+v", got)
+	}
+	if got.Host != "studio" {
+		t.Fatalf("host = %q, want studio", got.Host)
+	}
+	if strings.Join(got.Capabilities, ",") != "web,jobs" {
+		t.Fatalf("capabilities = %v", got.Capabilities)
+	}
+	// Advertised A records win over the packet source.
+	if len(got.IPs) != 1 || !got.IPs[0].Equal(net.IPv4(192, 168, 1, 20)) {
+		t.Fatalf("ips = %v", got.IPs)
+	}
+}
+
+func TestMDNSAnnouncementFallsBackToSourceAddress(t *testing.T) {
+	svc := testMDNSService()
+	svc.IPs = nil
+	packet, err := MDNSAnnouncement(svc, mdnsTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	services, err := parseMDNSResponse(packet, net.IPv4(10, 0, 0, 9))
+	if err != nil || len(services) != 1 {
+		t.Fatalf("parse = %+v, %v", services, err)
+	}
+	if got := MDNSServiceURL(services[0].MDNSService, nil); got != "http://10.0.0.9:8081" {
+		t.Fatalf("url = %q", got)
+	}
+}
+
+func TestMDNSAnnouncementRejectsInvalidService(t *testing.T) {
+	svc := testMDNSService()
+	svc.ID = "bad/id"
+	if _, err := MDNSAnnouncement(svc, mdnsTTL); err == nil {
+		t.Fatal("expected invalid id error")
+	}
+	svc = testMDNSService()
+	svc.Port = 0
+	if _, err := MDNSAnnouncement(svc, mdnsTTL); err == nil {
+		t.Fatal("expected invalid port error")
+	}
+}
+
+func TestMDNSQueryMatching(t *testing.T) {
+	query, err := MDNSQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isMDNSQueryFor(query) {
+		t.Fatal("browse query not recognized")
+	}
+	announce, err := MDNSAnnouncement(testMDNSService(), mdnsTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isMDNSQueryFor(announce) {
+		t.Fatal("response treated as a query")
+	}
+	// Queries are not responses and never populate a resolver.
+	if services, err := parseMDNSResponse(query, nil); err != nil || len(services) != 0 {
+		t.Fatalf("parse query = %+v, %v", services, err)
+	}
+}
+
+func TestMDNSResolverCacheLifecycle(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	r := NewMDNSResolver()
+	r.now = func() time.Time { return now }
+
+	announce, err := MDNSAnnouncement(testMDNSService(), mdnsTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Observe(announce, net.IPv4(10, 0, 0, 9))
+	nodes, err := r.Nodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 {
+		t.Fatalf("nodes = %+v, want 1", nodes)
+	}
+	n := nodes[0]
+	if n.ID != "studio.box" || n.Source != SourceMDNS || n.Connection != "direct" || n.URL != "http://192.168.1.20:8081" || n.BasePath != "/chat" || n.Token != "" {
+		t.Fatalf("node = %+v", n)
+	}
+	if strings.Join(n.Capabilities, ",") != "web,jobs" {
+		t.Fatalf("capabilities = %v", n.Capabilities)
+	}
+	if _, ok := r.Service("studio.box"); !ok {
+		t.Fatal("Service lookup missed live node")
+	}
+
+	// A goodbye drops the node immediately.
+	goodbye, err := MDNSAnnouncement(testMDNSService(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Observe(goodbye, net.IPv4(10, 0, 0, 9))
+	if nodes, _ := r.Nodes(); len(nodes) != 0 {
+		t.Fatalf("nodes after goodbye = %+v", nodes)
+	}
+
+	// Without re-announcement the node expires after its TTL.
+	r.Observe(announce, net.IPv4(10, 0, 0, 9))
+	now = now.Add(mdnsTTL - time.Second)
+	if nodes, _ := r.Nodes(); len(nodes) != 1 {
+		t.Fatalf("nodes before expiry = %+v", nodes)
+	}
+	now = now.Add(time.Second)
+	if nodes, _ := r.Nodes(); len(nodes) != 0 {
+		t.Fatalf("nodes after expiry = %+v", nodes)
+	}
+}
+
+func TestMDNSResolverSkipsNodesWithoutBasePath(t *testing.T) {
+	r := NewMDNSResolver()
+	svc := testMDNSService()
+	svc.BasePath = ""
+	announce, err := MDNSAnnouncement(svc, mdnsTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Observe(announce, nil)
+	if nodes, err := r.Nodes(); err != nil || len(nodes) != 0 {
+		t.Fatalf("nodes = %+v, %v", nodes, err)
+	}
+}
@@ -3,11 +3,12 @@
 // The core object is a Node: a reachable term-llm serve (web/API endpoint)
 // with an identity, a backend URL + base path, an optional bearer token, and
 // a source describing which resolver produced it. Resolvers (static config,
-// contain workspaces, the local UI-added store) feed a Registry, which is the
-// single lookup surface the hub server routes and proxies from.
+// contain workspaces, the local UI-added store, mDNS on the local network)
+// feed a Registry, which is the single lookup surface the hub server routes
+// and proxies from.
 //
-// Reverse nodes may self-register with the hub when the hub operator enables a
-// registration token; scheduling and mTLS between hub and nodes remain out of
+// Reverse nodes, and direct nodes discovered over mDNS, may self-register with
+// the hub when the hub operator enables a registration token; scheduling and mTLS between hub and nodes remain out of
 // scope for v1. See docs-site guide "Hub".
 package hub
 
@@ -23,6 +24,7 @@ const (
 	SourceConfig  = "config"
 	SourceContain = "contain"
 	SourceLocal   = "local"
+	SourceMDNS    = "mdns"
 )
 
 // Node is one reachable term-llm web/API endpoint known to the hub.
@@ -32,7 +34,7 @@ type Node struct {
 	ID string `json:"id" yaml:"id"`
 	// Name is the human-facing label shown on the dashboard.
 	Name string `json:"name" yaml:"name"`
-	// Source is the resolver that produced this node (config/contain/local).
+	// Source is the resolver that produced this node (config/contain/local/mdns).
 	Source string `json:"source" yaml:"-"`
 	// Connection is how the Hub reaches this node. Empty/"direct" means the Hub
 	// dials URL. "reverse" means the node must dial the Hub and keep a websocket
@@ -52,6 +54,15 @@ type Node struct {
 	// Delegation is the node's cross-node delegation policy. Nil or disabled
 	// means the node cannot originate or accept delegations.
 	Delegation *DelegationPolicy `json:"delegation,omitempty" yaml:"delegation"`
+	// Capabilities are the node's advertised capability labels (web, api,
+	// jobs, ...). Probes report live capabilities separately in Status.
+	Capabilities []string `json:"capabilities,omitempty" yaml:"capabilities"`
+	// Labels are operator-assigned routing labels (for example "gpu" or
+	// "repo:term-llm") that delegation requirements can match.
+	Labels []string `json:"labels,omitempty" yaml:"labels"`
+	// Registered marks local-store nodes created by node self-registration
+	// (POST /api/register-node); only those may be removed by deregistration.
+	Registered bool `json:"registered,omitempty" yaml:"-"`
 }
 
 // BaseURL returns the node's backend origin joined with its base path,
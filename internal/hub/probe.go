@@ -25,6 +25,9 @@ type Status struct {
 	Version      string   `json:"version,omitempty"`
 	Agent        string   `json:"agent,omitempty"`
 	Capabilities []string `json:"capabilities,omitempty"`
+	// Labels are the node's jobs worker labels, reported alongside
+	// capabilities and matched by delegation requirements.
+	Labels []string `json:"labels,omitempty"`
 	// Details carries small transport-specific facts such as reverse connection
 	// timestamps. It is diagnostic only and never carries credentials.
 	Details map[string]string `json:"details,omitempty"`
@@ -60,6 +63,7 @@ type healthzResponse struct {
 	Version      string   `json:"version"`
 	Agent        string   `json:"agent"`
 	Capabilities []string `json:"capabilities"`
+	Labels       []string `json:"labels"`
 }
 
 // Probe checks one node's {base}/healthz, measuring latency and decoding any
@@ -92,6 +96,7 @@ func (p *Prober) Probe(ctx context.Context, n Node) Status {
 		st.Version = body.Version
 		st.Agent = body.Agent
 		st.Capabilities = body.Capabilities
+		st.Labels = body.Labels
 	}
 	return st
 }
@@ -146,6 +146,33 @@ func NewImageProvider(cfg *config.Config, providerOverride string) (ImageProvide
 	}
 }
 
+// IsProvider reports whether name is an image provider accepted by
+// NewImageProvider.
+func IsProvider(name string) bool {
+	switch name {
+	case "debug", "gemini", "openai", "chatgpt", "xai", "grok", "venice", "flux", "bfl", "openrouter":
+		return true
+	}
+	return false
+}
+
+// ProviderForModel returns the provider that serves a well-known image model
+// name, or empty string when the name is not recognized.
+func ProviderForModel(model string) string {
+	m := strings.ToLower(strings.TrimSpace(model))
+	switch {
+	case strings.HasPrefix(m, "gpt-image-"), strings.HasPrefix(m, "dall-e-"):
+		return "openai"
+	case strings.HasPrefix(m, "gemini-"), strings.HasPrefix(m, "imagen-"):
+		return "gemini"
+	case strings.HasPrefix(m, "grok-"):
+		return "xai"
+	case strings.HasPrefix(m, "flux"):
+		return "flux"
+	}
+	return ""
+}
+
 // parseImageProviderModel parses "provider:model" or just "provider" from a string.
 // Returns (provider, model). Model will be empty if not specified.
 func parseImageProviderModel(s string) (string, string) {
@@ -120,3 +120,20 @@ func TestValidateSize(t *testing.T) {
 		})
 	}
 }
+
+func TestProviderForModel(t *testing.T) {
+	tests := map[string]string{
+		"gpt-image-1":             "openai",
+		"dall-e-3":                "openai",
+		"gemini-2.5-flash-image":  "gemini",
+		"imagen-4.0-generate-001": "gemini",
+		"grok-2-image":            "xai",
+		"flux-pro-1.1":            "flux",
+		"hidream":                 "",
+	}
+	for model, want := range tests {
+		if got := ProviderForModel(model); got != want {
+			t.Errorf("ProviderForModel(%q) = %q, want %q", model, got, want)
+		}
+	}
+}
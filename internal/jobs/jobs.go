@@ -7,9 +7,12 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/samsaffron/term-llm/internal/batch"
 	"github.com/samsaffron/term-llm/internal/llm"
 	"github.com/samsaffron/term-llm/internal/procutil"
 	"github.com/samsaffron/term-llm/internal/session"
@@ -28,10 +31,14 @@ type RunStatus string
 const (
 	RunnerLLM     RunnerType = "llm"
 	RunnerProgram RunnerType = "program"
+	RunnerBatch   RunnerType = "batch"
 
-	TriggerManual TriggerType = "manual"
-	TriggerOnce   TriggerType = "once"
-	TriggerCron   TriggerType = "cron"
+	TriggerManual  TriggerType = "manual"
+	TriggerOnce    TriggerType = "once"
+	TriggerCron    TriggerType = "cron"
+	TriggerAfter   TriggerType = "after"
+	TriggerWatch   TriggerType = "watch"
+	TriggerWebhook TriggerType = "webhook"
 
 	RunQueued          RunStatus = "queued"
 	RunClaimed         RunStatus = "claimed"
@@ -63,13 +70,28 @@ type RetryPolicy struct {
 }
 
 type TriggerConfig struct {
-	RunAt      string `json:"run_at,omitempty"`
-	Expression string `json:"expression,omitempty"`
-	Timezone   string `json:"timezone,omitempty"`
+	RunAt        string `json:"run_at,omitempty"`
+	Expression   string `json:"expression,omitempty"`
+	Timezone     string `json:"timezone,omitempty"`
+	JobID        string `json:"job_id,omitempty"`
+	OnStatus     string `json:"on_status,omitempty"`
+	PassUpstream bool   `json:"pass_upstream,omitempty"`
+
+	Dir          string   `json:"dir,omitempty"`
+	Patterns     []string `json:"patterns,omitempty"`
+	Ignore       []string `json:"ignore,omitempty"`
+	Debounce     string   `json:"debounce,omitempty"`
+	PollInterval string   `json:"poll_interval,omitempty"`
+
+	Secret         string   `json:"secret,omitempty"`
+	SecretEnv      string   `json:"secret_env,omitempty"`
+	SignatureStyle string   `json:"signature_style,omitempty"`
+	Events         []string `json:"events,omitempty"`
 }
 
 type Job struct {
 	ID                string          `json:"id"`
+	Key               string          `json:"key,omitempty"`
 	Name              string          `json:"name"`
 	Enabled           bool            `json:"enabled"`
 	RunnerType        RunnerType      `json:"runner_type"`
@@ -83,6 +105,7 @@ type Job struct {
 	TimeoutSeconds    int             `json:"timeout_seconds,omitempty"`
 	MisfirePolicy     string          `json:"misfire_policy,omitempty"`
 	Labels            json.RawMessage `json:"labels,omitempty"`
+	Artifacts         json.RawMessage `json:"artifacts,omitempty"`
 	NextRunAt         *time.Time      `json:"next_run_at,omitempty"`
 	LastRun           *Run            `json:"last_run,omitempty"`
 	CreatedAt         time.Time       `json:"created_at"`
@@ -90,6 +113,7 @@ type Job struct {
 }
 
 type JobRequest struct {
+	Key               string          `json:"key,omitempty"`
 	Name              string          `json:"name"`
 	Enabled           *bool           `json:"enabled,omitempty"`
 	RunnerType        RunnerType      `json:"runner_type"`
@@ -103,6 +127,7 @@ type JobRequest struct {
 	TimeoutSeconds    int             `json:"timeout_seconds,omitempty"`
 	MisfirePolicy     string          `json:"misfire_policy,omitempty"`
 	Labels            json.RawMessage `json:"labels,omitempty"`
+	Artifacts         json.RawMessage `json:"artifacts,omitempty"`
 }
 
 func (req JobRequest) ToJob(defaultEnabled bool) Job {
@@ -111,6 +136,7 @@ func (req JobRequest) ToJob(defaultEnabled bool) Job {
 		enabled = *req.Enabled
 	}
 	return Job{
+		Key:               req.Key,
 		Name:              req.Name,
 		Enabled:           enabled,
 		RunnerType:        req.RunnerType,
@@ -124,6 +150,7 @@ func (req JobRequest) ToJob(defaultEnabled bool) Job {
 		TimeoutSeconds:    req.TimeoutSeconds,
 		MisfirePolicy:     req.MisfirePolicy,
 		Labels:            req.Labels,
+		Artifacts:         req.Artifacts,
 	}
 }
 
@@ -149,8 +176,36 @@ type Run struct {
 	TurnCount    int        `json:"turn_count,omitempty"`
 	InputTokens  int        `json:"input_tokens,omitempty"`
 	OutputTokens int        `json:"output_tokens,omitempty"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ParentRunID  string     `json:"parent_run_id,omitempty"`
+	// TriggerPayload carries trigger-specific input for the run, such as the
+	// paths that changed for a watch trigger.
+	TriggerPayload json.RawMessage `json:"trigger_payload,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// ArtifactPolicy declares which files a job's runs keep after they finish.
+// Paths are doublestar globs relative to the runner's cwd. KeepRuns and
+// KeepDays bound how long captured artifacts are retained; zero defers to the
+// run retention policy.
+type ArtifactPolicy struct {
+	Paths         []string `json:"paths"`
+	MaxFiles      int      `json:"max_files,omitempty"`
+	MaxFileBytes  int64    `json:"max_file_bytes,omitempty"`
+	MaxTotalBytes int64    `json:"max_total_bytes,omitempty"`
+	KeepRuns      int      `json:"keep_runs,omitempty"`
+	KeepDays      int      `json:"keep_days,omitempty"`
+}
+
+// Artifact is a file captured from a run's working directory.
+type Artifact struct {
+	ID          int64     `json:"id"`
+	RunID       string    `json:"run_id"`
+	Path        string    `json:"path"`
+	SizeBytes   int64     `json:"size_bytes"`
+	ContentType string    `json:"content_type,omitempty"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type RunEvent struct {
@@ -196,18 +251,31 @@ type NotifyOrigin struct {
 	TelegramChatID int64  `json:"telegram_chat_id,omitempty"`
 }
 
+// ChangeDetection limits completion notifications to runs whose output
+// differs from the previous successful run of the same job.
+type ChangeDetection struct {
+	// Field is a dot path into a JSON response to compare instead of the
+	// whole response, e.g. "cves" or "result.items".
+	Field string `json:"field,omitempty"`
+	// Judge asks a fast model whether a detected change is meaningful before
+	// notifying. Judge errors fall back to notifying.
+	Judge             bool   `json:"judge,omitempty"`
+	JudgeInstructions string `json:"judge_instructions,omitempty"`
+}
+
 type LLMConfig struct {
-	AgentName      string        `json:"agent_name"`
-	Instructions   string        `json:"instructions"`
-	Progressive    bool          `json:"progressive,omitempty"`
-	StopWhen       string        `json:"stop_when,omitempty"`
-	ContinueWith   string        `json:"continue_with,omitempty"`
-	PersistSession *bool         `json:"persist_session,omitempty"`
-	SessionID      string        `json:"session_id,omitempty"`
-	SessionName    string        `json:"session_name,omitempty"`
-	NotifyWhenDone bool          `json:"notify_when_done,omitempty"`
-	NotifyOrigin   *NotifyOrigin `json:"notify_origin,omitempty"`
-	Cwd            string        `json:"cwd"`
+	AgentName      string           `json:"agent_name"`
+	Instructions   string           `json:"instructions"`
+	Progressive    bool             `json:"progressive,omitempty"`
+	StopWhen       string           `json:"stop_when,omitempty"`
+	ContinueWith   string           `json:"continue_with,omitempty"`
+	PersistSession *bool            `json:"persist_session,omitempty"`
+	SessionID      string           `json:"session_id,omitempty"`
+	SessionName    string           `json:"session_name,omitempty"`
+	NotifyWhenDone bool             `json:"notify_when_done,omitempty"`
+	NotifyOrigin   *NotifyOrigin    `json:"notify_origin,omitempty"`
+	NotifyOnChange *ChangeDetection `json:"notify_on_change,omitempty"`
+	Cwd            string           `json:"cwd"`
 
 	Provider        string   `json:"provider,omitempty"`
 	Model           string   `json:"model,omitempty"`
@@ -219,6 +287,27 @@ type LLMConfig struct {
 	Search          bool     `json:"search,omitempty"`
 	SystemMessage   string   `json:"system_message,omitempty"`
 	Skills          string   `json:"skills,omitempty"`
+
+	// APIKeyID and APIKeyName attribute run usage to the serve API key that
+	// created or last configured the job.
+	APIKeyID   string `json:"api_key_id,omitempty"`
+	APIKeyName string `json:"api_key_name,omitempty"`
+}
+
+// BatchConfig is the runner config of a batch job, created by POST
+// /v1/batches. Each line of the input file runs as a separate model call.
+type BatchConfig struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	// Native, when false, runs every line locally even where a provider
+	// offers a discounted batch API. Nil uses the server default.
+	Native *bool `json:"native,omitempty"`
+	// APIKeyID and APIKeyName attribute usage to the serve API key that
+	// created the batch.
+	APIKeyID   string `json:"api_key_id,omitempty"`
+	APIKeyName string `json:"api_key_name,omitempty"`
 }
 
 func (c LLMConfig) SessionPersistenceEnabled() bool {
@@ -251,11 +340,29 @@ func ValidateRunnerConfig(runnerType RunnerType, raw json.RawMessage) error {
 		if strings.TrimSpace(cfg.Cwd) == "" {
 			return fmt.Errorf("llm runner_config.cwd is required")
 		}
+		if cd := cfg.NotifyOnChange; cd != nil {
+			for _, part := range strings.Split(cd.Field, ".") {
+				if cd.Field != "" && strings.TrimSpace(part) == "" {
+					return fmt.Errorf("llm runner_config.notify_on_change.field must be a dot path, got %q", cd.Field)
+				}
+			}
+		}
 	case RunnerProgram:
+	case RunnerBatch:
+		var cfg BatchConfig
+		if err := json.Unmarshal([]byte(stringOrEmptyRaw(raw, "{}")), &cfg); err != nil {
+			return fmt.Errorf("invalid batch runner config: %w", err)
+		}
+		if strings.TrimSpace(cfg.InputFileID) == "" {
+			return fmt.Errorf("batch runner_config.input_file_id is required")
+		}
+		if !batch.ValidEndpoint(cfg.Endpoint) {
+			return fmt.Errorf("batch runner_config.endpoint must be %s or %s", batch.EndpointChatCompletions, batch.EndpointResponses)
+		}
 	case "":
 		return fmt.Errorf("runner_type is required")
 	default:
-		return fmt.Errorf("runner_type must be one of: llm, program")
+		return fmt.Errorf("runner_type must be one of: llm, program, batch")
 	}
 	return nil
 }
@@ -295,6 +402,10 @@ type ProgramConfig struct {
 	Cwd     string   `json:"cwd,omitempty"`
 	Env     []string `json:"env,omitempty"`
 	Shell   bool     `json:"shell,omitempty"`
+	// EnvFiles maps environment variable names to file contents. Each file
+	// is written to a private directory for the run and its path exported
+	// in the variable, for data too large to pass in the environment.
+	EnvFiles map[string]string `json:"env_files,omitempty"`
 }
 
 type ProgramRunner struct {
@@ -337,6 +448,17 @@ func (r *ProgramRunner) Run(ctx context.Context, job Job, pw ProgressWriter) (Ru
 	if len(cfg.Env) > 0 {
 		cmd.Env = append(os.Environ(), cfg.Env...)
 	}
+	if len(cfg.EnvFiles) > 0 {
+		dir, env, err := writeProgramEnvFiles(cfg.EnvFiles)
+		if err != nil {
+			return RunResult{}, fmt.Errorf("program setup failed: %w", err)
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, env...)
+	}
 
 	cleanup, prepErr := tools.PrepareCommand(cmd)
 	if prepErr != nil {
@@ -383,6 +505,34 @@ func (r *ProgramRunner) Run(ctx context.Context, job Job, pw ProgressWriter) (Ru
 	return result, nil
 }
 
+// writeProgramEnvFiles writes files into a fresh temporary directory and
+// returns it with the NAME=path entries to export.
+func writeProgramEnvFiles(files map[string]string) (string, []string, error) {
+	dir, err := os.MkdirTemp("", "term-llm-job-")
+	if err != nil {
+		return "", nil, err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	env := make([]string, 0, len(names))
+	for i, name := range names {
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			_ = os.RemoveAll(dir)
+			return "", nil, fmt.Errorf("invalid env_files name %q", name)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("env-file-%d", i))
+		if err := os.WriteFile(path, []byte(files[name]), 0o600); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", nil, err
+		}
+		env = append(env, name+"="+path)
+	}
+	return dir, env, nil
+}
+
 func stringOrEmptyRaw(raw json.RawMessage, fallback string) string {
 	if len(raw) == 0 || strings.TrimSpace(string(raw)) == "" || string(raw) == "null" {
 		return fallback
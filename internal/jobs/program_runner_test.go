@@ -39,6 +39,26 @@ func TestProgramRunnerShellArgsBecomePositionalParameters(t *testing.T) {
 	}
 }
 
+func TestProgramRunnerEnvFilesExportPathsAndAreRemoved(t *testing.T) {
+	runner := &ProgramRunner{}
+	job := testProgramJob(t, ProgramConfig{
+		Command:  `cat "$PAYLOAD_FILE"; printf '|%s' "$PAYLOAD_FILE"`,
+		Shell:    true,
+		EnvFiles: map[string]string{"PAYLOAD_FILE": `{"ok":true}`},
+	})
+	result, err := runner.Run(context.Background(), job, nil)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	content, path, ok := strings.Cut(result.Stdout, "|")
+	if !ok || content != `{"ok":true}` {
+		t.Fatalf("stdout = %q, want file content then path", result.Stdout)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Fatalf("env file dir %s still exists after run: %v", filepath.Dir(path), err)
+	}
+}
+
 func TestProgramRunnerTimeoutKillsBackgroundChildrenPromptly(t *testing.T) {
 	runner := &ProgramRunner{}
 	job := testProgramJob(t, ProgramConfig{Command: `sleep 1 & wait`, Shell: true})
@@ -250,6 +250,32 @@ func (p *AnthropicProvider) requestModelAndEffort(req Request) (model string, ef
 	return model, effort
 }
 
+// CountTokens reports the input tokens req would use via the Messages count
+// tokens endpoint.
+func (p *AnthropicProvider) CountTokens(ctx context.Context, req Request) (int, error) {
+	model, _ := p.requestModelAndEffort(req)
+	system, messages := buildAnthropicMessages(req.Messages)
+	params := anthropic.MessageCountTokensParams{
+		Model:    anthropic.Model(model),
+		Messages: messages,
+	}
+	if system != "" {
+		params.System = anthropic.MessageCountTokensParamsSystemUnion{OfString: anthropic.String(system)}
+	}
+	for _, tool := range buildAnthropicTools(req.Tools) {
+		params.Tools = append(params.Tools, anthropic.MessageCountTokensToolUnionParam{OfTool: tool.OfTool})
+	}
+	var opts []option.RequestOption
+	if p.use1m {
+		opts = append(opts, option.WithHeaderAdd("anthropic-beta", the1mBetaHeader))
+	}
+	count, err := p.client.Messages.CountTokens(ctx, params, opts...)
+	if err != nil {
+		return 0, fmt.Errorf("anthropic count tokens: %w", err)
+	}
+	return int(count.InputTokens), nil
+}
+
 func (p *AnthropicProvider) streamStandard(ctx context.Context, req Request) (Stream, error) {
 	model, reasoningEffort := p.requestModelAndEffort(req)
 	return p.streamStandardForModel(ctx, req, model, reasoningEffort, p.thinkingBudget, p.useAdaptive, true)
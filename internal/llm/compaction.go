@@ -521,6 +521,12 @@ func compactionResultFromBriefPrepared(systemPrompt, brief string, prepared prep
 // compacted context from a <PREVIOUS_TURNS> block, the brief, and any bounded
 // raw suffix selected by the shared compaction split.
 func SoftCompact(ctx context.Context, provider Provider, model, systemPrompt string, messages []Message, config CompactionConfig) (*CompactionResult, error) {
+	return traceCompaction(ctx, "soft", model, messages, func(ctx context.Context) (*CompactionResult, error) {
+		return softCompact(ctx, provider, model, systemPrompt, messages, config)
+	})
+}
+
+func softCompact(ctx context.Context, provider Provider, model, systemPrompt string, messages []Message, config CompactionConfig) (*CompactionResult, error) {
 	if len(messages) == 0 {
 		return nil, fmt.Errorf("no messages to compact")
 	}
@@ -1453,6 +1459,12 @@ func buildHelperRequestMessages(systemPrompt string, history []Message, inputLim
 // instruction to the existing messages — leveraging prompt cache on providers
 // like Anthropic — and enforces a token budget on the output.
 func Compact(ctx context.Context, provider Provider, model, systemPrompt string, messages []Message, config CompactionConfig) (*CompactionResult, error) {
+	return traceCompaction(ctx, "hard", model, messages, func(ctx context.Context) (*CompactionResult, error) {
+		return compact(ctx, provider, model, systemPrompt, messages, config)
+	})
+}
+
+func compact(ctx context.Context, provider Provider, model, systemPrompt string, messages []Message, config CompactionConfig) (*CompactionResult, error) {
 	if len(messages) == 0 {
 		return nil, fmt.Errorf("no messages to compact")
 	}
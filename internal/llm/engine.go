@@ -1894,10 +1894,13 @@ func (e *Engine) Stream(ctx context.Context, req Request) (Stream, error) {
 			// Tools read this for session-scoped concerns like file-change tracking.
 			ctx = ContextWithSessionID(ctx, req.SessionID)
 		}
+		ctx, span := startLLMSpan(ctx, "engine.run", e.provider.Name(), req)
 		stream := newEventStream(ctx, func(ctx context.Context, send eventSender) error {
 			return e.runLoop(ctx, req, send)
 		})
-		stream = wrapLoggingStream(stream, e.provider.Name(), req.Model)
+		stream = wrapTraceStream(stream, span, req.Model)
+		stream = wrapLoggingStream(ctx, stream, e.provider.Name(), req.Model)
+		stream = wrapMetricsStream(stream, e.provider.Name(), req.Model)
 		stream = e.wrapDebugLoggingStream(stream)
 
 		// Wrap with per-turn cleanup for providers that materialize temporary
@@ -1917,10 +1920,13 @@ func (e *Engine) Stream(ctx context.Context, req Request) (Stream, error) {
 		debugReq := e.prepareProviderRequest(req)
 		e.debugLogger.LogRequest(e.provider.Name(), req.Model, debugReq)
 	}
+	ctx, span := startLLMSpan(ctx, "engine.run", e.provider.Name(), req)
 	stream := newEventStream(ctx, func(ctx context.Context, send eventSender) error {
 		return e.runSimpleScratchpad(ctx, req, send)
 	})
-	stream = wrapLoggingStream(stream, e.provider.Name(), req.Model)
+	stream = wrapTraceStream(stream, span, req.Model)
+	stream = wrapLoggingStream(ctx, stream, e.provider.Name(), req.Model)
+	stream = wrapMetricsStream(stream, e.provider.Name(), req.Model)
 	stream = e.wrapDebugLoggingStream(stream)
 	return stream, nil
 }
@@ -2132,7 +2138,7 @@ func (e *Engine) runSimpleScratchpad(ctx context.Context, req Request, send even
 	for retry := 0; ; retry++ {
 		providerReq := e.prepareProviderRequest(req)
 		e.clearInlineFlush()
-		stream, err := e.provider.Stream(ctx, providerReq)
+		stream, err := e.streamProvider(ctx, providerReq)
 		if err != nil {
 			return err
 		}
@@ -2799,7 +2805,7 @@ turnLoop:
 		}
 
 		e.clearInlineFlush()
-		stream, err := e.provider.Stream(ctx, providerReq)
+		stream, err := e.streamProvider(ctx, providerReq)
 		if err != nil {
 			// Reactive compaction: if this is a context overflow error, try compacting and retrying (once)
 			if compactionConfig != nil && isContextOverflowError(err) && !reactiveCompactionDone {
@@ -4402,12 +4408,17 @@ func (e *Engine) executeSingleToolCall(ctx context.Context, call ToolCall, send
 	}
 
 	// Add call ID to context for spawn_agent event bubbling
-	toolCtx := ContextWithCallID(ctx, call.ID)
+	toolCtx, span := startToolSpan(ContextWithCallID(ctx, call.ID), call)
 
 	stopHeartbeat := startToolHeartbeat(ctx, call.ID, call.Name, send)
 	defer stopHeartbeat()
 
 	output, err, panicValue := executeToolWithCancellation(toolCtx, tool, call.Arguments)
+	if panicValue != nil {
+		endToolSpan(span, output, fmt.Errorf("tool panicked: %v", panicValue))
+	} else {
+		endToolSpan(span, output, err)
+	}
 	if planner := e.currentToolPlanner(); planner != nil {
 		planner.ToolExecuted(SessionIDFromContext(ctx), ToolRunIDFromContext(ctx), call.Name)
 	}
@@ -4484,12 +4495,13 @@ func (e *Engine) handleSyncToolExecution(ctx context.Context, event Event, send
 	} else if !e.IsToolAllowed(call.Name) {
 		err = fmt.Errorf("tool '%s' is not in the active skill's allowed-tools list", call.Name)
 	} else {
-		toolCtx := ContextWithCallID(ctx, callID)
+		toolCtx, span := startToolSpan(ContextWithCallID(ctx, callID), *call)
 		var panicValue any
 		result, err, panicValue = executeToolWithCancellation(toolCtx, tool, call.Arguments)
 		if panicValue != nil {
 			err = fmt.Errorf("Error: tool panicked: %v", panicValue)
 		}
+		endToolSpan(span, result, err)
 	}
 
 	// Truncate large tool outputs (global limit, then compaction limit).
@@ -4679,6 +4691,7 @@ type loggingStream struct {
 	providerName    string
 	model           string
 	trackedExternal string // "claude-code", "codex", or "" for direct API
+	attribution     usage.Attribution
 
 	// mu guards the accumulator/logged fields against concurrent Recv/Close.
 	mu              sync.Mutex
@@ -4738,7 +4751,7 @@ func (s *loggingStream) flushLocked() {
 		return
 	}
 	s.logged = true
-	_ = s.logger.Log(usage.LogEntry{
+	entry := usage.LogEntry{
 		Timestamp:           time.Now(),
 		Model:               s.model,
 		Provider:            s.providerName,
@@ -4747,22 +4760,30 @@ func (s *loggingStream) flushLocked() {
 		CacheReadTokens:     s.totalCacheRead,
 		CacheWriteTokens:    s.totalCacheWrite,
 		TrackedExternallyBy: s.trackedExternal,
-	})
+		APIKey:              s.attribution.APIKey,
+	}
+	_ = s.logger.Log(entry)
+	if s.attribution.Record != nil {
+		s.attribution.Record(entry)
+	}
 }
 
-// wrapLoggingStream wraps a stream with usage logging
-func wrapLoggingStream(inner Stream, providerName, model string) Stream {
+// wrapLoggingStream wraps a stream with usage logging, attributed to any
+// usage.Attribution carried by ctx.
+func wrapLoggingStream(ctx context.Context, inner Stream, providerName, model string) Stream {
 	// If model is empty, use providerName as the model identifier
 	// This helps identify what was used when providers auto-select models
 	if model == "" {
 		model = providerName
 	}
+	attribution, _ := usage.AttributionFromContext(ctx)
 	return &loggingStream{
 		inner:           inner,
 		logger:          usage.DefaultLogger(),
 		providerName:    providerName,
 		model:           model,
 		trackedExternal: usage.GetTrackedExternallyBy(providerName),
+		attribution:     attribution,
 	}
 }
 
@@ -0,0 +1,190 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StreamStats summarises one Engine stream for metrics: a single provider
+// call, or a whole agentic loop including its tool calls.
+type StreamStats struct {
+	Provider string // normalised provider label, e.g. "anthropic"
+	Model    string
+	Duration time.Duration
+	// TimeToFirstToken is the delay before the first text, reasoning or tool
+	// call event; zero when the stream produced none.
+	TimeToFirstToken time.Duration
+	InputTokens      int
+	OutputTokens     int
+	CacheReadTokens  int
+	CacheWriteTokens int
+	// Outcome is "ok", "error" or "cancelled".
+	Outcome string
+}
+
+// ToolStats describes one finished tool execution.
+type ToolStats struct {
+	Provider string
+	Model    string
+	Tool     string
+	Duration time.Duration
+	Success  bool
+}
+
+// StreamObserver receives metrics for every Engine stream in the process.
+// Methods are called synchronously from the stream consumer and must not
+// block.
+type StreamObserver interface {
+	ObserveStream(StreamStats)
+	ObserveTool(ToolStats)
+}
+
+type streamObserverHolder struct{ observer StreamObserver }
+
+var streamObserver atomic.Pointer[streamObserverHolder]
+
+// SetStreamObserver installs a process-wide stream observer; nil removes it.
+// Streams started while no observer is installed are not observed.
+func SetStreamObserver(observer StreamObserver) {
+	if observer == nil {
+		streamObserver.Store(nil)
+		return
+	}
+	streamObserver.Store(&streamObserverHolder{observer: observer})
+}
+
+// MetricsProviderLabel reduces a provider display name such as
+// "OpenAI (gpt-5, effort=high)" to a bounded label ("openai") and the model
+// named in parentheses, if any.
+func MetricsProviderLabel(name string) (provider, model string) {
+	provider = strings.TrimSpace(name)
+	if base, rest, ok := strings.Cut(provider, " ("); ok {
+		provider = base
+		rest = strings.TrimSuffix(rest, ")")
+		model, _, _ = strings.Cut(rest, ",")
+		model = strings.TrimSpace(model)
+	}
+	provider = strings.ToLower(strings.Join(strings.Fields(provider), "-"))
+	return provider, model
+}
+
+// metricsStream reports a stream's timing, usage and tool executions to the
+// stream observer when it finishes.
+type metricsStream struct {
+	inner    Stream
+	observer StreamObserver
+	provider string
+	model    string
+	start    time.Time
+
+	mu         sync.Mutex
+	firstToken time.Duration
+	toolStarts map[string]time.Time
+	stats      StreamStats
+	reported   bool
+}
+
+// wrapMetricsStream wraps a stream with metrics reporting when a stream
+// observer is installed.
+func wrapMetricsStream(inner Stream, providerName, model string) Stream {
+	holder := streamObserver.Load()
+	if holder == nil {
+		return inner
+	}
+	provider, nameModel := MetricsProviderLabel(providerName)
+	if model == "" {
+		model = nameModel
+	}
+	return &metricsStream{
+		inner:      inner,
+		observer:   holder.observer,
+		provider:   provider,
+		model:      model,
+		start:      time.Now(),
+		toolStarts: make(map[string]time.Time),
+	}
+}
+
+func (s *metricsStream) Recv() (Event, error) {
+	event, err := s.inner.Recv()
+	if err != nil {
+		outcome := "ok"
+		switch {
+		case errors.Is(err, io.EOF):
+		case errors.Is(err, context.Canceled):
+			outcome = "cancelled"
+		default:
+			outcome = "error"
+		}
+		s.report(outcome)
+		return event, err
+	}
+
+	now := time.Now()
+	var tool *ToolStats
+	s.mu.Lock()
+	switch event.Type {
+	case EventTextDelta, EventReasoningDelta, EventToolCall:
+		if s.firstToken == 0 {
+			s.firstToken = now.Sub(s.start)
+		}
+	case EventUsage:
+		if event.Use != nil {
+			s.stats.InputTokens += event.Use.InputTokens
+			s.stats.OutputTokens += event.Use.OutputTokens
+			s.stats.CacheReadTokens += event.Use.CachedInputTokens
+			s.stats.CacheWriteTokens += event.Use.CacheWriteTokens
+		}
+	case EventModelSwitch:
+		if event.Model != "" {
+			s.model = event.Model
+		}
+	case EventToolExecStart:
+		if event.ToolCallID != "" {
+			s.toolStarts[event.ToolCallID] = now
+		}
+	case EventToolExecEnd:
+		started, ok := s.toolStarts[event.ToolCallID]
+		if ok {
+			delete(s.toolStarts, event.ToolCallID)
+			tool = &ToolStats{Provider: s.provider, Model: s.model, Tool: event.ToolName, Duration: now.Sub(started), Success: event.ToolSuccess}
+		}
+	}
+	s.mu.Unlock()
+	if tool != nil {
+		s.observer.ObserveTool(*tool)
+	}
+	if event.Type == EventError {
+		s.report("error")
+	} else if event.Type == EventDone {
+		s.report("ok")
+	}
+	return event, nil
+}
+
+func (s *metricsStream) Close() error {
+	s.report("cancelled")
+	return s.inner.Close()
+}
+
+func (s *metricsStream) report(outcome string) {
+	s.mu.Lock()
+	if s.reported {
+		s.mu.Unlock()
+		return
+	}
+	s.reported = true
+	stats := s.stats
+	stats.Provider = s.provider
+	stats.Model = s.model
+	stats.Duration = time.Since(s.start)
+	stats.TimeToFirstToken = s.firstToken
+	stats.Outcome = outcome
+	s.mu.Unlock()
+	s.observer.ObserveStream(stats)
+}
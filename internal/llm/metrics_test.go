@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+type recordingStreamObserver struct {
+	streams []StreamStats
+	tools   []ToolStats
+}
+
+func (o *recordingStreamObserver) ObserveStream(stats StreamStats) {
+	o.streams = append(o.streams, stats)
+}
+
+func (o *recordingStreamObserver) ObserveTool(stats ToolStats) {
+	o.tools = append(o.tools, stats)
+}
+
+func TestMetricsProviderLabel(t *testing.T) {
+	tests := []struct {
+		name, provider, model string
+	}{
+		{"OpenAI (gpt-5, effort=high)", "openai", "gpt-5"},
+		{"Anthropic (claude-sonnet-4-5, 1m)", "anthropic", "claude-sonnet-4-5"},
+		{"Claude CLI", "claude-cli", ""},
+		{"debug", "debug", ""},
+	}
+	for _, tt := range tests {
+		provider, model := MetricsProviderLabel(tt.name)
+		if provider != tt.provider || model != tt.model {
+			t.Errorf("MetricsProviderLabel(%q) = %q, %q; want %q, %q", tt.name, provider, model, tt.provider, tt.model)
+		}
+	}
+}
+
+func TestMetricsStreamReportsOnce(t *testing.T) {
+	observer := &recordingStreamObserver{}
+	SetStreamObserver(observer)
+	t.Cleanup(func() { SetStreamObserver(nil) })
+
+	stream := wrapMetricsStream(&sliceStream{events: []Event{
+		{Type: EventTextDelta, Text: "hi"},
+		{Type: EventToolExecStart, ToolCallID: "call_1", ToolName: "grep"},
+		{Type: EventToolExecEnd, ToolCallID: "call_1", ToolName: "grep", ToolSuccess: true},
+		{Type: EventUsage, Use: &Usage{InputTokens: 10, OutputTokens: 4, CachedInputTokens: 2}},
+		{Type: EventDone},
+	}}, "OpenAI (gpt-5)", "")
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		}
+	}
+	_ = stream.Close()
+
+	if len(observer.streams) != 1 {
+		t.Fatalf("streams reported = %d, want 1", len(observer.streams))
+	}
+	got := observer.streams[0]
+	if got.Provider != "openai" || got.Model != "gpt-5" || got.Outcome != "ok" || got.InputTokens != 10 || got.OutputTokens != 4 || got.CacheReadTokens != 2 {
+		t.Fatalf("stream stats = %+v", got)
+	}
+	if got.TimeToFirstToken <= 0 {
+		t.Fatalf("time to first token not recorded: %+v", got)
+	}
+	if len(observer.tools) != 1 || observer.tools[0].Tool != "grep" || !observer.tools[0].Success {
+		t.Fatalf("tool stats = %+v", observer.tools)
+	}
+}
+
+func TestMetricsStreamCancelled(t *testing.T) {
+	observer := &recordingStreamObserver{}
+	SetStreamObserver(observer)
+	t.Cleanup(func() { SetStreamObserver(nil) })
+
+	stream := wrapMetricsStream(&errAfterEventsStream{err: context.Canceled}, "anthropic", "claude-sonnet-4-5")
+	if _, err := stream.Recv(); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(observer.streams) != 1 || observer.streams[0].Outcome != "cancelled" {
+		t.Fatalf("streams = %+v, want one cancelled", observer.streams)
+	}
+
+	SetStreamObserver(nil)
+	if _, ok := wrapMetricsStream(&sliceStream{}, "anthropic", "").(*metricsStream); ok {
+		t.Fatal("stream wrapped without an observer")
+	}
+}
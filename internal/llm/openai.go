@@ -1,8 +1,12 @@
 package llm
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"strings"
 
@@ -219,6 +223,52 @@ func (p *OpenAIProvider) Stream(ctx context.Context, req Request) (Stream, error
 	return p.responsesClient.Stream(ctx, responsesReq, req.DebugRaw)
 }
 
+// openAIInputTokensURL is the Responses API endpoint that counts input tokens
+// without running the request.
+const openAIInputTokensURL = "https://api.openai.com/v1/responses/input_tokens"
+
+// CountTokens reports the input tokens req would use via the Responses API
+// input token counting endpoint.
+func (p *OpenAIProvider) CountTokens(ctx context.Context, req Request) (int, error) {
+	reqModel, _ := parseModelEffortForProvider("openai", req.Model)
+	body := map[string]any{
+		"model": chooseModel(reqModel, p.model),
+		"input": BuildResponsesInputWithFilePolicy(req.Messages, p.effectiveFileUploadPolicy()),
+	}
+	if tools := BuildResponsesTools(req.Tools); len(tools) > 0 {
+		body["tools"] = tools
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, fmt.Errorf("marshal openai count tokens request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, openAIInputTokensURL, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := defaultHTTPClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("openai count tokens: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("read openai count tokens response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, newHTTPStatusError("OpenAI", resp, respBody)
+	}
+	var out struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return 0, fmt.Errorf("parse openai count tokens response: %w", err)
+	}
+	return out.InputTokens, nil
+}
+
 // ResetConversation clears server state for the Responses API client.
 // Called on /clear or new conversation.
 func (p *OpenAIProvider) ResetConversation() {
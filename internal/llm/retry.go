@@ -274,6 +274,18 @@ func (r *RetryProvider) RefreshModelMetadata(ctx context.Context) error {
 	return err
 }
 
+// CountTokens forwards to the inner provider's TokenCounter with the same
+// retry policy as Stream, or returns ErrTokenCountUnsupported.
+func (r *RetryProvider) CountTokens(ctx context.Context, req Request) (int, error) {
+	counter, ok := r.inner.(TokenCounter)
+	if !ok {
+		return 0, ErrTokenCountUnsupported
+	}
+	return retryCall(ctx, r.config, func() (int, error) {
+		return counter.CountTokens(ctx, req)
+	}, nil)
+}
+
 func (r *RetryProvider) Stream(ctx context.Context, req Request) (Stream, error) {
 	config := normalizeRetryConfig(r.config)
 	return newEventStream(ctx, func(ctx context.Context, send eventSender) error {
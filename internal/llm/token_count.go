@@ -0,0 +1,149 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrTokenCountUnsupported is returned by RetryProvider.CountTokens when the
+// inner provider has no token counting endpoint. CountTokenBreakdown treats it
+// as "fall back to the local estimate".
+var ErrTokenCountUnsupported = errors.New("provider does not support token counting")
+
+// TokenCounter is implemented by providers that can report the exact input
+// token count of a request without running it.
+type TokenCounter interface {
+	CountTokens(ctx context.Context, req Request) (int, error)
+}
+
+// toolTokenOverhead approximates the per-tool framing a provider wraps around
+// each definition (type tags, separators) beyond the raw name/schema text.
+const toolTokenOverhead = 8
+
+// EstimateToolTokens returns an approximate token count for tool definitions,
+// measured over the JSON each tool is sent as.
+func EstimateToolTokens(specs []ToolSpec) int {
+	total := 0
+	for _, spec := range specs {
+		data, err := json.Marshal(map[string]any{
+			"name":         spec.Name,
+			"description":  spec.Description,
+			"input_schema": spec.Schema,
+		})
+		if err != nil {
+			total += EstimateTokens(spec.Name) + EstimateTokens(spec.Description)
+		} else {
+			total += EstimateTokens(string(data))
+		}
+		total += toolTokenOverhead
+	}
+	return total
+}
+
+// TokenCountInput is a request split into the sections CountTokenBreakdown
+// reports separately.
+type TokenCountInput struct {
+	Model string
+	// System is the system prompt without skills metadata.
+	System string
+	// SystemWithSkills is the system prompt after skills metadata was
+	// injected. Empty when no skills are available.
+	SystemWithSkills string
+	Tools            []ToolSpec
+	// Messages is the conversation, excluding the system prompt.
+	Messages []Message
+}
+
+// TokenBreakdown reports input tokens per request section.
+type TokenBreakdown struct {
+	System   int  `json:"system"`
+	Skills   int  `json:"skills"`
+	Tools    int  `json:"tools"`
+	Messages int  `json:"messages"`
+	Total    int  `json:"total"`
+	Exact    bool `json:"exact"`
+}
+
+// EstimateTokenBreakdown estimates each section locally without calling a
+// provider.
+func EstimateTokenBreakdown(in TokenCountInput) TokenBreakdown {
+	b := TokenBreakdown{
+		System:   EstimateTokens(in.System),
+		Tools:    EstimateToolTokens(in.Tools),
+		Messages: EstimateMessageTokens(in.Messages),
+	}
+	if in.SystemWithSkills != "" {
+		b.Skills = max(EstimateTokens(in.SystemWithSkills)-b.System, 0)
+	}
+	b.Total = b.System + b.Skills + b.Tools + b.Messages
+	return b
+}
+
+// CountTokenBreakdown counts each section with the provider's token counting
+// endpoint when it has one. Providers only report a total, so sections are
+// counted as growing prefixes of the request and attributed by difference;
+// fixed per-request framing lands in Messages. Without a counter, or when the
+// provider reports ErrTokenCountUnsupported, the local estimate is returned.
+// On any other error the estimate is returned alongside the error.
+func CountTokenBreakdown(ctx context.Context, provider Provider, in TokenCountInput) (TokenBreakdown, error) {
+	estimate := EstimateTokenBreakdown(in)
+	counter, ok := provider.(TokenCounter)
+	if !ok {
+		return estimate, nil
+	}
+
+	messages := in.Messages
+	if len(messages) == 0 {
+		// Counting endpoints reject empty conversations.
+		messages = []Message{UserText(".")}
+	}
+	count := func(system string, tools []ToolSpec) (int, error) {
+		req := Request{Model: in.Model, Tools: tools, Messages: messages}
+		if system != "" {
+			req.Messages = append([]Message{SystemText(system)}, messages...)
+		}
+		return counter.CountTokens(ctx, req)
+	}
+
+	base, err := count("", nil)
+	if err != nil {
+		return countFallback(estimate, err)
+	}
+	b := TokenBreakdown{Messages: base, Total: base, Exact: true}
+	system := in.System
+	if system != "" {
+		n, err := count(system, nil)
+		if err != nil {
+			return countFallback(estimate, err)
+		}
+		b.System = max(n-b.Total, 0)
+		b.Total = n
+	}
+	if in.SystemWithSkills != "" {
+		system = in.SystemWithSkills
+		n, err := count(system, nil)
+		if err != nil {
+			return countFallback(estimate, err)
+		}
+		b.Skills = max(n-b.Total, 0)
+		b.Total = n
+	}
+	if len(in.Tools) > 0 {
+		n, err := count(system, in.Tools)
+		if err != nil {
+			return countFallback(estimate, err)
+		}
+		b.Tools = max(n-b.Total, 0)
+		b.Total = n
+	}
+	return b, nil
+}
+
+func countFallback(estimate TokenBreakdown, err error) (TokenBreakdown, error) {
+	if errors.Is(err, ErrTokenCountUnsupported) {
+		return estimate, nil
+	}
+	return estimate, fmt.Errorf("count tokens: %w", err)
+}
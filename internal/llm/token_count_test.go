@@ -0,0 +1,155 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// fakeTokenCounter counts one token per byte of system text, 100 per tool
+// and 10 per message, plus a fixed framing cost.
+type fakeTokenCounter struct {
+	*DebugProvider
+	calls int
+	err   error
+}
+
+func (f *fakeTokenCounter) CountTokens(_ context.Context, req Request) (int, error) {
+	f.calls++
+	if f.err != nil {
+		return 0, f.err
+	}
+	n := 3 + 100*len(req.Tools)
+	for _, msg := range req.Messages {
+		if msg.Role == RoleSystem {
+			n += len(collectTextParts(msg.Parts))
+			continue
+		}
+		n += 10
+	}
+	return n, nil
+}
+
+func TestEstimateToolTokens(t *testing.T) {
+	if got := EstimateToolTokens(nil); got != 0 {
+		t.Fatalf("EstimateToolTokens(nil) = %d, want 0", got)
+	}
+	small := []ToolSpec{{Name: "read", Description: "Read a file"}}
+	large := []ToolSpec{{
+		Name:        "read",
+		Description: "Read a file",
+		Schema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path": map[string]interface{}{"type": "string", "description": strings.Repeat("path ", 50)},
+			},
+		},
+	}}
+	if s, l := EstimateToolTokens(small), EstimateToolTokens(large); s <= toolTokenOverhead || l <= s {
+		t.Fatalf("EstimateToolTokens small=%d large=%d, want overhead < small < large", s, l)
+	}
+}
+
+func TestCountTokenBreakdownUsesCounterPrefixes(t *testing.T) {
+	counter := &fakeTokenCounter{DebugProvider: NewDebugProvider("fast")}
+	in := TokenCountInput{
+		System:           "abcd",
+		SystemWithSkills: "abcd<skills/>",
+		Tools:            []ToolSpec{{Name: "a"}, {Name: "b"}},
+		Messages:         []Message{UserText("hi"), AssistantText("hello")},
+	}
+	got, err := CountTokenBreakdown(context.Background(), counter, in)
+	if err != nil {
+		t.Fatalf("CountTokenBreakdown: %v", err)
+	}
+	want := TokenBreakdown{System: 4, Skills: 9, Tools: 200, Messages: 23, Total: 236, Exact: true}
+	if got != want {
+		t.Fatalf("breakdown = %+v, want %+v", got, want)
+	}
+	if counter.calls != 4 {
+		t.Fatalf("calls = %d, want 4", counter.calls)
+	}
+}
+
+func TestCountTokenBreakdownSkipsEmptySections(t *testing.T) {
+	counter := &fakeTokenCounter{DebugProvider: NewDebugProvider("fast")}
+	got, err := CountTokenBreakdown(context.Background(), counter, TokenCountInput{System: "sys"})
+	if err != nil {
+		t.Fatalf("CountTokenBreakdown: %v", err)
+	}
+	if got.System != 3 || got.Messages != 13 || got.Skills != 0 || got.Tools != 0 {
+		t.Fatalf("breakdown = %+v", got)
+	}
+	if counter.calls != 2 {
+		t.Fatalf("calls = %d, want 2", counter.calls)
+	}
+}
+
+func TestCountTokenBreakdownFallsBackToEstimate(t *testing.T) {
+	in := TokenCountInput{System: strings.Repeat("x", 40), Messages: []Message{UserText(strings.Repeat("y", 80))}}
+	want := EstimateTokenBreakdown(in)
+	if want.System != 10 || want.Messages != 20 || want.Total != 30 || want.Exact {
+		t.Fatalf("estimate = %+v", want)
+	}
+
+	got, err := CountTokenBreakdown(context.Background(), NewDebugProvider("fast"), in)
+	if err != nil || got != want {
+		t.Fatalf("no counter: got %+v, %v", got, err)
+	}
+
+	unsupported := WrapWithRetry(NewDebugProvider("fast"), DefaultRetryConfig())
+	got, err = CountTokenBreakdown(context.Background(), unsupported, in)
+	if err != nil || got != want {
+		t.Fatalf("unsupported: got %+v, %v", got, err)
+	}
+
+	failing := &fakeTokenCounter{DebugProvider: NewDebugProvider("fast"), err: errors.New("boom")}
+	got, err = CountTokenBreakdown(context.Background(), failing, in)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected counter error, got %v", err)
+	}
+	if got != want {
+		t.Fatalf("failing: got %+v, want estimate %+v", got, want)
+	}
+}
+
+func TestAnthropicCountTokens(t *testing.T) {
+	var body map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages/count_tokens" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"input_tokens":42}`))
+	}))
+	defer ts.Close()
+
+	client := anthropic.NewClient(option.WithAPIKey("test-key"), option.WithBaseURL(ts.URL))
+	provider := &AnthropicProvider{client: &client, model: "claude-sonnet-4-6"}
+	n, err := provider.CountTokens(context.Background(), Request{
+		Messages: []Message{SystemText("be brief"), UserText("hi")},
+		Tools:    []ToolSpec{{Name: "read", Description: "Read a file", Schema: map[string]interface{}{"type": "object"}}},
+	})
+	if err != nil {
+		t.Fatalf("CountTokens: %v", err)
+	}
+	if n != 42 {
+		t.Fatalf("CountTokens = %d, want 42", n)
+	}
+	if body["model"] != "claude-sonnet-4-6" || body["system"] != "be brief" {
+		t.Fatalf("request body = %v", body)
+	}
+	if tools, _ := body["tools"].([]any); len(tools) != 1 {
+		t.Fatalf("tools = %v", body["tools"])
+	}
+}
@@ -0,0 +1,206 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/samsaffron/term-llm/internal/tracing"
+	"github.com/samsaffron/term-llm/internal/usage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attribute keys. Token and model attributes follow the OpenTelemetry
+// GenAI semantic conventions; the rest are term-llm specific.
+const (
+	attrProvider         = attribute.Key("gen_ai.provider.name")
+	attrRequestModel     = attribute.Key("gen_ai.request.model")
+	attrResponseModel    = attribute.Key("gen_ai.response.model")
+	attrInputTokens      = attribute.Key("gen_ai.usage.input_tokens")
+	attrOutputTokens     = attribute.Key("gen_ai.usage.output_tokens")
+	attrCacheReadTokens  = attribute.Key("gen_ai.usage.cache_read.input_tokens")
+	attrCacheWriteTokens = attribute.Key("gen_ai.usage.cache_creation.input_tokens")
+	attrToolName         = attribute.Key("gen_ai.tool.name")
+	attrToolCallID       = attribute.Key("gen_ai.tool.call.id")
+	attrCostUSD          = attribute.Key("term_llm.cost_usd")
+	attrSessionID        = attribute.Key("term_llm.session_id")
+	attrOutcome          = attribute.Key("term_llm.outcome")
+	attrToolSuccess      = attribute.Key("term_llm.tool.success")
+	attrCompactionKind   = attribute.Key("term_llm.compaction.kind")
+	attrCompactionInput  = attribute.Key("term_llm.compaction.messages_in")
+	attrCompactionOutput = attribute.Key("term_llm.compaction.messages_out")
+)
+
+var tracer = tracing.Tracer("llm")
+
+// spanPricing prices token usage for span attributes. It only reads the
+// local pricing cache, so ending a span never waits on the network.
+var spanPricing = sync.OnceValue(usage.NewPricingFetcher)
+
+// startLLMSpan starts an engine or provider span labelled with the
+// normalised provider and requested model.
+func startLLMSpan(ctx context.Context, name, providerName string, req Request) (context.Context, trace.Span) {
+	provider, model := MetricsProviderLabel(providerName)
+	if req.Model != "" {
+		model = req.Model
+	}
+	attrs := []attribute.KeyValue{attrProvider.String(provider), attrRequestModel.String(model)}
+	if req.SessionID != "" {
+		attrs = append(attrs, attrSessionID.String(req.SessionID))
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...), trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// streamProvider makes one provider call under a "provider.stream" span
+// that ends when the provider stream does.
+func (e *Engine) streamProvider(ctx context.Context, req Request) (Stream, error) {
+	ctx, span := startLLMSpan(ctx, "provider.stream", e.provider.Name(), req)
+	stream, err := e.provider.Stream(ctx, req)
+	if err != nil {
+		tracing.EndSpan(span, err)
+		return nil, err
+	}
+	return wrapTraceStream(stream, span, req.Model), nil
+}
+
+// traceStream ends a span when its stream finishes, annotating it with the
+// usage, cost and model the stream reported.
+type traceStream struct {
+	inner Stream
+	span  trace.Span
+
+	mu    sync.Mutex
+	model string
+	use   Usage
+	ended bool
+}
+
+// wrapTraceStream hands ownership of span to the returned stream.
+func wrapTraceStream(inner Stream, span trace.Span, model string) Stream {
+	if !span.IsRecording() {
+		span.End()
+		return inner
+	}
+	return &traceStream{inner: inner, span: span, model: model}
+}
+
+func (s *traceStream) Recv() (Event, error) {
+	event, err := s.inner.Recv()
+	if err != nil {
+		switch {
+		case errors.Is(err, io.EOF):
+			s.end("ok", nil)
+		case errors.Is(err, context.Canceled):
+			s.end("cancelled", nil)
+		default:
+			s.end("error", err)
+		}
+		return event, err
+	}
+	switch event.Type {
+	case EventUsage:
+		if event.Use != nil {
+			s.mu.Lock()
+			s.use.InputTokens += event.Use.InputTokens
+			s.use.OutputTokens += event.Use.OutputTokens
+			s.use.CachedInputTokens += event.Use.CachedInputTokens
+			s.use.CacheWriteTokens += event.Use.CacheWriteTokens
+			s.mu.Unlock()
+		}
+	case EventModelSwitch:
+		if event.Model != "" {
+			s.mu.Lock()
+			s.model = event.Model
+			s.mu.Unlock()
+		}
+	case EventError:
+		s.end("error", event.Err)
+	case EventDone:
+		s.end("ok", nil)
+	}
+	return event, nil
+}
+
+func (s *traceStream) Close() error {
+	s.end("cancelled", nil)
+	return s.inner.Close()
+}
+
+func (s *traceStream) end(outcome string, err error) {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	use, model := s.use, s.model
+	s.mu.Unlock()
+
+	s.span.SetAttributes(
+		attrOutcome.String(outcome),
+		attrInputTokens.Int(use.InputTokens),
+		attrOutputTokens.Int(use.OutputTokens),
+	)
+	if use.CachedInputTokens > 0 {
+		s.span.SetAttributes(attrCacheReadTokens.Int(use.CachedInputTokens))
+	}
+	if use.CacheWriteTokens > 0 {
+		s.span.SetAttributes(attrCacheWriteTokens.Int(use.CacheWriteTokens))
+	}
+	if model != "" {
+		s.span.SetAttributes(attrResponseModel.String(model))
+		if use.InputTokens > 0 || use.OutputTokens > 0 {
+			cost, costErr := spanPricing().CalculateCostLocal(usage.UsageEntry{
+				Model:            model,
+				InputTokens:      use.InputTokens,
+				OutputTokens:     use.OutputTokens,
+				CacheReadTokens:  use.CachedInputTokens,
+				CacheWriteTokens: use.CacheWriteTokens,
+			})
+			if costErr == nil && cost > 0 {
+				s.span.SetAttributes(attrCostUSD.Float64(cost))
+			}
+		}
+	}
+	tracing.EndSpan(s.span, err)
+}
+
+// startToolSpan starts the span for one tool execution.
+func startToolSpan(ctx context.Context, call ToolCall) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "tool.execute", trace.WithAttributes(
+		attrToolName.String(call.Name),
+		attrToolCallID.String(call.ID),
+	))
+}
+
+// endToolSpan records a tool execution's outcome and ends its span.
+func endToolSpan(span trace.Span, output ToolOutput, err error) {
+	success := err == nil && !output.TimedOut && !output.IsError
+	span.SetAttributes(attrToolSuccess.Bool(success))
+	if err == nil && !success {
+		span.SetStatus(codes.Error, "tool reported an error")
+	}
+	tracing.EndSpan(span, err)
+}
+
+// traceCompaction runs a compaction helper under a "compaction" span.
+func traceCompaction(ctx context.Context, kind, model string, messages []Message, run func(context.Context) (*CompactionResult, error)) (*CompactionResult, error) {
+	ctx, span := tracer.Start(ctx, "compaction", trace.WithAttributes(
+		attrCompactionKind.String(kind),
+		attrRequestModel.String(model),
+		attrCompactionInput.Int(len(messages)),
+	))
+	result, err := run(ctx)
+	if result != nil {
+		span.SetAttributes(
+			attrCompactionOutput.Int(len(result.NewMessages)),
+			attrInputTokens.Int(result.Usage.InputTokens),
+			attrOutputTokens.Int(result.Usage.OutputTokens),
+		)
+	}
+	tracing.EndSpan(span, err)
+	return result, err
+}
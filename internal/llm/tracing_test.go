@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	testSpanRecorderOnce sync.Once
+	testSpanRecorder     *tracetest.SpanRecorder
+	testTracerProvider   *sdktrace.TracerProvider
+)
+
+// startTestTrace installs a process-wide recording tracer provider (the
+// global can only be delegated once) and starts a root span so each test can
+// pick out its own spans by trace ID.
+func startTestTrace(t *testing.T) (context.Context, trace.TraceID) {
+	t.Helper()
+	testSpanRecorderOnce.Do(func() {
+		testSpanRecorder = tracetest.NewSpanRecorder()
+		testTracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(testSpanRecorder))
+		otel.SetTracerProvider(testTracerProvider)
+	})
+	ctx, root := testTracerProvider.Tracer("test").Start(context.Background(), t.Name())
+	root.End()
+	return ctx, root.SpanContext().TraceID()
+}
+
+func endedSpans(traceID trace.TraceID) map[string][]sdktrace.ReadOnlySpan {
+	spans := map[string][]sdktrace.ReadOnlySpan{}
+	for _, span := range testSpanRecorder.Ended() {
+		if span.SpanContext().TraceID() == traceID {
+			spans[span.Name()] = append(spans[span.Name()], span)
+		}
+	}
+	return spans
+}
+
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestEngineTracesRunProviderCallsAndTools(t *testing.T) {
+	ctx, traceID := startTestTrace(t)
+
+	tool := &countingTool{}
+	registry := NewToolRegistry()
+	registry.Register(tool)
+	provider := &fakeProvider{
+		script: func(call int, req Request) []Event {
+			if call == 0 {
+				return []Event{
+					{Type: EventToolCall, Tool: &ToolCall{ID: "call-1", Name: "count_tool", Arguments: json.RawMessage(`{}`)}},
+					{Type: EventUsage, Use: &Usage{InputTokens: 100, OutputTokens: 10}},
+					{Type: EventDone},
+				}
+			}
+			return []Event{
+				{Type: EventTextDelta, Text: "done"},
+				{Type: EventUsage, Use: &Usage{InputTokens: 120, OutputTokens: 5}},
+				{Type: EventDone},
+			}
+		},
+	}
+	engine := NewEngine(provider, registry)
+	stream, err := engine.Stream(ctx, Request{
+		Model:      "fake-model",
+		SessionID:  "sess-1",
+		Messages:   []Message{UserText("run tool")},
+		Tools:      []ToolSpec{tool.Spec()},
+		ToolChoice: ToolChoice{Mode: ToolChoiceAuto},
+	})
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("recv error: %v", err)
+		}
+	}
+	_ = stream.Close()
+
+	spans := endedSpans(traceID)
+	runs, calls, tools := spans["engine.run"], spans["provider.stream"], spans["tool.execute"]
+	if len(runs) != 1 || len(calls) != 2 || len(tools) != 1 {
+		t.Fatalf("spans: engine.run=%d provider.stream=%d tool.execute=%d, want 1/2/1", len(runs), len(calls), len(tools))
+	}
+	run := runs[0]
+	if got := spanAttr(run, attrInputTokens).AsInt64(); got != 220 {
+		t.Errorf("engine.run input tokens = %d, want 220", got)
+	}
+	if got := spanAttr(run, attrSessionID).AsString(); got != "sess-1" {
+		t.Errorf("engine.run session = %q", got)
+	}
+	if got := spanAttr(run, attrProvider).AsString(); got != "fake" {
+		t.Errorf("engine.run provider = %q", got)
+	}
+	for _, call := range calls {
+		if call.Parent().SpanID() != run.SpanContext().SpanID() {
+			t.Errorf("provider.stream parent = %v, want engine.run", call.Parent().SpanID())
+		}
+	}
+	if got := spanAttr(tools[0], attrToolName).AsString(); got != "count_tool" {
+		t.Errorf("tool name = %q", got)
+	}
+	if !spanAttr(tools[0], attrToolSuccess).AsBool() {
+		t.Error("tool span not marked successful")
+	}
+	if tools[0].Parent().SpanID() != run.SpanContext().SpanID() {
+		t.Error("tool.execute is not a child of engine.run")
+	}
+}
+
+func TestTraceStreamRecordsErrors(t *testing.T) {
+	ctx, traceID := startTestTrace(t)
+
+	_, span := tracer.Start(ctx, "provider.stream")
+	stream := wrapTraceStream(&errAfterEventsStream{err: io.ErrUnexpectedEOF}, span, "m")
+	if _, err := stream.Recv(); err == nil {
+		t.Fatal("expected error")
+	}
+	_ = stream.Close()
+
+	spans := endedSpans(traceID)["provider.stream"]
+	if len(spans) != 1 {
+		t.Fatalf("spans = %d, want 1", len(spans))
+	}
+	if spans[0].Status().Code.String() != "Error" || spanAttr(spans[0], attrOutcome).AsString() != "error" {
+		t.Fatalf("status = %v outcome = %q", spans[0].Status(), spanAttr(spans[0], attrOutcome).AsString())
+	}
+}
@@ -0,0 +1,390 @@
+// Package metrics is a small Prometheus-compatible metrics registry.
+//
+// It supports counters, histograms and scrape-time gauges, and renders the
+// Prometheus text exposition format (version 0.0.4). Every metric has a
+// fixed set of label names and a cap on the number of label combinations it
+// tracks: once the cap is reached, new combinations are folded into a single
+// series whose labels are all "other", so a misbehaving label source cannot
+// grow memory or scrape size without bound.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxSeries is the per-metric label combination cap used when a
+// metric is registered without an explicit one.
+const DefaultMaxSeries = 200
+
+// OverflowLabel replaces every label value of a series that exceeded its
+// metric's cap, and is also used for empty label values.
+const OverflowLabel = "other"
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are latency buckets in seconds suitable for HTTP requests and
+// model calls, which range from milliseconds to several minutes.
+var DefBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
+// Registry holds metrics and renders them in registration order.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[m.name()] {
+		panic("metrics: duplicate metric " + m.name())
+	}
+	r.names[m.name()] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes all metrics in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	metrics := slices.Clone(r.metrics)
+	r.mu.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteText(w)
+	})
+}
+
+// desc is the identity shared by every metric kind.
+type desc struct {
+	metricName string
+	help       string
+	labels     []string
+	maxSeries  int
+}
+
+func (d desc) name() string { return d.metricName }
+
+func (d desc) writeHeader(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, escapeHelp(d.help), d.metricName, kind)
+}
+
+// seriesKey joins label values into a map key, normalising empty values.
+func (d desc) seriesKey(values []string) (string, []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.metricName, len(d.labels), len(values)))
+	}
+	normalized := make([]string, len(values))
+	for i, v := range values {
+		if v == "" {
+			v = OverflowLabel
+		}
+		normalized[i] = v
+	}
+	return strings.Join(normalized, "\xff"), normalized
+}
+
+func (d desc) overflowValues() []string {
+	values := make([]string, len(d.labels))
+	for i := range values {
+		values[i] = OverflowLabel
+	}
+	return values
+}
+
+func (d desc) formatLabels(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range d.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(values[i]))
+		b.WriteByte('"')
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(extra[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Options configure a metric at registration.
+type Options struct {
+	Name   string
+	Help   string
+	Labels []string
+	// MaxSeries caps distinct label combinations; 0 means DefaultMaxSeries.
+	MaxSeries int
+}
+
+func (o Options) desc() desc {
+	maxSeries := o.MaxSeries
+	if maxSeries <= 0 {
+		maxSeries = DefaultMaxSeries
+	}
+	return desc{metricName: o.Name, help: o.Help, labels: slices.Clone(o.Labels), maxSeries: maxSeries}
+}
+
+// Counter is a monotonically increasing value per label combination.
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+	order  []string
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// NewCounter registers a counter.
+func (r *Registry) NewCounter(opts Options) *Counter {
+	c := &Counter{desc: opts.desc(), series: make(map[string]*counterSeries)}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the series for the label values.
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Add adds v, which must not be negative, to the series for the label values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 || math.IsNaN(v) {
+		return
+	}
+	key, values := c.seriesKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		if len(c.series) >= c.maxSeries {
+			values = c.overflowValues()
+			key, _ = c.seriesKey(values)
+			s = c.series[key]
+		}
+		if s == nil {
+			s = &counterSeries{values: values}
+			c.series[key] = s
+			c.order = append(c.order, key)
+		}
+	}
+	s.value += v
+}
+
+// Value returns the current value of a series, for tests and diagnostics.
+func (c *Counter) Value(labelValues ...string) float64 {
+	key, _ := c.seriesKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.writeHeader(w, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.order) {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.formatLabels(s.values), formatFloat(s.value))
+	}
+}
+
+// Histogram counts observations into cumulative buckets per label
+// combination.
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+	order   []string
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram registers a histogram with the given upper bounds, which must
+// be sorted ascending; nil uses DefBuckets.
+func (r *Registry) NewHistogram(opts Options, buckets []float64) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	h := &Histogram{desc: opts.desc(), buckets: slices.Clone(buckets), series: make(map[string]*histogramSeries)}
+	r.register(h)
+	return h
+}
+
+// Observe records v in the series for the label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	if math.IsNaN(v) {
+		return
+	}
+	key, values := h.seriesKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		if len(h.series) >= h.maxSeries {
+			values = h.overflowValues()
+			key, _ = h.seriesKey(values)
+			s = h.series[key]
+		}
+		if s == nil {
+			s = &histogramSeries{values: values, counts: make([]uint64, len(h.buckets))}
+			h.series[key] = s
+			h.order = append(h.order, key)
+		}
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations in a series, for tests and
+// diagnostics.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	key, _ := h.seriesKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.writeHeader(w, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.order) {
+		s := h.series[key]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.formatLabels(s.values, "le", formatFloat(upper)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.formatLabels(s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.formatLabels(s.values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.formatLabels(s.values), s.count)
+	}
+}
+
+// GaugeFunc reports values computed at scrape time, such as queue depths.
+type GaugeFunc struct {
+	desc
+	collect func(emit func(value float64, labelValues ...string))
+}
+
+// NewGaugeFunc registers a gauge whose series are produced by collect on
+// every scrape. Series beyond the metric's cap are summed into the overflow
+// series.
+func (r *Registry) NewGaugeFunc(opts Options, collect func(emit func(value float64, labelValues ...string))) *GaugeFunc {
+	g := &GaugeFunc{desc: opts.desc(), collect: collect}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	type sample struct {
+		values []string
+		value  float64
+	}
+	samples := make(map[string]*sample)
+	var order []string
+	g.collect(func(value float64, labelValues ...string) {
+		key, values := g.seriesKey(labelValues)
+		s, ok := samples[key]
+		if !ok {
+			if len(samples) >= g.maxSeries {
+				values = g.overflowValues()
+				key, _ = g.seriesKey(values)
+				s = samples[key]
+			}
+			if s == nil {
+				s = &sample{values: values}
+				samples[key] = s
+				order = append(order, key)
+			}
+		}
+		s.value += value
+	})
+	g.writeHeader(w, "gauge")
+	for _, key := range sortedKeys(order) {
+		s := samples[key]
+		fmt.Fprintf(w, "%s%s %s\n", g.metricName, g.formatLabels(s.values), formatFloat(s.value))
+	}
+}
+
+func sortedKeys(keys []string) []string {
+	sorted := slices.Clone(keys)
+	slices.Sort(sorted)
+	return sorted
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter(Options{Name: "app_requests_total", Help: "Requests.", Labels: []string{"route", "status"}})
+	latency := r.NewHistogram(Options{Name: "app_request_seconds", Help: "Latency.", Labels: []string{"route"}}, []float64{0.1, 1})
+	r.NewGaugeFunc(Options{Name: "app_queue_depth", Help: "Queued items."}, func(emit func(float64, ...string)) {
+		emit(3)
+	})
+
+	requests.Inc("/b", "200")
+	requests.Add(2, "/a", "500")
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.5, "/a")
+	latency.Observe(5, "/a")
+
+	var b strings.Builder
+	r.WriteText(&b)
+	want := `# HELP app_requests_total Requests.
+# TYPE app_requests_total counter
+app_requests_total{route="/a",status="500"} 2
+app_requests_total{route="/b",status="200"} 1
+# HELP app_request_seconds Latency.
+# TYPE app_request_seconds histogram
+app_request_seconds_bucket{route="/a",le="0.1"} 1
+app_request_seconds_bucket{route="/a",le="1"} 2
+app_request_seconds_bucket{route="/a",le="+Inf"} 3
+app_request_seconds_sum{route="/a"} 5.55
+app_request_seconds_count{route="/a"} 3
+# HELP app_queue_depth Queued items.
+# TYPE app_queue_depth gauge
+app_queue_depth 3
+`
+	if got := b.String(); got != want {
+		t.Fatalf("WriteText mismatch\n got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSeriesCapFoldsIntoOverflow(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter(Options{Name: "calls_total", Help: "Calls.", Labels: []string{"model"}, MaxSeries: 2})
+	c.Inc("a")
+	c.Inc("b")
+	c.Inc("c")
+	c.Inc("d")
+	c.Inc("a")
+	if got := c.Value("a"); got != 2 {
+		t.Fatalf("a = %v, want 2", got)
+	}
+	if got := c.Value("c"); got != 0 {
+		t.Fatalf("c tracked separately: %v", got)
+	}
+	if got := c.Value(OverflowLabel); got != 2 {
+		t.Fatalf("overflow = %v, want 2", got)
+	}
+
+	g := r.NewGaugeFunc(Options{Name: "nodes_up", Help: "Nodes.", Labels: []string{"node"}, MaxSeries: 1}, func(emit func(float64, ...string)) {
+		emit(1, "x")
+		emit(1, "y")
+		emit(1, "z")
+	})
+	var b strings.Builder
+	g.write(&b)
+	if !strings.Contains(b.String(), `nodes_up{node="other"} 2`) {
+		t.Fatalf("gauge overflow not folded:\n%s", b.String())
+	}
+}
+
+func TestLabelEscapingAndHandler(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter(Options{Name: "errors_total", Help: "Errors\nby kind.", Labels: []string{"kind"}})
+	c.Inc("say \"hi\"\\now")
+	c.Inc("")
+
+	rr := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rr.Header().Get("Content-Type"); ct != ContentType {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		`# HELP errors_total Errors\nby kind.`,
+		`errors_total{kind="say \"hi\"\\now"} 1`,
+		`errors_total{kind="other"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("body missing %q:\n%s", want, body)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	r.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("POST status = %d, want 405", rr.Code)
+	}
+}
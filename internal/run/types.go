@@ -12,6 +12,10 @@ const (
 	PlatformConsole  = "console"
 	PlatformWeb      = "web"
 	PlatformTelegram = "telegram"
+	PlatformSlack    = "slack"
+	PlatformDiscord  = "discord"
+	PlatformEmail    = "email"
+	PlatformMatrix   = "matrix"
 	PlatformJob      = "jobs"
 	PlatformChat     = "chat"
 	PlatformExec     = "exec"
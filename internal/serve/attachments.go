@@ -0,0 +1,128 @@
+package serve
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/samsaffron/term-llm/internal/llm"
+)
+
+const (
+	// maxChatAttachmentBytes caps a single file shared with a chat adapter.
+	maxChatAttachmentBytes = 20 << 20
+	// maxInlineAttachmentTextBytes is the largest text file inlined into
+	// the prompt instead of being attached.
+	maxInlineAttachmentTextBytes = 256 << 10
+)
+
+// chatAttachment is a file shared with a chat adapter message.
+type chatAttachment struct {
+	Name     string
+	MimeType string
+	Size     int64
+	URL      string
+}
+
+// buildAttachmentMessage turns message text and shared files into one user
+// turn. Images are passed as image parts (and kept on disk for tools until
+// the returned cleanup runs), small text files are inlined, and other files
+// are attached. tempPrefix names the temp files, e.g. "slack-upload".
+func buildAttachmentMessage(ctx context.Context, text string, files []chatAttachment, tempPrefix string, download func(context.Context, string) ([]byte, error)) (llm.Message, func(), error) {
+	var tempPaths []string
+	cleanup := func() {
+		for _, path := range tempPaths {
+			os.Remove(path)
+		}
+	}
+	msg := llm.Message{Role: llm.RoleUser}
+	for _, f := range files {
+		if f.URL == "" {
+			continue
+		}
+		if f.Size > maxChatAttachmentBytes {
+			return msg, cleanup, fmt.Errorf("%s is too large (%d bytes, max %d)", f.Name, f.Size, maxChatAttachmentBytes)
+		}
+		data, err := download(ctx, f.URL)
+		if err != nil {
+			return msg, cleanup, fmt.Errorf("download %s: %w", f.Name, err)
+		}
+		mimeType := strings.TrimSpace(f.MimeType)
+		if mimeType == "" || mimeType == "application/octet-stream" {
+			mimeType = http.DetectContentType(data)
+		}
+		mediaType, _, _ := strings.Cut(mimeType, ";")
+		encoded := base64.StdEncoding.EncodeToString(data)
+
+		switch {
+		case strings.HasPrefix(mediaType, "image/"):
+			path, err := writeAttachmentTempFile(tempPrefix, mimeExtension(mediaType), data)
+			if err != nil {
+				return msg, cleanup, err
+			}
+			tempPaths = append(tempPaths, path)
+			msg.Parts = append(msg.Parts, llm.Part{
+				Type:      llm.PartImage,
+				ImageData: &llm.ToolImageData{MediaType: mediaType, Base64: encoded},
+				ImagePath: path,
+			})
+		case isInlineTextMedia(mediaType, data) && len(data) <= maxInlineAttachmentTextBytes:
+			msg.Parts = append(msg.Parts, llm.Part{
+				Type: llm.PartText,
+				Text: fmt.Sprintf("Attached file %s:\n```\n%s\n```", f.Name, strings.TrimRight(string(data), "\n")),
+			})
+		default:
+			path, err := writeAttachmentTempFile(tempPrefix, filepath.Ext(f.Name), data)
+			if err != nil {
+				return msg, cleanup, err
+			}
+			tempPaths = append(tempPaths, path)
+			msg.Parts = append(msg.Parts, llm.Part{
+				Type: llm.PartFile,
+				Text: fmt.Sprintf("[attached file: %s (%s, %d bytes)]", f.Name, mediaType, len(data)),
+				FileData: &llm.ToolFileData{
+					MediaType: mediaType,
+					Base64:    encoded,
+					Filename:  f.Name,
+					SizeBytes: int64(len(data)),
+				},
+				FilePath: path,
+			})
+		}
+	}
+	if text != "" {
+		msg.Parts = append(msg.Parts, llm.Part{Type: llm.PartText, Text: text})
+	}
+	return msg, cleanup, nil
+}
+
+func isInlineTextMedia(mediaType string, data []byte) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json",
+		mediaType == "application/xml",
+		mediaType == "application/x-yaml",
+		mediaType == "application/yaml":
+		return utf8.Valid(data)
+	}
+	return false
+}
+
+func writeAttachmentTempFile(prefix, ext string, data []byte) (string, error) {
+	tmp, err := os.CreateTemp("", prefix+"-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("create temp file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("write temp file: %w", err)
+	}
+	tmp.Close()
+	return tmp.Name(), nil
+}
@@ -0,0 +1,282 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samsaffron/term-llm/internal/llm"
+	runpkg "github.com/samsaffron/term-llm/internal/run"
+	"github.com/samsaffron/term-llm/internal/session"
+	"github.com/samsaffron/term-llm/internal/ui"
+)
+
+// chatTurn runs one conversation turn for a chat-style adapter (Slack,
+// Discord, ...). It assembles the request, persists messages as the engine
+// produces them, and exposes the event stream for the adapter to render.
+type chatTurn struct {
+	platform  string // runpkg platform name; also selects the PlatformMessages entry
+	store     session.Store
+	sessionID string
+
+	cancel     context.CancelFunc
+	stream     llm.Stream
+	newHistory []llm.Message
+	cleanup    []func()
+
+	mu       sync.Mutex
+	produced []llm.Message
+	metrics  llm.TurnMetrics
+	turns    int
+	captured bool // assistant message already captured by the response callback
+}
+
+// chatTurnEvent is one item read from a turn's stream.
+type chatTurnEvent struct {
+	ev  llm.Event
+	err error
+}
+
+// startChatTurn persists the user message and starts streaming a reply. The
+// caller must call close when done reading events and finish to obtain the
+// messages to append to its in-memory history.
+func startChatTurn(ctx context.Context, platform string, settings Settings, runtime *SessionRuntime, sessionID string, history []llm.Message, userMsg llm.Message) (*chatTurn, error) {
+	if runtime == nil {
+		return nil, fmt.Errorf("session is closed")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	t := &chatTurn{
+		platform:  platform,
+		store:     settings.Store,
+		sessionID: sessionID,
+		cancel:    cancel,
+	}
+
+	messages := make([]llm.Message, 0, len(history)+3)
+	if settings.SystemPrompt != "" && !containsSystemMsg(history) {
+		sysMsg := llm.SystemText(settings.SystemPrompt)
+		messages = append(messages, sysMsg)
+		t.newHistory = append(t.newHistory, sysMsg)
+	}
+	if devText := settings.PlatformMessages.For(platform); devText != "" {
+		messages = append(messages, llm.Message{Role: llm.RoleDeveloper, Parts: []llm.Part{{Type: llm.PartText, Text: devText}}})
+	}
+	messages = append(messages, history...)
+	messages = append(messages, userMsg)
+	t.newHistory = append(t.newHistory, normalizeUserMessageForHistory(userMsg))
+
+	for _, msg := range t.newHistory {
+		t.persist(ctx, msg)
+	}
+	if t.store != nil {
+		if err := t.store.UpdateStatus(ctx, sessionID, session.StatusActive); err != nil {
+			log.Printf("[%s] session UpdateStatus failed for %s: %v", platform, sessionID, err)
+		}
+	}
+
+	if settings.Runner != nil {
+		pipe := runpkg.NewEventPipe(ctx, ui.DefaultStreamBufferSize)
+		t.stream = pipe
+		runnerDone := make(chan struct{})
+		t.cleanup = append(t.cleanup, func() { <-runnerDone })
+		search := settings.Search
+		forceExternalSearch := settings.ForceExternalSearch
+		go func() {
+			defer close(runnerDone)
+			_, runErr := settings.Runner.Run(ctx, runpkg.Request{
+				Platform:                  platform,
+				AgentName:                 settings.Agent,
+				Messages:                  messages,
+				Engine:                    runtime.Engine,
+				ProviderInstance:          runtime.Provider,
+				SessionID:                 sessionID,
+				DeferSession:              true,
+				DisableRuntimePersistence: true,
+				Persist:                   false,
+				Tools:                     settings.Tools,
+				MCP:                       settings.MCP,
+				MaxTurns:                  settings.MaxTurns,
+				Search:                    &search,
+				Debug:                     settings.Debug,
+				DebugRaw:                  settings.DebugRaw,
+				ForceExternalSearch:       &forceExternalSearch,
+				OnResponseCompleted:       t.onResponseCompleted,
+				OnTurnCompleted:           t.onTurnCompleted,
+			}, pipe)
+			pipe.CloseWithError(runErr)
+		}()
+		return t, nil
+	}
+
+	runtime.Engine.SetResponseCompletedCallback(t.onResponseCompleted)
+	runtime.Engine.SetTurnCompletedCallback(t.onTurnCompleted)
+	t.cleanup = append(t.cleanup, func() {
+		runtime.Engine.SetResponseCompletedCallback(nil)
+		runtime.Engine.SetTurnCompletedCallback(nil)
+	})
+
+	req := llm.Request{
+		SessionID:           sessionID,
+		Messages:            messages,
+		MaxTurns:            settings.MaxTurns,
+		Debug:               settings.Debug,
+		DebugRaw:            settings.DebugRaw,
+		Search:              settings.Search,
+		ForceExternalSearch: settings.ForceExternalSearch,
+	}
+	if specs := llm.ToolSpecsForRequest(runtime.Engine.Tools(), settings.Search); len(specs) > 0 {
+		req.Tools = specs
+		req.ToolChoice = llm.ToolChoice{Mode: llm.ToolChoiceAuto}
+	}
+	stream, err := runtime.Engine.Stream(ctx, req)
+	if err != nil {
+		t.close()
+		t.updateStatus(session.StatusError)
+		return nil, fmt.Errorf("stream: %w", err)
+	}
+	t.stream = stream
+	return t, nil
+}
+
+func (t *chatTurn) onResponseCompleted(ctx context.Context, _ int, assistantMsg llm.Message, _ llm.TurnMetrics) error {
+	t.mu.Lock()
+	t.produced = append(t.produced, assistantMsg)
+	t.captured = true
+	t.mu.Unlock()
+	t.persist(ctx, assistantMsg)
+	return nil
+}
+
+func (t *chatTurn) onTurnCompleted(ctx context.Context, _ int, msgs []llm.Message, metrics llm.TurnMetrics) error {
+	start := 0
+	t.mu.Lock()
+	if t.captured && len(msgs) > 0 && msgs[0].Role == llm.RoleAssistant {
+		start = 1
+	}
+	t.produced = append(t.produced, msgs[start:]...)
+	t.metrics.ToolCalls += metrics.ToolCalls
+	t.metrics.InputTokens += metrics.InputTokens
+	t.metrics.OutputTokens += metrics.OutputTokens
+	t.metrics.CachedInputTokens += metrics.CachedInputTokens
+	t.metrics.CacheWriteTokens += metrics.CacheWriteTokens
+	t.turns++
+	t.captured = false
+	t.mu.Unlock()
+	for _, msg := range msgs[start:] {
+		t.persist(ctx, msg)
+	}
+	return nil
+}
+
+// events reads the stream until it ends. The channel closes after the final
+// item (io.EOF, an error) or once ctx is done.
+func (t *chatTurn) events(ctx context.Context) <-chan chatTurnEvent {
+	out := make(chan chatTurnEvent)
+	go func() {
+		defer close(out)
+		for {
+			ev, err := t.stream.Recv()
+			select {
+			case out <- chatTurnEvent{ev, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// close stops the turn and releases the stream, waiting for a runner
+// goroutine to unwind.
+func (t *chatTurn) close() {
+	t.cancel()
+	if t.stream != nil {
+		t.stream.Close()
+	}
+	for _, fn := range t.cleanup {
+		fn()
+	}
+	t.cleanup = nil
+}
+
+// finish records usage and the outcome of the turn and returns the messages
+// to append to the adapter's history. partialText is kept as the assistant
+// reply when the turn ended before the engine produced a message.
+func (t *chatTurn) finish(ctx context.Context, streamErr error, partialText string) []llm.Message {
+	t.mu.Lock()
+	produced := append([]llm.Message(nil), t.produced...)
+	metrics, turns := t.metrics, t.turns
+	t.mu.Unlock()
+	if len(produced) == 0 && partialText != "" {
+		partial := llm.AssistantText(partialText)
+		produced = append(produced, partial)
+		t.persist(ctx, partial)
+	}
+
+	if t.store != nil {
+		storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		if err := t.store.UpdateMetrics(storeCtx, t.sessionID, turns, metrics.ToolCalls, metrics.InputTokens, metrics.OutputTokens, metrics.CachedInputTokens, metrics.CacheWriteTokens); err != nil {
+			log.Printf("[%s] session UpdateMetrics failed for %s: %v", t.platform, t.sessionID, err)
+		}
+		cancel()
+	}
+	switch {
+	case errors.Is(streamErr, context.Canceled):
+		t.updateStatus(session.StatusInterrupted)
+	case streamErr != nil:
+		t.updateStatus(session.StatusError)
+	}
+
+	out := append([]llm.Message(nil), t.newHistory...)
+	return append(out, produced...)
+}
+
+func (t *chatTurn) persist(ctx context.Context, msg llm.Message) {
+	if t.store == nil {
+		return
+	}
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := t.store.AddMessage(storeCtx, t.sessionID, session.NewMessage(t.sessionID, msg, -1)); err != nil {
+		log.Printf("[%s] session AddMessage failed for %s: %v", t.platform, t.sessionID, err)
+	}
+}
+
+func (t *chatTurn) updateStatus(status session.SessionStatus) {
+	if t.store == nil {
+		return
+	}
+	storeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := t.store.UpdateStatus(storeCtx, t.sessionID, status); err != nil {
+		log.Printf("[%s] session UpdateStatus failed for %s: %v", t.platform, t.sessionID, err)
+	}
+}
+
+// splitMessageAtLine cuts text at the last line break before maxRunes,
+// closing and reopening a code fence that spans the cut.
+func splitMessageAtLine(text string, maxRunes int) (head, rest string) {
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text, ""
+	}
+	cut := maxRunes
+	if idx := strings.LastIndex(string(runes[:maxRunes]), "\n"); idx > 0 {
+		cut = utf8.RuneCountInString(string(runes[:maxRunes])[:idx])
+	}
+	head = string(runes[:cut])
+	rest = strings.TrimLeft(string(runes[cut:]), "\n")
+	if strings.Count(head, "```")%2 == 1 {
+		head += "\n```"
+		rest = "```\n" + rest
+	}
+	return head, rest
+}
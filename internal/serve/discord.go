@@ -0,0 +1,1228 @@
+package serve
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/llm"
+	runpkg "github.com/samsaffron/term-llm/internal/run"
+	"github.com/samsaffron/term-llm/internal/session"
+	"github.com/samsaffron/term-llm/internal/tools"
+)
+
+const (
+	// discordMaxMessageRunes leaves headroom under Discord's 2000 character
+	// message limit for the status line and code fence repairs.
+	discordMaxMessageRunes = 1900
+	// discordMinEditInterval stays inside the per-channel message rate
+	// limit (5 requests per 5 seconds) while streaming.
+	discordMinEditInterval = 1500 * time.Millisecond
+
+	discordMaxConcurrentHandlers = 16
+	discordFinalDeliveryTimeout  = 30 * time.Second
+	discordMaxThreadNameRunes    = 90
+
+	discordScopeThread  = "thread"
+	discordScopeChannel = "channel"
+
+	// Discord REST error codes that mean a thread cannot be started on the
+	// message because it already lives in a thread.
+	discordErrCannotExecuteOnChannelType = 50024
+	discordErrThreadAlreadyCreated       = 160004
+)
+
+// discordMessageTypeDefault and discordMessageTypeReply are the user
+// message types the bot answers; system messages (joins, pins) are ignored.
+const (
+	discordMessageTypeDefault = 0
+	discordMessageTypeReply   = 19
+)
+
+// discordApprovalEmoji are the keycap reactions accepted as approval answers.
+var discordApprovalEmoji = []string{"1️⃣", "2️⃣", "3️⃣", "4️⃣", "5️⃣", "6️⃣", "7️⃣", "8️⃣", "9️⃣"}
+
+// DiscordPlatform implements Platform for a Discord bot.
+type DiscordPlatform struct {
+	cfg config.DiscordServeConfig
+
+	// rest and gateway override the network clients in tests.
+	rest    discordREST
+	gateway discordGateway
+}
+
+// NewDiscordPlatform creates a new DiscordPlatform with the given config.
+func NewDiscordPlatform(cfg config.DiscordServeConfig) *DiscordPlatform {
+	return &DiscordPlatform{cfg: cfg}
+}
+
+func (p *DiscordPlatform) Name() string { return "discord" }
+
+// NeedsSetup returns true when the bot token is missing.
+func (p *DiscordPlatform) NeedsSetup() bool {
+	return strings.TrimSpace(p.cfg.Token) == ""
+}
+
+// RunSetup runs an interactive wizard that collects and persists bot credentials.
+func (p *DiscordPlatform) RunSetup() error {
+	scanner := bufio.NewScanner(os.Stdin)
+	prompt := func(label string) (string, error) {
+		fmt.Print(label)
+		if !scanner.Scan() {
+			return "", fmt.Errorf("no input received")
+		}
+		return strings.TrimSpace(scanner.Text()), nil
+	}
+	splitIDs := func(raw string) []string {
+		var ids []string
+		for _, part := range strings.Split(raw, ",") {
+			if id := strings.TrimSpace(part); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+
+	fmt.Println()
+	fmt.Println("Discord Bot Setup")
+	fmt.Println("=================")
+	fmt.Println()
+	fmt.Println("1. Create an application at https://discord.com/developers/applications,")
+	fmt.Println("   open Bot, enable the Message Content intent and reset/copy the token.")
+	token, err := prompt("   Bot token: ")
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return fmt.Errorf("token cannot be empty")
+	}
+
+	fmt.Println()
+	fmt.Println("2. Invite the bot with the scopes bot and applications.commands and the")
+	fmt.Println("   permissions Send Messages, Send Messages in Threads, Create Public Threads,")
+	fmt.Println("   Read Message History, Attach Files and Add Reactions.")
+	fmt.Println()
+	fmt.Println("3. Whitelist users (Developer Mode → right-click a user → Copy User ID).")
+	fmt.Println("   Users listed here can also approve tool calls.")
+	rawUsers, err := prompt("   Allowed user IDs (comma-separated): ")
+	if err != nil {
+		return err
+	}
+	fmt.Println("   Optionally allow everyone with a guild role. A server's ID is its @everyone role.")
+	rawRoles, err := prompt("   Allowed role IDs (comma-separated, optional): ")
+	if err != nil {
+		return err
+	}
+	userIDs, roleIDs := splitIDs(rawUsers), splitIDs(rawRoles)
+	if len(userIDs) == 0 && len(roleIDs) == 0 {
+		return fmt.Errorf("at least one user or role ID is required")
+	}
+
+	newCfg := p.cfg
+	newCfg.Token = token
+	newCfg.AllowedUserIDs = userIDs
+	newCfg.AllowedRoleIDs = roleIDs
+
+	if err := config.SetServeDiscordConfig(newCfg); err != nil {
+		return fmt.Errorf("save discord config: %w", err)
+	}
+
+	p.cfg = newCfg
+	fmt.Println()
+	fmt.Println("Discord configuration saved.")
+	return nil
+}
+
+// Run connects to the Discord gateway and serves messages, blocking until
+// ctx is cancelled.
+func (p *DiscordPlatform) Run(ctx context.Context, cfg *config.Config, settings Settings) error {
+	token := strings.TrimSpace(p.cfg.Token)
+	if token == "" {
+		return fmt.Errorf("discord token is not configured; run with --setup to configure")
+	}
+	if len(p.cfg.AllowedUserIDs) == 0 && len(p.cfg.AllowedRoleIDs) == 0 {
+		log.Println("[discord] warning: no allowed_user_ids or allowed_role_ids configured; all messages will be rejected")
+	}
+	switch p.cfg.SessionScope {
+	case "", discordScopeThread, discordScopeChannel:
+	default:
+		return fmt.Errorf("invalid discord session_scope %q (valid: thread, channel)", p.cfg.SessionScope)
+	}
+
+	rest := p.rest
+	if rest == nil {
+		rest = newDiscordRESTClient(p.cfg.APIURL, token)
+	}
+	gateway := p.gateway
+	if gateway == nil {
+		gateway = newDiscordGatewayClient(rest, token)
+	}
+
+	me, err := rest.currentUser(ctx)
+	if err != nil {
+		return fmt.Errorf("discord connect: %w", err)
+	}
+	log.Printf("[discord] authorised as %s (%s)", me.Username, me.ID)
+
+	idleTimeout := settings.IdleTimeout
+	if idleTimeout <= 0 {
+		if p.cfg.IdleTimeout > 0 {
+			idleTimeout = time.Duration(p.cfg.IdleTimeout) * time.Minute
+		} else {
+			idleTimeout = 30 * time.Minute
+		}
+	}
+
+	mgr := newDiscordSessionMgr(ctx, rest, settings, me.ID, p.cfg)
+	mgr.idleTimeout = idleTimeout
+	go mgr.reapIdleSessions(ctx)
+	defer mgr.closeAllSessions()
+
+	return gateway.run(ctx, mgr.dispatch)
+}
+
+// discordSession holds one channel's or thread's conversation state.
+type discordSession struct {
+	mu        sync.Mutex // held for a whole turn so messages run in order
+	channelID string
+	runtime   *SessionRuntime
+	history   []llm.Message
+	meta      *session.Session
+
+	// Guarded by discordSessionMgr.mu.
+	lastActivity time.Time
+	turnCancel   context.CancelFunc
+	closed       bool
+}
+
+// discordPendingApproval is a tool approval waiting on a button or reaction.
+type discordPendingApproval struct {
+	id        string
+	channelID string
+	messageID string // the approval message, edited once answered
+	req       ApprovalRequest
+	answer    chan discordApprovalAnswer
+}
+
+type discordApprovalAnswer struct {
+	choice int
+	user   string
+}
+
+// discordSessionMgr manages per-channel and per-thread sessions.
+type discordSessionMgr struct {
+	ctx       context.Context
+	rest      discordREST
+	settings  Settings
+	store     session.Store
+	botUserID string
+	scope     string
+
+	idleTimeout     time.Duration
+	editInterval    time.Duration // 0 means use discordMinEditInterval; overridden in tests
+	allowedUsers    map[string]struct{}
+	allowedRoles    map[string]struct{}
+	allowedChannels map[string]struct{}
+	approvers       map[string]struct{}
+	messageSlots    chan struct{}
+
+	mu       sync.Mutex
+	sessions map[string]*discordSession
+	threads  map[string]struct{} // threads the bot runs a conversation in
+
+	approvalMu sync.Mutex
+	approvals  map[string]*discordPendingApproval
+}
+
+func newDiscordSessionMgr(ctx context.Context, rest discordREST, settings Settings, botUserID string, cfg config.DiscordServeConfig) *discordSessionMgr {
+	toSet := func(ids []string) map[string]struct{} {
+		set := make(map[string]struct{}, len(ids))
+		for _, id := range ids {
+			if id = strings.TrimSpace(id); id != "" {
+				set[id] = struct{}{}
+			}
+		}
+		return set
+	}
+	scope := cfg.SessionScope
+	if scope == "" {
+		scope = discordScopeThread
+	}
+	approvers := cfg.ApproverIDs
+	if len(approvers) == 0 {
+		approvers = cfg.AllowedUserIDs
+	}
+	return &discordSessionMgr{
+		ctx:             ctx,
+		rest:            rest,
+		settings:        settings,
+		store:           settings.Store,
+		botUserID:       botUserID,
+		scope:           scope,
+		idleTimeout:     30 * time.Minute,
+		allowedUsers:    toSet(cfg.AllowedUserIDs),
+		allowedRoles:    toSet(cfg.AllowedRoleIDs),
+		allowedChannels: toSet(cfg.AllowedChannelIDs),
+		approvers:       toSet(approvers),
+		messageSlots:    make(chan struct{}, discordMaxConcurrentHandlers),
+		sessions:        make(map[string]*discordSession),
+		threads:         make(map[string]struct{}),
+		approvals:       make(map[string]*discordPendingApproval),
+	}
+}
+
+// isAllowed reports whether the author may talk to the bot, by user ID or
+// by one of their guild roles.
+func (m *discordSessionMgr) isAllowed(userID string, member *discordMember) bool {
+	if _, ok := m.allowedUsers[userID]; ok {
+		return true
+	}
+	if member != nil {
+		for _, role := range member.Roles {
+			if _, ok := m.allowedRoles[role]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m *discordSessionMgr) isApprover(userID string) bool {
+	_, ok := m.approvers[userID]
+	return ok
+}
+
+// isAllowedChannel applies allowed_channel_ids to guild channels. Threads
+// the bot opened inherit their parent's permission; DMs are governed by the
+// user allowlist alone.
+func (m *discordSessionMgr) isAllowedChannel(msg discordMessage) bool {
+	if len(m.allowedChannels) == 0 || msg.GuildID == "" {
+		return true
+	}
+	if _, ok := m.allowedChannels[msg.ChannelID]; ok {
+		return true
+	}
+	return m.isBotThread(msg.ChannelID)
+}
+
+func (m *discordSessionMgr) isBotThread(channelID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.threads[channelID]
+	return ok
+}
+
+// dispatch handles one gateway event. It must not block the gateway read
+// loop, so message handling runs in its own goroutine.
+func (m *discordSessionMgr) dispatch(eventType string, data json.RawMessage) {
+	switch eventType {
+	case "MESSAGE_CREATE":
+		var msg discordMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Printf("[discord] invalid message payload: %v", err)
+			return
+		}
+		if !m.shouldHandle(msg) {
+			return
+		}
+		if !m.isAllowed(msg.Author.ID, msg.Member) {
+			log.Printf("[discord] ignoring message from unauthorised user %s (%s)", msg.Author.Username, msg.Author.ID)
+			return
+		}
+		if !m.isAllowedChannel(msg) {
+			log.Printf("[discord] ignoring message in channel %s (not in allowed_channel_ids)", msg.ChannelID)
+			return
+		}
+		go func() {
+			select {
+			case m.messageSlots <- struct{}{}:
+			case <-m.ctx.Done():
+				return
+			}
+			defer func() { <-m.messageSlots }()
+			m.handleMessage(m.ctx, msg)
+		}()
+	case "INTERACTION_CREATE":
+		m.handleInteraction(data)
+	case "MESSAGE_REACTION_ADD":
+		m.handleReaction(data)
+	}
+}
+
+// shouldHandle selects messages that start or continue a conversation:
+// direct messages, @-mentions, and anything in a thread the bot is running.
+func (m *discordSessionMgr) shouldHandle(msg discordMessage) bool {
+	if msg.Author.Bot || msg.WebhookID != "" || msg.Author.ID == "" || msg.Author.ID == m.botUserID {
+		return false
+	}
+	if msg.Type != discordMessageTypeDefault && msg.Type != discordMessageTypeReply {
+		return false
+	}
+	return msg.GuildID == "" || m.mentionsBot(msg) || m.isBotThread(msg.ChannelID)
+}
+
+func (m *discordSessionMgr) mentionsBot(msg discordMessage) bool {
+	for _, u := range msg.Mentions {
+		if u.ID == m.botUserID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *discordSessionMgr) stripMention(text string) string {
+	text = strings.ReplaceAll(text, "<@"+m.botUserID+">", "")
+	text = strings.ReplaceAll(text, "<@!"+m.botUserID+">", "")
+	return strings.TrimSpace(text)
+}
+
+func (m *discordSessionMgr) handleMessage(ctx context.Context, msg discordMessage) {
+	text := m.stripMention(msg.Content)
+	if cmd, ok := strings.CutPrefix(text, "!"); ok && !strings.ContainsAny(cmd, " \n") {
+		if m.handleCommand(ctx, msg, strings.ToLower(cmd)) {
+			return
+		}
+	}
+
+	channelID, thread, replyTo := m.conversationChannel(ctx, msg, text)
+
+	attachments := make([]chatAttachment, 0, len(msg.Attachments))
+	for _, a := range msg.Attachments {
+		attachments = append(attachments, chatAttachment{Name: a.Filename, MimeType: a.ContentType, Size: a.Size, URL: a.URL})
+	}
+	userMsg, cleanup, err := buildAttachmentMessage(ctx, text, attachments, "discord-upload", m.rest.downloadAttachment)
+	defer cleanup()
+	if err != nil {
+		log.Printf("[discord] failed to read attachments in %s: %v", msg.ChannelID, err)
+		m.postNotice(ctx, channelID, "Failed to process attachment: "+err.Error())
+		return
+	}
+	if len(userMsg.Parts) == 0 {
+		return
+	}
+
+	sess, err := m.getOrCreate(ctx, channelID, thread)
+	if err != nil {
+		m.postNotice(ctx, channelID, "Error creating session: "+err.Error())
+		return
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if m.isClosed(sess) {
+		// Reset while this message was queued behind the previous turn.
+		if sess, err = m.getOrCreate(ctx, channelID, thread); err != nil {
+			m.postNotice(ctx, channelID, "Error creating session: "+err.Error())
+			return
+		}
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+	}
+	if err := m.streamReply(ctx, sess, userMsg, replyTo); err != nil {
+		log.Printf("[discord] reply failed in %s: %v", sess.channelID, err)
+		m.postNotice(ctx, channelID, "Error: "+err.Error())
+	}
+}
+
+// conversationChannel picks the channel a message's conversation lives in.
+// In thread scope a mention in a guild channel opens a thread on the
+// message; a mention inside an existing thread continues there. replyTo is
+// set when the reply should quote the triggering message.
+func (m *discordSessionMgr) conversationChannel(ctx context.Context, msg discordMessage, text string) (channelID string, thread bool, replyTo string) {
+	m.mu.Lock()
+	_, ok := m.sessions[msg.ChannelID]
+	_, thread = m.threads[msg.ChannelID]
+	m.mu.Unlock()
+	if ok || thread || msg.GuildID == "" {
+		return msg.ChannelID, thread, ""
+	}
+	if m.scope != discordScopeThread {
+		return msg.ChannelID, false, msg.ID
+	}
+
+	threadID, err := m.rest.startThread(ctx, msg.ChannelID, msg.ID, discordThreadName(text))
+	if err == nil {
+		return threadID, true, ""
+	}
+	var apiErr *discordAPIError
+	if errors.As(err, &apiErr) && (apiErr.Code == discordErrCannotExecuteOnChannelType || apiErr.Code == discordErrThreadAlreadyCreated) {
+		// Already in a thread: keep the conversation there.
+		return msg.ChannelID, true, ""
+	}
+	log.Printf("[discord] start thread in %s failed, replying in channel: %v", msg.ChannelID, err)
+	return msg.ChannelID, false, msg.ID
+}
+
+// discordThreadName derives a thread title from the opening message.
+func discordThreadName(text string) string {
+	name := strings.Join(strings.Fields(text), " ")
+	if name == "" {
+		return "term-llm"
+	}
+	if utf8.RuneCountInString(name) > discordMaxThreadNameRunes {
+		name = string([]rune(name)[:discordMaxThreadNameRunes-1]) + "…"
+	}
+	return name
+}
+
+func (m *discordSessionMgr) postNotice(ctx context.Context, channelID, text string) {
+	if _, err := m.rest.createMessage(ctx, channelID, discordMessageSend{Content: text, AllowedMentions: discordAllowedMentions{Parse: []string{}}}); err != nil {
+		log.Printf("[discord] post notice to %s: %v", channelID, err)
+	}
+}
+
+// handleCommand answers the !-prefixed text commands. It returns false for
+// unknown commands so they reach the agent as ordinary text.
+func (m *discordSessionMgr) handleCommand(ctx context.Context, msg discordMessage, cmd string) bool {
+	switch cmd {
+	case "help":
+		m.postNotice(ctx, msg.ChannelID, "Mention me or send a direct message to start a conversation. "+
+			"In servers each conversation gets its own thread; reply there without mentioning me.\n\n"+
+			"`!status` - show this conversation's state\n"+
+			"`!reset` - end this conversation and start fresh")
+	case "reset":
+		m.mu.Lock()
+		sess := m.sessions[msg.ChannelID]
+		m.mu.Unlock()
+		if sess == nil {
+			m.postNotice(ctx, msg.ChannelID, "No active conversation here.")
+			return true
+		}
+		m.closeSession(sess)
+		m.postNotice(ctx, msg.ChannelID, "Conversation cleared.")
+	case "status":
+		m.mu.Lock()
+		sess := m.sessions[msg.ChannelID]
+		running := sess != nil && sess.turnCancel != nil
+		var lastActivity time.Time
+		if sess != nil {
+			lastActivity = sess.lastActivity
+		}
+		m.mu.Unlock()
+		m.approvalMu.Lock()
+		waiting := 0
+		for _, pending := range m.approvals {
+			if pending.channelID == msg.ChannelID {
+				waiting++
+			}
+		}
+		m.approvalMu.Unlock()
+		if sess == nil {
+			m.postNotice(ctx, msg.ChannelID, "No active conversation here.")
+			return true
+		}
+		state := "idle"
+		if running {
+			state = "responding"
+		}
+		m.postNotice(ctx, msg.ChannelID, fmt.Sprintf("Session `%s`: %s, last activity <t:%d:R>\nPending approvals: %d",
+			sess.meta.ID, state, lastActivity.Unix(), waiting))
+	default:
+		return false
+	}
+	return true
+}
+
+func (m *discordSessionMgr) getOrCreate(ctx context.Context, channelID string, thread bool) (*discordSession, error) {
+	m.mu.Lock()
+	if sess, ok := m.sessions[channelID]; ok {
+		sess.lastActivity = time.Now()
+		m.mu.Unlock()
+		return sess, nil
+	}
+	m.mu.Unlock()
+
+	created, err := m.newSession(ctx, channelID, thread)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if existing, ok := m.sessions[channelID]; ok {
+		m.mu.Unlock()
+		closeDiscordRuntime(created)
+		return existing, nil
+	}
+	m.sessions[channelID] = created
+	if thread {
+		m.threads[channelID] = struct{}{}
+	}
+	m.mu.Unlock()
+	return created, nil
+}
+
+// newSession creates a runtime for a channel, resuming the channel's stored
+// session when the bot restarted mid-conversation.
+func (m *discordSessionMgr) newSession(ctx context.Context, channelID string, thread bool) (*discordSession, error) {
+	if m.settings.NewSession == nil {
+		return nil, fmt.Errorf("discord runtime factory is not configured")
+	}
+	runtime, err := m.settings.NewSession(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create runtime: %w", err)
+	}
+
+	sess := &discordSession{
+		channelID:    channelID,
+		runtime:      runtime,
+		lastActivity: time.Now(),
+	}
+	if runtime.SetApprovalHandler != nil {
+		runtime.SetApprovalHandler(func(req ApprovalRequest) (int, error) {
+			return m.requestApproval(sess, req)
+		})
+	}
+
+	name := "discord:" + channelID
+	if m.store != nil && m.resumeSession(ctx, sess, name) {
+		return sess, nil
+	}
+
+	providerName := strings.TrimSpace(runtime.ProviderName)
+	if providerName == "" {
+		providerName = "unknown"
+	}
+	modelName := strings.TrimSpace(runtime.ModelName)
+	if modelName == "" {
+		modelName = "unknown"
+	}
+	sess.meta = &session.Session{
+		ID:        session.NewID(),
+		Name:      name,
+		Provider:  providerName,
+		Model:     modelName,
+		Mode:      session.ModeChat,
+		Origin:    session.OriginDiscord,
+		Agent:     m.settings.Agent,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Search:    m.settings.Search,
+		Tools:     m.settings.Tools,
+		MCP:       m.settings.MCP,
+		Status:    session.StatusActive,
+	}
+	if cwd, cwdErr := os.Getwd(); cwdErr == nil {
+		sess.meta.CWD = cwd
+	}
+	if m.store != nil {
+		if err := m.store.Create(ctx, sess.meta); err != nil {
+			log.Printf("[discord] session Create failed for %s: %v", sess.meta.ID, err)
+		}
+	}
+	return sess, nil
+}
+
+func (m *discordSessionMgr) resumeSession(ctx context.Context, sess *discordSession, name string) bool {
+	summaries, err := m.store.List(ctx, session.ListOptions{Name: name, Limit: 1})
+	if err != nil || len(summaries) == 0 {
+		return false
+	}
+	meta, err := m.store.Get(ctx, summaries[0].ID)
+	if err != nil || meta == nil {
+		return false
+	}
+	stored, err := m.store.GetMessages(ctx, meta.ID, 0, 0)
+	if err != nil {
+		log.Printf("[discord] resume %s: get messages failed: %v", meta.ID, err)
+		return false
+	}
+	sess.meta = meta
+	for _, msg := range stored {
+		sess.history = append(sess.history, msg.ToLLMMessage())
+	}
+	log.Printf("[discord] resumed session %s (%d messages) for %s", meta.ID, len(sess.history), name)
+	return true
+}
+
+func (m *discordSessionMgr) isClosed(sess *discordSession) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sess.closed
+}
+
+// closeSession removes sess and cancels its active turn. The runtime is
+// released once the turn has unwound.
+func (m *discordSessionMgr) closeSession(sess *discordSession) {
+	m.mu.Lock()
+	if m.sessions[sess.channelID] == sess {
+		delete(m.sessions, sess.channelID)
+	}
+	sess.closed = true
+	if sess.turnCancel != nil {
+		sess.turnCancel()
+	}
+	m.mu.Unlock()
+	m.cancelApprovals(sess.channelID)
+
+	go func() {
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		closeDiscordRuntime(sess)
+	}()
+}
+
+func closeDiscordRuntime(sess *discordSession) {
+	if sess.runtime != nil && sess.runtime.Cleanup != nil {
+		sess.runtime.Cleanup()
+	}
+	sess.runtime = nil
+}
+
+func (m *discordSessionMgr) closeAllSessions() {
+	m.mu.Lock()
+	sessions := make([]*discordSession, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		sessions = append(sessions, sess)
+	}
+	m.mu.Unlock()
+	for _, sess := range sessions {
+		m.closeSession(sess)
+	}
+}
+
+// reapIdleSessions closes sessions with no activity for idleTimeout.
+// History stays in the store, so the next message resumes it.
+func (m *discordSessionMgr) reapIdleSessions(ctx context.Context) {
+	interval := min(m.idleTimeout/2, time.Minute)
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var idle []*discordSession
+		m.mu.Lock()
+		for _, sess := range m.sessions {
+			if sess.turnCancel == nil && time.Since(sess.lastActivity) > m.idleTimeout {
+				idle = append(idle, sess)
+			}
+		}
+		m.mu.Unlock()
+		for _, sess := range idle {
+			// m.threads keeps the thread, so follow-ups still need no mention.
+			m.closeSession(sess)
+		}
+	}
+}
+
+// streamReply runs one turn and streams it into the channel by editing a
+// placeholder message. Replies that outgrow one message continue in a new
+// one. The caller holds sess.mu.
+func (m *discordSessionMgr) streamReply(ctx context.Context, sess *discordSession, userMsg llm.Message, replyTo string) error {
+	if sess.runtime == nil {
+		return fmt.Errorf("session is closed")
+	}
+	turnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m.mu.Lock()
+	sess.turnCancel = cancel
+	sess.lastActivity = time.Now()
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		sess.turnCancel = nil
+		sess.lastActivity = time.Now()
+		m.mu.Unlock()
+	}()
+
+	turn, err := startChatTurn(turnCtx, runpkg.PlatformDiscord, m.settings, sess.runtime, sess.meta.ID, sess.history, userMsg)
+	if err != nil {
+		return err
+	}
+	defer turn.close()
+
+	reply := &discordReplyWriter{
+		rest:         m.rest,
+		channelID:    sess.channelID,
+		replyTo:      replyTo,
+		editInterval: m.editInterval,
+	}
+	if err := reply.start(turnCtx); err != nil {
+		return fmt.Errorf("send placeholder: %w", err)
+	}
+
+	var (
+		streamErr error
+		images    []string
+		toolsRan  bool
+	)
+	events := turn.events(turnCtx)
+	ticker := time.NewTicker(reply.interval())
+	defer ticker.Stop()
+recvLoop:
+	for {
+		select {
+		case <-ticker.C:
+			reply.flush(turnCtx, false)
+		case <-turnCtx.Done():
+			streamErr = turnCtx.Err()
+			break recvLoop
+		case res, ok := <-events:
+			if !ok {
+				break recvLoop
+			}
+			if res.err != nil {
+				if !errors.Is(res.err, io.EOF) {
+					streamErr = res.err
+				}
+				break recvLoop
+			}
+			switch ev := res.ev; ev.Type {
+			case llm.EventTextDelta:
+				reply.appendText(ev.Text)
+			case llm.EventToolExecStart:
+				toolsRan = true
+				status := "🔧 " + ev.ToolName
+				if ev.ToolInfo != "" {
+					status += " " + ev.ToolInfo
+				}
+				reply.setStatus(status)
+			case llm.EventToolExecEnd:
+				reply.setStatus("")
+				images = append(images, ev.ToolImages...)
+			case llm.EventRetry:
+				reply.setStatus(fmt.Sprintf("retrying (attempt %d)…", ev.RetryAttempt))
+			case llm.EventError:
+				if ev.Err != nil {
+					streamErr = ev.Err
+				}
+			}
+		}
+	}
+
+	deliveryCtx, cancelDelivery := context.WithTimeout(context.WithoutCancel(ctx), discordFinalDeliveryTimeout)
+	defer cancelDelivery()
+	reply.setStatus("")
+	if streamErr != nil {
+		notice := "⚠️ " + streamErr.Error()
+		if errors.Is(streamErr, context.Canceled) {
+			notice = "*(stopped)*"
+		}
+		reply.appendNotice(notice)
+	} else if reply.empty() {
+		if toolsRan {
+			reply.appendNotice("(done)")
+		} else {
+			reply.appendNotice("(no response)")
+		}
+	}
+	if err := reply.flush(deliveryCtx, true); err != nil {
+		log.Printf("[discord] final edit failed in %s: %v", sess.channelID, err)
+	}
+	for _, path := range images {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[discord] read tool image %s: %v", path, err)
+			continue
+		}
+		if err := m.rest.uploadFile(deliveryCtx, sess.channelID, filepath.Base(path), data); err != nil {
+			log.Printf("[discord] upload image %s: %v", path, err)
+		}
+	}
+
+	sess.history = append(sess.history, turn.finish(deliveryCtx, streamErr, reply.text())...)
+	return nil
+}
+
+// discordReplyWriter accumulates streamed text and mirrors it into Discord
+// messages, splitting into follow-up messages past discordMaxMessageRunes.
+type discordReplyWriter struct {
+	rest         discordREST
+	channelID    string
+	replyTo      string // message quoted by the first reply message
+	editInterval time.Duration
+
+	mu        sync.Mutex
+	full      strings.Builder // all assistant text this turn
+	pending   string          // text not yet committed to an earlier message
+	status    string
+	currentID string
+	lastSent  string
+	lastEdit  time.Time
+}
+
+func (w *discordReplyWriter) interval() time.Duration {
+	if w.editInterval > 0 {
+		return w.editInterval
+	}
+	return discordMinEditInterval
+}
+
+func (w *discordReplyWriter) send(ctx context.Context, content, replyTo string) error {
+	msg := discordMessageSend{Content: content, AllowedMentions: discordAllowedMentions{Parse: []string{}}}
+	if replyTo != "" {
+		msg.Reference = &discordMessageReference{MessageID: replyTo}
+	}
+	sent, err := w.rest.createMessage(ctx, w.channelID, msg)
+	if err != nil {
+		return err
+	}
+	w.currentID = sent.ID
+	w.lastSent = content
+	return nil
+}
+
+func (w *discordReplyWriter) start(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.send(ctx, "⏳", w.replyTo)
+}
+
+func (w *discordReplyWriter) appendText(text string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.full.WriteString(text)
+	w.pending += text
+}
+
+// appendNotice adds a status line that is shown but not kept as assistant text.
+func (w *discordReplyWriter) appendNotice(text string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if strings.TrimSpace(w.pending) != "" {
+		w.pending += "\n\n"
+	}
+	w.pending += text
+}
+
+func (w *discordReplyWriter) setStatus(status string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status = status
+}
+
+func (w *discordReplyWriter) text() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.full.String()
+}
+
+func (w *discordReplyWriter) empty() bool {
+	return strings.TrimSpace(w.text()) == ""
+}
+
+// flush pushes the current text to Discord. Intermediate flushes are rate
+// limited and skipped when nothing changed; the final flush always edits.
+func (w *discordReplyWriter) flush(ctx context.Context, final bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !final && time.Since(w.lastEdit) < w.interval() {
+		return nil
+	}
+
+	for utf8.RuneCountInString(w.pending) > discordMaxMessageRunes {
+		head, rest := splitMessageAtLine(w.pending, discordMaxMessageRunes)
+		if err := w.edit(ctx, head); err != nil {
+			return err
+		}
+		if err := w.send(ctx, "⏳", ""); err != nil {
+			return err
+		}
+		w.pending = rest
+	}
+
+	body := strings.TrimSpace(w.pending)
+	if w.status != "" {
+		if body != "" {
+			body += "\n\n"
+		}
+		body += "-# " + w.status
+	} else if !final && body != "" {
+		body += " ▌"
+	}
+	if body == "" {
+		if !final {
+			return nil
+		}
+		body = "(no response)"
+	}
+	if body == w.lastSent {
+		return nil
+	}
+	return w.edit(ctx, body)
+}
+
+func (w *discordReplyWriter) edit(ctx context.Context, body string) error {
+	err := w.rest.editMessage(ctx, w.channelID, w.currentID, discordMessageSend{Content: body, AllowedMentions: discordAllowedMentions{Parse: []string{}}})
+	w.lastEdit = time.Now()
+	if err == nil {
+		w.lastSent = body
+	}
+	return err
+}
+
+// requestApproval posts an approval prompt with one button (and keycap
+// reaction) per option and blocks until an approver answers it or the
+// session ends.
+func (m *discordSessionMgr) requestApproval(sess *discordSession, req ApprovalRequest) (int, error) {
+	m.mu.Lock()
+	closed := sess.closed
+	m.mu.Unlock()
+	if closed {
+		return -1, nil
+	}
+
+	pending := &discordPendingApproval{
+		id:        rand.Text()[:16],
+		channelID: sess.channelID,
+		req:       req,
+		answer:    make(chan discordApprovalAnswer, 1),
+	}
+	text := discordApprovalText(req)
+	sent, err := m.rest.createMessage(m.ctx, sess.channelID, discordMessageSend{
+		Content:         text,
+		Components:      discordApprovalComponents(pending.id, req.Options),
+		AllowedMentions: discordAllowedMentions{Parse: []string{}},
+	})
+	if err != nil {
+		return -1, fmt.Errorf("post approval prompt: %w", err)
+	}
+	pending.messageID = sent.ID
+
+	m.approvalMu.Lock()
+	m.approvals[pending.id] = pending
+	m.approvalMu.Unlock()
+	defer func() {
+		m.approvalMu.Lock()
+		delete(m.approvals, pending.id)
+		m.approvalMu.Unlock()
+	}()
+
+	answer := discordApprovalAnswer{choice: -1}
+	select {
+	case answer = <-pending.answer:
+	case <-m.ctx.Done():
+	}
+
+	outcome := "Cancelled"
+	if answer.choice >= 0 && answer.choice < len(req.Options) {
+		outcome = "**" + req.Options[answer.choice].Label + "**"
+	}
+	if answer.user != "" {
+		outcome += " by <@" + answer.user + ">"
+	}
+	resolved := discordApprovalPrompt(req) + "\n➜ " + outcome
+	if err := m.rest.editMessage(context.WithoutCancel(m.ctx), sess.channelID, sent.ID, discordMessageSend{
+		Content:         resolved,
+		AllowedMentions: discordAllowedMentions{Parse: []string{}},
+	}); err != nil {
+		log.Printf("[discord] update approval %s: %v", pending.id, err)
+	}
+	return answer.choice, nil
+}
+
+// discordApprovalPrompt renders the request itself: title, target and
+// working directory.
+func discordApprovalPrompt(req ApprovalRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s**\n```\n%s\n```", req.Title, strings.ReplaceAll(req.Target, "```", "`​``"))
+	if req.WorkDir != "" {
+		fmt.Fprintf(&b, "in `%s`\n", req.WorkDir)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// discordApprovalText is the prompt plus the numbered options that can also
+// be answered with a keycap reaction.
+func discordApprovalText(req ApprovalRequest) string {
+	var b strings.Builder
+	b.WriteString(discordApprovalPrompt(req))
+	b.WriteString("\n")
+	for i, opt := range req.Options {
+		if i < len(discordApprovalEmoji) {
+			fmt.Fprintf(&b, "\n%s %s", discordApprovalEmoji[i], opt.Label)
+		}
+	}
+	return b.String()
+}
+
+// discordApprovalComponents renders one button per option, five per action
+// row (Discord allows at most five rows). custom_id carries
+// "approval:<id>:<option>".
+func discordApprovalComponents(approvalID string, options []tools.ApprovalOption) []discordComponent {
+	var rows []discordComponent
+	for i, opt := range options {
+		if i/5 >= 5 {
+			break
+		}
+		if i%5 == 0 {
+			rows = append(rows, discordComponent{Type: discordComponentActionRow})
+		}
+		style := discordButtonSecondary
+		switch {
+		case opt.Choice == tools.ApprovalChoiceDeny:
+			style = discordButtonDanger
+		case i == 0:
+			style = discordButtonPrimary
+		}
+		label := opt.Label
+		if utf8.RuneCountInString(label) > 80 {
+			label = string([]rune(label)[:79]) + "…"
+		}
+		row := &rows[len(rows)-1]
+		row.Components = append(row.Components, discordComponent{
+			Type:     discordComponentButton,
+			Style:    style,
+			Label:    label,
+			CustomID: fmt.Sprintf("approval:%s:%d", approvalID, i),
+		})
+	}
+	return rows
+}
+
+// resolveApproval delivers an answer to a pending approval. It reports
+// whether the approval existed and the choice was valid.
+func (m *discordSessionMgr) resolveApproval(pending *discordPendingApproval, choice int, userID string) bool {
+	if pending == nil || choice < 0 || choice >= len(pending.req.Options) {
+		return false
+	}
+	select {
+	case pending.answer <- discordApprovalAnswer{choice: choice, user: userID}:
+	default: // already answered
+	}
+	return true
+}
+
+// handleInteraction resolves approval button presses. Every interaction
+// must be acknowledged within three seconds.
+func (m *discordSessionMgr) handleInteraction(data json.RawMessage) {
+	var interaction struct {
+		ID     string         `json:"id"`
+		Token  string         `json:"token"`
+		Type   int            `json:"type"`
+		Member *discordMember `json:"member"`
+		User   *discordUser   `json:"user"`
+		Data   struct {
+			CustomID string `json:"custom_id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &interaction); err != nil {
+		log.Printf("[discord] invalid interaction payload: %v", err)
+		return
+	}
+	if interaction.Type != discordInteractionComponent {
+		return
+	}
+	rest, ok := strings.CutPrefix(interaction.Data.CustomID, "approval:")
+	if !ok {
+		return
+	}
+	userID := ""
+	switch {
+	case interaction.Member != nil && interaction.Member.User != nil:
+		userID = interaction.Member.User.ID
+	case interaction.User != nil:
+		userID = interaction.User.ID
+	}
+
+	respond := func(resp discordInteractionResponse) {
+		go func() {
+			ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+			defer cancel()
+			if err := m.rest.respondInteraction(ctx, interaction.ID, interaction.Token, resp); err != nil {
+				log.Printf("[discord] respond to interaction: %v", err)
+			}
+		}()
+	}
+	if !m.isApprover(userID) {
+		log.Printf("[discord] ignoring approval click from unauthorised user %s", userID)
+		respond(discordInteractionResponse{
+			Type: discordCallbackChannelMessage,
+			Data: &discordInteractionResponseData{Content: "You are not allowed to approve tool calls.", Flags: discordFlagEphemeral},
+		})
+		return
+	}
+
+	id, idx, _ := strings.Cut(rest, ":")
+	var choice int
+	if _, err := fmt.Sscanf(idx, "%d", &choice); err != nil {
+		choice = -1
+	}
+	m.approvalMu.Lock()
+	pending := m.approvals[id]
+	m.approvalMu.Unlock()
+	if !m.resolveApproval(pending, choice, userID) {
+		respond(discordInteractionResponse{
+			Type: discordCallbackChannelMessage,
+			Data: &discordInteractionResponseData{Content: "This approval is no longer pending.", Flags: discordFlagEphemeral},
+		})
+		return
+	}
+	respond(discordInteractionResponse{Type: discordCallbackDeferredUpdate})
+}
+
+// handleReaction resolves approvals answered with a keycap reaction.
+func (m *discordSessionMgr) handleReaction(data json.RawMessage) {
+	var reaction struct {
+		UserID    string `json:"user_id"`
+		MessageID string `json:"message_id"`
+		Emoji     struct {
+			Name string `json:"name"`
+		} `json:"emoji"`
+	}
+	if err := json.Unmarshal(data, &reaction); err != nil {
+		log.Printf("[discord] invalid reaction payload: %v", err)
+		return
+	}
+	if reaction.UserID == m.botUserID {
+		return
+	}
+	choice := -1
+	for i, emoji := range discordApprovalEmoji {
+		if reaction.Emoji.Name == emoji {
+			choice = i
+			break
+		}
+	}
+	if choice < 0 {
+		return
+	}
+	m.approvalMu.Lock()
+	var pending *discordPendingApproval
+	for _, p := range m.approvals {
+		if p.messageID == reaction.MessageID {
+			pending = p
+			break
+		}
+	}
+	m.approvalMu.Unlock()
+	if pending == nil {
+		return
+	}
+	if !m.isApprover(reaction.UserID) {
+		log.Printf("[discord] ignoring approval reaction from unauthorised user %s", reaction.UserID)
+		return
+	}
+	m.resolveApproval(pending, choice, reaction.UserID)
+}
+
+// cancelApprovals dismisses the prompts a closed session left open.
+func (m *discordSessionMgr) cancelApprovals(channelID string) {
+	m.approvalMu.Lock()
+	defer m.approvalMu.Unlock()
+	for _, pending := range m.approvals {
+		if pending.channelID == channelID {
+			select {
+			case pending.answer <- discordApprovalAnswer{choice: -1}:
+			default:
+			}
+		}
+	}
+}
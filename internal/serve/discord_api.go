@@ -0,0 +1,617 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	discordDefaultAPIURL = "https://discord.com/api/v10"
+	discordUserAgent     = "DiscordBot (https://github.com/samsaffron/term-llm, 1)"
+
+	discordMaxAPIResponseBytes = 4 << 20
+	// discordMaxRateLimitWait is the longest 429 retry_after honoured inline;
+	// longer waits surface as an error rather than stalling a reply.
+	discordMaxRateLimitWait = 30 * time.Second
+	discordMaxRetries       = 3
+
+	discordGatewayMaxBackoff = 60 * time.Second
+)
+
+// Gateway intents requested by the bot. MESSAGE_CONTENT is privileged and
+// must be enabled for the application in the developer portal.
+const (
+	discordIntentGuilds                 = 1 << 0
+	discordIntentGuildMessages          = 1 << 9
+	discordIntentGuildMessageReactions  = 1 << 10
+	discordIntentDirectMessages         = 1 << 12
+	discordIntentDirectMessageReactions = 1 << 13
+	discordIntentMessageContent         = 1 << 15
+
+	discordIntents = discordIntentGuilds | discordIntentGuildMessages | discordIntentGuildMessageReactions |
+		discordIntentDirectMessages | discordIntentDirectMessageReactions | discordIntentMessageContent
+)
+
+// Gateway opcodes.
+const (
+	discordOpDispatch       = 0
+	discordOpHeartbeat      = 1
+	discordOpIdentify       = 2
+	discordOpResume         = 6
+	discordOpReconnect      = 7
+	discordOpInvalidSession = 9
+	discordOpHello          = 10
+	discordOpHeartbeatACK   = 11
+)
+
+// Message component and interaction constants.
+const (
+	discordComponentActionRow = 1
+	discordComponentButton    = 2
+
+	discordButtonPrimary   = 1
+	discordButtonSecondary = 2
+	discordButtonDanger    = 4
+
+	discordInteractionComponent = 3
+
+	discordCallbackChannelMessage = 4
+	// discordCallbackDeferredUpdate acknowledges a button press without
+	// changing the message; the bot edits it afterwards.
+	discordCallbackDeferredUpdate = 6
+
+	discordFlagEphemeral = 1 << 6
+)
+
+type discordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Bot      bool   `json:"bot"`
+}
+
+type discordMember struct {
+	User  *discordUser `json:"user"`
+	Roles []string     `json:"roles"`
+}
+
+type discordAttachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url"`
+}
+
+// discordMessage is the subset of a Discord message object we consume.
+type discordMessage struct {
+	ID          string              `json:"id"`
+	ChannelID   string              `json:"channel_id"`
+	GuildID     string              `json:"guild_id"`
+	Author      discordUser         `json:"author"`
+	Member      *discordMember      `json:"member"`
+	Content     string              `json:"content"`
+	Mentions    []discordUser       `json:"mentions"`
+	Attachments []discordAttachment `json:"attachments"`
+	WebhookID   string              `json:"webhook_id"`
+	Type        int                 `json:"type"`
+}
+
+type discordComponent struct {
+	Type       int                `json:"type"`
+	Style      int                `json:"style,omitempty"`
+	Label      string             `json:"label,omitempty"`
+	CustomID   string             `json:"custom_id,omitempty"`
+	Components []discordComponent `json:"components,omitempty"`
+}
+
+// discordMessageSend is a create or edit message request. Components is
+// always sent so an edit with a nil slice removes earlier buttons.
+type discordMessageSend struct {
+	Content         string                   `json:"content"`
+	Components      []discordComponent       `json:"components"`
+	AllowedMentions discordAllowedMentions   `json:"allowed_mentions"`
+	Reference       *discordMessageReference `json:"message_reference,omitempty"`
+}
+
+// discordAllowedMentions controls which mentions in content notify anyone.
+// Replies never ping roles or @everyone, whatever the model writes.
+type discordAllowedMentions struct {
+	Parse []string `json:"parse"`
+	Users []string `json:"users,omitempty"`
+}
+
+type discordMessageReference struct {
+	MessageID       string `json:"message_id"`
+	FailIfNotExists bool   `json:"fail_if_not_exists"`
+}
+
+// discordInteractionResponse answers an interaction. Data is only used for
+// channel-message responses.
+type discordInteractionResponse struct {
+	Type int                             `json:"type"`
+	Data *discordInteractionResponseData `json:"data,omitempty"`
+}
+
+type discordInteractionResponseData struct {
+	Content string `json:"content"`
+	Flags   int    `json:"flags,omitempty"`
+}
+
+// discordREST is the subset of the Discord REST API the adapter uses. It is
+// an interface so tests can substitute an in-memory fake.
+type discordREST interface {
+	currentUser(ctx context.Context) (discordUser, error)
+	gatewayURL(ctx context.Context) (string, error)
+	createMessage(ctx context.Context, channelID string, msg discordMessageSend) (discordMessage, error)
+	editMessage(ctx context.Context, channelID, messageID string, msg discordMessageSend) error
+	startThread(ctx context.Context, channelID, messageID, name string) (string, error)
+	respondInteraction(ctx context.Context, interactionID, token string, resp discordInteractionResponse) error
+	downloadAttachment(ctx context.Context, url string) ([]byte, error)
+	uploadFile(ctx context.Context, channelID, filename string, data []byte) error
+}
+
+// discordGateway delivers gateway dispatch events (MESSAGE_CREATE, ...) to
+// dispatch until ctx is cancelled.
+type discordGateway interface {
+	run(ctx context.Context, dispatch func(eventType string, data json.RawMessage)) error
+}
+
+// discordAPIError is a non-2xx REST response.
+type discordAPIError struct {
+	Method     string
+	Path       string
+	Status     int
+	Code       int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *discordAPIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("discord %s %s: %s (retry after %s)", e.Method, e.Path, msg, e.RetryAfter)
+	}
+	return fmt.Sprintf("discord %s %s: %d %s", e.Method, e.Path, e.Status, msg)
+}
+
+// discordRESTClient is a minimal Discord REST client authenticating as a bot.
+type discordRESTClient struct {
+	apiURL string
+	token  string
+	http   *http.Client
+}
+
+func newDiscordRESTClient(apiURL, token string) *discordRESTClient {
+	apiURL = strings.TrimRight(strings.TrimSpace(apiURL), "/")
+	if apiURL == "" {
+		apiURL = discordDefaultAPIURL
+	}
+	return &discordRESTClient{
+		apiURL: apiURL,
+		token:  token,
+		http:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (c *discordRESTClient) doJSON(ctx context.Context, method, path string, body any, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("discord %s %s: encode request: %w", method, path, err)
+		}
+	}
+	return c.do(ctx, method, path, "application/json", func() io.Reader {
+		if payload == nil {
+			return nil
+		}
+		return bytes.NewReader(payload)
+	}, out)
+}
+
+// do sends one request, waiting out short rate limits. body is called per
+// attempt so the request can be replayed.
+func (c *discordRESTClient) do(ctx context.Context, method, path, contentType string, body func() io.Reader, out any) error {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, body())
+		if err != nil {
+			return fmt.Errorf("discord %s %s: %w", method, path, err)
+		}
+		req.Header.Set("Authorization", "Bot "+c.token)
+		req.Header.Set("User-Agent", discordUserAgent)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return fmt.Errorf("discord %s %s: %w", method, path, err)
+		}
+		data, readErr := io.ReadAll(io.LimitReader(resp.Body, discordMaxAPIResponseBytes))
+		resp.Body.Close()
+		if readErr != nil {
+			return fmt.Errorf("discord %s %s: read response: %w", method, path, readErr)
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			if out != nil && len(data) > 0 {
+				if err := json.Unmarshal(data, out); err != nil {
+					return fmt.Errorf("discord %s %s: decode response: %w", method, path, err)
+				}
+			}
+			return nil
+		}
+
+		apiErr := &discordAPIError{Method: method, Path: path, Status: resp.StatusCode}
+		var body struct {
+			Code       int     `json:"code"`
+			Message    string  `json:"message"`
+			RetryAfter float64 `json:"retry_after"`
+		}
+		if json.Unmarshal(data, &body) == nil {
+			apiErr.Code = body.Code
+			apiErr.Message = body.Message
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return apiErr
+		}
+		wait := time.Duration(body.RetryAfter * float64(time.Second))
+		if wait <= 0 {
+			if secs, convErr := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); convErr == nil {
+				wait = time.Duration(secs * float64(time.Second))
+			}
+		}
+		if wait <= 0 {
+			wait = time.Second
+		}
+		apiErr.RetryAfter = wait
+		if attempt >= discordMaxRetries || wait > discordMaxRateLimitWait {
+			return apiErr
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *discordRESTClient) currentUser(ctx context.Context) (discordUser, error) {
+	var user discordUser
+	err := c.doJSON(ctx, http.MethodGet, "/users/@me", nil, &user)
+	return user, err
+}
+
+func (c *discordRESTClient) gatewayURL(ctx context.Context) (string, error) {
+	var out struct {
+		URL string `json:"url"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/gateway/bot", nil, &out); err != nil {
+		return "", err
+	}
+	if out.URL == "" {
+		return "", errors.New("discord /gateway/bot: empty url")
+	}
+	return out.URL, nil
+}
+
+func (c *discordRESTClient) createMessage(ctx context.Context, channelID string, msg discordMessageSend) (discordMessage, error) {
+	var out discordMessage
+	err := c.doJSON(ctx, http.MethodPost, "/channels/"+channelID+"/messages", msg, &out)
+	return out, err
+}
+
+func (c *discordRESTClient) editMessage(ctx context.Context, channelID, messageID string, msg discordMessageSend) error {
+	return c.doJSON(ctx, http.MethodPatch, "/channels/"+channelID+"/messages/"+messageID, msg, nil)
+}
+
+// startThread opens a public thread on messageID and returns its channel ID.
+func (c *discordRESTClient) startThread(ctx context.Context, channelID, messageID, name string) (string, error) {
+	var out struct {
+		ID string `json:"id"`
+	}
+	body := map[string]any{"name": name, "auto_archive_duration": 1440}
+	if err := c.doJSON(ctx, http.MethodPost, "/channels/"+channelID+"/messages/"+messageID+"/threads", body, &out); err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
+
+func (c *discordRESTClient) respondInteraction(ctx context.Context, interactionID, token string, resp discordInteractionResponse) error {
+	return c.doJSON(ctx, http.MethodPost, "/interactions/"+interactionID+"/"+token+"/callback", resp, nil)
+}
+
+// downloadAttachment fetches an attachment from Discord's CDN. Attachment
+// URLs are pre-signed, so no bot token is sent.
+func (c *discordRESTClient) downloadAttachment(ctx context.Context, fileURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", discordUserAgent)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxChatAttachmentBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxChatAttachmentBytes {
+		return nil, fmt.Errorf("file exceeds %d bytes", maxChatAttachmentBytes)
+	}
+	return data, nil
+}
+
+// uploadFile posts data as a message attachment in channelID.
+func (c *discordRESTClient) uploadFile(ctx context.Context, channelID, filename string, data []byte) error {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	payload, _ := json.Marshal(map[string]any{
+		"attachments":      []any{map[string]any{"id": 0, "filename": filename}},
+		"allowed_mentions": discordAllowedMentions{Parse: []string{}},
+	})
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="payload_json"`)
+	header.Set("Content-Type", "application/json")
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	part.Write(payload)
+	filePart, err := mw.CreateFormFile("files[0]", filename)
+	if err != nil {
+		return err
+	}
+	filePart.Write(data)
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	body := buf.Bytes()
+	return c.do(ctx, http.MethodPost, "/channels/"+channelID+"/messages", mw.FormDataContentType(), func() io.Reader {
+		return bytes.NewReader(body)
+	}, nil)
+}
+
+// discordGatewayPayload is one gateway frame.
+type discordGatewayPayload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  *int64          `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+// discordFatalGatewayError is a close code that reconnecting cannot fix,
+// such as an invalid token or disallowed intents.
+type discordFatalGatewayError struct {
+	Code   int
+	Reason string
+}
+
+func (e *discordFatalGatewayError) Error() string {
+	msg := fmt.Sprintf("discord gateway closed with %d: %s", e.Code, e.Reason)
+	if e.Code == 4014 {
+		msg += " (enable the Message Content intent for the bot in the Discord developer portal)"
+	}
+	return msg
+}
+
+// discordGatewayClient maintains a gateway websocket session, resuming it
+// across reconnects when Discord allows.
+type discordGatewayClient struct {
+	rest    discordREST
+	token   string
+	intents int
+	dialer  *websocket.Dialer
+
+	baseURL   string
+	sessionID string
+	resumeURL string
+	seq       atomic.Int64
+}
+
+func newDiscordGatewayClient(rest discordREST, token string) *discordGatewayClient {
+	return &discordGatewayClient{
+		rest:    rest,
+		token:   token,
+		intents: discordIntents,
+		dialer:  websocket.DefaultDialer,
+	}
+}
+
+func (g *discordGatewayClient) run(ctx context.Context, dispatch func(string, json.RawMessage)) error {
+	backoff := time.Second
+	for {
+		connected, err := g.connect(ctx, dispatch)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var fatal *discordFatalGatewayError
+		if errors.As(err, &fatal) {
+			return fatal
+		}
+		if connected {
+			backoff = time.Second
+		}
+		if err == nil {
+			continue // Discord asked us to reconnect
+		}
+		log.Printf("[discord] gateway: %v; reconnecting in %s", err, backoff)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, discordGatewayMaxBackoff)
+	}
+}
+
+// connect runs one websocket connection. connected reports whether the
+// session reached READY or RESUMED, which resets the reconnect backoff.
+func (g *discordGatewayClient) connect(ctx context.Context, dispatch func(string, json.RawMessage)) (connected bool, err error) {
+	resuming := g.sessionID != "" && g.resumeURL != ""
+	wsURL := g.resumeURL
+	if !resuming {
+		if g.baseURL == "" {
+			if g.baseURL, err = g.rest.gatewayURL(ctx); err != nil {
+				return false, err
+			}
+		}
+		wsURL = g.baseURL
+	}
+	conn, _, err := g.dialer.DialContext(ctx, strings.TrimRight(wsURL, "/")+"/?v=10&encoding=json", nil)
+	if err != nil {
+		return false, fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var writeMu sync.Mutex
+	send := func(op int, d any) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return conn.WriteJSON(map[string]any{"op": op, "d": d})
+	}
+
+	var hello discordGatewayPayload
+	if err := conn.ReadJSON(&hello); err != nil {
+		return false, fmt.Errorf("read hello: %w", err)
+	}
+	if hello.Op != discordOpHello {
+		return false, fmt.Errorf("expected hello, got op %d", hello.Op)
+	}
+	var helloData struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	if err := json.Unmarshal(hello.D, &helloData); err != nil || helloData.HeartbeatInterval <= 0 {
+		return false, fmt.Errorf("invalid hello payload")
+	}
+
+	heartbeat := func() error {
+		if seq := g.seq.Load(); seq > 0 {
+			return send(discordOpHeartbeat, seq)
+		}
+		return send(discordOpHeartbeat, nil)
+	}
+	var acked atomic.Bool
+	acked.Store(true)
+	hbCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go func() {
+		ticker := time.NewTicker(time.Duration(helloData.HeartbeatInterval) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-hbCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			if !acked.Swap(false) {
+				// No ACK since the last heartbeat: the connection is a
+				// zombie. Closing it makes the read loop reconnect.
+				log.Printf("[discord] gateway heartbeat not acknowledged; reconnecting")
+				conn.Close()
+				return
+			}
+			if err := heartbeat(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if resuming {
+		err = send(discordOpResume, map[string]any{"token": g.token, "session_id": g.sessionID, "seq": g.seq.Load()})
+	} else {
+		err = send(discordOpIdentify, map[string]any{
+			"token":   g.token,
+			"intents": g.intents,
+			"properties": map[string]string{
+				"os":      runtime.GOOS,
+				"browser": "term-llm",
+				"device":  "term-llm",
+			},
+		})
+	}
+	if err != nil {
+		return false, fmt.Errorf("identify: %w", err)
+	}
+
+	for {
+		var frame discordGatewayPayload
+		if err := conn.ReadJSON(&frame); err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				switch closeErr.Code {
+				case 4004, 4010, 4011, 4012, 4013, 4014:
+					return connected, &discordFatalGatewayError{Code: closeErr.Code, Reason: closeErr.Text}
+				case 4007, 4009:
+					g.sessionID, g.resumeURL = "", ""
+				}
+			}
+			return connected, fmt.Errorf("read: %w", err)
+		}
+		if frame.S != nil {
+			g.seq.Store(*frame.S)
+		}
+		switch frame.Op {
+		case discordOpDispatch:
+			switch frame.T {
+			case "READY":
+				var ready struct {
+					SessionID        string `json:"session_id"`
+					ResumeGatewayURL string `json:"resume_gateway_url"`
+				}
+				if err := json.Unmarshal(frame.D, &ready); err == nil {
+					g.sessionID, g.resumeURL = ready.SessionID, ready.ResumeGatewayURL
+				}
+				connected = true
+				log.Printf("[discord] gateway connected")
+			case "RESUMED":
+				connected = true
+				log.Printf("[discord] gateway session resumed")
+			}
+			dispatch(frame.T, frame.D)
+		case discordOpHeartbeat:
+			if err := heartbeat(); err != nil {
+				return connected, fmt.Errorf("heartbeat: %w", err)
+			}
+		case discordOpHeartbeatACK:
+			acked.Store(true)
+		case discordOpReconnect:
+			return connected, nil
+		case discordOpInvalidSession:
+			var resumable bool
+			_ = json.Unmarshal(frame.D, &resumable)
+			if !resumable {
+				g.sessionID, g.resumeURL = "", ""
+				g.seq.Store(0)
+			}
+			return connected, errors.New("session invalidated")
+		}
+	}
+}
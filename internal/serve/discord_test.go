@@ -0,0 +1,605 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/testutil"
+	"github.com/samsaffron/term-llm/internal/tools"
+)
+
+type discordCall struct {
+	method    string
+	channelID string
+	messageID string
+	msg       discordMessageSend
+	resp      discordInteractionResponse
+}
+
+// fakeDiscordREST records REST calls in memory.
+type fakeDiscordREST struct {
+	mu        sync.Mutex
+	calls     []discordCall
+	nextID    int
+	threadErr error
+}
+
+func (f *fakeDiscordREST) record(c discordCall) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, c)
+	f.nextID++
+	return fmt.Sprintf("M%d", f.nextID)
+}
+
+func (f *fakeDiscordREST) callsTo(method string) []discordCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []discordCall
+	for _, c := range f.calls {
+		if c.method == method {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (f *fakeDiscordREST) currentUser(context.Context) (discordUser, error) {
+	return discordUser{ID: "BOT", Username: "term-llm", Bot: true}, nil
+}
+
+func (f *fakeDiscordREST) gatewayURL(context.Context) (string, error) {
+	return "", fmt.Errorf("no gateway in fake")
+}
+
+func (f *fakeDiscordREST) createMessage(_ context.Context, channelID string, msg discordMessageSend) (discordMessage, error) {
+	id := f.record(discordCall{method: "create", channelID: channelID, msg: msg})
+	return discordMessage{ID: id, ChannelID: channelID}, nil
+}
+
+func (f *fakeDiscordREST) editMessage(_ context.Context, channelID, messageID string, msg discordMessageSend) error {
+	f.record(discordCall{method: "edit", channelID: channelID, messageID: messageID, msg: msg})
+	return nil
+}
+
+func (f *fakeDiscordREST) startThread(_ context.Context, channelID, messageID, name string) (string, error) {
+	f.record(discordCall{method: "thread", channelID: channelID, messageID: messageID, msg: discordMessageSend{Content: name}})
+	if f.threadErr != nil {
+		return "", f.threadErr
+	}
+	return "T-" + messageID, nil
+}
+
+func (f *fakeDiscordREST) respondInteraction(_ context.Context, interactionID, _ string, resp discordInteractionResponse) error {
+	f.record(discordCall{method: "interaction", messageID: interactionID, resp: resp})
+	return nil
+}
+
+func (f *fakeDiscordREST) downloadAttachment(context.Context, string) ([]byte, error) {
+	return []byte("hello from a file\n"), nil
+}
+
+func (f *fakeDiscordREST) uploadFile(_ context.Context, channelID, filename string, _ []byte) error {
+	f.record(discordCall{method: "upload", channelID: channelID, msg: discordMessageSend{Content: filename}})
+	return nil
+}
+
+// fakeDiscordGateway delivers queued events to the dispatcher.
+type fakeDiscordGateway struct {
+	events chan discordGatewayPayload
+}
+
+func (g *fakeDiscordGateway) run(ctx context.Context, dispatch func(string, json.RawMessage)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev := <-g.events:
+			dispatch(ev.T, ev.D)
+		}
+	}
+}
+
+func newTestDiscordMgr(t *testing.T, rest *fakeDiscordREST, h *testutil.EngineHarness, cfg config.DiscordServeConfig) *discordSessionMgr {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if cfg.AllowedUserIDs == nil {
+		cfg.AllowedUserIDs = []string{"U1"}
+	}
+	mgr := newDiscordSessionMgr(ctx, rest, Settings{
+		MaxTurns: 5,
+		NewSession: func(context.Context) (*SessionRuntime, error) {
+			return &SessionRuntime{Engine: h.Engine, ProviderName: "mock", ModelName: "test"}, nil
+		},
+	}, "BOT", cfg)
+	mgr.editInterval = 5 * time.Millisecond
+	return mgr
+}
+
+func discordEvent(t *testing.T, eventType string, data any) discordGatewayPayload {
+	t.Helper()
+	raw, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return discordGatewayPayload{Op: discordOpDispatch, T: eventType, D: raw}
+}
+
+func TestDiscordMentionStartsThreadAndStreamsReply(t *testing.T) {
+	rest := &fakeDiscordREST{}
+	gateway := &fakeDiscordGateway{events: make(chan discordGatewayPayload, 4)}
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("Hello **there**")
+	h.Provider.AddTextResponse("Again")
+
+	p := NewDiscordPlatform(config.DiscordServeConfig{Token: "t", AllowedUserIDs: []string{"U1"}})
+	p.rest, p.gateway = rest, gateway
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Run(ctx, nil, Settings{
+			MaxTurns: 5,
+			NewSession: func(context.Context) (*SessionRuntime, error) {
+				return &SessionRuntime{Engine: h.Engine, ProviderName: "mock", ModelName: "test"}, nil
+			},
+		})
+	}()
+
+	gateway.events <- discordEvent(t, "MESSAGE_CREATE", discordMessage{
+		ID: "100", ChannelID: "C1", GuildID: "G1", Content: "<@BOT> say hi",
+		Author: discordUser{ID: "U1"}, Mentions: []discordUser{{ID: "BOT"}},
+	})
+
+	waitFor(t, "final edit", func() bool {
+		edits := rest.callsTo("edit")
+		return len(edits) > 0 && edits[len(edits)-1].msg.Content == "Hello **there**"
+	})
+	threads := rest.callsTo("thread")
+	if len(threads) != 1 || threads[0].channelID != "C1" || threads[0].messageID != "100" || threads[0].msg.Content != "say hi" {
+		t.Fatalf("thread calls = %#v, want one thread on message 100 named after it", threads)
+	}
+	creates := rest.callsTo("create")
+	if len(creates) != 1 || creates[0].channelID != "T-100" || creates[0].msg.Content != "⏳" {
+		t.Fatalf("create calls = %#v, want one placeholder in the thread", creates)
+	}
+	if parse := creates[0].msg.AllowedMentions.Parse; parse == nil || len(parse) != 0 {
+		t.Fatalf("allowed_mentions.parse = %#v, want empty list", parse)
+	}
+
+	// Follow-ups in the bot's thread need no mention.
+	gateway.events <- discordEvent(t, "MESSAGE_CREATE", discordMessage{
+		ID: "101", ChannelID: "T-100", GuildID: "G1", Content: "and again",
+		Author: discordUser{ID: "U1"},
+	})
+	waitFor(t, "follow-up reply", func() bool {
+		edits := rest.callsTo("edit")
+		return len(edits) > 0 && edits[len(edits)-1].msg.Content == "Again"
+	})
+	if got := len(rest.callsTo("thread")); got != 1 {
+		t.Fatalf("thread calls = %d, want 1", got)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestDiscordChannelScopeRepliesInChannel(t *testing.T) {
+	rest := &fakeDiscordREST{}
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("ok")
+	mgr := newTestDiscordMgr(t, rest, h, config.DiscordServeConfig{SessionScope: discordScopeChannel})
+
+	mgr.handleMessage(context.Background(), discordMessage{
+		ID: "100", ChannelID: "C1", GuildID: "G1", Content: "<@!BOT> hi", Author: discordUser{ID: "U1"},
+	})
+
+	if got := len(rest.callsTo("thread")); got != 0 {
+		t.Fatalf("thread calls = %d, want 0 in channel scope", got)
+	}
+	creates := rest.callsTo("create")
+	if len(creates) != 1 || creates[0].channelID != "C1" || creates[0].msg.Reference == nil || creates[0].msg.Reference.MessageID != "100" {
+		t.Fatalf("create calls = %#v, want a reply to message 100 in C1", creates)
+	}
+}
+
+func TestDiscordShouldHandle(t *testing.T) {
+	mgr := newTestDiscordMgr(t, &fakeDiscordREST{}, testutil.NewEngineHarness(), config.DiscordServeConfig{})
+	mgr.threads["T1"] = struct{}{}
+
+	user := discordUser{ID: "U1"}
+	tests := []struct {
+		name string
+		msg  discordMessage
+		want bool
+	}{
+		{"direct message", discordMessage{ChannelID: "D1", Author: user, Content: "hi"}, true},
+		{"mention", discordMessage{ChannelID: "C1", GuildID: "G1", Author: user, Mentions: []discordUser{{ID: "BOT"}}}, true},
+		{"reply message type", discordMessage{ChannelID: "D1", Author: user, Type: discordMessageTypeReply}, true},
+		{"own message", discordMessage{ChannelID: "D1", Author: discordUser{ID: "BOT"}}, false},
+		{"other bot", discordMessage{ChannelID: "D1", Author: discordUser{ID: "B2", Bot: true}}, false},
+		{"webhook", discordMessage{ChannelID: "D1", Author: user, WebhookID: "W1"}, false},
+		{"system message", discordMessage{ChannelID: "D1", Author: user, Type: 7}, false},
+		{"bot thread", discordMessage{ChannelID: "T1", GuildID: "G1", Author: user}, true},
+		{"channel chatter", discordMessage{ChannelID: "C1", GuildID: "G1", Author: user}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mgr.shouldHandle(tt.msg); got != tt.want {
+				t.Fatalf("shouldHandle = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscordAllowlist(t *testing.T) {
+	mgr := newTestDiscordMgr(t, &fakeDiscordREST{}, testutil.NewEngineHarness(), config.DiscordServeConfig{
+		AllowedUserIDs:    []string{"U1"},
+		AllowedRoleIDs:    []string{"R1"},
+		AllowedChannelIDs: []string{"C1"},
+	})
+	mgr.threads["T1"] = struct{}{}
+
+	if !mgr.isAllowed("U1", nil) {
+		t.Fatal("listed user should be allowed")
+	}
+	if !mgr.isAllowed("U2", &discordMember{Roles: []string{"R0", "R1"}}) {
+		t.Fatal("member with listed role should be allowed")
+	}
+	if mgr.isAllowed("U2", &discordMember{Roles: []string{"R0"}}) {
+		t.Fatal("member without listed role should be rejected")
+	}
+	if mgr.isApprover("U2") || !mgr.isApprover("U1") {
+		t.Fatal("approvers should default to allowed_user_ids")
+	}
+
+	for _, tt := range []struct {
+		msg  discordMessage
+		want bool
+	}{
+		{discordMessage{ChannelID: "C1", GuildID: "G1"}, true},
+		{discordMessage{ChannelID: "C2", GuildID: "G1"}, false},
+		{discordMessage{ChannelID: "T1", GuildID: "G1"}, true},
+		{discordMessage{ChannelID: "D1"}, true},
+	} {
+		if got := mgr.isAllowedChannel(tt.msg); got != tt.want {
+			t.Fatalf("isAllowedChannel(%s) = %v, want %v", tt.msg.ChannelID, got, tt.want)
+		}
+	}
+}
+
+func TestDiscordAttachmentInlinedAsText(t *testing.T) {
+	rest := &fakeDiscordREST{}
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("read it")
+	mgr := newTestDiscordMgr(t, rest, h, config.DiscordServeConfig{})
+
+	mgr.handleMessage(context.Background(), discordMessage{
+		ID: "100", ChannelID: "D1", Content: "summarise", Author: discordUser{ID: "U1"},
+		Attachments: []discordAttachment{{Filename: "notes.txt", ContentType: "text/plain; charset=utf-8", Size: 18, URL: "https://cdn.example/notes.txt"}},
+	})
+
+	calls := h.Provider.RecordedRequests()
+	if len(calls) == 0 {
+		t.Fatal("provider was not called")
+	}
+	last := calls[0].Messages[len(calls[0].Messages)-1]
+	if len(last.Parts) != 2 || !strings.Contains(last.Parts[0].Text, "hello from a file") || last.Parts[1].Text != "summarise" {
+		t.Fatalf("user message parts = %#v, want inlined file then text", last.Parts)
+	}
+}
+
+func startDiscordApproval(t *testing.T, mgr *discordSessionMgr, rest *fakeDiscordREST) (<-chan int, *discordPendingApproval) {
+	t.Helper()
+	sess := &discordSession{channelID: "T1"}
+	req := ApprovalRequest{
+		Title:  "Allow shell command?",
+		Target: "rm -rf build",
+		Options: []tools.ApprovalOption{
+			{Label: "Allow once", Choice: tools.ApprovalChoiceOnce},
+			{Label: "Deny", Choice: tools.ApprovalChoiceDeny},
+		},
+	}
+	result := make(chan int, 1)
+	go func() {
+		choice, err := mgr.requestApproval(sess, req)
+		if err != nil {
+			t.Errorf("requestApproval: %v", err)
+		}
+		result <- choice
+	}()
+
+	var pending *discordPendingApproval
+	waitFor(t, "approval prompt", func() bool {
+		mgr.approvalMu.Lock()
+		defer mgr.approvalMu.Unlock()
+		for _, p := range mgr.approvals {
+			pending = p
+		}
+		return pending != nil
+	})
+	creates := rest.callsTo("create")
+	if len(creates) != 1 {
+		t.Fatalf("create calls = %d, want 1", len(creates))
+	}
+	rows := creates[0].msg.Components
+	if len(rows) != 1 || len(rows[0].Components) != 2 {
+		t.Fatalf("components = %#v, want one row of two buttons", rows)
+	}
+	if rows[0].Components[0].Style != discordButtonPrimary || rows[0].Components[1].Style != discordButtonDanger {
+		t.Fatalf("button styles = %d, %d, want primary, danger", rows[0].Components[0].Style, rows[0].Components[1].Style)
+	}
+	if !strings.Contains(creates[0].msg.Content, "2️⃣ Deny") {
+		t.Fatalf("prompt = %q, want numbered options", creates[0].msg.Content)
+	}
+	return result, pending
+}
+
+func TestDiscordApprovalButtons(t *testing.T) {
+	rest := &fakeDiscordREST{}
+	mgr := newTestDiscordMgr(t, rest, testutil.NewEngineHarness(), config.DiscordServeConfig{})
+	result, pending := startDiscordApproval(t, mgr, rest)
+
+	click := func(user string) discordGatewayPayload {
+		return discordEvent(t, "INTERACTION_CREATE", map[string]any{
+			"id": "I-" + user, "token": "tok", "type": discordInteractionComponent,
+			"member": map[string]any{"user": map[string]any{"id": user}},
+			"data":   map[string]any{"custom_id": "approval:" + pending.id + ":1"},
+		})
+	}
+	ev := click("U9")
+	mgr.dispatch(ev.T, ev.D)
+	select {
+	case <-result:
+		t.Fatal("approval resolved by an unauthorised user")
+	case <-time.After(50 * time.Millisecond):
+	}
+	waitFor(t, "ephemeral rejection", func() bool {
+		resp := rest.callsTo("interaction")
+		return len(resp) == 1 && resp[0].resp.Type == discordCallbackChannelMessage && resp[0].resp.Data.Flags == discordFlagEphemeral
+	})
+
+	ev = click("U1")
+	mgr.dispatch(ev.T, ev.D)
+	select {
+	case choice := <-result:
+		if choice != 1 {
+			t.Fatalf("choice = %d, want 1", choice)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("approval was not resolved")
+	}
+	waitFor(t, "resolved prompt", func() bool {
+		edits := rest.callsTo("edit")
+		return len(edits) == 1 && strings.Contains(edits[0].msg.Content, "**Deny** by <@U1>") && edits[0].msg.Components == nil
+	})
+	waitFor(t, "deferred update", func() bool {
+		resp := rest.callsTo("interaction")
+		return len(resp) == 2 && resp[1].resp.Type == discordCallbackDeferredUpdate
+	})
+}
+
+func TestDiscordApprovalReaction(t *testing.T) {
+	rest := &fakeDiscordREST{}
+	mgr := newTestDiscordMgr(t, rest, testutil.NewEngineHarness(), config.DiscordServeConfig{})
+	result, pending := startDiscordApproval(t, mgr, rest)
+
+	react := func(user, emoji string) {
+		ev := discordEvent(t, "MESSAGE_REACTION_ADD", map[string]any{
+			"user_id": user, "message_id": pending.messageID, "emoji": map[string]any{"name": emoji},
+		})
+		mgr.dispatch(ev.T, ev.D)
+	}
+	react("U9", "1️⃣")
+	react("U1", "👍")
+	select {
+	case <-result:
+		t.Fatal("approval resolved by an unauthorised user or unrelated emoji")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	react("U1", "1️⃣")
+	select {
+	case choice := <-result:
+		if choice != 0 {
+			t.Fatalf("choice = %d, want 0", choice)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("approval was not resolved")
+	}
+}
+
+func TestDiscordResetCommand(t *testing.T) {
+	rest := &fakeDiscordREST{}
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("first")
+	mgr := newTestDiscordMgr(t, rest, h, config.DiscordServeConfig{})
+
+	mgr.handleMessage(context.Background(), discordMessage{ID: "1", ChannelID: "D1", Content: "hi", Author: discordUser{ID: "U1"}})
+	mgr.handleMessage(context.Background(), discordMessage{ID: "2", ChannelID: "D1", Content: "!reset", Author: discordUser{ID: "U1"}})
+
+	mgr.mu.Lock()
+	_, ok := mgr.sessions["D1"]
+	mgr.mu.Unlock()
+	if ok {
+		t.Fatal("session should be removed after !reset")
+	}
+	creates := rest.callsTo("create")
+	if last := creates[len(creates)-1].msg.Content; last != "Conversation cleared." {
+		t.Fatalf("last message = %q, want reset confirmation", last)
+	}
+}
+
+func TestDiscordReplyWriterSplitsLongReplies(t *testing.T) {
+	rest := &fakeDiscordREST{}
+	w := &discordReplyWriter{rest: rest, channelID: "C1", editInterval: time.Millisecond}
+	if err := w.start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	line := strings.Repeat("x", 99) + "\n"
+	w.appendText(strings.Repeat(line, 30))
+	if err := w.flush(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(rest.callsTo("create")); got != 2 {
+		t.Fatalf("create calls = %d, want placeholder plus one continuation", got)
+	}
+	for _, c := range rest.callsTo("edit") {
+		if n := len([]rune(c.msg.Content)); n > 2000 {
+			t.Fatalf("edit of %d runes exceeds Discord's limit", n)
+		}
+	}
+}
+
+func TestDiscordGatewayClientIdentifiesAndDispatches(t *testing.T) {
+	identified := make(chan map[string]any, 1)
+	var heartbeats atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.WriteJSON(map[string]any{"op": discordOpHello, "d": map[string]any{"heartbeat_interval": 20}})
+		for {
+			var frame struct {
+				Op int             `json:"op"`
+				D  json.RawMessage `json:"d"`
+			}
+			if err := conn.ReadJSON(&frame); err != nil {
+				return
+			}
+			switch frame.Op {
+			case discordOpIdentify:
+				var d map[string]any
+				_ = json.Unmarshal(frame.D, &d)
+				identified <- d
+				_ = conn.WriteJSON(map[string]any{"op": discordOpDispatch, "s": 1, "t": "READY",
+					"d": map[string]any{"session_id": "S1", "resume_gateway_url": "ws://unused"}})
+				_ = conn.WriteJSON(map[string]any{"op": discordOpDispatch, "s": 2, "t": "MESSAGE_CREATE",
+					"d": map[string]any{"id": "9", "content": "hi"}})
+			case discordOpHeartbeat:
+				heartbeats.Add(1)
+				_ = conn.WriteJSON(map[string]any{"op": discordOpHeartbeatACK})
+			}
+		}
+	}))
+	defer server.Close()
+
+	rest := &fakeDiscordREST{}
+	g := newDiscordGatewayClient(rest, "tok")
+	g.baseURL = "ws" + strings.TrimPrefix(server.URL, "http")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan string, 4)
+	done := make(chan error, 1)
+	go func() {
+		done <- g.run(ctx, func(eventType string, data json.RawMessage) {
+			events <- eventType + " " + string(data)
+		})
+	}()
+
+	select {
+	case d := <-identified:
+		if d["token"] != "tok" || int(d["intents"].(float64)) != discordIntents {
+			t.Fatalf("identify payload = %#v", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no identify received")
+	}
+	for _, want := range []string{"READY", "MESSAGE_CREATE"} {
+		select {
+		case got := <-events:
+			if !strings.HasPrefix(got, want+" ") {
+				t.Fatalf("event = %q, want %s", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s event", want)
+		}
+	}
+	waitFor(t, "heartbeats", func() bool { return heartbeats.Load() >= 2 })
+	if g.sessionID != "S1" || g.seq.Load() != 2 {
+		t.Fatalf("session = %q seq = %d, want S1 and 2", g.sessionID, g.seq.Load())
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return after cancel")
+	}
+}
+
+func TestDiscordRESTClientRetriesRateLimit(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot tok" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"content":"hi"`) {
+			t.Errorf("body = %s", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = io.WriteString(w, `{"message":"You are being rate limited.","retry_after":0.01,"global":false}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"id":"M1","channel_id":"C1"}`)
+	}))
+	defer server.Close()
+
+	c := newDiscordRESTClient(server.URL, "tok")
+	msg, err := c.createMessage(context.Background(), "C1", discordMessageSend{Content: "hi"})
+	if err != nil {
+		t.Fatalf("createMessage: %v", err)
+	}
+	if msg.ID != "M1" || attempts.Load() != 2 {
+		t.Fatalf("id = %q attempts = %d, want M1 after 2 attempts", msg.ID, attempts.Load())
+	}
+
+	c = newDiscordRESTClient(server.URL+"/missing", "tok")
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"message":"Unknown Channel","code":10003}`)
+	})
+	_, err = c.createMessage(context.Background(), "C1", discordMessageSend{Content: "hi"})
+	var apiErr *discordAPIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 10003 || apiErr.Status != http.StatusNotFound {
+		t.Fatalf("err = %v, want discordAPIError 10003", err)
+	}
+}
+
+func TestDiscordPlatformNeedsSetup(t *testing.T) {
+	if !NewDiscordPlatform(config.DiscordServeConfig{}).NeedsSetup() {
+		t.Fatal("empty token should need setup")
+	}
+	if NewDiscordPlatform(config.DiscordServeConfig{Token: "t"}).NeedsSetup() {
+		t.Fatal("configured token should not need setup")
+	}
+}
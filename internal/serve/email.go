@@ -0,0 +1,651 @@
+package serve
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/agents"
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/llm"
+	runpkg "github.com/samsaffron/term-llm/internal/run"
+	"github.com/samsaffron/term-llm/internal/session"
+	"github.com/samsaffron/term-llm/internal/tools"
+)
+
+const (
+	emailMaxConcurrentHandlers = 4
+	emailSendTimeout           = 2 * time.Minute
+	// emailMaxReferences bounds the References header on long threads; the
+	// root and the most recent messages are kept.
+	emailMaxReferences = 20
+)
+
+// EmailPlatform implements Platform for email: messages arrive over IMAP or
+// LMTP and replies go out over SMTP.
+type EmailPlatform struct {
+	cfg config.EmailServeConfig
+
+	// inbox and sender override the network transports in tests.
+	inbox  emailInbox
+	sender emailSender
+}
+
+// NewEmailPlatform creates a new EmailPlatform with the given config.
+func NewEmailPlatform(cfg config.EmailServeConfig) *EmailPlatform {
+	return &EmailPlatform{cfg: cfg}
+}
+
+func (p *EmailPlatform) Name() string { return "email" }
+
+// NeedsSetup returns true when the bot address, the outbound relay or an
+// inbound transport is missing.
+func (p *EmailPlatform) NeedsSetup() bool {
+	return strings.TrimSpace(p.cfg.Address) == "" ||
+		strings.TrimSpace(p.cfg.SMTPAddr) == "" ||
+		(strings.TrimSpace(p.cfg.IMAPAddr) == "" && strings.TrimSpace(p.cfg.LMTPListen) == "")
+}
+
+// RunSetup runs an interactive wizard that collects and persists mailbox
+// credentials.
+func (p *EmailPlatform) RunSetup() error {
+	scanner := bufio.NewScanner(os.Stdin)
+	prompt := func(label string) (string, error) {
+		fmt.Print(label)
+		if !scanner.Scan() {
+			return "", fmt.Errorf("no input received")
+		}
+		return strings.TrimSpace(scanner.Text()), nil
+	}
+
+	fmt.Println()
+	fmt.Println("Email Setup")
+	fmt.Println("===========")
+	fmt.Println()
+	fmt.Println("Use a dedicated mailbox: the bot answers every unread message in it.")
+	fmt.Println()
+	address, err := prompt("Bot email address: ")
+	if err != nil {
+		return err
+	}
+	if _, err := mail.ParseAddress(address); err != nil {
+		return fmt.Errorf("invalid address %q: %w", address, err)
+	}
+
+	newCfg := p.cfg
+	newCfg.Address = address
+	fmt.Println()
+	fmt.Println("1. Incoming mail (IMAP, implicit TLS)")
+	if newCfg.IMAPAddr, err = prompt("   IMAP server host:port (e.g. imap.example.com:993): "); err != nil {
+		return err
+	}
+	if newCfg.IMAPAddr == "" {
+		return fmt.Errorf("IMAP server cannot be empty (configure serve.email.lmtp_listen manually to use LMTP)")
+	}
+	if newCfg.IMAPUsername, err = prompt("   Username [" + address + "]: "); err != nil {
+		return err
+	}
+	if newCfg.IMAPUsername == "" {
+		newCfg.IMAPUsername = address
+	}
+	if newCfg.IMAPPassword, err = prompt("   Password: "); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("2. Outgoing mail (SMTP with STARTTLS; the IMAP credentials are reused)")
+	if newCfg.SMTPAddr, err = prompt("   SMTP server host:port (e.g. smtp.example.com:587): "); err != nil {
+		return err
+	}
+	if newCfg.SMTPAddr == "" {
+		return fmt.Errorf("SMTP server cannot be empty")
+	}
+
+	fmt.Println()
+	fmt.Println("3. Allowed senders: addresses, or @domain for everyone at a domain.")
+	raw, err := prompt("   Allowed senders (comma-separated): ")
+	if err != nil {
+		return err
+	}
+	newCfg.AllowedSenders = nil
+	for _, part := range strings.Split(raw, ",") {
+		if s := strings.TrimSpace(part); s != "" {
+			newCfg.AllowedSenders = append(newCfg.AllowedSenders, s)
+		}
+	}
+	if len(newCfg.AllowedSenders) == 0 {
+		return fmt.Errorf("at least one allowed sender is required")
+	}
+
+	if err := config.SetServeEmailConfig(newCfg); err != nil {
+		return fmt.Errorf("save email config: %w", err)
+	}
+	p.cfg = newCfg
+	fmt.Println()
+	fmt.Println("Email configuration saved.")
+	return nil
+}
+
+// Run receives mail until ctx is cancelled.
+func (p *EmailPlatform) Run(ctx context.Context, cfg *config.Config, settings Settings) error {
+	from, err := mail.ParseAddress(strings.TrimSpace(p.cfg.Address))
+	if err != nil {
+		return fmt.Errorf("email address is not configured or invalid; run with --setup to configure")
+	}
+	if p.cfg.FromName != "" {
+		from.Name = p.cfg.FromName
+	}
+	if len(p.cfg.AllowedSenders) == 0 {
+		log.Println("[email] warning: no allowed_senders configured; all messages will be ignored")
+	}
+	for _, security := range []string{p.cfg.IMAPSecurity, p.cfg.SMTPSecurity} {
+		switch security {
+		case "", emailSecurityTLS, emailSecurityStartTLS, emailSecurityNone:
+		default:
+			return fmt.Errorf("invalid email security %q (valid: tls, starttls, none)", security)
+		}
+	}
+
+	inbox := p.inbox
+	if inbox == nil {
+		if listen := strings.TrimSpace(p.cfg.LMTPListen); listen != "" {
+			inbox = &lmtpListener{addr: listen, hostname: emailDomain(from.Address)}
+		} else {
+			if strings.TrimSpace(p.cfg.IMAPAddr) == "" {
+				return fmt.Errorf("email needs imap_addr or lmtp_listen; run with --setup to configure")
+			}
+			interval := time.Duration(p.cfg.PollInterval) * time.Second
+			if interval <= 0 {
+				interval = time.Minute
+			}
+			inbox = &imapPoller{
+				addr:     p.cfg.IMAPAddr,
+				username: p.cfg.IMAPUsername,
+				password: p.cfg.IMAPPassword,
+				mailbox:  p.cfg.IMAPMailbox,
+				security: p.cfg.IMAPSecurity,
+				interval: interval,
+			}
+		}
+	}
+	sender := p.sender
+	if sender == nil {
+		if strings.TrimSpace(p.cfg.SMTPAddr) == "" {
+			return fmt.Errorf("email smtp_addr is not configured; run with --setup to configure")
+		}
+		username, password := p.cfg.SMTPUsername, p.cfg.SMTPPassword
+		if username == "" {
+			username, password = p.cfg.IMAPUsername, p.cfg.IMAPPassword
+		}
+		sender = &smtpSender{addr: p.cfg.SMTPAddr, username: username, password: password, security: p.cfg.SMTPSecurity}
+	}
+
+	mgr := newEmailMgr(ctx, sender, settings, *from, p.cfg)
+	if settings.IdleTimeout > 0 {
+		mgr.idleTimeout = settings.IdleTimeout
+	} else if p.cfg.IdleTimeout > 0 {
+		mgr.idleTimeout = time.Duration(p.cfg.IdleTimeout) * time.Minute
+	}
+	go mgr.reapIdleThreads(ctx)
+	defer mgr.wait()
+
+	log.Printf("[email] answering mail to %s", from.Address)
+	return inbox.run(ctx, mgr.deliver)
+}
+
+// emailThread is one conversation, keyed by the Message-ID of the mail that
+// started it.
+type emailThread struct {
+	mu      sync.Mutex // serialises turns in the thread
+	key     string
+	agent   string
+	meta    *session.Session
+	history []llm.Message
+
+	lastActivity time.Time // guarded by emailMgr.mu
+}
+
+// emailMgr maps inbound mail to threads and answers it.
+type emailMgr struct {
+	ctx      context.Context
+	sender   emailSender
+	settings Settings
+	store    session.Store
+	from     mail.Address
+
+	idleTimeout  time.Duration
+	allowed      []string
+	senderAgents []config.EmailSenderAgent
+	slots        chan struct{}
+	wg           sync.WaitGroup
+
+	mu      sync.Mutex
+	threads map[string]*emailThread
+	aliases map[string]string // Message-ID -> thread key, for every message seen or sent
+}
+
+func newEmailMgr(ctx context.Context, sender emailSender, settings Settings, from mail.Address, cfg config.EmailServeConfig) *emailMgr {
+	return &emailMgr{
+		ctx:          ctx,
+		sender:       sender,
+		settings:     settings,
+		store:        settings.Store,
+		from:         from,
+		idleTimeout:  30 * time.Minute,
+		allowed:      cfg.AllowedSenders,
+		senderAgents: cfg.SenderAgents,
+		slots:        make(chan struct{}, emailMaxConcurrentHandlers),
+		threads:      make(map[string]*emailThread),
+		aliases:      make(map[string]string),
+	}
+}
+
+// emailAddressMatches reports whether addr matches pattern: "*", a full
+// address, or "@domain".
+func emailAddressMatches(addr, pattern string) bool {
+	addr = strings.ToLower(strings.TrimSpace(addr))
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	switch {
+	case pattern == "":
+		return false
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "@"):
+		return strings.HasSuffix(addr, pattern) && strings.Count(addr, "@") == 1
+	}
+	return addr == pattern
+}
+
+func emailDomain(addr string) string {
+	_, domain, _ := strings.Cut(addr, "@")
+	return domain
+}
+
+func (m *emailMgr) isAllowed(addr string) bool {
+	for _, pattern := range m.allowed {
+		if emailAddressMatches(addr, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// agentFor returns the agent configured for a sender. Exact addresses win
+// over domains; the serve agent is the fallback.
+func (m *emailMgr) agentFor(addr string) string {
+	best, bestExact := "", false
+	for _, route := range m.senderAgents {
+		if !emailAddressMatches(addr, route.Sender) {
+			continue
+		}
+		exact := !strings.HasPrefix(strings.TrimSpace(route.Sender), "@") && route.Sender != "*"
+		if best == "" || (exact && !bestExact) {
+			best, bestExact = route.Agent, exact
+		}
+	}
+	if best == "" {
+		return m.settings.Agent
+	}
+	return best
+}
+
+// deliver accepts one raw message from the inbox. It only fails when the
+// adapter is shutting down, so LMTP deliveries are retried by the MTA.
+// Messages that are ignored or cannot be parsed are logged and dropped.
+func (m *emailMgr) deliver(ctx context.Context, raw []byte) error {
+	if m.ctx.Err() != nil {
+		return fmt.Errorf("shutting down")
+	}
+	msg, err := parseEmail(raw)
+	if err != nil {
+		log.Printf("[email] dropping unparseable message: %v", err)
+		return nil
+	}
+	sender := msg.sender()
+	switch {
+	case msg.AutoGenerated:
+		log.Printf("[email] ignoring automated message %s from %s", msg.MessageID, msg.From.Address)
+		return nil
+	case strings.EqualFold(msg.From.Address, m.from.Address) || strings.EqualFold(sender.Address, m.from.Address):
+		return nil
+	case !m.isAllowed(msg.From.Address):
+		log.Printf("[email] ignoring message from unauthorised sender %s", msg.From.Address)
+		return nil
+	}
+
+	key, duplicate := m.threadKey(msg)
+	if duplicate {
+		return nil
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		select {
+		case m.slots <- struct{}{}:
+		case <-m.ctx.Done():
+			return
+		}
+		defer func() { <-m.slots }()
+		if err := m.handle(m.ctx, key, msg); err != nil {
+			log.Printf("[email] reply to %s failed: %v", msg.From.Address, err)
+		}
+	}()
+	return nil
+}
+
+// threadKey finds the conversation msg belongs to through its References and
+// In-Reply-To headers, falling back to the root of its reference chain. It
+// reports duplicates of a message already handled.
+func (m *emailMgr) threadKey(msg *emailMessage) (key string, duplicate bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if msg.MessageID != "" {
+		if _, seen := m.aliases[msg.MessageID]; seen {
+			return "", true
+		}
+	}
+	candidates := append(append([]string(nil), msg.References...), msg.InReplyTo)
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if k, ok := m.aliases[candidates[i]]; ok {
+			key = k
+			break
+		}
+	}
+	if key == "" {
+		switch {
+		case len(msg.References) > 0:
+			key = msg.References[0]
+		case msg.InReplyTo != "":
+			key = msg.InReplyTo
+		case msg.MessageID != "":
+			key = msg.MessageID
+		default:
+			key = newEmailMessageID(m.from.Address)
+		}
+	}
+	if msg.MessageID != "" {
+		m.aliases[msg.MessageID] = key
+	}
+	return key, false
+}
+
+func (m *emailMgr) handle(ctx context.Context, key string, msg *emailMessage) error {
+	thread, err := m.thread(ctx, key, m.agentFor(msg.From.Address))
+	if err != nil {
+		return err
+	}
+	thread.mu.Lock()
+	defer thread.mu.Unlock()
+	m.touch(thread)
+	defer m.touch(thread)
+
+	text := msg.Text
+	if len(thread.history) > 0 {
+		text = stripQuotedReply(text)
+	} else if msg.Subject != "" {
+		text = "Subject: " + msg.Subject + "\n\n" + text
+	}
+	attachments := make([]chatAttachment, len(msg.Attachments))
+	for i, a := range msg.Attachments {
+		attachments[i] = chatAttachment{Name: a.Filename, MimeType: a.ContentType, Size: int64(len(a.Data)), URL: strconv.Itoa(i)}
+	}
+	userMsg, cleanupFiles, err := buildAttachmentMessage(ctx, strings.TrimSpace(text), attachments, "email-upload", func(_ context.Context, ref string) ([]byte, error) {
+		i, _ := strconv.Atoi(ref)
+		return msg.Attachments[i].Data, nil
+	})
+	defer cleanupFiles()
+	if err != nil {
+		return m.reply(ctx, thread, msg, "⚠️ "+err.Error(), nil)
+	}
+	if len(userMsg.Parts) == 0 {
+		return nil
+	}
+
+	settings := m.settings
+	runtime, err := m.newRuntime(ctx, thread.agent, &settings)
+	if err != nil {
+		return m.reply(ctx, thread, msg, "⚠️ Could not start the agent: "+err.Error(), nil)
+	}
+	defer func() {
+		if runtime.Cleanup != nil {
+			runtime.Cleanup()
+		}
+	}()
+	var denied []string
+	if runtime.SetApprovalHandler != nil {
+		// Nobody can answer a prompt mid-email, so tool calls that need
+		// approval are denied and listed in the reply.
+		runtime.SetApprovalHandler(func(req ApprovalRequest) (int, error) {
+			denied = append(denied, strings.TrimSpace(req.Title+": "+req.Target))
+			for i, opt := range req.Options {
+				if opt.Choice == tools.ApprovalChoiceDeny {
+					return i, nil
+				}
+			}
+			return -1, nil
+		})
+	}
+
+	turn, err := startChatTurn(ctx, runpkg.PlatformEmail, settings, runtime, thread.meta.ID, thread.history, userMsg)
+	if err != nil {
+		return m.reply(ctx, thread, msg, "⚠️ "+err.Error(), nil)
+	}
+	var (
+		body      strings.Builder
+		images    []string
+		streamErr error
+	)
+	for res := range turn.events(ctx) {
+		if res.err != nil {
+			if !errors.Is(res.err, io.EOF) {
+				streamErr = res.err
+			}
+			break
+		}
+		switch ev := res.ev; ev.Type {
+		case llm.EventTextDelta:
+			body.WriteString(ev.Text)
+		case llm.EventToolExecEnd:
+			images = append(images, ev.ToolImages...)
+		case llm.EventError:
+			if ev.Err != nil {
+				streamErr = ev.Err
+			}
+		}
+	}
+	turn.close()
+	if streamErr == nil && ctx.Err() != nil {
+		streamErr = ctx.Err()
+	}
+	thread.history = append(thread.history, turn.finish(ctx, streamErr, body.String())...)
+	if errors.Is(streamErr, context.Canceled) {
+		return nil // shutting down; the sender is not told about a half answer
+	}
+
+	reply := strings.TrimSpace(body.String())
+	if streamErr != nil {
+		reply = strings.TrimSpace(reply + "\n\n⚠️ " + streamErr.Error())
+	}
+	if reply == "" {
+		reply = "(no response)"
+	}
+	if len(denied) > 0 {
+		reply += "\n\n---\nThese tool calls needed approval and were denied:\n"
+		for _, d := range denied {
+			reply += "\n- `" + strings.ReplaceAll(d, "`", "'") + "`"
+		}
+	}
+
+	var files []emailAttachment
+	for _, path := range images {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[email] read tool image %s: %v", path, err)
+			continue
+		}
+		files = append(files, emailAttachment{Filename: filepath.Base(path), ContentType: mime.TypeByExtension(filepath.Ext(path)), Data: data})
+	}
+	return m.reply(ctx, thread, msg, reply, files)
+}
+
+// newRuntime returns the runtime for a turn. Threads for a sender-specific
+// agent run through the runner with no borrowed engine, so the runner builds
+// that agent's tools, prompt and provider; settings is adjusted to match.
+func (m *emailMgr) newRuntime(ctx context.Context, agent string, settings *Settings) (*SessionRuntime, error) {
+	if agent != m.settings.Agent && m.settings.Runner != nil {
+		settings.Agent = agent
+		settings.SystemPrompt = ""
+		settings.PlatformMessages = agents.PlatformMessagesConfig{}
+		return &SessionRuntime{}, nil
+	}
+	if m.settings.NewSession == nil {
+		return nil, fmt.Errorf("email runtime factory is not configured")
+	}
+	return m.settings.NewSession(ctx)
+}
+
+// reply sends text back to the sender, threaded under msg.
+func (m *emailMgr) reply(ctx context.Context, thread *emailThread, msg *emailMessage, text string, files []emailAttachment) error {
+	to := msg.sender()
+	refs := append(append([]string(nil), msg.References...), msg.MessageID)
+	if len(refs) > emailMaxReferences {
+		refs = append(refs[:1], refs[len(refs)-emailMaxReferences+1:]...)
+	}
+	out := emailReply{
+		From:        m.from,
+		To:          *to,
+		Subject:     emailReplySubject(msg.Subject),
+		MessageID:   newEmailMessageID(m.from.Address),
+		InReplyTo:   msg.MessageID,
+		References:  refs,
+		Markdown:    text,
+		Attachments: files,
+	}
+	raw, err := composeEmail(out)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.aliases[out.MessageID] = thread.key
+	m.mu.Unlock()
+
+	sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), emailSendTimeout)
+	defer cancel()
+	if err := m.sender.send(sendCtx, m.from.Address, []string{to.Address}, raw); err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	log.Printf("[email] replied to %s in thread %s", to.Address, thread.key)
+	return nil
+}
+
+// thread returns the in-memory thread for key, resuming it from the session
+// store or creating it.
+func (m *emailMgr) thread(ctx context.Context, key, agent string) (*emailThread, error) {
+	m.mu.Lock()
+	if t, ok := m.threads[key]; ok {
+		m.mu.Unlock()
+		return t, nil
+	}
+	m.mu.Unlock()
+
+	t := &emailThread{key: key, agent: agent, lastActivity: time.Now()}
+	name := "email:" + key
+	if m.store != nil {
+		if summaries, err := m.store.List(ctx, session.ListOptions{Name: name, Limit: 1}); err == nil && len(summaries) > 0 {
+			if meta, err := m.store.Get(ctx, summaries[0].ID); err == nil && meta != nil {
+				if stored, err := m.store.GetMessages(ctx, meta.ID, 0, 0); err == nil {
+					t.meta = meta
+					if meta.Agent != "" {
+						t.agent = meta.Agent
+					}
+					for _, msg := range stored {
+						t.history = append(t.history, msg.ToLLMMessage())
+					}
+					log.Printf("[email] resumed session %s (%d messages) for %s", meta.ID, len(t.history), name)
+				}
+			}
+		}
+	}
+	if t.meta == nil {
+		t.meta = &session.Session{
+			ID:        session.NewID(),
+			Name:      name,
+			Provider:  "email",
+			Model:     "unknown",
+			Mode:      session.ModeChat,
+			Origin:    session.OriginEmail,
+			Agent:     t.agent,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			Search:    m.settings.Search,
+			Tools:     m.settings.Tools,
+			MCP:       m.settings.MCP,
+			Status:    session.StatusActive,
+		}
+		if cwd, err := os.Getwd(); err == nil {
+			t.meta.CWD = cwd
+		}
+		if m.store != nil {
+			if err := m.store.Create(ctx, t.meta); err != nil {
+				log.Printf("[email] session Create failed for %s: %v", t.meta.ID, err)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, ok := m.threads[key]; ok {
+		return existing, nil
+	}
+	m.threads[key] = t
+	return t, nil
+}
+
+func (m *emailMgr) touch(t *emailThread) {
+	m.mu.Lock()
+	t.lastActivity = time.Now()
+	m.mu.Unlock()
+}
+
+// reapIdleThreads drops threads with no activity for idleTimeout from
+// memory. Their history stays in the store and is reloaded on the next mail.
+func (m *emailMgr) reapIdleThreads(ctx context.Context) {
+	interval := min(m.idleTimeout/2, time.Minute)
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.mu.Lock()
+		for key, t := range m.threads {
+			if time.Since(t.lastActivity) > m.idleTimeout && t.mu.TryLock() {
+				delete(m.threads, key)
+				t.mu.Unlock()
+			}
+		}
+		m.mu.Unlock()
+	}
+}
+
+// wait blocks until in-flight replies finish.
+func (m *emailMgr) wait() {
+	m.wg.Wait()
+}
@@ -0,0 +1,490 @@
+package serve
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// maxEmailMIMEDepth bounds nested multipart parsing.
+const maxEmailMIMEDepth = 10
+
+// emailMarkdown renders replies to HTML. Raw HTML in model output stays
+// escaped (goldmark's default).
+var emailMarkdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+var emailMessageIDPattern = regexp.MustCompile(`<[^<>\s]+>`)
+
+// emailMessage is an inbound message reduced to what the adapter uses.
+type emailMessage struct {
+	MessageID  string // without angle brackets
+	InReplyTo  string
+	References []string
+	Subject    string
+	From       *mail.Address
+	ReplyTo    *mail.Address
+	// AutoGenerated marks auto-replies, bounces and list traffic, which the
+	// bot never answers to avoid mail loops.
+	AutoGenerated bool
+	Text          string
+	Attachments   []emailAttachment
+}
+
+type emailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// sender is the address replies go to.
+func (m *emailMessage) sender() *mail.Address {
+	if m.ReplyTo != nil {
+		return m.ReplyTo
+	}
+	return m.From
+}
+
+var emailHeaderDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// parseEmail parses a raw RFC 5322 message.
+func parseEmail(raw []byte) (*emailMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("parse message: %w", err)
+	}
+	h := msg.Header
+	out := &emailMessage{
+		MessageID:  firstMessageID(h.Get("Message-Id")),
+		InReplyTo:  firstMessageID(h.Get("In-Reply-To")),
+		References: parseMessageIDs(h.Get("References")),
+	}
+	if subject, err := emailHeaderDecoder.DecodeHeader(h.Get("Subject")); err == nil {
+		out.Subject = strings.TrimSpace(subject)
+	} else {
+		out.Subject = strings.TrimSpace(h.Get("Subject"))
+	}
+	parser := &mail.AddressParser{WordDecoder: emailHeaderDecoder}
+	if from, err := parser.Parse(h.Get("From")); err == nil {
+		out.From = from
+	} else {
+		return nil, fmt.Errorf("parse From: %w", err)
+	}
+	if v := h.Get("Reply-To"); v != "" {
+		if replyTo, err := parser.Parse(v); err == nil {
+			out.ReplyTo = replyTo
+		}
+	}
+
+	autoSubmitted := strings.ToLower(strings.TrimSpace(h.Get("Auto-Submitted")))
+	precedence := strings.ToLower(strings.TrimSpace(h.Get("Precedence")))
+	out.AutoGenerated = (autoSubmitted != "" && autoSubmitted != "no") ||
+		precedence == "bulk" || precedence == "list" || precedence == "junk" ||
+		h.Get("List-Id") != "" || h.Get("X-Autoreply") != "" ||
+		strings.TrimSpace(h.Get("Return-Path")) == "<>"
+
+	var plain, htmlParts []string
+	if err := walkEmailPart(textproto.MIMEHeader(h), msg.Body, 0, &plain, &htmlParts, &out.Attachments); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(plain) > 0:
+		out.Text = strings.Join(plain, "\n\n")
+	case len(htmlParts) > 0:
+		out.Text = htmlToPlainText(strings.Join(htmlParts, "\n"))
+	}
+	out.Text = strings.TrimSpace(strings.ReplaceAll(out.Text, "\r\n", "\n"))
+	return out, nil
+}
+
+// walkEmailPart collects text bodies and attachments from one MIME part.
+// In multipart/alternative the plain-text version wins; HTML is only used
+// when a message has no plain text at all.
+func walkEmailPart(h textproto.MIMEHeader, body io.Reader, depth int, plain, htmlParts *[]string, attachments *[]emailAttachment) error {
+	if depth > maxEmailMIMEDepth {
+		return fmt.Errorf("message nesting too deep")
+	}
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || mediaType == "" {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		var altPlain, altHTML []string
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("read multipart: %w", err)
+			}
+			if mediaType == "multipart/alternative" {
+				err = walkEmailPart(part.Header, part, depth+1, &altPlain, &altHTML, attachments)
+			} else {
+				err = walkEmailPart(part.Header, part, depth+1, plain, htmlParts, attachments)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if len(altPlain) > 0 {
+			*plain = append(*plain, altPlain...)
+		} else {
+			*htmlParts = append(*htmlParts, altHTML...)
+		}
+		return nil
+	}
+
+	data, err := io.ReadAll(decodeTransferEncoding(h.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("decode %s part: %w", mediaType, err)
+	}
+
+	disposition, dispParams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	filename := dispParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	if decoded, err := emailHeaderDecoder.DecodeHeader(filename); err == nil {
+		filename = decoded
+	}
+	isBody := disposition != "attachment" && filename == "" &&
+		(mediaType == "text/plain" || mediaType == "text/html")
+	if !isBody {
+		if filename == "" {
+			filename = "attachment" + mimeExtension(mediaType)
+		}
+		*attachments = append(*attachments, emailAttachment{Filename: filename, ContentType: mediaType, Data: data})
+		return nil
+	}
+
+	text := decodeCharset(params["charset"], data)
+	if mediaType == "text/html" {
+		*htmlParts = append(*htmlParts, text)
+	} else {
+		*plain = append(*plain, text)
+	}
+	return nil
+}
+
+func decodeTransferEncoding(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// decodeCharset converts a text part to UTF-8, falling back to the raw bytes
+// with invalid sequences replaced.
+func decodeCharset(label string, data []byte) string {
+	label = strings.TrimSpace(label)
+	if label != "" && !strings.EqualFold(label, "utf-8") && !strings.EqualFold(label, "us-ascii") {
+		if r, err := charset.NewReaderLabel(label, bytes.NewReader(data)); err == nil {
+			if decoded, err := io.ReadAll(r); err == nil {
+				return string(decoded)
+			}
+		}
+	}
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	return strings.ToValidUTF8(string(data), "�")
+}
+
+// htmlToPlainText extracts readable text from an HTML body.
+func htmlToPlainText(src string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(src))
+	skip := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return collapseBlankLines(b.String())
+		case html.TextToken:
+			if skip > 0 {
+				break
+			}
+			text := string(z.Text())
+			words := strings.Join(strings.Fields(text), " ")
+			if words == "" {
+				if text != "" {
+					b.WriteByte(' ')
+				}
+				break
+			}
+			if strings.TrimLeftFunc(text, unicode.IsSpace) != text {
+				b.WriteByte(' ')
+			}
+			b.WriteString(words)
+			if strings.TrimRightFunc(text, unicode.IsSpace) != text {
+				b.WriteByte(' ')
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style", "head":
+				skip++
+			case "br":
+				b.WriteString("\n")
+			case "p", "div", "tr", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "pre", "table":
+				b.WriteString("\n\n")
+			case "li":
+				b.WriteString("\n- ")
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style", "head":
+				if skip > 0 {
+					skip--
+				}
+			case "p", "div", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "pre", "table", "ul", "ol":
+				b.WriteString("\n\n")
+			}
+		}
+	}
+}
+
+func collapseBlankLines(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+var (
+	emailQuoteHeaderPattern = regexp.MustCompile(`^(On\s.+\swrote:|Am\s.+\sschrieb\s.+:|Le\s.+\sa\sécrit\s?:)$`)
+	emailOutlookPattern     = regexp.MustCompile(`^-{2,}\s*Original Message\s*-{2,}$|^_{10,}$`)
+)
+
+// stripQuotedReply removes the quoted earlier conversation and signature
+// that mail clients append to replies. The session already holds that
+// history, so only the new text is sent to the model.
+func stripQuotedReply(text string) string {
+	lines := strings.Split(text, "\n")
+	cut := len(lines)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		joined := trimmed
+		if i+1 < len(lines) && strings.HasPrefix(trimmed, "On ") && !strings.HasSuffix(trimmed, ":") {
+			// Clients wrap long attribution lines.
+			joined = trimmed + " " + strings.TrimSpace(lines[i+1])
+		}
+		// "-- " is the conventional signature separator.
+		if trimmed == "--" || emailQuoteHeaderPattern.MatchString(joined) || emailOutlookPattern.MatchString(trimmed) {
+			cut = i
+			break
+		}
+	}
+	lines = lines[:cut]
+	for len(lines) > 0 {
+		last := strings.TrimSpace(lines[len(lines)-1])
+		if last == "" || strings.HasPrefix(last, ">") {
+			lines = lines[:len(lines)-1]
+			continue
+		}
+		break
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func firstMessageID(v string) string {
+	if ids := parseMessageIDs(v); len(ids) > 0 {
+		return ids[0]
+	}
+	return strings.Trim(strings.TrimSpace(v), "<>")
+}
+
+func parseMessageIDs(v string) []string {
+	matches := emailMessageIDPattern.FindAllString(v, -1)
+	ids := make([]string, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, strings.Trim(m, "<>"))
+	}
+	return ids
+}
+
+// emailReply is an outbound reply.
+type emailReply struct {
+	From        mail.Address
+	To          mail.Address
+	Subject     string
+	MessageID   string // without angle brackets
+	InReplyTo   string
+	References  []string
+	Markdown    string
+	Attachments []emailAttachment
+	Date        time.Time
+}
+
+// newEmailMessageID returns a unique Message-ID in the bot's domain.
+func newEmailMessageID(address string) string {
+	domain := "term-llm.invalid"
+	if _, d, ok := strings.Cut(address, "@"); ok && d != "" {
+		domain = d
+	}
+	return "term-llm." + strings.ToLower(rand.Text()) + "@" + domain
+}
+
+// emailReplySubject prefixes "Re: " unless the subject already has it.
+func emailReplySubject(subject string) string {
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return "Re: (no subject)"
+	}
+	if len(subject) >= 3 && strings.EqualFold(subject[:3], "re:") {
+		return subject
+	}
+	return "Re: " + subject
+}
+
+// composeEmail renders reply as a MIME message: a plain-text (Markdown) and
+// HTML alternative, followed by any attachments.
+func composeEmail(reply emailReply) ([]byte, error) {
+	var htmlBody bytes.Buffer
+	htmlBody.WriteString("<!DOCTYPE html>\n<html><body style=\"font-family: sans-serif; line-height: 1.5\">\n")
+	if err := emailMarkdown.Convert([]byte(reply.Markdown), &htmlBody); err != nil {
+		return nil, fmt.Errorf("render markdown: %w", err)
+	}
+	htmlBody.WriteString("</body></html>\n")
+
+	var buf bytes.Buffer
+	writeHeader := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	writeHeader("From", reply.From.String())
+	writeHeader("To", reply.To.String())
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", reply.Subject))
+	date := reply.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	writeHeader("Date", date.Format(time.RFC1123Z))
+	writeHeader("Message-ID", "<"+reply.MessageID+">")
+	if reply.InReplyTo != "" {
+		writeHeader("In-Reply-To", "<"+reply.InReplyTo+">")
+	}
+	if len(reply.References) > 0 {
+		refs := make([]string, len(reply.References))
+		for i, id := range reply.References {
+			refs[i] = "<" + id + ">"
+		}
+		writeHeader("References", strings.Join(refs, "\r\n "))
+	}
+	writeHeader("Auto-Submitted", "auto-replied")
+	writeHeader("MIME-Version", "1.0")
+
+	mixed := multipart.NewWriter(&buf)
+	var altBuf bytes.Buffer
+	alternative := multipart.NewWriter(&altBuf)
+	if err := writeQuotedPrintablePart(alternative, "text/plain; charset=utf-8", reply.Markdown); err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintablePart(alternative, "text/html; charset=utf-8", htmlBody.String()); err != nil {
+		return nil, err
+	}
+	if err := alternative.Close(); err != nil {
+		return nil, err
+	}
+
+	if len(reply.Attachments) == 0 {
+		writeHeader("Content-Type", "multipart/alternative; boundary="+alternative.Boundary())
+		buf.WriteString("\r\n")
+		buf.Write(altBuf.Bytes())
+		return buf.Bytes(), nil
+	}
+
+	writeHeader("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	buf.WriteString("\r\n")
+	altPart, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + alternative.Boundary()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := altPart.Write(altBuf.Bytes()); err != nil {
+		return nil, err
+	}
+	for _, a := range reply.Attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": a.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64Lines(part, a.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintablePart(w *multipart.Writer, contentType, body string) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64Lines writes data base64-encoded in 76-character lines.
+func writeBase64Lines(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := min(76, len(encoded))
+		if _, err := io.WriteString(w, encoded[:n]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
@@ -0,0 +1,667 @@
+package serve
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/testutil"
+)
+
+type fakeEmailInbox struct {
+	messages chan []byte
+}
+
+func (f *fakeEmailInbox) run(ctx context.Context, deliver func(context.Context, []byte) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case raw := <-f.messages:
+			if err := deliver(ctx, raw); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+type sentEmail struct {
+	from string
+	to   []string
+	msg  *emailMessage
+	raw  []byte
+}
+
+type fakeEmailSender struct {
+	mu   sync.Mutex
+	sent []sentEmail
+}
+
+func (f *fakeEmailSender) send(_ context.Context, from string, to []string, raw []byte) error {
+	msg, err := parseEmail(raw)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, sentEmail{from: from, to: to, msg: msg, raw: raw})
+	return nil
+}
+
+func (f *fakeEmailSender) all() []sentEmail {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]sentEmail(nil), f.sent...)
+}
+
+func rawEmail(headers map[string]string, body string) []byte {
+	var b strings.Builder
+	for _, k := range []string{"From", "To", "Subject", "Message-ID", "In-Reply-To", "References", "Auto-Submitted", "Content-Type", "Content-Transfer-Encoding"} {
+		if v, ok := headers[k]; ok {
+			b.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+func TestParseEmailMultipartWithAttachment(t *testing.T) {
+	raw := rawEmail(map[string]string{
+		"From":         "=?utf-8?q?Ana_L=C3=B3pez?= <ana@example.com>",
+		"Subject":      "=?utf-8?q?Caf=C3=A9_report?=",
+		"Message-ID":   "<m2@example.com>",
+		"In-Reply-To":  "<m1@example.com>",
+		"References":   "<m0@example.com> <m1@example.com>",
+		"Content-Type": `multipart/mixed; boundary="outer"`,
+	}, `--outer
+Content-Type: multipart/alternative; boundary="inner"
+
+--inner
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+Please summarise the caf=C3=A9 notes.
+--inner
+Content-Type: text/html; charset=utf-8
+
+<p>Please summarise the <b>café</b> notes.</p>
+--inner--
+--outer
+Content-Type: text/plain; name="notes.txt"
+Content-Disposition: attachment; filename="notes.txt"
+Content-Transfer-Encoding: base64
+
+aGVsbG8gZnJvbSBhIGZpbGU=
+--outer--
+`)
+	msg, err := parseEmail(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.From.Name != "Ana López" || msg.From.Address != "ana@example.com" {
+		t.Fatalf("From = %#v", msg.From)
+	}
+	if msg.Subject != "Café report" || msg.MessageID != "m2@example.com" || msg.InReplyTo != "m1@example.com" {
+		t.Fatalf("headers = %q %q %q", msg.Subject, msg.MessageID, msg.InReplyTo)
+	}
+	if strings.Join(msg.References, ",") != "m0@example.com,m1@example.com" {
+		t.Fatalf("References = %v", msg.References)
+	}
+	if msg.Text != "Please summarise the café notes." {
+		t.Fatalf("Text = %q, want the plain-text alternative", msg.Text)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Filename != "notes.txt" || string(msg.Attachments[0].Data) != "hello from a file" {
+		t.Fatalf("Attachments = %#v", msg.Attachments)
+	}
+	if msg.AutoGenerated {
+		t.Fatal("personal mail flagged as auto-generated")
+	}
+}
+
+func TestParseEmailHTMLOnlyLatin1(t *testing.T) {
+	raw := rawEmail(map[string]string{
+		"From":                      "bob@example.com",
+		"Content-Type":              "text/html; charset=iso-8859-1",
+		"Content-Transfer-Encoding": "quoted-printable",
+	}, "<div>Hola se=F1or,</div><div>  line two<br>line three</div><script>x()</script>")
+	msg, err := parseEmail(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hola señor,\n\nline two\nline three"; msg.Text != want {
+		t.Fatalf("Text = %q, want %q", msg.Text, want)
+	}
+}
+
+func TestParseEmailDetectsAutomatedMail(t *testing.T) {
+	for _, tc := range []struct {
+		header, value string
+	}{
+		{"Auto-Submitted", "auto-replied"},
+		{"Precedence", "bulk"},
+		{"List-Id", "<team.example.com>"},
+		{"X-Autoreply", "yes"},
+		{"Return-Path", "<>"},
+	} {
+		raw := []byte("From: a@example.com\r\n" + tc.header + ": " + tc.value + "\r\n\r\nhi\r\n")
+		msg, err := parseEmail(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !msg.AutoGenerated {
+			t.Errorf("%s: %s not detected as automated", tc.header, tc.value)
+		}
+	}
+	msg, err := parseEmail([]byte("From: a@example.com\r\nAuto-Submitted: no\r\n\r\nhi\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.AutoGenerated {
+		t.Error("Auto-Submitted: no flagged as automated")
+	}
+}
+
+func TestStripQuotedReply(t *testing.T) {
+	for name, tc := range map[string]struct{ in, want string }{
+		"gmail": {
+			in:   "Thanks, now do the rest.\n\nOn Tue, 3 Mar 2026 at 10:00, Bot <bot@example.com>\nwrote:\n> Earlier answer\n> more",
+			want: "Thanks, now do the rest.",
+		},
+		"outlook": {
+			in:   "Sounds good.\n\n-----Original Message-----\nFrom: Bot\nSent: today",
+			want: "Sounds good.",
+		},
+		"signature": {
+			in:   "Ship it.\n-- \nAna\nACME Corp",
+			want: "Ship it.",
+		},
+		"bottom quote": {
+			in:   "Looks right.\n> quoted\n> lines",
+			want: "Looks right.",
+		},
+		"inline quotes kept": {
+			in:   "> first point\nAgreed.\n> second point\nNo.",
+			want: "> first point\nAgreed.\n> second point\nNo.",
+		},
+	} {
+		if got := stripQuotedReply(tc.in); got != tc.want {
+			t.Errorf("%s: got %q, want %q", name, got, tc.want)
+		}
+	}
+}
+
+func TestComposeEmailRoundTrip(t *testing.T) {
+	raw, err := composeEmail(emailReply{
+		From:        mail.Address{Name: "Helper", Address: "bot@example.com"},
+		To:          mail.Address{Address: "ana@example.com"},
+		Subject:     emailReplySubject("Café report"),
+		MessageID:   "r1@example.com",
+		InReplyTo:   "m2@example.com",
+		References:  []string{"m1@example.com", "m2@example.com"},
+		Markdown:    "Here is **the** summary:\n\n- one\n- two",
+		Attachments: []emailAttachment{{Filename: "chart.png", ContentType: "image/png", Data: []byte{0x89, 'P', 'N', 'G'}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(raw)
+	for _, want := range []string{"Auto-Submitted: auto-replied", "In-Reply-To: <m2@example.com>", "multipart/alternative", "text/html"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("composed message lacks %q", want)
+		}
+	}
+
+	msg, err := parseEmail(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Subject != "Re: Café report" || msg.MessageID != "r1@example.com" || msg.InReplyTo != "m2@example.com" {
+		t.Fatalf("headers = %q %q %q", msg.Subject, msg.MessageID, msg.InReplyTo)
+	}
+	if strings.Join(msg.References, " ") != "m1@example.com m2@example.com" {
+		t.Fatalf("References = %v", msg.References)
+	}
+	if !msg.AutoGenerated {
+		t.Fatal("our own replies must be marked auto-replied")
+	}
+	if msg.Text != "Here is **the** summary:\n\n- one\n- two" {
+		t.Fatalf("plain text part = %q, want the Markdown source", msg.Text)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Filename != "chart.png" || string(msg.Attachments[0].Data) != "\x89PNG" {
+		t.Fatalf("Attachments = %#v", msg.Attachments)
+	}
+
+	// The HTML alternative carries the rendered Markdown.
+	htmlStart := strings.Index(s, "text/html")
+	if htmlStart < 0 || !strings.Contains(s[htmlStart:], "<strong>the</strong>") || !strings.Contains(s[htmlStart:], "<li>one</li>") {
+		t.Fatalf("HTML part does not contain rendered Markdown:\n%s", s)
+	}
+}
+
+func TestEmailSenderMatching(t *testing.T) {
+	mgr := newEmailMgr(context.Background(), &fakeEmailSender{}, Settings{Agent: "default"}, mail.Address{Address: "bot@example.com"}, config.EmailServeConfig{
+		AllowedSenders: []string{"ana@example.com", "@corp.example"},
+		SenderAgents: []config.EmailSenderAgent{
+			{Sender: "@corp.example", Agent: "support"},
+			{Sender: "CEO@corp.example", Agent: "exec"},
+		},
+	})
+	for addr, want := range map[string]bool{
+		"ana@example.com":        true,
+		"Ana@Example.com":        true,
+		"bob@example.com":        false,
+		"dev@corp.example":       true,
+		"dev@notcorp.example":    false,
+		"x@y@corp.example":       false,
+		"dev@corp.example.evil":  false,
+		"ana@example.com.attack": false,
+	} {
+		if got := mgr.isAllowed(addr); got != want {
+			t.Errorf("isAllowed(%q) = %v, want %v", addr, got, want)
+		}
+	}
+	for addr, want := range map[string]string{
+		"dev@corp.example": "support",
+		"ceo@corp.example": "exec",
+		"ana@example.com":  "default",
+	} {
+		if got := mgr.agentFor(addr); got != want {
+			t.Errorf("agentFor(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestEmailThreadKey(t *testing.T) {
+	mgr := newEmailMgr(context.Background(), &fakeEmailSender{}, Settings{}, mail.Address{Address: "bot@example.com"}, config.EmailServeConfig{})
+
+	key, dup := mgr.threadKey(&emailMessage{MessageID: "a@x"})
+	if dup || key != "a@x" {
+		t.Fatalf("new thread key = %q dup=%v, want a@x", key, dup)
+	}
+	if _, dup := mgr.threadKey(&emailMessage{MessageID: "a@x"}); !dup {
+		t.Fatal("redelivered message not detected as duplicate")
+	}
+	// Our reply's Message-ID is registered, so answering it joins the thread
+	// even when the client drops the References header.
+	mgr.aliases["reply@bot"] = "a@x"
+	if key, _ := mgr.threadKey(&emailMessage{MessageID: "b@x", InReplyTo: "reply@bot"}); key != "a@x" {
+		t.Fatalf("reply key = %q, want a@x", key)
+	}
+	// Unknown threads fall back to the root of the reference chain.
+	if key, _ := mgr.threadKey(&emailMessage{MessageID: "c@x", References: []string{"root@y", "mid@y"}, InReplyTo: "mid@y"}); key != "root@y" {
+		t.Fatalf("unknown reply key = %q, want root@y", key)
+	}
+}
+
+func newTestEmailPlatform(t *testing.T, h *testutil.EngineHarness, cfg config.EmailServeConfig) (*fakeEmailInbox, *fakeEmailSender, context.CancelFunc, <-chan error) {
+	t.Helper()
+	if cfg.Address == "" {
+		cfg.Address = "bot@example.com"
+	}
+	if cfg.AllowedSenders == nil {
+		cfg.AllowedSenders = []string{"@example.com"}
+	}
+	inbox := &fakeEmailInbox{messages: make(chan []byte, 4)}
+	sender := &fakeEmailSender{}
+	p := NewEmailPlatform(cfg)
+	p.inbox, p.sender = inbox, sender
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Run(ctx, nil, Settings{
+			MaxTurns: 5,
+			NewSession: func(context.Context) (*SessionRuntime, error) {
+				return &SessionRuntime{Engine: h.Engine, ProviderName: "mock", ModelName: "test"}, nil
+			},
+		})
+	}()
+	return inbox, sender, cancel, done
+}
+
+func TestEmailPlatformRepliesInThread(t *testing.T) {
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("Hello **Ana**")
+	h.Provider.AddTextResponse("Second answer")
+	inbox, sender, cancel, done := newTestEmailPlatform(t, h, config.EmailServeConfig{})
+
+	inbox.messages <- rawEmail(map[string]string{
+		"From":       "Ana <ana@example.com>",
+		"To":         "bot@example.com",
+		"Subject":    "Question",
+		"Message-ID": "<q1@example.com>",
+	}, "What is up?\n")
+	waitFor(t, "first reply", func() bool { return len(sender.all()) == 1 })
+
+	first := sender.all()[0]
+	if first.from != "bot@example.com" || len(first.to) != 1 || first.to[0] != "ana@example.com" {
+		t.Fatalf("envelope = %q -> %v", first.from, first.to)
+	}
+	if first.msg.Subject != "Re: Question" || first.msg.InReplyTo != "q1@example.com" || first.msg.Text != "Hello **Ana**" {
+		t.Fatalf("reply = %+v", first.msg)
+	}
+	if !strings.Contains(string(first.raw), "<strong>Ana</strong>") {
+		t.Fatal("reply lacks the rendered HTML alternative")
+	}
+	calls := h.Provider.RecordedRequests()
+	if len(calls) != 1 {
+		t.Fatalf("provider calls = %d, want 1", len(calls))
+	}
+	last := calls[0].Messages[len(calls[0].Messages)-1]
+	if len(last.Parts) != 1 || last.Parts[0].Text != "Subject: Question\n\nWhat is up?" {
+		t.Fatalf("first user message = %#v, want subject and body", last.Parts)
+	}
+
+	// The follow-up answers our reply and quotes it; the quote is dropped
+	// and the earlier turn is in the history.
+	inbox.messages <- rawEmail(map[string]string{
+		"From":        "ana@example.com",
+		"Subject":     "Re: Question",
+		"Message-ID":  "<q2@example.com>",
+		"In-Reply-To": "<" + first.msg.MessageID + ">",
+		"References":  "<q1@example.com> <" + first.msg.MessageID + ">",
+	}, "And then?\n\nOn Mon, Bot wrote:\n> Hello **Ana**\n")
+	waitFor(t, "second reply", func() bool { return len(sender.all()) == 2 })
+
+	second := sender.all()[1]
+	if second.msg.Text != "Second answer" || second.msg.InReplyTo != "q2@example.com" {
+		t.Fatalf("second reply = %+v", second.msg)
+	}
+	if refs := strings.Join(second.msg.References, " "); refs != "q1@example.com "+first.msg.MessageID+" q2@example.com" {
+		t.Fatalf("References = %q", refs)
+	}
+	calls = h.Provider.RecordedRequests()
+	msgs := calls[1].Messages
+	if got := msgs[len(msgs)-1].Parts[0].Text; got != "And then?" {
+		t.Fatalf("follow-up user text = %q, want quote stripped", got)
+	}
+	var sawFirst bool
+	for _, m := range msgs {
+		for _, p := range m.Parts {
+			if p.Text == "Hello **Ana**" {
+				sawFirst = true
+			}
+		}
+	}
+	if !sawFirst {
+		t.Fatal("follow-up request is missing the earlier assistant reply")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestEmailPlatformIgnoresUnwantedMail(t *testing.T) {
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("ok")
+	inbox, sender, _, _ := newTestEmailPlatform(t, h, config.EmailServeConfig{AllowedSenders: []string{"ana@example.com"}})
+
+	inbox.messages <- rawEmail(map[string]string{"From": "eve@example.com", "Message-ID": "<e1@x>"}, "hi")
+	inbox.messages <- rawEmail(map[string]string{"From": "ana@example.com", "Message-ID": "<v1@x>", "Auto-Submitted": "auto-replied"}, "I am on vacation")
+	inbox.messages <- rawEmail(map[string]string{"From": "bot@example.com", "Message-ID": "<b1@x>"}, "loop")
+	inbox.messages <- rawEmail(map[string]string{"From": "ana@example.com", "Message-ID": "<ok@x>"}, "real question")
+	waitFor(t, "reply to the allowed sender", func() bool { return len(sender.all()) == 1 })
+
+	if got := sender.all()[0].msg.InReplyTo; got != "ok@x" {
+		t.Fatalf("replied to %q, want only ok@x", got)
+	}
+	if calls := h.Provider.RecordedRequests(); len(calls) != 1 {
+		t.Fatalf("provider calls = %d, want 1", len(calls))
+	}
+}
+
+func TestEmailPlatformPassesAttachments(t *testing.T) {
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("read it")
+	inbox, sender, _, _ := newTestEmailPlatform(t, h, config.EmailServeConfig{})
+
+	inbox.messages <- rawEmail(map[string]string{
+		"From":         "ana@example.com",
+		"Message-ID":   "<a1@x>",
+		"Content-Type": `multipart/mixed; boundary="b"`,
+	}, "--b\nContent-Type: text/plain\n\nsummarise\n--b\nContent-Type: text/plain\nContent-Disposition: attachment; filename=notes.txt\n\nhello from a file\n--b--\n")
+	waitFor(t, "reply", func() bool { return len(sender.all()) == 1 })
+
+	calls := h.Provider.RecordedRequests()
+	last := calls[0].Messages[len(calls[0].Messages)-1]
+	if len(last.Parts) != 2 || !strings.Contains(last.Parts[0].Text, "hello from a file") || last.Parts[1].Text != "summarise" {
+		t.Fatalf("user message parts = %#v, want inlined file then text", last.Parts)
+	}
+}
+
+// fakeIMAPServer serves a single connection with just enough IMAP for
+// imapPoller.
+func fakeIMAPServer(t *testing.T, messages map[int]string) (addr string, stored <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	storedCh := make(chan string, len(messages))
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "* OK fake IMAP ready\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			tag, cmd, _ := strings.Cut(strings.TrimSpace(line), " ")
+			switch {
+			case strings.HasPrefix(cmd, "LOGIN "):
+				if cmd != `LOGIN "bot" "p\"w"` {
+					fmt.Fprintf(conn, "%s NO bad credentials\r\n", tag)
+					continue
+				}
+			case strings.HasPrefix(cmd, "SELECT "):
+				fmt.Fprintf(conn, "* %d EXISTS\r\n", len(messages))
+			case cmd == "UID SEARCH UNSEEN":
+				var uids []string
+				for uid := range messages {
+					uids = append(uids, fmt.Sprint(uid))
+				}
+				fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(uids, " "))
+			case strings.HasPrefix(cmd, "UID FETCH "):
+				var uid int
+				fmt.Sscanf(cmd, "UID FETCH %d", &uid)
+				body := messages[uid]
+				fmt.Fprintf(conn, "* 1 FETCH (UID %d BODY[] {%d}\r\n%s)\r\n", uid, len(body), body)
+			case strings.HasPrefix(cmd, "UID STORE "):
+				storedCh <- cmd
+			case cmd == "LOGOUT":
+				fmt.Fprintf(conn, "* BYE\r\n%s OK done\r\n", tag)
+				return
+			}
+			fmt.Fprintf(conn, "%s OK done\r\n", tag)
+		}
+	}()
+	return ln.Addr().String(), storedCh
+}
+
+func TestIMAPPollerFetchesUnseenMessages(t *testing.T) {
+	addr, stored := fakeIMAPServer(t, map[int]string{7: "From: ana@example.com\r\nSubject: hi\r\n\r\nbody with ) and {3}\r\n"})
+	p := &imapPoller{addr: addr, username: "bot", password: `p"w`, security: emailSecurityNone, interval: time.Hour}
+
+	var got []string
+	err := p.poll(context.Background(), func(_ context.Context, raw []byte) error {
+		got = append(got, string(raw))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !strings.Contains(got[0], "body with ) and {3}") {
+		t.Fatalf("delivered = %q", got)
+	}
+	select {
+	case cmd := <-stored:
+		if cmd != `UID STORE 7 +FLAGS.SILENT (\Seen)` {
+			t.Fatalf("store command = %q", cmd)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message was not marked seen")
+	}
+}
+
+func TestLMTPListenerDeliversPerRecipient(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu        sync.Mutex
+		delivered []string
+	)
+	l := &lmtpListener{listener: ln, hostname: "bot.example.com"}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- l.run(ctx, func(_ context.Context, raw []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			delivered = append(delivered, string(raw))
+			return nil
+		})
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	expect := func(code int) {
+		t.Helper()
+		if _, _, err := tp.ReadResponse(code); err != nil {
+			t.Fatalf("expected %d: %v", code, err)
+		}
+	}
+	expect(220)
+	tp.PrintfLine("LHLO mta.example.com")
+	expect(250)
+	tp.PrintfLine("MAIL FROM:<ana@example.com>")
+	expect(250)
+	tp.PrintfLine("RCPT TO:<bot@example.com>")
+	expect(250)
+	tp.PrintfLine("RCPT TO:<other@example.com>")
+	expect(250)
+	tp.PrintfLine("DATA")
+	expect(354)
+	w := tp.DotWriter()
+	fmt.Fprint(w, "From: ana@example.com\r\n\r\n.leading dot\r\n")
+	w.Close()
+	expect(250) // one status per recipient
+	expect(250)
+	tp.PrintfLine("QUIT")
+	expect(221)
+
+	mu.Lock()
+	if len(delivered) != 1 || !strings.Contains(delivered[0], "\n.leading dot") {
+		t.Fatalf("delivered = %q, want one message with dot-unstuffed body", delivered)
+	}
+	mu.Unlock()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not return after cancel")
+	}
+}
+
+func TestSMTPSenderRequiresStartTLSByDefault(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tp := textproto.NewConn(conn)
+				tp.PrintfLine("220 fake SMTP")
+				for {
+					line, err := tp.ReadLine()
+					if err != nil {
+						return
+					}
+					switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+					case "EHLO":
+						tp.PrintfLine("250-fake\r\n250 8BITMIME")
+					case "DATA":
+						tp.PrintfLine("354 go ahead")
+						body, _ := tp.ReadDotBytes()
+						received <- string(body)
+						tp.PrintfLine("250 queued")
+					case "QUIT":
+						tp.PrintfLine("221 bye")
+						return
+					default:
+						tp.PrintfLine("250 ok")
+					}
+				}
+			}()
+		}
+	}()
+
+	s := &smtpSender{addr: ln.Addr().String()}
+	if err := s.send(context.Background(), "bot@example.com", []string{"ana@example.com"}, []byte("Subject: x\r\n\r\nhi\r\n")); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("send without STARTTLS = %v, want refusal", err)
+	}
+
+	s.security = emailSecurityNone
+	if err := s.send(context.Background(), "bot@example.com", []string{"ana@example.com"}, []byte("Subject: x\r\n\r\nhi\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case body := <-received:
+		if !strings.Contains(body, "Subject: x") {
+			t.Fatalf("received = %q", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not received")
+	}
+}
+
+func TestEmailPlatformNeedsSetup(t *testing.T) {
+	if !NewEmailPlatform(config.EmailServeConfig{}).NeedsSetup() {
+		t.Fatal("empty config should need setup")
+	}
+	if !NewEmailPlatform(config.EmailServeConfig{Address: "bot@example.com", SMTPAddr: "smtp:587"}).NeedsSetup() {
+		t.Fatal("config without an inbound transport should need setup")
+	}
+	if NewEmailPlatform(config.EmailServeConfig{Address: "bot@example.com", SMTPAddr: "smtp:587", LMTPListen: "127.0.0.1:2424"}).NeedsSetup() {
+		t.Fatal("LMTP config should not need setup")
+	}
+}
@@ -0,0 +1,467 @@
+package serve
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxEmailMessageBytes caps a single inbound message.
+	maxEmailMessageBytes = 50 << 20
+
+	emailDialTimeout    = 30 * time.Second
+	emailCommandTimeout = 2 * time.Minute
+
+	emailSecurityTLS      = "tls"
+	emailSecurityStartTLS = "starttls"
+	emailSecurityNone     = "none"
+)
+
+// emailInbox delivers raw RFC 5322 messages to deliver until ctx is
+// cancelled. Implementations: IMAP polling and an LMTP listener.
+type emailInbox interface {
+	run(ctx context.Context, deliver func(ctx context.Context, raw []byte) error) error
+}
+
+// emailSender submits an outbound message.
+type emailSender interface {
+	send(ctx context.Context, from string, to []string, msg []byte) error
+}
+
+// ---------------------------------------------------------------------------
+// IMAP
+// ---------------------------------------------------------------------------
+
+// imapPoller polls a mailbox for unseen messages. Each poll opens a fresh
+// connection: mail volume is low and this avoids keeping idle sessions alive
+// through NAT and server timeouts.
+type imapPoller struct {
+	addr     string
+	username string
+	password string
+	mailbox  string
+	security string
+	interval time.Duration
+	tls      *tls.Config // optional override, used by tests
+}
+
+func (p *imapPoller) run(ctx context.Context, deliver func(context.Context, []byte) error) error {
+	for {
+		if err := p.poll(ctx, deliver); err != nil && ctx.Err() == nil {
+			log.Printf("[email] imap poll failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(p.interval):
+		}
+	}
+}
+
+// poll fetches every unseen message, hands it to deliver and marks it seen.
+// Messages are marked seen even when deliver fails so a message the adapter
+// cannot handle is not retried forever.
+func (p *imapPoller) poll(ctx context.Context, deliver func(context.Context, []byte) error) error {
+	c, err := dialIMAP(ctx, p.addr, p.security, p.tls)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	if _, err := c.cmd("LOGIN " + imapQuote(p.username) + " " + imapQuote(p.password)); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	mailbox := p.mailbox
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+	if _, err := c.cmd("SELECT " + imapQuote(mailbox)); err != nil {
+		return fmt.Errorf("select %s: %w", mailbox, err)
+	}
+	lines, err := c.cmd("UID SEARCH UNSEEN")
+	if err != nil {
+		return fmt.Errorf("search: %w", err)
+	}
+	var uids []uint32
+	for _, line := range lines {
+		if rest, ok := strings.CutPrefix(line.text, "* SEARCH"); ok {
+			for _, field := range strings.Fields(rest) {
+				if uid, err := strconv.ParseUint(field, 10, 32); err == nil {
+					uids = append(uids, uint32(uid))
+				}
+			}
+		}
+	}
+
+	for _, uid := range uids {
+		if ctx.Err() != nil {
+			break
+		}
+		lines, err := c.cmd(fmt.Sprintf("UID FETCH %d (UID BODY.PEEK[])", uid))
+		if err != nil {
+			return fmt.Errorf("fetch %d: %w", uid, err)
+		}
+		var raw []byte
+		for _, line := range lines {
+			if strings.Contains(line.text, "FETCH") && len(line.literals) > 0 {
+				raw = line.literals[0]
+				break
+			}
+		}
+		if raw != nil {
+			if err := deliver(ctx, raw); err != nil {
+				log.Printf("[email] message uid %d: %v", uid, err)
+			}
+		}
+		if _, err := c.cmd(fmt.Sprintf("UID STORE %d +FLAGS.SILENT (\\Seen)", uid)); err != nil {
+			return fmt.Errorf("mark %d seen: %w", uid, err)
+		}
+	}
+	_, _ = c.cmd("LOGOUT")
+	return nil
+}
+
+// imapConn is a minimal IMAP4rev1 client: enough to log in, search, fetch
+// and flag messages.
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+	stop func() bool // unregisters the ctx cancellation hook
+}
+
+// imapLine is one server response line with any literals it carried.
+type imapLine struct {
+	text     string
+	literals [][]byte
+}
+
+var imapLiteralPattern = regexp.MustCompile(`\{(\d+)\+?\}$`)
+
+func dialIMAP(ctx context.Context, addr, security string, tlsConfig *tls.Config) (*imapConn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("imap address %q: %w", addr, err)
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: host}
+	}
+	dialer := &net.Dialer{Timeout: emailDialTimeout}
+	var conn net.Conn
+	if security == "" || security == emailSecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("imap dial: %w", err)
+	}
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn)}
+	// Closing on cancellation interrupts a blocked read.
+	c.stop = context.AfterFunc(ctx, func() { conn.Close() })
+
+	greeting, err := c.readLine()
+	if err != nil {
+		c.close()
+		return nil, fmt.Errorf("imap greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting.text, "* OK") && !strings.HasPrefix(greeting.text, "* PREAUTH") {
+		c.close()
+		return nil, fmt.Errorf("imap greeting: %s", greeting.text)
+	}
+	if security == emailSecurityStartTLS {
+		if _, err := c.cmd("STARTTLS"); err != nil {
+			c.close()
+			return nil, fmt.Errorf("starttls: %w", err)
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			c.close()
+			return nil, fmt.Errorf("starttls handshake: %w", err)
+		}
+		c.conn, c.r = tlsConn, bufio.NewReader(tlsConn)
+	}
+	return c, nil
+}
+
+func (c *imapConn) close() error {
+	c.stop()
+	return c.conn.Close()
+}
+
+// cmd sends one command and reads responses up to its tagged completion.
+// It returns the untagged responses, or an error for NO/BAD.
+func (c *imapConn) cmd(command string) ([]imapLine, error) {
+	c.tag++
+	tag := fmt.Sprintf("T%d", c.tag)
+	c.conn.SetDeadline(time.Now().Add(emailCommandTimeout))
+	if _, err := io.WriteString(c.conn, tag+" "+command+"\r\n"); err != nil {
+		return nil, err
+	}
+	var lines []imapLine
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if status, ok := strings.CutPrefix(line.text, tag+" "); ok {
+			if strings.HasPrefix(status, "OK") {
+				return lines, nil
+			}
+			return nil, errors.New(status)
+		}
+		lines = append(lines, line)
+	}
+}
+
+// readLine reads a response line, following {n} literals until the line ends.
+func (c *imapConn) readLine() (imapLine, error) {
+	var out imapLine
+	var text strings.Builder
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return out, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		m := imapLiteralPattern.FindStringSubmatch(line)
+		if m == nil {
+			text.WriteString(line)
+			out.text = text.String()
+			return out, nil
+		}
+		size, err := strconv.Atoi(m[1])
+		if err != nil || size > maxEmailMessageBytes {
+			return out, fmt.Errorf("imap literal too large")
+		}
+		text.WriteString(line[:len(line)-len(m[0])])
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return out, err
+		}
+		out.literals = append(out.literals, literal)
+	}
+}
+
+// imapQuote renders s as an IMAP quoted string.
+func imapQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// ---------------------------------------------------------------------------
+// LMTP
+// ---------------------------------------------------------------------------
+
+// lmtpListener accepts mail over LMTP (RFC 2033) from a local MTA such as
+// Postfix or Dovecot.
+type lmtpListener struct {
+	addr     string
+	hostname string
+	listener net.Listener // optional, used by tests
+}
+
+func (l *lmtpListener) run(ctx context.Context, deliver func(context.Context, []byte) error) error {
+	ln := l.listener
+	if ln == nil {
+		network, address := "tcp", l.addr
+		if strings.HasPrefix(address, "/") {
+			network = "unix"
+		}
+		var err error
+		if ln, err = net.Listen(network, address); err != nil {
+			return fmt.Errorf("lmtp listen: %w", err)
+		}
+	}
+	log.Printf("[email] accepting LMTP on %s", ln.Addr())
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("lmtp accept: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			stopConn := context.AfterFunc(ctx, func() { conn.Close() })
+			defer stopConn()
+			l.serveConn(ctx, conn, deliver)
+		}()
+	}
+}
+
+func (l *lmtpListener) serveConn(ctx context.Context, conn net.Conn, deliver func(context.Context, []byte) error) {
+	hostname := l.hostname
+	if hostname == "" {
+		hostname = "term-llm"
+	}
+	tp := textproto.NewConn(conn)
+	reply := func(format string, args ...any) bool {
+		return tp.PrintfLine(format, args...) == nil
+	}
+	if !reply("220 %s LMTP ready", hostname) {
+		return
+	}
+	var rcpts []string
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "LHLO":
+			if !reply("250-%s\r\n250-8BITMIME\r\n250-ENHANCEDSTATUSCODES\r\n250 SIZE %d", hostname, maxEmailMessageBytes) {
+				return
+			}
+		case "MAIL":
+			rcpts = nil
+			reply("250 2.1.0 OK")
+		case "RCPT":
+			rcpts = append(rcpts, arg)
+			reply("250 2.1.5 OK")
+		case "DATA":
+			if len(rcpts) == 0 {
+				reply("503 5.5.1 No valid recipients")
+				continue
+			}
+			if !reply("354 Start mail input; end with <CRLF>.<CRLF>") {
+				return
+			}
+			data, err := io.ReadAll(io.LimitReader(tp.DotReader(), maxEmailMessageBytes+1))
+			if err != nil {
+				return
+			}
+			status := "250 2.0.0 OK"
+			switch {
+			case len(data) > maxEmailMessageBytes:
+				status = "552 5.3.4 Message too big"
+			default:
+				if err := deliver(ctx, data); err != nil {
+					log.Printf("[email] lmtp delivery: %v", err)
+					status = "451 4.3.0 " + strings.ReplaceAll(err.Error(), "\n", " ")
+				}
+			}
+			// LMTP answers DATA once per accepted recipient.
+			for range rcpts {
+				if !reply("%s", status) {
+					return
+				}
+			}
+			rcpts = nil
+		case "RSET":
+			rcpts = nil
+			reply("250 2.0.0 OK")
+		case "NOOP":
+			reply("250 2.0.0 OK")
+		case "QUIT":
+			reply("221 2.0.0 Bye")
+			return
+		default:
+			reply("500 5.5.2 Unrecognized command")
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// SMTP
+// ---------------------------------------------------------------------------
+
+// smtpSender submits mail to a relay, authenticating with PLAIN when a
+// username is configured.
+type smtpSender struct {
+	addr     string
+	username string
+	password string
+	security string
+	tls      *tls.Config // optional override, used by tests
+}
+
+func (s *smtpSender) send(ctx context.Context, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return fmt.Errorf("smtp address %q: %w", s.addr, err)
+	}
+	tlsConfig := s.tls
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: host}
+	}
+	dialer := &net.Dialer{Timeout: emailDialTimeout}
+	var conn net.Conn
+	if s.security == emailSecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", s.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("smtp dial: %w", err)
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(emailCommandTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("smtp greeting: %w", err)
+	}
+	defer c.Close()
+	if s.security == "" || s.security == emailSecurityStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server %s does not offer STARTTLS (set smtp_security: none to send in cleartext)", s.addr)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := io.Copy(w, bytes.NewReader(msg)); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	return c.Quit()
+}
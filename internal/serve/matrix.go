@@ -0,0 +1,1358 @@
+package serve
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samsaffron/term-llm/internal/agents"
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/llm"
+	runpkg "github.com/samsaffron/term-llm/internal/run"
+	"github.com/samsaffron/term-llm/internal/session"
+)
+
+const (
+	// matrixMaxMessageRunes keeps each event well under the 64 KiB event size
+	// limit once the HTML rendering is added alongside the plain body.
+	matrixMaxMessageRunes = 12000
+	// matrixMinEditInterval paces streaming edits; Synapse's default message
+	// rate limit allows short bursts and then roughly one event per second.
+	matrixMinEditInterval = 2 * time.Second
+
+	matrixMaxConcurrentHandlers = 16
+	matrixFinalDeliveryTimeout  = 30 * time.Second
+	matrixSyncTimeout           = 30 * time.Second
+	matrixSyncMaxBackoff        = 60 * time.Second
+
+	matrixScopeThread = "thread"
+	matrixScopeRoom   = "room"
+)
+
+// matrixApprovalEmoji are the keycap reactions offered as approval answers.
+var matrixApprovalEmoji = []string{"1️⃣", "2️⃣", "3️⃣", "4️⃣", "5️⃣", "6️⃣", "7️⃣", "8️⃣", "9️⃣"}
+
+// MatrixPlatform implements Platform for a Matrix bot account.
+type MatrixPlatform struct {
+	cfg config.MatrixServeConfig
+}
+
+// NewMatrixPlatform creates a new MatrixPlatform with the given config.
+func NewMatrixPlatform(cfg config.MatrixServeConfig) *MatrixPlatform {
+	return &MatrixPlatform{cfg: cfg}
+}
+
+func (p *MatrixPlatform) Name() string { return "matrix" }
+
+// NeedsSetup returns true when the homeserver or access token is missing.
+func (p *MatrixPlatform) NeedsSetup() bool {
+	return strings.TrimSpace(p.cfg.HomeserverURL) == "" || strings.TrimSpace(p.cfg.AccessToken) == ""
+}
+
+// RunSetup runs an interactive wizard that collects and persists the bot
+// account's credentials.
+func (p *MatrixPlatform) RunSetup() error {
+	scanner := bufio.NewScanner(os.Stdin)
+	prompt := func(label string) (string, error) {
+		fmt.Print(label)
+		if !scanner.Scan() {
+			return "", fmt.Errorf("no input received")
+		}
+		return strings.TrimSpace(scanner.Text()), nil
+	}
+
+	fmt.Println()
+	fmt.Println("Matrix Bot Setup")
+	fmt.Println("================")
+	fmt.Println()
+	fmt.Println("1. Register an account for the bot on your homeserver.")
+	homeserver, err := prompt("   Homeserver URL (e.g. https://matrix.example.org): ")
+	if err != nil {
+		return err
+	}
+	if homeserver == "" {
+		return fmt.Errorf("homeserver URL cannot be empty")
+	}
+
+	fmt.Println()
+	fmt.Println("2. Log in as the bot in Element and copy the token from")
+	fmt.Println("   Settings → Help & About → Access Token, then log out of that")
+	fmt.Println("   browser tab without signing the session out.")
+	token, err := prompt("   Access token: ")
+	if err != nil {
+		return err
+	}
+	if token == "" {
+		return fmt.Errorf("access token cannot be empty")
+	}
+
+	fmt.Println()
+	fmt.Println("3. Whitelist users by Matrix ID (@alice:example.org), or :example.org")
+	fmt.Println("   for everyone on a homeserver. Listed users can also approve tool calls.")
+	raw, err := prompt("   Allowed user IDs (comma-separated): ")
+	if err != nil {
+		return err
+	}
+	var users []string
+	for _, part := range strings.Split(raw, ",") {
+		if id := strings.TrimSpace(part); id != "" {
+			users = append(users, id)
+		}
+	}
+	if len(users) == 0 {
+		return fmt.Errorf("at least one user ID is required")
+	}
+
+	newCfg := p.cfg
+	newCfg.HomeserverURL = homeserver
+	newCfg.AccessToken = token
+	newCfg.AllowedUserIDs = users
+
+	if err := config.SetServeMatrixConfig(newCfg); err != nil {
+		return fmt.Errorf("save matrix config: %w", err)
+	}
+
+	p.cfg = newCfg
+	fmt.Println()
+	fmt.Println("Matrix configuration saved.")
+	return nil
+}
+
+// Run syncs with the homeserver and serves messages, blocking until ctx is
+// cancelled.
+func (p *MatrixPlatform) Run(ctx context.Context, cfg *config.Config, settings Settings) error {
+	if p.NeedsSetup() {
+		return fmt.Errorf("matrix homeserver_url or access_token is not configured; run with --setup to configure")
+	}
+	if len(p.cfg.AllowedUserIDs) == 0 {
+		log.Println("[matrix] warning: no allowed_user_ids configured; all messages will be rejected")
+	}
+	switch p.cfg.SessionScope {
+	case "", matrixScopeThread, matrixScopeRoom:
+	default:
+		return fmt.Errorf("invalid matrix session_scope %q (valid: thread, room)", p.cfg.SessionScope)
+	}
+
+	client := newMatrixClient(p.cfg.HomeserverURL, strings.TrimSpace(p.cfg.AccessToken))
+	userID, err := client.whoami(ctx)
+	if err != nil {
+		return fmt.Errorf("matrix connect: %w", err)
+	}
+	displayName, err := client.displayName(ctx, userID)
+	if err != nil {
+		log.Printf("[matrix] could not read display name: %v", err)
+	}
+	log.Printf("[matrix] logged in as %s", userID)
+
+	idleTimeout := settings.IdleTimeout
+	if idleTimeout <= 0 {
+		if p.cfg.IdleTimeout > 0 {
+			idleTimeout = time.Duration(p.cfg.IdleTimeout) * time.Minute
+		} else {
+			idleTimeout = 30 * time.Minute
+		}
+	}
+
+	mgr := newMatrixSessionMgr(ctx, client, settings, userID, displayName, p.cfg)
+	mgr.idleTimeout = idleTimeout
+	go mgr.reapIdleSessions(ctx)
+	defer mgr.closeAllSessions()
+
+	return mgr.syncLoop(ctx)
+}
+
+// matrixConversation identifies a session: a room, or a thread in it.
+type matrixConversation struct {
+	roomID string
+	thread string // thread root event ID; empty for the room itself
+}
+
+func (c matrixConversation) key() string {
+	if c.thread == "" {
+		return c.roomID
+	}
+	return c.roomID + "/" + c.thread
+}
+
+// matrixSession holds one room's or thread's conversation state.
+type matrixSession struct {
+	mu       sync.Mutex // held for a whole turn so messages run in order
+	conv     matrixConversation
+	agent    string
+	settings Settings // per-session copy; differs from the manager's for room agents
+	runtime  *SessionRuntime
+	history  []llm.Message
+	meta     *session.Session
+
+	// Guarded by matrixSessionMgr.mu.
+	lastActivity time.Time
+	turnCancel   context.CancelFunc
+	closed       bool
+}
+
+// matrixPendingApproval is a tool approval waiting on a reaction.
+type matrixPendingApproval struct {
+	roomID  string
+	eventID string // the prompt, edited once answered
+	req     ApprovalRequest
+	answer  chan matrixApprovalAnswer
+}
+
+type matrixApprovalAnswer struct {
+	choice int
+	user   string
+}
+
+// matrixSessionMgr manages per-room and per-thread sessions.
+type matrixSessionMgr struct {
+	ctx         context.Context
+	client      *matrixClient
+	settings    Settings
+	store       session.Store
+	userID      string
+	displayName string
+	scope       string
+
+	idleTimeout  time.Duration
+	editInterval time.Duration // 0 means use matrixMinEditInterval; overridden in tests
+	syncTimeout  time.Duration // 0 means use matrixSyncTimeout; overridden in tests
+	allowedUsers []string
+	allowedRooms map[string]struct{}
+	approvers    []string
+	roomAgents   map[string]string
+	messageSlots chan struct{}
+
+	mu       sync.Mutex
+	sessions map[string]*matrixSession
+	threads  map[string]struct{} // conversation keys of threads the bot replies in
+	members  map[string]int      // joined member count per room, from sync summaries
+
+	approvalMu sync.Mutex
+	approvals  map[string]*matrixPendingApproval // by prompt event ID
+}
+
+func newMatrixSessionMgr(ctx context.Context, client *matrixClient, settings Settings, userID, displayName string, cfg config.MatrixServeConfig) *matrixSessionMgr {
+	scope := cfg.SessionScope
+	if scope == "" {
+		scope = matrixScopeThread
+	}
+	approvers := cfg.ApproverIDs
+	if len(approvers) == 0 {
+		approvers = cfg.AllowedUserIDs
+	}
+	allowedRooms := make(map[string]struct{}, len(cfg.AllowedRoomIDs))
+	for _, id := range cfg.AllowedRoomIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			allowedRooms[id] = struct{}{}
+		}
+	}
+	roomAgents := make(map[string]string, len(cfg.RoomAgents))
+	for _, ra := range cfg.RoomAgents {
+		if room := strings.TrimSpace(ra.Room); room != "" {
+			roomAgents[room] = strings.TrimSpace(ra.Agent)
+		}
+	}
+	return &matrixSessionMgr{
+		ctx:          ctx,
+		client:       client,
+		settings:     settings,
+		store:        settings.Store,
+		userID:       userID,
+		displayName:  displayName,
+		scope:        scope,
+		idleTimeout:  30 * time.Minute,
+		allowedUsers: cfg.AllowedUserIDs,
+		allowedRooms: allowedRooms,
+		approvers:    approvers,
+		roomAgents:   roomAgents,
+		messageSlots: make(chan struct{}, matrixMaxConcurrentHandlers),
+		sessions:     make(map[string]*matrixSession),
+		threads:      make(map[string]struct{}),
+		members:      make(map[string]int),
+		approvals:    make(map[string]*matrixPendingApproval),
+	}
+}
+
+// matrixUserMatches reports whether userID matches an allow-list entry: a
+// full Matrix ID, or ":server" for every user on a homeserver.
+func matrixUserMatches(userID string, entries []string) bool {
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+		case strings.HasPrefix(entry, ":"):
+			if strings.HasSuffix(userID, entry) && strings.Count(userID, ":") == 1 {
+				return true
+			}
+		case entry == userID:
+			return true
+		}
+	}
+	return false
+}
+
+func (m *matrixSessionMgr) isAllowed(userID string) bool {
+	return matrixUserMatches(userID, m.allowedUsers)
+}
+
+func (m *matrixSessionMgr) isApprover(userID string) bool {
+	return matrixUserMatches(userID, m.approvers)
+}
+
+func (m *matrixSessionMgr) isAllowedRoom(roomID string) bool {
+	if len(m.allowedRooms) == 0 {
+		return true
+	}
+	_, ok := m.allowedRooms[roomID]
+	return ok
+}
+
+// isDirect treats rooms with at most two members as direct messages.
+func (m *matrixSessionMgr) isDirect(roomID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.members[roomID]
+	return ok && n <= 2
+}
+
+// agentFor returns the agent assigned to roomID, or the serve agent.
+func (m *matrixSessionMgr) agentFor(roomID string) string {
+	if agent, ok := m.roomAgents[roomID]; ok && agent != "" {
+		return agent
+	}
+	return m.settings.Agent
+}
+
+// syncLoop long-polls /sync until ctx is cancelled. The first sync only
+// establishes the starting point, so messages sent while the bot was
+// offline are not answered late.
+func (m *matrixSessionMgr) syncLoop(ctx context.Context) error {
+	timeout := m.syncTimeout
+	if timeout <= 0 {
+		timeout = matrixSyncTimeout
+	}
+	since := ""
+	backoff := time.Second
+	for {
+		pollTimeout := timeout
+		if since == "" {
+			pollTimeout = 0
+		}
+		resp, err := m.client.sync(ctx, since, pollTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var apiErr *matrixAPIError
+			if errors.As(err, &apiErr) && (apiErr.Status == http.StatusUnauthorized || apiErr.ErrCode == "M_UNKNOWN_TOKEN") {
+				return fmt.Errorf("matrix sync: %w (check serve.matrix.access_token)", err)
+			}
+			log.Printf("[matrix] sync failed, retrying in %s: %v", backoff, err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, matrixSyncMaxBackoff)
+			continue
+		}
+		backoff = time.Second
+		m.processSync(resp, since == "")
+		since = resp.NextBatch
+	}
+}
+
+// processSync handles one /sync response. Timeline events of the initial
+// sync are skipped; invites and member counts are always processed.
+func (m *matrixSessionMgr) processSync(resp *matrixSyncResponse, initial bool) {
+	for roomID, room := range resp.Rooms.Invite {
+		m.handleInvite(roomID, room.InviteState.Events)
+	}
+	for roomID, room := range resp.Rooms.Join {
+		if n := room.Summary.JoinedMemberCount; n != nil {
+			m.mu.Lock()
+			m.members[roomID] = *n
+			m.mu.Unlock()
+		}
+		if initial {
+			continue
+		}
+		for _, ev := range room.Timeline.Events {
+			ev.RoomID = roomID
+			m.dispatch(ev)
+		}
+	}
+	for roomID := range resp.Rooms.Leave {
+		m.mu.Lock()
+		delete(m.members, roomID)
+		m.mu.Unlock()
+	}
+}
+
+// handleInvite joins rooms an allowed user invites the bot to.
+func (m *matrixSessionMgr) handleInvite(roomID string, events []matrixEvent) {
+	inviter := ""
+	for _, ev := range events {
+		if ev.Type != matrixEventMember || ev.StateKey == nil || *ev.StateKey != m.userID {
+			continue
+		}
+		var content struct {
+			Membership string `json:"membership"`
+		}
+		if json.Unmarshal(ev.Content, &content) == nil && content.Membership == "invite" {
+			inviter = ev.Sender
+		}
+	}
+	if inviter == "" {
+		return
+	}
+	if !m.isAllowed(inviter) || !m.isAllowedRoom(roomID) {
+		log.Printf("[matrix] ignoring invite to %s from %s", roomID, inviter)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(m.ctx, 30*time.Second)
+		defer cancel()
+		if err := m.client.joinRoom(ctx, roomID); err != nil {
+			log.Printf("[matrix] join %s: %v", roomID, err)
+			return
+		}
+		log.Printf("[matrix] joined %s (invited by %s)", roomID, inviter)
+	}()
+}
+
+// dispatch handles one timeline event. Message handling runs in its own
+// goroutine so a long turn does not hold up the sync loop.
+func (m *matrixSessionMgr) dispatch(ev matrixEvent) {
+	if ev.Sender == m.userID {
+		return
+	}
+	switch ev.Type {
+	case matrixEventReaction:
+		m.handleReaction(ev)
+	case matrixEventMessage:
+		var content matrixMessageContent
+		if err := json.Unmarshal(ev.Content, &content); err != nil {
+			log.Printf("[matrix] invalid message %s: %v", ev.EventID, err)
+			return
+		}
+		if !m.shouldHandle(ev, content) {
+			return
+		}
+		if !m.isAllowed(ev.Sender) {
+			log.Printf("[matrix] ignoring message from unauthorised user %s", ev.Sender)
+			return
+		}
+		if !m.isAllowedRoom(ev.RoomID) {
+			log.Printf("[matrix] ignoring message in room %s (not in allowed_room_ids)", ev.RoomID)
+			return
+		}
+		go func() {
+			select {
+			case m.messageSlots <- struct{}{}:
+			case <-m.ctx.Done():
+				return
+			}
+			defer func() { <-m.messageSlots }()
+			m.handleMessage(m.ctx, ev, content)
+		}()
+	}
+}
+
+// shouldHandle selects messages that start or continue a conversation:
+// direct messages, mentions, and anything in a thread the bot replies in.
+// Edits and notices (which bots send) are ignored.
+func (m *matrixSessionMgr) shouldHandle(ev matrixEvent, content matrixMessageContent) bool {
+	if content.MsgType == matrixMsgNotice || content.MsgType == "" {
+		return false
+	}
+	if rel := content.RelatesTo; rel != nil && rel.RelType == matrixRelReplace {
+		return false
+	}
+	if m.isDirect(ev.RoomID) || m.mentionsBot(content) {
+		return true
+	}
+	if rel := content.RelatesTo; rel != nil && rel.RelType == matrixRelThread {
+		m.mu.Lock()
+		_, ok := m.threads[matrixConversation{roomID: ev.RoomID, thread: rel.EventID}.key()]
+		m.mu.Unlock()
+		return ok
+	}
+	return false
+}
+
+// mentionsBot checks intentional mentions first and falls back to the bot's
+// ID, a matrix.to pill, or a leading "Name:" for older clients.
+func (m *matrixSessionMgr) mentionsBot(content matrixMessageContent) bool {
+	if content.Mentions != nil {
+		for _, id := range content.Mentions.UserIDs {
+			if id == m.userID {
+				return true
+			}
+		}
+	}
+	if strings.Contains(content.Body, m.userID) || strings.Contains(content.FormattedBody, "https://matrix.to/#/"+m.userID) {
+		return true
+	}
+	return m.displayName != "" && m.namePrefixLen(content.Body) > 0
+}
+
+// namePrefixLen returns the length of a leading "DisplayName:" or
+// "DisplayName," address, or 0.
+func (m *matrixSessionMgr) namePrefixLen(text string) int {
+	name := m.displayName
+	if name == "" || len(text) <= len(name) || !strings.EqualFold(text[:len(name)], name) {
+		return 0
+	}
+	if c := text[len(name)]; c == ':' || c == ',' {
+		return len(name) + 1
+	}
+	return 0
+}
+
+func (m *matrixSessionMgr) stripMention(text string) string {
+	text = strings.TrimSpace(matrixStripReplyFallback(text))
+	text = strings.TrimSpace(strings.ReplaceAll(text, m.userID, ""))
+	text = strings.TrimLeft(text, ":, ")
+	if n := m.namePrefixLen(text); n > 0 {
+		text = text[n:]
+	}
+	return strings.TrimSpace(text)
+}
+
+// matrixStripReplyFallback removes the "> <@user> quoted text" block that
+// older clients prepend to the body of replies.
+func matrixStripReplyFallback(body string) string {
+	if !strings.HasPrefix(body, "> <") {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], ">") {
+		i++
+	}
+	return strings.TrimLeft(strings.Join(lines[i:], "\n"), "\n")
+}
+
+// conversation picks the session a message belongs to. Thread messages stay
+// in their thread; in thread scope a mention in a group room starts a thread
+// rooted at the message. replyTo is the event the first reply should quote
+// (room scope only).
+func (m *matrixSessionMgr) conversation(ev matrixEvent, content matrixMessageContent) (conv matrixConversation, replyTo string) {
+	if rel := content.RelatesTo; rel != nil && rel.RelType == matrixRelThread && rel.EventID != "" {
+		return matrixConversation{roomID: ev.RoomID, thread: rel.EventID}, ""
+	}
+	if m.isDirect(ev.RoomID) {
+		return matrixConversation{roomID: ev.RoomID}, ""
+	}
+	if m.scope == matrixScopeThread {
+		return matrixConversation{roomID: ev.RoomID, thread: ev.EventID}, ""
+	}
+	return matrixConversation{roomID: ev.RoomID}, ev.EventID
+}
+
+func (m *matrixSessionMgr) handleMessage(ctx context.Context, ev matrixEvent, content matrixMessageContent) {
+	conv, replyTo := m.conversation(ev, content)
+
+	var attachments []chatAttachment
+	text := content.Body
+	switch content.MsgType {
+	case matrixMsgImage, matrixMsgFile, matrixMsgAudio, matrixMsgVideo:
+		name := content.Filename
+		if name == "" {
+			name = content.Body
+		}
+		// body is the file name unless a separate filename makes it a caption.
+		if content.Filename == "" || content.Filename == content.Body {
+			text = ""
+		}
+		a := chatAttachment{Name: name, URL: content.URL}
+		if content.Info != nil {
+			a.MimeType, a.Size = content.Info.MimeType, content.Info.Size
+		}
+		attachments = append(attachments, a)
+	}
+	text = m.stripMention(text)
+	if cmd, ok := strings.CutPrefix(text, "!"); ok && len(attachments) == 0 && !strings.ContainsAny(cmd, " \n") {
+		if m.handleCommand(ctx, ev, conv, strings.ToLower(cmd)) {
+			return
+		}
+	}
+
+	userMsg, cleanup, err := buildAttachmentMessage(ctx, text, attachments, "matrix-upload", m.client.downloadMedia)
+	defer cleanup()
+	if err != nil {
+		log.Printf("[matrix] failed to read attachment in %s: %v", ev.RoomID, err)
+		m.postNotice(ctx, conv, ev.EventID, "Failed to process attachment: "+err.Error())
+		return
+	}
+	if len(userMsg.Parts) == 0 {
+		return
+	}
+
+	sess, err := m.getOrCreate(ctx, conv)
+	if err != nil {
+		m.postNotice(ctx, conv, ev.EventID, "Error creating session: "+err.Error())
+		return
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if m.isClosed(sess) {
+		// Reset or expired while this message was queued behind the previous turn.
+		if sess, err = m.getOrCreate(ctx, conv); err != nil {
+			m.postNotice(ctx, conv, ev.EventID, "Error creating session: "+err.Error())
+			return
+		}
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+	}
+	if err := m.streamReply(ctx, sess, userMsg, ev.EventID, replyTo); err != nil {
+		log.Printf("[matrix] reply failed in %s: %v", conv.key(), err)
+		m.postNotice(ctx, conv, ev.EventID, "Error: "+err.Error())
+	}
+}
+
+// relation returns the m.relates_to for a bot message in conv. lastEventID is
+// the message being answered, used as the thread's reply fallback.
+func matrixRelation(conv matrixConversation, lastEventID, replyTo string) *matrixRelatesTo {
+	switch {
+	case conv.thread != "":
+		return &matrixRelatesTo{
+			RelType:       matrixRelThread,
+			EventID:       conv.thread,
+			IsFallingBack: true,
+			InReplyTo:     &matrixInReplyTo{EventID: lastEventID},
+		}
+	case replyTo != "":
+		return &matrixRelatesTo{InReplyTo: &matrixInReplyTo{EventID: replyTo}}
+	}
+	return nil
+}
+
+// matrixTextContent renders Markdown as a message with an HTML body.
+func matrixTextContent(msgType, markdown string) matrixMessageContent {
+	return matrixMessageContent{
+		MsgType:       msgType,
+		Body:          markdown,
+		Format:        matrixFormatHTML,
+		FormattedBody: mdToMatrixHTML(markdown),
+		Mentions:      &matrixMentions{},
+	}
+}
+
+// mdToMatrixHTML converts Markdown to the HTML subset Matrix clients
+// render. Raw HTML in the source is escaped.
+func mdToMatrixHTML(md string) string {
+	var buf bytes.Buffer
+	if err := emailMarkdown.Convert([]byte(md), &buf); err != nil {
+		return html.EscapeString(md)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func (m *matrixSessionMgr) postNotice(ctx context.Context, conv matrixConversation, lastEventID, text string) {
+	content := matrixTextContent(matrixMsgNotice, text)
+	content.RelatesTo = matrixRelation(conv, lastEventID, "")
+	if _, err := m.client.sendEvent(ctx, conv.roomID, matrixEventMessage, content); err != nil {
+		log.Printf("[matrix] post notice to %s: %v", conv.roomID, err)
+	}
+}
+
+// handleCommand answers the !-prefixed text commands. It returns false for
+// unknown commands so they reach the agent as ordinary text.
+func (m *matrixSessionMgr) handleCommand(ctx context.Context, ev matrixEvent, conv matrixConversation, cmd string) bool {
+	switch cmd {
+	case "help":
+		m.postNotice(ctx, conv, ev.EventID, "Mention me or send a direct message to start a conversation. "+
+			"In group rooms each conversation gets its own thread; reply there without mentioning me.\n\n"+
+			"`!status` - show this conversation's state\n"+
+			"`!reset` - start a new session for this conversation")
+	case "reset":
+		m.mu.Lock()
+		sess := m.sessions[conv.key()]
+		m.mu.Unlock()
+		if sess == nil {
+			m.postNotice(ctx, conv, ev.EventID, "No active conversation here.")
+			return true
+		}
+		m.closeSession(sess)
+		m.postNotice(ctx, conv, ev.EventID, "Conversation cleared.")
+	case "status":
+		m.mu.Lock()
+		sess := m.sessions[conv.key()]
+		running := sess != nil && sess.turnCancel != nil
+		var lastActivity time.Time
+		if sess != nil {
+			lastActivity = sess.lastActivity
+		}
+		m.mu.Unlock()
+		if sess == nil {
+			m.postNotice(ctx, conv, ev.EventID, "No active conversation here.")
+			return true
+		}
+		m.approvalMu.Lock()
+		waiting := 0
+		for _, pending := range m.approvals {
+			if pending.roomID == conv.roomID {
+				waiting++
+			}
+		}
+		m.approvalMu.Unlock()
+		state := "idle"
+		if running {
+			state = "responding"
+		}
+		agent := sess.agent
+		if agent == "" {
+			agent = "default"
+		}
+		m.postNotice(ctx, conv, ev.EventID, fmt.Sprintf("Session `%s` (agent %s): %s, last activity %s ago\nPending approvals in this room: %d",
+			sess.meta.ID, agent, state, time.Since(lastActivity).Round(time.Second), waiting))
+	default:
+		return false
+	}
+	return true
+}
+
+// getOrCreate returns the live session for conv. A session idle for longer
+// than idleTimeout is replaced, like one reaped in the background.
+func (m *matrixSessionMgr) getOrCreate(ctx context.Context, conv matrixConversation) (*matrixSession, error) {
+	key := conv.key()
+	m.mu.Lock()
+	if sess, ok := m.sessions[key]; ok {
+		if sess.turnCancel != nil || time.Since(sess.lastActivity) <= m.idleTimeout {
+			sess.lastActivity = time.Now()
+			m.mu.Unlock()
+			return sess, nil
+		}
+		m.mu.Unlock()
+		m.closeSession(sess)
+	} else {
+		m.mu.Unlock()
+	}
+
+	created, err := m.newSession(ctx, conv)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if existing, ok := m.sessions[key]; ok {
+		m.mu.Unlock()
+		closeMatrixRuntime(created)
+		return existing, nil
+	}
+	m.sessions[key] = created
+	if conv.thread != "" {
+		m.threads[key] = struct{}{}
+	}
+	m.mu.Unlock()
+	return created, nil
+}
+
+// newSession starts a new stored session for conv. Like Telegram, the tail
+// of the conversation's previous session (up to Settings.CarryoverChars) is
+// carried over as context, so a reset, an idle timeout or a restart does not
+// lose the thread of the conversation.
+func (m *matrixSessionMgr) newSession(ctx context.Context, conv matrixConversation) (*matrixSession, error) {
+	agent := m.agentFor(conv.roomID)
+	settings := m.settings
+	var runtime *SessionRuntime
+	if agent != m.settings.Agent && m.settings.Runner != nil {
+		// The runner builds the room agent's own engine, tools and prompt.
+		settings.Agent = agent
+		settings.SystemPrompt = ""
+		settings.PlatformMessages = agents.PlatformMessagesConfig{}
+		runtime = &SessionRuntime{}
+	} else {
+		if m.settings.NewSession == nil {
+			return nil, fmt.Errorf("matrix runtime factory is not configured")
+		}
+		var err error
+		if runtime, err = m.settings.NewSession(ctx); err != nil {
+			return nil, fmt.Errorf("create runtime: %w", err)
+		}
+	}
+
+	sess := &matrixSession{
+		conv:         conv,
+		agent:        agent,
+		settings:     settings,
+		runtime:      runtime,
+		lastActivity: time.Now(),
+	}
+	if runtime.SetApprovalHandler != nil {
+		runtime.SetApprovalHandler(func(req ApprovalRequest) (int, error) {
+			return m.requestApproval(sess, req)
+		})
+	}
+
+	providerName := strings.TrimSpace(runtime.ProviderName)
+	if providerName == "" {
+		providerName = "unknown"
+	}
+	modelName := strings.TrimSpace(runtime.ModelName)
+	if modelName == "" {
+		modelName = "unknown"
+	}
+	name := "matrix:" + conv.key()
+	sess.meta = &session.Session{
+		ID:        session.NewID(),
+		Name:      name,
+		Provider:  providerName,
+		Model:     modelName,
+		Mode:      session.ModeChat,
+		Origin:    session.OriginMatrix,
+		Agent:     agent,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Search:    m.settings.Search,
+		Tools:     m.settings.Tools,
+		MCP:       m.settings.MCP,
+		Status:    session.StatusActive,
+	}
+	if cwd, cwdErr := os.Getwd(); cwdErr == nil {
+		sess.meta.CWD = cwd
+	}
+	if m.store != nil {
+		m.carryOver(ctx, sess, name)
+		if err := m.store.Create(ctx, sess.meta); err != nil {
+			log.Printf("[matrix] session Create failed for %s: %v", sess.meta.ID, err)
+		}
+	}
+	return sess, nil
+}
+
+// carryOver seeds sess.history with the tail of the most recent earlier
+// session with the same name. The carried messages are context only; they
+// are not persisted into the new session.
+func (m *matrixSessionMgr) carryOver(ctx context.Context, sess *matrixSession, name string) {
+	maxChars := m.settings.CarryoverChars
+	if maxChars <= 0 {
+		return
+	}
+	summaries, err := m.store.List(ctx, session.ListOptions{Name: name, Limit: 5})
+	if err != nil {
+		log.Printf("[matrix] carry-over: list failed for %s: %v", name, err)
+		return
+	}
+	for _, s := range summaries {
+		if s.MessageCount == 0 {
+			continue
+		}
+		history, _, err := loadCarryoverHistory(ctx, m.store, s.ID, maxChars)
+		if err != nil {
+			log.Printf("[matrix] carry-over: get messages failed for session %s: %v", s.ID, err)
+			continue
+		}
+		if history = sanitizeCarryoverMessages(history); len(history) > 0 {
+			sess.history = history
+			log.Printf("[matrix] carried %d messages from session %s into %s", len(history), s.ID, name)
+		}
+		return
+	}
+}
+
+func (m *matrixSessionMgr) isClosed(sess *matrixSession) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sess.closed
+}
+
+// closeSession removes sess and cancels its active turn. The runtime is
+// released once the turn has unwound.
+func (m *matrixSessionMgr) closeSession(sess *matrixSession) {
+	key := sess.conv.key()
+	m.mu.Lock()
+	if m.sessions[key] == sess {
+		delete(m.sessions, key)
+	}
+	sess.closed = true
+	if sess.turnCancel != nil {
+		sess.turnCancel()
+	}
+	m.mu.Unlock()
+	m.cancelApprovals(sess.conv.roomID)
+
+	go func() {
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		closeMatrixRuntime(sess)
+	}()
+}
+
+func closeMatrixRuntime(sess *matrixSession) {
+	if sess.runtime != nil && sess.runtime.Cleanup != nil {
+		sess.runtime.Cleanup()
+	}
+	sess.runtime = nil
+}
+
+func (m *matrixSessionMgr) closeAllSessions() {
+	m.mu.Lock()
+	sessions := make([]*matrixSession, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		sessions = append(sessions, sess)
+	}
+	m.mu.Unlock()
+	for _, sess := range sessions {
+		m.closeSession(sess)
+	}
+}
+
+// reapIdleSessions closes sessions with no activity for idleTimeout. The
+// next message starts a new session with the old one's tail carried over.
+func (m *matrixSessionMgr) reapIdleSessions(ctx context.Context) {
+	interval := min(m.idleTimeout/2, time.Minute)
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var idle []*matrixSession
+		m.mu.Lock()
+		for _, sess := range m.sessions {
+			if sess.turnCancel == nil && time.Since(sess.lastActivity) > m.idleTimeout {
+				idle = append(idle, sess)
+			}
+		}
+		m.mu.Unlock()
+		for _, sess := range idle {
+			// m.threads keeps the thread, so follow-ups still need no mention.
+			m.closeSession(sess)
+		}
+	}
+}
+
+// streamReply runs one turn and streams it into the conversation by editing
+// a placeholder message. Replies that outgrow one message continue in a new
+// one. The caller holds sess.mu.
+func (m *matrixSessionMgr) streamReply(ctx context.Context, sess *matrixSession, userMsg llm.Message, lastEventID, replyTo string) error {
+	if sess.runtime == nil {
+		return fmt.Errorf("session is closed")
+	}
+	turnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m.mu.Lock()
+	sess.turnCancel = cancel
+	sess.lastActivity = time.Now()
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		sess.turnCancel = nil
+		sess.lastActivity = time.Now()
+		m.mu.Unlock()
+	}()
+
+	turn, err := startChatTurn(turnCtx, runpkg.PlatformMatrix, sess.settings, sess.runtime, sess.meta.ID, sess.history, userMsg)
+	if err != nil {
+		return err
+	}
+	defer turn.close()
+
+	reply := &matrixReplyWriter{
+		client:       m.client,
+		roomID:       sess.conv.roomID,
+		relation:     matrixRelation(sess.conv, lastEventID, replyTo),
+		editInterval: m.editInterval,
+	}
+	if err := reply.start(turnCtx); err != nil {
+		return fmt.Errorf("send placeholder: %w", err)
+	}
+
+	var (
+		streamErr error
+		images    []string
+		toolsRan  bool
+	)
+	events := turn.events(turnCtx)
+	ticker := time.NewTicker(reply.interval())
+	defer ticker.Stop()
+recvLoop:
+	for {
+		select {
+		case <-ticker.C:
+			reply.flush(turnCtx, false)
+		case <-turnCtx.Done():
+			streamErr = turnCtx.Err()
+			break recvLoop
+		case res, ok := <-events:
+			if !ok {
+				break recvLoop
+			}
+			if res.err != nil {
+				if !errors.Is(res.err, io.EOF) {
+					streamErr = res.err
+				}
+				break recvLoop
+			}
+			switch ev := res.ev; ev.Type {
+			case llm.EventTextDelta:
+				reply.appendText(ev.Text)
+			case llm.EventToolExecStart:
+				toolsRan = true
+				status := "🔧 " + ev.ToolName
+				if ev.ToolInfo != "" {
+					status += " " + ev.ToolInfo
+				}
+				reply.setStatus(status)
+			case llm.EventToolExecEnd:
+				reply.setStatus("")
+				images = append(images, ev.ToolImages...)
+			case llm.EventRetry:
+				reply.setStatus(fmt.Sprintf("retrying (attempt %d)…", ev.RetryAttempt))
+			case llm.EventError:
+				if ev.Err != nil {
+					streamErr = ev.Err
+				}
+			}
+		}
+	}
+
+	deliveryCtx, cancelDelivery := context.WithTimeout(context.WithoutCancel(ctx), matrixFinalDeliveryTimeout)
+	defer cancelDelivery()
+	reply.setStatus("")
+	if streamErr != nil {
+		notice := "⚠️ " + streamErr.Error()
+		if errors.Is(streamErr, context.Canceled) {
+			notice = "*(stopped)*"
+		}
+		reply.appendNotice(notice)
+	} else if reply.empty() {
+		if toolsRan {
+			reply.appendNotice("(done)")
+		} else {
+			reply.appendNotice("(no response)")
+		}
+	}
+	if err := reply.flush(deliveryCtx, true); err != nil {
+		log.Printf("[matrix] final edit failed in %s: %v", sess.conv.key(), err)
+	}
+	for _, path := range images {
+		if err := m.sendImage(deliveryCtx, sess.conv.roomID, reply.relation, path); err != nil {
+			log.Printf("[matrix] upload image %s: %v", path, err)
+		}
+	}
+
+	sess.history = append(sess.history, turn.finish(deliveryCtx, streamErr, reply.text())...)
+	return nil
+}
+
+// sendImage uploads a tool-generated image and posts it to the room.
+func (m *matrixSessionMgr) sendImage(ctx context.Context, roomID string, relation *matrixRelatesTo, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(path)
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	uri, err := m.client.uploadMedia(ctx, name, contentType, data)
+	if err != nil {
+		return err
+	}
+	_, err = m.client.sendEvent(ctx, roomID, matrixEventMessage, matrixMessageContent{
+		MsgType:   matrixMsgImage,
+		Body:      name,
+		Filename:  name,
+		URL:       uri,
+		Info:      &matrixMediaInfo{MimeType: contentType, Size: int64(len(data))},
+		RelatesTo: relation,
+		Mentions:  &matrixMentions{},
+	})
+	return err
+}
+
+// matrixReplyWriter accumulates streamed text and mirrors it into Matrix
+// messages via edits, splitting into follow-up messages past
+// matrixMaxMessageRunes.
+type matrixReplyWriter struct {
+	client       *matrixClient
+	roomID       string
+	relation     *matrixRelatesTo
+	editInterval time.Duration
+
+	mu        sync.Mutex
+	full      strings.Builder // all assistant text this turn
+	pending   string          // text not yet committed to an earlier message
+	status    string
+	currentID string
+	lastSent  string
+	lastEdit  time.Time
+}
+
+func (w *matrixReplyWriter) interval() time.Duration {
+	if w.editInterval > 0 {
+		return w.editInterval
+	}
+	return matrixMinEditInterval
+}
+
+func (w *matrixReplyWriter) send(ctx context.Context, body string) error {
+	content := matrixTextContent(matrixMsgText, body)
+	content.RelatesTo = w.relation
+	id, err := w.client.sendEvent(ctx, w.roomID, matrixEventMessage, content)
+	if err != nil {
+		return err
+	}
+	w.currentID = id
+	w.lastSent = body
+	return nil
+}
+
+func (w *matrixReplyWriter) start(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.send(ctx, "⏳")
+}
+
+func (w *matrixReplyWriter) appendText(text string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.full.WriteString(text)
+	w.pending += text
+}
+
+// appendNotice adds a status line that is shown but not kept as assistant text.
+func (w *matrixReplyWriter) appendNotice(text string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if strings.TrimSpace(w.pending) != "" {
+		w.pending += "\n\n"
+	}
+	w.pending += text
+}
+
+func (w *matrixReplyWriter) setStatus(status string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status = status
+}
+
+func (w *matrixReplyWriter) text() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.full.String()
+}
+
+func (w *matrixReplyWriter) empty() bool {
+	return strings.TrimSpace(w.text()) == ""
+}
+
+// flush pushes the current text to the room. Intermediate flushes are rate
+// limited and skipped when nothing changed; the final flush always edits.
+func (w *matrixReplyWriter) flush(ctx context.Context, final bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !final && time.Since(w.lastEdit) < w.interval() {
+		return nil
+	}
+
+	for utf8.RuneCountInString(w.pending) > matrixMaxMessageRunes {
+		head, rest := splitMessageAtLine(w.pending, matrixMaxMessageRunes)
+		if err := w.edit(ctx, head); err != nil {
+			return err
+		}
+		if err := w.send(ctx, "⏳"); err != nil {
+			return err
+		}
+		w.pending = rest
+	}
+
+	body := strings.TrimSpace(w.pending)
+	if w.status != "" {
+		if body != "" {
+			body += "\n\n"
+		}
+		body += "*" + w.status + "*"
+	} else if !final && body != "" {
+		body += " ▌"
+	}
+	if body == "" {
+		if !final {
+			return nil
+		}
+		body = "(no response)"
+	}
+	if body == w.lastSent {
+		return nil
+	}
+	return w.edit(ctx, body)
+}
+
+// edit replaces the current message's content with an m.replace event.
+func (w *matrixReplyWriter) edit(ctx context.Context, body string) error {
+	newContent := matrixTextContent(matrixMsgText, body)
+	content := matrixTextContent(matrixMsgText, "* "+body)
+	content.NewContent = &newContent
+	content.RelatesTo = &matrixRelatesTo{RelType: matrixRelReplace, EventID: w.currentID}
+	_, err := w.client.sendEvent(ctx, w.roomID, matrixEventMessage, content)
+	w.lastEdit = time.Now()
+	if err == nil {
+		w.lastSent = body
+	}
+	return err
+}
+
+// requestApproval posts an approval prompt, offers one keycap reaction per
+// option and blocks until an approver reacts or the session ends.
+func (m *matrixSessionMgr) requestApproval(sess *matrixSession, req ApprovalRequest) (int, error) {
+	m.mu.Lock()
+	closed := sess.closed
+	m.mu.Unlock()
+	if closed {
+		return -1, nil
+	}
+
+	content := matrixTextContent(matrixMsgText, matrixApprovalText(req))
+	content.RelatesTo = matrixRelation(sess.conv, sess.conv.thread, "")
+	eventID, err := m.client.sendEvent(m.ctx, sess.conv.roomID, matrixEventMessage, content)
+	if err != nil {
+		return -1, fmt.Errorf("post approval prompt: %w", err)
+	}
+	pending := &matrixPendingApproval{
+		roomID:  sess.conv.roomID,
+		eventID: eventID,
+		req:     req,
+		answer:  make(chan matrixApprovalAnswer, 1),
+	}
+	m.approvalMu.Lock()
+	m.approvals[eventID] = pending
+	m.approvalMu.Unlock()
+	defer func() {
+		m.approvalMu.Lock()
+		delete(m.approvals, eventID)
+		m.approvalMu.Unlock()
+	}()
+
+	// Seed the reactions so approvers can answer with one click.
+	for i := range req.Options {
+		if i >= len(matrixApprovalEmoji) {
+			break
+		}
+		if _, err := m.client.sendEvent(m.ctx, sess.conv.roomID, matrixEventReaction, map[string]any{
+			"m.relates_to": matrixRelatesTo{RelType: matrixRelAnnotation, EventID: eventID, Key: matrixApprovalEmoji[i]},
+		}); err != nil {
+			log.Printf("[matrix] seed approval reaction: %v", err)
+			break
+		}
+	}
+
+	answer := matrixApprovalAnswer{choice: -1}
+	select {
+	case answer = <-pending.answer:
+	case <-m.ctx.Done():
+	}
+
+	outcome := "Cancelled"
+	if answer.choice >= 0 && answer.choice < len(req.Options) {
+		outcome = "**" + req.Options[answer.choice].Label + "**"
+	}
+	if answer.user != "" {
+		outcome += " by " + answer.user
+	}
+	resolved := matrixApprovalPrompt(req) + "\n\n➜ " + outcome
+	newContent := matrixTextContent(matrixMsgText, resolved)
+	edit := matrixTextContent(matrixMsgText, "* "+resolved)
+	edit.NewContent = &newContent
+	edit.RelatesTo = &matrixRelatesTo{RelType: matrixRelReplace, EventID: eventID}
+	if _, err := m.client.sendEvent(context.WithoutCancel(m.ctx), sess.conv.roomID, matrixEventMessage, edit); err != nil {
+		log.Printf("[matrix] update approval %s: %v", eventID, err)
+	}
+	return answer.choice, nil
+}
+
+// matrixApprovalPrompt renders the request itself: title, target and
+// working directory.
+func matrixApprovalPrompt(req ApprovalRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s**\n\n```\n%s\n```", req.Title, strings.ReplaceAll(req.Target, "```", "`​``"))
+	if req.WorkDir != "" {
+		fmt.Fprintf(&b, "\n\nin `%s`", req.WorkDir)
+	}
+	return b.String()
+}
+
+// matrixApprovalText is the prompt plus the numbered options, answered by
+// reacting with the matching keycap.
+func matrixApprovalText(req ApprovalRequest) string {
+	var b strings.Builder
+	b.WriteString(matrixApprovalPrompt(req))
+	b.WriteString("\n")
+	for i, opt := range req.Options {
+		if i < len(matrixApprovalEmoji) {
+			fmt.Fprintf(&b, "\n%s %s  ", matrixApprovalEmoji[i], opt.Label)
+		}
+	}
+	b.WriteString("\n\nReact with a number to answer.")
+	return b.String()
+}
+
+// handleReaction resolves approvals answered with a keycap reaction.
+func (m *matrixSessionMgr) handleReaction(ev matrixEvent) {
+	var content struct {
+		RelatesTo matrixRelatesTo `json:"m.relates_to"`
+	}
+	if err := json.Unmarshal(ev.Content, &content); err != nil || content.RelatesTo.RelType != matrixRelAnnotation {
+		return
+	}
+	choice := -1
+	for i, emoji := range matrixApprovalEmoji {
+		// Some clients drop the variation selector from keycaps.
+		if content.RelatesTo.Key == emoji || content.RelatesTo.Key == strings.ReplaceAll(emoji, "️", "") {
+			choice = i
+			break
+		}
+	}
+	if choice < 0 {
+		return
+	}
+	m.approvalMu.Lock()
+	pending := m.approvals[content.RelatesTo.EventID]
+	m.approvalMu.Unlock()
+	if pending == nil || choice >= len(pending.req.Options) {
+		return
+	}
+	if !m.isApprover(ev.Sender) {
+		log.Printf("[matrix] ignoring approval reaction from unauthorised user %s", ev.Sender)
+		return
+	}
+	select {
+	case pending.answer <- matrixApprovalAnswer{choice: choice, user: ev.Sender}:
+	default: // already answered
+	}
+}
+
+// cancelApprovals dismisses the prompts a closed session left open.
+func (m *matrixSessionMgr) cancelApprovals(roomID string) {
+	m.approvalMu.Lock()
+	defer m.approvalMu.Unlock()
+	for _, pending := range m.approvals {
+		if pending.roomID == roomID {
+			select {
+			case pending.answer <- matrixApprovalAnswer{choice: -1}:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// matrixMaxAPIResponseBytes also bounds media downloads; one byte over
+	// the attachment limit lets downloadMedia detect oversized files.
+	matrixMaxAPIResponseBytes = maxChatAttachmentBytes + 1
+	// matrixMaxRateLimitWait is the longest M_LIMIT_EXCEEDED wait honoured
+	// inline; longer waits surface as an error rather than stalling a reply.
+	matrixMaxRateLimitWait = 30 * time.Second
+	matrixMaxRetries       = 3
+
+	// matrixSyncFilter keeps /sync to what the adapter reads: messages and
+	// reactions in the timeline, and room summaries for member counts.
+	matrixSyncFilter = `{"presence":{"types":[]},"account_data":{"types":[]},` +
+		`"room":{"timeline":{"types":["m.room.message","m.reaction"],"limit":50},` +
+		`"state":{"types":[],"lazy_load_members":true},"ephemeral":{"types":[]},"account_data":{"types":[]}}}`
+)
+
+// Matrix event types, relation types and message types used by the adapter.
+const (
+	matrixEventMessage  = "m.room.message"
+	matrixEventReaction = "m.reaction"
+	matrixEventMember   = "m.room.member"
+
+	matrixRelThread     = "m.thread"
+	matrixRelReplace    = "m.replace"
+	matrixRelAnnotation = "m.annotation"
+
+	matrixMsgText   = "m.text"
+	matrixMsgNotice = "m.notice"
+	matrixMsgImage  = "m.image"
+	matrixMsgFile   = "m.file"
+	matrixMsgAudio  = "m.audio"
+	matrixMsgVideo  = "m.video"
+
+	matrixFormatHTML = "org.matrix.custom.html"
+)
+
+// matrixEvent is a client-format room event from /sync.
+type matrixEvent struct {
+	Type     string          `json:"type"`
+	EventID  string          `json:"event_id"`
+	Sender   string          `json:"sender"`
+	StateKey *string         `json:"state_key,omitempty"`
+	Content  json.RawMessage `json:"content"`
+	RoomID   string          `json:"-"` // filled in from the enclosing room
+}
+
+type matrixSyncResponse struct {
+	NextBatch string `json:"next_batch"`
+	Rooms     struct {
+		Join   map[string]matrixJoinedRoom  `json:"join"`
+		Invite map[string]matrixInvitedRoom `json:"invite"`
+		Leave  map[string]json.RawMessage   `json:"leave"`
+	} `json:"rooms"`
+}
+
+type matrixJoinedRoom struct {
+	Summary struct {
+		JoinedMemberCount *int `json:"m.joined_member_count"`
+	} `json:"summary"`
+	Timeline struct {
+		Events []matrixEvent `json:"events"`
+	} `json:"timeline"`
+}
+
+type matrixInvitedRoom struct {
+	InviteState struct {
+		Events []matrixEvent `json:"events"`
+	} `json:"invite_state"`
+}
+
+// matrixMessageContent is the content of m.room.message events, both the
+// ones we read and the ones we send.
+type matrixMessageContent struct {
+	MsgType       string                `json:"msgtype"`
+	Body          string                `json:"body"`
+	Format        string                `json:"format,omitempty"`
+	FormattedBody string                `json:"formatted_body,omitempty"`
+	Filename      string                `json:"filename,omitempty"`
+	URL           string                `json:"url,omitempty"`
+	Info          *matrixMediaInfo      `json:"info,omitempty"`
+	RelatesTo     *matrixRelatesTo      `json:"m.relates_to,omitempty"`
+	NewContent    *matrixMessageContent `json:"m.new_content,omitempty"`
+	// Mentions is always sent (as {} when empty) so clients do not fall
+	// back to pinging users whose names appear in the body.
+	Mentions *matrixMentions `json:"m.mentions,omitempty"`
+}
+
+type matrixMediaInfo struct {
+	MimeType string `json:"mimetype,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+}
+
+type matrixRelatesTo struct {
+	RelType       string           `json:"rel_type,omitempty"`
+	EventID       string           `json:"event_id,omitempty"`
+	Key           string           `json:"key,omitempty"`
+	IsFallingBack bool             `json:"is_falling_back,omitempty"`
+	InReplyTo     *matrixInReplyTo `json:"m.in_reply_to,omitempty"`
+}
+
+type matrixInReplyTo struct {
+	EventID string `json:"event_id"`
+}
+
+type matrixMentions struct {
+	UserIDs []string `json:"user_ids,omitempty"`
+	Room    bool     `json:"room,omitempty"`
+}
+
+// matrixAPIError is a non-2xx client-server API response.
+type matrixAPIError struct {
+	Method     string
+	Path       string
+	Status     int
+	ErrCode    string
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *matrixAPIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	if e.ErrCode != "" {
+		msg = e.ErrCode + ": " + msg
+	}
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("matrix %s %s: %s (retry after %s)", e.Method, e.Path, msg, e.RetryAfter)
+	}
+	return fmt.Sprintf("matrix %s %s: %d %s", e.Method, e.Path, e.Status, msg)
+}
+
+// matrixClient is a minimal Matrix client-server API client authenticating
+// with an access token.
+type matrixClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+	txnBase string
+	txnSeq  atomic.Uint64
+}
+
+func newMatrixClient(homeserverURL, token string) *matrixClient {
+	return &matrixClient{
+		baseURL: strings.TrimRight(strings.TrimSpace(homeserverURL), "/"),
+		token:   token,
+		// No client timeout: /sync long-polls. Requests are bounded by ctx.
+		http:    &http.Client{},
+		txnBase: rand.Text()[:12],
+	}
+}
+
+func (c *matrixClient) doJSON(ctx context.Context, method, path string, body any, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("matrix %s %s: encode request: %w", method, path, err)
+		}
+	}
+	return c.do(ctx, method, path, "application/json", payload, out)
+}
+
+// do sends one request, waiting out short rate limits. path may carry a
+// query string; it is reported without it in errors.
+func (c *matrixClient) do(ctx context.Context, method, path, contentType string, payload []byte, out any) error {
+	shortPath, _, _ := strings.Cut(path, "?")
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+		if err != nil {
+			return fmt.Errorf("matrix %s %s: %w", method, shortPath, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		if contentType != "" && payload != nil {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return fmt.Errorf("matrix %s %s: %w", method, shortPath, err)
+		}
+		data, readErr := io.ReadAll(io.LimitReader(resp.Body, matrixMaxAPIResponseBytes))
+		resp.Body.Close()
+		if readErr != nil {
+			return fmt.Errorf("matrix %s %s: read response: %w", method, shortPath, readErr)
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			if out != nil {
+				if raw, ok := out.(*[]byte); ok {
+					*raw = data
+				} else if len(data) > 0 {
+					if err := json.Unmarshal(data, out); err != nil {
+						return fmt.Errorf("matrix %s %s: decode response: %w", method, shortPath, err)
+					}
+				}
+			}
+			return nil
+		}
+
+		apiErr := &matrixAPIError{Method: method, Path: shortPath, Status: resp.StatusCode}
+		var errBody struct {
+			ErrCode      string `json:"errcode"`
+			Error        string `json:"error"`
+			RetryAfterMS int64  `json:"retry_after_ms"`
+		}
+		if json.Unmarshal(data, &errBody) == nil {
+			apiErr.ErrCode = errBody.ErrCode
+			apiErr.Message = errBody.Error
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return apiErr
+		}
+		wait := time.Duration(errBody.RetryAfterMS) * time.Millisecond
+		if wait <= 0 {
+			if secs, convErr := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); convErr == nil {
+				wait = time.Duration(secs * float64(time.Second))
+			}
+		}
+		if wait <= 0 {
+			wait = time.Second
+		}
+		apiErr.RetryAfter = wait
+		if attempt >= matrixMaxRetries || wait > matrixMaxRateLimitWait {
+			return apiErr
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// whoami returns the user ID the access token belongs to.
+func (c *matrixClient) whoami(ctx context.Context) (string, error) {
+	var out struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.doJSON(ctx, http.MethodGet, "/_matrix/client/v3/account/whoami", nil, &out); err != nil {
+		return "", err
+	}
+	if out.UserID == "" {
+		return "", errors.New("matrix whoami: empty user_id")
+	}
+	return out.UserID, nil
+}
+
+// displayName returns userID's display name, or "" when none is set.
+func (c *matrixClient) displayName(ctx context.Context, userID string) (string, error) {
+	var out struct {
+		DisplayName string `json:"displayname"`
+	}
+	err := c.doJSON(ctx, http.MethodGet, "/_matrix/client/v3/profile/"+url.PathEscape(userID)+"/displayname", nil, &out)
+	var apiErr *matrixAPIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound {
+		return "", nil
+	}
+	return out.DisplayName, err
+}
+
+// sync long-polls for events after since. An empty since returns the
+// current state, which the adapter uses only to find its starting point.
+func (c *matrixClient) sync(ctx context.Context, since string, timeout time.Duration) (*matrixSyncResponse, error) {
+	q := url.Values{}
+	q.Set("filter", matrixSyncFilter)
+	q.Set("set_presence", "offline")
+	q.Set("timeout", strconv.FormatInt(timeout.Milliseconds(), 10))
+	if since != "" {
+		q.Set("since", since)
+	}
+	var out matrixSyncResponse
+	if err := c.doJSON(ctx, http.MethodGet, "/_matrix/client/v3/sync?"+q.Encode(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *matrixClient) joinRoom(ctx context.Context, roomID string) error {
+	return c.doJSON(ctx, http.MethodPost, "/_matrix/client/v3/join/"+url.PathEscape(roomID), map[string]any{}, nil)
+}
+
+// sendEvent sends a room event and returns its event ID. Each call uses a
+// fresh transaction ID, so retries inside do are deduplicated by the server.
+func (c *matrixClient) sendEvent(ctx context.Context, roomID, eventType string, content any) (string, error) {
+	txnID := fmt.Sprintf("%s.%d", c.txnBase, c.txnSeq.Add(1))
+	var out struct {
+		EventID string `json:"event_id"`
+	}
+	path := "/_matrix/client/v3/rooms/" + url.PathEscape(roomID) + "/send/" + url.PathEscape(eventType) + "/" + url.PathEscape(txnID)
+	if err := c.doJSON(ctx, http.MethodPut, path, content, &out); err != nil {
+		return "", err
+	}
+	return out.EventID, nil
+}
+
+// uploadMedia stores data in the media repository and returns its mxc:// URI.
+func (c *matrixClient) uploadMedia(ctx context.Context, filename, contentType string, data []byte) (string, error) {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	var out struct {
+		ContentURI string `json:"content_uri"`
+	}
+	path := "/_matrix/media/v3/upload?filename=" + url.QueryEscape(filename)
+	if err := c.do(ctx, http.MethodPost, path, contentType, data, &out); err != nil {
+		return "", err
+	}
+	if out.ContentURI == "" {
+		return "", errors.New("matrix upload: empty content_uri")
+	}
+	return out.ContentURI, nil
+}
+
+// downloadMedia fetches an mxc:// URI through the authenticated media API,
+// falling back to the legacy endpoint on homeservers that predate it.
+func (c *matrixClient) downloadMedia(ctx context.Context, mxcURI string) ([]byte, error) {
+	serverAndID, ok := strings.CutPrefix(mxcURI, "mxc://")
+	server, mediaID, found := strings.Cut(serverAndID, "/")
+	if !ok || !found || server == "" || mediaID == "" {
+		return nil, fmt.Errorf("invalid media URI %q", mxcURI)
+	}
+	suffix := "/download/" + url.PathEscape(server) + "/" + url.PathEscape(mediaID)
+	var data []byte
+	err := c.do(ctx, http.MethodGet, "/_matrix/client/v1/media"+suffix, "", nil, &data)
+	var apiErr *matrixAPIError
+	if errors.As(err, &apiErr) && ((apiErr.Status == http.StatusNotFound && apiErr.ErrCode == "M_UNRECOGNIZED") || apiErr.Status == http.StatusMethodNotAllowed) {
+		err = c.do(ctx, http.MethodGet, "/_matrix/media/v3"+suffix, "", nil, &data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxChatAttachmentBytes {
+		return nil, fmt.Errorf("file exceeds %d bytes", maxChatAttachmentBytes)
+	}
+	return data, nil
+}
@@ -0,0 +1,616 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/llm"
+	runpkg "github.com/samsaffron/term-llm/internal/run"
+	"github.com/samsaffron/term-llm/internal/session"
+	"github.com/samsaffron/term-llm/internal/testutil"
+	"github.com/samsaffron/term-llm/internal/tools"
+)
+
+const matrixTestBot = "@bot:example.org"
+
+type matrixSent struct {
+	roomID    string
+	eventType string
+	eventID   string
+	content   matrixMessageContent
+	raw       json.RawMessage
+}
+
+// fakeHomeserver is a minimal client-server API stub: /sync serves queued
+// responses, and sends, joins and uploads are recorded.
+type fakeHomeserver struct {
+	t     *testing.T
+	srv   *httptest.Server
+	syncs chan matrixSyncResponse
+	media map[string][]byte
+
+	mu      sync.Mutex
+	sent    []matrixSent
+	joined  []string
+	uploads []string
+	nextID  int
+	batch   int
+}
+
+func newFakeHomeserver(t *testing.T) *fakeHomeserver {
+	t.Helper()
+	hs := &fakeHomeserver{t: t, syncs: make(chan matrixSyncResponse, 8), media: map[string][]byte{}}
+	hs.srv = httptest.NewServer(http.HandlerFunc(hs.serve))
+	t.Cleanup(hs.srv.Close)
+	return hs
+}
+
+func (hs *fakeHomeserver) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer tok" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, `{"errcode":"M_UNKNOWN_TOKEN","error":"bad token"}`)
+		return
+	}
+	path := r.URL.Path
+	reply := func(v any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	switch {
+	case path == "/_matrix/client/v3/account/whoami":
+		reply(map[string]string{"user_id": matrixTestBot})
+	case strings.HasPrefix(path, "/_matrix/client/v3/profile/"):
+		reply(map[string]string{"displayname": "Bot"})
+	case path == "/_matrix/client/v3/sync":
+		var resp matrixSyncResponse
+		if r.URL.Query().Get("since") != "" {
+			select {
+			case resp = <-hs.syncs:
+			case <-r.Context().Done():
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+		hs.mu.Lock()
+		hs.batch++
+		resp.NextBatch = fmt.Sprintf("s%d", hs.batch)
+		hs.mu.Unlock()
+		reply(resp)
+	case strings.HasPrefix(path, "/_matrix/client/v3/join/"):
+		hs.mu.Lock()
+		hs.joined = append(hs.joined, strings.TrimPrefix(path, "/_matrix/client/v3/join/"))
+		hs.mu.Unlock()
+		reply(map[string]string{})
+	case strings.HasPrefix(path, "/_matrix/client/v3/rooms/"):
+		parts := strings.Split(strings.TrimPrefix(path, "/_matrix/client/v3/rooms/"), "/")
+		if len(parts) != 4 || parts[1] != "send" || r.Method != http.MethodPut {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var content matrixMessageContent
+		_ = json.Unmarshal(body, &content)
+		hs.mu.Lock()
+		hs.nextID++
+		id := fmt.Sprintf("$ev%d", hs.nextID)
+		hs.sent = append(hs.sent, matrixSent{roomID: parts[0], eventType: parts[2], eventID: id, content: content, raw: body})
+		hs.mu.Unlock()
+		reply(map[string]string{"event_id": id})
+	case path == "/_matrix/media/v3/upload":
+		hs.mu.Lock()
+		hs.uploads = append(hs.uploads, r.URL.Query().Get("filename"))
+		hs.mu.Unlock()
+		reply(map[string]string{"content_uri": "mxc://example.org/uploaded"})
+	case strings.HasPrefix(path, "/_matrix/client/v1/media/download/"):
+		data, ok := hs.media[strings.TrimPrefix(path, "/_matrix/client/v1/media/download/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// messages returns the m.room.message events sent to roomID.
+func (hs *fakeHomeserver) messages(roomID string) []matrixSent {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	var out []matrixSent
+	for _, s := range hs.sent {
+		if s.roomID == roomID && s.eventType == matrixEventMessage {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// lastBody returns the current text of the bot's latest message in roomID,
+// following edits.
+func (hs *fakeHomeserver) lastBody(roomID string) string {
+	msgs := hs.messages(roomID)
+	if len(msgs) == 0 {
+		return ""
+	}
+	last := msgs[len(msgs)-1]
+	if last.content.NewContent != nil {
+		return last.content.NewContent.Body
+	}
+	return last.content.Body
+}
+
+func matrixTimeline(t *testing.T, roomID string, members int, events ...matrixEvent) matrixSyncResponse {
+	t.Helper()
+	var resp matrixSyncResponse
+	room := matrixJoinedRoom{}
+	room.Summary.JoinedMemberCount = &members
+	room.Timeline.Events = events
+	resp.Rooms.Join = map[string]matrixJoinedRoom{roomID: room}
+	return resp
+}
+
+func matrixMessage(t *testing.T, id, sender string, content matrixMessageContent) matrixEvent {
+	t.Helper()
+	raw, err := json.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matrixEvent{Type: matrixEventMessage, EventID: id, Sender: sender, Content: raw}
+}
+
+func newTestMatrixMgr(t *testing.T, hs *fakeHomeserver, h *testutil.EngineHarness, cfg config.MatrixServeConfig) *matrixSessionMgr {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if cfg.AllowedUserIDs == nil {
+		cfg.AllowedUserIDs = []string{"@alice:example.org"}
+	}
+	mgr := newMatrixSessionMgr(ctx, newMatrixClient(hs.srv.URL, "tok"), Settings{
+		MaxTurns: 5,
+		NewSession: func(context.Context) (*SessionRuntime, error) {
+			return &SessionRuntime{Engine: h.Engine, ProviderName: "mock", ModelName: "test"}, nil
+		},
+	}, matrixTestBot, "Bot", cfg)
+	mgr.editInterval = 5 * time.Millisecond
+	return mgr
+}
+
+func TestMatrixDirectMessageStreamsReplyViaEdits(t *testing.T) {
+	hs := newFakeHomeserver(t)
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("Hello **there**")
+
+	p := NewMatrixPlatform(config.MatrixServeConfig{
+		HomeserverURL: hs.srv.URL, AccessToken: "tok", AllowedUserIDs: []string{"@alice:example.org"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Run(ctx, nil, Settings{
+			MaxTurns: 5,
+			NewSession: func(context.Context) (*SessionRuntime, error) {
+				return &SessionRuntime{Engine: h.Engine, ProviderName: "mock", ModelName: "test"}, nil
+			},
+		})
+	}()
+
+	hs.syncs <- matrixTimeline(t, "!dm:example.org", 2,
+		matrixMessage(t, "$m1", "@alice:example.org", matrixMessageContent{MsgType: matrixMsgText, Body: "say hi"}))
+	waitFor(t, "final edit", func() bool { return hs.lastBody("!dm:example.org") == "Hello **there**" })
+
+	msgs := hs.messages("!dm:example.org")
+	if msgs[0].content.Body != "⏳" || msgs[0].content.RelatesTo != nil {
+		t.Fatalf("first message = %#v, want an unthreaded placeholder", msgs[0].content)
+	}
+	last := msgs[len(msgs)-1].content
+	if last.RelatesTo == nil || last.RelatesTo.RelType != matrixRelReplace || last.RelatesTo.EventID != msgs[0].eventID {
+		t.Fatalf("last relation = %#v, want m.replace of the placeholder", last.RelatesTo)
+	}
+	if last.Body != "* Hello **there**" || !strings.Contains(last.NewContent.FormattedBody, "<strong>there</strong>") {
+		t.Fatalf("edit = %#v, want fallback body and HTML new content", last)
+	}
+	if !strings.Contains(string(msgs[0].raw), `"m.mentions":{}`) {
+		t.Fatalf("placeholder = %s, want empty m.mentions", msgs[0].raw)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestMatrixInitialSyncSkipsBacklog(t *testing.T) {
+	hs := newFakeHomeserver(t)
+	mgr := newTestMatrixMgr(t, hs, testutil.NewEngineHarness(), config.MatrixServeConfig{})
+	mgr.processSync(ptr(matrixTimeline(t, "!dm:example.org", 2,
+		matrixMessage(t, "$old", "@alice:example.org", matrixMessageContent{MsgType: matrixMsgText, Body: "old"}))), true)
+	time.Sleep(50 * time.Millisecond)
+	if got := hs.messages("!dm:example.org"); len(got) != 0 {
+		t.Fatalf("sent %d messages for the initial sync, want 0", len(got))
+	}
+	if !mgr.isDirect("!dm:example.org") {
+		t.Fatal("initial sync should still record the member count")
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestMatrixMentionStartsThreadAndFollowUpNeedsNoMention(t *testing.T) {
+	hs := newFakeHomeserver(t)
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("First")
+	h.Provider.AddTextResponse("Second")
+	mgr := newTestMatrixMgr(t, hs, h, config.MatrixServeConfig{})
+	room := "!team:example.org"
+
+	mgr.processSync(ptr(matrixTimeline(t, room, 5,
+		matrixMessage(t, "$q1", "@alice:example.org", matrixMessageContent{
+			MsgType: matrixMsgText, Body: "Bot: what's up?", Mentions: &matrixMentions{UserIDs: []string{matrixTestBot}},
+		}))), false)
+	waitFor(t, "thread reply", func() bool { return hs.lastBody(room) == "First" })
+	placeholder := hs.messages(room)[0].content
+	if rel := placeholder.RelatesTo; rel == nil || rel.RelType != matrixRelThread || rel.EventID != "$q1" || rel.InReplyTo == nil || rel.InReplyTo.EventID != "$q1" {
+		t.Fatalf("placeholder relation = %#v, want thread rooted at $q1", rel)
+	}
+
+	// Unaddressed room chatter is ignored; the thread follow-up is not.
+	mgr.processSync(ptr(matrixTimeline(t, room, 5,
+		matrixMessage(t, "$chat", "@alice:example.org", matrixMessageContent{MsgType: matrixMsgText, Body: "lunch?"}),
+		matrixMessage(t, "$q2", "@alice:example.org", matrixMessageContent{
+			MsgType: matrixMsgText, Body: "and now?", RelatesTo: &matrixRelatesTo{RelType: matrixRelThread, EventID: "$q1"},
+		}))), false)
+	waitFor(t, "follow-up reply", func() bool { return hs.lastBody(room) == "Second" })
+
+	reqs := h.Provider.RecordedRequests()
+	if len(reqs) != 2 {
+		t.Fatalf("provider requests = %d, want 2", len(reqs))
+	}
+	var users []string
+	for _, msg := range reqs[1].Messages {
+		if msg.Role == llm.RoleUser {
+			users = append(users, msg.Parts[0].Text)
+		}
+	}
+	if strings.Join(users, "|") != "what's up?|and now?" {
+		t.Fatalf("user messages = %q, want mention stripped and history kept", users)
+	}
+}
+
+func TestMatrixRoomScopeRepliesInRoom(t *testing.T) {
+	hs := newFakeHomeserver(t)
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("ok")
+	mgr := newTestMatrixMgr(t, hs, h, config.MatrixServeConfig{SessionScope: "room"})
+	room := "!team:example.org"
+
+	mgr.processSync(ptr(matrixTimeline(t, room, 5,
+		matrixMessage(t, "$q1", "@alice:example.org", matrixMessageContent{
+			MsgType: matrixMsgText, Body: "@bot:example.org ping",
+		}))), false)
+	waitFor(t, "reply", func() bool { return hs.lastBody(room) == "ok" })
+	rel := hs.messages(room)[0].content.RelatesTo
+	if rel == nil || rel.RelType != "" || rel.InReplyTo == nil || rel.InReplyTo.EventID != "$q1" {
+		t.Fatalf("placeholder relation = %#v, want a plain reply to $q1", rel)
+	}
+}
+
+func TestMatrixAllowlist(t *testing.T) {
+	mgr := &matrixSessionMgr{
+		allowedUsers: []string{"@alice:example.org", ":corp.example"},
+		allowedRooms: map[string]struct{}{"!ok:example.org": {}},
+	}
+	for user, want := range map[string]bool{
+		"@alice:example.org":    true,
+		"@bob:example.org":      false,
+		"@carol:corp.example":   true,
+		"@eve:evilcorp.example": false,
+	} {
+		if got := mgr.isAllowed(user); got != want {
+			t.Errorf("isAllowed(%q) = %v, want %v", user, got, want)
+		}
+	}
+	if !mgr.isAllowedRoom("!ok:example.org") || mgr.isAllowedRoom("!other:example.org") {
+		t.Error("isAllowedRoom should only accept listed rooms")
+	}
+}
+
+func TestMatrixIgnoresUnauthorisedAndOwnMessages(t *testing.T) {
+	hs := newFakeHomeserver(t)
+	mgr := newTestMatrixMgr(t, hs, testutil.NewEngineHarness(), config.MatrixServeConfig{})
+	mgr.processSync(ptr(matrixTimeline(t, "!dm:example.org", 2,
+		matrixMessage(t, "$m1", "@mallory:example.org", matrixMessageContent{MsgType: matrixMsgText, Body: "hi"}),
+		matrixMessage(t, "$m2", matrixTestBot, matrixMessageContent{MsgType: matrixMsgText, Body: "echo"}),
+		matrixMessage(t, "$m3", "@alice:example.org", matrixMessageContent{MsgType: matrixMsgNotice, Body: "bot notice"}),
+	)), false)
+	time.Sleep(50 * time.Millisecond)
+	if got := hs.messages("!dm:example.org"); len(got) != 0 {
+		t.Fatalf("sent %#v, want nothing", got)
+	}
+}
+
+func TestMatrixAcceptsInvitesFromAllowedUsers(t *testing.T) {
+	hs := newFakeHomeserver(t)
+	mgr := newTestMatrixMgr(t, hs, testutil.NewEngineHarness(), config.MatrixServeConfig{})
+	invite := func(inviter string) []matrixEvent {
+		key := matrixTestBot
+		return []matrixEvent{{Type: matrixEventMember, Sender: inviter, StateKey: &key, Content: json.RawMessage(`{"membership":"invite"}`)}}
+	}
+	var resp matrixSyncResponse
+	resp.Rooms.Invite = map[string]matrixInvitedRoom{
+		"!good:example.org": {InviteState: struct {
+			Events []matrixEvent `json:"events"`
+		}{Events: invite("@alice:example.org")}},
+		"!bad:example.org": {InviteState: struct {
+			Events []matrixEvent `json:"events"`
+		}{Events: invite("@mallory:example.org")}},
+	}
+	mgr.processSync(&resp, true)
+	waitFor(t, "join", func() bool {
+		hs.mu.Lock()
+		defer hs.mu.Unlock()
+		return len(hs.joined) > 0
+	})
+	time.Sleep(20 * time.Millisecond)
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if len(hs.joined) != 1 || hs.joined[0] != "!good:example.org" {
+		t.Fatalf("joined = %q, want only the allowed inviter's room", hs.joined)
+	}
+}
+
+func TestMatrixApprovalReaction(t *testing.T) {
+	hs := newFakeHomeserver(t)
+	mgr := newTestMatrixMgr(t, hs, testutil.NewEngineHarness(), config.MatrixServeConfig{})
+	sess := &matrixSession{conv: matrixConversation{roomID: "!dm:example.org"}}
+	req := ApprovalRequest{
+		Title:  "Allow shell command?",
+		Target: "rm -rf build",
+		Options: []tools.ApprovalOption{
+			{Label: "Allow once", Choice: tools.ApprovalChoiceOnce},
+			{Label: "Deny", Choice: tools.ApprovalChoiceDeny},
+		},
+	}
+	result := make(chan int, 1)
+	go func() {
+		choice, err := mgr.requestApproval(sess, req)
+		if err != nil {
+			t.Errorf("requestApproval: %v", err)
+		}
+		result <- choice
+	}()
+
+	var promptID string
+	waitFor(t, "seeded reactions", func() bool {
+		hs.mu.Lock()
+		defer hs.mu.Unlock()
+		reactions := 0
+		for _, s := range hs.sent {
+			if s.eventType == matrixEventReaction {
+				reactions++
+			} else if promptID == "" {
+				promptID = s.eventID
+			}
+		}
+		return reactions == 2
+	})
+	if prompt := hs.messages("!dm:example.org")[0].content.Body; !strings.Contains(prompt, "2️⃣ Deny") {
+		t.Fatalf("prompt = %q, want numbered options", prompt)
+	}
+
+	react := func(sender, key string) matrixEvent {
+		raw, _ := json.Marshal(map[string]any{"m.relates_to": matrixRelatesTo{RelType: matrixRelAnnotation, EventID: promptID, Key: key}})
+		return matrixEvent{Type: matrixEventReaction, Sender: sender, RoomID: "!dm:example.org", Content: raw}
+	}
+	mgr.dispatch(react("@mallory:example.org", "1️⃣"))
+	mgr.dispatch(react("@alice:example.org", "2️⃣"))
+
+	select {
+	case choice := <-result:
+		if choice != 1 {
+			t.Fatalf("choice = %d, want 1 (Deny)", choice)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("approval was not resolved")
+	}
+	waitFor(t, "resolved prompt", func() bool {
+		return strings.Contains(hs.lastBody("!dm:example.org"), "➜ **Deny** by @alice:example.org")
+	})
+}
+
+func TestMatrixAttachmentDownloadedAndInlined(t *testing.T) {
+	hs := newFakeHomeserver(t)
+	hs.media["example.org/notes"] = []byte("line one\nline two\n")
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("read it")
+	mgr := newTestMatrixMgr(t, hs, h, config.MatrixServeConfig{})
+
+	mgr.processSync(ptr(matrixTimeline(t, "!dm:example.org", 2,
+		matrixMessage(t, "$f1", "@alice:example.org", matrixMessageContent{
+			MsgType: matrixMsgFile, Body: "summarise this", Filename: "notes.txt", URL: "mxc://example.org/notes",
+			Info: &matrixMediaInfo{MimeType: "text/plain", Size: 18},
+		}))), false)
+	waitFor(t, "reply", func() bool { return hs.lastBody("!dm:example.org") == "read it" })
+
+	reqs := h.Provider.RecordedRequests()
+	msgs := reqs[0].Messages
+	var text string
+	for _, part := range msgs[len(msgs)-1].Parts {
+		text += part.Text
+	}
+	if !strings.Contains(text, "summarise this") || !strings.Contains(text, "line two") {
+		t.Fatalf("user message = %q, want caption and inlined file", text)
+	}
+}
+
+func TestMatrixSendImageUploadsMedia(t *testing.T) {
+	hs := newFakeHomeserver(t)
+	mgr := newTestMatrixMgr(t, hs, testutil.NewEngineHarness(), config.MatrixServeConfig{})
+	path := filepath.Join(t.TempDir(), "chart.png")
+	if err := os.WriteFile(path, []byte("\x89PNG"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.sendImage(context.Background(), "!dm:example.org", nil, path); err != nil {
+		t.Fatal(err)
+	}
+	msgs := hs.messages("!dm:example.org")
+	if len(hs.uploads) != 1 || hs.uploads[0] != "chart.png" {
+		t.Fatalf("uploads = %q, want chart.png", hs.uploads)
+	}
+	if len(msgs) != 1 || msgs[0].content.MsgType != matrixMsgImage || msgs[0].content.URL != "mxc://example.org/uploaded" {
+		t.Fatalf("messages = %#v, want one m.image with the uploaded URI", msgs)
+	}
+}
+
+func TestMatrixIdleSessionReplacedWithCarryover(t *testing.T) {
+	hs := newFakeHomeserver(t)
+	store, err := session.NewStore(session.Config{Enabled: true, Path: filepath.Join(t.TempDir(), "matrix.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("the answer is 42")
+	h.Provider.AddTextResponse("still 42")
+	mgr := newTestMatrixMgr(t, hs, h, config.MatrixServeConfig{})
+	mgr.settings.Store = store
+	mgr.settings.CarryoverChars = 1000
+	mgr.store = store
+	room := "!dm:example.org"
+
+	mgr.processSync(ptr(matrixTimeline(t, room, 2,
+		matrixMessage(t, "$m1", "@alice:example.org", matrixMessageContent{MsgType: matrixMsgText, Body: "what is the answer?"}))), false)
+	waitFor(t, "first reply", func() bool { return hs.lastBody(room) == "the answer is 42" })
+	mgr.mu.Lock()
+	first := mgr.sessions[room]
+	first.lastActivity = time.Now().Add(-time.Hour)
+	mgr.mu.Unlock()
+
+	mgr.processSync(ptr(matrixTimeline(t, room, 2,
+		matrixMessage(t, "$m2", "@alice:example.org", matrixMessageContent{MsgType: matrixMsgText, Body: "sure?"}))), false)
+	waitFor(t, "second reply", func() bool { return hs.lastBody(room) == "still 42" })
+
+	mgr.mu.Lock()
+	second := mgr.sessions[room]
+	mgr.mu.Unlock()
+	if second == first || second.meta.ID == first.meta.ID {
+		t.Fatal("idle session was not replaced")
+	}
+	reqs := h.Provider.RecordedRequests()
+	var sawOld bool
+	for _, msg := range reqs[1].Messages {
+		for _, part := range msg.Parts {
+			if strings.Contains(part.Text, "the answer is 42") {
+				sawOld = true
+			}
+		}
+	}
+	if !sawOld {
+		t.Fatal("replacement session should carry over the previous reply")
+	}
+	stored, err := store.GetMessages(context.Background(), second.meta.ID, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("replacement session stored %d messages, want only the new turn", len(stored))
+	}
+}
+
+// matrixStubRunner satisfies runpkg.Runner for tests that never start a turn.
+type matrixStubRunner struct{}
+
+func (matrixStubRunner) Run(context.Context, runpkg.Request, runpkg.EventSink) (runpkg.Result, error) {
+	return runpkg.Result{}, nil
+}
+
+func TestMatrixRoomAgentUsesRunner(t *testing.T) {
+	hs := newFakeHomeserver(t)
+	mgr := newTestMatrixMgr(t, hs, testutil.NewEngineHarness(), config.MatrixServeConfig{
+		RoomAgents: []config.MatrixRoomAgent{{Room: "!ops:example.org", Agent: "ops"}},
+	})
+	mgr.settings.Agent = "default"
+	mgr.settings.SystemPrompt = "default prompt"
+	mgr.settings.Runner = matrixStubRunner{}
+
+	ops, err := mgr.newSession(context.Background(), matrixConversation{roomID: "!ops:example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ops.agent != "ops" || ops.settings.Agent != "ops" || ops.settings.SystemPrompt != "" || ops.runtime.Engine != nil {
+		t.Fatalf("ops session = agent %q settings %q/%q, want the runner to build the ops agent", ops.agent, ops.settings.Agent, ops.settings.SystemPrompt)
+	}
+	other, err := mgr.newSession(context.Background(), matrixConversation{roomID: "!dm:example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.agent != "default" || other.runtime.Engine == nil {
+		t.Fatalf("default session = agent %q engine %v, want the serve runtime", other.agent, other.runtime.Engine)
+	}
+}
+
+func TestMatrixClientRetriesRateLimit(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = io.WriteString(w, `{"errcode":"M_LIMIT_EXCEEDED","retry_after_ms":10}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"event_id":"$ok"}`)
+	}))
+	defer srv.Close()
+	id, err := newMatrixClient(srv.URL, "tok").sendEvent(context.Background(), "!r:example.org", matrixEventMessage, map[string]string{"body": "x"})
+	if err != nil || id != "$ok" || calls.Load() != 2 {
+		t.Fatalf("sendEvent = %q, %v after %d calls, want $ok after a retry", id, err, calls.Load())
+	}
+}
+
+func TestMatrixRunRejectsBadToken(t *testing.T) {
+	hs := newFakeHomeserver(t)
+	p := NewMatrixPlatform(config.MatrixServeConfig{HomeserverURL: hs.srv.URL, AccessToken: "wrong"})
+	if err := p.Run(context.Background(), nil, Settings{}); err == nil || !strings.Contains(err.Error(), "M_UNKNOWN_TOKEN") {
+		t.Fatalf("Run = %v, want M_UNKNOWN_TOKEN error", err)
+	}
+}
+
+func TestMatrixStripMention(t *testing.T) {
+	mgr := &matrixSessionMgr{userID: matrixTestBot, displayName: "Bot"}
+	for in, want := range map[string]string{
+		"bot: hello":                           "hello",
+		"@bot:example.org: do it":              "do it",
+		"> <@alice:example.org> earlier\n\nok": "ok",
+		"robot: leave me":                      "robot: leave me",
+	} {
+		if got := mgr.stripMention(in); got != want {
+			t.Errorf("stripMention(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMatrixPlatformNeedsSetup(t *testing.T) {
+	if !NewMatrixPlatform(config.MatrixServeConfig{HomeserverURL: "https://m.example.org"}).NeedsSetup() {
+		t.Error("NeedsSetup should be true without an access token")
+	}
+	if NewMatrixPlatform(config.MatrixServeConfig{HomeserverURL: "https://m.example.org", AccessToken: "t"}).NeedsSetup() {
+		t.Error("NeedsSetup should be false with homeserver and token")
+	}
+}
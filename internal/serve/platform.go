@@ -9,6 +9,7 @@ import (
 	"github.com/samsaffron/term-llm/internal/llm"
 	runpkg "github.com/samsaffron/term-llm/internal/run"
 	"github.com/samsaffron/term-llm/internal/session"
+	"github.com/samsaffron/term-llm/internal/tools"
 )
 
 // SessionRuntime is a per-conversation runtime for non-web platforms.
@@ -18,20 +19,38 @@ type SessionRuntime struct {
 	ProviderName string
 	ModelName    string
 	Cleanup      func()
+	// SetApprovalHandler routes this runtime's tool approval prompts to the
+	// platform. Nil when the runtime never prompts (yolo mode).
+	SetApprovalHandler func(ApprovalHandler)
 }
 
+// ApprovalRequest is a tool approval prompt a platform puts to its user.
+type ApprovalRequest struct {
+	Title   string
+	Target  string // file path or shell command
+	WorkDir string // shell working directory, when known
+	IsShell bool
+	IsWrite bool
+	Options []tools.ApprovalOption
+}
+
+// ApprovalHandler presents req and blocks until the user picks an option.
+// It returns the chosen index into req.Options, or -1 when the prompt was
+// dismissed.
+type ApprovalHandler func(req ApprovalRequest) (int, error)
+
 // Settings holds per-platform runtime settings derived from CLI flags and config.
 type Settings struct {
 	SystemPrompt string
 	IdleTimeout  time.Duration
-	// TelegramCarryoverChars controls how many trailing characters from the
-	// previous Telegram session are carried into a replacement session.
-	// 0 disables carry-over.
-	TelegramCarryoverChars int
-	MaxTurns               int
-	Debug                  bool
-	DebugRaw               bool
-	Search                 bool
+	// CarryoverChars controls how many trailing characters from a chat's
+	// previous session are carried into a replacement session (Telegram,
+	// Matrix). 0 disables carry-over.
+	CarryoverChars int
+	MaxTurns       int
+	Debug          bool
+	DebugRaw       bool
+	Search         bool
 	// ForceExternalSearch disables provider-native search and uses external
 	// web_search/read_url tools instead when search is enabled.
 	ForceExternalSearch bool
@@ -0,0 +1,1187 @@
+package serve
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/llm"
+	runpkg "github.com/samsaffron/term-llm/internal/run"
+	"github.com/samsaffron/term-llm/internal/session"
+	"github.com/samsaffron/term-llm/internal/tools"
+)
+
+const (
+	slackDefaultEventsListen = "127.0.0.1:3000"
+	slackEventsPath          = "/slack/events"
+
+	// slackMaxMessageRunes keeps each reply message comfortably below the
+	// point where Slack truncates long messages behind "Show more".
+	slackMaxMessageRunes = 3500
+	// slackMinEditInterval respects chat.update's Tier 3 rate limit
+	// (roughly 50 calls per minute per workspace).
+	slackMinEditInterval = 1200 * time.Millisecond
+
+	slackMaxConcurrentHandlers = 16
+	slackEventDedupWindow      = 10 * time.Minute
+	slackFinalDeliveryTimeout  = 30 * time.Second
+)
+
+// SlackPlatform implements Platform for a Slack app bot.
+type SlackPlatform struct {
+	cfg config.SlackServeConfig
+}
+
+// NewSlackPlatform creates a new SlackPlatform with the given config.
+func NewSlackPlatform(cfg config.SlackServeConfig) *SlackPlatform {
+	return &SlackPlatform{cfg: cfg}
+}
+
+func (p *SlackPlatform) Name() string { return "slack" }
+
+// NeedsSetup returns true when the bot token or a transport credential
+// (Socket Mode app token or Events API signing secret) is missing.
+func (p *SlackPlatform) NeedsSetup() bool {
+	if strings.TrimSpace(p.cfg.BotToken) == "" {
+		return true
+	}
+	return strings.TrimSpace(p.cfg.AppToken) == "" && strings.TrimSpace(p.cfg.SigningSecret) == ""
+}
+
+// RunSetup runs an interactive wizard that collects and persists app credentials.
+func (p *SlackPlatform) RunSetup() error {
+	scanner := bufio.NewScanner(os.Stdin)
+	prompt := func(label string) (string, error) {
+		fmt.Print(label)
+		if !scanner.Scan() {
+			return "", fmt.Errorf("no input received")
+		}
+		return strings.TrimSpace(scanner.Text()), nil
+	}
+
+	fmt.Println()
+	fmt.Println("Slack App Setup")
+	fmt.Println("===============")
+	fmt.Println()
+	fmt.Println("1. Create an app at https://api.slack.com/apps, add the bot scopes")
+	fmt.Println("   app_mentions:read, chat:write, im:history, channels:history, files:read, files:write")
+	fmt.Println("   and install it to your workspace. Copy the Bot User OAuth Token.")
+	botToken, err := prompt("   Bot token (xoxb-...): ")
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(botToken, "xoxb-") {
+		return fmt.Errorf("bot token must start with xoxb-")
+	}
+
+	fmt.Println()
+	fmt.Println("2. For Socket Mode (no public URL needed), enable it under Socket Mode and")
+	fmt.Println("   create an app-level token with connections:write. Leave blank to use the")
+	fmt.Println("   Events API over HTTP instead.")
+	appToken, err := prompt("   App token (xapp-..., optional): ")
+	if err != nil {
+		return err
+	}
+	if appToken != "" && !strings.HasPrefix(appToken, "xapp-") {
+		return fmt.Errorf("app token must start with xapp-")
+	}
+
+	signingSecret := ""
+	if appToken == "" {
+		fmt.Println()
+		fmt.Printf("   Events API: point Event Subscriptions and Interactivity at\n   https://<your-host>%s and copy the Signing Secret from Basic Information.\n", slackEventsPath)
+		signingSecret, err = prompt("   Signing secret: ")
+		if err != nil {
+			return err
+		}
+		if signingSecret == "" {
+			return fmt.Errorf("a signing secret is required without an app token")
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("3. Whitelist Slack member IDs (profile → ⋮ → Copy member ID, e.g. U012AB3CD)")
+	rawIDs, err := prompt("   Allowed user IDs (comma-separated, required): ")
+	if err != nil {
+		return err
+	}
+	var userIDs []string
+	for _, part := range strings.Split(rawIDs, ",") {
+		if id := strings.ToUpper(strings.TrimSpace(part)); id != "" {
+			userIDs = append(userIDs, id)
+		}
+	}
+	if len(userIDs) == 0 {
+		return fmt.Errorf("at least one user ID is required")
+	}
+
+	newCfg := p.cfg
+	newCfg.BotToken = botToken
+	newCfg.AppToken = appToken
+	newCfg.SigningSecret = signingSecret
+	newCfg.AllowedUserIDs = userIDs
+
+	if err := config.SetServeSlackConfig(newCfg); err != nil {
+		return fmt.Errorf("save slack config: %w", err)
+	}
+
+	p.cfg = newCfg
+	fmt.Println()
+	fmt.Println("Slack configuration saved.")
+	return nil
+}
+
+// Run connects to Slack and serves messages, blocking until ctx is cancelled.
+func (p *SlackPlatform) Run(ctx context.Context, cfg *config.Config, settings Settings) error {
+	botToken := strings.TrimSpace(p.cfg.BotToken)
+	appToken := strings.TrimSpace(p.cfg.AppToken)
+	signingSecret := strings.TrimSpace(p.cfg.SigningSecret)
+	if botToken == "" {
+		return fmt.Errorf("slack bot token is not configured; run with --setup to configure")
+	}
+	if appToken == "" && signingSecret == "" {
+		return fmt.Errorf("slack needs app_token (Socket Mode) or signing_secret (Events API); run with --setup to configure")
+	}
+	if len(p.cfg.AllowedUserIDs) == 0 {
+		log.Println("[slack] warning: no allowed_user_ids configured; all messages will be rejected")
+	}
+
+	client := newSlackClient(p.cfg.APIURL, botToken, appToken)
+	botUserID, _, err := client.authTest(ctx)
+	if err != nil {
+		return fmt.Errorf("slack connect: %w", err)
+	}
+	log.Printf("[slack] authorised as <@%s>", botUserID)
+
+	idleTimeout := settings.IdleTimeout
+	if idleTimeout <= 0 {
+		if p.cfg.IdleTimeout > 0 {
+			idleTimeout = time.Duration(p.cfg.IdleTimeout) * time.Minute
+		} else {
+			idleTimeout = 30 * time.Minute
+		}
+	}
+
+	mgr := newSlackSessionMgr(ctx, client, cfg, settings, botUserID, p.cfg)
+	mgr.idleTimeout = idleTimeout
+	go mgr.reapIdleSessions(ctx)
+	defer mgr.closeAllSessions()
+
+	if appToken != "" {
+		return runSlackSocketMode(ctx, client, mgr.dispatch)
+	}
+
+	listen := strings.TrimSpace(p.cfg.EventsListen)
+	if listen == "" {
+		listen = slackDefaultEventsListen
+	}
+	mux := http.NewServeMux()
+	mux.Handle(slackEventsPath, slackEventsHandler(signingSecret, mgr.dispatch))
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	stop := context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	})
+	defer stop()
+	log.Printf("[slack] Events API listening on http://%s%s", listen, slackEventsPath)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("slack events listener: %w", err)
+	}
+	return nil
+}
+
+// slackEvent is the subset of a message or app_mention event we consume.
+type slackEvent struct {
+	Type        string      `json:"type"`
+	Subtype     string      `json:"subtype"`
+	Channel     string      `json:"channel"`
+	ChannelType string      `json:"channel_type"`
+	User        string      `json:"user"`
+	BotID       string      `json:"bot_id"`
+	Text        string      `json:"text"`
+	TS          string      `json:"ts"`
+	ThreadTS    string      `json:"thread_ts"`
+	Files       []slackFile `json:"files"`
+}
+
+type slackFile struct {
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	Mimetype           string `json:"mimetype"`
+	Size               int64  `json:"size"`
+	URLPrivateDownload string `json:"url_private_download"`
+}
+
+// slackSession holds one thread's conversation state.
+type slackSession struct {
+	mu       sync.Mutex // held for a whole turn so messages in one thread run in order
+	key      string
+	channel  string
+	threadTS string // "" for top-level direct messages
+	runtime  *SessionRuntime
+	history  []llm.Message
+	meta     *session.Session
+
+	// Guarded by slackSessionMgr.mu.
+	lastActivity time.Time
+	turnCancel   context.CancelFunc
+	closed       bool
+}
+
+// slackPendingApproval is a tool approval waiting on a button press.
+type slackPendingApproval struct {
+	id      string
+	sessKey string
+	channel string
+	ts      string // the approval message, edited once answered
+	req     ApprovalRequest
+	answer  chan slackApprovalAnswer
+}
+
+type slackApprovalAnswer struct {
+	choice int
+	user   string
+}
+
+// slackSessionMgr manages per-thread sessions.
+type slackSessionMgr struct {
+	ctx       context.Context
+	api       *slackClient
+	cfg       *config.Config
+	settings  Settings
+	store     session.Store
+	botUserID string
+
+	idleTimeout     time.Duration
+	editInterval    time.Duration // 0 means use slackMinEditInterval; overridden in tests
+	allowedUsers    map[string]struct{}
+	allowedChannels map[string]struct{}
+	messageSlots    chan struct{}
+
+	mu         sync.Mutex
+	sessions   map[string]*slackSession
+	seenEvents map[string]time.Time
+
+	approvalMu sync.Mutex
+	approvals  map[string]*slackPendingApproval
+}
+
+func newSlackSessionMgr(ctx context.Context, api *slackClient, cfg *config.Config, settings Settings, botUserID string, slackCfg config.SlackServeConfig) *slackSessionMgr {
+	mgr := &slackSessionMgr{
+		ctx:             ctx,
+		api:             api,
+		cfg:             cfg,
+		settings:        settings,
+		store:           settings.Store,
+		botUserID:       botUserID,
+		idleTimeout:     30 * time.Minute,
+		allowedUsers:    make(map[string]struct{}, len(slackCfg.AllowedUserIDs)),
+		allowedChannels: make(map[string]struct{}, len(slackCfg.AllowedChannelIDs)),
+		messageSlots:    make(chan struct{}, slackMaxConcurrentHandlers),
+		sessions:        make(map[string]*slackSession),
+		seenEvents:      make(map[string]time.Time),
+		approvals:       make(map[string]*slackPendingApproval),
+	}
+	for _, id := range slackCfg.AllowedUserIDs {
+		mgr.allowedUsers[strings.ToUpper(strings.TrimSpace(id))] = struct{}{}
+	}
+	for _, id := range slackCfg.AllowedChannelIDs {
+		mgr.allowedChannels[strings.ToUpper(strings.TrimSpace(id))] = struct{}{}
+	}
+	return mgr
+}
+
+func (m *slackSessionMgr) isAllowedUser(userID string) bool {
+	_, ok := m.allowedUsers[strings.ToUpper(userID)]
+	return ok
+}
+
+// isAllowedChannel applies allowed_channel_ids to shared channels only;
+// direct messages are governed by the user allowlist alone.
+func (m *slackSessionMgr) isAllowedChannel(channel, channelType string) bool {
+	if len(m.allowedChannels) == 0 || channelType == "im" {
+		return true
+	}
+	_, ok := m.allowedChannels[strings.ToUpper(channel)]
+	return ok
+}
+
+func slackSessionKey(channel, threadTS string) string {
+	return channel + ":" + threadTS
+}
+
+// dispatch routes one inbound payload from either transport.
+func (m *slackSessionMgr) dispatch(kind string, payload json.RawMessage) any {
+	switch kind {
+	case slackKindEvents:
+		m.dispatchEvent(payload)
+	case slackKindInteractive:
+		m.handleInteraction(payload)
+	case slackKindSlash:
+		return m.handleSlashCommand(payload)
+	}
+	return nil
+}
+
+func (m *slackSessionMgr) dispatchEvent(payload json.RawMessage) {
+	var callback struct {
+		Type    string     `json:"type"`
+		EventID string     `json:"event_id"`
+		Event   slackEvent `json:"event"`
+	}
+	if err := json.Unmarshal(payload, &callback); err != nil {
+		log.Printf("[slack] invalid event payload: %v", err)
+		return
+	}
+	if callback.Type != "event_callback" || m.isDuplicateEvent(callback.EventID) {
+		return
+	}
+	ev := callback.Event
+	if !m.shouldHandle(ev) {
+		return
+	}
+	if !m.isAllowedUser(ev.User) {
+		log.Printf("[slack] ignoring message from unauthorised user %s", ev.User)
+		return
+	}
+	if !m.isAllowedChannel(ev.Channel, ev.ChannelType) {
+		log.Printf("[slack] ignoring message in channel %s (not in allowed_channel_ids)", ev.Channel)
+		return
+	}
+	go func() {
+		select {
+		case m.messageSlots <- struct{}{}:
+		case <-m.ctx.Done():
+			return
+		}
+		defer func() { <-m.messageSlots }()
+		m.handleMessage(m.ctx, ev)
+	}()
+}
+
+// isDuplicateEvent drops redeliveries of an event Slack already sent.
+func (m *slackSessionMgr) isDuplicateEvent(eventID string) bool {
+	if eventID == "" {
+		return false
+	}
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, seen := range m.seenEvents {
+		if now.Sub(seen) > slackEventDedupWindow {
+			delete(m.seenEvents, id)
+		}
+	}
+	if _, ok := m.seenEvents[eventID]; ok {
+		return true
+	}
+	m.seenEvents[eventID] = now
+	return false
+}
+
+// shouldHandle selects the events that start or continue a conversation:
+// direct messages, @-mentions, and follow-ups in a thread the bot is already
+// part of. Mentions also arrive as message events; only app_mention is used
+// for those so each is handled once.
+func (m *slackSessionMgr) shouldHandle(ev slackEvent) bool {
+	if ev.BotID != "" || ev.User == "" || ev.User == m.botUserID {
+		return false
+	}
+	if ev.Subtype != "" && ev.Subtype != "file_share" {
+		return false
+	}
+	switch ev.Type {
+	case "app_mention":
+		return ev.ChannelType != "im"
+	case "message":
+		if ev.ChannelType == "im" {
+			return true
+		}
+		if ev.ThreadTS == "" || m.mentionsBot(ev.Text) {
+			return false
+		}
+		m.mu.Lock()
+		_, ok := m.sessions[slackSessionKey(ev.Channel, ev.ThreadTS)]
+		m.mu.Unlock()
+		return ok
+	}
+	return false
+}
+
+func (m *slackSessionMgr) mentionsBot(text string) bool {
+	return m.botUserID != "" && strings.Contains(text, "<@"+m.botUserID+">")
+}
+
+// replyThread returns the thread a reply to ev belongs in. Top-level direct
+// messages form one running conversation; everything in a channel is
+// threaded under the message that started it.
+func replyThread(ev slackEvent) string {
+	if ev.ThreadTS != "" {
+		return ev.ThreadTS
+	}
+	if ev.ChannelType == "im" {
+		return ""
+	}
+	return ev.TS
+}
+
+func (m *slackSessionMgr) handleMessage(ctx context.Context, ev slackEvent) {
+	threadTS := replyThread(ev)
+	text := strings.TrimSpace(strings.ReplaceAll(ev.Text, "<@"+m.botUserID+">", ""))
+
+	userMsg, cleanup, err := m.buildUserMessage(ctx, text, ev.Files)
+	defer cleanup()
+	if err != nil {
+		log.Printf("[slack] failed to read attachments in %s: %v", ev.Channel, err)
+		m.postNotice(ctx, ev.Channel, threadTS, "Failed to process attachment: "+err.Error())
+		return
+	}
+	if len(userMsg.Parts) == 0 {
+		return
+	}
+
+	sess, err := m.getOrCreate(ctx, ev.Channel, threadTS)
+	if err != nil {
+		m.postNotice(ctx, ev.Channel, threadTS, "Error creating session: "+err.Error())
+		return
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if m.isClosed(sess) {
+		// Reset while this message was queued behind the previous turn.
+		if sess, err = m.getOrCreate(ctx, ev.Channel, threadTS); err != nil {
+			m.postNotice(ctx, ev.Channel, threadTS, "Error creating session: "+err.Error())
+			return
+		}
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+	}
+	if err := m.streamReply(ctx, sess, userMsg); err != nil {
+		log.Printf("[slack] reply failed in %s: %v", sess.key, err)
+		m.postNotice(ctx, ev.Channel, threadTS, "Error: "+err.Error())
+	}
+}
+
+func (m *slackSessionMgr) postNotice(ctx context.Context, channel, threadTS, text string) {
+	if _, err := m.api.postMessage(ctx, slackMessage{Channel: channel, ThreadTS: threadTS, Text: text}); err != nil {
+		log.Printf("[slack] post notice to %s: %v", channel, err)
+	}
+}
+
+// buildUserMessage turns message text and shared files into one user turn.
+func (m *slackSessionMgr) buildUserMessage(ctx context.Context, text string, files []slackFile) (llm.Message, func(), error) {
+	attachments := make([]chatAttachment, 0, len(files))
+	for _, f := range files {
+		attachments = append(attachments, chatAttachment{Name: f.Name, MimeType: f.Mimetype, Size: f.Size, URL: f.URLPrivateDownload})
+	}
+	return buildAttachmentMessage(ctx, text, attachments, "slack-upload", m.api.downloadFile)
+}
+
+func (m *slackSessionMgr) getOrCreate(ctx context.Context, channel, threadTS string) (*slackSession, error) {
+	key := slackSessionKey(channel, threadTS)
+	m.mu.Lock()
+	if sess, ok := m.sessions[key]; ok {
+		sess.lastActivity = time.Now()
+		m.mu.Unlock()
+		return sess, nil
+	}
+	m.mu.Unlock()
+
+	created, err := m.newSession(ctx, channel, threadTS)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if existing, ok := m.sessions[key]; ok {
+		m.mu.Unlock()
+		closeSlackRuntime(created)
+		return existing, nil
+	}
+	m.sessions[key] = created
+	m.mu.Unlock()
+	return created, nil
+}
+
+// newSession creates a runtime for a thread, resuming the thread's stored
+// session when the bot restarted mid-conversation.
+func (m *slackSessionMgr) newSession(ctx context.Context, channel, threadTS string) (*slackSession, error) {
+	if m.settings.NewSession == nil {
+		return nil, fmt.Errorf("slack runtime factory is not configured")
+	}
+	runtime, err := m.settings.NewSession(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create runtime: %w", err)
+	}
+
+	key := slackSessionKey(channel, threadTS)
+	sess := &slackSession{
+		key:          key,
+		channel:      channel,
+		threadTS:     threadTS,
+		runtime:      runtime,
+		lastActivity: time.Now(),
+	}
+	if runtime.SetApprovalHandler != nil {
+		runtime.SetApprovalHandler(func(req ApprovalRequest) (int, error) {
+			return m.requestApproval(sess, req)
+		})
+	}
+
+	name := "slack:" + strings.TrimSuffix(key, ":")
+	if m.store != nil && m.resumeSession(ctx, sess, name) {
+		return sess, nil
+	}
+
+	providerName := strings.TrimSpace(runtime.ProviderName)
+	if providerName == "" {
+		providerName = "unknown"
+	}
+	modelName := strings.TrimSpace(runtime.ModelName)
+	if modelName == "" {
+		modelName = "unknown"
+	}
+	sess.meta = &session.Session{
+		ID:        session.NewID(),
+		Name:      name,
+		Provider:  providerName,
+		Model:     modelName,
+		Mode:      session.ModeChat,
+		Origin:    session.OriginSlack,
+		Agent:     m.settings.Agent,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		Search:    m.settings.Search,
+		Tools:     m.settings.Tools,
+		MCP:       m.settings.MCP,
+		Status:    session.StatusActive,
+	}
+	if cwd, cwdErr := os.Getwd(); cwdErr == nil {
+		sess.meta.CWD = cwd
+	}
+	if m.store != nil {
+		if err := m.store.Create(ctx, sess.meta); err != nil {
+			log.Printf("[slack] session Create failed for %s: %v", sess.meta.ID, err)
+		}
+	}
+	return sess, nil
+}
+
+func (m *slackSessionMgr) resumeSession(ctx context.Context, sess *slackSession, name string) bool {
+	summaries, err := m.store.List(ctx, session.ListOptions{Name: name, Limit: 1})
+	if err != nil || len(summaries) == 0 {
+		return false
+	}
+	meta, err := m.store.Get(ctx, summaries[0].ID)
+	if err != nil || meta == nil {
+		return false
+	}
+	stored, err := m.store.GetMessages(ctx, meta.ID, 0, 0)
+	if err != nil {
+		log.Printf("[slack] resume %s: get messages failed: %v", meta.ID, err)
+		return false
+	}
+	sess.meta = meta
+	for _, msg := range stored {
+		sess.history = append(sess.history, msg.ToLLMMessage())
+	}
+	log.Printf("[slack] resumed session %s (%d messages) for %s", meta.ID, len(sess.history), name)
+	return true
+}
+
+func (m *slackSessionMgr) isClosed(sess *slackSession) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sess.closed
+}
+
+// closeSession removes sess and cancels its active turn. The runtime is
+// released once the turn has unwound.
+func (m *slackSessionMgr) closeSession(sess *slackSession) {
+	m.mu.Lock()
+	if m.sessions[sess.key] == sess {
+		delete(m.sessions, sess.key)
+	}
+	sess.closed = true
+	if sess.turnCancel != nil {
+		sess.turnCancel()
+	}
+	m.mu.Unlock()
+	m.cancelApprovals(sess.key)
+
+	go func() {
+		sess.mu.Lock()
+		defer sess.mu.Unlock()
+		closeSlackRuntime(sess)
+	}()
+}
+
+func closeSlackRuntime(sess *slackSession) {
+	if sess.runtime != nil && sess.runtime.Cleanup != nil {
+		sess.runtime.Cleanup()
+	}
+	sess.runtime = nil
+}
+
+func (m *slackSessionMgr) closeAllSessions() {
+	m.mu.Lock()
+	sessions := make([]*slackSession, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		sessions = append(sessions, sess)
+	}
+	m.mu.Unlock()
+	for _, sess := range sessions {
+		m.closeSession(sess)
+	}
+}
+
+// reapIdleSessions closes thread sessions with no activity for idleTimeout.
+// History stays in the store, so a later reply in the thread resumes it.
+func (m *slackSessionMgr) reapIdleSessions(ctx context.Context) {
+	interval := min(m.idleTimeout/2, time.Minute)
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var idle []*slackSession
+		m.mu.Lock()
+		for _, sess := range m.sessions {
+			if sess.turnCancel == nil && time.Since(sess.lastActivity) > m.idleTimeout {
+				idle = append(idle, sess)
+			}
+		}
+		m.mu.Unlock()
+		for _, sess := range idle {
+			m.closeSession(sess)
+		}
+	}
+}
+
+// streamReply runs one turn and streams it into the thread by editing a
+// placeholder message. Replies that outgrow one message continue in a new
+// one. The caller holds sess.mu.
+func (m *slackSessionMgr) streamReply(ctx context.Context, sess *slackSession, userMsg llm.Message) error {
+	if sess.runtime == nil {
+		return fmt.Errorf("session is closed")
+	}
+	turnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m.mu.Lock()
+	sess.turnCancel = cancel
+	sess.lastActivity = time.Now()
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		sess.turnCancel = nil
+		sess.lastActivity = time.Now()
+		m.mu.Unlock()
+	}()
+
+	turn, err := startChatTurn(turnCtx, runpkg.PlatformSlack, m.settings, sess.runtime, sess.meta.ID, sess.history, userMsg)
+	if err != nil {
+		return err
+	}
+	defer turn.close()
+
+	reply := &slackReplyWriter{
+		api:          m.api,
+		channel:      sess.channel,
+		threadTS:     sess.threadTS,
+		editInterval: m.editInterval,
+	}
+	if err := reply.start(turnCtx); err != nil {
+		return fmt.Errorf("send placeholder: %w", err)
+	}
+
+	events := turn.events(turnCtx)
+
+	var (
+		streamErr error
+		images    []string
+		toolsRan  bool
+	)
+	ticker := time.NewTicker(reply.interval())
+	defer ticker.Stop()
+recvLoop:
+	for {
+		select {
+		case <-ticker.C:
+			reply.flush(turnCtx, false)
+		case <-turnCtx.Done():
+			streamErr = turnCtx.Err()
+			break recvLoop
+		case res, ok := <-events:
+			if !ok {
+				break recvLoop
+			}
+			if res.err != nil {
+				if !errors.Is(res.err, io.EOF) {
+					streamErr = res.err
+				}
+				break recvLoop
+			}
+			switch ev := res.ev; ev.Type {
+			case llm.EventTextDelta:
+				reply.appendText(ev.Text)
+			case llm.EventToolExecStart:
+				toolsRan = true
+				status := "🔧 " + ev.ToolName
+				if ev.ToolInfo != "" {
+					status += " " + ev.ToolInfo
+				}
+				reply.setStatus(status)
+			case llm.EventToolExecEnd:
+				reply.setStatus("")
+				images = append(images, ev.ToolImages...)
+			case llm.EventRetry:
+				reply.setStatus(fmt.Sprintf("retrying (attempt %d)…", ev.RetryAttempt))
+			case llm.EventError:
+				if ev.Err != nil {
+					streamErr = ev.Err
+				}
+			}
+		}
+	}
+
+	deliveryCtx, cancelDelivery := context.WithTimeout(context.WithoutCancel(ctx), slackFinalDeliveryTimeout)
+	defer cancelDelivery()
+	reply.setStatus("")
+	if streamErr != nil {
+		notice := "⚠️ " + streamErr.Error()
+		if errors.Is(streamErr, context.Canceled) {
+			notice = "_(stopped)_"
+		}
+		reply.appendNotice(notice)
+	} else if reply.empty() {
+		if toolsRan {
+			reply.appendNotice("(done)")
+		} else {
+			reply.appendNotice("(no response)")
+		}
+	}
+	if err := reply.flush(deliveryCtx, true); err != nil {
+		log.Printf("[slack] final edit failed in %s: %v", sess.key, err)
+	}
+	for _, path := range images {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("[slack] read tool image %s: %v", path, err)
+			continue
+		}
+		if err := m.api.uploadFile(deliveryCtx, sess.channel, sess.threadTS, filepath.Base(path), data); err != nil {
+			log.Printf("[slack] upload image %s: %v", path, err)
+		}
+	}
+
+	sess.history = append(sess.history, turn.finish(deliveryCtx, streamErr, reply.text())...)
+	return nil
+}
+
+// slackReplyWriter accumulates streamed text and mirrors it into Slack
+// messages, splitting into follow-up messages past slackMaxMessageRunes.
+type slackReplyWriter struct {
+	api          *slackClient
+	channel      string
+	threadTS     string
+	editInterval time.Duration
+
+	mu        sync.Mutex
+	full      strings.Builder // all assistant text this turn
+	pending   string          // text not yet committed to an earlier message
+	status    string
+	currentTS string
+	lastSent  string
+	lastEdit  time.Time
+	notFirst  bool
+}
+
+func (w *slackReplyWriter) interval() time.Duration {
+	if w.editInterval > 0 {
+		return w.editInterval
+	}
+	return slackMinEditInterval
+}
+
+func (w *slackReplyWriter) start(ctx context.Context) error {
+	ts, err := w.api.postMessage(ctx, slackMessage{Channel: w.channel, ThreadTS: w.threadTS, Text: "⏳"})
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.currentTS = ts
+	w.lastSent = "⏳"
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *slackReplyWriter) appendText(text string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.full.WriteString(text)
+	w.pending += text
+}
+
+// appendNotice adds a status line that is shown but not kept as assistant text.
+func (w *slackReplyWriter) appendNotice(text string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if strings.TrimSpace(w.pending) != "" {
+		w.pending += "\n\n"
+	}
+	w.pending += text
+}
+
+func (w *slackReplyWriter) setStatus(status string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status = status
+}
+
+func (w *slackReplyWriter) text() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.full.String()
+}
+
+func (w *slackReplyWriter) empty() bool {
+	return strings.TrimSpace(w.text()) == ""
+}
+
+// flush pushes the current text to Slack. Intermediate flushes are rate
+// limited and skipped when nothing changed; the final flush always edits.
+func (w *slackReplyWriter) flush(ctx context.Context, final bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !final && time.Since(w.lastEdit) < w.interval() {
+		return nil
+	}
+
+	for utf8.RuneCountInString(w.pending) > slackMaxMessageRunes {
+		head, rest := splitMessageAtLine(w.pending, slackMaxMessageRunes)
+		if err := w.edit(ctx, mdToSlackMrkdwn(head)); err != nil {
+			return err
+		}
+		ts, err := w.api.postMessage(ctx, slackMessage{Channel: w.channel, ThreadTS: w.threadTS, Text: "⏳"})
+		if err != nil {
+			return err
+		}
+		w.currentTS = ts
+		w.lastSent = "⏳"
+		w.pending = rest
+	}
+
+	body := mdToSlackMrkdwn(w.pending)
+	if w.status != "" {
+		if body != "" {
+			body += "\n\n"
+		}
+		body += "_" + slackEscaper.Replace(w.status) + "_"
+	} else if !final {
+		body += " ▌"
+	}
+	if strings.TrimSpace(body) == "" {
+		if !final {
+			return nil
+		}
+		body = "(no response)"
+	}
+	if body == w.lastSent {
+		return nil
+	}
+	return w.edit(ctx, body)
+}
+
+func (w *slackReplyWriter) edit(ctx context.Context, body string) error {
+	err := w.api.updateMessage(ctx, slackMessage{Channel: w.channel, TS: w.currentTS, Text: body})
+	var apiErr *slackAPIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(apiErr.RetryAfter):
+		}
+		err = w.api.updateMessage(ctx, slackMessage{Channel: w.channel, TS: w.currentTS, Text: body})
+	}
+	w.lastEdit = time.Now()
+	if err == nil {
+		w.lastSent = body
+	}
+	return err
+}
+
+// requestApproval posts an approval prompt with one button per option and
+// blocks until an allowed user answers it or the session's turn ends.
+func (m *slackSessionMgr) requestApproval(sess *slackSession, req ApprovalRequest) (int, error) {
+	m.mu.Lock()
+	closed := sess.closed
+	m.mu.Unlock()
+	if closed {
+		return -1, nil
+	}
+
+	pending := &slackPendingApproval{
+		id:      "appr_" + rand.Text()[:16],
+		sessKey: sess.key,
+		channel: sess.channel,
+		req:     req,
+		answer:  make(chan slackApprovalAnswer, 1),
+	}
+	text := slackApprovalText(req)
+	ts, err := m.api.postMessage(m.ctx, slackMessage{
+		Channel:  sess.channel,
+		ThreadTS: sess.threadTS,
+		Text:     text,
+		Blocks:   slackApprovalBlocks(pending.id, text, req.Options),
+	})
+	if err != nil {
+		return -1, fmt.Errorf("post approval prompt: %w", err)
+	}
+	pending.ts = ts
+
+	m.approvalMu.Lock()
+	m.approvals[pending.id] = pending
+	m.approvalMu.Unlock()
+	defer func() {
+		m.approvalMu.Lock()
+		delete(m.approvals, pending.id)
+		m.approvalMu.Unlock()
+	}()
+
+	answer := slackApprovalAnswer{choice: -1}
+	select {
+	case answer = <-pending.answer:
+	case <-m.ctx.Done():
+	}
+
+	outcome := "Cancelled"
+	if answer.choice >= 0 && answer.choice < len(req.Options) {
+		outcome = slackEscaper.Replace(req.Options[answer.choice].Label)
+	}
+	if answer.user != "" {
+		outcome += " by <@" + answer.user + ">"
+	}
+	resolved := text + "\n➜ " + outcome
+	if err := m.api.updateMessage(context.WithoutCancel(m.ctx), slackMessage{Channel: sess.channel, TS: ts, Text: resolved}); err != nil {
+		log.Printf("[slack] update approval %s: %v", pending.id, err)
+	}
+	return answer.choice, nil
+}
+
+func slackApprovalText(req ApprovalRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*\n```%s```", slackEscaper.Replace(req.Title), slackEscaper.Replace(req.Target))
+	if req.WorkDir != "" {
+		fmt.Fprintf(&b, "\nin `%s`", slackEscaper.Replace(req.WorkDir))
+	}
+	return b.String()
+}
+
+// slackApprovalBlocks renders an approval prompt as Block Kit: the request
+// text and an actions block whose button values carry "<id>:<option>".
+func slackApprovalBlocks(approvalID, text string, options []tools.ApprovalOption) []any {
+	buttons := make([]any, 0, len(options))
+	for i, opt := range options {
+		button := map[string]any{
+			"type":      "button",
+			"action_id": fmt.Sprintf("approval_%d", i),
+			"text":      map[string]any{"type": "plain_text", "text": opt.Label, "emoji": true},
+			"value":     fmt.Sprintf("%s:%d", approvalID, i),
+		}
+		switch {
+		case opt.Choice == tools.ApprovalChoiceDeny:
+			button["style"] = "danger"
+		case i == 0:
+			button["style"] = "primary"
+		}
+		buttons = append(buttons, button)
+	}
+	return []any{
+		map[string]any{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": text}},
+		map[string]any{"type": "actions", "block_id": approvalID, "elements": buttons},
+	}
+}
+
+// handleInteraction resolves approval button presses.
+func (m *slackSessionMgr) handleInteraction(payload json.RawMessage) {
+	var interaction struct {
+		Type string `json:"type"`
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+		Actions []struct {
+			ActionID string `json:"action_id"`
+			Value    string `json:"value"`
+		} `json:"actions"`
+	}
+	if err := json.Unmarshal(payload, &interaction); err != nil {
+		log.Printf("[slack] invalid interaction payload: %v", err)
+		return
+	}
+	if interaction.Type != "block_actions" {
+		return
+	}
+	if !m.isAllowedUser(interaction.User.ID) {
+		log.Printf("[slack] ignoring approval click from unauthorised user %s", interaction.User.ID)
+		return
+	}
+	for _, action := range interaction.Actions {
+		if !strings.HasPrefix(action.ActionID, "approval_") {
+			continue
+		}
+		id, idx, ok := strings.Cut(action.Value, ":")
+		if !ok {
+			continue
+		}
+		var choice int
+		if _, err := fmt.Sscanf(idx, "%d", &choice); err != nil {
+			continue
+		}
+		m.approvalMu.Lock()
+		pending := m.approvals[id]
+		m.approvalMu.Unlock()
+		if pending == nil || choice < 0 || choice >= len(pending.req.Options) {
+			continue
+		}
+		select {
+		case pending.answer <- slackApprovalAnswer{choice: choice, user: interaction.User.ID}:
+		default: // already answered
+		}
+	}
+}
+
+// cancelApprovals dismisses the prompts a closed session left open.
+func (m *slackSessionMgr) cancelApprovals(sessKey string) {
+	m.approvalMu.Lock()
+	defer m.approvalMu.Unlock()
+	for _, pending := range m.approvals {
+		if pending.sessKey == sessKey {
+			select {
+			case pending.answer <- slackApprovalAnswer{choice: -1}:
+			default:
+			}
+		}
+	}
+}
+
+var slackWhitespace = regexp.MustCompile(`\s+`)
+
+// handleSlashCommand answers the app's slash command. Replies are
+// ephemeral, visible only to the caller.
+func (m *slackSessionMgr) handleSlashCommand(payload json.RawMessage) any {
+	var cmd struct {
+		Command   string `json:"command"`
+		Text      string `json:"text"`
+		UserID    string `json:"user_id"`
+		ChannelID string `json:"channel_id"`
+	}
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		log.Printf("[slack] invalid slash command payload: %v", err)
+		return nil
+	}
+	reply := func(text string, blocks ...any) map[string]any {
+		resp := map[string]any{"response_type": "ephemeral", "text": text}
+		if len(blocks) > 0 {
+			resp["blocks"] = blocks
+		}
+		return resp
+	}
+	if !m.isAllowedUser(cmd.UserID) {
+		return reply("You are not allowed to use this bot.")
+	}
+
+	args := slackWhitespace.Split(strings.TrimSpace(cmd.Text), -1)
+	switch strings.ToLower(args[0]) {
+	case "reset":
+		var closing []*slackSession
+		m.mu.Lock()
+		for _, sess := range m.sessions {
+			if sess.channel == cmd.ChannelID {
+				closing = append(closing, sess)
+			}
+		}
+		m.mu.Unlock()
+		for _, sess := range closing {
+			m.closeSession(sess)
+		}
+		return reply(fmt.Sprintf("Cleared %d conversation(s) in this channel.", len(closing)))
+
+	case "status":
+		active, running := 0, 0
+		m.mu.Lock()
+		for _, sess := range m.sessions {
+			if sess.channel == cmd.ChannelID {
+				active++
+				if sess.turnCancel != nil {
+					running++
+				}
+			}
+		}
+		m.mu.Unlock()
+		m.approvalMu.Lock()
+		waiting := 0
+		for _, pending := range m.approvals {
+			if pending.channel == cmd.ChannelID {
+				waiting++
+			}
+		}
+		m.approvalMu.Unlock()
+		return reply(fmt.Sprintf("Conversations in this channel: %d (%d responding)\nPending approvals: %d", active, running, waiting))
+
+	case "approvals":
+		m.approvalMu.Lock()
+		pendings := make([]*slackPendingApproval, 0, len(m.approvals))
+		for _, pending := range m.approvals {
+			if pending.channel == cmd.ChannelID {
+				pendings = append(pendings, pending)
+			}
+		}
+		m.approvalMu.Unlock()
+		if len(pendings) == 0 {
+			return reply("No pending approvals in this channel.")
+		}
+		var blocks []any
+		for _, pending := range pendings {
+			blocks = append(blocks, slackApprovalBlocks(pending.id, slackApprovalText(pending.req), pending.req.Options)...)
+		}
+		return reply(fmt.Sprintf("%d pending approval(s)", len(pendings)), blocks...)
+
+	default:
+		name := cmd.Command
+		if name == "" {
+			name = "/term-llm"
+		}
+		return reply("Mention me in a channel or send a direct message to start a conversation; each thread is its own session.\n\n" +
+			"`" + name + " status` - show conversations and pending approvals here\n" +
+			"`" + name + " approvals` - list pending tool approvals with buttons\n" +
+			"`" + name + " reset` - end every conversation in this channel")
+	}
+}
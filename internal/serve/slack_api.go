@@ -0,0 +1,449 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	slackDefaultAPIURL = "https://slack.com/api/"
+
+	slackMaxAPIResponseBytes  = 4 << 20
+	slackMaxFileDownloadBytes = 20 << 20
+	slackMaxEventBodyBytes    = 1 << 20
+
+	// slackSignatureMaxSkew rejects replayed Events API requests, matching
+	// the five minutes Slack recommends.
+	slackSignatureMaxSkew = 5 * time.Minute
+
+	slackSocketMaxBackoff = 30 * time.Second
+)
+
+// Envelope and payload kinds shared by Socket Mode and the Events API.
+const (
+	slackKindEvents      = "events_api"
+	slackKindInteractive = "interactive"
+	slackKindSlash       = "slash_commands"
+)
+
+// slackDispatcher handles one inbound Slack payload. The returned value, if
+// non-nil, is sent back as the acknowledgement body (slash command replies).
+// Dispatchers must return quickly: Slack expects an ack within three seconds.
+type slackDispatcher func(kind string, payload json.RawMessage) any
+
+// slackClient is a minimal Slack Web API client. apiURL is overridable so the
+// platform can be exercised against a local stub.
+type slackClient struct {
+	apiURL   string
+	botToken string
+	appToken string
+	http     *http.Client
+}
+
+func newSlackClient(apiURL, botToken, appToken string) *slackClient {
+	apiURL = strings.TrimSpace(apiURL)
+	if apiURL == "" {
+		apiURL = slackDefaultAPIURL
+	}
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	return &slackClient{
+		apiURL:   apiURL,
+		botToken: botToken,
+		appToken: appToken,
+		http:     &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// slackAPIError is an ok=false (or rate limited) Web API response.
+type slackAPIError struct {
+	Method     string
+	Code       string
+	RetryAfter time.Duration
+}
+
+func (e *slackAPIError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("slack %s: %s (retry after %s)", e.Method, e.Code, e.RetryAfter)
+	}
+	return fmt.Sprintf("slack %s: %s", e.Method, e.Code)
+}
+
+func (c *slackClient) call(ctx context.Context, token, method string, params any, out any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("slack %s: encode request: %w", method, err)
+	}
+	return c.do(ctx, token, method, "application/json; charset=utf-8", bytes.NewReader(body), out)
+}
+
+func (c *slackClient) callForm(ctx context.Context, token, method string, form url.Values, out any) error {
+	return c.do(ctx, token, method, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), out)
+}
+
+func (c *slackClient) do(ctx context.Context, token, method, contentType string, body io.Reader, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL+method, body)
+	if err != nil {
+		return fmt.Errorf("slack %s: %w", method, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter := time.Second
+		if secs, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && secs > 0 {
+			retryAfter = time.Duration(secs) * time.Second
+		}
+		return &slackAPIError{Method: method, Code: "ratelimited", RetryAfter: retryAfter}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, slackMaxAPIResponseBytes))
+	if err != nil {
+		return fmt.Errorf("slack %s: read response: %w", method, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack %s: unexpected status %d: %s", method, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var envelope struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("slack %s: decode response: %w", method, err)
+	}
+	if !envelope.OK {
+		return &slackAPIError{Method: method, Code: envelope.Error}
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("slack %s: decode response: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// authTest returns the bot's own user ID so the platform can ignore its own
+// messages and strip @-mentions of itself.
+func (c *slackClient) authTest(ctx context.Context) (userID, botID string, err error) {
+	var out struct {
+		UserID string `json:"user_id"`
+		BotID  string `json:"bot_id"`
+	}
+	if err := c.call(ctx, c.botToken, "auth.test", map[string]any{}, &out); err != nil {
+		return "", "", err
+	}
+	return out.UserID, out.BotID, nil
+}
+
+// slackMessage is a chat.postMessage / chat.update request body.
+type slackMessage struct {
+	Channel  string `json:"channel"`
+	TS       string `json:"ts,omitempty"`
+	ThreadTS string `json:"thread_ts,omitempty"`
+	Text     string `json:"text"`
+	Blocks   []any  `json:"blocks,omitempty"`
+}
+
+func (c *slackClient) postMessage(ctx context.Context, msg slackMessage) (string, error) {
+	var out struct {
+		TS string `json:"ts"`
+	}
+	if err := c.call(ctx, c.botToken, "chat.postMessage", msg, &out); err != nil {
+		return "", err
+	}
+	return out.TS, nil
+}
+
+// updateMessage edits a message in place. A nil Blocks slice is sent as an
+// empty list so buttons from an earlier revision are removed.
+func (c *slackClient) updateMessage(ctx context.Context, msg slackMessage) error {
+	body := map[string]any{
+		"channel": msg.Channel,
+		"ts":      msg.TS,
+		"text":    msg.Text,
+		"blocks":  msg.Blocks,
+	}
+	if msg.Blocks == nil {
+		body["blocks"] = []any{}
+	}
+	return c.call(ctx, c.botToken, "chat.update", body, nil)
+}
+
+// downloadFile fetches a url_private file using the bot token.
+func (c *slackClient) downloadFile(ctx context.Context, fileURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.botToken)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if resp.ContentLength > slackMaxFileDownloadBytes {
+		return nil, fmt.Errorf("file too large: %d bytes (max %d)", resp.ContentLength, slackMaxFileDownloadBytes)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, slackMaxFileDownloadBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > slackMaxFileDownloadBytes {
+		return nil, fmt.Errorf("file too large: exceeds %d bytes", slackMaxFileDownloadBytes)
+	}
+	return data, nil
+}
+
+// uploadFile shares data in a channel thread using the external upload flow
+// (files.getUploadURLExternal, upload, files.completeUploadExternal).
+func (c *slackClient) uploadFile(ctx context.Context, channel, threadTS, filename string, data []byte) error {
+	var ticket struct {
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+	form := url.Values{}
+	form.Set("filename", filename)
+	form.Set("length", strconv.Itoa(len(data)))
+	if err := c.callForm(ctx, c.botToken, "files.getUploadURLExternal", form, &ticket); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ticket.UploadURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("slack upload: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack upload: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack upload: unexpected status %d", resp.StatusCode)
+	}
+
+	complete := map[string]any{
+		"files":      []map[string]string{{"id": ticket.FileID, "title": filename}},
+		"channel_id": channel,
+	}
+	if threadTS != "" {
+		complete["thread_ts"] = threadTS
+	}
+	return c.call(ctx, c.botToken, "files.completeUploadExternal", complete, nil)
+}
+
+// openConnection asks for a Socket Mode websocket URL using the app token.
+func (c *slackClient) openConnection(ctx context.Context) (string, error) {
+	var out struct {
+		URL string `json:"url"`
+	}
+	if err := c.call(ctx, c.appToken, "apps.connections.open", map[string]any{}, &out); err != nil {
+		return "", err
+	}
+	if out.URL == "" {
+		return "", errors.New("slack apps.connections.open: empty url")
+	}
+	return out.URL, nil
+}
+
+// slackEnvelope is one Socket Mode frame.
+type slackEnvelope struct {
+	EnvelopeID string          `json:"envelope_id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	Reason     string          `json:"reason"`
+}
+
+// runSlackSocketMode keeps a Socket Mode connection open until ctx is
+// cancelled, reconnecting with backoff when Slack drops or refreshes it.
+func runSlackSocketMode(ctx context.Context, client *slackClient, dispatch slackDispatcher) error {
+	backoff := time.Second
+	for {
+		connected, err := serveSlackSocket(ctx, client, dispatch)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if connected {
+			backoff = time.Second
+		}
+		if err != nil {
+			log.Printf("[slack] socket mode: %v; reconnecting in %s", err, backoff)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, slackSocketMaxBackoff)
+		}
+	}
+}
+
+// serveSlackSocket runs one websocket connection. It returns nil when Slack
+// asks the client to reconnect.
+func serveSlackSocket(ctx context.Context, client *slackClient, dispatch slackDispatcher) (connected bool, err error) {
+	wsURL, err := client.openConnection(ctx)
+	if err != nil {
+		return false, err
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var writeMu sync.Mutex
+	for {
+		var env slackEnvelope
+		if err := conn.ReadJSON(&env); err != nil {
+			return connected, fmt.Errorf("read: %w", err)
+		}
+		switch env.Type {
+		case "hello":
+			connected = true
+			log.Printf("[slack] socket mode connected")
+			continue
+		case "disconnect":
+			log.Printf("[slack] socket mode disconnect requested (%s)", env.Reason)
+			return connected, nil
+		}
+		if env.EnvelopeID == "" {
+			continue
+		}
+
+		ack := map[string]any{"envelope_id": env.EnvelopeID}
+		if resp := dispatch(env.Type, env.Payload); resp != nil {
+			ack["payload"] = resp
+		}
+		writeMu.Lock()
+		err := conn.WriteJSON(ack)
+		writeMu.Unlock()
+		if err != nil {
+			return connected, fmt.Errorf("ack: %w", err)
+		}
+	}
+}
+
+// slackEventsHandler serves the Events API request URL. One endpoint accepts
+// event callbacks (JSON), interactivity payloads and slash commands (forms).
+func slackEventsHandler(signingSecret string, dispatch slackDispatcher) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, slackMaxEventBodyBytes))
+		if err != nil {
+			http.Error(w, "read body", http.StatusBadRequest)
+			return
+		}
+		if err := verifySlackSignature(signingSecret, r.Header, body, time.Now()); err != nil {
+			log.Printf("[slack] rejected events request: %v", err)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+			var probe struct {
+				Type      string `json:"type"`
+				Challenge string `json:"challenge"`
+			}
+			if err := json.Unmarshal(body, &probe); err != nil {
+				http.Error(w, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+			if probe.Type == "url_verification" {
+				w.Header().Set("Content-Type", "text/plain")
+				_, _ = io.WriteString(w, probe.Challenge)
+				return
+			}
+			// Slack retries unacknowledged deliveries; the dispatcher
+			// de-duplicates by event_id.
+			dispatch(slackKindEvents, body)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, "invalid form", http.StatusBadRequest)
+			return
+		}
+		var resp any
+		switch {
+		case form.Get("payload") != "":
+			resp = dispatch(slackKindInteractive, json.RawMessage(form.Get("payload")))
+		case form.Get("command") != "":
+			fields := make(map[string]string, len(form))
+			for key := range form {
+				fields[key] = form.Get(key)
+			}
+			payload, _ := json.Marshal(fields)
+			resp = dispatch(slackKindSlash, payload)
+		default:
+			http.Error(w, "unsupported payload", http.StatusBadRequest)
+			return
+		}
+		if resp == nil {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// verifySlackSignature checks the v0 request signature Slack attaches to
+// Events API, interactivity and slash command requests.
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	signature := header.Get("X-Slack-Signature")
+	if timestamp == "" || signature == "" {
+		return errors.New("missing signature headers")
+	}
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if skew := now.Sub(time.Unix(secs, 0)); skew > slackSignatureMaxSkew || skew < -slackSignatureMaxSkew {
+		return fmt.Errorf("stale timestamp (%s old)", skew.Round(time.Second))
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
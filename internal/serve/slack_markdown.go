@@ -0,0 +1,164 @@
+package serve
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// slackEscaper escapes the three characters Slack treats as control
+// sequences in message text.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// mdToSlackMrkdwn converts Markdown text to Slack mrkdwn.
+//
+// Slack's mrkdwn supports *bold*, _italic_, ~strike~, `code`, ```pre```,
+// <url|links> and "> " quotes. Headings become bold lines and lists are
+// rendered with bullets; everything else is reduced to plain text.
+func mdToSlackMrkdwn(md string) string {
+	if strings.TrimSpace(md) == "" {
+		return md
+	}
+
+	var htmlBuf bytes.Buffer
+	if err := telegramMarkdown.Convert([]byte(md), &htmlBuf); err != nil {
+		return slackEscaper.Replace(md)
+	}
+
+	return htmlToSlackMrkdwn(htmlBuf.String())
+}
+
+// htmlToSlackMrkdwn walks goldmark HTML output and produces Slack mrkdwn.
+func htmlToSlackMrkdwn(src string) string {
+	z := html.NewTokenizer(strings.NewReader(src))
+
+	var sb strings.Builder
+	type listState struct {
+		ordered bool
+		counter int
+	}
+	var listStack []listState
+	var linkStack []string // pending hrefs; "" when the <a> had none
+
+	inPre := false
+	// Slack quotes are line-based, so a blockquote is rendered first and its
+	// lines prefixed when it closes.
+	var quoteStarts []int
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+
+		tok := z.Token()
+
+		switch tt {
+		case html.TextToken:
+			// goldmark separates block tags with bare newlines; spacing is
+			// emitted explicitly at block boundaries instead.
+			if !inPre && strings.Trim(tok.Data, "\n") == "" {
+				continue
+			}
+			sb.WriteString(slackEscaper.Replace(tok.Data))
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch tok.Data {
+			case "b", "strong":
+				sb.WriteString("*")
+			case "i", "em":
+				sb.WriteString("_")
+			case "s", "strike", "del":
+				sb.WriteString("~")
+			case "code":
+				if !inPre {
+					sb.WriteString("`")
+				}
+			case "pre":
+				inPre = true
+				sb.WriteString("```\n")
+			case "a":
+				href := attrVal(tok.Attr, "href")
+				linkStack = append(linkStack, href)
+				if href != "" {
+					sb.WriteString("<" + slackEscaper.Replace(href) + "|")
+				}
+			case "blockquote":
+				quoteStarts = append(quoteStarts, sb.Len())
+			case "br":
+				sb.WriteString("\n")
+			case "ul":
+				listStack = append(listStack, listState{ordered: false})
+			case "ol":
+				listStack = append(listStack, listState{ordered: true})
+			case "li":
+				indent := ""
+				if len(listStack) > 1 {
+					indent = strings.Repeat("    ", len(listStack)-1)
+				}
+				if len(listStack) > 0 && listStack[len(listStack)-1].ordered {
+					top := &listStack[len(listStack)-1]
+					top.counter++
+					fmt.Fprintf(&sb, "\n%s%d. ", indent, top.counter)
+				} else {
+					sb.WriteString("\n" + indent + "• ")
+				}
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				sb.WriteString("*")
+			case "hr":
+				sb.WriteString("\n──────────\n")
+			}
+
+		case html.EndTagToken:
+			switch tok.Data {
+			case "b", "strong":
+				sb.WriteString("*")
+			case "i", "em":
+				sb.WriteString("_")
+			case "s", "strike", "del":
+				sb.WriteString("~")
+			case "code":
+				if !inPre {
+					sb.WriteString("`")
+				}
+			case "pre":
+				inPre = false
+				sb.WriteString("```\n\n")
+			case "a":
+				if n := len(linkStack); n > 0 {
+					if linkStack[n-1] != "" {
+						sb.WriteString(">")
+					}
+					linkStack = linkStack[:n-1]
+				}
+			case "blockquote":
+				if n := len(quoteStarts); n > 0 {
+					start := quoteStarts[n-1]
+					quoteStarts = quoteStarts[:n-1]
+					quoted := strings.TrimSpace(sb.String()[start:])
+					rendered := sb.String()[:start]
+					sb.Reset()
+					sb.WriteString(rendered)
+					sb.WriteString("> " + strings.ReplaceAll(quoted, "\n", "\n> ") + "\n\n")
+				}
+			case "p":
+				sb.WriteString("\n\n")
+			case "ul", "ol":
+				if len(listStack) > 0 {
+					listStack = listStack[:len(listStack)-1]
+				}
+				sb.WriteString("\n")
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				sb.WriteString("*\n\n")
+			}
+		}
+	}
+
+	result := strings.TrimSpace(sb.String())
+	for strings.Contains(result, "\n\n\n") {
+		result = strings.ReplaceAll(result, "\n\n\n", "\n\n")
+	}
+	return result
+}
@@ -0,0 +1,507 @@
+package serve
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/testutil"
+	"github.com/samsaffron/term-llm/internal/tools"
+)
+
+type slackAPICall struct {
+	method string
+	body   map[string]any
+}
+
+// fakeSlackAPI stubs the Web API methods the platform uses and, for Socket
+// Mode, serves a websocket that replays queued envelopes.
+type fakeSlackAPI struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu     sync.Mutex
+	calls  []slackAPICall
+	nextTS int
+
+	envelopes chan slackEnvelope
+	acks      chan map[string]any
+}
+
+func newFakeSlackAPI(t *testing.T) *fakeSlackAPI {
+	t.Helper()
+	f := &fakeSlackAPI{
+		t:         t,
+		envelopes: make(chan slackEnvelope, 8),
+		acks:      make(chan map[string]any, 8),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeSlackAPI) client() *slackClient {
+	return newSlackClient(f.server.URL+"/api", "xoxb-test", "xapp-test")
+}
+
+func (f *fakeSlackAPI) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/ws" {
+		f.serveSocket(w, r)
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	f.mu.Lock()
+	f.calls = append(f.calls, slackAPICall{method: method, body: body})
+	f.nextTS++
+	ts := fmt.Sprintf("1700000000.%06d", f.nextTS)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch method {
+	case "auth.test":
+		_, _ = io.WriteString(w, `{"ok":true,"user_id":"UBOT","bot_id":"BBOT"}`)
+	case "chat.postMessage":
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "ts": ts})
+	case "apps.connections.open":
+		wsURL := "ws" + strings.TrimPrefix(f.server.URL, "http") + "/ws"
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "url": wsURL})
+	default:
+		_, _ = io.WriteString(w, `{"ok":true}`)
+	}
+}
+
+func (f *fakeSlackAPI) serveSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if err := conn.WriteJSON(slackEnvelope{Type: "hello"}); err != nil {
+		return
+	}
+	go func() {
+		for {
+			var ack map[string]any
+			if err := conn.ReadJSON(&ack); err != nil {
+				return
+			}
+			f.acks <- ack
+		}
+	}()
+	for {
+		select {
+		case env := <-f.envelopes:
+			if err := conn.WriteJSON(env); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (f *fakeSlackAPI) callsTo(method string) []slackAPICall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []slackAPICall
+	for _, c := range f.calls {
+		if c.method == method {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// waitFor polls until cond holds or the deadline passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newTestSlackMgr(t *testing.T, api *fakeSlackAPI, h *testutil.EngineHarness) *slackSessionMgr {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	mgr := newSlackSessionMgr(ctx, api.client(), nil, Settings{
+		MaxTurns: 5,
+		NewSession: func(context.Context) (*SessionRuntime, error) {
+			return &SessionRuntime{Engine: h.Engine, ProviderName: "mock", ModelName: "test"}, nil
+		},
+	}, "UBOT", config.SlackServeConfig{AllowedUserIDs: []string{"U123"}})
+	mgr.editInterval = 5 * time.Millisecond
+	return mgr
+}
+
+func eventCallback(eventID string, ev map[string]any) json.RawMessage {
+	data, _ := json.Marshal(map[string]any{"type": "event_callback", "event_id": eventID, "event": ev})
+	return data
+}
+
+func TestSlackSocketModeMentionStreamsThreadedReply(t *testing.T) {
+	api := newFakeSlackAPI(t)
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("Hello **there**")
+	mgr := newTestSlackMgr(t, api, h)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- runSlackSocketMode(ctx, api.client(), mgr.dispatch) }()
+
+	api.envelopes <- slackEnvelope{
+		EnvelopeID: "env-1",
+		Type:       slackKindEvents,
+		Payload: eventCallback("Ev1", map[string]any{
+			"type": "app_mention", "channel": "C1", "channel_type": "channel",
+			"user": "U123", "text": "<@UBOT> hi", "ts": "1699999999.000100",
+		}),
+	}
+
+	select {
+	case ack := <-api.acks:
+		if ack["envelope_id"] != "env-1" {
+			t.Fatalf("ack = %v, want envelope_id env-1", ack)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no ack received")
+	}
+
+	waitFor(t, "final edit", func() bool {
+		updates := api.callsTo("chat.update")
+		return len(updates) > 0 && updates[len(updates)-1].body["text"] == "Hello *there*"
+	})
+	posts := api.callsTo("chat.postMessage")
+	if len(posts) != 1 || posts[0].body["thread_ts"] != "1699999999.000100" || posts[0].body["text"] != "⏳" {
+		t.Fatalf("postMessage calls = %#v, want one threaded placeholder", posts)
+	}
+
+	sess := mgr.sessions[slackSessionKey("C1", "1699999999.000100")]
+	if sess == nil {
+		t.Fatal("thread session was not created")
+	}
+	sess.mu.Lock()
+	historyLen := len(sess.history)
+	sess.mu.Unlock()
+	if historyLen != 2 {
+		t.Fatalf("history length = %d, want 2", historyLen)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("runSlackSocketMode returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("socket mode did not stop")
+	}
+}
+
+func TestSlackShouldHandle(t *testing.T) {
+	api := newFakeSlackAPI(t)
+	mgr := newTestSlackMgr(t, api, testutil.NewEngineHarness())
+	mgr.sessions[slackSessionKey("C1", "100.1")] = &slackSession{key: slackSessionKey("C1", "100.1")}
+
+	tests := []struct {
+		name string
+		ev   slackEvent
+		want bool
+	}{
+		{"direct message", slackEvent{Type: "message", ChannelType: "im", User: "U123", Text: "hi"}, true},
+		{"mention", slackEvent{Type: "app_mention", ChannelType: "channel", User: "U123", Text: "<@UBOT> hi"}, true},
+		{"own message", slackEvent{Type: "message", ChannelType: "im", User: "UBOT"}, false},
+		{"bot message", slackEvent{Type: "message", ChannelType: "im", User: "U9", BotID: "B9"}, false},
+		{"edited message", slackEvent{Type: "message", Subtype: "message_changed", ChannelType: "im", User: "U123"}, false},
+		{"file share", slackEvent{Type: "message", Subtype: "file_share", ChannelType: "im", User: "U123"}, true},
+		{"follow-up in known thread", slackEvent{Type: "message", ChannelType: "channel", Channel: "C1", ThreadTS: "100.1", User: "U123", Text: "more"}, true},
+		{"mention in known thread", slackEvent{Type: "message", ChannelType: "channel", Channel: "C1", ThreadTS: "100.1", User: "U123", Text: "<@UBOT> more"}, false},
+		{"unknown thread", slackEvent{Type: "message", ChannelType: "channel", Channel: "C1", ThreadTS: "200.1", User: "U123"}, false},
+		{"channel chatter", slackEvent{Type: "message", ChannelType: "channel", Channel: "C1", User: "U123"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mgr.shouldHandle(tt.ev); got != tt.want {
+				t.Fatalf("shouldHandle = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlackDispatchDeduplicatesAndEnforcesAllowlist(t *testing.T) {
+	api := newFakeSlackAPI(t)
+	h := testutil.NewEngineHarness()
+	h.Provider.AddTextResponse("one")
+	h.Provider.AddTextResponse("two")
+	mgr := newTestSlackMgr(t, api, h)
+
+	dm := map[string]any{"type": "message", "channel": "D1", "channel_type": "im", "user": "U123", "text": "hi", "ts": "1.1"}
+	mgr.dispatch(slackKindEvents, eventCallback("EvA", dm))
+	mgr.dispatch(slackKindEvents, eventCallback("EvA", dm))
+	stranger := map[string]any{"type": "message", "channel": "D2", "channel_type": "im", "user": "U999", "text": "hi", "ts": "1.2"}
+	mgr.dispatch(slackKindEvents, eventCallback("EvB", stranger))
+
+	waitFor(t, "reply", func() bool {
+		updates := api.callsTo("chat.update")
+		return len(updates) > 0 && updates[len(updates)-1].body["text"] == "one"
+	})
+	time.Sleep(50 * time.Millisecond)
+	posts := api.callsTo("chat.postMessage")
+	if len(posts) != 1 {
+		t.Fatalf("postMessage calls = %d, want 1 (duplicate and unauthorised events dropped)", len(posts))
+	}
+	if _, threaded := posts[0].body["thread_ts"]; threaded {
+		t.Fatalf("top-level DM reply should not be threaded: %#v", posts[0].body)
+	}
+}
+
+func TestSlackApprovalButtons(t *testing.T) {
+	api := newFakeSlackAPI(t)
+	mgr := newTestSlackMgr(t, api, testutil.NewEngineHarness())
+	sess := &slackSession{key: slackSessionKey("C1", "100.1"), channel: "C1", threadTS: "100.1"}
+
+	req := ApprovalRequest{
+		Title:  "Allow shell command?",
+		Target: "rm -rf build",
+		Options: []tools.ApprovalOption{
+			{Label: "Allow once", Choice: tools.ApprovalChoiceOnce},
+			{Label: "Deny", Choice: tools.ApprovalChoiceDeny},
+		},
+	}
+	result := make(chan int, 1)
+	go func() {
+		choice, err := mgr.requestApproval(sess, req)
+		if err != nil {
+			t.Errorf("requestApproval: %v", err)
+		}
+		result <- choice
+	}()
+
+	var value string
+	waitFor(t, "approval prompt", func() bool {
+		posts := api.callsTo("chat.postMessage")
+		if len(posts) == 0 {
+			return false
+		}
+		blocks, _ := posts[0].body["blocks"].([]any)
+		if len(blocks) != 2 {
+			return false
+		}
+		elements := blocks[1].(map[string]any)["elements"].([]any)
+		deny := elements[1].(map[string]any)
+		if deny["style"] != "danger" {
+			t.Fatalf("deny button style = %v, want danger", deny["style"])
+		}
+		value = deny["value"].(string)
+		mgr.approvalMu.Lock()
+		defer mgr.approvalMu.Unlock()
+		return len(mgr.approvals) == 1
+	})
+
+	click := func(user string) json.RawMessage {
+		data, _ := json.Marshal(map[string]any{
+			"type":    "block_actions",
+			"user":    map[string]any{"id": user},
+			"actions": []any{map[string]any{"action_id": "approval_1", "value": value}},
+		})
+		return data
+	}
+	mgr.dispatch(slackKindInteractive, click("U999"))
+	select {
+	case <-result:
+		t.Fatal("approval resolved by an unauthorised user")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	mgr.dispatch(slackKindInteractive, click("U123"))
+	select {
+	case choice := <-result:
+		if choice != 1 {
+			t.Fatalf("choice = %d, want 1", choice)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("approval was not resolved")
+	}
+	waitFor(t, "resolved prompt", func() bool {
+		updates := api.callsTo("chat.update")
+		return len(updates) == 1 && strings.Contains(updates[0].body["text"].(string), "Deny by <@U123>")
+	})
+}
+
+func signSlackRequest(t *testing.T, secret string, body string, ts time.Time) *http.Request {
+	t.Helper()
+	stamp := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", stamp, body)
+	req := httptest.NewRequest(http.MethodPost, slackEventsPath, strings.NewReader(body))
+	req.Header.Set("X-Slack-Request-Timestamp", stamp)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func TestSlackEventsHandler(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		dispatched []string
+	)
+	handler := slackEventsHandler("secret", func(kind string, payload json.RawMessage) any {
+		mu.Lock()
+		dispatched = append(dispatched, kind)
+		mu.Unlock()
+		if kind == slackKindSlash {
+			return map[string]any{"response_type": "ephemeral", "text": "ok"}
+		}
+		return nil
+	})
+
+	t.Run("url verification", func(t *testing.T) {
+		req := signSlackRequest(t, "secret", `{"type":"url_verification","challenge":"abc"}`, time.Now())
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Body.String() != "abc" {
+			t.Fatalf("response = %d %q, want 200 abc", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("bad signature", func(t *testing.T) {
+		req := signSlackRequest(t, "wrong", `{"type":"event_callback"}`, time.Now())
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want 401", rec.Code)
+		}
+	})
+
+	t.Run("stale timestamp", func(t *testing.T) {
+		req := signSlackRequest(t, "secret", `{"type":"event_callback"}`, time.Now().Add(-10*time.Minute))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want 401", rec.Code)
+		}
+	})
+
+	t.Run("slash command", func(t *testing.T) {
+		form := url.Values{"command": {"/term-llm"}, "text": {"status"}, "user_id": {"U123"}}
+		req := signSlackRequest(t, "secret", form.Encode(), time.Now())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ephemeral"`) {
+			t.Fatalf("response = %d %q, want ephemeral JSON", rec.Code, rec.Body.String())
+		}
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(dispatched, ",") != slackKindSlash {
+		t.Fatalf("dispatched = %v, want only the slash command", dispatched)
+	}
+}
+
+func TestSlackSlashCommandReset(t *testing.T) {
+	api := newFakeSlackAPI(t)
+	mgr := newTestSlackMgr(t, api, testutil.NewEngineHarness())
+	for _, key := range []string{slackSessionKey("C1", "1.1"), slackSessionKey("C1", "2.1"), slackSessionKey("C2", "3.1")} {
+		channel, _, _ := strings.Cut(key, ":")
+		mgr.sessions[key] = &slackSession{key: key, channel: channel}
+	}
+
+	payload, _ := json.Marshal(map[string]string{"command": "/term-llm", "text": "reset", "user_id": "U123", "channel_id": "C1"})
+	resp, _ := mgr.dispatch(slackKindSlash, payload).(map[string]any)
+	if resp == nil || !strings.Contains(resp["text"].(string), "Cleared 2") {
+		t.Fatalf("reset response = %#v", resp)
+	}
+	mgr.mu.Lock()
+	remaining := len(mgr.sessions)
+	mgr.mu.Unlock()
+	if remaining != 1 {
+		t.Fatalf("remaining sessions = %d, want 1", remaining)
+	}
+
+	payload, _ = json.Marshal(map[string]string{"command": "/term-llm", "text": "reset", "user_id": "U999", "channel_id": "C2"})
+	resp, _ = mgr.dispatch(slackKindSlash, payload).(map[string]any)
+	if !strings.Contains(resp["text"].(string), "not allowed") {
+		t.Fatalf("unauthorised reset response = %#v", resp)
+	}
+}
+
+func TestSplitMessageAtLine(t *testing.T) {
+	text := "intro\n```\n" + strings.Repeat("line\n", 20) + "```"
+	head, rest := splitMessageAtLine(text, 40)
+	if strings.Count(head, "```")%2 != 0 || strings.Count(rest, "```")%2 != 0 {
+		t.Fatalf("unbalanced fences:\nhead=%q\nrest=%q", head, rest)
+	}
+	if !strings.HasPrefix(rest, "```\n") {
+		t.Fatalf("rest should reopen the code fence: %q", rest)
+	}
+}
+
+func TestMdToSlackMrkdwn(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"bold and italic", "**bold** and *it*", "*bold* and _it_"},
+		{"strike", "~~gone~~", "~gone~"},
+		{"inline code", "run `go test`", "run `go test`"},
+		{"link", "[docs](https://example.com)", "<https://example.com|docs>"},
+		{"escapes", "a < b & c", "a &lt; b &amp; c"},
+		{"heading", "# Title\n\nbody", "*Title*\n\nbody"},
+		{"bullets", "- one\n- two", "• one\n• two"},
+		{"ordered", "1. one\n2. two", "1. one\n2. two"},
+		{"quote", "> quoted\n> text", "> quoted\n> text"},
+		{"code block", "```go\nx := 1\n```", "```\nx := 1\n```"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mdToSlackMrkdwn(tt.in); got != tt.want {
+				t.Fatalf("mdToSlackMrkdwn(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlackPlatformNeedsSetup(t *testing.T) {
+	tests := []struct {
+		cfg  config.SlackServeConfig
+		want bool
+	}{
+		{config.SlackServeConfig{}, true},
+		{config.SlackServeConfig{BotToken: "xoxb-1"}, true},
+		{config.SlackServeConfig{BotToken: "xoxb-1", AppToken: "xapp-1"}, false},
+		{config.SlackServeConfig{BotToken: "xoxb-1", SigningSecret: "s"}, false},
+	}
+	for _, tt := range tests {
+		if got := NewSlackPlatform(tt.cfg).NeedsSetup(); got != tt.want {
+			t.Errorf("NeedsSetup(%+v) = %v, want %v", tt.cfg, got, tt.want)
+		}
+	}
+}
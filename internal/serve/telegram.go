@@ -751,14 +751,14 @@ func (m *telegramSessionMgr) resetSessionIfCurrent(ctx context.Context, chatID i
 }
 
 // restoreHistoryFromDB loads the tail of message history from the most recent
-// prior session for this chatID, capped at TelegramCarryoverChars worth of text.
+// prior session for this chatID, capped at CarryoverChars worth of text.
 // This ensures continuity after server restarts without bloating context.
 func (m *telegramSessionMgr) restoreHistoryFromDB(ctx context.Context, chatID int64, sess *telegramSession) {
 	if m.store == nil || sess.meta == nil {
 		return
 	}
 
-	maxChars := m.settings.TelegramCarryoverChars
+	maxChars := m.settings.CarryoverChars
 	if maxChars <= 0 {
 		return // 0 (or negative) explicitly disables carryover
 	}
@@ -779,7 +779,7 @@ func (m *telegramSessionMgr) restoreHistoryFromDB(ctx context.Context, chatID in
 			continue
 		}
 
-		history, loadedRows, loadErr := m.loadCarryoverHistory(ctx, s.ID, maxChars)
+		history, loadedRows, loadErr := loadCarryoverHistory(ctx, m.store, s.ID, maxChars)
 		if loadErr != nil {
 			log.Printf("[telegram] restore history: get messages failed for session %s: %v", s.ID, loadErr)
 			continue
@@ -796,14 +796,16 @@ func (m *telegramSessionMgr) restoreHistoryFromDB(ctx context.Context, chatID in
 	}
 }
 
-func (m *telegramSessionMgr) loadCarryoverHistory(ctx context.Context, sessionID string, maxChars int) ([]llm.Message, int, error) {
-	if m.store == nil || maxChars <= 0 {
+// loadCarryoverHistory returns the newest messages of sessionID that fit in
+// maxChars, and the number of stored rows read to find them.
+func loadCarryoverHistory(ctx context.Context, store session.Store, sessionID string, maxChars int) ([]llm.Message, int, error) {
+	if store == nil || maxChars <= 0 {
 		return nil, 0, nil
 	}
 
-	pager, ok := m.store.(session.MessagesDescendingPager)
+	pager, ok := store.(session.MessagesDescendingPager)
 	if !ok {
-		msgs, err := m.store.GetMessages(ctx, sessionID, 0, 0)
+		msgs, err := store.GetMessages(ctx, sessionID, 0, 0)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -2099,8 +2099,8 @@ func TestTelegramSessionMgrResetSessionIfCurrent_RestoresHistoryFromDB(t *testin
 		sessions: make(map[int64]*telegramSession),
 		store:    store,
 		settings: Settings{
-			TelegramCarryoverChars: 10000,
-			Store:                  store,
+			CarryoverChars: 10000,
+			Store:          store,
 			NewSession: func(ctx context.Context) (*SessionRuntime, error) {
 				return &SessionRuntime{
 					ProviderName: "mock",
@@ -2170,8 +2170,8 @@ func TestTelegramSessionMgrResetSessionIfCurrent_RestoresSanitizedCarryoverHisto
 		sessions: make(map[int64]*telegramSession),
 		store:    store,
 		settings: Settings{
-			TelegramCarryoverChars: 200,
-			Store:                  store,
+			CarryoverChars: 200,
+			Store:          store,
 			NewSession: func(ctx context.Context) (*SessionRuntime, error) {
 				return &SessionRuntime{
 					ProviderName: "mock",
@@ -2296,8 +2296,8 @@ func TestTelegramSessionMgrResetSession_ZeroCarryoverDisablesHistory(t *testing.
 		sessions: make(map[int64]*telegramSession),
 		store:    store,
 		settings: Settings{
-			TelegramCarryoverChars: 0, // explicitly disable carryover
-			Store:                  store,
+			CarryoverChars: 0, // explicitly disable carryover
+			Store:          store,
 			NewSession: func(ctx context.Context) (*SessionRuntime, error) {
 				return &SessionRuntime{
 					ProviderName: "mock",
@@ -2333,7 +2333,7 @@ func TestTelegramSessionMgrResetSession_ZeroCarryoverDisablesHistory(t *testing.
 		t.Fatalf("expected reset to replace session")
 	}
 
-	// With TelegramCarryoverChars=0, no history should be restored.
+	// With CarryoverChars=0, no history should be restored.
 	if len(replacement.history) != 0 {
 		t.Fatalf("expected 0 messages in restored history (carryover disabled), got %d", len(replacement.history))
 	}
@@ -2935,7 +2935,7 @@ func TestRestoreHistoryFromDB_LoadsCarryoverTailWithoutReadingWholeSession(t *te
 	mgr := &telegramSessionMgr{
 		store: store,
 		settings: Settings{
-			TelegramCarryoverChars: 8,
+			CarryoverChars: 8,
 		},
 	}
 	current := &telegramSession{meta: &session.Session{ID: currentID}}
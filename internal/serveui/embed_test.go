@@ -54,6 +54,7 @@ func TestConversationLifecycleSizeAndPurityBudgets(t *testing.T) {
 	completionLines := 0
 	branchingLines := 0
 	branchCommandLines := 0
+	revertLines := 0
 	diffLines := map[string]int{}
 	for _, entry := range entries {
 		name := entry.Name()
@@ -73,6 +74,8 @@ func TestConversationLifecycleSizeAndPurityBudgets(t *testing.T) {
 			branchingLines = lineCount
 		} else if name == "app-branch-commands.js" {
 			branchCommandLines = lineCount
+		} else if name == "app-diff-revert.js" {
+			revertLines = lineCount
 		} else if name == "app-diffs.js" || name == "app-diff-comments.js" || name == "app-diff-queue.js" {
 			diffLines[name] = lineCount
 		} else {
@@ -97,6 +100,9 @@ func TestConversationLifecycleSizeAndPurityBudgets(t *testing.T) {
 	if branchCommandLines == 0 || branchCommandLines > 100 {
 		t.Fatalf("conversation branch command controller=%d lines, budget=100", branchCommandLines)
 	}
+	if revertLines == 0 || revertLines > 120 {
+		t.Fatalf("diff revert controller=%d lines, budget=120", revertLines)
+	}
 	diffControllerLines := diffLines["app-diffs.js"] + diffLines["app-diff-comments.js"] + diffLines["app-diff-queue.js"]
 	if diffLines["app-diffs.js"] == 0 || diffLines["app-diff-comments.js"] == 0 || diffLines["app-diff-queue.js"] == 0 || diffControllerLines > 2700 {
 		t.Fatalf("diff sidebar, comments, and queue controllers grew beyond focused budget: %v", diffLines)
@@ -1085,6 +1091,7 @@ func TestStaticAssetsSupportDiffSidebar(t *testing.T) {
 		`id="diffQueueStatus"`,
 		`src="app-diff-comments.js"`,
 		`src="app-diff-queue.js"`,
+		`src="app-diff-revert.js"`,
 		`src="app-diffs.js"`,
 	} {
 		if !strings.Contains(indexSrc, want) {
@@ -1153,20 +1160,20 @@ func TestStaticAssetsSupportDiffSidebar(t *testing.T) {
 	if err != nil {
 		t.Fatalf("StaticAsset(sw.js): %v", err)
 	}
-	for _, asset := range []string{"app-diff-comments.js", "app-diff-queue.js", "app-diffs.js"} {
+	for _, asset := range []string{"app-diff-comments.js", "app-diff-queue.js", "app-diff-revert.js", "app-diffs.js"} {
 		if !strings.Contains(string(swJS), "'./"+asset+"'") {
 			t.Fatalf("sw.js SHELL_ASSETS missing %s", asset)
 		}
 	}
 
 	rendered := RenderIndexHTML("/ui", "", RenderOptions{})
-	for _, asset := range []string{"app-diff-comments.js", "app-diff-queue.js", "app-diffs.js"} {
+	for _, asset := range []string{"app-diff-comments.js", "app-diff-queue.js", "app-diff-revert.js", "app-diffs.js"} {
 		if !strings.Contains(string(rendered), `src="`+asset+`?v=`) {
 			t.Fatalf("RenderIndexHTML does not version %s", asset)
 		}
 	}
 	renderedSW := RenderServiceWorker(RenderOptions{})
-	for _, asset := range []string{"app-diff-comments.js", "app-diff-queue.js", "app-diffs.js"} {
+	for _, asset := range []string{"app-diff-comments.js", "app-diff-queue.js", "app-diff-revert.js", "app-diffs.js"} {
 		if !strings.Contains(string(renderedSW), "'./"+asset+"?v=") {
 			t.Fatalf("RenderServiceWorker does not version %s", asset)
 		}
@@ -0,0 +1,95 @@
+(() => {
+'use strict';
+
+// Revert actions for the diff sidebar: restore a whole file, or single hunks
+// of its cumulative diff, to the session baseline on disk. The server records
+// each revert as a new change, so the authoritative list refresh picks it up
+// and marks the cached diff stale.
+const app = window.TermLLMApp || (window.TermLLMApp = {});
+const { UI_PREFIX, state, createEl } = app;
+
+const revertHeaders = (sessionId) => ({
+  ...(typeof app.requestHeaders === 'function' ? app.requestHeaders(sessionId) : (state.token ? { Authorization: `Bearer ${state.token}` } : {})),
+  'Content-Type': 'application/json'
+});
+
+const fileBaseName = (path) => String(path || '').split('/').filter(Boolean).pop() || String(path || '');
+
+// revertFileChange restores path, or only the listed hunk indexes of its
+// cumulative diff, and refreshes the sidebar. Failures surface as a toast.
+const revertFileChange = async (sessionId, path, hunks = null) => {
+  const payload = { path };
+  if (Array.isArray(hunks)) payload.hunks = hunks;
+  try {
+    const resp = await app.apiFetch(`${UI_PREFIX}/v1/sessions/${encodeURIComponent(sessionId)}/file-changes/revert`, {
+      method: 'POST',
+      headers: revertHeaders(sessionId),
+      body: JSON.stringify(payload)
+    });
+    if (!resp.ok) {
+      const body = await resp.json().catch(() => null);
+      app.showToast?.(body?.error?.message || 'Could not revert this change.', { id: 'diff-revert', tone: 'error' });
+      return false;
+    }
+    await app.fetchSessionFileChanges?.(sessionId);
+    app.pinDiffFileExpanded?.(sessionId, path);
+    app.showToast?.(Array.isArray(hunks) ? 'Hunk reverted.' : `Reverted ${fileBaseName(path)}.`, { id: 'diff-revert' });
+    return true;
+  } catch {
+    app.showToast?.('Could not revert this change.', { id: 'diff-revert', tone: 'error' });
+    return false;
+  }
+};
+
+const actionButton = (className, label, ariaLabel, onClick) => {
+  const button = createEl('button', `diff-action-btn ${className}`, label);
+  button.setAttribute('type', 'button');
+  button.setAttribute('aria-label', ariaLabel);
+  button.addEventListener('click', (event) => {
+    event.stopPropagation?.();
+    onClick(button);
+  });
+  return button;
+};
+
+const hunkButton = (sessionId, path, hunk) => actionButton(
+  'diff-revert-hunk', '↶ Revert hunk', `Revert hunk ${hunk + 1} in ${path}`,
+  (button) => {
+    button.disabled = true;
+    revertFileChange(sessionId, path, [hunk]).finally(() => { button.disabled = false; });
+  });
+
+// decorateDiffRevertRows adds a revert control per hunk to a rendered diff
+// table: above the first hunk and inside each separator row after it. Only
+// modifications get them; created and deleted files revert as a whole from
+// the header.
+const decorateDiffRevertRows = (sessionId, path, kind, table) => {
+  const rows = Array.from(table?.children || []);
+  if (kind !== 'modify' || rows.length === 0) return;
+  let hunk = 0;
+  rows.forEach((row) => {
+    if (String(row.className || '').split(' ').includes('hunk')) {
+      hunk += 1;
+      row.appendChild(hunkButton(sessionId, path, hunk));
+    }
+  });
+  const head = createEl('div', 'diff-hunk-head');
+  head.appendChild(hunkButton(sessionId, path, 0));
+  table.insertBefore(head, rows[0]);
+};
+
+const diffRevertFileButton = (sessionId, path) => {
+  const button = actionButton('diff-revert-file', '↶', `Revert ${path} to its session baseline`, () => {
+    if (window.confirm && !window.confirm(`Revert ${fileBaseName(path)} to its state before this session?`)) return;
+    void revertFileChange(sessionId, path);
+  });
+  button.title = 'Revert file';
+  return button;
+};
+
+Object.assign(app, {
+  revertFileChange,
+  decorateDiffRevertRows,
+  diffRevertFileButton
+});
+})();
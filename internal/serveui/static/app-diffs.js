@@ -861,6 +861,7 @@ const renderDiffFileBody = (sessionId, ds, path, commentFocus = null) => {
     }
     table.appendChild(rowEl);
   });
+  app.decorateDiffRevertRows?.(sessionId, path, normalizeDiffKind(ds.files.get(path)?.kind), table);
   if (!table.querySelector?.('.diff-comment-panel')) app.clearDiffCommentPanel?.(sessionId, path);
   body.appendChild(table);
   if (postAttachCommentFocus.length > 0) body._restoreDiffCommentFocus = () => postAttachCommentFocus.forEach((restoreFocus) => restoreFocus());
@@ -951,6 +952,7 @@ const syncDiffFileBlock = (sessionId, ds, path) => {
     });
     actions.appendChild(copyPath);
     actions.appendChild(copyPatch);
+    if (app.diffRevertFileButton) actions.appendChild(app.diffRevertFileButton(sessionId, path));
 
     header.appendChild(chevron);
     header.appendChild(kindBadge);
@@ -8,6 +8,7 @@ const vm = require('vm');
 const source = fs.readFileSync(path.join(__dirname, 'app-diffs.js'), 'utf8');
 const cssSource = fs.readFileSync(path.join(__dirname, 'app.css'), 'utf8');
 const commentSource = fs.readFileSync(path.join(__dirname, 'app-diff-comments.js'), 'utf8');
+const revertSource = fs.readFileSync(path.join(__dirname, 'app-diff-revert.js'), 'utf8');
 let failures = 0;
 
 function fail(name, message, details) {
@@ -411,6 +412,7 @@ function createHarness(options = {}) {
   context.globalThis = context;
   app.apiFetch = (...args) => context.fetch(...args);
   if (options.diffComments) vm.runInNewContext(commentSource, context, { filename: 'app-diff-comments.js' });
+  if (options.diffRevert) vm.runInNewContext(revertSource, context, { filename: 'app-diff-revert.js' });
   vm.runInNewContext(source, context, { filename: 'app-diffs.js' });
 
   const flushTimers = async () => {
@@ -1530,6 +1532,46 @@ async function run(name, fn) {
     assertEqual(actions.querySelectorAll('.diff-action-btn').length, 2, 'copy path and copy diff buttons present');
   });
 
+  await run('revert actions post the file or hunk and refresh the list', async () => {
+    const posts = [];
+    let reverted = false;
+    const { app, elements, flushTimers } = createHarness({
+      diffRevert: true,
+      fetch: async (url, init = {}) => {
+        if (String(url).endsWith('/file-changes/revert')) {
+          posts.push(JSON.parse(init.body));
+          reverted = true;
+          return { ok: true, json: async () => ({ reverted: true }) };
+        }
+        return {
+          ok: true,
+          json: async () => (String(url).includes('/diff?')
+            ? { path: '/a', kind: 'modify', lang: '', truncated: false, hunks: [
+              { old_start: 1, new_start: 1, lines: [{ t: 'del', s: 'x' }, { t: 'add', s: 'y' }] },
+              { old_start: 9, new_start: 9, lines: [{ t: 'add', s: 'z' }] }
+            ] }
+            : { file_changes: reverted ? [] : [{ path: '/a', kind: 'modify', adds: 2, dels: 1, truncated: false, seq: 1 }] })
+        };
+      }
+    });
+    app.toggleDiffSidebar();
+    app.handleFileChangeEvent({ id: 's1' }, { path: '/a', kind: 'modify', adds: 2, dels: 1, seq: 1 });
+    await flushTimers();
+    await flushTimers();
+
+    assert(elements.diffFileList.querySelector('.diff-revert-file'), 'file header offers a revert');
+    const hunkButtons = elements.diffFileList.querySelectorAll('.diff-revert-hunk');
+    assertEqual(hunkButtons.length, 2, 'one revert control per hunk');
+    await hunkButtons[1].dispatchEvent({ type: 'click' });
+    await flushTimers();
+    await flushTimers();
+    assertEqual(JSON.stringify(posts[0]), JSON.stringify({ path: '/a', hunks: [1] }), 'hunk revert posts its index');
+    assert(elements.diffToggleBtn.hidden, 'list refreshed after revert');
+
+    assert(await app.revertFileChange('s1', '/a'), 'file revert succeeds');
+    assertEqual(JSON.stringify(posts[1]), JSON.stringify({ path: '/a' }), 'file revert omits hunks');
+  });
+
   await run('canonical server paths inherit live-follow expansion state', async () => {
     const { app, elements } = createHarness({
       fetch: async (url) => ({
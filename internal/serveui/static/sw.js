@@ -38,6 +38,7 @@ const SHELL_ASSETS = [
   './app-session-admin.js',
   './app-diff-comments.js',
   './app-diff-queue.js',
+  './app-diff-revert.js',
   './app-diffs.js',
   './app-worktrees.js',
   // term-llm:webrtc-shell-asset
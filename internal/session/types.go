@@ -43,8 +43,22 @@ const (
 	OriginTUI      SessionOrigin = "tui"
 	OriginWeb      SessionOrigin = "web"
 	OriginTelegram SessionOrigin = "telegram"
+	OriginSlack    SessionOrigin = "slack"
+	OriginDiscord  SessionOrigin = "discord"
+	OriginEmail    SessionOrigin = "email"
+	OriginMatrix   SessionOrigin = "matrix"
 )
 
+// IsServe reports whether the session was created by a term-llm serve
+// platform (web UI or a chat bot) rather than a local CLI launch.
+func (o SessionOrigin) IsServe() bool {
+	switch o {
+	case OriginWeb, OriginTelegram, OriginSlack, OriginDiscord, OriginEmail, OriginMatrix:
+		return true
+	}
+	return false
+}
+
 type SessionTitleSource string
 
 const (
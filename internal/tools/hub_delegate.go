@@ -13,6 +13,7 @@ import (
 
 	"github.com/samsaffron/term-llm/internal/hub"
 	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/tracing"
 )
 
 // hub_delegate / hub_check_delegation let an agent on one term-llm node run
@@ -35,6 +36,11 @@ type HubDelegateArgs struct {
 	Cwd                string `json:"cwd,omitempty"`
 	Wait               bool   `json:"wait,omitempty"`
 	ParentDelegationID string `json:"parent_delegation_id,omitempty"`
+	// Labels, MaxQueueDepth and FanOut let the hub choose the target when
+	// TargetNode is empty.
+	Labels        []string `json:"labels,omitempty"`
+	MaxQueueDepth *int     `json:"max_queue_depth,omitempty"`
+	FanOut        int      `json:"fan_out,omitempty"`
 }
 
 type HubCheckDelegationArgs struct {
@@ -128,6 +134,7 @@ func (c *hubDelegationClient) doJSON(ctx context.Context, method, path string, p
 	}
 	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("X-Term-LLM-Node-ID", c.nodeID)
+	tracing.Inject(ctx, req.Header)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return err
@@ -158,12 +165,27 @@ func (c *hubDelegationClient) doJSON(ctx context.Context, method, path string, p
 type hubDelegationEnvelope struct {
 	Delegation   hub.Delegation `json:"delegation"`
 	RefreshError string         `json:"refresh_error"`
+	// Delegations lists every member of a fan-out create.
+	Delegations []hub.Delegation `json:"delegations"`
 }
 
-func (c *hubDelegationClient) createDelegation(ctx context.Context, a HubDelegateArgs) (hub.Delegation, error) {
+// createDelegation starts a delegation and returns every record the hub
+// created: one, or each member of a fan-out group.
+func (c *hubDelegationClient) createDelegation(ctx context.Context, a HubDelegateArgs) ([]hub.Delegation, error) {
 	payload := map[string]any{
-		"target_node": a.TargetNode,
-		"prompt":      a.Prompt,
+		"prompt": a.Prompt,
+	}
+	if a.TargetNode != "" {
+		payload["target_node"] = a.TargetNode
+	}
+	if len(a.Labels) > 0 {
+		payload["labels"] = a.Labels
+	}
+	if a.MaxQueueDepth != nil {
+		payload["max_queue_depth"] = *a.MaxQueueDepth
+	}
+	if a.FanOut > 1 {
+		payload["fan_out"] = a.FanOut
 	}
 	if a.AgentName != "" {
 		payload["agent_name"] = a.AgentName
@@ -182,12 +204,15 @@ func (c *hubDelegationClient) createDelegation(ctx context.Context, a HubDelegat
 	}
 	var envelope hubDelegationEnvelope
 	if err := c.doJSON(ctx, http.MethodPost, "/api/delegations", payload, &envelope); err != nil {
-		return hub.Delegation{}, err
+		return nil, err
 	}
 	if envelope.Delegation.ID == "" {
-		return hub.Delegation{}, fmt.Errorf("hub returned a delegation without an id")
+		return nil, fmt.Errorf("hub returned a delegation without an id")
+	}
+	if len(envelope.Delegations) == 0 {
+		return []hub.Delegation{envelope.Delegation}, nil
 	}
-	return envelope.Delegation, nil
+	return envelope.Delegations, nil
 }
 
 func (c *hubDelegationClient) getDelegation(ctx context.Context, id string) (hub.Delegation, string, error) {
@@ -220,6 +245,47 @@ func (c *hubDelegationClient) waitForDelegation(ctx context.Context, id string,
 	}
 }
 
+// waitForDelegationGroup polls a fan-out group until one member succeeds or
+// all have finished, returning the winner or, without one, every member.
+func (c *hubDelegationClient) waitForDelegationGroup(ctx context.Context, group []hub.Delegation, pollInterval time.Duration) ([]HubDelegationResult, error) {
+	if pollInterval <= 0 {
+		pollInterval = defaultHubDelegationPollInterval
+	}
+	for {
+		results := make([]HubDelegationResult, 0, len(group))
+		done := true
+		for i, d := range group {
+			if !hub.DelegationStatusTerminal(d.Status) {
+				updated, refreshErr, err := c.getDelegation(ctx, d.ID)
+				if err != nil {
+					return nil, err
+				}
+				group[i] = updated
+				d = updated
+				results = append(results, hubDelegationResultFrom(d, refreshErr))
+			} else {
+				results = append(results, hubDelegationResultFrom(d, ""))
+			}
+			if d.Status == hub.DelegationStatusSucceeded {
+				return []HubDelegationResult{results[len(results)-1]}, nil
+			}
+			if !hub.DelegationStatusTerminal(d.Status) {
+				done = false
+			}
+		}
+		if done {
+			return results, nil
+		}
+		timer := time.NewTimer(pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return results, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
 // resolveClient returns the injected test client or builds one from the
 // in-process config.
 func resolveHubDelegationClient(injected *hubDelegationClient) (*hubDelegationClient, error) {
@@ -244,13 +310,29 @@ func NewHubDelegateToolWithClient(client *hubDelegationClient) *HubDelegateTool
 func (t *HubDelegateTool) Spec() llm.ToolSpec {
 	return llm.ToolSpec{
 		Name:        HubDelegateToolName,
-		Description: `Delegate a task to another term-llm node via the Hub. The Hub runs the prompt as a background agent job on the target node and returns a delegation_id; use hub_check_delegation to retrieve the result (or set wait=true to block).`,
+		Description: `Delegate a task to another term-llm node via the Hub. The Hub runs the prompt as a background agent job on the target node and returns a delegation_id; use hub_check_delegation to retrieve the result (or set wait=true to block). Omit target_node to let the Hub pick the least-loaded node that accepts the agent and model and carries every requested label; fan_out runs it on several such nodes and the first success wins.`,
 		Schema: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
 				"target_node": map[string]any{
 					"type":        "string",
-					"description": "Hub node id to delegate to",
+					"description": "Hub node id to delegate to; omit to let the Hub choose",
+				},
+				"labels": map[string]any{
+					"type":        "array",
+					"description": "When target_node is omitted, labels the chosen node must have (for example \"gpu\" or \"repo:term-llm\")",
+					"items":       map[string]any{"type": "string"},
+				},
+				"max_queue_depth": map[string]any{
+					"type":        "integer",
+					"description": "When target_node is omitted, skip nodes already running more than this many delegations (0 = idle nodes only)",
+					"minimum":     0,
+				},
+				"fan_out": map[string]any{
+					"type":        "integer",
+					"description": "When target_node is omitted, run the task on up to this many nodes; the first successful result wins and the rest are cancelled (default 1, max 4)",
+					"minimum":     1,
+					"maximum":     4,
 				},
 				"prompt": map[string]any{
 					"type":        "string",
@@ -283,7 +365,7 @@ func (t *HubDelegateTool) Spec() llm.ToolSpec {
 					"description": "Set automatically when this task runs inside a delegated hub job; only provide it when chaining manually outside one (the hub verifies it either way)",
 				},
 			},
-			"required":             []string{"target_node", "prompt"},
+			"required":             []string{"prompt"},
 			"additionalProperties": false,
 		},
 	}
@@ -294,9 +376,7 @@ func (t *HubDelegateTool) Execute(ctx context.Context, args json.RawMessage) (ll
 	if err := json.Unmarshal(args, &a); err != nil {
 		return llm.TextOutput(formatQueuedAgentError(ErrInvalidParams, fmt.Sprintf("failed to parse arguments: %v", err))), nil
 	}
-	if strings.TrimSpace(a.TargetNode) == "" {
-		return llm.TextOutput(formatQueuedAgentError(ErrInvalidParams, "target_node is required")), nil
-	}
+	a.TargetNode = strings.TrimSpace(a.TargetNode)
 	if strings.TrimSpace(a.Prompt) == "" {
 		return llm.TextOutput(formatQueuedAgentError(ErrInvalidParams, "prompt is required")), nil
 	}
@@ -310,10 +390,14 @@ func (t *HubDelegateTool) Execute(ctx context.Context, args json.RawMessage) (ll
 	if err != nil {
 		return llm.TextOutput(formatQueuedAgentError(ErrExecutionFailed, err.Error())), nil
 	}
-	d, err := client.createDelegation(ctx, a)
+	group, err := client.createDelegation(ctx, a)
 	if err != nil {
 		return llm.TextOutput(formatQueuedAgentError(ErrExecutionFailed, err.Error())), nil
 	}
+	if len(group) > 1 {
+		return t.fanOutOutput(ctx, client, group, a.Wait)
+	}
+	d := group[0]
 	refreshErr := ""
 	if a.Wait {
 		d, refreshErr, err = client.waitForDelegation(ctx, d.ID, t.pollIntervalOverride)
@@ -325,10 +409,33 @@ func (t *HubDelegateTool) Execute(ctx context.Context, args json.RawMessage) (ll
 	return llm.TextOutput(string(data)), nil
 }
 
+// fanOutOutput reports a fan-out group: with wait, the winning delegation
+// (or every member when none succeeded); otherwise all member ids so
+// hub_check_delegation can follow them.
+func (t *HubDelegateTool) fanOutOutput(ctx context.Context, client *hubDelegationClient, group []hub.Delegation, wait bool) (llm.ToolOutput, error) {
+	var results []HubDelegationResult
+	if wait {
+		var err error
+		results, err = client.waitForDelegationGroup(ctx, group, t.pollIntervalOverride)
+		if err != nil {
+			return llm.TextOutput(formatQueuedAgentError(ErrExecutionFailed, fmt.Sprintf("fan-out delegation %s created but waiting failed: %v", group[0].GroupID, err))), nil
+		}
+	} else {
+		for _, d := range group {
+			results = append(results, hubDelegationResultFrom(d, ""))
+		}
+	}
+	data, _ := json.Marshal(map[string]any{"group_id": group[0].GroupID, "delegations": results})
+	return llm.TextOutput(string(data)), nil
+}
+
 func (t *HubDelegateTool) Preview(args json.RawMessage) string {
 	var a HubDelegateArgs
 	_ = json.Unmarshal(args, &a)
 	if a.TargetNode == "" {
+		if a.FanOut > 1 {
+			return fmt.Sprintf("hub delegate (fan-out %d)", a.FanOut)
+		}
 		return "hub delegate"
 	}
 	return fmt.Sprintf("delegate to %s", a.TargetNode)
@@ -272,14 +272,34 @@ func TestHubDelegateWait(t *testing.T) {
 
 func TestHubDelegateInvalidParams(t *testing.T) {
 	tool := NewHubDelegateToolWithClient(newFakeHub(t).client())
-	out, _ := tool.Execute(context.Background(), json.RawMessage(`{"prompt":"x"}`))
-	if !strings.Contains(out.Content, "target_node is required") {
+	out, _ := tool.Execute(context.Background(), json.RawMessage(`{"target_node":"beta"}`))
+	if !strings.Contains(out.Content, "prompt is required") {
 		t.Fatalf("output = %s", out.Content)
 	}
-	out, _ = tool.Execute(context.Background(), json.RawMessage(`{"target_node":"beta"}`))
-	if !strings.Contains(out.Content, "prompt is required") {
+}
+
+func TestHubDelegateLetsHubChooseTarget(t *testing.T) {
+	f := newFakeHub(t)
+	tool := NewHubDelegateToolWithClient(f.client())
+	out, err := tool.Execute(context.Background(), json.RawMessage(
+		`{"prompt":"do it","labels":["gpu"],"max_queue_depth":0}`))
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !strings.Contains(out.Content, "dlg_test") {
 		t.Fatalf("output = %s", out.Content)
 	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.lastCreate["target_node"]; ok {
+		t.Fatalf("create payload = %v, want no target_node", f.lastCreate)
+	}
+	if labels, _ := f.lastCreate["labels"].([]any); len(labels) != 1 || labels[0] != "gpu" {
+		t.Fatalf("labels = %v", f.lastCreate["labels"])
+	}
+	if depth, ok := f.lastCreate["max_queue_depth"].(float64); !ok || depth != 0 {
+		t.Fatalf("max_queue_depth = %v", f.lastCreate["max_queue_depth"])
+	}
 }
 
 func TestHubCheckDelegationWaits(t *testing.T) {
@@ -12,8 +12,13 @@ import (
 	"unicode"
 
 	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var spawnTracer = tracing.Tracer("tools")
+
 // SpawnAgentArgs are the arguments for the spawn_agent tool.
 type SpawnAgentArgs struct {
 	AgentName string `json:"agent_name"`        // Required: name of the agent to spawn
@@ -437,9 +442,14 @@ func (t *SpawnAgentTool) Execute(ctx context.Context, args json.RawMessage) (llm
 		return llm.TextOutput(t.formatError(ErrExecutionFailed, "context cancelled while waiting for agent slot")), nil
 	}
 
-	// Create child context with timeout
+	// Create child context with timeout. The sub-agent's engine spans nest
+	// under the spawn span.
 	childCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
 	defer cancel()
+	childCtx, span := spawnTracer.Start(childCtx, "agent.spawn", trace.WithAttributes(
+		attribute.String("gen_ai.agent.name", a.AgentName),
+		attribute.Int("term_llm.agent.depth", currentDepth+1),
+	))
 
 	// Run the sub-agent (with callback if available)
 	start := time.Now()
@@ -473,6 +483,10 @@ func (t *SpawnAgentTool) Execute(ctx context.Context, args json.RawMessage) (llm
 		}
 	}
 	duration := time.Since(start).Milliseconds()
+	if runResult.SessionID != "" {
+		span.SetAttributes(attribute.String("term_llm.session_id", runResult.SessionID))
+	}
+	tracing.EndSpan(span, err)
 
 	if err != nil {
 		return llm.TextOutput(t.formatErrorWithPartialResult(classifySpawnAgentError(err, ctx, childCtx), spawnAgentErrorMessage(err, ctx, childCtx, a.AgentName, timeout), duration, runResult)), nil
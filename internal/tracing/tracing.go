@@ -0,0 +1,227 @@
+// Package tracing wires OpenTelemetry trace export for term-llm.
+//
+// Instrumented packages obtain tracers with Tracer and never check whether
+// tracing is enabled: until Setup installs an exporter the global provider is
+// a no-op and spans cost next to nothing.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationPrefix = "github.com/samsaffron/term-llm/"
+
+// DefaultServiceName is the service.name resource attribute unless
+// overridden by Config.ServiceName or OTEL_SERVICE_NAME.
+const DefaultServiceName = "term-llm"
+
+// Config selects where spans are exported. The zero value exports nothing
+// unless the standard OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT variables are set.
+type Config struct {
+	// Endpoint is an OTLP/HTTP collector base URL such as
+	// "http://localhost:4318"; "/v1/traces" is appended when no path is given.
+	// A bare "host:port" is treated as plain HTTP.
+	Endpoint string
+	// Headers are sent with every export request (e.g. collector auth).
+	Headers map[string]string
+	// File, when set, appends one JSON span per line to this path for
+	// offline inspection. It can be combined with Endpoint.
+	File string
+	// ServiceName overrides the service.name resource attribute.
+	ServiceName string
+	// SampleRatio is the fraction of new root traces recorded, in (0, 1].
+	// Zero records everything. Child spans follow their parent's decision.
+	SampleRatio float64
+}
+
+// HasExporter reports whether c selects an exporter, directly or through
+// the standard OTLP endpoint variables. Setup does nothing otherwise.
+func (c Config) HasExporter() bool {
+	return strings.TrimSpace(c.Endpoint) != "" || strings.TrimSpace(c.File) != "" || otlpEndpointFromEnv()
+}
+
+func otlpEndpointFromEnv() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+var enabled atomic.Bool
+
+// Enabled reports whether Setup installed at least one exporter.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Tracer returns a tracer named after a term-llm package, e.g. "llm".
+func Tracer(pkg string) trace.Tracer {
+	return otel.Tracer(instrumentationPrefix + pkg)
+}
+
+// Setup installs the global tracer provider and W3C trace context
+// propagator. The returned shutdown flushes buffered spans; it is never nil.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	noop := func(context.Context) error { return nil }
+
+	if !cfg.HasExporter() {
+		return noop, nil
+	}
+	endpoint := strings.TrimSpace(cfg.Endpoint)
+	useOTLP := endpoint != "" || otlpEndpointFromEnv()
+	path := strings.TrimSpace(cfg.File)
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return noop, fmt.Errorf("tracing sample ratio must be between 0 and 1, got %v", cfg.SampleRatio)
+	}
+
+	var opts []sdktrace.TracerProviderOption
+	var closers []func() error
+	if useOTLP {
+		var exportOpts []otlptracehttp.Option
+		if endpoint != "" {
+			endpointURL, err := otlpEndpointURL(endpoint)
+			if err != nil {
+				return noop, err
+			}
+			exportOpts = append(exportOpts, otlptracehttp.WithEndpointURL(endpointURL))
+		}
+		if len(cfg.Headers) > 0 {
+			exportOpts = append(exportOpts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+		exporter, err := otlptracehttp.New(ctx, exportOpts...)
+		if err != nil {
+			return noop, fmt.Errorf("create OTLP trace exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return noop, fmt.Errorf("open trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return noop, fmt.Errorf("create file trace exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+		closers = append(closers, f.Close)
+	}
+
+	serviceName := strings.TrimSpace(cfg.ServiceName)
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	// Detectors run in order; OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES
+	// (WithFromEnv) win over the configured name.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		res = resource.Default()
+	}
+	opts = append(opts, sdktrace.WithResource(res))
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		opts = append(opts, sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))))
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	enabled.Store(true)
+
+	return func(ctx context.Context) error {
+		enabled.Store(false)
+		err := provider.Shutdown(ctx)
+		for _, closeFn := range closers {
+			if closeErr := closeFn(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// otlpEndpointURL normalises a configured collector endpoint to the full
+// traces URL the OTLP/HTTP exporter expects.
+func otlpEndpointURL(endpoint string) (string, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid tracing endpoint %q: want http(s)://host:port", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return u.String(), nil
+}
+
+// Inject writes the span context carried by ctx into outgoing request
+// headers as traceparent/tracestate.
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Extract returns ctx with the remote span context from incoming request
+// headers, if any, so new spans continue the caller's trace.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// TraceParent returns the W3C traceparent for the span in ctx, or "" when
+// there is no sampled span. It lets trace context survive in stored records
+// such as job labels.
+func TraceParent(ctx context.Context) string {
+	header := http.Header{}
+	Inject(ctx, header)
+	return header.Get("traceparent")
+}
+
+// ContextWithTraceParent is the inverse of TraceParent.
+func ContextWithTraceParent(ctx context.Context, traceparent string) context.Context {
+	if strings.TrimSpace(traceparent) == "" {
+		return ctx
+	}
+	header := http.Header{}
+	header.Set("traceparent", traceparent)
+	return Extract(ctx, header)
+}
+
+// Middleware continues traces from incoming traceparent headers. Handlers
+// start their own spans; this only establishes the parent.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Enabled() && r.Header.Get("traceparent") != "" {
+			r = r.WithContext(Extract(r.Context(), r.Header))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// EndSpan records err (if any) on span and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
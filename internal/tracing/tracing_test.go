@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOTLPEndpointURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{in: "localhost:4318", want: "http://localhost:4318/v1/traces"},
+		{in: "http://collector:4318/", want: "http://collector:4318/v1/traces"},
+		{in: "https://otel.example.com/custom/path", want: "https://otel.example.com/custom/path"},
+		{in: "ftp://collector:4318", wantErr: true},
+		{in: "http://", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := otlpEndpointURL(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("otlpEndpointURL(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("otlpEndpointURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSetupDisabledWithoutExporter(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	shutdown, err := Setup(context.Background(), Config{})
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if Enabled() {
+		t.Fatal("tracing enabled without an exporter")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+}
+
+func TestFileExporterAndTraceParentPropagation(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	shutdown, err := Setup(context.Background(), Config{File: path, ServiceName: "test-svc"})
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	if !Enabled() {
+		t.Fatal("tracing not enabled")
+	}
+
+	ctx, span := Tracer("test").Start(context.Background(), "origin")
+	traceparent := TraceParent(ctx)
+	if !strings.HasPrefix(traceparent, "00-"+span.SpanContext().TraceID().String()+"-") {
+		t.Fatalf("traceparent = %q", traceparent)
+	}
+
+	// A remote hop: the header is extracted by the middleware and a child
+	// span joins the origin trace.
+	var childTraceID trace.TraceID
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, child := Tracer("test").Start(r.Context(), "remote")
+		childTraceID = child.SpanContext().TraceID()
+		child.End()
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	Inject(ctx, req.Header)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if childTraceID != span.SpanContext().TraceID() {
+		t.Fatalf("remote span trace = %s, want %s", childTraceID, span.SpanContext().TraceID())
+	}
+
+	// And the stored-label path used across job boundaries.
+	restored := trace.SpanContextFromContext(ContextWithTraceParent(context.Background(), traceparent))
+	if restored.TraceID() != span.SpanContext().TraceID() || !restored.IsRemote() {
+		t.Fatalf("restored span context = %+v", restored)
+	}
+	span.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if Enabled() {
+		t.Fatal("tracing still enabled after shutdown")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var exported struct{ Name string }
+		if err := json.Unmarshal([]byte(line), &exported); err != nil {
+			t.Fatalf("span line is not JSON: %v\n%s", err, line)
+		}
+		names = append(names, exported.Name)
+	}
+	if strings.Join(names, ",") != "remote,origin" {
+		t.Fatalf("exported spans = %v, want remote,origin", names)
+	}
+	if !strings.Contains(string(data), "test-svc") {
+		t.Fatal("service name missing from exported resource")
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/samsaffron/term-llm/internal/agents"
 	"github.com/samsaffron/term-llm/internal/clipboard"
 	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/filetrack"
 	"github.com/samsaffron/term-llm/internal/llm"
 	"github.com/samsaffron/term-llm/internal/mcp"
 	"github.com/samsaffron/term-llm/internal/mentions"
@@ -37,6 +38,7 @@ import (
 	"github.com/samsaffron/term-llm/internal/termimage"
 	"github.com/samsaffron/term-llm/internal/tooldiscovery"
 	"github.com/samsaffron/term-llm/internal/tools"
+	filechangesui "github.com/samsaffron/term-llm/internal/tui/filechanges"
 	"github.com/samsaffron/term-llm/internal/tui/inspector"
 	sessionsui "github.com/samsaffron/term-llm/internal/tui/sessions"
 	worktreesui "github.com/samsaffron/term-llm/internal/tui/worktrees"
@@ -427,6 +429,9 @@ type Model struct {
 	sessionSwitchPending bool
 	sessionTransition    *sessionTransition
 
+	// Open session tabs, shared by every model the program swaps in.
+	tabs *SessionTabs
+
 	// If set, the caller should auto-send this message after handover restart.
 	pendingHandoverAutoSend string
 
@@ -479,6 +484,12 @@ type Model struct {
 	worktreeBrowserRoot      string
 	worktreeBrowserOperation string
 
+	// File-change review mode (/changes)
+	fileTrack            *filetrack.Store
+	fileChangesMode      bool
+	fileChangesModel     *filechangesui.Model
+	fileChangesReverting bool
+
 	// Alt screen mode (full-screen rendering)
 	altScreen                    bool
 	mouseMode                    bool
@@ -2274,6 +2285,8 @@ func (m *Model) closeEmbeddedViewsForInteractivePrompt() {
 	m.worktreeBrowserModel = nil
 	m.worktreeBrowserRoot = ""
 	m.worktreeBrowserOperation = ""
+	m.fileChangesMode = false
+	m.fileChangesModel = nil
 	m.sideQuestion.Visible = false
 	m.sideQuestion.ConfirmClear = false
 	m.selection = Selection{}
@@ -2452,6 +2465,11 @@ func (m *Model) Update(msg tea.Msg) (model tea.Model, cmd tea.Cmd) {
 		return m.updateWorktreeBrowserMode(msg)
 	}
 
+	// Handle file-change review mode. Revert completions route back to it.
+	if m.fileChangesMode && !isSpinnerTick && !parentChatMsg {
+		return m.updateFileChangesMode(msg)
+	}
+
 	// Handle resume browser mode
 	if m.resumeBrowserMode && !isSpinnerTick && !parentChatMsg {
 		return m.updateResumeBrowserMode(msg)
@@ -142,6 +142,11 @@ func AllCommands() []Command {
 			Description: "Restore the turn removed by /undo",
 			Usage:       "/redo",
 		},
+		{
+			Name:        "changes",
+			Description: "Review and revert this session's file changes",
+			Usage:       "/changes",
+		},
 		{
 			Name:        "quit",
 			Aliases:     []string{"q", "exit"},
@@ -291,6 +296,18 @@ func AllCommands() []Command {
 			Description: "Browse and resume a previous session",
 			Usage:       "/resume [number|id]",
 		},
+		{
+			Name:        "tab",
+			Description: "Open, switch or close session tabs",
+			Usage:       "/tab [new|close|next|prev|<n>]",
+			Subcommands: []Subcommand{
+				{Name: "new", Description: "Open a new session in a tab next to this one"},
+				{Name: "close", Description: "Close this tab (its run keeps going in the background)"},
+				{Name: "next", Description: "Switch to the next tab"},
+				{Name: "prev", Description: "Switch to the previous tab"},
+				{Name: "list", Description: "List open tabs and their activity"},
+			},
+		},
 		{
 			Name:        "reload",
 			Description: "Re-exec under the current binary, resuming this session (useful after upgrades)",
@@ -576,6 +593,8 @@ func (m *Model) ExecuteCommand(input string) (tea.Model, tea.Cmd) {
 		return m.cmdUndoRedo(false, args)
 	case "redo":
 		return m.cmdUndoRedo(true, args)
+	case "changes":
+		return m.cmdChanges()
 	case "quit":
 		return m.cmdQuit()
 	case "model":
@@ -622,6 +641,8 @@ func (m *Model) ExecuteCommand(input string) (tea.Model, tea.Cmd) {
 		return m.cmdCompress(args...)
 	case "resume":
 		return m.cmdResume(args)
+	case "tab":
+		return m.cmdTab(args)
 	case "reload":
 		return m.cmdReload()
 	case "handover":
@@ -968,6 +989,9 @@ func (m *Model) showHelpModal() (tea.Model, tea.Cmd) {
 				{"Ctrl+P", "Command palette"},
 				{"Ctrl+K", "Clear conversation"},
 				{"Ctrl+N", "New session"},
+				{"Alt+T", "Open a new session tab"},
+				{"Alt+. / Alt+, (Ctrl+PgDn / Ctrl+PgUp)", "Next / previous tab"},
+				{"Alt+1…9", "Jump to tab"},
 				{"Ctrl+L", "Switch model"},
 				{"Ctrl+R", "Cycle reasoning effort"},
 				{"Ctrl+S", "Toggle web search"},
@@ -2174,23 +2198,9 @@ func (m *Model) cmdNew() (tea.Model, tea.Cmd) {
 	}
 
 	// Create new session with current settings
-	m.sess = &session.Session{
-		ID:           session.NewID(),
-		Provider:     m.providerName,
-		ProviderKey:  m.providerKey,
-		Model:        m.modelName,
-		Mode:         session.ModeChat,
-		Agent:        m.agentName,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
-		Search:       m.searchEnabled,
-		Tools:        m.toolsStr,
-		MCP:          m.mcpStr,
-		ApprovalMode: sessionApprovalModeFromTools(m.requestedApprovalMode),
-	}
-	if cwd, err := os.Getwd(); err == nil {
-		m.sess.CWD = cwd
-		m.pendingTerminalDirectory = cwd
+	m.sess = m.newChatSession()
+	if m.sess.CWD != "" {
+		m.pendingTerminalDirectory = m.sess.CWD
 	}
 
 	// Persist to store
@@ -2249,6 +2259,28 @@ func (m *Model) cmdNew() (tea.Model, tea.Cmd) {
 	return updated, tea.Batch(footerCmd, m.terminalTitleCmd())
 }
 
+// newChatSession returns an unsaved session carrying the current settings.
+func (m *Model) newChatSession() *session.Session {
+	sess := &session.Session{
+		ID:           session.NewID(),
+		Provider:     m.providerName,
+		ProviderKey:  m.providerKey,
+		Model:        m.modelName,
+		Mode:         session.ModeChat,
+		Agent:        m.agentName,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		Search:       m.searchEnabled,
+		Tools:        m.toolsStr,
+		MCP:          m.mcpStr,
+		ApprovalMode: sessionApprovalModeFromTools(m.requestedApprovalMode),
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		sess.CWD = cwd
+	}
+	return sess
+}
+
 func (m *Model) cmdTitleRaw(rawName string) (tea.Model, tea.Cmd) {
 	m.setTextareaValue("")
 	name := strings.TrimSpace(rawName)
@@ -0,0 +1,119 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/samsaffron/term-llm/internal/filetrack"
+	filechangesui "github.com/samsaffron/term-llm/internal/tui/filechanges"
+)
+
+// fileChangesRevertDoneMsg reports the outcome of a revert requested by the
+// file-change browser.
+type fileChangesRevertDoneMsg struct {
+	label    string
+	reverted int
+	err      error
+}
+
+// SetFileTrackStore wires the file-change history used by /changes. A nil
+// store (file tracking disabled) leaves the command unavailable.
+func (m *Model) SetFileTrackStore(store *filetrack.Store) {
+	m.fileTrack = store
+}
+
+func (m *Model) cmdChanges() (tea.Model, tea.Cmd) {
+	if m.fileTrack == nil {
+		return m.showFooterError("File tracking is not enabled")
+	}
+	if m.sess == nil || m.sess.ID == "" {
+		return m.showFooterError("No session file changes to review")
+	}
+	browser := filechangesui.New(m.fileTrack, m.store, m.sess.ID, m.boundWorktreeDir(), m.width, m.height, m.styles)
+	m.fileChangesMode = true
+	m.fileChangesModel = browser
+	return m, browser.Init()
+}
+
+func (m *Model) closeFileChanges() (tea.Model, tea.Cmd) {
+	m.fileChangesMode = false
+	m.fileChangesModel = nil
+	m.fileChangesReverting = false
+	m.textarea.Focus()
+	return m, nil
+}
+
+func (m *Model) updateFileChangesMode(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.applyWindowSize(msg)
+		return m.delegateFileChanges(msg)
+	case filechangesui.CloseMsg:
+		return m.closeFileChanges()
+	case filechangesui.RevertMsg:
+		return m.startFileChangesRevert(msg)
+	case fileChangesRevertDoneMsg:
+		return m.handleFileChangesRevertDone(msg)
+	default:
+		return m.delegateFileChanges(msg)
+	}
+}
+
+func (m *Model) delegateFileChanges(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.fileChangesModel == nil {
+		return m, nil
+	}
+	updated, cmd := m.fileChangesModel.Update(msg)
+	if browser, ok := updated.(*filechangesui.Model); ok {
+		m.fileChangesModel = browser
+	}
+	return m, cmd
+}
+
+func (m *Model) startFileChangesRevert(msg filechangesui.RevertMsg) (tea.Model, tea.Cmd) {
+	if m.fileChangesModel == nil {
+		return m, nil
+	}
+	if m.streaming {
+		m.fileChangesModel.Error(fmt.Errorf("cannot revert files while a response is streaming"))
+		return m, nil
+	}
+	if m.fileChangesReverting {
+		m.fileChangesModel.Error(fmt.Errorf("a revert is already running"))
+		return m, nil
+	}
+	store := m.fileTrack
+	parentCtx := m.rootContext()
+	m.fileChangesReverting = true
+	m.fileChangesModel.SetBusy(true, "Reverting "+msg.Label+"…")
+	return m, func() tea.Msg {
+		ctx, cancel := context.WithTimeout(parentCtx, time.Minute)
+		defer cancel()
+		done := fileChangesRevertDoneMsg{label: msg.Label}
+		var errs []error
+		for _, req := range msg.Requests {
+			if _, err := store.Revert(ctx, req); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", req.Path, err))
+				continue
+			}
+			done.reverted++
+		}
+		done.err = errors.Join(errs...)
+		return done
+	}
+}
+
+func (m *Model) handleFileChangesRevertDone(msg fileChangesRevertDoneMsg) (tea.Model, tea.Cmd) {
+	m.fileChangesReverting = false
+	if m.fileChangesModel == nil {
+		return m, nil
+	}
+	status := "Reverted " + msg.label
+	if msg.reverted > 1 {
+		status = fmt.Sprintf("Reverted %d files (%s)", msg.reverted, msg.label)
+	}
+	return m, m.fileChangesModel.ReportRevertResult(msg.err, status)
+}
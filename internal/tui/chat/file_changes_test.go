@@ -0,0 +1,80 @@
+package chat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/samsaffron/term-llm/internal/filetrack"
+	"github.com/samsaffron/term-llm/internal/session"
+	filechangesui "github.com/samsaffron/term-llm/internal/tui/filechanges"
+)
+
+func TestCmdChangesRequiresFileTracking(t *testing.T) {
+	m := newTestChatModel(false)
+	m.sess = &session.Session{ID: "changes"}
+
+	result, _ := m.ExecuteCommand("/changes")
+	m = result.(*Model)
+	if m.fileChangesMode || m.fileChangesModel != nil {
+		t.Fatal("browser opened without a file tracking store")
+	}
+	if !strings.Contains(m.footerMessage, "not enabled") {
+		t.Fatalf("footer = %q", m.footerMessage)
+	}
+}
+
+func TestFileChangesRevertRunsInParent(t *testing.T) {
+	ctx := context.Background()
+	store, err := filetrack.Open(filepath.Join(t.TempDir(), "file_history.db"), filetrack.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("after\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.RecordChange(ctx, filetrack.ChangeRecord{SessionID: "changes", ToolCallID: "c1", Path: path, Before: []byte("before\n"), After: []byte("after\n")}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newTestChatModel(false)
+	m.sess = &session.Session{ID: "changes"}
+	m.SetFileTrackStore(store)
+	result, cmd := m.ExecuteCommand("/changes")
+	m = result.(*Model)
+	if !m.fileChangesMode || m.fileChangesModel == nil || cmd == nil {
+		t.Fatal("file change browser did not open")
+	}
+
+	m.streaming = true
+	revert := filechangesui.RevertMsg{Requests: []filetrack.RevertRequest{{SessionID: "changes", Path: path}}, Label: "notes.txt"}
+	if _, cmd := m.Update(revert); cmd != nil || m.fileChangesReverting {
+		t.Fatal("revert started while streaming")
+	}
+	m.streaming = false
+
+	_, cmd = m.Update(revert)
+	if cmd == nil || !m.fileChangesReverting {
+		t.Fatal("expected asynchronous revert")
+	}
+	done, ok := cmd().(fileChangesRevertDoneMsg)
+	if !ok || done.err != nil || done.reverted != 1 {
+		t.Fatalf("revert result = %+v", done)
+	}
+	m.Update(done)
+	if m.fileChangesReverting {
+		t.Fatal("revert still marked as running")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "before\n" {
+		t.Fatalf("file = %q, %v", data, err)
+	}
+
+	m.Update(filechangesui.CloseMsg{})
+	if m.fileChangesMode || m.fileChangesModel != nil {
+		t.Fatal("browser did not close")
+	}
+}
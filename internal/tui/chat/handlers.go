@@ -551,6 +551,12 @@ func (m *Model) handleKeyMsg(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
+	// Tab switching works while streaming: the outgoing tab's run continues in
+	// the background. Visible prompts above must be answered first.
+	if handled, model, cmd := m.handleTabKey(msg); handled {
+		return model, cmd
+	}
+
 	// Ctrl+? / Ctrl+Shift+/ opens help globally in the normal chat UI and
 	// preserves the current composer draft. Handle this before dialogs,
 	// completions, and the textarea so terminal-specific encodings don't leak
@@ -33,6 +33,9 @@ type KeyMap struct {
 	ToggleYolo  key.Binding
 	Clear       key.Binding
 	NewSession  key.Binding
+	NewTab      key.Binding
+	NextTab     key.Binding
+	PrevTab     key.Binding
 	MCPPicker   key.Binding
 	Inspector   key.Binding
 	ExpandTools key.Binding
@@ -133,6 +136,18 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("ctrl+n"),
 			key.WithHelp("ctrl+n", "new session"),
 		),
+		NewTab: key.NewBinding(
+			key.WithKeys("alt+t"),
+			key.WithHelp("alt+t", "new tab"),
+		),
+		NextTab: key.NewBinding(
+			key.WithKeys("alt+.", "ctrl+pgdown"),
+			key.WithHelp("alt+.", "next tab"),
+		),
+		PrevTab: key.NewBinding(
+			key.WithKeys("alt+,", "ctrl+pgup"),
+			key.WithHelp("alt+,", "previous tab"),
+		),
 		MCPPicker: key.NewBinding(
 			key.WithKeys("ctrl+t"),
 			key.WithHelp("ctrl+t", "mcp servers"),
@@ -113,6 +113,9 @@ type MainRunStatus struct {
 	RunID     string
 	Active    bool
 	Unvisited bool
+	// NeedsInput reports approval/ask/handover prompts retained while no
+	// model is attached to the session.
+	NeedsInput bool
 }
 
 // MainRunManager owns all process-lifetime TUI main runs. It is independent of
@@ -511,6 +514,7 @@ func (m *MainRunManager) Status(sessionID string) MainRunStatus {
 	defer run.mu.Unlock()
 	return MainRunStatus{
 		RunID: run.id, Active: run.active, Unvisited: !run.active && !run.visited,
+		NeedsInput: run.active && len(run.pendingUI) > 0,
 	}
 }
 
@@ -76,6 +76,12 @@ func (m *Model) View() (view tea.View) {
 		return m.worktreeBrowserModel.View()
 	}
 
+	// File-change review mode uses its dedicated full-screen view.
+	if m.fileChangesMode && m.fileChangesModel != nil {
+		m.resetPostFrameCurrentImages()
+		return m.fileChangesModel.View()
+	}
+
 	// Resume browser mode uses the dedicated sessions browser view
 	if m.resumeBrowserMode && m.resumeBrowserModel != nil {
 		m.resetPostFrameCurrentImages()
@@ -771,6 +777,8 @@ func (m *Model) buildFooterLayout() footerLayout {
 		textareaOffsetY += lipgloss.Height(row)
 	}
 
+	appendMetaRow(m.renderTabBar())
+
 	if m.interruptNotice != "" {
 		noticeStyle := lipgloss.NewStyle().Foreground(theme.Muted).Italic(true)
 		appendMetaRow(noticeStyle.Render("  " + m.interruptNotice))
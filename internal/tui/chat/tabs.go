@@ -0,0 +1,608 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"charm.land/bubbles/v2/key"
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/samsaffron/term-llm/internal/config"
+	"github.com/samsaffron/term-llm/internal/session"
+	"github.com/samsaffron/term-llm/internal/terminaltext"
+)
+
+// Session tabs keep several sessions open in one chat program. Each tab is a
+// session ID; switching tabs is an in-process session switch, so the outgoing
+// tab's run keeps executing under the MainRunManager (with its own engine and
+// retained approval prompts) while another tab is visible. The tab strip is
+// persisted per tab group so /reload and --resume of any member restore it.
+
+const (
+	sessionTabLabelWidth   = 24
+	maxPersistedTabGroups  = 20
+	sessionTabsFileVersion = 1
+)
+
+type sessionTab struct {
+	sessionID string
+	label     string
+	draft     string
+}
+
+// SessionTabs is the ordered tab strip shared by every model a chat program
+// swaps in. It is safe for concurrent use.
+type SessionTabs struct {
+	mu      sync.Mutex
+	path    string
+	groupID string
+	tabs    []sessionTab
+	active  int
+	// closing is removed once another tab's model takes over; a failed switch
+	// re-activates it and cancels the close.
+	closing string
+}
+
+// NewSessionTabs returns an empty tab strip persisted at path ("" keeps it in
+// memory only). The first activated session restores its saved group.
+func NewSessionTabs(path string) *SessionTabs {
+	return &SessionTabs{path: path}
+}
+
+// DefaultSessionTabsPath is where chat tab groups are persisted.
+func DefaultSessionTabsPath() (string, error) {
+	dir, err := config.GetConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "chat_tabs.json"), nil
+}
+
+// Activate marks sessionID as the visible tab. An empty strip first restores
+// the saved group containing sessionID; a session that is not open replaces
+// the active tab, the way /resume or /fork navigate within a tab.
+func (t *SessionTabs) Activate(sessionID, label string) {
+	if t == nil || sessionID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	changed := false
+	if t.closing != "" {
+		if t.closing != sessionID {
+			t.removeLocked(t.closing)
+			changed = true
+		}
+		t.closing = ""
+	}
+	if len(t.tabs) == 0 {
+		t.restoreLocked(sessionID)
+	}
+	if idx := t.indexLocked(sessionID); idx >= 0 {
+		t.active = idx
+	} else if len(t.tabs) == 0 {
+		t.tabs = []sessionTab{{sessionID: sessionID}}
+		t.active = 0
+	} else {
+		t.tabs[t.active] = sessionTab{sessionID: sessionID}
+		changed = true
+	}
+	if label != "" {
+		t.tabs[t.active].label = label
+	}
+	if changed {
+		t.saveLocked()
+	}
+}
+
+// Insert opens sessionID as a new tab after the active one without
+// activating it; the switched-in model activates it.
+func (t *SessionTabs) Insert(sessionID, label string) {
+	if t == nil || sessionID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.indexLocked(sessionID) >= 0 {
+		return
+	}
+	at := min(t.active+1, len(t.tabs))
+	t.tabs = append(t.tabs, sessionTab{})
+	copy(t.tabs[at+1:], t.tabs[at:])
+	t.tabs[at] = sessionTab{sessionID: sessionID, label: label}
+	t.saveLocked()
+}
+
+// Close schedules sessionID's tab for removal and returns the neighbour to
+// switch to. The tab stays until that neighbour activates.
+func (t *SessionTabs) Close(sessionID string) (sessionTab, bool) {
+	if t == nil {
+		return sessionTab{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	idx := t.indexLocked(sessionID)
+	if idx < 0 || len(t.tabs) < 2 {
+		return sessionTab{}, false
+	}
+	t.closing = sessionID
+	next := idx + 1
+	if next >= len(t.tabs) {
+		next = idx - 1
+	}
+	return t.tabs[next], true
+}
+
+// Len returns the number of open tabs.
+func (t *SessionTabs) Len() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.tabs)
+}
+
+// Index returns sessionID's position, or -1.
+func (t *SessionTabs) Index(sessionID string) int {
+	if t == nil {
+		return -1
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.indexLocked(sessionID)
+}
+
+func (t *SessionTabs) snapshot() []sessionTab {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]sessionTab(nil), t.tabs...)
+}
+
+func (t *SessionTabs) at(index int) (sessionTab, bool) {
+	if t == nil {
+		return sessionTab{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if index < 0 || index >= len(t.tabs) {
+		return sessionTab{}, false
+	}
+	return t.tabs[index], true
+}
+
+func (t *SessionTabs) setLabel(sessionID, label string) {
+	if t == nil || label == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if idx := t.indexLocked(sessionID); idx >= 0 {
+		t.tabs[idx].label = label
+	}
+}
+
+// setDraft parks a tab's unsent composer text while another tab is visible.
+func (t *SessionTabs) setDraft(sessionID, draft string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if idx := t.indexLocked(sessionID); idx >= 0 {
+		t.tabs[idx].draft = draft
+	}
+}
+
+func (t *SessionTabs) takeDraft(sessionID string) string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	idx := t.indexLocked(sessionID)
+	if idx < 0 {
+		return ""
+	}
+	draft := t.tabs[idx].draft
+	t.tabs[idx].draft = ""
+	return draft
+}
+
+func (t *SessionTabs) indexLocked(sessionID string) int {
+	for i, tab := range t.tabs {
+		if tab.sessionID == sessionID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *SessionTabs) removeLocked(sessionID string) {
+	idx := t.indexLocked(sessionID)
+	if idx < 0 {
+		return
+	}
+	t.tabs = append(t.tabs[:idx], t.tabs[idx+1:]...)
+	if t.active > idx || t.active >= len(t.tabs) {
+		t.active = max(0, t.active-1)
+	}
+}
+
+type sessionTabsFile struct {
+	Version int               `json:"version"`
+	Groups  []sessionTabGroup `json:"groups"`
+}
+
+type sessionTabGroup struct {
+	ID        string    `json:"id"`
+	Sessions  []string  `json:"sessions"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (t *SessionTabs) restoreLocked(sessionID string) {
+	if t.path == "" {
+		return
+	}
+	for _, group := range loadSessionTabGroups(t.path) {
+		for _, id := range group.Sessions {
+			if id != sessionID {
+				continue
+			}
+			t.groupID = group.ID
+			t.tabs = make([]sessionTab, 0, len(group.Sessions))
+			for _, member := range group.Sessions {
+				t.tabs = append(t.tabs, sessionTab{sessionID: member})
+			}
+			return
+		}
+	}
+}
+
+// saveLocked writes this strip's group, best effort. Sessions move to the
+// newest group that holds them, and a strip of one tab is not a group.
+func (t *SessionTabs) saveLocked() {
+	if t.path == "" {
+		return
+	}
+	if t.groupID == "" {
+		t.groupID = session.NewID()
+	}
+	ids := make([]string, 0, len(t.tabs))
+	members := make(map[string]bool, len(t.tabs))
+	for _, tab := range t.tabs {
+		ids = append(ids, tab.sessionID)
+		members[tab.sessionID] = true
+	}
+	var groups []sessionTabGroup
+	if len(ids) > 1 {
+		groups = append(groups, sessionTabGroup{ID: t.groupID, Sessions: ids, UpdatedAt: time.Now().UTC()})
+	}
+	for _, group := range loadSessionTabGroups(t.path) {
+		if group.ID == t.groupID {
+			continue
+		}
+		kept := group.Sessions[:0]
+		for _, id := range group.Sessions {
+			if !members[id] {
+				kept = append(kept, id)
+			}
+		}
+		if len(kept) > 1 {
+			group.Sessions = kept
+			groups = append(groups, group)
+		}
+		if len(groups) >= maxPersistedTabGroups {
+			break
+		}
+	}
+	data, err := json.MarshalIndent(sessionTabsFile{Version: sessionTabsFileVersion, Groups: groups}, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return
+	}
+	_ = os.WriteFile(t.path, data, 0o600)
+}
+
+func loadSessionTabGroups(path string) []sessionTabGroup {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var file sessionTabsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil // treat a corrupt file as empty
+	}
+	return file.Groups
+}
+
+// sessionTabLabel is the short tab title for sess.
+func sessionTabLabel(sess *session.Session) string {
+	if sess == nil {
+		return ""
+	}
+	for _, candidate := range []string{sess.Name, sess.GeneratedShortTitle, sess.Summary} {
+		label := terminaltext.SanitizeSingleLine(candidate)
+		if label = strings.TrimSpace(label); label == "" {
+			continue
+		}
+		if runes := []rune(label); len(runes) > sessionTabLabelWidth {
+			label = strings.TrimSpace(string(runes[:sessionTabLabelWidth-1])) + "…"
+		}
+		return label
+	}
+	if sess.Number > 0 {
+		return fmt.Sprintf("#%d", sess.Number)
+	}
+	return "new"
+}
+
+// SetSessionTabs shares the program's tab strip with this model.
+func (m *Model) SetSessionTabs(tabs *SessionTabs) {
+	m.tabs = tabs
+	m.syncSessionTab()
+	m.labelRestoredTabs()
+}
+
+// syncSessionTab keeps the strip pointed at this model's session, which can
+// change in place (/new) or be created lazily.
+func (m *Model) syncSessionTab() {
+	if m.tabs == nil || m.sessionTransition != nil {
+		return
+	}
+	m.tabs.Activate(m.SessionID(), sessionTabLabel(m.sess))
+}
+
+// labelRestoredTabs titles tabs restored from disk from the session store.
+func (m *Model) labelRestoredTabs() {
+	if m.tabs == nil || m.store == nil {
+		return
+	}
+	for _, tab := range m.tabs.snapshot() {
+		if tab.label != "" {
+			continue
+		}
+		label := session.ShortID(tab.sessionID)
+		if sess, err := m.store.Get(context.Background(), tab.sessionID); err == nil && sess != nil {
+			label = sessionTabLabel(sess)
+		}
+		m.tabs.setLabel(tab.sessionID, label)
+	}
+}
+
+// tabSwitchBlocker explains why the visible tab cannot be left right now.
+func (m *Model) tabSwitchBlocker() string {
+	switch {
+	case m.tabs == nil:
+		return "Tabs are not available in this chat."
+	case m.branchContextInFlight():
+		return "Wait for path notes to finish, or press Esc to cancel them before switching tabs."
+	case m.directShellRun != nil:
+		return "Cannot switch tabs while a shell command is running."
+	case m.activeSkillRunCount() > 0:
+		return "Cannot switch tabs while a skill is running."
+	case m.streaming && (m.mainRunManager == nil || !m.mainRunManager.HasActive(m.SessionID())):
+		return "Cannot switch tabs while work is active because background TUI runs are not available."
+	}
+	return ""
+}
+
+func (m *Model) switchToTab(index int) (tea.Model, tea.Cmd) {
+	tab, ok := m.tabs.at(index)
+	if !ok {
+		return m.showFooterWarning(fmt.Sprintf("No tab %d.", index+1))
+	}
+	if tab.sessionID == m.SessionID() {
+		return m, nil
+	}
+	return m.enterTab(tab)
+}
+
+func (m *Model) enterTab(tab sessionTab) (tea.Model, tea.Cmd) {
+	if blocker := m.tabSwitchBlocker(); blocker != "" {
+		return m.showFooterWarning(blocker)
+	}
+	m.clearSideQuestionHistory()
+	m.tabs.setDraft(m.SessionID(), m.textarea.Value())
+	return m.beginSessionSwitch(SessionSwitchRequest{
+		SessionID:     tab.sessionID,
+		TargetLabel:   tab.label,
+		BranchPrefill: m.tabs.takeDraft(tab.sessionID),
+	})
+}
+
+func (m *Model) cycleTab(delta int) (tea.Model, tea.Cmd) {
+	count := m.tabs.Len()
+	if count < 2 {
+		return m.showFooterMuted("Only one tab is open. Alt+T opens another.")
+	}
+	current := max(0, m.tabs.Index(m.SessionID()))
+	return m.switchToTab(((current+delta)%count + count) % count)
+}
+
+// openNewTab creates a fresh session with the current settings and opens it
+// in a tab next to this one, leaving this session running in the background.
+func (m *Model) openNewTab() (tea.Model, tea.Cmd) {
+	if blocker := m.tabSwitchBlocker(); blocker != "" {
+		return m.showFooterWarning(blocker)
+	}
+	if m.store == nil {
+		return m.showFooterWarning("Tabs need session storage.")
+	}
+	m.syncSessionTab()
+	sess := m.newChatSession()
+	if err := m.store.Create(context.Background(), sess); err != nil {
+		return m.showFooterError(fmt.Sprintf("Open tab: %v", err))
+	}
+	m.tabs.Insert(sess.ID, sessionTabLabel(sess))
+	return m.enterTab(sessionTab{sessionID: sess.ID, label: "New tab"})
+}
+
+func (m *Model) closeTab() (tea.Model, tea.Cmd) {
+	if m.tabs.Len() < 2 {
+		return m.showFooterWarning("This is the only open tab.")
+	}
+	if blocker := m.tabSwitchBlocker(); blocker != "" {
+		return m.showFooterWarning(blocker)
+	}
+	next, ok := m.tabs.Close(m.SessionID())
+	if !ok {
+		return m.showFooterWarning("This session is not an open tab.")
+	}
+	return m.enterTab(next)
+}
+
+func (m *Model) cmdTab(args []string) (tea.Model, tea.Cmd) {
+	m.setTextareaValue("")
+	if m.tabs == nil {
+		return m.showFooterWarning("Tabs are not available in this chat.")
+	}
+	if len(args) == 0 {
+		return m.showSystemMessage(m.describeTabs())
+	}
+	switch strings.ToLower(args[0]) {
+	case "new", "open":
+		return m.openNewTab()
+	case "close":
+		return m.closeTab()
+	case "next":
+		return m.cycleTab(1)
+	case "prev", "previous":
+		return m.cycleTab(-1)
+	case "list":
+		return m.showSystemMessage(m.describeTabs())
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return m.showFooterError("Usage: /tab [new|close|next|prev|<n>]")
+	}
+	return m.switchToTab(n - 1)
+}
+
+func (m *Model) describeTabs() string {
+	var b strings.Builder
+	b.WriteString("Open tabs\n")
+	current := m.SessionID()
+	for i, tab := range m.tabs.snapshot() {
+		marker := " "
+		if tab.sessionID == current {
+			marker = "▸"
+		}
+		fmt.Fprintf(&b, "  %s %d  %s", marker, i+1, tab.label)
+		if state := m.tabActivity(tab.sessionID); state != "" && tab.sessionID != current {
+			b.WriteString(" · " + state)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\nAlt+T opens a tab, Alt+. / Alt+, cycle, Alt+1…9 jump, /tab close closes this one.")
+	return b.String()
+}
+
+// tabActivity summarises a background tab's run: "needs input", "running" or
+// "new result".
+func (m *Model) tabActivity(sessionID string) string {
+	if m.mainRunManager == nil {
+		return ""
+	}
+	status := m.mainRunManager.Status(sessionID)
+	switch {
+	case status.NeedsInput:
+		return "needs input"
+	case status.Active:
+		return "running"
+	case status.Unvisited:
+		return "new result"
+	}
+	return ""
+}
+
+// handleTabKey switches tabs from the keyboard. It reports false for keys
+// that are not tab bindings.
+func (m *Model) handleTabKey(msg tea.KeyPressMsg) (bool, tea.Model, tea.Cmd) {
+	if m.tabs == nil {
+		return false, m, nil
+	}
+	switch {
+	case key.Matches(msg, m.keyMap.NewTab):
+		model, cmd := m.openNewTab()
+		return true, model, cmd
+	case key.Matches(msg, m.keyMap.NextTab):
+		model, cmd := m.cycleTab(1)
+		return true, model, cmd
+	case key.Matches(msg, m.keyMap.PrevTab):
+		model, cmd := m.cycleTab(-1)
+		return true, model, cmd
+	}
+	if digit, ok := strings.CutPrefix(msg.String(), "alt+"); ok && len(digit) == 1 && digit[0] >= '1' && digit[0] <= '9' {
+		model, cmd := m.switchToTab(int(digit[0] - '1'))
+		return true, model, cmd
+	}
+	return false, m, nil
+}
+
+// renderTabBar renders the tab strip when more than one tab is open. Inactive
+// tabs carry a badge: "!" for a pending approval or question, a green dot
+// while running, and a highlighted dot for an unseen result.
+func (m *Model) renderTabBar() string {
+	if m.tabs == nil {
+		return ""
+	}
+	m.syncSessionTab()
+	m.tabs.setLabel(m.SessionID(), sessionTabLabel(m.sess))
+	tabs := m.tabs.snapshot()
+	if len(tabs) < 2 {
+		return ""
+	}
+	theme := m.styles.Theme()
+	activeStyle := lipgloss.NewStyle().Foreground(theme.Primary).Bold(true)
+	mutedStyle := lipgloss.NewStyle().Foreground(theme.Muted)
+	needsInputStyle := lipgloss.NewStyle().Foreground(theme.Warning).Bold(true)
+	runningStyle := lipgloss.NewStyle().Foreground(theme.Success).Bold(true)
+	unseenStyle := lipgloss.NewStyle().Foreground(theme.Primary).Bold(true)
+	current := m.SessionID()
+
+	render := func(withLabels bool) string {
+		parts := make([]string, 0, len(tabs))
+		for i, tab := range tabs {
+			text := strconv.Itoa(i + 1)
+			if withLabels || tab.sessionID == current {
+				text += " " + tab.label
+			}
+			if tab.sessionID == current {
+				parts = append(parts, activeStyle.Render("▸"+text))
+				continue
+			}
+			part := mutedStyle.Render(" " + text)
+			if m.mainRunManager != nil {
+				status := m.mainRunManager.Status(tab.sessionID)
+				switch {
+				case status.NeedsInput:
+					part += " " + needsInputStyle.Render("!")
+				case status.Active:
+					part += " " + runningStyle.Render("●")
+				case status.Unvisited:
+					part += " " + unseenStyle.Render("●")
+				}
+			}
+			parts = append(parts, part)
+		}
+		return " " + strings.Join(parts, mutedStyle.Render("  "))
+	}
+	bar := render(true)
+	if m.width > 0 && lipgloss.Width(bar) > m.width {
+		bar = render(false)
+	}
+	return bar
+}
@@ -0,0 +1,177 @@
+package chat
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/samsaffron/term-llm/internal/session"
+	"github.com/samsaffron/term-llm/internal/tools"
+	"github.com/samsaffron/term-llm/internal/ui"
+)
+
+func tabIDs(tabs *SessionTabs) []string {
+	var ids []string
+	for _, tab := range tabs.snapshot() {
+		ids = append(ids, tab.sessionID)
+	}
+	return ids
+}
+
+func TestSessionTabsPersistAndRestoreGroup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat_tabs.json")
+	tabs := NewSessionTabs(path)
+	tabs.Activate("a", "alpha")
+	tabs.Insert("b", "beta")
+	tabs.Activate("b", "")
+
+	restored := NewSessionTabs(path)
+	restored.Activate("b", "")
+	if got := strings.Join(tabIDs(restored), ","); got != "a,b" {
+		t.Fatalf("restored tabs = %q", got)
+	}
+	if restored.Index("b") != 1 {
+		t.Fatalf("restored active index = %d", restored.Index("b"))
+	}
+
+	fresh := NewSessionTabs(path)
+	fresh.Activate("c", "")
+	if fresh.Len() != 1 {
+		t.Fatalf("unrelated session restored %v", tabIDs(fresh))
+	}
+
+	// Closing down to a single tab drops the group from disk.
+	if _, ok := tabs.Close("b"); !ok {
+		t.Fatal("close failed")
+	}
+	tabs.Activate("a", "")
+	again := NewSessionTabs(path)
+	again.Activate("a", "")
+	if again.Len() != 1 {
+		t.Fatalf("single-tab group was persisted: %v", tabIDs(again))
+	}
+}
+
+func TestSessionTabsRetargetAndCloseSemantics(t *testing.T) {
+	tabs := NewSessionTabs("")
+	tabs.Activate("a", "")
+	tabs.Insert("b", "")
+	tabs.Activate("b", "")
+
+	// Navigating to an unopened session (/resume, /fork) retargets the tab.
+	tabs.Activate("c", "")
+	if got := strings.Join(tabIDs(tabs), ","); got != "a,c" {
+		t.Fatalf("retargeted tabs = %q", got)
+	}
+
+	next, ok := tabs.Close("c")
+	if !ok || next.sessionID != "a" {
+		t.Fatalf("close neighbour = %+v ok=%v", next, ok)
+	}
+	// A failed switch re-activates the closing tab and keeps it.
+	tabs.Activate("c", "")
+	if tabs.Len() != 2 {
+		t.Fatalf("failed close removed tab: %v", tabIDs(tabs))
+	}
+	tabs.Close("c")
+	tabs.Activate("a", "")
+	if got := strings.Join(tabIDs(tabs), ","); got != "a" {
+		t.Fatalf("tabs after close = %q", got)
+	}
+}
+
+func TestTabKeySwitchesSessionAndKeepsDrafts(t *testing.T) {
+	tabs := NewSessionTabs("")
+	m := newTestChatModel(false)
+	m.keyMap = DefaultKeyMap()
+	m.sess = &session.Session{ID: "a", Name: "first"}
+	m.SetSessionTabs(tabs)
+	tabs.Insert("b", "second")
+
+	next := newTestChatModel(false)
+	next.keyMap = DefaultKeyMap()
+	next.sess = &session.Session{ID: "b", Name: "second"}
+	var got SessionSwitchRequest
+	m.SetSessionSwitcher(func(request SessionSwitchRequest) (*Model, error) {
+		got = request
+		next.SetSessionTabs(tabs)
+		return next, nil
+	})
+
+	m.setTextareaValue("unsent in a")
+	_, cmd := m.Update(tea.KeyPressMsg{Code: '2', Mod: tea.ModAlt})
+	switched, ok := searchSessionSwitchedMsg(cmd)
+	if !ok {
+		t.Fatal("alt+2 did not switch sessions")
+	}
+	if got.SessionID != "b" || got.TargetLabel != "second" {
+		t.Fatalf("switch request = %+v", got)
+	}
+	updated, _ := m.Update(switched)
+	if updated != next || tabs.Index("b") != 1 {
+		t.Fatalf("switch landed on %T", updated)
+	}
+
+	// Cycling back hands tab a its parked draft.
+	next.SetSessionSwitcher(func(request SessionSwitchRequest) (*Model, error) {
+		got = request
+		return newTestChatModel(false), nil
+	})
+	_, cmd = next.Update(tea.KeyPressMsg{Code: '.', Mod: tea.ModAlt})
+	if _, ok := searchSessionSwitchedMsg(cmd); !ok {
+		t.Fatal("alt+. did not switch sessions")
+	}
+	if got.SessionID != "a" || got.BranchPrefill != "unsent in a" {
+		t.Fatalf("cycle request = %+v", got)
+	}
+}
+
+func TestTabBarBadgesBackgroundTabs(t *testing.T) {
+	manager := NewMainRunManager(context.Background())
+	t.Cleanup(func() { manager.Close(time.Second) })
+	_, err := manager.Start("b", MainRunExecution{Execute: func(ctx context.Context, _ func(ui.StreamEvent)) error {
+		<-ctx.Done()
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tabs := NewSessionTabs("")
+	m := newTestChatModel(false)
+	m.width = 100
+	m.sess = &session.Session{ID: "a", Name: "first"}
+	m.SetMainRunManager(manager)
+	m.SetSessionTabs(tabs)
+	tabs.Insert("b", "second")
+
+	bar := ui.StripANSI(m.renderTabBar())
+	if !strings.Contains(bar, "▸1 first") || !strings.Contains(bar, "2 second ●") {
+		t.Fatalf("running badge missing: %q", bar)
+	}
+
+	doneCh := make(chan tools.ApprovalResult, 1)
+	if err := manager.DeliverUI("b", ApprovalRequestMsg{DoneCh: doneCh}); err != nil {
+		t.Fatal(err)
+	}
+	if !manager.Status("b").NeedsInput {
+		t.Fatal("retained approval not reported")
+	}
+	bar = ui.StripANSI(m.renderTabBar())
+	if !strings.Contains(bar, "2 second !") {
+		t.Fatalf("approval badge missing: %q", bar)
+	}
+	if !strings.Contains(m.describeTabs(), "needs input") {
+		t.Fatalf("tab list missing activity: %q", m.describeTabs())
+	}
+
+	single := newTestChatModel(false)
+	single.sess = &session.Session{ID: "solo"}
+	single.SetSessionTabs(NewSessionTabs(""))
+	if bar := single.renderTabBar(); bar != "" {
+		t.Fatalf("single tab rendered a bar: %q", bar)
+	}
+}
@@ -0,0 +1,543 @@
+package filechanges
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	tea "charm.land/bubbletea/v2"
+	"charm.land/lipgloss/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/samsaffron/term-llm/internal/filetrack"
+	"github.com/samsaffron/term-llm/internal/session"
+	"github.com/samsaffron/term-llm/internal/ui"
+)
+
+// CloseMsg asks the parent to close the embedded browser.
+type CloseMsg struct{}
+
+// RevertMsg asks the parent to restore recorded snapshots on disk. Label
+// describes the target for status messages.
+type RevertMsg struct {
+	Requests []filetrack.RevertRequest
+	Label    string
+}
+
+type loadResultMsg struct {
+	generation uint64
+	turns      []Turn
+	scope      int
+	files      []filetrack.CumulativeChange
+	err        error
+}
+
+type diffResultMsg struct {
+	generation uint64
+	path       string
+	content    *filetrack.FileDiffContent
+	err        error
+}
+
+type viewMode int
+
+const (
+	modeFiles viewMode = iota
+	modeDiff
+	modeConfirm
+)
+
+// Model is a full-screen review of the files a session changed. It shows
+// either the cumulative session diff or one turn's diff, and requests reverts
+// of hunks, files or whole turns from the parent.
+type Model struct {
+	store         *filetrack.Store
+	sessions      session.Store
+	sessionID     string
+	root          string
+	width, height int
+	styles        *ui.Styles
+
+	turns      []Turn
+	scope      int // 0 = whole session, otherwise a Turn.Number
+	files      []filetrack.CumulativeChange
+	cursor     int
+	mode       viewMode
+	returnMode viewMode
+	busy       bool
+	loading    bool
+	status     string
+	err        error
+	generation uint64
+
+	diffGeneration uint64
+	diffPath       string
+	diff           *filetrack.FileDiffContent
+	hunks          []filetrack.Hunk
+	hunk           int
+	diffScroll     int
+	diffErr        error
+
+	pending *RevertMsg
+}
+
+// New constructs an embedded file-change browser for sessionID. Paths under
+// root are shown relative to it.
+func New(store *filetrack.Store, sessions session.Store, sessionID, root string, width, height int, styles *ui.Styles) *Model {
+	if styles == nil {
+		styles = ui.DefaultStyles()
+	}
+	return &Model{store: store, sessions: sessions, sessionID: sessionID, root: root, width: width, height: height, styles: styles}
+}
+
+func (m *Model) Init() tea.Cmd { return m.Refresh() }
+
+// Refresh asynchronously reloads the turn list and the files in scope.
+func (m *Model) Refresh() tea.Cmd {
+	m.generation++
+	m.loading = true
+	generation, scope := m.generation, m.scope
+	store, sessions, sessionID := m.store, m.sessions, m.sessionID
+	return func() tea.Msg {
+		ctx := context.Background()
+		changes, err := store.ListChanges(ctx, sessionID)
+		if err != nil {
+			return loadResultMsg{generation: generation, err: err}
+		}
+		var messages []session.Message
+		if sessions != nil {
+			if messages, err = sessions.GetMessages(ctx, sessionID, 0, 0); err != nil {
+				return loadResultMsg{generation: generation, err: err}
+			}
+		}
+		turns := GroupTurns(messages, changes)
+		r, scope := scopeRange(turns, scope)
+		files, err := store.ListSessionChangesRange(ctx, sessionID, r)
+		return loadResultMsg{generation: generation, turns: turns, scope: scope, files: files, err: err}
+	}
+}
+
+// scopeRange resolves a scope to its sequence range, falling back to the
+// whole session when the turn no longer has changes.
+func scopeRange(turns []Turn, scope int) (filetrack.SeqRange, int) {
+	for _, t := range turns {
+		if t.Number == scope {
+			return t.Range, scope
+		}
+	}
+	return filetrack.SeqRange{}, 0
+}
+
+func (m *Model) loadDiff(path string) tea.Cmd {
+	m.diffGeneration++
+	generation := m.diffGeneration
+	store, sessionID := m.store, m.sessionID
+	r, _ := scopeRange(m.turns, m.scope)
+	return func() tea.Msg {
+		content, err := store.GetFileDiffContentRange(context.Background(), sessionID, path, r)
+		return diffResultMsg{generation: generation, path: path, content: content, err: err}
+	}
+}
+
+// ReportRevertResult reports a completed revert and reloads the view.
+func (m *Model) ReportRevertResult(err error, status string) tea.Cmd {
+	m.busy = false
+	m.err = err
+	m.status = ""
+	if err == nil {
+		m.status = status
+	}
+	cmds := []tea.Cmd{m.Refresh()}
+	if m.mode == modeDiff {
+		cmds = append(cmds, m.loadDiff(m.diffPath))
+	}
+	return tea.Batch(cmds...)
+}
+
+// SetBusy updates the browser-owned operation indicator.
+func (m *Model) SetBusy(busy bool, status string) {
+	m.busy, m.status = busy, status
+	if busy {
+		m.err = nil
+	}
+}
+
+// Error displays an action error without closing the browser.
+func (m *Model) Error(err error) { m.busy, m.err = false, err }
+
+func (m *Model) Files() []filetrack.CumulativeChange {
+	return append([]filetrack.CumulativeChange(nil), m.files...)
+}
+func (m *Model) Turns() []Turn { return append([]Turn(nil), m.turns...) }
+func (m *Model) Scope() int    { return m.scope }
+func (m *Model) Width() int    { return m.width }
+func (m *Model) Height() int   { return m.height }
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+	case loadResultMsg:
+		if msg.generation != m.generation {
+			return m, nil
+		}
+		m.loading = false
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.turns, m.scope, m.files = msg.turns, msg.scope, msg.files
+		m.clampCursor()
+		return m, nil
+	case diffResultMsg:
+		if msg.generation != m.diffGeneration || msg.path != m.diffPath {
+			return m, nil
+		}
+		m.diff, m.diffErr, m.hunks = msg.content, msg.err, nil
+		if msg.content != nil && !msg.content.Truncated && !msg.content.IsImage {
+			m.hunks = filetrack.BuildHunks(msg.content.Path, msg.content.Before, msg.content.After)
+		}
+		m.hunk = max(0, min(m.hunk, len(m.hunks)-1))
+		m.diffScroll = min(m.diffScroll, m.diffMaxScroll())
+		return m, nil
+	case tea.KeyPressMsg:
+		return m.handleKey(msg)
+	}
+	return m, nil
+}
+
+func (m *Model) handleKey(msg tea.KeyPressMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+	if m.busy {
+		if key == "esc" || key == "q" {
+			m.err = fmt.Errorf("a revert is still running")
+		}
+		return m, nil
+	}
+	switch m.mode {
+	case modeConfirm:
+		switch key {
+		case "y", "Y":
+			m.mode = m.returnMode
+			if pending := m.pending; pending != nil {
+				m.pending = nil
+				return m, func() tea.Msg { return *pending }
+			}
+		case "n", "N", "esc", "q":
+			m.mode, m.pending = m.returnMode, nil
+		}
+		return m, nil
+	case modeDiff:
+		return m.handleDiffKey(key)
+	}
+
+	switch key {
+	case "esc", "q":
+		return m, func() tea.Msg { return CloseMsg{} }
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case "pgup":
+		m.move(-m.viewportHeight())
+	case "pgdown":
+		m.move(m.viewportHeight())
+	case "g", "home":
+		m.cursor = 0
+	case "G", "end":
+		m.cursor = max(0, len(m.files)-1)
+	case "left", "[":
+		return m, m.cycleScope(-1)
+	case "right", "]":
+		return m, m.cycleScope(1)
+	case "enter":
+		if f := m.selected(); f != nil {
+			m.mode, m.diffPath, m.diff, m.hunks = modeDiff, f.Path, nil, nil
+			m.hunk, m.diffScroll, m.diffErr = 0, 0, nil
+			return m, m.loadDiff(f.Path)
+		}
+	case "r":
+		if f := m.selected(); f != nil {
+			m.confirmRevert(RevertMsg{
+				Requests: []filetrack.RevertRequest{m.request(f.Path, nil)},
+				Label:    m.displayPath(f.Path),
+			})
+		}
+	case "R":
+		if len(m.files) > 0 {
+			requests := make([]filetrack.RevertRequest, 0, len(m.files))
+			for _, f := range m.files {
+				requests = append(requests, m.request(f.Path, nil))
+			}
+			m.confirmRevert(RevertMsg{Requests: requests, Label: m.scopeLabel()})
+		}
+	case "ctrl+r":
+		return m, m.Refresh()
+	}
+	return m, nil
+}
+
+func (m *Model) handleDiffKey(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case "esc", "q":
+		m.mode = modeFiles
+		m.diff, m.hunks, m.diffPath = nil, nil, ""
+	case "up", "k":
+		m.diffScroll = max(0, m.diffScroll-1)
+	case "down", "j":
+		m.diffScroll = min(m.diffMaxScroll(), m.diffScroll+1)
+	case "pgup":
+		m.diffScroll = max(0, m.diffScroll-m.diffHeight())
+	case "pgdown":
+		m.diffScroll = min(m.diffMaxScroll(), m.diffScroll+m.diffHeight())
+	case "n", "tab":
+		m.selectHunk(m.hunk + 1)
+	case "p", "shift+tab":
+		m.selectHunk(m.hunk - 1)
+	case "r":
+		if m.hunkRevertable() {
+			m.confirmRevert(RevertMsg{
+				Requests: []filetrack.RevertRequest{m.request(m.diffPath, []int{m.hunk})},
+				Label:    fmt.Sprintf("hunk %d of %s", m.hunk+1, m.displayPath(m.diffPath)),
+			})
+		}
+	case "f":
+		if m.diff != nil {
+			m.confirmRevert(RevertMsg{
+				Requests: []filetrack.RevertRequest{m.request(m.diffPath, nil)},
+				Label:    m.displayPath(m.diffPath),
+			})
+		}
+	}
+	return m, nil
+}
+
+func (m *Model) request(path string, hunks []int) filetrack.RevertRequest {
+	r, _ := scopeRange(m.turns, m.scope)
+	return filetrack.RevertRequest{SessionID: m.sessionID, Path: path, Range: r, Hunks: hunks}
+}
+
+func (m *Model) confirmRevert(msg RevertMsg) {
+	m.pending = &msg
+	m.returnMode = m.mode
+	m.mode = modeConfirm
+	m.err = nil
+}
+
+// cycleScope steps between the whole-session view and each turn with changes.
+func (m *Model) cycleScope(delta int) tea.Cmd {
+	scopes := []int{0}
+	current := 0
+	for i, t := range m.turns {
+		scopes = append(scopes, t.Number)
+		if t.Number == m.scope {
+			current = i + 1
+		}
+	}
+	next := scopes[(current+delta+len(scopes))%len(scopes)]
+	if next == m.scope {
+		return nil
+	}
+	m.scope, m.cursor, m.status, m.err = next, 0, "", nil
+	return m.Refresh()
+}
+
+// hunkRevertable reports whether the selected hunk can be undone on its own.
+// Created and deleted files only revert as a whole.
+func (m *Model) hunkRevertable() bool {
+	return m.diff != nil && m.diff.Kind == filetrack.KindModify && m.hunk < len(m.hunks)
+}
+
+func (m *Model) selectHunk(i int) {
+	if len(m.hunks) == 0 {
+		return
+	}
+	m.hunk = max(0, min(i, len(m.hunks)-1))
+	offset := 0
+	for j := 0; j < m.hunk; j++ {
+		offset += len(m.hunks[j].Lines) + 1
+	}
+	m.diffScroll = min(m.diffMaxScroll(), offset)
+}
+
+func (m *Model) selected() *filetrack.CumulativeChange {
+	if m.cursor < 0 || m.cursor >= len(m.files) {
+		return nil
+	}
+	return &m.files[m.cursor]
+}
+func (m *Model) move(delta int) { m.cursor += delta; m.clampCursor() }
+func (m *Model) clampCursor()   { m.cursor = max(0, min(m.cursor, len(m.files)-1)) }
+
+func (m *Model) viewportHeight() int { return ui.RemainingLines(m.height, 5) }
+func (m *Model) diffHeight() int     { return ui.RemainingLines(m.height, 3) }
+func (m *Model) diffMaxScroll() int  { return max(0, len(m.diffLines())-m.diffHeight()) }
+
+func (m *Model) scopeLabel() string {
+	for _, t := range m.turns {
+		if t.Number == m.scope {
+			if t.Prompt == "" {
+				return fmt.Sprintf("turn %d", t.Number)
+			}
+			return fmt.Sprintf("turn %d: %s", t.Number, t.Prompt)
+		}
+	}
+	return "all session changes"
+}
+
+func (m *Model) displayPath(path string) string {
+	if m.root != "" {
+		if rel, err := filepath.Rel(m.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return path
+}
+
+func (m *Model) View() tea.View {
+	width := max(1, m.width)
+	theme := m.styles.Theme()
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(theme.Text).Background(theme.Border).Padding(0, 1).Width(width)
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	errStyle := lipgloss.NewStyle().Foreground(theme.Error)
+	var b strings.Builder
+
+	if m.mode == modeDiff || (m.mode == modeConfirm && m.returnMode == modeDiff) {
+		b.WriteString(headerStyle.Render(fit("Diff: "+m.displayPath(m.diffPath)+"  ("+m.scopeLabel()+")", width)))
+		b.WriteByte('\n')
+		lines := m.diffLines()
+		h := m.diffHeight()
+		scroll := min(m.diffScroll, max(0, len(lines)-h))
+		end := min(len(lines), scroll+h)
+		for _, line := range lines[scroll:end] {
+			b.WriteString(fit(line, width))
+			b.WriteByte('\n')
+		}
+		for i := end - scroll; i < h; i++ {
+			b.WriteByte('\n')
+		}
+		b.WriteString(m.footer(width, "n/p hunk  ↑/↓ scroll  [r] revert hunk  [f] revert file  q/Esc back", muted, errStyle))
+		return ui.NewAltScreenView(b.String())
+	}
+
+	b.WriteString(headerStyle.Render(fit("File Changes", width)))
+	b.WriteByte('\n')
+	scope := "◂ " + m.scopeLabel() + " ▸"
+	if len(m.turns) > 0 {
+		scope += fmt.Sprintf("  (%d turns with changes)", len(m.turns))
+	}
+	b.WriteString(muted.Render(fit(scope, width)))
+	b.WriteByte('\n')
+	b.WriteString(muted.Render(fit("  kind      +/-  path", width)))
+	b.WriteByte('\n')
+
+	selected := lipgloss.NewStyle().Bold(true).Foreground(theme.Text).Background(theme.Primary)
+	h := m.viewportHeight()
+	start, end := ui.VisibleRange(len(m.files), m.cursor, h)
+	if len(m.files) == 0 {
+		note := "  No file changes recorded."
+		if m.loading {
+			note = "  Loading…"
+		}
+		b.WriteString(muted.Render(fit(note, width)))
+		b.WriteByte('\n')
+		end = start + 1
+	}
+	for i := start; i < end && i < len(m.files); i++ {
+		f := m.files[i]
+		counts := fmt.Sprintf("+%d -%d", f.Adds, f.Dels)
+		if f.Truncated {
+			counts = "–"
+		}
+		row := fit(fmt.Sprintf("  %-7s %8s  %s", f.Kind, counts, m.displayPath(f.Path)), width)
+		if i == m.cursor {
+			b.WriteString(selected.Render(row))
+		} else {
+			b.WriteString(row)
+		}
+		b.WriteByte('\n')
+	}
+	for i := end - start; i < h; i++ {
+		b.WriteByte('\n')
+	}
+	b.WriteString(strings.Repeat("─", width))
+	b.WriteByte('\n')
+	b.WriteString(m.footer(width, "[enter] diff  ←/→ turn  [r] revert file  [R] revert all shown  [q] back", muted, errStyle))
+	return ui.NewAltScreenView(b.String())
+}
+
+func (m *Model) footer(width int, help string, muted, errStyle lipgloss.Style) string {
+	switch {
+	case m.mode == modeConfirm && m.pending != nil:
+		return muted.Render(fit(fmt.Sprintf("Revert %s to its recorded snapshot? (y/n)", m.pending.Label), width))
+	case m.busy && m.status != "":
+		return muted.Render(fit(m.status, width))
+	case m.err != nil:
+		return errStyle.Render(fit("Error: "+m.err.Error(), width))
+	case m.status != "":
+		return muted.Render(fit(m.status+"  ·  "+help, width))
+	}
+	return muted.Render(fit(help, width))
+}
+
+func (m *Model) diffLines() []string {
+	switch {
+	case m.diffErr != nil:
+		return []string{"Error: " + m.diffErr.Error()}
+	case m.diff == nil && m.diffPath != "":
+		return []string{"Loading…"}
+	case m.diff == nil:
+		return []string{"No changes."}
+	case m.diff.IsImage:
+		return []string{"Image change; preview it in the web UI. The whole file can still be reverted."}
+	case m.diff.Truncated:
+		return []string{"Diff content was not retained for this file (too large, unsupported binary, or unrecoverable)."}
+	case len(m.hunks) == 0:
+		return []string{"No line changes."}
+	}
+	theme := m.styles.Theme()
+	add := lipgloss.NewStyle().Foreground(theme.Success)
+	del := lipgloss.NewStyle().Foreground(theme.Error)
+	head := lipgloss.NewStyle().Foreground(theme.Muted)
+	current := lipgloss.NewStyle().Bold(true).Foreground(theme.Primary)
+
+	var lines []string
+	for i, h := range m.hunks {
+		marker, style := "  ", head
+		if i == m.hunk {
+			marker, style = "▶ ", current
+		}
+		lines = append(lines, style.Render(fmt.Sprintf("%s@@ -%d +%d @@  hunk %d/%d", marker, h.OldStart, h.NewStart, i+1, len(m.hunks))))
+		for _, line := range h.Lines {
+			text := strings.ReplaceAll(line.S, "\t", "    ")
+			switch line.T {
+			case "add":
+				lines = append(lines, add.Render("  +"+text))
+			case "del":
+				lines = append(lines, del.Render("  -"+text))
+			default:
+				lines = append(lines, "   "+text)
+			}
+		}
+	}
+	return lines
+}
+
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "�")
+	}
+	current := ansi.StringWidth(s)
+	if current > width {
+		return ansi.Truncate(s, width, "...")
+	}
+	if current < width {
+		return s + strings.Repeat(" ", width-current)
+	}
+	return s
+}
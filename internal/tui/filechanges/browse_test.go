@@ -0,0 +1,171 @@
+package filechanges
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/samsaffron/term-llm/internal/filetrack"
+	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/session"
+	"github.com/samsaffron/term-llm/internal/ui"
+)
+
+func press(m *Model, code rune, text string) tea.Cmd {
+	_, cmd := m.Update(tea.KeyPressMsg{Code: code, Text: text})
+	return cmd
+}
+
+// run drains a command, feeding results back into the model, and returns the
+// first message the model does not consume itself.
+func run(t *testing.T, m *Model, cmd tea.Cmd) tea.Msg {
+	t.Helper()
+	for cmd != nil {
+		msg := cmd()
+		switch msg := msg.(type) {
+		case tea.BatchMsg:
+			var out tea.Msg
+			for _, c := range msg {
+				if got := run(t, m, c); got != nil {
+					out = got
+				}
+			}
+			return out
+		case loadResultMsg, diffResultMsg:
+			_, cmd = m.Update(msg)
+		default:
+			return msg
+		}
+	}
+	return nil
+}
+
+func toolTurn(prompt string, callIDs ...string) []session.Message {
+	msgs := []session.Message{{Role: llm.RoleUser, TextContent: prompt, Parts: []llm.Part{{Type: llm.PartText, Text: prompt}}}}
+	var parts []llm.Part
+	for _, id := range callIDs {
+		parts = append(parts, llm.Part{Type: llm.PartToolCall, ToolCall: &llm.ToolCall{ID: id, Name: "edit_file"}})
+	}
+	msgs = append(msgs, session.Message{Role: llm.RoleAssistant, Parts: parts})
+	for _, id := range callIDs {
+		msgs = append(msgs, session.Message{Role: llm.RoleUser, Parts: []llm.Part{{Type: llm.PartToolResult, ToolResult: &llm.ToolResult{ID: id}}}})
+	}
+	return msgs
+}
+
+func TestGroupTurnsAttributesChangesByToolCall(t *testing.T) {
+	var messages []session.Message
+	messages = append(messages, toolTurn("fix the parser\nplease", "c1", "c2")...)
+	messages = append(messages, toolTurn("just chat")...)
+	messages = append(messages, toolTurn("add tests", "c3")...)
+	changes := []filetrack.Change{
+		{Seq: 1, Path: "/a", ToolCallID: "c1"},
+		{Seq: 2, Path: "/b", ToolCallID: "c2"},
+		{Seq: 3, Path: "/a", ToolCallID: "c2"},
+		{Seq: 4, Path: "/a", ToolName: filetrack.RevertToolName},
+		{Seq: 5, Path: "/c", ToolCallID: "c3"},
+	}
+	turns := GroupTurns(messages, changes)
+	if len(turns) != 2 {
+		t.Fatalf("turns = %+v", turns)
+	}
+	if turns[0].Number != 1 || turns[0].Prompt != "fix the parser" || turns[0].Range != (filetrack.SeqRange{From: 1, To: 3}) || turns[0].Files != 2 {
+		t.Fatalf("first turn = %+v", turns[0])
+	}
+	if turns[1].Number != 3 || turns[1].Range != (filetrack.SeqRange{From: 5, To: 5}) {
+		t.Fatalf("second turn = %+v", turns[1])
+	}
+}
+
+func TestBrowserScopesTurnsAndRequestsReverts(t *testing.T) {
+	ctx := context.Background()
+	store, err := filetrack.Open(filepath.Join(t.TempDir(), "file_history.db"), filetrack.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	sessions, err := session.NewStore(session.Config{Enabled: true, Path: ":memory:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sessions.Close() })
+	if err := sessions.Create(ctx, &session.Session{ID: "s", Mode: "chat"}); err != nil {
+		t.Fatal(err)
+	}
+	var messages []session.Message
+	messages = append(messages, toolTurn("first", "c1")...)
+	messages = append(messages, toolTurn("second", "c2")...)
+	for i := range messages {
+		messages[i].Sequence = i
+		if err := sessions.AddMessage(ctx, "s", &messages[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := t.TempDir()
+	a, b := filepath.Join(root, "a.txt"), filepath.Join(root, "b.txt")
+	for _, rec := range []filetrack.ChangeRecord{
+		{SessionID: "s", ToolCallID: "c1", Path: a, Before: []byte("one\n"), After: []byte("ONE\n")},
+		{SessionID: "s", ToolCallID: "c2", Path: b, BeforeMissing: true, After: []byte("new\n")},
+		{SessionID: "s", ToolCallID: "c2", Path: a, Before: []byte("ONE\n"), After: []byte("ONE\ntwo\n")},
+	} {
+		if _, err := store.RecordChange(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := New(store, sessions, "s", root, 100, 20, ui.DefaultStyles())
+	run(t, m, m.Init())
+	if got := len(m.Files()); got != 2 || len(m.Turns()) != 2 {
+		t.Fatalf("files = %d turns = %+v", got, m.Turns())
+	}
+	if view := ui.StripANSI(m.View().Content); !strings.Contains(view, "all session changes") || !strings.Contains(view, "b.txt") {
+		t.Fatalf("view missing scope or relative path:\n%s", view)
+	}
+
+	// Step to the last turn and revert everything it changed.
+	run(t, m, press(m, '[', "["))
+	if m.Scope() != 2 {
+		t.Fatalf("scope = %d, want turn 2", m.Scope())
+	}
+	if cmd := press(m, 'R', "R"); cmd != nil {
+		t.Fatal("revert ran without confirmation")
+	}
+	if !strings.Contains(ui.StripANSI(m.View().Content), "Revert turn 2: second") {
+		t.Fatalf("confirmation missing:\n%s", ui.StripANSI(m.View().Content))
+	}
+	revert, ok := press(m, 'y', "y")().(RevertMsg)
+	if !ok || len(revert.Requests) != 2 {
+		t.Fatalf("revert msg = %+v", revert)
+	}
+	for _, req := range revert.Requests {
+		if req.Range != (filetrack.SeqRange{From: 2, To: 3}) || req.Hunks != nil {
+			t.Fatalf("turn request = %+v", req)
+		}
+	}
+
+	// A hunk revert from the diff view carries the hunk index.
+	m.cursor = 0
+	_, cmd := m.Update(tea.KeyPressMsg{Code: tea.KeyEnter})
+	run(t, m, cmd)
+	if len(m.hunks) != 1 {
+		t.Fatalf("hunks = %d", len(m.hunks))
+	}
+	press(m, 'r', "r")
+	hunk, ok := press(m, 'y', "y")().(RevertMsg)
+	if !ok || len(hunk.Requests) != 1 || len(hunk.Requests[0].Hunks) != 1 || hunk.Requests[0].Hunks[0] != 0 {
+		t.Fatalf("hunk revert msg = %+v", hunk)
+	}
+	if cmd := press(m, 'q', "q"); cmd != nil {
+		t.Fatal("q in the diff view closed the browser")
+	}
+	if _, ok := press(m, 'q', "q")().(CloseMsg); !ok {
+		t.Fatal("q in the list did not close the browser")
+	}
+	if _, err := os.Stat(a); !os.IsNotExist(err) {
+		t.Fatal("browser wrote files itself; reverts belong to the parent")
+	}
+}
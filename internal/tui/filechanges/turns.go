@@ -0,0 +1,86 @@
+package filechanges
+
+import (
+	"strings"
+
+	"github.com/samsaffron/term-llm/internal/filetrack"
+	"github.com/samsaffron/term-llm/internal/llm"
+	"github.com/samsaffron/term-llm/internal/session"
+)
+
+// Turn is the span of recorded file changes made while answering one user
+// message.
+type Turn struct {
+	Number int // 1-based user message number in the transcript
+	Prompt string
+	Range  filetrack.SeqRange
+	Files  int
+}
+
+// GroupTurns attributes change rows to the user turns whose tool calls made
+// them, in transcript order. Rows without a known tool call (such as reverts)
+// belong to no turn. Turns run sequentially, so a turn's range spans exactly
+// the rows recorded while it was active.
+func GroupTurns(messages []session.Message, changes []filetrack.Change) []Turn {
+	turnByCall := make(map[string]int)
+	var prompts []string
+	for _, msg := range messages {
+		switch msg.Role {
+		case llm.RoleUser:
+			if isToolResultMessage(msg) {
+				continue
+			}
+			prompts = append(prompts, firstLine(msg.TextContent))
+		case llm.RoleAssistant:
+			if len(prompts) == 0 {
+				continue
+			}
+			for _, part := range msg.Parts {
+				if part.ToolCall != nil && part.ToolCall.ID != "" {
+					turnByCall[part.ToolCall.ID] = len(prompts)
+				}
+			}
+		}
+	}
+
+	var turns []Turn
+	index := make(map[int]int)
+	paths := make(map[int]map[string]struct{})
+	for _, change := range changes {
+		number, ok := turnByCall[change.ToolCallID]
+		if !ok || change.ToolCallID == "" {
+			continue
+		}
+		i, seen := index[number]
+		if !seen {
+			i = len(turns)
+			index[number] = i
+			paths[number] = make(map[string]struct{})
+			turns = append(turns, Turn{Number: number, Prompt: prompts[number-1], Range: filetrack.SeqRange{From: change.Seq}})
+		}
+		turns[i].Range.To = change.Seq
+		paths[number][change.Path] = struct{}{}
+		turns[i].Files = len(paths[number])
+	}
+	return turns
+}
+
+func isToolResultMessage(msg session.Message) bool {
+	if len(msg.Parts) == 0 {
+		return false
+	}
+	for _, part := range msg.Parts {
+		if part.ToolResult == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s
+}
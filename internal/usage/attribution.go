@@ -0,0 +1,28 @@
+package usage
+
+import "context"
+
+// Attribution identifies who usage is charged to, such as a serve API key.
+type Attribution struct {
+	// APIKey is the key name written to LogEntry.APIKey.
+	APIKey string
+	// Record, when set, is called with every entry logged under this
+	// attribution so callers can enforce quotas.
+	Record func(LogEntry)
+}
+
+type attributionContextKey struct{}
+
+// WithAttribution returns a context whose usage is attributed to a.
+func WithAttribution(ctx context.Context, a Attribution) context.Context {
+	return context.WithValue(ctx, attributionContextKey{}, a)
+}
+
+// AttributionFromContext returns the attribution stored in ctx, if any.
+func AttributionFromContext(ctx context.Context) (Attribution, bool) {
+	if ctx == nil {
+		return Attribution{}, false
+	}
+	a, ok := ctx.Value(attributionContextKey{}).(Attribution)
+	return a, ok
+}
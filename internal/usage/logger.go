@@ -21,6 +21,7 @@ type LogEntry struct {
 	CacheReadTokens     int       `json:"cache_read_tokens,omitempty"`
 	CostUSD             float64   `json:"cost_usd,omitempty"`
 	TrackedExternallyBy string    `json:"tracked_externally_by,omitempty"`
+	APIKey              string    `json:"api_key,omitempty"`
 }
 
 // Logger writes usage entries to daily JSONL files
@@ -91,6 +91,7 @@ func loadTermLLMFile(path string) ([]UsageEntry, []error) {
 			CostUSD:             entry.CostUSD,
 			Provider:            ProviderTermLLM,
 			TrackedExternallyBy: entry.TrackedExternallyBy,
+			APIKey:              entry.APIKey,
 		})
 	}
 
@@ -21,6 +21,7 @@ type UsageEntry struct {
 	CostUSD             float64 // Pre-calculated cost if available
 	Provider            string  // Usage data source, such as ProviderClaudeCode or ProviderTermLLM
 	TrackedExternallyBy string  // External tracker name, or empty for direct API usage
+	APIKey              string  // serve API key name the usage is attributed to, if any
 }
 
 // TotalTokens returns the sum of all token types
@@ -77,6 +78,7 @@ type FilterOptions struct {
 	Until           time.Time // Include entries on or before this time
 	Provider        string    // Filter to specific provider, or empty for all
 	IncludeExternal bool      // Include term-llm entries with TrackedExternallyBy set (opt-in)
+	APIKey          string    // Filter to entries attributed to a serve API key name
 }
 
 // Filter returns entries matching the filter options
@@ -87,6 +89,9 @@ func (r LoadResult) Filter(opts FilterOptions) []UsageEntry {
 		if opts.Provider != "" && e.Provider != opts.Provider {
 			continue
 		}
+		if opts.APIKey != "" && e.APIKey != opts.APIKey {
+			continue
+		}
 		// Check date range
 		if !opts.Since.IsZero() && e.Timestamp.Before(opts.Since) {
 			continue
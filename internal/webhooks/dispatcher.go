@@ -0,0 +1,381 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	EventResponseCompleted = "response.completed"
+	EventResponseFailed    = "response.failed"
+	EventApprovalPending   = "approval.pending"
+	EventAskUserPending    = "ask_user.pending"
+	EventJobRunFinished    = "job_run.finished"
+	EventDelegationUpdated = "delegation.updated"
+	EventSessionCompacted  = "session.compacted"
+	// EventPing is only sent by 'serve webhooks test'.
+	EventPing = "ping"
+)
+
+// EventTypes lists the event types endpoints can subscribe to.
+var EventTypes = []string{
+	EventResponseCompleted,
+	EventResponseFailed,
+	EventApprovalPending,
+	EventAskUserPending,
+	EventJobRunFinished,
+	EventDelegationUpdated,
+	EventSessionCompacted,
+}
+
+// Request headers. They match the generic signature style accepted by
+// webhook-triggered jobs, so one term-llm server can drive another's jobs.
+const (
+	HeaderEvent     = "X-Event-Type"
+	HeaderDelivery  = "X-Delivery-ID"
+	HeaderSignature = "X-Signature-256"
+)
+
+const (
+	queueSize     = 256
+	batchSize     = 16
+	pollInterval  = 5 * time.Second
+	sendTimeout   = 10 * time.Second
+	retryBase     = 15 * time.Second
+	retryMax      = time.Hour
+	maxAttempts   = 10
+	retention     = 7 * 24 * time.Hour
+	pruneInterval = time.Hour
+	// maxErrorBody bounds the response body kept as the last error.
+	maxErrorBody = 512
+)
+
+// Endpoint is a configured receiver.
+type Endpoint struct {
+	Name   string
+	URL    string
+	Secret string
+	// Events selects event types; empty means all. An entry ending in ".*"
+	// matches every type with that prefix.
+	Events []string
+}
+
+// Wants reports whether the endpoint subscribes to eventType.
+func (e Endpoint) Wants(eventType string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, pattern := range e.Events {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "*" || pattern == eventType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateEndpoints checks that endpoints have unique names, http(s) URLs
+// and known event types.
+func ValidateEndpoints(endpoints []Endpoint) error {
+	seen := make(map[string]bool, len(endpoints))
+	for i, ep := range endpoints {
+		name := strings.TrimSpace(ep.Name)
+		if name == "" {
+			return fmt.Errorf("webhook %d: name is required", i+1)
+		}
+		if seen[name] {
+			return fmt.Errorf("webhook %q: duplicate name", name)
+		}
+		seen[name] = true
+		u, err := url.Parse(strings.TrimSpace(ep.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook %q: url must be an http or https URL", name)
+		}
+		for _, pattern := range ep.Events {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "*" || slices.Contains(EventTypes, pattern) {
+				continue
+			}
+			if prefix, ok := strings.CutSuffix(pattern, "*"); ok && slices.ContainsFunc(EventTypes, func(t string) bool { return strings.HasPrefix(t, prefix) }) {
+				continue
+			}
+			return fmt.Errorf("webhook %q: unknown event %q (valid: %s)", name, pattern, strings.Join(EventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// Event is the JSON body of every delivery.
+type Event struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// NewEvent builds an event with a fresh ID, marshalling data now so later
+// changes to it are not reflected in the delivery.
+func NewEvent(eventType string, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+	id, err := newID("evt_")
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{ID: id, Type: eventType, CreatedAt: time.Now().UTC(), Data: raw}, nil
+}
+
+// Sign returns the X-Signature-256 value for body: "sha256=" and the hex
+// HMAC-SHA256 of body keyed by secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post makes one delivery attempt and returns the response status code.
+// Any non-2xx status is an error.
+func Post(ctx context.Context, client *http.Client, ep Endpoint, deliveryID, eventType string, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "term-llm-webhooks/1")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	if ep.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(ep.Secret, payload))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return resp.StatusCode, nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		msg = http.StatusText(resp.StatusCode)
+	}
+	return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, msg)
+}
+
+// retryable reports whether a failed attempt with this status may succeed
+// later. Other 4xx responses mean the receiver rejected the payload.
+func retryable(code int) bool {
+	return code == 0 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// backoff returns the delay before retrying after the given attempt.
+func backoff(attempt int) time.Duration {
+	delay := retryBase
+	for i := 1; i < attempt && delay < retryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMax)
+}
+
+// Dispatcher queues events for matching endpoints and delivers them.
+type Dispatcher struct {
+	store     *Store
+	endpoints []Endpoint
+	client    *http.Client
+	queue     chan Event
+	logf      func(format string, args ...any)
+
+	// Test hooks.
+	pollInterval time.Duration
+	backoff      func(attempt int) time.Duration
+}
+
+// NewDispatcher returns a dispatcher for endpoints that logs deliveries in
+// store. Call Run to start delivering.
+func NewDispatcher(store *Store, endpoints []Endpoint) *Dispatcher {
+	return &Dispatcher{
+		store:        store,
+		endpoints:    endpoints,
+		client:       &http.Client{Timeout: sendTimeout},
+		queue:        make(chan Event, queueSize),
+		logf:         log.Printf,
+		pollInterval: pollInterval,
+		backoff:      backoff,
+	}
+}
+
+// Emit queues an event for every endpoint subscribed to eventType. It never
+// blocks; if the queue is full the event is dropped and logged. A nil
+// dispatcher ignores events.
+func (d *Dispatcher) Emit(eventType string, data any) {
+	if d == nil || !slices.ContainsFunc(d.endpoints, func(ep Endpoint) bool { return ep.Wants(eventType) }) {
+		return
+	}
+	event, err := NewEvent(eventType, data)
+	if err != nil {
+		d.logf("[webhooks] %v", err)
+		return
+	}
+	select {
+	case d.queue <- event:
+	default:
+		d.logf("[webhooks] queue full; dropping %s event %s", eventType, event.ID)
+	}
+}
+
+// Run records emitted events and delivers due deliveries until ctx is
+// cancelled. Deliveries still pending at shutdown resume on the next start.
+func (d *Dispatcher) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	lastPrune := time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			d.drain()
+			return
+		case event := <-d.queue:
+			d.record(ctx, event)
+		case <-timer.C:
+		}
+		if time.Since(lastPrune) > pruneInterval {
+			if _, err := d.store.Prune(ctx, time.Now().Add(-retention)); err != nil && ctx.Err() == nil {
+				d.logf("[webhooks] prune delivery log: %v", err)
+			}
+			lastPrune = time.Now()
+		}
+		d.deliverDue(ctx)
+		timer.Reset(d.nextWake(ctx))
+	}
+}
+
+// drain records events emitted just before shutdown so they are delivered
+// after the next start.
+func (d *Dispatcher) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		select {
+		case event := <-d.queue:
+			d.record(ctx, event)
+		default:
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) record(ctx context.Context, event Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		d.logf("[webhooks] encode event %s: %v", event.ID, err)
+		return
+	}
+	var deliveries []*Delivery
+	for _, ep := range d.endpoints {
+		if ep.Wants(event.Type) {
+			deliveries = append(deliveries, &Delivery{
+				EventID: event.ID, EventType: event.Type, Endpoint: ep.Name, URL: ep.URL, Payload: payload,
+			})
+		}
+	}
+	if err := d.store.Enqueue(ctx, deliveries...); err != nil {
+		d.logf("[webhooks] record %s event %s: %v", event.Type, event.ID, err)
+	}
+}
+
+// nextWake returns how long to sleep: until the next retry is due, but no
+// longer than the poll interval so CLI retries are noticed promptly.
+func (d *Dispatcher) nextWake(ctx context.Context) time.Duration {
+	wait := d.pollInterval
+	if next, err := d.store.NextDue(ctx); err == nil && !next.IsZero() {
+		wait = min(wait, max(time.Until(next), 0))
+	}
+	return wait
+}
+
+func (d *Dispatcher) endpoint(name string) (Endpoint, bool) {
+	for _, ep := range d.endpoints {
+		if ep.Name == name {
+			return ep, true
+		}
+	}
+	return Endpoint{}, false
+}
+
+// deliverDue attempts every due delivery, a batch at a time in parallel.
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := d.store.Due(ctx, batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				d.logf("[webhooks] load due deliveries: %v", err)
+			}
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+		var wg sync.WaitGroup
+		for _, delivery := range due {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.attempt(ctx, delivery)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery) {
+	ep, ok := d.endpoint(delivery.Endpoint)
+	if !ok {
+		_ = d.store.MarkAttemptFailed(ctx, delivery.ID, 0, "endpoint is no longer configured", time.Time{})
+		return
+	}
+	code, err := Post(ctx, d.client, ep, delivery.ID, delivery.EventType, delivery.Payload)
+	if ctx.Err() != nil {
+		// Shutting down; leave it pending for the next start.
+		return
+	}
+	if err == nil {
+		if err := d.store.MarkDelivered(ctx, delivery.ID, code); err != nil {
+			d.logf("[webhooks] record delivery %s: %v", delivery.ID, err)
+		}
+		return
+	}
+	attempt := delivery.Attempts + 1
+	var next time.Time
+	if retryable(code) && attempt < maxAttempts {
+		next = time.Now().Add(d.backoff(attempt))
+		d.logf("[webhooks] %s delivery %s to %s failed (attempt %d), retrying at %s: %v",
+			delivery.EventType, delivery.ID, ep.Name, attempt, next.Format(time.RFC3339), err)
+	} else {
+		d.logf("[webhooks] %s delivery %s to %s failed after %d attempts: %v",
+			delivery.EventType, delivery.ID, ep.Name, attempt, err)
+	}
+	if err := d.store.MarkAttemptFailed(ctx, delivery.ID, code, err.Error(), next); err != nil {
+		d.logf("[webhooks] record delivery %s: %v", delivery.ID, err)
+	}
+}
@@ -0,0 +1,202 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEndpointWants(t *testing.T) {
+	tests := []struct {
+		events []string
+		event  string
+		want   bool
+	}{
+		{nil, EventJobRunFinished, true},
+		{[]string{"*"}, EventSessionCompacted, true},
+		{[]string{EventApprovalPending}, EventApprovalPending, true},
+		{[]string{EventApprovalPending}, EventAskUserPending, false},
+		{[]string{"response.*"}, EventResponseFailed, true},
+		{[]string{"response.*"}, EventJobRunFinished, false},
+	}
+	for _, tt := range tests {
+		if got := (Endpoint{Events: tt.events}).Wants(tt.event); got != tt.want {
+			t.Errorf("Endpoint{Events: %v}.Wants(%q) = %v, want %v", tt.events, tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestValidateEndpoints(t *testing.T) {
+	valid := Endpoint{Name: "ops", URL: "https://ops.example/hook", Events: []string{"response.*", EventJobRunFinished}}
+	if err := ValidateEndpoints([]Endpoint{valid}); err != nil {
+		t.Fatalf("valid endpoint: %v", err)
+	}
+	tests := []struct {
+		name      string
+		endpoints []Endpoint
+		want      string
+	}{
+		{"missing name", []Endpoint{{URL: "https://x.example"}}, "name is required"},
+		{"duplicate", []Endpoint{valid, valid}, "duplicate name"},
+		{"bad url", []Endpoint{{Name: "x", URL: "ftp://x.example"}}, "http or https"},
+		{"unknown event", []Endpoint{{Name: "x", URL: "https://x.example", Events: []string{"response.started"}}}, "unknown event"},
+		{"unknown group", []Endpoint{{Name: "x", URL: "https://x.example", Events: []string{"tool.*"}}}, "unknown event"},
+	}
+	for _, tt := range tests {
+		if err := ValidateEndpoints(tt.endpoints); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if got := backoff(1); got != retryBase {
+		t.Fatalf("backoff(1) = %v", got)
+	}
+	if got := backoff(3); got != 4*retryBase {
+		t.Fatalf("backoff(3) = %v", got)
+	}
+	if got := backoff(50); got != retryMax {
+		t.Fatalf("backoff(50) = %v", got)
+	}
+}
+
+type recordedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// startDispatcher runs a dispatcher against a fresh store with fast polling
+// and no retry delay.
+func startDispatcher(t *testing.T, endpoints []Endpoint) (*Dispatcher, *Store) {
+	t.Helper()
+	store := openTestStore(t)
+	d := NewDispatcher(store, endpoints)
+	d.pollInterval = 10 * time.Millisecond
+	d.backoff = func(int) time.Duration { return 0 }
+	d.logf = t.Logf
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return d, store
+}
+
+func waitForDeliveries(t *testing.T, store *Store, status string, n int) []Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := store.List(context.Background(), ListOptions{Status: status})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) >= n {
+			return got
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d %s deliveries; have %d", n, status, len(got))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDispatcherDeliversSignedEvents(t *testing.T) {
+	var mu sync.Mutex
+	var requests []recordedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, recordedRequest{header: r.Header.Clone(), body: body})
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d, store := startDispatcher(t, []Endpoint{
+		{Name: "ops", URL: srv.URL, Secret: "s3cret", Events: []string{"response.*"}},
+		{Name: "jobs", URL: srv.URL, Events: []string{EventJobRunFinished}},
+	})
+	d.Emit(EventResponseCompleted, map[string]string{"response_id": "resp_1"})
+
+	delivered := waitForDeliveries(t, store, StatusDelivered, 1)
+	if len(delivered) != 1 || delivered[0].Endpoint != "ops" || delivered[0].LastCode != http.StatusNoContent {
+		t.Fatalf("delivered = %+v", delivered)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 1 {
+		t.Fatalf("received %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if got := req.header.Get(HeaderSignature); got != Sign("s3cret", req.body) {
+		t.Fatalf("signature = %q, want %q", got, Sign("s3cret", req.body))
+	}
+	if req.header.Get(HeaderEvent) != EventResponseCompleted || req.header.Get(HeaderDelivery) != delivered[0].ID {
+		t.Fatalf("headers = %v", req.header)
+	}
+	var event Event
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != EventResponseCompleted || event.ID != delivered[0].EventID || string(event.Data) != `{"response_id":"resp_1"}` {
+		t.Fatalf("event = %+v", event)
+	}
+}
+
+func TestDispatcherRetriesServerErrors(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		n := calls
+		mu.Unlock()
+		if n < 3 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	d, store := startDispatcher(t, []Endpoint{{Name: "ops", URL: srv.URL}})
+	d.Emit(EventJobRunFinished, map[string]string{"run_id": "run_1"})
+
+	delivered := waitForDeliveries(t, store, StatusDelivered, 1)
+	if delivered[0].Attempts != 3 || delivered[0].LastError != "" {
+		t.Fatalf("delivered = %+v", delivered[0])
+	}
+}
+
+func TestDispatcherGivesUpOnClientErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown event", http.StatusUnprocessableEntity)
+	}))
+	defer srv.Close()
+
+	d, store := startDispatcher(t, []Endpoint{{Name: "ops", URL: srv.URL}})
+	d.Emit(EventSessionCompacted, map[string]string{"session_id": "sess_1"})
+
+	failed := waitForDeliveries(t, store, StatusFailed, 1)
+	if failed[0].Attempts != 1 || failed[0].LastCode != http.StatusUnprocessableEntity || !strings.Contains(failed[0].LastError, "unknown event") {
+		t.Fatalf("failed = %+v", failed[0])
+	}
+}
+
+func TestNilDispatcherIgnoresEvents(t *testing.T) {
+	var d *Dispatcher
+	d.Emit(EventResponseFailed, nil)
+}
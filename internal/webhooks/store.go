@@ -0,0 +1,338 @@
+// Package webhooks delivers serve events to outbound HTTP endpoints.
+//
+// Each event is queued once per matching endpoint in a SQLite delivery log,
+// so deliveries survive restarts, failed deliveries are retried with
+// exponential backoff, and the log can be inspected and replayed from the
+// CLI while the server is running.
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const schemaVersion = 1
+
+// ErrNotFound is returned for an unknown delivery ID.
+var ErrNotFound = errors.New("webhook delivery not found")
+
+// Delivery states.
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// Delivery is one event queued for one endpoint.
+type Delivery struct {
+	ID            string    `json:"id"`
+	EventID       string    `json:"event_id"`
+	EventType     string    `json:"event_type"`
+	Endpoint      string    `json:"endpoint"`
+	URL           string    `json:"url"`
+	Payload       []byte    `json:"-"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at,omitzero"`
+	LastCode      int       `json:"last_status_code,omitempty"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	DeliveredAt   time.Time `json:"delivered_at,omitzero"`
+}
+
+// ListOptions filters List. Zero values match everything.
+type ListOptions struct {
+	Status   string
+	Endpoint string
+	Event    string
+	Limit    int
+}
+
+// Store is a SQLite-backed delivery log. It is safe to share between a
+// running server and the webhooks CLI.
+type Store struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	version INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id TEXT PRIMARY KEY,
+	event_id TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	endpoint TEXT NOT NULL,
+	url TEXT NOT NULL,
+	payload BLOB NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at INTEGER,
+	last_status_code INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	delivered_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created ON webhook_deliveries(created_at);
+`
+
+// DefaultPath returns the delivery log location inside dataDir.
+func DefaultPath(dataDir string) string {
+	return filepath.Join(dataDir, "serve_webhooks.db")
+}
+
+// Open opens (creating if necessary) the delivery log at path.
+func Open(path string) (*Store, error) {
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, fmt.Errorf("create webhook data directory: %w", err)
+		}
+	}
+
+	dsn := path
+	if strings.Contains(dsn, "?") {
+		dsn += "&"
+	} else {
+		dsn += "?"
+	}
+	dsn += "_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open webhook database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := initSchema(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initialize webhook schema: %w", err)
+	}
+	if path != ":memory:" {
+		// Payloads carry session content, so keep the log private.
+		for _, candidate := range []string{path, path + "-wal", path + "-shm"} {
+			if err := os.Chmod(candidate, 0600); err != nil && !os.IsNotExist(err) {
+				db.Close()
+				return nil, fmt.Errorf("secure webhook database permissions: %w", err)
+			}
+		}
+	}
+	return &Store{db: db, now: time.Now}, nil
+}
+
+func initSchema(db *sql.DB) error {
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+	var version int
+	err := db.QueryRow("SELECT version FROM schema_version LIMIT 1").Scan(&version)
+	if err == sql.ErrNoRows {
+		_, err = db.Exec("INSERT INTO schema_version (version) VALUES (?)", schemaVersion)
+		return err
+	}
+	return err
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Enqueue stores deliveries as pending and due immediately. IDs and
+// timestamps are filled in.
+func (s *Store) Enqueue(ctx context.Context, deliveries ...*Delivery) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := s.now()
+	for _, d := range deliveries {
+		if d.ID == "" {
+			if d.ID, err = newID("whd_"); err != nil {
+				return err
+			}
+		}
+		d.Status = StatusPending
+		d.CreatedAt, d.UpdatedAt, d.NextAttemptAt = now, now, now
+		if _, err := tx.ExecContext(ctx, `INSERT INTO webhook_deliveries
+			(id, event_id, event_type, endpoint, url, payload, status, next_attempt_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			d.ID, d.EventID, d.EventType, d.Endpoint, d.URL, d.Payload, d.Status,
+			now.UnixMilli(), now.UnixMilli(), now.UnixMilli()); err != nil {
+			return fmt.Errorf("enqueue webhook delivery: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Due returns up to limit pending deliveries whose next attempt is due,
+// oldest first.
+func (s *Store) Due(ctx context.Context, limit int) ([]Delivery, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at, created_at LIMIT ?`,
+		StatusPending, s.now().UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+// NextDue returns when the earliest pending delivery is due, or the zero
+// time when nothing is pending.
+func (s *Store) NextDue(ctx context.Context) (time.Time, error) {
+	var ms sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `SELECT MIN(next_attempt_at) FROM webhook_deliveries WHERE status = ?`, StatusPending).Scan(&ms)
+	if err != nil || !ms.Valid {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms.Int64), nil
+}
+
+// MarkDelivered records a successful attempt.
+func (s *Store) MarkDelivered(ctx context.Context, id string, code int) error {
+	now := s.now().UnixMilli()
+	_, err := s.db.ExecContext(ctx, `UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, last_status_code = ?, last_error = '',
+			next_attempt_at = NULL, updated_at = ?, delivered_at = ?
+		WHERE id = ?`, StatusDelivered, code, now, now, id)
+	return err
+}
+
+// MarkAttemptFailed records a failed attempt. A zero next gives up on the
+// delivery; otherwise it is retried at next.
+func (s *Store) MarkAttemptFailed(ctx context.Context, id string, code int, errText string, next time.Time) error {
+	status := StatusPending
+	var nextMS any = next.UnixMilli()
+	if next.IsZero() {
+		status, nextMS = StatusFailed, nil
+	}
+	_, err := s.db.ExecContext(ctx, `UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, last_status_code = ?, last_error = ?,
+			next_attempt_at = ?, updated_at = ?
+		WHERE id = ?`, status, code, errText, nextMS, s.now().UnixMilli(), id)
+	return err
+}
+
+// Retry queues a delivery again, due immediately with a fresh attempt
+// budget. A running server picks it up on its next poll.
+func (s *Store) Retry(ctx context.Context, id string) (Delivery, error) {
+	now := s.now().UnixMilli()
+	res, err := s.db.ExecContext(ctx, `UPDATE webhook_deliveries
+		SET status = ?, attempts = 0, next_attempt_at = ?, updated_at = ?, delivered_at = NULL
+		WHERE id = ?`, StatusPending, now, now, id)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Delivery{}, ErrNotFound
+	}
+	return s.Get(ctx, id)
+}
+
+// Get returns one delivery, including its payload.
+func (s *Store) Get(ctx context.Context, id string) (Delivery, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = ?`, id)
+	if err != nil {
+		return Delivery{}, err
+	}
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if len(deliveries) == 0 {
+		return Delivery{}, ErrNotFound
+	}
+	return deliveries[0], nil
+}
+
+// List returns deliveries matching opts, newest first.
+func (s *Store) List(ctx context.Context, opts ListOptions) ([]Delivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE 1 = 1`
+	var args []any
+	if opts.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, opts.Status)
+	}
+	if opts.Endpoint != "" {
+		query += ` AND endpoint = ?`
+		args = append(args, opts.Endpoint)
+	}
+	if opts.Event != "" {
+		query += ` AND event_type = ?`
+		args = append(args, opts.Event)
+	}
+	query += ` ORDER BY created_at DESC, id DESC`
+	if opts.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, opts.Limit)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+// Prune deletes delivered and failed deliveries created before cutoff.
+// Pending deliveries are always kept.
+func (s *Store) Prune(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE status != ? AND created_at < ?`,
+		StatusPending, cutoff.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+const deliveryColumns = `id, event_id, event_type, endpoint, url, payload, status, attempts,
+	next_attempt_at, last_status_code, last_error, created_at, updated_at, delivered_at`
+
+func scanDeliveries(rows *sql.Rows) ([]Delivery, error) {
+	defer rows.Close()
+	var out []Delivery
+	for rows.Next() {
+		var (
+			d                    Delivery
+			next, delivered      sql.NullInt64
+			createdAt, updatedAt int64
+		)
+		if err := rows.Scan(&d.ID, &d.EventID, &d.EventType, &d.Endpoint, &d.URL, &d.Payload, &d.Status, &d.Attempts,
+			&next, &d.LastCode, &d.LastError, &createdAt, &updatedAt, &delivered); err != nil {
+			return nil, err
+		}
+		d.CreatedAt = time.UnixMilli(createdAt)
+		d.UpdatedAt = time.UnixMilli(updatedAt)
+		if next.Valid {
+			d.NextAttemptAt = time.UnixMilli(next.Int64)
+		}
+		if delivered.Valid {
+			d.DeliveredAt = time.UnixMilli(delivered.Int64)
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func newID(prefix string) (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generate webhook id: %w", err)
+	}
+	return prefix + hex.EncodeToString(b[:]), nil
+}
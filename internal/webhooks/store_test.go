@@ -0,0 +1,118 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "webhooks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStoreDeliveryLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	first := &Delivery{EventID: "evt_1", EventType: EventResponseCompleted, Endpoint: "ops", URL: "https://ops.example/hook", Payload: []byte(`{"id":"evt_1"}`)}
+	second := &Delivery{EventID: "evt_1", EventType: EventResponseCompleted, Endpoint: "audit", URL: "https://audit.example/hook", Payload: []byte(`{"id":"evt_1"}`)}
+	if err := store.Enqueue(ctx, first, second); err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == "" || first.ID == second.ID || first.Status != StatusPending {
+		t.Fatalf("enqueued = %+v, %+v", first, second)
+	}
+
+	due, err := store.Due(ctx, 10)
+	if err != nil || len(due) != 2 {
+		t.Fatalf("Due = %d deliveries, %v", len(due), err)
+	}
+
+	retryAt := now.Add(time.Minute)
+	if err := store.MarkAttemptFailed(ctx, first.ID, 503, "HTTP 503: busy", retryAt); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MarkDelivered(ctx, second.ID, 204); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := store.Due(ctx, 10); err != nil || len(due) != 0 {
+		t.Fatalf("Due before retry time = %d deliveries, %v", len(due), err)
+	}
+	next, err := store.NextDue(ctx)
+	if err != nil || !next.Equal(retryAt) {
+		t.Fatalf("NextDue = %v, %v; want %v", next, err, retryAt)
+	}
+
+	got, err := store.Get(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatusPending || got.Attempts != 1 || got.LastCode != 503 || got.LastError != "HTTP 503: busy" || string(got.Payload) != `{"id":"evt_1"}` {
+		t.Fatalf("after failed attempt = %+v", got)
+	}
+
+	if err := store.MarkAttemptFailed(ctx, first.ID, 400, "HTTP 400: bad", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	failed, err := store.List(ctx, ListOptions{Status: StatusFailed})
+	if err != nil || len(failed) != 1 || failed[0].ID != first.ID || !failed[0].NextAttemptAt.IsZero() {
+		t.Fatalf("failed deliveries = %+v, %v", failed, err)
+	}
+	if next, err := store.NextDue(ctx); err != nil || !next.IsZero() {
+		t.Fatalf("NextDue with nothing pending = %v, %v", next, err)
+	}
+
+	retried, err := store.Retry(ctx, first.ID)
+	if err != nil || retried.Status != StatusPending || retried.Attempts != 0 {
+		t.Fatalf("Retry = %+v, %v", retried, err)
+	}
+	if _, err := store.Retry(ctx, "whd_missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Retry(missing) err = %v", err)
+	}
+
+	byEndpoint, err := store.List(ctx, ListOptions{Endpoint: "audit"})
+	if err != nil || len(byEndpoint) != 1 || byEndpoint[0].Status != StatusDelivered || byEndpoint[0].DeliveredAt.IsZero() {
+		t.Fatalf("audit deliveries = %+v, %v", byEndpoint, err)
+	}
+}
+
+func TestStorePruneKeepsPending(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	old := []*Delivery{
+		{EventID: "evt_1", EventType: EventPing, Endpoint: "a", URL: "https://a.example", Payload: []byte(`{}`)},
+		{EventID: "evt_2", EventType: EventPing, Endpoint: "a", URL: "https://a.example", Payload: []byte(`{}`)},
+		{EventID: "evt_3", EventType: EventPing, Endpoint: "a", URL: "https://a.example", Payload: []byte(`{}`)},
+	}
+	if err := store.Enqueue(ctx, old...); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MarkDelivered(ctx, old[0].ID, 200); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.MarkAttemptFailed(ctx, old[1].ID, 410, "gone", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := store.Prune(ctx, now.Add(time.Hour))
+	if err != nil || n != 2 {
+		t.Fatalf("Prune = %d, %v; want 2", n, err)
+	}
+	left, err := store.List(ctx, ListOptions{})
+	if err != nil || len(left) != 1 || left[0].ID != old[2].ID {
+		t.Fatalf("remaining = %+v, %v", left, err)
+	}
+}